@@ -16,10 +16,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/puppetlabs/wash/analytics"
 	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/metrics"
+	"github.com/puppetlabs/wash/retention"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -41,6 +44,14 @@ var journalDir = func() string {
 }()
 var expires = 30 * time.Second
 
+// The journals (and their exec transcripts) are pruned according to the
+// "journals" retention policy. Journals that are open are never pruned.
+var _ = retention.Register("journals", Dir, func(name string) bool {
+	id := strings.TrimSuffix(strings.TrimSuffix(name, ".log"), ".transcripts")
+	obj, _ := recorderCache.Get("", id)
+	return obj != nil
+})
+
 // CloseAll ensures open journals are flushed to disk and closed.
 // Use when the application is shutting down.
 func CloseAll() {
@@ -89,6 +100,7 @@ func Record(ctx context.Context, msg string, a ...interface{}) {
 // provided context at WARN level. It also writes to the server logs at the same level. Use Warnf
 // in plugin `Init` methods for issues during setup that will result in degraded behavior.
 func Warnf(ctx context.Context, msg string, a ...interface{}) {
+	metrics.RecordError(fmt.Sprintf(msg, a...))
 	journal, ok := ctx.Value(JournalKey).(Journal)
 	if !ok {
 		log.Warnf(msg, a...)
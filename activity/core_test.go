@@ -10,6 +10,7 @@ import (
 
 	"github.com/Benchkram/errz"
 	"github.com/puppetlabs/wash/analytics"
+	"github.com/puppetlabs/wash/retention"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -80,6 +81,37 @@ func TestLogging(t *testing.T) {
 	}
 }
 
+func TestPruneKeepsOpenJournals(t *testing.T) {
+	// Ensure the cache is cleaned up afterward.
+	defer CloseAll()
+	Record(context.WithValue(context.Background(), JournalKey, Journal{ID: "open"}), "hello there")
+	for _, name := range []string{"closed.log", "closed.transcripts.log"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(Dir(), name), []byte("old"), 0640))
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"open.log", "closed.log", "closed.transcripts.log"} {
+		assert.Nil(t, os.Chtimes(filepath.Join(Dir(), name), old, old))
+	}
+
+	var journals *retention.Store
+	for _, s := range retention.All() {
+		if s.Name() == "journals" {
+			journals = s
+		}
+	}
+	if !assert.NotNil(t, journals) {
+		return
+	}
+	result, err := journals.Prune(retention.Policy{MaxAgeDays: 1})
+	if assert.Nil(t, err) {
+		assert.Contains(t, result.Removed, "closed.log")
+		assert.Contains(t, result.Removed, "closed.transcripts.log")
+		assert.NotContains(t, result.Removed, "open.log")
+	}
+	_, err = os.Stat(filepath.Join(Dir(), "open.log"))
+	assert.Nil(t, err)
+}
+
 func TestSubmitMethodInvocation_NewMethodInvocation_SubmitsToGA(t *testing.T) {
 	// Setup the mocks
 	ctx := context.Background()
@@ -0,0 +1,223 @@
+package activity
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TranscriptEventType is the type of an exec transcript event
+type TranscriptEventType = string
+
+// These are the possible transcript event types
+const (
+	TranscriptStart  TranscriptEventType = "start"
+	TranscriptStdout TranscriptEventType = "stdout"
+	TranscriptStderr TranscriptEventType = "stderr"
+	TranscriptExit   TranscriptEventType = "exit"
+)
+
+// TranscriptEvent is a timestamped event in an exec transcript
+type TranscriptEvent struct {
+	Time time.Time           `json:"time"`
+	Type TranscriptEventType `json:"type"`
+	// Data is the output if Type is TranscriptStdout or TranscriptStderr
+	Data string `json:"data,omitempty"`
+	// ExitCode and Err are set if Type is TranscriptExit
+	ExitCode int    `json:"exit_code,omitempty"`
+	Err      string `json:"error,omitempty"`
+}
+
+// ExecTranscript is the transcript of a command that was executed on an entry.
+// Its events are in the order that they happened, so its stdout and stderr
+// output is interleaved.
+type ExecTranscript struct {
+	Path string   `json:"path"`
+	Cmd  string   `json:"cmd"`
+	Args []string `json:"args"`
+	// Justification is why the user ran the command, if the exec policy
+	// required one
+	Justification string            `json:"justification,omitempty"`
+	Events        []TranscriptEvent `json:"events"`
+}
+
+// Start returns when the command started
+func (t ExecTranscript) Start() time.Time {
+	if len(t.Events) == 0 {
+		return time.Time{}
+	}
+	return t.Events[0].Time
+}
+
+// Duration returns how long the command ran for. It's the time of the last
+// recorded event if the command didn't exit.
+func (t ExecTranscript) Duration() time.Duration {
+	if len(t.Events) == 0 {
+		return 0
+	}
+	return t.Events[len(t.Events)-1].Time.Sub(t.Start())
+}
+
+// transcriptLine is a line in a journal's transcripts file. Each line is
+// tagged by its exec's ID so that the transcripts of concurrent execs can
+// be separated.
+type transcriptLine struct {
+	Exec int64 `json:"exec"`
+	TranscriptEvent
+	// Path, Cmd, Args, and Justification are set on start events
+	Path          string   `json:"path,omitempty"`
+	Cmd           string   `json:"cmd,omitempty"`
+	Args          []string `json:"args,omitempty"`
+	Justification string   `json:"justification,omitempty"`
+}
+
+// TranscriptRecorder records an exec's transcript to its journal's transcripts
+// file. It is safe for concurrent use.
+type TranscriptRecorder struct {
+	id   int64
+	mux  sync.Mutex
+	file *os.File
+}
+
+// lastTranscriptID is seeded with the current time so that the IDs of execs
+// recorded by different Wash server sessions don't collide if they're stored
+// in the same journal.
+var lastTranscriptID = time.Now().UnixNano()
+
+// RecordExec starts recording the transcript of an exec of cmd with args on the
+// entry at path. justification is why the user ran it; it's optional. The
+// transcript is stored alongside the journal identified by the ID at
+// `activity.JournalKey` in the provided context. Callers must call Exit when
+// the command's done. Failures to record the transcript are logged
+// instead of being returned since they shouldn't fail the exec.
+func RecordExec(ctx context.Context, path string, cmd string, args []string, justification string) *TranscriptRecorder {
+	journal, ok := ctx.Value(JournalKey).(Journal)
+	if !ok || journal.ID == "" {
+		journal = deadLetterOfficeJournal
+	}
+
+	t := &TranscriptRecorder{id: atomic.AddInt64(&lastTranscriptID, 1)}
+	tpath := journal.transcriptsFilepath()
+	if err := os.MkdirAll(filepath.Dir(tpath), 0750); err != nil {
+		log.Warnf("Error creating the transcripts file for journal %v: %v", journal.ID, err)
+		return t
+	}
+	f, err := os.OpenFile(tpath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		log.Warnf("Error creating the transcripts file for journal %v: %v", journal.ID, err)
+		return t
+	}
+	t.file = f
+	t.write(transcriptLine{
+		TranscriptEvent: TranscriptEvent{Time: time.Now(), Type: TranscriptStart},
+		Path:            path,
+		Cmd:             cmd,
+		Args:            args,
+		Justification:   justification,
+	})
+	return t
+}
+
+// Output records a chunk of the command's stdout or stderr output
+func (t *TranscriptRecorder) Output(eventType TranscriptEventType, timestamp time.Time, data string) {
+	t.write(transcriptLine{TranscriptEvent: TranscriptEvent{Time: timestamp, Type: eventType, Data: data}})
+}
+
+// Exit records the command's exit code, or the error that prevented Wash from
+// getting it. It also closes t.
+func (t *TranscriptRecorder) Exit(exitCode int, err error) {
+	event := TranscriptEvent{Time: time.Now(), Type: TranscriptExit, ExitCode: exitCode}
+	if err != nil {
+		event.Err = err.Error()
+	}
+	t.write(transcriptLine{TranscriptEvent: event})
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.file != nil {
+		if err := t.file.Close(); err != nil {
+			log.Warnf("Failed closing transcripts file %v: %v", t.file.Name(), err)
+		}
+		t.file = nil
+	}
+}
+
+func (t *TranscriptRecorder) write(line transcriptLine) {
+	line.Exec = t.id
+	bits, err := json.Marshal(line)
+	if err != nil {
+		// This should never happen
+		panic(fmt.Sprintf("Error marshalling transcript line %+v: %v", line, err))
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.file == nil {
+		return
+	}
+	if _, err := t.file.Write(append(bits, '\n')); err != nil {
+		log.Warnf("Failed writing to transcripts file %v: %v", t.file.Name(), err)
+	}
+}
+
+// Transcripts returns the transcripts of the execs that were recorded in the
+// journal, sorted by when they started.
+func (j Journal) Transcripts() ([]ExecTranscript, error) {
+	f, err := os.Open(j.transcriptsFilepath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ExecTranscript{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	transcripts := make(map[int64]*ExecTranscript)
+	var ids []int64
+	scanner := bufio.NewScanner(f)
+	// Output chunks can be larger than the scanner's default max token size
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		var line transcriptLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			// The line was likely truncated by a crash. Skip it.
+			log.Debugf("Skipping malformed transcript line %q: %v", scanner.Text(), err)
+			continue
+		}
+		t, ok := transcripts[line.Exec]
+		if !ok {
+			t = &ExecTranscript{}
+			transcripts[line.Exec] = t
+			ids = append(ids, line.Exec)
+		}
+		if line.Type == TranscriptStart {
+			t.Path, t.Cmd, t.Args, t.Justification = line.Path, line.Cmd, line.Args, line.Justification
+		}
+		t.Events = append(t.Events, line.TranscriptEvent)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]ExecTranscript, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, *transcripts[id])
+	}
+	sort.SliceStable(result, func(i, k int) bool {
+		return result[i].Start().Before(result[k].Start())
+	})
+	return result, nil
+}
+
+func (j Journal) transcriptsFilepath() string {
+	return filepath.Join(Dir(), j.ID+".transcripts.log")
+}
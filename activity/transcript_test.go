@@ -0,0 +1,109 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TranscriptTestSuite struct {
+	suite.Suite
+	origDir string
+}
+
+func (s *TranscriptTestSuite) SetupTest() {
+	s.origDir = Dir()
+	dir, err := ioutil.TempDir("", "wash-transcripts")
+	if err != nil {
+		s.FailNow(err.Error())
+	}
+	SetDir(dir)
+}
+
+func (s *TranscriptTestSuite) TearDownTest() {
+	s.NoError(os.RemoveAll(Dir()))
+	SetDir(s.origDir)
+}
+
+func (s *TranscriptTestSuite) TestTranscripts_NoExecs() {
+	transcripts, err := Journal{ID: "none"}.Transcripts()
+	if s.NoError(err) {
+		s.Equal([]ExecTranscript{}, transcripts)
+	}
+}
+
+func (s *TranscriptTestSuite) TestRecordExec() {
+	journal := Journal{ID: "execs"}
+	ctx := context.WithValue(context.Background(), JournalKey, journal)
+
+	start := time.Now()
+	first := RecordExec(ctx, "/docker/containers/foo", "ls", []string{"-l"}, "")
+	// Interleave the second exec's events with the first's to ensure that
+	// they're separated.
+	second := RecordExec(ctx, "/docker/containers/bar", "false", nil, "TICKET-1")
+	first.Output(TranscriptStdout, start.Add(time.Second), "a\n")
+	first.Output(TranscriptStderr, start.Add(2*time.Second), "oops\n")
+	second.Exit(1, nil)
+	first.Output(TranscriptStdout, start.Add(3*time.Second), "b\n")
+	first.Exit(0, nil)
+
+	transcripts, err := journal.Transcripts()
+	if !s.NoError(err) || !s.Len(transcripts, 2) {
+		return
+	}
+
+	t := transcripts[0]
+	s.Equal("/docker/containers/foo", t.Path)
+	s.Equal("ls", t.Cmd)
+	s.Equal([]string{"-l"}, t.Args)
+	s.Empty(t.Justification)
+	var types []string
+	var output string
+	for _, event := range t.Events {
+		types = append(types, event.Type)
+		output += event.Data
+	}
+	s.Equal([]string{TranscriptStart, TranscriptStdout, TranscriptStderr, TranscriptStdout, TranscriptExit}, types)
+	s.Equal("a\noops\nb\n", output)
+
+	t = transcripts[1]
+	s.Equal("/docker/containers/bar", t.Path)
+	s.Equal("false", t.Cmd)
+	s.Equal("TICKET-1", t.Justification)
+	if s.Len(t.Events, 2) {
+		s.Equal(TranscriptExit, t.Events[1].Type)
+		s.Equal(1, t.Events[1].ExitCode)
+	}
+}
+
+func (s *TranscriptTestSuite) TestRecordExec_ExitCodeError() {
+	journal := Journal{ID: "failed"}
+	ctx := context.WithValue(context.Background(), JournalKey, journal)
+
+	RecordExec(ctx, "/foo", "ls", nil, "").Exit(0, fmt.Errorf("connection lost"))
+
+	transcripts, err := journal.Transcripts()
+	if s.NoError(err) && s.Len(transcripts, 1) && s.Len(transcripts[0].Events, 2) {
+		s.Equal("connection lost", transcripts[0].Events[1].Err)
+	}
+}
+
+func (s *TranscriptTestSuite) TestExecTranscript_Duration() {
+	start := time.Now()
+	t := ExecTranscript{Events: []TranscriptEvent{
+		{Time: start, Type: TranscriptStart},
+		{Time: start.Add(5 * time.Second), Type: TranscriptExit},
+	}}
+	s.Equal(start, t.Start())
+	s.Equal(5*time.Second, t.Duration())
+	s.Equal(time.Duration(0), ExecTranscript{}.Duration())
+}
+
+func TestTranscript(t *testing.T) {
+	suite.Run(t, new(TranscriptTestSuite))
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+)
+
+// The admin endpoints are mounted under /admin. Unlike the rest of the API,
+// they require the admin token that the server saves next to its socket. The
+// socket's directory is group-accessible, but the token's only readable by the
+// user that started the server, so this restricts the admin endpoints to that
+// user. The endpoints that change the server's behavior (e.g. tuning limits,
+// toggling features, clearing the cache and cancelling requests) require it
+// too.
+
+const adminTokenBytes = 32
+
+// newAdminToken generates a random admin token and saves it to path
+func newAdminToken(path string) (string, error) {
+	b := make([]byte, adminTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	// Remove any stale token first so that the new one's created with the
+	// right permissions
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err := ioutil.WriteFile(path, []byte(token), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// requireAdmin only passes the requests that include token on to next
+func requireAdmin(token string, next http.Handler) handler {
+	return func(w http.ResponseWriter, r *http.Request) *errorResponse {
+		auth := r.Header.Get(apitypes.AdminTokenHeader)
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return unauthorizedResponse("the request is missing the admin token")
+		}
+		got := strings.TrimPrefix(auth, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return unauthorizedResponse("the admin token is invalid")
+		}
+		next.ServeHTTP(w, r)
+		return nil
+	}
+}
+
+// swagger:route GET /admin/debug/pprof/{profile} adminProfile
+//
+// Runtime profiles of the Wash server
+//
+// Serves the profiles of the Go runtime's net/http/pprof package, e.g.
+// /admin/debug/pprof/profile?seconds=30 for a CPU profile and
+// /admin/debug/pprof/heap for a heap profile. Requests must set the
+// Authorization header to "Bearer <token>", where <token> is the content of
+// the admin token file.
+//
+//     Produces:
+//     - application/octet-stream
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: octetResponse
+//       401: errorResp
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// pprof.Index expects the paths to start with /debug/pprof/
+	return http.StripPrefix("/admin", mux)
+}
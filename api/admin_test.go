@@ -0,0 +1,108 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type AdminTestSuite struct {
+	suite.Suite
+}
+
+func (suite *AdminTestSuite) TestNewAdminTokenIsOnlyReadableByTheOwner() {
+	path := filepath.Join(suite.T().TempDir(), "api.sock.token")
+	// A stale token's replaced
+	suite.NoError(ioutil.WriteFile(path, []byte("stale"), 0644))
+
+	token, err := newAdminToken(path)
+	if suite.NoError(err) {
+		suite.Len(token, 2*adminTokenBytes)
+		info, err := os.Stat(path)
+		if suite.NoError(err) {
+			suite.Equal(os.FileMode(0600), info.Mode().Perm())
+		}
+		saved, err := ioutil.ReadFile(path)
+		suite.NoError(err)
+		suite.Equal(token, string(saved))
+	}
+}
+
+func (suite *AdminTestSuite) TestRequireAdmin() {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := requireAdmin("secret", next)
+
+	for auth, expected := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"secret":        http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/debug/pprof/heap", nil)
+		if auth != "" {
+			req.Header.Set(apitypes.AdminTokenHeader, auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		suite.Equal(expected, w.Code, "Authorization: %v", auth)
+		if expected == http.StatusUnauthorized {
+			suite.Contains(w.Body.String(), apitypes.Unauthorized)
+		}
+	}
+}
+
+func (suite *AdminTestSuite) TestRequireCredentialAccess() {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	router := mux.NewRouter()
+	router.Handle("/admin/credentials/{name:.+}", requireCredentialAccess("secret", next))
+
+	token, err := plugin.CredentialsTokenFor("fooplugin")
+	suite.Require().NoError(err)
+	for _, test := range []struct {
+		name     string
+		auth     string
+		expected int
+	}{
+		{"fooplugin", "", http.StatusUnauthorized},
+		{"fooplugin", "Bearer wrong", http.StatusUnauthorized},
+		{"aws/default", "Bearer secret", http.StatusOK},
+		{"fooplugin", "Bearer " + token, http.StatusOK},
+		{"fooplugin/nested", "Bearer " + token, http.StatusOK},
+		{"aws/default", "Bearer " + token, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/credentials/"+test.name, nil)
+		if test.auth != "" {
+			req.Header.Set(apitypes.AdminTokenHeader, test.auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(test.expected, w.Code, "%v with Authorization: %v", test.name, test.auth)
+		if test.expected == http.StatusForbidden {
+			suite.Contains(w.Body.String(), apitypes.CredentialForbidden)
+		}
+	}
+}
+
+func (suite *AdminTestSuite) TestPprofHandlerServesTheProfiles() {
+	req := httptest.NewRequest(http.MethodGet, "/admin/debug/pprof/heap", nil)
+	w := httptest.NewRecorder()
+	pprofHandler().ServeHTTP(w, req)
+	suite.Equal(http.StatusOK, w.Code)
+	suite.NotEmpty(w.Body.Bytes())
+}
+
+func TestAdmin(t *testing.T) {
+	suite.Run(t, new(AdminTestSuite))
+}
@@ -0,0 +1,359 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// These limits bound the archived subtree so that archiving an over-broad
+// entry (e.g. a plugin's root) doesn't stream the plugin's entire hierarchy
+var archiveMaxDepth = limits.Register(
+	"api.archive_max_depth",
+	"The maximum depth of the subtree that an archive (see `/fs/archive`) includes. The children of deeper parents are skipped. 0 disables the limit.",
+	32,
+	nil,
+)
+
+var archiveMaxSize = limits.Register(
+	"api.archive_max_mb",
+	"The maximum size (in megabytes) of the content that an archive (see `/fs/archive`) includes. Files that would exceed it are skipped. 0 disables the limit.",
+	1024,
+	nil,
+)
+
+// archiveErrorsName is the name of the file that lists the entries that were
+// skipped (and why). It's only included in archives that skipped entries.
+const archiveErrorsName = ".wash-archive-errors"
+
+// swagger:parameters getArchive
+//nolint:deadcode,unused
+type archiveParams struct {
+	// the archive's format, either tar (the default) or zip
+	//
+	// in: query
+	Format string
+}
+
+// swagger:route GET /fs/archive archive getArchive
+//
+// Get an archive of a subtree
+//
+// Streams an archive of the subtree rooted at the specified entry. The
+// archive is generated on the fly. It contains a directory for each
+// listable entry and a file for each readable entry. Entries that fail
+// to list or read, or that exceed the api.archive_max_depth and
+// api.archive_max_mb limits, are skipped. They're listed (with their
+// errors) in the archive's .wash-archive-errors file, and logged in the
+// activity journal.
+//
+//     Produces:
+//     - application/json
+//     - application/x-tar
+//     - application/zip
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: octetResponse
+//       400: errorResp
+//       404: errorResp
+//       500: errorResp
+var archiveHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	ctx := r.Context()
+	entry, path, errResp := getEntryFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+
+	if !plugin.ListAction().IsSupportedOn(entry) && !plugin.ReadAction().IsSupportedOn(entry) {
+		return unsupportedActionResponse(path, entry, plugin.ListAction())
+	}
+
+	name := plugin.CName(entry)
+	if name == "" || name == "/" {
+		name = "wash"
+	}
+
+	var aw archiveWriter
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "tar":
+		format = "tar"
+		w.Header().Set("Content-Type", "application/x-tar")
+		aw = tarArchiveWriter{tar.NewWriter(w)}
+	case "zip":
+		w.Header().Set("Content-Type", "application/zip")
+		aw = zipArchiveWriter{zip.NewWriter(w)}
+	default:
+		return badRequestResponse(fmt.Sprintf("unsupported archive format %v; supported formats are tar and zip", format))
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+
+	activity.Record(ctx, "API: Archive %v as %v", path, format)
+	a := newArchiver(aw)
+	if err := a.write(ctx, entry, path, name, 0); err != nil {
+		// The archive's already being streamed, so we can't return an error
+		// response. The client will get a truncated archive.
+		activity.Warnf(ctx, "API: Archive %v aborted: %v", path, err)
+		return nil
+	}
+	errorsName := archiveErrorsName
+	if _, ok := entry.(plugin.Parent); ok && plugin.ListAction().IsSupportedOn(entry) {
+		errorsName = name + "/" + archiveErrorsName
+	}
+	if err := a.writeErrors(errorsName); err != nil {
+		activity.Warnf(ctx, "API: Archive %v aborted: %v", path, err)
+		return nil
+	}
+	if err := aw.Close(); err != nil {
+		activity.Warnf(ctx, "API: Archive %v could not be finalized: %v", path, err)
+	}
+	return nil
+}
+
+// archiver writes a subtree to an archive, keeping track of the archived
+// content's size and of the entries that were skipped
+type archiver struct {
+	aw       archiveWriter
+	maxDepth int
+	maxSize  int64
+	size     int64
+	errors   []string
+}
+
+func newArchiver(aw archiveWriter) *archiver {
+	return &archiver{
+		aw:       aw,
+		maxDepth: archiveMaxDepth.Value(),
+		maxSize:  int64(archiveMaxSize.Value()) * 1024 * 1024,
+	}
+}
+
+// skip records that path (or part of it) was skipped
+func (a *archiver) skip(ctx context.Context, path string, format string, args ...interface{}) {
+	reason := fmt.Sprintf(format, args...)
+	activity.Warnf(ctx, "API: Archive skipping %v: %v", path, reason)
+	a.errors = append(a.errors, path+": "+reason)
+}
+
+// write writes the subtree rooted at entry to the archive. depth is entry's
+// depth below the archived entry. Entries that error are skipped. The
+// returned error is non-nil only if writing to the archive failed (e.g.
+// because the client disconnected).
+func (a *archiver) write(ctx context.Context, entry plugin.Entry, path string, name string, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	attr := plugin.Attributes(entry)
+
+	if parent, ok := entry.(plugin.Parent); ok && plugin.ListAction().IsSupportedOn(entry) {
+		if err := a.aw.WriteDir(name, attr); err != nil {
+			return err
+		}
+		if a.maxDepth > 0 && depth >= a.maxDepth {
+			a.skip(ctx, path, "its children are deeper than the %v limit", archiveMaxDepth.Name())
+			return nil
+		}
+		children, err := plugin.List(ctx, parent)
+		if err != nil {
+			a.skip(ctx, path, "could not list its children: %v", err)
+			return nil
+		}
+		cnames := make([]string, 0, len(children))
+		for cname := range children {
+			cnames = append(cnames, cname)
+		}
+		sort.Strings(cnames)
+		for _, cname := range cnames {
+			if err := a.write(ctx, children[cname], path+"/"+cname, name+"/"+cname, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if readable, ok := entry.(plugin.Readable); ok && plugin.ReadAction().IsSupportedOn(entry) {
+		content, err := plugin.Open(ctx, readable)
+		if err != nil {
+			a.skip(ctx, path, "could not read it: %v", err)
+			return nil
+		}
+		if closer, ok := content.(io.Closer); ok {
+			defer func() {
+				if err := closer.Close(); err != nil {
+					activity.Warnf(ctx, "API: Archive could not close the content of %v: %v", path, err)
+				}
+			}()
+		}
+		if a.maxSize > 0 && a.size+content.Size() > a.maxSize {
+			a.skip(ctx, path, "its content would exceed the %v limit", archiveMaxSize.Name())
+			return nil
+		}
+		a.size += content.Size()
+		err = a.aw.WriteFile(name, attr, content)
+		if readErr, ok := err.(archiveReadError); ok {
+			// The file's entry is still valid (see archiveWriter), so the
+			// rest of the archive can be written
+			a.skip(ctx, path, "could not read all of it: %v", readErr.err)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// writeErrors writes the file that lists the skipped entries, if there are
+// any
+func (a *archiver) writeErrors(name string) error {
+	if len(a.errors) == 0 {
+		return nil
+	}
+	var errors strings.Builder
+	for _, err := range a.errors {
+		errors.WriteString(err + "\n")
+	}
+	attr := plugin.EntryAttributes{}
+	attr.SetMode(0444)
+	return a.aw.WriteFile(name, attr, strings.NewReader(errors.String()))
+}
+
+// archiveReadError is returned by an archiveWriter's WriteFile when reading
+// the file's content failed. The rest of the archive can still be written.
+type archiveReadError struct {
+	err error
+}
+
+func (e archiveReadError) Error() string {
+	return e.err.Error()
+}
+
+// copyContent copies content to w. Content that errors or that's shorter than
+// its size returns an archiveReadError along with the number of copied bytes.
+func copyContent(w io.Writer, content plugin.SizedReader) (int64, error) {
+	size := content.Size()
+	r := &archiveContentReader{r: io.NewSectionReader(content, 0, size)}
+	n, err := io.Copy(w, r)
+	if r.err != nil {
+		return n, archiveReadError{r.err}
+	}
+	if err == nil && n < size {
+		return n, archiveReadError{fmt.Errorf("read %v of its %v bytes", n, size)}
+	}
+	return n, err
+}
+
+// archiveContentReader distinguishes read errors from write errors
+type archiveContentReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *archiveContentReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// archiveWriter is implemented by each of the supported archive formats.
+// WriteFile returns an archiveReadError if the content couldn't be read, in
+// which case the file's entry is still valid (e.g. it's padded to its size).
+type archiveWriter interface {
+	WriteDir(name string, attr plugin.EntryAttributes) error
+	WriteFile(name string, attr plugin.EntryAttributes, content plugin.SizedReader) error
+	Close() error
+}
+
+func archiveModeOf(attr plugin.EntryAttributes, defaultMode os.FileMode) os.FileMode {
+	if attr.HasMode() {
+		return attr.Mode().Perm()
+	}
+	return defaultMode
+}
+
+func archiveMtimeOf(attr plugin.EntryAttributes) time.Time {
+	if attr.HasMtime() {
+		return attr.Mtime()
+	}
+	return time.Now()
+}
+
+type tarArchiveWriter struct {
+	*tar.Writer
+}
+
+func (w tarArchiveWriter) WriteDir(name string, attr plugin.EntryAttributes) error {
+	return w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     int64(archiveModeOf(attr, 0755)),
+		ModTime:  archiveMtimeOf(attr),
+	})
+}
+
+func (w tarArchiveWriter) WriteFile(name string, attr plugin.EntryAttributes, content plugin.SizedReader) error {
+	size := content.Size()
+	err := w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(archiveModeOf(attr, 0644)),
+		ModTime:  archiveMtimeOf(attr),
+		Size:     size,
+	})
+	if err != nil {
+		return err
+	}
+	n, err := copyContent(w, content)
+	if _, ok := err.(archiveReadError); ok {
+		// Tar headers include the file's size, so a short read has to be
+		// padded to keep the archive valid
+		if _, padErr := io.CopyN(w, zeroReader{}, size-n); padErr != nil {
+			return padErr
+		}
+	}
+	return err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+type zipArchiveWriter struct {
+	*zip.Writer
+}
+
+func (w zipArchiveWriter) WriteDir(name string, attr plugin.EntryAttributes) error {
+	hdr := &zip.FileHeader{Name: name + "/", Modified: archiveMtimeOf(attr)}
+	hdr.SetMode(os.ModeDir | archiveModeOf(attr, 0755))
+	_, err := w.CreateHeader(hdr)
+	return err
+}
+
+func (w zipArchiveWriter) WriteFile(name string, attr plugin.EntryAttributes, content plugin.SizedReader) error {
+	hdr := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: archiveMtimeOf(attr)}
+	hdr.SetMode(archiveModeOf(attr, 0644))
+	fw, err := w.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = copyContent(fw, content)
+	return err
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTarArchiveWriter(t *testing.T) {
+	var buf bytes.Buffer
+	aw := tarArchiveWriter{tar.NewWriter(&buf)}
+	attr := plugin.EntryAttributes{}
+	assert.NoError(t, aw.WriteDir("foo", attr))
+	assert.NoError(t, aw.WriteFile("foo/bar", attr, strings.NewReader("hello")))
+	assert.NoError(t, aw.Close())
+
+	rdr := tar.NewReader(&buf)
+	hdr, err := rdr.Next()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "foo/", hdr.Name)
+		assert.Equal(t, byte(tar.TypeDir), hdr.Typeflag)
+	}
+	hdr, err = rdr.Next()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "foo/bar", hdr.Name)
+		assert.Equal(t, int64(0644), hdr.Mode)
+		content, err := ioutil.ReadAll(rdr)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(content))
+	}
+}
+
+func TestZipArchiveWriter(t *testing.T) {
+	var buf bytes.Buffer
+	aw := zipArchiveWriter{zip.NewWriter(&buf)}
+	attr := plugin.EntryAttributes{}
+	attr.SetMode(0600)
+	assert.NoError(t, aw.WriteDir("foo", plugin.EntryAttributes{}))
+	assert.NoError(t, aw.WriteFile("foo/bar", attr, strings.NewReader("hello")))
+	assert.NoError(t, aw.Close())
+
+	rdr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if !assert.NoError(t, err) || !assert.Len(t, rdr.File, 2) {
+		return
+	}
+	assert.Equal(t, "foo/", rdr.File[0].Name)
+	assert.True(t, rdr.File[0].FileInfo().IsDir())
+	assert.Equal(t, "foo/bar", rdr.File[1].Name)
+	assert.Equal(t, "-rw-------", rdr.File[1].Mode().String())
+	f, err := rdr.File[1].Open()
+	if assert.NoError(t, err) {
+		content, err := ioutil.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(content))
+	}
+}
+
+type archiveTestsFile struct {
+	plugin.EntryBase
+	content string
+	readErr error
+	closed  bool
+}
+
+func newArchiveTestsFile(name string, content string) *archiveTestsFile {
+	f := &archiveTestsFile{EntryBase: plugin.NewEntry(name), content: content}
+	f.DisableDefaultCaching()
+	return f
+}
+
+func (f *archiveTestsFile) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+func (f *archiveTestsFile) Open(context.Context) (plugin.SizedReader, error) {
+	return &archiveTestsContent{Reader: strings.NewReader(f.content), f: f}, nil
+}
+
+type archiveTestsContent struct {
+	*strings.Reader
+	f *archiveTestsFile
+}
+
+func (c *archiveTestsContent) ReadAt(p []byte, off int64) (int, error) {
+	if c.f.readErr != nil && off > 0 {
+		return 0, c.f.readErr
+	}
+	if c.f.readErr != nil {
+		// Only the first byte is readable
+		n, _ := c.Reader.ReadAt(p[:1], off)
+		return n, nil
+	}
+	return c.Reader.ReadAt(p, off)
+}
+
+func (c *archiveTestsContent) Close() error {
+	c.f.closed = true
+	return nil
+}
+
+// archiveTestsTree archives root as a tar and returns each of its files'
+// content, keyed by name
+func archiveTestsTree(t *testing.T, root plugin.Entry) map[string]string {
+	plugin.SetTestCache(datastore.NewMemCache())
+	defer plugin.UnsetTestCache()
+	root.(interface{ SetTestID(string) }).SetTestID("/root")
+
+	var buf bytes.Buffer
+	aw := tarArchiveWriter{tar.NewWriter(&buf)}
+	a := newArchiver(aw)
+	assert.NoError(t, a.write(context.Background(), root, "/root", "root", 0))
+	assert.NoError(t, a.writeErrors("root/"+archiveErrorsName))
+	assert.NoError(t, aw.Close())
+
+	files := make(map[string]string)
+	rdr := tar.NewReader(&buf)
+	for {
+		hdr, err := rdr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if !assert.NoError(t, err) {
+			return files
+		}
+		content, err := ioutil.ReadAll(rdr)
+		assert.NoError(t, err)
+		files[hdr.Name] = string(content)
+	}
+}
+
+func TestArchiver(t *testing.T) {
+	file := newArchiveTestsFile("file", "hello")
+	files := archiveTestsTree(t, newGlobTestsDir("root", file))
+	assert.Equal(t, map[string]string{"root/": "", "root/file": "hello"}, files)
+	assert.True(t, file.closed)
+}
+
+func TestArchiver_RecordsReadErrorsAndContinues(t *testing.T) {
+	broken := newArchiveTestsFile("broken", "hello")
+	broken.readErr = fmt.Errorf("the connection was reset")
+	files := archiveTestsTree(t, newGlobTestsDir("root", broken, newArchiveTestsFile("file", "world")))
+	// The broken file's padded to its size
+	assert.Equal(t, "h\x00\x00\x00\x00", files["root/broken"])
+	assert.Equal(t, "world", files["root/file"])
+	assert.Contains(t, files["root/"+archiveErrorsName], "/root/broken: could not read all of it: the connection was reset")
+}
+
+func TestArchiver_EnforcesTheLimits(t *testing.T) {
+	_, err := limits.Set(archiveMaxDepth.Name(), 1)
+	assert.NoError(t, err)
+	_, err = limits.Set(archiveMaxSize.Name(), 1)
+	assert.NoError(t, err)
+	defer func() {
+		_, err := limits.Set(archiveMaxDepth.Name(), 32)
+		assert.NoError(t, err)
+		_, err = limits.Set(archiveMaxSize.Name(), 1024)
+		assert.NoError(t, err)
+	}()
+
+	big := newArchiveTestsFile("big", strings.Repeat("a", 1024*1024+1))
+	deep := newGlobTestsDir("deep", newArchiveTestsFile("file", "hello"))
+	files := archiveTestsTree(t, newGlobTestsDir("root", big, newGlobTestsDir("dir", deep)))
+	assert.NotContains(t, files, "root/big")
+	assert.Contains(t, files, "root/dir/")
+	assert.NotContains(t, files, "root/dir/deep/")
+	errors := files["root/"+archiveErrorsName]
+	assert.Contains(t, errors, "/root/big: its content would exceed the api.archive_max_mb limit")
+	assert.Contains(t, errors, "/root/dir: its children are deeper than the api.archive_max_depth limit")
+	assert.True(t, big.closed)
+}
@@ -14,6 +14,8 @@ import (
 // Remove items from the cache
 //
 // Removes the specified entry and its children from the cache.
+// Requests must set the Authorization header to "Bearer <token>", where
+// <token> is the content of the admin token file.
 //
 //     Produces:
 //     - application/json
@@ -22,6 +24,7 @@ import (
 //
 //     Responses:
 //       200:
+//       401: errorResp
 //       500: errorResp
 var cacheHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
 	path, errResp := getWashPathFromRequest(r)
@@ -48,6 +48,19 @@ func (m *mockCache) GetOrUpdate(cat, key string, ttl time.Duration, resetTTLOnHi
 	return val, nil
 }
 
+func (m *mockCache) Refresh(cat, key string, ttl time.Duration, generateValue func() (interface{}, error)) error {
+	key = cat + "::" + key
+	if _, ok := m.items[key]; !ok {
+		return nil
+	}
+	val, err := generateValue()
+	if err != nil {
+		return err
+	}
+	m.items[key] = val
+	return nil
+}
+
 func (m *mockCache) Flush() {
 	m.items = make(map[string]interface{})
 }
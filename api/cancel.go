@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// inFlightRequests tracks the cancel functions of the in-flight requests by
+// their journal ID so that an interrupted wash command can cancel its
+// requests. The server usually notices when a client disconnects, but not
+// until it next reads from the connection, so the client cancels them
+// explicitly instead.
+type inFlightRequests struct {
+	mux      sync.Mutex
+	nextID   uint64
+	requests map[string]map[uint64]context.CancelFunc
+}
+
+var inFlight = &inFlightRequests{requests: make(map[string]map[uint64]context.CancelFunc)}
+
+type inFlightRequestKey struct{}
+
+// track returns a cancellable copy of ctx that's tracked under journalID. The
+// returned function untracks it, and must be called once the request's done.
+// Requests without a journal aren't tracked since they can't be told apart.
+func (t *inFlightRequests) track(ctx context.Context, journalID string) (context.Context, func()) {
+	if journalID == "" {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.nextID++
+	id := t.nextID
+	if t.requests[journalID] == nil {
+		t.requests[journalID] = make(map[uint64]context.CancelFunc)
+	}
+	t.requests[journalID][id] = cancel
+	ctx = context.WithValue(ctx, inFlightRequestKey{}, id)
+
+	return ctx, func() {
+		cancel()
+		t.mux.Lock()
+		defer t.mux.Unlock()
+		delete(t.requests[journalID], id)
+		if len(t.requests[journalID]) == 0 {
+			delete(t.requests, journalID)
+		}
+	}
+}
+
+// cancel cancels journalID's in-flight requests except for the request in
+// ctx, and returns how many were cancelled
+func (t *inFlightRequests) cancel(ctx context.Context, journalID string) int {
+	self, _ := ctx.Value(inFlightRequestKey{}).(uint64)
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	cancelled := 0
+	for id, cancel := range t.requests[journalID] {
+		if id == self {
+			continue
+		}
+		cancel()
+		cancelled++
+	}
+	return cancelled
+}
+
+// swagger:parameters cancelRequests
+//nolint:deadcode,unused
+type cancelParams struct {
+	// the ID of the journal whose requests should be cancelled. Defaults to
+	// the requester's journal.
+	//
+	// in: query
+	Journal string
+}
+
+// swagger:route POST /cancel cancel cancelRequests
+//
+// Cancel a journal's requests
+//
+// Cancels the in-flight requests and running operations of the specified
+// journal, e.g. of a wash command that was interrupted, so that their plugin
+// invocations are stopped instead of running to completion.
+// Requests must set the Authorization header to "Bearer <token>", where
+// <token> is the content of the admin token file.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: CancelResult
+//       400: errorResp
+//       401: errorResp
+//       500: errorResp
+var cancelHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	ctx := r.Context()
+	journalID := r.URL.Query().Get("journal")
+	if journalID == "" {
+		journalID = activity.GetJournal(ctx).ID
+	}
+	if journalID == "" {
+		return badRequestResponse("Please specify the journal whose requests should be cancelled")
+	}
+
+	result := apitypes.CancelResult{
+		Requests:   inFlight.cancel(ctx, journalID),
+		Operations: []apitypes.Operation{},
+	}
+	for _, o := range plugin.CancelOperationsOf(journalID) {
+		result.Operations = append(result.Operations, toAPIOperation(o))
+	}
+	activity.Record(ctx, "API: Cancelled %v requests and %v operations of journal %v", result.Requests, len(result.Operations), journalID)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the cancellation result: %v", err))
+	}
+	return nil
+}
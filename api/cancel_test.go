@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type InFlightRequestsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *InFlightRequestsTestSuite) TestCancel() {
+	t := &inFlightRequests{requests: make(map[string]map[uint64]context.CancelFunc)}
+	first, untrackFirst := t.track(context.Background(), "1")
+	defer untrackFirst()
+	second, untrackSecond := t.track(context.Background(), "1")
+	defer untrackSecond()
+	other, untrackOther := t.track(context.Background(), "2")
+	defer untrackOther()
+
+	// The cancelling request isn't cancelled
+	suite.Equal(1, t.cancel(second, "1"))
+	suite.Error(first.Err())
+	suite.NoError(second.Err())
+	suite.NoError(other.Err())
+}
+
+func (suite *InFlightRequestsTestSuite) TestUntrack() {
+	t := &inFlightRequests{requests: make(map[string]map[uint64]context.CancelFunc)}
+	ctx, untrack := t.track(context.Background(), "1")
+	untrack()
+	suite.Error(ctx.Err())
+	suite.Empty(t.requests)
+	suite.Equal(0, t.cancel(context.Background(), "1"))
+}
+
+func (suite *InFlightRequestsTestSuite) TestRequestsWithoutAJournalAreNotTracked() {
+	t := &inFlightRequests{requests: make(map[string]map[uint64]context.CancelFunc)}
+	ctx, untrack := t.track(context.Background(), "")
+	defer untrack()
+	suite.Empty(t.requests)
+	suite.Equal(0, t.cancel(context.Background(), ""))
+	suite.NoError(ctx.Err())
+}
+
+func TestInFlightRequests(t *testing.T) {
+	suite.Run(t, new(InFlightRequestsTestSuite))
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCachedResponses is the maximum number of responses that are cached by a
+// caching client. Once it's reached, the responses that expire first are
+// evicted.
+const maxCachedResponses = 1000
+
+type cachedResponse struct {
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+// responseCache caches the responses to GET requests according to their
+// Cache-Control and ETag headers. Responses are served from the cache until
+// their max-age expires. After that, they're revalidated via their ETag.
+type responseCache struct {
+	mux       sync.Mutex
+	responses map[string]cachedResponse
+	now       func() time.Time
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		responses: make(map[string]cachedResponse),
+		now:       time.Now,
+	}
+}
+
+// lookup returns the cached response for the given URL. fresh is true if the
+// response hasn't expired, i.e. if it can be used without revalidation.
+func (c *responseCache) lookup(url string) (resp cachedResponse, fresh bool, ok bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	resp, ok = c.responses[url]
+	return resp, ok && c.now().Before(resp.expires), ok
+}
+
+// store caches body as the response for the given URL according to the
+// response's headers. Responses without a max-age or an ETag aren't cached.
+func (c *responseCache) store(url string, header http.Header, body []byte) {
+	cacheControl := parseCacheControl(header.Get("Cache-Control"))
+	if _, ok := cacheControl["no-store"]; ok {
+		return
+	}
+	resp := cachedResponse{body: body, etag: header.Get("ETag")}
+	if maxAge, err := strconv.Atoi(cacheControl["max-age"]); err == nil && maxAge > 0 {
+		resp.expires = c.now().Add(time.Duration(maxAge) * time.Second)
+	} else if resp.etag == "" {
+		// The response can't be revalidated
+		return
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if _, ok := c.responses[url]; !ok && len(c.responses) >= maxCachedResponses {
+		c.evict()
+	}
+	c.responses[url] = resp
+}
+
+// flush removes all of the cached responses
+func (c *responseCache) flush() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.responses = make(map[string]cachedResponse)
+}
+
+// evict evicts the response that expires first. It must be called with c.mux
+// held.
+func (c *responseCache) evict() {
+	var evictedURL string
+	var evictedExpires time.Time
+	for url, resp := range c.responses {
+		if evictedURL == "" || resp.expires.Before(evictedExpires) {
+			evictedURL, evictedExpires = url, resp.expires
+		}
+	}
+	delete(c.responses, evictedURL)
+}
+
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		segments := strings.SplitN(directive, "=", 2)
+		name := strings.ToLower(segments[0])
+		if len(segments) == 2 {
+			directives[name] = strings.Trim(segments[1], "\"")
+		} else {
+			directives[name] = ""
+		}
+	}
+	return directives
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CacheTestSuite struct {
+	suite.Suite
+	requests    int
+	lastETag    string
+	cacheHeader string
+	server      *httptest.Server
+	client      *domainSocketClient
+	now         time.Time
+	origBaseURL string
+}
+
+func (s *CacheTestSuite) SetupTest() {
+	s.requests = 0
+	s.lastETag = ""
+	s.cacheHeader = "max-age=10"
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests++
+		s.lastETag = r.Header.Get("If-None-Match")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", s.cacheHeader)
+		if s.lastETag == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintln(w, `{"state":"running"}`)
+	}))
+	s.origBaseURL = domainSocketBaseURL
+	domainSocketBaseURL = s.server.URL
+
+	s.now = time.Now()
+	s.client = &domainSocketClient{Client: s.server.Client(), cache: newResponseCache()}
+	s.client.cache.now = func() time.Time {
+		return s.now
+	}
+}
+
+func (s *CacheTestSuite) TearDownTest() {
+	domainSocketBaseURL = s.origBaseURL
+	s.server.Close()
+}
+
+func (s *CacheTestSuite) metadata() {
+	metadata, err := s.client.Metadata("/foo")
+	if s.NoError(err) {
+		s.Equal(map[string]interface{}{"state": "running"}, metadata)
+	}
+}
+
+func (s *CacheTestSuite) TestFreshResponsesAreReused() {
+	s.metadata()
+	s.metadata()
+	s.Equal(1, s.requests)
+}
+
+func (s *CacheTestSuite) TestExpiredResponsesAreRevalidated() {
+	s.metadata()
+	s.now = s.now.Add(11 * time.Second)
+	s.metadata()
+	s.Equal(2, s.requests)
+	s.Equal(`"v1"`, s.lastETag)
+
+	// The 304 should've renewed the response's max-age
+	s.metadata()
+	s.Equal(2, s.requests)
+}
+
+func (s *CacheTestSuite) TestNoCacheResponsesAreAlwaysRevalidated() {
+	s.cacheHeader = "no-cache"
+	s.metadata()
+	s.metadata()
+	s.Equal(2, s.requests)
+	s.Equal(`"v1"`, s.lastETag)
+}
+
+func (s *CacheTestSuite) TestNoStoreResponsesAreNotCached() {
+	s.cacheHeader = "no-store"
+	s.metadata()
+	s.metadata()
+	s.Equal(2, s.requests)
+	s.Equal("", s.lastETag)
+}
+
+func (s *CacheTestSuite) TestEvictsResponsesThatExpireFirst() {
+	cache := newResponseCache()
+	header := http.Header{}
+	header.Set("Cache-Control", "max-age=1")
+	cache.store("first", header, []byte("first"))
+	header.Set("Cache-Control", "max-age=10")
+	for i := 1; i < maxCachedResponses; i++ {
+		cache.store(fmt.Sprintf("url%v", i), header, []byte("foo"))
+	}
+	cache.store("last", header, []byte("last"))
+
+	_, _, ok := cache.lookup("first")
+	s.False(ok)
+	resp, fresh, ok := cache.lookup("last")
+	if s.True(ok) {
+		s.True(fresh)
+		s.Equal("last", string(resp.body))
+	}
+}
+
+func TestCache(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
@@ -19,6 +19,8 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Benchkram/errz"
 	"github.com/puppetlabs/wash/activity"
@@ -29,36 +31,97 @@ import (
 // Client represents a Wash API client.
 type Client interface {
 	Info(path string) (apitypes.Entry, error)
+	Capabilities(path string) (apitypes.EntryCapabilities, error)
+	Resolve(path string) (apitypes.Entry, error)
 	List(path string) ([]apitypes.Entry, error)
+	ListFlat(path string) ([]apitypes.Entry, error)
+	ListWithMetadata(path string, strict bool) ([]apitypes.Entry, error)
+	ListWithTelemetry(path string, flat bool) ([]apitypes.Entry, error)
+	ListStream(path string) (<-chan apitypes.ListPacket, error)
+	ListPage(path string, page string) (apitypes.ListPage, error)
+	Glob(pattern string) ([]apitypes.Entry, error)
+	Delete(path string) error
+	Signal(path string, signal string) error
+	Run(path string, action string, args []string, opts apitypes.RunOptions) (string, error)
+	RunAsync(path string, action string, args []string, opts apitypes.RunOptions) (apitypes.Operation, error)
 	Metadata(path string) (map[string]interface{}, error)
+	MetadataHistory(path string) ([]apitypes.MetadataSnapshot, error)
+	Telemetry(path string) (map[string]interface{}, error)
 	Stream(path string) (io.ReadCloser, error)
+	ResumeStream(path string, pos apitypes.StreamPosition) (io.ReadCloser, error)
+	Archive(path string, format string) (io.ReadCloser, error)
 	Exec(path string, command string, args []string, opts apitypes.ExecOptions) (<-chan apitypes.ExecPacket, error)
 	History(bool) (chan apitypes.Activity, error)
 	ActivityJournal(index int, follow bool) (io.ReadCloser, error)
+	ExecTranscripts(index int) ([]activity.ExecTranscript, error)
 	Clear(path string) ([]string, error)
+	Pin(path string, ttl time.Duration) (apitypes.Pin, error)
+	Unpin(path string) (apitypes.Pin, error)
 	// A "nil" schema means that the schema's unknown.
 	Schema(path string) (*apitypes.EntrySchema, error)
+	Whereami(path string) (apitypes.ResourceContext, error)
+	PluginHelp(name string) (string, error)
+	TranslatePath(path string) (apitypes.PathTranslation, error)
+	TranslateWashPath(washPath string) (apitypes.PathTranslation, error)
 	Screenview(name string, params analytics.Params) error
+	Limits() ([]apitypes.Limit, error)
+	SetLimit(name string, value int, persist bool) (apitypes.Limit, error)
+	Metrics() (apitypes.Metrics, error)
+	Features() ([]apitypes.Feature, error)
+	SetFeature(name string, plugin string, enabled bool, persist bool) (apitypes.Feature, error)
+	Read(path string) (io.ReadCloser, error)
+	ReadAsync(path string) (apitypes.Operation, error)
+	Operations() ([]apitypes.Operation, error)
+	Operation(id string) (apitypes.Operation, error)
+	OperationResult(id string) (io.ReadCloser, error)
+	CancelOperation(id string) (apitypes.Operation, error)
+	Snapshot() (apitypes.Snapshot, error)
+	Prune(override *apitypes.PruneBody) ([]apitypes.PruneResult, error)
+	Cancel() (apitypes.CancelResult, error)
+	Profile(kind string, duration time.Duration) (io.ReadCloser, error)
 }
 
 // A domainSocketClient is a wash API client.
 type domainSocketClient struct {
 	*http.Client
+	// cache is optional. If set, then it's used to cache the responses to
+	// GET requests.
+	cache *responseCache
+	// socketPath is the path of the server's socket. It's used to find the
+	// server's admin token.
+	socketPath string
 }
 
 var domainSocketBaseURL = "http://localhost"
 
+// WarningWriter is where the client writes any warnings returned by the API,
+// e.g. that an invoked action is deprecated. It defaults to os.Stderr.
+var WarningWriter io.Writer = os.Stderr
+
 // ForUNIXSocket returns a client suitable for making wash API calls over a UNIX
 // domain socket.
 func ForUNIXSocket(pathToSocket string) Client {
 	return &domainSocketClient{
-		&http.Client{
+		Client: &http.Client{
 			Transport: &http.Transport{
 				DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
 					return net.Dial("unix", pathToSocket)
 				},
 			},
-		}}
+		},
+		socketPath: pathToSocket,
+	}
+}
+
+// ForUNIXSocketWithCache returns a client like ForUNIXSocket, except that it
+// caches the responses to GET requests (e.g. for metadata and listings) in
+// memory. Cached responses are reused until the max-age in their Cache-Control
+// header expires, after which they're revalidated via their ETag. This reduces
+// the load on the Wash server for tools that make many small requests.
+func ForUNIXSocketWithCache(pathToSocket string) Client {
+	c := ForUNIXSocket(pathToSocket).(*domainSocketClient)
+	c.cache = newResponseCache()
+	return c
 }
 
 func unmarshalErrorResp(resp *http.Response) error {
@@ -74,7 +137,7 @@ func unmarshalErrorResp(resp *http.Response) error {
 	return &errorObj
 }
 
-func (c *domainSocketClient) doRequest(method, endpoint string, params url.Values, body io.Reader) (io.ReadCloser, error) {
+func (c *domainSocketClient) newRequest(method, endpoint string, params url.Values, body io.Reader) (*http.Request, error) {
 	// Do common parameter munging.
 	if paths, ok := params["path"]; ok {
 		if len(paths) != 1 {
@@ -98,30 +161,134 @@ func (c *domainSocketClient) doRequest(method, endpoint string, params url.Value
 	journal := activity.JournalForPID(os.Getpid())
 	req.Header.Set(apitypes.JournalIDHeader, journal.ID)
 	req.Header.Set(apitypes.JournalDescHeader, journal.Description)
+	return req, nil
+}
+
+func (c *domainSocketClient) doRequest(method, endpoint string, params url.Values, body io.Reader) (io.ReadCloser, error) {
+	req, err := c.newRequest(method, endpoint, params, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// doAdminRequest is like doRequest, except that the request includes the
+// server's admin token. The endpoints that change the server's behavior
+// require it, so they only work for the user that started the server.
+func (c *domainSocketClient) doAdminRequest(method, endpoint string, params url.Values, body io.Reader) (io.ReadCloser, error) {
+	req, err := c.newAdminRequest(method, endpoint, params, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *domainSocketClient) newAdminRequest(method, endpoint string, params url.Values, body io.Reader) (*http.Request, error) {
+	token, err := ioutil.ReadFile(apitypes.AdminTokenPath(c.socketPath))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the server's admin token: %v", err)
+	}
+	req, err := c.newRequest(method, endpoint, params, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(apitypes.AdminTokenHeader, "Bearer "+strings.TrimSpace(string(token)))
+	return req, nil
+}
+
+func (c *domainSocketClient) sendRequest(req *http.Request) (*http.Response, error) {
 	resp, err := c.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode == http.StatusOK {
-		return resp.Body, nil
+	// Some endpoints respond with other 2xx codes, e.g. async reads respond
+	// with 202 Accepted
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		printWarnings(resp)
+		return resp, nil
 	}
 
 	return nil, unmarshalErrorResp(resp)
 }
 
-func (c *domainSocketClient) getRequest(endpoint string, params url.Values, result interface{}) error {
-	respBody, err := c.doRequest(http.MethodGet, endpoint, params, nil)
+// doCachedGetRequest is like sendRequest for GET requests, except that it uses
+// c.cache to cache the response's body
+func (c *domainSocketClient) doCachedGetRequest(req *http.Request) ([]byte, error) {
+	key := req.URL.String()
+	cached, fresh, ok := c.cache.lookup(key)
+	if fresh {
+		return cached.body, nil
+	}
+	if ok && cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := c.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	defer func() { errz.Log(respBody.Close()) }()
-	body, err := ioutil.ReadAll(respBody)
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		errz.Log(resp.Body.Close())
+		c.cache.store(key, resp.Header, cached.body)
+		return cached.body, nil
+	case http.StatusOK:
+		printWarnings(resp)
+		defer func() { errz.Log(resp.Body.Close()) }()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		c.cache.store(key, resp.Header, body)
+		return body, nil
+	default:
+		return nil, unmarshalErrorResp(resp)
+	}
+}
+
+func printWarnings(resp *http.Response) {
+	for _, warning := range resp.Header[apitypes.WarningHeader] {
+		_, err := fmt.Fprintf(WarningWriter, "Warning: %v\n", apitypes.ParseWarning(warning))
+		errz.Log(err)
+	}
+}
+
+func (c *domainSocketClient) getRequest(endpoint string, params url.Values, result interface{}) error {
+	req, err := c.newRequest(http.MethodGet, endpoint, params, nil)
 	if err != nil {
 		return err
 	}
 
+	var body []byte
+	if c.cache != nil {
+		if body, err = c.doCachedGetRequest(req); err != nil {
+			return err
+		}
+	} else {
+		resp, err := c.sendRequest(req)
+		if err != nil {
+			return err
+		}
+
+		defer func() { errz.Log(resp.Body.Close()) }()
+		body, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+	}
+
 	if err := json.Unmarshal(body, result); err != nil {
 		return fmt.Errorf("Non-JSON body at %v: %v", endpoint, string(body))
 	}
@@ -139,6 +306,77 @@ func (c *domainSocketClient) Info(path string) (apitypes.Entry, error) {
 	return e, nil
 }
 
+// Capabilities retrieves the capabilities of the resource located at "path",
+// i.e. its supported actions, the attributes it sets, how its ops are cached
+// and the plugin it belongs to
+func (c *domainSocketClient) Capabilities(path string) (apitypes.EntryCapabilities, error) {
+	var capabilities apitypes.EntryCapabilities
+	if err := c.getRequest("/fs/info/capabilities", url.Values{"path": []string{path}}, &capabilities); err != nil {
+		return capabilities, err
+	}
+
+	return capabilities, nil
+}
+
+// Resolve normalizes "path", expands any symlinks that lead into Wash, and
+// retrieves the information of the resulting resource. The returned entry's
+// path is the canonical path.
+func (c *domainSocketClient) Resolve(path string) (apitypes.Entry, error) {
+	var e apitypes.Entry
+	if err := c.getRequest("/fs/resolve", url.Values{"path": []string{path}}, &e); err != nil {
+		return e, err
+	}
+
+	return e, nil
+}
+
+// Whereami retrieves the resource context of "path", i.e. the resources
+// that it's nested under.
+func (c *domainSocketClient) Whereami(path string) (apitypes.ResourceContext, error) {
+	var rc apitypes.ResourceContext
+	if err := c.getRequest("/fs/whereami", url.Values{"path": []string{path}}, &rc); err != nil {
+		return rc, err
+	}
+
+	return rc, nil
+}
+
+// PluginHelp returns the named plugin's help document
+func (c *domainSocketClient) PluginHelp(name string) (string, error) {
+	respBody, err := c.doRequest(http.MethodGet, "/plugins/"+url.PathEscape(name)+"/help", url.Values{}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() { errz.Log(respBody.Close()) }()
+	help, err := ioutil.ReadAll(respBody)
+	if err != nil {
+		return "", err
+	}
+	return string(help), nil
+}
+
+// TranslatePath translates "path", an OS path under Wash's mountpoint, into
+// its Wash path.
+func (c *domainSocketClient) TranslatePath(path string) (apitypes.PathTranslation, error) {
+	var t apitypes.PathTranslation
+	if err := c.getRequest("/fs/translate", url.Values{"path": []string{path}}, &t); err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+// TranslateWashPath translates "washPath" into its OS path under Wash's
+// mountpoint.
+func (c *domainSocketClient) TranslateWashPath(washPath string) (apitypes.PathTranslation, error) {
+	var t apitypes.PathTranslation
+	if err := c.getRequest("/fs/translate", url.Values{"wash_path": []string{washPath}}, &t); err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
 // List lists the resources located at "path".
 func (c *domainSocketClient) List(path string) ([]apitypes.Entry, error) {
 	var ls []apitypes.Entry
@@ -149,6 +387,188 @@ func (c *domainSocketClient) List(path string) ([]apitypes.Entry, error) {
 	return ls, nil
 }
 
+// ListFlat lists the resources located at "path" without partitioning them,
+// even if there's more of them than the plugins.partition_threshold limit.
+func (c *domainSocketClient) ListFlat(path string) ([]apitypes.Entry, error) {
+	var ls []apitypes.Entry
+	if err := c.getRequest("/fs/list", url.Values{"path": []string{path}, "flat": []string{"true"}}, &ls); err != nil {
+		return nil, err
+	}
+
+	return ls, nil
+}
+
+// ListWithMetadata lists the resources located at "path" along with their
+// metadata. Resources whose metadata couldn't be fetched in time are marked as
+// stale, and those that errored include the error, unless strict is true, in
+// which case the listing errors instead.
+func (c *domainSocketClient) ListWithMetadata(path string, strict bool) ([]apitypes.Entry, error) {
+	params := url.Values{"path": []string{path}, "metadata": []string{"true"}}
+	if strict {
+		params.Set("strict", "true")
+	}
+	var ls []apitypes.Entry
+	if err := c.getRequest("/fs/list", params, &ls); err != nil {
+		return nil, err
+	}
+
+	return ls, nil
+}
+
+// ListWithTelemetry lists the resources located at "path" along with the
+// telemetry of those that support the telemetry action. Telemetry's
+// best-effort, so resources whose telemetry couldn't be fetched don't have
+// any. If flat is true, then partitioned resources are listed like ListFlat.
+func (c *domainSocketClient) ListWithTelemetry(path string, flat bool) ([]apitypes.Entry, error) {
+	params := url.Values{"path": []string{path}, "telemetry": []string{"true"}}
+	if flat {
+		params.Set("flat", "true")
+	}
+	var ls []apitypes.Entry
+	if err := c.getRequest("/fs/list", params, &ls); err != nil {
+		return nil, err
+	}
+
+	return ls, nil
+}
+
+// ListStream streams the resources located at "path" as they're listed, which
+// is useful for paths with lots of them. The channel's closed once the listing
+// is done. If the listing fails after some of the resources were streamed,
+// then the last packet is the error.
+func (c *domainSocketClient) ListStream(path string) (<-chan apitypes.ListPacket, error) {
+	params := url.Values{"path": []string{path}, "stream": []string{"true"}}
+	respBody, err := c.doRequest(http.MethodGet, "/fs/list", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	packets := make(chan apitypes.ListPacket, 1)
+	go func() {
+		defer close(packets)
+		defer func() { errz.Log(respBody.Close()) }()
+		decoder := json.NewDecoder(respBody)
+		for {
+			var pkt apitypes.ListPacket
+			if err := decoder.Decode(&pkt); err == io.EOF {
+				return
+			} else if err != nil {
+				packets <- apitypes.ListPacket{Err: &apitypes.ErrorObj{
+					Kind: apitypes.UnknownError,
+					Msg:  fmt.Sprintf("could not decode the listing of %v: %v", path, err),
+				}}
+				return
+			}
+			packets <- pkt
+		}
+	}()
+	return packets, nil
+}
+
+// ListPage lists the given page of the resources located at "path", which is
+// useful for paths with lots of them. The first page is the empty page. The
+// returned page's NextPage is empty if it's the last page.
+func (c *domainSocketClient) ListPage(path string, page string) (apitypes.ListPage, error) {
+	params := url.Values{"path": []string{path}, "paged": []string{"true"}}
+	if page != "" {
+		params.Set("page", page)
+	}
+	var lp apitypes.ListPage
+	if err := c.getRequest("/fs/list", params, &lp); err != nil {
+		return apitypes.ListPage{}, err
+	}
+
+	return lp, nil
+}
+
+// Delete deletes the resource located at "path".
+func (c *domainSocketClient) Delete(path string) error {
+	respBody, err := c.doRequest(http.MethodDelete, "/fs/delete", url.Values{"path": []string{path}}, nil)
+	if err != nil {
+		return err
+	}
+	errz.Log(respBody.Close())
+	if c.cache != nil {
+		// The cached listings may still include the deleted resource
+		c.cache.flush()
+	}
+	return nil
+}
+
+// Signal sends the signal to the resource located at "path".
+func (c *domainSocketClient) Signal(path string, signal string) error {
+	jsonBody, err := json.Marshal(apitypes.SignalBody{Signal: signal})
+	if err != nil {
+		return err
+	}
+	respBody, err := c.doRequest(http.MethodPost, "/fs/signal", url.Values{"path": []string{path}}, bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+	errz.Log(respBody.Close())
+	if c.cache != nil {
+		// The cached listings may include the resource's old state
+		c.cache.flush()
+	}
+	return nil
+}
+
+// Run performs the custom action on the resource located at "path", and
+// returns its output.
+func (c *domainSocketClient) Run(path string, action string, args []string, opts apitypes.RunOptions) (string, error) {
+	jsonBody, err := json.Marshal(apitypes.RunBody{Action: action, Args: args, Opts: opts})
+	if err != nil {
+		return "", err
+	}
+	endpoint := "/fs/run"
+	respBody, err := c.doRequest(http.MethodPost, endpoint, url.Values{"path": []string{path}}, bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", err
+	}
+	defer func() { errz.Log(respBody.Close()) }()
+	if c.cache != nil {
+		// The cached listings may include the resource's old state
+		c.cache.flush()
+	}
+	body, err := ioutil.ReadAll(respBody)
+	if err != nil {
+		return "", err
+	}
+	var result apitypes.RunResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("Non-JSON body at %v: %v", endpoint, string(body))
+	}
+	return result.Output, nil
+}
+
+// RunAsync starts performing the custom action on the resource located at
+// "path" in the background. The returned operation's result is the action's
+// apitypes.RunResult.
+func (c *domainSocketClient) RunAsync(path string, action string, args []string, opts apitypes.RunOptions) (apitypes.Operation, error) {
+	jsonBody, err := json.Marshal(apitypes.RunBody{Action: action, Args: args, Opts: opts})
+	if err != nil {
+		return apitypes.Operation{}, err
+	}
+	params := url.Values{"path": []string{path}, "async": []string{"true"}}
+	op, err := c.operationRequest(c.doRequest, http.MethodPost, "/fs/run", params, bytes.NewReader(jsonBody))
+	if err == nil && c.cache != nil {
+		// The cached listings may include the resource's old state
+		c.cache.flush()
+	}
+	return op, err
+}
+
+// Glob returns the resources whose path matches "pattern", sorted by path.
+// See apitypes.IsGlob for the supported wildcards.
+func (c *domainSocketClient) Glob(pattern string) ([]apitypes.Entry, error) {
+	var matches []apitypes.Entry
+	if err := c.getRequest("/fs/glob", url.Values{"path": []string{pattern}}, &matches); err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
 // Metadata gets the metadata of the resource located at "path".
 func (c *domainSocketClient) Metadata(path string) (map[string]interface{}, error) {
 	var metadata map[string]interface{}
@@ -159,14 +579,118 @@ func (c *domainSocketClient) Metadata(path string) (map[string]interface{}, erro
 	return metadata, nil
 }
 
-// Stream updates for the resource located at "path".
+// MetadataHistory gets the retained snapshots of the metadata of the resource located at "path".
+func (c *domainSocketClient) MetadataHistory(path string) ([]apitypes.MetadataSnapshot, error) {
+	var snapshots []apitypes.MetadataSnapshot
+	if err := c.getRequest("/fs/metadata/history", url.Values{"path": []string{path}}, &snapshots); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// Telemetry gets the live telemetry of the resource located at "path".
+func (c *domainSocketClient) Telemetry(path string) (map[string]interface{}, error) {
+	var telemetry map[string]interface{}
+	if err := c.getRequest("/fs/telemetry", url.Values{"path": []string{path}}, &telemetry); err != nil {
+		return nil, err
+	}
+
+	return telemetry, nil
+}
+
+// Stream updates for the resource located at "path". Once the stream ends,
+// the returned reader's Read returns io.EOF, and StreamEndOf describes why it
+// ended.
 func (c *domainSocketClient) Stream(path string) (io.ReadCloser, error) {
-	respBody, err := c.doRequest(http.MethodGet, "/fs/stream", url.Values{"path": []string{path}}, nil)
+	return c.stream(url.Values{"path": []string{path}})
+}
+
+// ResumeStream is like Stream, except that the stream starts at pos in the
+// stream's history. It errors if the resource's stream can't be resumed.
+func (c *domainSocketClient) ResumeStream(path string, pos apitypes.StreamPosition) (io.ReadCloser, error) {
+	params := url.Values{"path": []string{path}}
+	if pos.HasOffset {
+		params.Set("offset", strconv.FormatInt(pos.Offset, 10))
+	} else {
+		// The fractional seconds keep output from the same second from being
+		// replayed
+		params.Set("since", pos.Since.Format(time.RFC3339Nano))
+	}
+	return c.stream(params)
+}
+
+func (c *domainSocketClient) stream(params url.Values) (io.ReadCloser, error) {
+	req, err := c.newRequest(http.MethodGet, "/fs/stream", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.sendRequest(req)
 	if err != nil {
 		return nil, err
 	}
+	return &streamReader{resp: resp}, nil
+}
+
+// streamReader reads a stream's response body. Its trailer is only available
+// once the body's been read, so streamReader decodes the StreamEnd in the
+// trailer once the body returns io.EOF.
+type streamReader struct {
+	resp *http.Response
+	end  *apitypes.StreamEnd
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	n, err := r.resp.Body.Read(p)
+	if err != io.EOF || r.end != nil {
+		return n, err
+	}
+	trailer := r.resp.Trailer.Get(apitypes.StreamEndTrailer)
+	if trailer == "" {
+		// The server didn't report why the stream ended (e.g. it's an older
+		// server)
+		return n, err
+	}
+	var end apitypes.StreamEnd
+	if jsonErr := json.Unmarshal([]byte(trailer), &end); jsonErr != nil {
+		return n, fmt.Errorf("could not decode the %v trailer %q: %v", apitypes.StreamEndTrailer, trailer, jsonErr)
+	}
+	r.end = &end
+	return n, err
+}
+
+func (r *streamReader) Close() error {
+	return r.resp.Body.Close()
+}
+
+// StreamEndOf returns why the stream ended once its Read returned io.EOF. The
+// returned bool is false if the stream hasn't ended, or if the server didn't
+// report why it ended.
+func StreamEndOf(stream io.Reader) (*apitypes.StreamEnd, bool) {
+	r, ok := stream.(*streamReader)
+	if !ok || r.end == nil {
+		return nil, false
+	}
+	return r.end, true
+}
+
+// StreamOffsetOf returns the offset (in the stream's history) of the stream's
+// output, i.e. of the first byte that it read. The returned bool is false if
+// the offset's unknown, e.g. because the stream isn't resumable.
+func StreamOffsetOf(stream io.Reader) (int64, bool) {
+	r, ok := stream.(*streamReader)
+	if !ok {
+		return 0, false
+	}
+	offset, err := strconv.ParseInt(r.resp.Header.Get(apitypes.StreamOffsetHeader), 10, 64)
+	return offset, err == nil
+}
 
-	return respBody, nil
+// Archive returns a tar or zip archive of the subtree rooted at the resource located at "path".
+func (c *domainSocketClient) Archive(path string, format string) (io.ReadCloser, error) {
+	params := url.Values{"path": []string{path}, "format": []string{format}}
+	return c.doRequest(http.MethodGet, "/fs/archive", params, nil)
 }
 
 // Exec invokes the given command + args on the resource located at "path".
@@ -174,13 +698,22 @@ func (c *domainSocketClient) Stream(path string) (io.ReadCloser, error) {
 // The resulting channel contains events, ordered as we receive them from the
 // server. The channel will be closed when there are no more events.
 func (c *domainSocketClient) Exec(path string, command string, args []string, opts apitypes.ExecOptions) (<-chan apitypes.ExecPacket, error) {
+	opts.StreamStdin = opts.Stdin != nil
 	payload := apitypes.ExecBody{Cmd: command, Args: args, Opts: opts}
 	jsonBody, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
 
-	respBody, err := c.doRequest(http.MethodPost, "/fs/exec", url.Values{"path": []string{path}}, bytes.NewReader(jsonBody))
+	// Stdin is streamed after the JSON payload. The request body's sent while
+	// the response is received, so stdin's only read as fast as the server
+	// (and ultimately the command) consumes it.
+	var body io.Reader = bytes.NewReader(jsonBody)
+	if opts.Stdin != nil {
+		body = io.MultiReader(body, opts.Stdin)
+	}
+
+	respBody, err := c.doRequest(http.MethodPost, "/fs/exec", url.Values{"path": []string{path}}, body)
 	if err != nil {
 		return nil, err
 	}
@@ -249,12 +782,27 @@ func (c *domainSocketClient) ActivityJournal(index int, follow bool) (io.ReadClo
 	return c.doRequest(http.MethodGet, "/history/"+strconv.Itoa(index), params, nil)
 }
 
+// ExecTranscripts returns the transcripts of the commands that were executed by a particular
+// command in history.
+func (c *domainSocketClient) ExecTranscripts(index int) ([]activity.ExecTranscript, error) {
+	var transcripts []activity.ExecTranscript
+	if err := c.getRequest("/history/"+strconv.Itoa(index)+"/exec", url.Values{}, &transcripts); err != nil {
+		return nil, err
+	}
+	return transcripts, nil
+}
+
 // Clear the cache at "path".
 func (c *domainSocketClient) Clear(path string) ([]string, error) {
-	respBody, err := c.doRequest(http.MethodDelete, "/cache", url.Values{"path": []string{path}}, nil)
+	respBody, err := c.doAdminRequest(http.MethodDelete, "/cache", url.Values{"path": []string{path}}, nil)
 	if err != nil {
 		return nil, err
 	}
+	if c.cache != nil {
+		// The cached responses may be stale now that the server's cache was
+		// cleared
+		c.cache.flush()
+	}
 
 	defer func() { errz.Log(respBody.Close()) }()
 	body, err := ioutil.ReadAll(respBody)
@@ -270,6 +818,46 @@ func (c *domainSocketClient) Clear(path string) ([]string, error) {
 	return result, nil
 }
 
+// Pin pins the entry at "path" and its children so that their cached
+// results are refreshed every ttl instead of expiring. A ttl of 0 uses the
+// server's default.
+func (c *domainSocketClient) Pin(path string, ttl time.Duration) (apitypes.Pin, error) {
+	params := url.Values{"path": []string{path}}
+	if ttl != 0 {
+		params.Set("ttl", ttl.String())
+	}
+	return c.doPinRequest(http.MethodPut, params)
+}
+
+// Unpin unpins the entry at "path" and its children
+func (c *domainSocketClient) Unpin(path string) (apitypes.Pin, error) {
+	pin, err := c.doPinRequest(http.MethodDelete, url.Values{"path": []string{path}})
+	if err == nil && c.cache != nil {
+		// Unpinning clears the server's cache, so the cached responses may
+		// be stale
+		c.cache.flush()
+	}
+	return pin, err
+}
+
+func (c *domainSocketClient) doPinRequest(method string, params url.Values) (apitypes.Pin, error) {
+	var pin apitypes.Pin
+	endpoint := "/cache/pins"
+	respBody, err := c.doAdminRequest(method, endpoint, params, nil)
+	if err != nil {
+		return pin, err
+	}
+	defer func() { errz.Log(respBody.Close()) }()
+	body, err := ioutil.ReadAll(respBody)
+	if err != nil {
+		return pin, err
+	}
+	if err := json.Unmarshal(body, &pin); err != nil {
+		return pin, fmt.Errorf("Non-JSON body at %v: %v", endpoint, string(body))
+	}
+	return pin, nil
+}
+
 // Schema returns the entry's schema
 func (c *domainSocketClient) Schema(path string) (*apitypes.EntrySchema, error) {
 	var schema *apitypes.EntrySchema
@@ -292,3 +880,239 @@ func (c *domainSocketClient) Screenview(name string, params analytics.Params) er
 	_, err = c.doRequest(http.MethodPost, "/analytics/screenview", url.Values{}, bytes.NewReader(jsonBody))
 	return err
 }
+
+// Limits returns the server's concurrency and rate limits
+func (c *domainSocketClient) Limits() ([]apitypes.Limit, error) {
+	var ls []apitypes.Limit
+	if err := c.getRequest("/limits", url.Values{}, &ls); err != nil {
+		return nil, err
+	}
+	return ls, nil
+}
+
+// SetLimit sets the named limit's value. If persist is true, then the server
+// also persists the new value to its config.
+func (c *domainSocketClient) SetLimit(name string, value int, persist bool) (apitypes.Limit, error) {
+	var l apitypes.Limit
+	jsonBody, err := json.Marshal(apitypes.LimitBody{Value: value, Persist: persist})
+	if err != nil {
+		return l, err
+	}
+
+	endpoint := "/limits/" + name
+	respBody, err := c.doAdminRequest(http.MethodPut, endpoint, url.Values{}, bytes.NewReader(jsonBody))
+	if err != nil {
+		return l, err
+	}
+	defer func() { errz.Log(respBody.Close()) }()
+	body, err := ioutil.ReadAll(respBody)
+	if err != nil {
+		return l, err
+	}
+	if err := json.Unmarshal(body, &l); err != nil {
+		return l, fmt.Errorf("Non-JSON body at %v: %v", endpoint, string(body))
+	}
+	return l, nil
+}
+
+// Metrics returns a snapshot of the server's recent activity
+func (c *domainSocketClient) Metrics() (apitypes.Metrics, error) {
+	var m apitypes.Metrics
+	err := c.getRequest("/metrics", url.Values{}, &m)
+	return m, err
+}
+
+// Features returns the server's feature flags
+func (c *domainSocketClient) Features() ([]apitypes.Feature, error) {
+	var fs []apitypes.Feature
+	if err := c.getRequest("/features", url.Values{}, &fs); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// SetFeature enables or disables the named feature flag. If plugin is set,
+// then only the plugin's override is set. If persist is true, then the server
+// also persists the new value to its config.
+func (c *domainSocketClient) SetFeature(name string, plugin string, enabled bool, persist bool) (apitypes.Feature, error) {
+	var f apitypes.Feature
+	jsonBody, err := json.Marshal(apitypes.FeatureBody{Enabled: enabled, Plugin: plugin, Persist: persist})
+	if err != nil {
+		return f, err
+	}
+
+	endpoint := "/features/" + name
+	respBody, err := c.doAdminRequest(http.MethodPut, endpoint, url.Values{}, bytes.NewReader(jsonBody))
+	if err != nil {
+		return f, err
+	}
+	defer func() { errz.Log(respBody.Close()) }()
+	body, err := ioutil.ReadAll(respBody)
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(body, &f); err != nil {
+		return f, fmt.Errorf("Non-JSON body at %v: %v", endpoint, string(body))
+	}
+	return f, nil
+}
+
+// Snapshot captures and saves a snapshot of the server's cache
+func (c *domainSocketClient) Snapshot() (apitypes.Snapshot, error) {
+	var s apitypes.Snapshot
+	endpoint := "/snapshots"
+	respBody, err := c.doAdminRequest(http.MethodPost, endpoint, url.Values{}, nil)
+	if err != nil {
+		return s, err
+	}
+	defer func() { errz.Log(respBody.Close()) }()
+	body, err := ioutil.ReadAll(respBody)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(body, &s); err != nil {
+		return s, fmt.Errorf("Non-JSON body at %v: %v", endpoint, string(body))
+	}
+	return s, nil
+}
+
+// Read reads the content of the resource located at "path"
+func (c *domainSocketClient) Read(path string) (io.ReadCloser, error) {
+	return c.doRequest(http.MethodGet, "/fs/read", url.Values{"path": []string{path}}, nil)
+}
+
+// ReadAsync starts reading the resource located at "path" in the background.
+// Use the returned operation's ID to check its progress and to get the
+// content once it's done.
+func (c *domainSocketClient) ReadAsync(path string) (apitypes.Operation, error) {
+	params := url.Values{"path": []string{path}, "async": []string{"true"}}
+	return c.operationRequest(c.doRequest, http.MethodGet, "/fs/read", params, nil)
+}
+
+// Operations returns the server's running and recently finished operations
+func (c *domainSocketClient) Operations() ([]apitypes.Operation, error) {
+	var ops []apitypes.Operation
+	if err := c.getRequest("/operations", url.Values{}, &ops); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// Operation returns the operation with the given ID
+func (c *domainSocketClient) Operation(id string) (apitypes.Operation, error) {
+	var op apitypes.Operation
+	if err := c.getRequest("/operations/"+id, url.Values{}, &op); err != nil {
+		return op, err
+	}
+	return op, nil
+}
+
+// OperationResult returns the result of a successful operation, e.g. the
+// content of an asynchronous read
+func (c *domainSocketClient) OperationResult(id string) (io.ReadCloser, error) {
+	return c.doRequest(http.MethodGet, "/operations/"+id+"/result", url.Values{}, nil)
+}
+
+// CancelOperation cancels the operation with the given ID
+func (c *domainSocketClient) CancelOperation(id string) (apitypes.Operation, error) {
+	return c.operationRequest(c.doAdminRequest, http.MethodDelete, "/operations/"+id, url.Values{}, nil)
+}
+
+// operationRequest is a helper for the requests that respond with an
+// operation. Their responses aren't cacheable. do is c.doRequest, or
+// c.doAdminRequest if the endpoint requires the admin token.
+func (c *domainSocketClient) operationRequest(
+	do func(method, endpoint string, params url.Values, body io.Reader) (io.ReadCloser, error),
+	method string,
+	endpoint string,
+	params url.Values,
+	reqBody io.Reader,
+) (apitypes.Operation, error) {
+	var op apitypes.Operation
+	respBody, err := do(method, endpoint, params, reqBody)
+	if err != nil {
+		return op, err
+	}
+	defer func() { errz.Log(respBody.Close()) }()
+	body, err := ioutil.ReadAll(respBody)
+	if err != nil {
+		return op, err
+	}
+	if err := json.Unmarshal(body, &op); err != nil {
+		return op, fmt.Errorf("Non-JSON body at %v: %v", endpoint, string(body))
+	}
+	return op, nil
+}
+
+// Prune prunes the server's on-disk stores according to their retention
+// policies. override is optional. If set, its non-zero fields override the
+// corresponding fields of each store's policy.
+func (c *domainSocketClient) Prune(override *apitypes.PruneBody) ([]apitypes.PruneResult, error) {
+	var reqBody io.Reader
+	if override != nil {
+		jsonBody, err := json.Marshal(override)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	endpoint := "/prune"
+	respBody, err := c.doAdminRequest(http.MethodPost, endpoint, url.Values{}, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { errz.Log(respBody.Close()) }()
+	body, err := ioutil.ReadAll(respBody)
+	if err != nil {
+		return nil, err
+	}
+	var results []apitypes.PruneResult
+	if err := json.Unmarshal(body, &results); err != nil {
+		return nil, fmt.Errorf("Non-JSON body at %v: %v", endpoint, string(body))
+	}
+	return results, nil
+}
+
+// Cancel cancels the in-flight requests and running operations of this
+// process's journal, e.g. when the command that's making them is interrupted.
+func (c *domainSocketClient) Cancel() (apitypes.CancelResult, error) {
+	var result apitypes.CancelResult
+	endpoint := "/cancel"
+	respBody, err := c.doAdminRequest(http.MethodPost, endpoint, url.Values{}, nil)
+	if err != nil {
+		return result, err
+	}
+	defer func() { errz.Log(respBody.Close()) }()
+	body, err := ioutil.ReadAll(respBody)
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return result, fmt.Errorf("Non-JSON body at %v: %v", endpoint, string(body))
+	}
+	return result, nil
+}
+
+// Profile fetches a profile of the Wash server. kind is apitypes.CPUProfile or
+// apitypes.HeapProfile. A CPU profile covers the given duration. A heap profile
+// is a snapshot of the heap if duration is zero, otherwise it only includes
+// the allocations made during the duration. Profile requires the server's
+// admin token, so it only works for the user that started the server.
+func (c *domainSocketClient) Profile(kind string, duration time.Duration) (io.ReadCloser, error) {
+	var endpoint string
+	switch kind {
+	case apitypes.CPUProfile:
+		endpoint = "/admin/debug/pprof/profile"
+	case apitypes.HeapProfile:
+		endpoint = "/admin/debug/pprof/heap"
+	default:
+		return nil, fmt.Errorf("unknown profile %v; it must be %v or %v", kind, apitypes.CPUProfile, apitypes.HeapProfile)
+	}
+
+	params := url.Values{}
+	if duration > 0 {
+		params.Set("seconds", strconv.Itoa(int(duration.Seconds())))
+	}
+	return c.doAdminRequest(http.MethodGet, endpoint, params, nil)
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecStreamsStdinAfterThePayload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body apitypes.ExecBody
+		decoder := json.NewDecoder(r.Body)
+		if !assert.NoError(t, decoder.Decode(&body)) {
+			return
+		}
+		assert.Equal(t, "bash", body.Cmd)
+		assert.True(t, body.Opts.StreamStdin)
+
+		// Start responding before stdin's read to ensure that it's streamed
+		// alongside the response
+		if !assert.NoError(t, http.NewResponseController(w).EnableFullDuplex()) {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		stdin, err := ioutil.ReadAll(io.MultiReader(decoder.Buffered(), r.Body))
+		assert.NoError(t, err)
+		enc := json.NewEncoder(w)
+		assert.NoError(t, enc.Encode(apitypes.ExecPacket{TypeField: apitypes.Stdout, Data: string(stdin)}))
+		assert.NoError(t, enc.Encode(apitypes.ExecPacket{TypeField: apitypes.Exitcode, Data: 0}))
+	}))
+	defer server.Close()
+	origBaseURL := domainSocketBaseURL
+	domainSocketBaseURL = server.URL
+	defer func() { domainSocketBaseURL = origBaseURL }()
+
+	c := &domainSocketClient{Client: server.Client()}
+	ch, err := c.Exec("/foo", "bash", []string{"-s"}, apitypes.ExecOptions{Stdin: strings.NewReader("echo hello\n")})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var packets []apitypes.ExecPacket
+	for pkt := range ch {
+		packets = append(packets, pkt)
+	}
+	if assert.Len(t, packets, 2) {
+		assert.Equal(t, "echo hello\n", packets[0].Data)
+		assert.Equal(t, apitypes.Exitcode, packets[1].TypeField)
+	}
+}
+
+func serveStream(t *testing.T, trailer string) (*domainSocketClient, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", apitypes.StreamEndTrailer)
+		w.WriteHeader(http.StatusOK)
+		_, err := io.WriteString(w, "hello\n")
+		assert.NoError(t, err)
+		if trailer != "" {
+			w.Header().Set(apitypes.StreamEndTrailer, trailer)
+		}
+	}))
+	origBaseURL := domainSocketBaseURL
+	domainSocketBaseURL = server.URL
+	return &domainSocketClient{Client: server.Client()}, func() {
+		domainSocketBaseURL = origBaseURL
+		server.Close()
+	}
+}
+
+func TestStreamReportsTheStreamEnd(t *testing.T) {
+	c, cleanup := serveStream(t, `{"reason":"the container exited","exit_code":3}`)
+	defer cleanup()
+
+	stream, err := c.Stream("/foo")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer stream.Close()
+	// The stream's a plain io.Reader, so it ends with io.EOF
+	output, err := ioutil.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(output))
+	if end, ok := StreamEndOf(stream); assert.True(t, ok) {
+		assert.Equal(t, "the container exited", end.Reason)
+		if assert.NotNil(t, end.ExitCode) {
+			assert.Equal(t, 3, *end.ExitCode)
+		}
+	}
+}
+
+func TestStreamReturnsEOFWithoutAStreamEnd(t *testing.T) {
+	c, cleanup := serveStream(t, "")
+	defer cleanup()
+
+	stream, err := c.Stream("/foo")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer stream.Close()
+	output, err := ioutil.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(output))
+	_, ok := StreamEndOf(stream)
+	assert.False(t, ok)
+}
+
+func TestProfileSendsTheAdminToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/admin/debug/pprof/profile", r.URL.Path)
+		assert.Equal(t, "5", r.URL.Query().Get("seconds"))
+		assert.Equal(t, "Bearer secret", r.Header.Get(apitypes.AdminTokenHeader))
+		_, err := io.WriteString(w, "profile")
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+	origBaseURL := domainSocketBaseURL
+	domainSocketBaseURL = server.URL
+	defer func() { domainSocketBaseURL = origBaseURL }()
+
+	socketPath := filepath.Join(t.TempDir(), "api.sock")
+	if !assert.NoError(t, ioutil.WriteFile(apitypes.AdminTokenPath(socketPath), []byte("secret\n"), 0600)) {
+		return
+	}
+	c := &domainSocketClient{Client: server.Client(), socketPath: socketPath}
+	rdr, err := c.Profile(apitypes.CPUProfile, 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rdr.Close()
+	profile, err := ioutil.ReadAll(rdr)
+	assert.NoError(t, err)
+	assert.Equal(t, "profile", string(profile))
+}
+
+func TestProfileRejectsUnknownProfiles(t *testing.T) {
+	c := &domainSocketClient{}
+	_, err := c.Profile("goroutine", 0)
+	assert.Regexp(t, "unknown profile goroutine", err)
+}
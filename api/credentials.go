@@ -0,0 +1,145 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+func toAPICredentialStatus(c *plugin.Credentials) apitypes.CredentialStatus {
+	status := c.Status()
+	result := apitypes.CredentialStatus{
+		Name:        status.Name,
+		Generation:  status.Generation,
+		RefreshedAt: status.RefreshedAt,
+		ExpiresAt:   status.ExpiresAt,
+		Refreshing:  status.Refreshing,
+	}
+	if status.LastError != nil {
+		result.LastError = status.LastError.Error()
+	}
+	return result
+}
+
+// swagger:route GET /credentials credentials listCredentials
+//
+// Get the credentials' statuses
+//
+// Get a list of the plugins' credentials, including when they were last
+// refreshed and when they expire. The credentials themselves aren't included.
+// Requests must set the Authorization header to "Bearer <token>", where
+// <token> is the content of the admin token file.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: CredentialsResponse
+//       401: errorResp
+//       500: errorResp
+var credentialsHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	all := plugin.AllCredentials()
+	result := make([]apitypes.CredentialStatus, 0, len(all))
+	for _, c := range all {
+		result = append(result, toAPICredentialStatus(c))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the credentials: %v", err))
+	}
+	return nil
+}
+
+// swagger:route GET /admin/credentials/{name} credentials getCredential
+//
+// Get a credential
+//
+// Gets the named credential, refreshing it if it's expired. Concurrent
+// requests for an expired credential share the same refresh. If the expired
+// parameter is set to a generation, then that generation of the credential is
+// expired first, e.g. because the plugin's backend rejected it. Requests must
+// set the Authorization header to "Bearer <token>", where <token> is either
+// the content of the admin token file or the credentials token that the
+// plugin's scripts are invoked with. A plugin's token only grants access to
+// the plugin's own credentials.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: Credential
+//       400: errorResp
+//       401: errorResp
+//       403: errorResp
+//       404: errorResp
+//       500: errorResp
+var credentialHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	name := mux.Vars(r)["name"]
+	c, ok := plugin.FindCredentials(name)
+	if !ok {
+		return credentialNotFoundResponse(name)
+	}
+
+	if expired := r.URL.Query().Get("expired"); expired != "" {
+		generation, err := strconv.Atoi(expired)
+		if err != nil {
+			return badRequestResponse(fmt.Sprintf("The expired parameter must be a generation, not %v", expired))
+		}
+		c.Expire(generation)
+	}
+	credential, err := c.Get(r.Context())
+	if err != nil {
+		return unknownErrorResponse(err)
+	}
+	// The credential's value is a secret, so only the request's recorded
+	activity.Record(r.Context(), "API: Credential %v (generation %v)", name, credential.Generation)
+
+	w.Header().Set("Content-Type", "application/json")
+	result := apitypes.Credential{
+		Name:       name,
+		Generation: credential.Generation,
+		ExpiresAt:  credential.ExpiresAt,
+		Value:      credential.Value,
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal credential %v: %v", name, err))
+	}
+	return nil
+}
+
+// requireCredentialAccess only passes the requests that include the admin
+// token, or the credentials token of the plugin that the requested credential
+// belongs to, on to next
+func requireCredentialAccess(adminToken string, next http.Handler) handler {
+	return func(w http.ResponseWriter, r *http.Request) *errorResponse {
+		auth := r.Header.Get(apitypes.AdminTokenHeader)
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return unauthorizedResponse("the request is missing the admin token or the plugin's credentials token")
+		}
+		got := strings.TrimPrefix(auth, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(adminToken)) != 1 {
+			owner, ok := plugin.CredentialsTokenOwner(got)
+			if !ok {
+				return unauthorizedResponse("the token is invalid")
+			}
+			if name := mux.Vars(r)["name"]; !plugin.IsCredentialOf(name, owner) {
+				return credentialForbiddenResponse(name, owner)
+			}
+		}
+		next.ServeHTTP(w, r)
+		return nil
+	}
+}
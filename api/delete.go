@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// swagger:route DELETE /fs/delete delete deleteEntry
+//
+// Deletes an entry
+//
+// Deletes the specified entry, e.g. terminates an EC2 instance or removes a
+// Docker container. The entry's parent's cached results are cleared so that
+// the entry's no longer listed.
+//
+//     Schemes: http
+//
+//     Responses:
+//       204:
+//       404: errorResp
+//       500: errorResp
+var deleteHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	ctx := r.Context()
+	entry, path, errResp := getEntryFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+
+	if !plugin.DeleteAction().IsSupportedOn(entry) {
+		return unsupportedActionResponse(path, entry, plugin.DeleteAction())
+	}
+	warnIfDeprecated(ctx, w, entry, path, plugin.DeleteAction())
+
+	if err := plugin.Delete(ctx, entry.(plugin.Deletable)); err != nil {
+		return actionErrorResponse(path, plugin.DeleteAction(), err)
+	}
+	activity.Record(ctx, "API: Deleted %v", path)
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 
 	apitypes "github.com/puppetlabs/wash/api/types"
 	"github.com/puppetlabs/wash/plugin"
@@ -22,6 +25,7 @@ type errorResp struct {
 func newErrorObj(kind string, message string, fields apitypes.ErrorFields) *apitypes.ErrorObj {
 	return &apitypes.ErrorObj{
 		Kind:   kind,
+		Code:   apitypes.ErrorCodeOf(kind),
 		Msg:    message,
 		Fields: fields,
 	}
@@ -91,7 +95,7 @@ func pluginDoesNotExistResponse(plugin string) *errorResponse {
 	return &errorResponse{statusCode, body}
 }
 
-func unsupportedActionResponse(path string, a plugin.Action) *errorResponse {
+func unsupportedActionResponse(path string, entry plugin.Entry, a plugin.Action) *errorResponse {
 	fields := apitypes.ErrorFields{
 		"path":   path,
 		"action": a,
@@ -99,6 +103,12 @@ func unsupportedActionResponse(path string, a plugin.Action) *errorResponse {
 
 	statusCode := http.StatusNotFound
 	msg := fmt.Sprintf("Entry %v does not support the %v action: It does not implement the %v protocol", path, a.Name, a.Protocol)
+	if deprecation, ok := a.IsRemovedFrom(entry); ok {
+		msg = fmt.Sprintf("Entry %v no longer supports the %v action: It was removed in %v", path, a.Name, deprecation.RemovedIn)
+		if deprecation.Message != "" {
+			msg += ": " + deprecation.Message
+		}
+	}
 	body := newErrorObj(
 		apitypes.UnsupportedAction,
 		msg,
@@ -157,6 +167,88 @@ func invalidBoolParam(name, value string) *errorResponse {
 	)}
 }
 
+func limitNotFoundResponse(name string) *errorResponse {
+	return &errorResponse{http.StatusNotFound, newErrorObj(
+		apitypes.LimitNotFound,
+		fmt.Sprintf("Limit %v does not exist", name),
+		apitypes.ErrorFields{"name": name},
+	)}
+}
+
+func featureNotFoundResponse(name string) *errorResponse {
+	return &errorResponse{http.StatusNotFound, newErrorObj(
+		apitypes.FeatureNotFound,
+		fmt.Sprintf("Feature flag %v does not exist", name),
+		apitypes.ErrorFields{"name": name},
+	)}
+}
+
+func pluginQuarantinedResponse(path string, e plugin.QuarantinedErr) *errorResponse {
+	return &errorResponse{http.StatusServiceUnavailable, newErrorObj(
+		apitypes.PluginQuarantined,
+		fmt.Sprintf("Could not access %v: %v", path, e),
+		apitypes.ErrorFields{"path": path, "plugin": e.Plugin, "failures": e.Failures},
+	)}
+}
+
+func credentialNotFoundResponse(name string) *errorResponse {
+	return &errorResponse{http.StatusNotFound, newErrorObj(
+		apitypes.CredentialNotFound,
+		fmt.Sprintf("Credential %v does not exist", name),
+		apitypes.ErrorFields{"name": name},
+	)}
+}
+
+func unauthorizedResponse(reason string) *errorResponse {
+	return &errorResponse{http.StatusUnauthorized, newErrorObj(
+		apitypes.Unauthorized,
+		fmt.Sprintf("Unauthorized: %v", reason),
+		apitypes.ErrorFields{},
+	)}
+}
+
+func credentialForbiddenResponse(name string, pluginName string) *errorResponse {
+	return &errorResponse{http.StatusForbidden, newErrorObj(
+		apitypes.CredentialForbidden,
+		fmt.Sprintf("The %v plugin's credentials token does not grant access to credential %v", pluginName, name),
+		apitypes.ErrorFields{"name": name, "plugin": pluginName},
+	)}
+}
+
+func devModeRequiredResponse(reason string) *errorResponse {
+	return &errorResponse{http.StatusForbidden, newErrorObj(
+		apitypes.DevModeRequired,
+		fmt.Sprintf("The server is not in dev mode: %v", reason),
+		apitypes.ErrorFields{},
+	)}
+}
+
+func pinNotFoundResponse(path string) *errorResponse {
+	return &errorResponse{http.StatusNotFound, newErrorObj(
+		apitypes.PinNotFound,
+		fmt.Sprintf("%v is not pinned", path),
+		apitypes.ErrorFields{"path": path},
+	)}
+}
+
+func operationNotFoundResponse(id string) *errorResponse {
+	return &errorResponse{http.StatusNotFound, newErrorObj(
+		apitypes.OperationNotFound,
+		fmt.Sprintf("Operation %v does not exist", id),
+		apitypes.ErrorFields{"id": id},
+	)}
+}
+
+func operationNotDoneResponse(op plugin.OperationInfo) *errorResponse {
+	fields := apitypes.ErrorFields{"id": op.ID, "status": op.Status}
+	msg := fmt.Sprintf("Operation %v is %v", op.ID, op.Status)
+	if op.Err != "" {
+		fields["error"] = op.Err
+		msg += ": " + op.Err
+	}
+	return &errorResponse{http.StatusConflict, newErrorObj(apitypes.OperationNotDone, msg, fields)}
+}
+
 func invalidPathsResponse() *errorResponse {
 	return &errorResponse{http.StatusBadRequest, newErrorObj(
 		apitypes.InvalidPaths,
@@ -181,6 +273,102 @@ func erroredActionResponse(path string, a plugin.Action, reason string) *errorRe
 	return &errorResponse{statusCode, body}
 }
 
+func permissionDeniedResponse(path string, reason string) *errorResponse {
+	fields := apitypes.ErrorFields{"path": path}
+
+	statusCode := http.StatusForbidden
+	body := newErrorObj(
+		apitypes.PermissionDenied,
+		fmt.Sprintf("Permission denied on %v: %v", path, reason),
+		fields,
+	)
+
+	return &errorResponse{statusCode, body}
+}
+
+func timeoutResponse(path string, reason string) *errorResponse {
+	fields := apitypes.ErrorFields{"path": path}
+
+	statusCode := http.StatusGatewayTimeout
+	body := newErrorObj(
+		apitypes.Timeout,
+		fmt.Sprintf("Timed out on %v: %v", path, reason),
+		fields,
+	)
+
+	return &errorResponse{statusCode, body}
+}
+
+func execPolicyResponse(path string, err error) *errorResponse {
+	fields := apitypes.ErrorFields{"path": path}
+
+	var kind string
+	statusCode := http.StatusForbidden
+	switch err := err.(type) {
+	case plugin.ExecConsentRequiredError:
+		kind = apitypes.ExecConsentRequired
+		statusCode = http.StatusPreconditionRequired
+		fields["banner"] = err.Banner
+	case plugin.ExecJustificationRequiredError:
+		kind = apitypes.ExecJustificationRequired
+		statusCode = http.StatusPreconditionRequired
+		fields["reason"] = err.Reason
+	default:
+		kind = apitypes.ExecDenied
+	}
+	body := newErrorObj(
+		kind,
+		fmt.Sprintf("Cannot exec on %v: %v", path, err),
+		fields,
+	)
+
+	return &errorResponse{statusCode, body}
+}
+
+// classifiedErrorResponse returns a timeout or permission denied response if
+// err is a timeout or permission error. Otherwise, it returns nil.
+func classifiedErrorResponse(path string, err error) *errorResponse {
+	var quarantinedErr plugin.QuarantinedErr
+	switch {
+	case errors.As(err, &quarantinedErr):
+		return pluginQuarantinedResponse(path, quarantinedErr)
+	case errors.Is(err, context.DeadlineExceeded):
+		return timeoutResponse(path, err.Error())
+	case errors.Is(err, os.ErrPermission):
+		return permissionDeniedResponse(path, err.Error())
+	case errors.Is(err, os.ErrNotExist):
+		return entryNotFoundResponse(path, err.Error())
+	default:
+		return nil
+	}
+}
+
+// metadataErrorResponse returns the classified error response for err if
+// there is one (see classifiedErrorResponse). Otherwise, it returns an unknown
+// error response.
+func metadataErrorResponse(path string, err error) *errorResponse {
+	if errResp := classifiedErrorResponse(path, err); errResp != nil {
+		return errResp
+	}
+	return unknownErrorResponse(err)
+}
+
+// actionErrorResponse returns the classified error response for err if there
+// is one (see classifiedErrorResponse). Otherwise, it returns an errored action
+// response. The errored action response is a 503 with its retryable field set if
+// err is retryable (see plugin.IsRetryable).
+func actionErrorResponse(path string, a plugin.Action, err error) *errorResponse {
+	if errResp := classifiedErrorResponse(path, err); errResp != nil {
+		return errResp
+	}
+	errResp := erroredActionResponse(path, a, err.Error())
+	if plugin.IsRetryable(err) {
+		errResp.statusCode = http.StatusServiceUnavailable
+		errResp.body.Fields["retryable"] = true
+	}
+	return errResp
+}
+
 func duplicateCNameResponse(e plugin.DuplicateCNameErr) *errorResponse {
 	fields := apitypes.ErrorFields{
 		"parent_id":                   e.ParentID,
@@ -200,6 +388,20 @@ func duplicateCNameResponse(e plugin.DuplicateCNameErr) *errorResponse {
 	return &errorResponse{http.StatusInternalServerError, body}
 }
 
+func patternTooBroadResponse(pattern string, reason string) *errorResponse {
+	fields := apitypes.ErrorFields{
+		"pattern": pattern,
+	}
+
+	body := newErrorObj(
+		apitypes.PatternTooBroad,
+		fmt.Sprintf("The pattern %v is too broad: %v. Use a more specific pattern or raise the limit.", pattern, reason),
+		fields,
+	)
+
+	return &errorResponse{http.StatusBadRequest, body}
+}
+
 func relativePathResponse(path string) *errorResponse {
 	fields := apitypes.ErrorFields{
 		"path": path,
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/cmd/version"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionErrorResponse(t *testing.T) {
+	timeoutErr := fmt.Errorf("list failed: %w", context.DeadlineExceeded)
+	errResp := actionErrorResponse("/foo", plugin.ListAction(), timeoutErr)
+	assert.Equal(t, http.StatusGatewayTimeout, errResp.statusCode)
+	assert.Equal(t, apitypes.Timeout, errResp.body.Kind)
+	assert.Equal(t, "WASH1017", errResp.body.Code)
+
+	permissionErr := &os.PathError{Op: "open", Path: "/foo", Err: os.ErrPermission}
+	errResp = actionErrorResponse("/foo", plugin.ReadAction(), permissionErr)
+	assert.Equal(t, http.StatusForbidden, errResp.statusCode)
+	assert.Equal(t, apitypes.PermissionDenied, errResp.body.Kind)
+
+	errResp = actionErrorResponse("/foo", plugin.ListAction(), fmt.Errorf("failed"))
+	assert.Equal(t, http.StatusInternalServerError, errResp.statusCode)
+	assert.Equal(t, apitypes.ErroredAction, errResp.body.Kind)
+
+	notFoundErr := &plugin.ExternalPluginError{Kind: plugin.ErrorKindNotFound, Message: "no such bucket"}
+	errResp = actionErrorResponse("/foo", plugin.ListAction(), notFoundErr)
+	assert.Equal(t, http.StatusNotFound, errResp.statusCode)
+	assert.Equal(t, apitypes.EntryNotFound, errResp.body.Kind)
+
+	retryableErr := &plugin.ExternalPluginError{Kind: plugin.ErrorKindUnavailable, Message: "rate-limited", Retryable: true}
+	errResp = actionErrorResponse("/foo", plugin.ListAction(), retryableErr)
+	assert.Equal(t, http.StatusServiceUnavailable, errResp.statusCode)
+	assert.Equal(t, apitypes.ErroredAction, errResp.body.Kind)
+	assert.Equal(t, true, errResp.body.Fields["retryable"])
+
+	quarantinedErr := plugin.QuarantinedErr{Plugin: "foo", Failures: 3, Reason: fmt.Errorf("list timed out")}
+	errResp = actionErrorResponse("/foo/bar", plugin.ListAction(), quarantinedErr)
+	assert.Equal(t, http.StatusServiceUnavailable, errResp.statusCode)
+	assert.Equal(t, apitypes.PluginQuarantined, errResp.body.Kind)
+	assert.Equal(t, "WASH1028", errResp.body.Code)
+	assert.Equal(t, "foo", errResp.body.Fields["plugin"])
+}
+
+func TestUnsupportedActionResponse(t *testing.T) {
+	entry := newMockEntry("foo")
+	errResp := unsupportedActionResponse("/foo", entry, plugin.ReadAction())
+	assert.Equal(t, http.StatusNotFound, errResp.statusCode)
+	assert.Equal(t, apitypes.UnsupportedAction, errResp.body.Kind)
+	assert.Regexp(t, "does not implement the Readable protocol", errResp.body.Msg)
+
+	defer func(v string) { version.BuildVersion = v }(version.BuildVersion)
+	version.BuildVersion = "2.1.0"
+	entry.DeprecateAction(plugin.ReadAction(), plugin.ActionDeprecation{Message: "use stream instead", RemovedIn: "2.0"})
+	errResp = unsupportedActionResponse("/foo", entry, plugin.ReadAction())
+	assert.Equal(t, http.StatusNotFound, errResp.statusCode)
+	assert.Equal(t, apitypes.UnsupportedAction, errResp.body.Kind)
+	assert.Equal(t, "Entry /foo no longer supports the read action: It was removed in 2.0: use stream instead", errResp.body.Msg)
+}
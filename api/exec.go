@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/puppetlabs/wash/activity"
 	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/metrics"
 	"github.com/puppetlabs/wash/plugin"
 )
 
@@ -46,6 +48,12 @@ type execResponse struct {
 // Execute a command on a remote system
 //
 // Executes a command on the remote system described by the supplied path.
+// If stream_stdin is set, then the rest of the request body (after the JSON
+// payload) is streamed to the command's stdin. Setting env or cwd responds
+// with a 400 if the entry doesn't honor those exec options. If the server has
+// an exec policy, then it responds with a 428 until the banner's consented to
+// (or a required justification's supplied), and with a 403 if it denies the
+// command.
 //
 //     Consumes:
 //     - application/json
@@ -58,7 +66,9 @@ type execResponse struct {
 //     Responses:
 //       200: execResponse
 //       400: errorResp
+//       403: errorResp
 //       404: errorResp
+//       428: errorResp
 //       500: errorResp
 var execHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
 	ctx := r.Context()
@@ -68,17 +78,37 @@ var execHandler handler = func(w http.ResponseWriter, r *http.Request) *errorRes
 	}
 
 	if !plugin.ExecAction().IsSupportedOn(entry) {
-		return unsupportedActionResponse(path, plugin.ExecAction())
+		return unsupportedActionResponse(path, entry, plugin.ExecAction())
 	}
+	warnIfDeprecated(ctx, w, entry, path, plugin.ExecAction())
 
 	if r.Body == nil {
 		return badActionRequestResponse(path, plugin.ExecAction(), "Please send a JSON request body")
 	}
 
 	var body apitypes.ExecBody
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&body); err != nil {
 		return badActionRequestResponse(path, plugin.ExecAction(), err.Error())
 	}
+	if body.Opts.StreamStdin && body.Opts.Input != "" {
+		return badActionRequestResponse(path, plugin.ExecAction(), "input and stream_stdin cannot both be set")
+	}
+
+	opts := plugin.ExecOptions{Env: body.Opts.Env, Cwd: body.Opts.Cwd}
+	if err := plugin.CheckExecOptions(entry.(plugin.Execable), opts); err != nil {
+		return badActionRequestResponse(path, plugin.ExecAction(), err.Error())
+	}
+
+	policyReq := plugin.ExecPolicyRequest{
+		Path:          path,
+		Cmd:           body.Cmd,
+		Args:          body.Args,
+		Justification: body.Opts.Justification,
+	}
+	if err := plugin.CheckExecPolicy(ctx, policyReq, body.Opts.Consented); err != nil {
+		return execPolicyResponse(path, err)
+	}
 
 	fw, ok := w.(flushableWriter)
 	if !ok {
@@ -86,15 +116,26 @@ var execHandler handler = func(w http.ResponseWriter, r *http.Request) *errorRes
 	}
 
 	activity.Record(ctx, "API: Exec %v %+v", path, body)
-	opts := plugin.ExecOptions{}
 	if body.Opts.Input != "" {
 		opts.Stdin = strings.NewReader(body.Opts.Input)
+	} else if body.Opts.StreamStdin {
+		// The rest of the request body is the command's stdin. It's read while
+		// the command's output is streamed, which HTTP/1 servers don't allow
+		// by default.
+		if err := http.NewResponseController(w).EnableFullDuplex(); err != nil {
+			return unknownErrorResponse(fmt.Errorf("Cannot stream stdin to %v: %v", path, err))
+		}
+		opts.Stdin = io.MultiReader(decoder.Buffered(), r.Body)
 	}
 	cmd, err := plugin.Exec(ctx, entry.(plugin.Execable), body.Cmd, body.Args, opts)
 	if err != nil {
-		return erroredActionResponse(path, plugin.ExecAction(), err.Error())
+		return actionErrorResponse(path, plugin.ExecAction(), err)
 	}
 
+	// Record the exec's transcript so that it can be replayed via the history
+	transcript := activity.RecordExec(ctx, path, body.Cmd, body.Args, body.Opts.Justification)
+	defer metrics.StartExec()()
+
 	// Ensure every write is a flush, and do an initial flush to send the header.
 	w.WriteHeader(http.StatusOK)
 	fw.Flush()
@@ -107,6 +148,7 @@ var execHandler handler = func(w http.ResponseWriter, r *http.Request) *errorRes
 			packet.Err = newStreamingErrorObj(chunk.StreamID, err.Error())
 		} else {
 			packet.Data = chunk.Data
+			transcript.Output(string(chunk.StreamID), chunk.Timestamp, chunk.Data)
 		}
 
 		sendPacket(ctx, enc, &packet)
@@ -115,6 +157,7 @@ var execHandler handler = func(w http.ResponseWriter, r *http.Request) *errorRes
 	// Now stream its exit code
 	packet := apitypes.ExecPacket{TypeField: apitypes.Exitcode, Timestamp: time.Now()}
 	exitCode, err := cmd.ExitCode()
+	transcript.Exit(exitCode, err)
 	if err != nil {
 		packet.Err = newUnknownErrorObj(fmt.Errorf("could not get the exit code: %v", err))
 	} else {
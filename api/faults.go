@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// swagger:response
+//nolint:deadcode,unused
+type faultRules struct {
+	// in: body
+	Rules []plugin.FaultRule
+}
+
+// swagger:route GET /faults faults listFaults
+//
+// Get the fault injection rules
+//
+// Get the rules that inject faults (delays, errors, or truncated results)
+// into plugin calls. They're meant for testing how Wash behaves when a
+// plugin's backend misbehaves.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: faultRules
+//       500: errorResp
+var faultsHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	return writeFaultRules(w)
+}
+
+// swagger:route PUT /faults faults setFaults
+//
+// Set the fault injection rules
+//
+// Replaces the fault injection rules. An empty array disables fault
+// injection. The rules can only be set while the server's in dev mode.
+// Requests must set the Authorization header to "Bearer <token>", where
+// <token> is the content of the admin token file.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: faultRules
+//       400: errorResp
+//       401: errorResp
+//       403: errorResp
+//       500: errorResp
+var setFaultsHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	if r.Body == nil {
+		return badRequestResponse("Please send a JSON request body")
+	}
+	var rules []plugin.FaultRule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		return badRequestResponse(err.Error())
+	}
+	if err := plugin.SetFaultRules(rules); err != nil {
+		return badRequestResponse(err.Error())
+	}
+	activity.Record(r.Context(), "API: Set the fault injection rules to %+v", rules)
+	return writeFaultRules(w)
+}
+
+// requireDevMode only passes the requests on to next if the server's in dev
+// mode
+func requireDevMode(devMode bool, next handler) handler {
+	return func(w http.ResponseWriter, r *http.Request) *errorResponse {
+		if !devMode {
+			return devModeRequiredResponse("restart it with --dev-mode (or set dev_mode in the config) to inject faults")
+		}
+		return next(w, r)
+	}
+}
+
+func writeFaultRules(w http.ResponseWriter) *errorResponse {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(plugin.FaultRules()); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the fault injection rules: %v", err))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type FaultsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *FaultsTestSuite) TearDownTest() {
+	suite.NoError(plugin.SetFaultRules(nil))
+}
+
+func (suite *FaultsTestSuite) put(devMode bool, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/faults", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	requireDevMode(devMode, setFaultsHandler).ServeHTTP(w, req)
+	return w
+}
+
+func (suite *FaultsTestSuite) TestSetFaultsRequiresDevMode() {
+	w := suite.put(false, `[{"path": "/docker/*", "error": "injected"}]`)
+	suite.Equal(http.StatusForbidden, w.Code)
+	suite.Contains(w.Body.String(), apitypes.DevModeRequired)
+	suite.Empty(plugin.FaultRules())
+
+	w = suite.put(true, `[{"path": "/docker/*", "error": "injected"}]`)
+	if suite.Equal(http.StatusOK, w.Code, w.Body.String()) {
+		suite.Equal([]plugin.FaultRule{{Path: "/docker/*", Error: "injected"}}, plugin.FaultRules())
+	}
+}
+
+func TestFaults(t *testing.T) {
+	suite.Run(t, new(FaultsTestSuite))
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/features"
+)
+
+func toAPIFeature(f *features.Flag) apitypes.Feature {
+	return apitypes.Feature{
+		Name:        f.Name(),
+		Description: f.Description(),
+		Enabled:     f.Enabled(""),
+		Overrides:   f.Overrides(),
+	}
+}
+
+// swagger:route GET /features features listFeatures
+//
+// Get the feature flags
+//
+// Get a list of the server's feature flags, including whether they're enabled
+// and their per-plugin overrides.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: FeaturesResponse
+//       500: errorResp
+var featuresHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	all := features.All()
+	result := make([]apitypes.Feature, 0, len(all))
+	for _, f := range all {
+		result = append(result, toAPIFeature(f))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the feature flags: %v", err))
+	}
+	return nil
+}
+
+// swagger:route PUT /features/{name} features toggleFeature
+//
+// Toggle a feature flag
+//
+// Enables or disables the feature flag. If plugin is set, then only the
+// plugin's override is set. The new value takes effect immediately. If
+// persist is true, then the new value is also written to the config so that
+// it's used the next time the server starts.
+// Requests must set the Authorization header to "Bearer <token>", where
+// <token> is the content of the admin token file.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: Feature
+//       400: errorResp
+//       401: errorResp
+//       404: errorResp
+//       500: errorResp
+var featureHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	name := mux.Vars(r)["name"]
+	f, ok := features.Get(name)
+	if !ok {
+		return featureNotFoundResponse(name)
+	}
+
+	if r.Body == nil {
+		return badRequestResponse("Please send a JSON request body")
+	}
+	var body apitypes.FeatureBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return badRequestResponse(err.Error())
+	}
+
+	if _, err := features.Set(name, body.Plugin, body.Enabled); err != nil {
+		return unknownErrorResponse(err)
+	}
+	if body.Plugin == "" {
+		activity.Record(r.Context(), "API: Set feature flag %v to %v", name, body.Enabled)
+	} else {
+		activity.Record(r.Context(), "API: Set feature flag %v to %v for plugin %v", name, body.Enabled, body.Plugin)
+	}
+	if body.Persist {
+		if err := features.Persist(f, body.Plugin); err != nil {
+			return unknownErrorResponse(fmt.Errorf("Could not persist feature flag %v: %v", name, err))
+		}
+		activity.Record(r.Context(), "API: Persisted feature flag %v", name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(toAPIFeature(f)); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal feature flag %v: %v", name, err))
+	}
+	return nil
+}
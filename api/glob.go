@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	pathpkg "path"
+	"sort"
+	"strings"
+
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// These limits bound the expansion of glob patterns so that over-broad
+// patterns (e.g. /**) error instead of listing every plugin's hierarchy
+var globMaxMatches = limits.Register(
+	"api.glob_max_matches",
+	"The maximum number of entries that a glob pattern (see `/fs/glob`) can match. Patterns that match more entries error. 0 disables the limit.",
+	1000,
+	nil,
+)
+
+var globMaxListed = limits.Register(
+	"api.glob_max_listed",
+	"The maximum number of parents that expanding a glob pattern can list. Patterns that need to list more parents error. 0 disables the limit.",
+	1000,
+	nil,
+)
+
+// swagger:route GET /fs/glob glob globEntries
+//
+// Expands a glob pattern
+//
+// Returns a list of Entry objects describing the entries whose path matches
+// the pattern given by the path parameter. Each of the pattern's segments can
+// contain the wildcards supported by path.Match (*, ? and [...]), which match
+// the segment's cname, and a ** segment matches zero or more segments.
+// Patterns that match more than api.glob_max_matches entries, or that need to
+// list more than api.glob_max_listed parents, error.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: entryList
+//       400: errorResp
+//       404: errorResp
+//       500: errorResp
+var globHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	ctx := r.Context()
+	pattern, errResp := getPathFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+
+	// The pattern's segments up to its first glob are resolved like any other
+	// path so that they don't count against the limits
+	segments := splitPath(pattern)
+	prefix := 0
+	for prefix < len(segments) && !apitypes.IsGlob(segments[prefix]) {
+		prefix++
+	}
+	for _, segment := range segments[prefix:] {
+		if _, err := pathpkg.Match(segment, ""); err != nil {
+			return badRequestResponse(fmt.Sprintf("%v is not a valid glob pattern: %v contains a malformed wildcard", pattern, segment))
+		}
+	}
+	entry, path, errResp := getEntryFromPath(ctx, "/"+strings.Join(segments[:prefix], "/"))
+	if errResp != nil {
+		return errResp
+	}
+
+	g := &globber{
+		ctx:        ctx,
+		pattern:    pattern,
+		maxMatches: globMaxMatches.Value(),
+		maxListed:  globMaxListed.Value(),
+		seen:       make(map[string]bool),
+	}
+	if errResp := g.expand(entry, path, segments[prefix:]); errResp != nil {
+		return errResp
+	}
+	if len(g.matches) == 0 {
+		reason := "no entries match the pattern"
+		if g.errors > 0 {
+			reason += fmt.Sprintf(" (%v parents could not be listed; see the journal for details)", g.errors)
+		}
+		return entryNotFoundResponse(pattern, reason)
+	}
+	sort.Slice(g.matches, func(i, j int) bool { return g.matches[i].Path < g.matches[j].Path })
+	activity.Record(ctx, "API: Glob %v matched %v entries", pattern, len(g.matches))
+
+	if err := writeCacheableJSON(w, r, g.matches, -1); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the entries that match %v: %v", pattern, err))
+	}
+	return nil
+}
+
+type globber struct {
+	ctx        context.Context
+	pattern    string
+	maxMatches int
+	maxListed  int
+	listed     int
+	// errors is the number of parents that couldn't be listed
+	errors  int
+	matches []apitypes.Entry
+	// seen contains the paths of the matches. A pattern with multiple **
+	// segments can match the same entry more than once.
+	seen map[string]bool
+}
+
+// expand adds the entries at or beneath entry (whose path is path) that match
+// segments to the matches
+func (g *globber) expand(entry plugin.Entry, path string, segments []string) *errorResponse {
+	if len(segments) == 0 {
+		return g.match(entry, path)
+	}
+	segment, rest := segments[0], segments[1:]
+	if segment == "**" {
+		if errResp := g.expand(entry, path, rest); errResp != nil {
+			return errResp
+		}
+		return g.forEachChild(entry, path, func(child plugin.Entry, childPath string) *errorResponse {
+			return g.expand(child, childPath, segments)
+		})
+	}
+	return g.forEachChild(entry, path, func(child plugin.Entry, childPath string) *errorResponse {
+		if matched, _ := pathpkg.Match(segment, plugin.CName(child)); !matched {
+			return nil
+		}
+		return g.expand(child, childPath, rest)
+	})
+}
+
+func (g *globber) match(entry plugin.Entry, path string) *errorResponse {
+	if g.seen[path] {
+		return nil
+	}
+	g.seen[path] = true
+	if g.maxMatches > 0 && len(g.matches) >= g.maxMatches {
+		return patternTooBroadResponse(g.pattern, fmt.Sprintf("it matches more than %v entries (the api.glob_max_matches limit)", g.maxMatches))
+	}
+	apiEntry := toAPIEntry(entry)
+	apiEntry.Path = path
+	g.matches = append(g.matches, apiEntry)
+	return nil
+}
+
+// forEachChild invokes f on each of entry's children. Failures to list entry
+// are recorded instead of returned so that one broken parent (e.g. a profile
+// with expired credentials) doesn't fail the entire expansion.
+func (g *globber) forEachChild(entry plugin.Entry, path string, f func(plugin.Entry, string) *errorResponse) *errorResponse {
+	if !plugin.ListAction().IsSupportedOn(entry) {
+		return nil
+	}
+	g.listed++
+	if g.maxListed > 0 && g.listed > g.maxListed {
+		return patternTooBroadResponse(g.pattern, fmt.Sprintf("expanding it lists more than %v parents (the api.glob_max_listed limit)", g.maxListed))
+	}
+	children, err := plugin.List(g.ctx, entry.(plugin.Parent))
+	if err != nil {
+		g.errors++
+		activity.Warnf(g.ctx, "API: Glob %v could not list %v: %v", g.pattern, path, err)
+		return nil
+	}
+	for _, child := range children {
+		if errResp := f(child, strings.TrimRight(path, "/")+"/"+plugin.CName(child)); errResp != nil {
+			return errResp
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type globTestsDir struct {
+	plugin.EntryBase
+	children []plugin.Entry
+}
+
+func newGlobTestsDir(name string, children ...plugin.Entry) *globTestsDir {
+	return &globTestsDir{EntryBase: plugin.NewEntry(name), children: children}
+}
+
+func (d *globTestsDir) Init(map[string]interface{}) error {
+	return nil
+}
+
+func (d *globTestsDir) List(context.Context) ([]plugin.Entry, error) {
+	return d.children, nil
+}
+
+func (d *globTestsDir) ChildSchemas() []*plugin.EntrySchema {
+	return nil
+}
+
+func (d *globTestsDir) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+type GlobHandlerTestSuite struct {
+	suite.Suite
+	router *mux.Router
+	ctx    context.Context
+}
+
+func (suite *GlobHandlerTestSuite) SetupTest() {
+	plugin.SetTestCache(datastore.NewMemCache())
+	suite.router = mux.NewRouter()
+	suite.router.Handle("/fs/glob", globHandler).Methods(http.MethodGet)
+
+	container := func(name string) plugin.Entry {
+		return newGlobTestsDir(name, newMockEntry("log"))
+	}
+	root := newGlobTestsDir("docker", newGlobTestsDir("containers", container("web-1"), container("web-2"), container("db")))
+	root.SetTestID("/docker")
+	registry := plugin.NewRegistry()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+	suite.ctx = context.WithValue(context.Background(), pluginRegistryKey, registry)
+	suite.ctx = context.WithValue(suite.ctx, mountpointKey, "/mnt")
+}
+
+func (suite *GlobHandlerTestSuite) TearDownTest() {
+	plugin.UnsetTestCache()
+	_, err := limits.Set(globMaxMatches.Name(), 1000)
+	suite.NoError(err)
+	_, err = limits.Set(globMaxListed.Name(), 1000)
+	suite.NoError(err)
+}
+
+func (suite *GlobHandlerTestSuite) glob(pattern string) *httptest.ResponseRecorder {
+	u := "http://example.com/fs/glob?" + url.Values{"path": []string{pattern}}.Encode()
+	req := httptest.NewRequest(http.MethodGet, u, nil).WithContext(suite.ctx)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *GlobHandlerTestSuite) assertMatches(pattern string, expected ...string) {
+	w := suite.glob(pattern)
+	if !suite.Equal(http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	var entries []apitypes.Entry
+	suite.NoError(json.Unmarshal(w.Body.Bytes(), &entries))
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	suite.Equal(expected, paths)
+}
+
+func (suite *GlobHandlerTestSuite) assertErrorKind(pattern string, statusCode int, kind string) {
+	w := suite.glob(pattern)
+	suite.Equal(statusCode, w.Code)
+	var errResp apitypes.ErrorObj
+	if suite.NoError(json.Unmarshal(w.Body.Bytes(), &errResp)) {
+		suite.Equal(kind, errResp.Kind)
+	}
+}
+
+func (suite *GlobHandlerTestSuite) TestWildcards() {
+	suite.assertMatches("/mnt/docker/containers/web-*", "/mnt/docker/containers/web-1", "/mnt/docker/containers/web-2")
+	suite.assertMatches("/mnt/docker/containers/web-?/log", "/mnt/docker/containers/web-1/log", "/mnt/docker/containers/web-2/log")
+	suite.assertMatches("/mnt/*/containers/[d]*", "/mnt/docker/containers/db")
+}
+
+func (suite *GlobHandlerTestSuite) TestDoubleStar() {
+	logs := []string{"/mnt/docker/containers/db/log", "/mnt/docker/containers/web-1/log", "/mnt/docker/containers/web-2/log"}
+	suite.assertMatches("/mnt/docker/**/log", logs...)
+	// Entries that are reachable in multiple ways are only matched once
+	suite.assertMatches("/mnt/docker/**/**/log", logs...)
+	suite.assertMatches("/mnt/docker/containers/**", append([]string{"/mnt/docker/containers"}, "/mnt/docker/containers/db", logs[0], "/mnt/docker/containers/web-1", logs[1], "/mnt/docker/containers/web-2", logs[2])...)
+}
+
+func (suite *GlobHandlerTestSuite) TestNoMatches() {
+	suite.assertErrorKind("/mnt/docker/containers/nope-*", http.StatusNotFound, apitypes.EntryNotFound)
+}
+
+func (suite *GlobHandlerTestSuite) TestInvalidPattern() {
+	suite.assertErrorKind("/mnt/docker/containers/[", http.StatusBadRequest, apitypes.BadRequest)
+}
+
+func (suite *GlobHandlerTestSuite) TestLimits() {
+	_, err := limits.Set(globMaxMatches.Name(), 2)
+	suite.NoError(err)
+	suite.assertMatches("/mnt/docker/containers/web-*", "/mnt/docker/containers/web-1", "/mnt/docker/containers/web-2")
+	suite.assertErrorKind("/mnt/docker/containers/*", http.StatusBadRequest, apitypes.PatternTooBroad)
+
+	_, err = limits.Set(globMaxMatches.Name(), 0)
+	suite.NoError(err)
+	_, err = limits.Set(globMaxListed.Name(), 2)
+	suite.NoError(err)
+	suite.assertErrorKind("/mnt/docker/**/log", http.StatusBadRequest, apitypes.PatternTooBroad)
+}
+
+func TestGlobHandler(t *testing.T) {
+	suite.Run(t, new(GlobHandlerTestSuite))
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// swagger:route GET /plugins/{name}/help help pluginHelp
+//
+// Get a plugin's help document
+//
+// Returns the help document that the plugin supplied. It's also readable as
+// the .help entry at the plugin's root.
+//
+//     Produces:
+//     - application/json
+//     - application/octet-stream
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: octetResponse
+//       404: errorResp
+//       500: errorResp
+var pluginHelpHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	name := mux.Vars(r)["name"]
+	registry := r.Context().Value(pluginRegistryKey).(*plugin.Registry)
+	root, ok := registry.Plugins()[name]
+	if !ok {
+		return pluginDoesNotExistResponse(name)
+	}
+	help := plugin.Help(root)
+	if help == "" {
+		return entryNotFoundResponse("/"+name+"/"+plugin.HelpCName, "the plugin does not have a help document")
+	}
+
+	activity.Record(r.Context(), "API: Getting the %v plugin's help", name)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, help); err != nil {
+		activity.Record(r.Context(), "API: Getting the %v plugin's help incomplete: %v", name, err)
+	}
+	return nil
+}
@@ -2,6 +2,9 @@ package api
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -9,22 +12,42 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/puppetlabs/wash/activity"
 	apifs "github.com/puppetlabs/wash/api/fs"
 	apitypes "github.com/puppetlabs/wash/api/types"
 	"github.com/puppetlabs/wash/plugin"
 )
 
 func toAPIEntry(e plugin.Entry) apitypes.Entry {
+	target, _ := plugin.SymlinkTargetOf(e)
+	attr := plugin.Attributes(e)
+	attr.SetMeta(plugin.NormalizeMetadata(e, attr.Meta()))
 	return apitypes.Entry{
-		TypeID:     plugin.TypeID(e),
-		Name:       plugin.Name(e),
-		CName:      plugin.CName(e),
-		Actions:    plugin.SupportedActionsOf(e),
-		Attributes: plugin.Attributes(e),
+		TypeID:            plugin.TypeID(e),
+		Name:              plugin.Name(e),
+		CName:             plugin.CName(e),
+		Actions:           plugin.SupportedActionsOf(e),
+		Attributes:        attr,
+		DeprecatedActions: plugin.DeprecatedActionsOf(e),
+		CustomActions:     plugin.CustomActionsOf(e),
+		SymlinkTarget:     target,
 	}
 }
 
+// warnIfDeprecated adds a warning to the response (and to the activity
+// journal) if the action's deprecated on the entry.
+func warnIfDeprecated(ctx context.Context, w http.ResponseWriter, entry plugin.Entry, path string, action plugin.Action) {
+	deprecation, ok := action.IsDeprecatedOn(entry)
+	if !ok {
+		return
+	}
+	msg := deprecation.Warning(path, action.Name)
+	activity.Warnf(ctx, "API: %v", msg)
+	w.Header().Add(apitypes.WarningHeader, apitypes.FormatWarning(msg))
+}
+
 func toAPIEntrySchema(s *plugin.EntrySchema) *apitypes.EntrySchema {
 	if s == nil {
 		return nil
@@ -41,6 +64,10 @@ func findEntry(ctx context.Context, root plugin.Entry, segments []string) (plugi
 		if cnameErr, ok := err.(plugin.DuplicateCNameErr); ok {
 			return nil, duplicateCNameResponse(cnameErr)
 		}
+		var quarantinedErr plugin.QuarantinedErr
+		if errors.As(err, &quarantinedErr) {
+			return nil, pluginQuarantinedResponse(path, quarantinedErr)
+		}
 
 		return nil, entryNotFoundResponse(path, err.Error())
 	}
@@ -57,6 +84,14 @@ func getPathFromRequest(r *http.Request) (string, *errorResponse) {
 		return "", relativePathResponse(path)
 	}
 
+	// Paths that reach the mountpoint via a symlink (e.g. a ~/wash symlink to
+	// the mountpoint) are handled like any other Wash path
+	if mountpoint, ok := r.Context().Value(mountpointKey).(string); ok && !isWithin(path, mountpoint) {
+		if resolvedPath, err := cachedCanonicalPath(mountpoint, path); err == nil && isWithin(resolvedPath, mountpoint) {
+			path = resolvedPath
+		}
+	}
+
 	return path, nil
 }
 
@@ -87,8 +122,10 @@ func getEntryFromRequest(r *http.Request) (plugin.Entry, string, *errorResponse)
 	if errResp != nil {
 		return nil, "", errResp
 	}
+	return getEntryFromPath(r.Context(), path)
+}
 
-	ctx := r.Context()
+func getEntryFromPath(ctx context.Context, path string) (plugin.Entry, string, *errorResponse) {
 	trimmedPath, errResp := toWashPath(ctx, path)
 	if errResp != nil {
 		if errResp.body.Kind != apitypes.NonWashPath {
@@ -151,3 +188,31 @@ func getBoolParam(u *url.URL, key string) (bool, *errorResponse) {
 	}
 	return false, nil
 }
+
+// writeCacheableJSON writes v as JSON along with headers that let clients cache
+// the response for up to ttl and then revalidate it via its ETag. It responds
+// with 304 Not Modified instead if the request's If-None-Match header matches
+// the ETag. A negative ttl means that the response must always be revalidated.
+func writeCacheableJSON(w http.ResponseWriter, r *http.Request, v interface{}, ttl time.Duration) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	body = append(body, '\n')
+
+	etag := fmt.Sprintf("\"%x\"", sha1.Sum(body))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag)
+	if ttl >= time.Second {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(ttl/time.Second)))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	_, err = w.Write(body)
+	return err
+}
@@ -3,9 +3,12 @@ package api
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
+	apitypes "github.com/puppetlabs/wash/api/types"
 	"github.com/puppetlabs/wash/plugin"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -134,6 +137,34 @@ func (suite *HelpersTestSuite) TestGetBoolParam() {
 	suite.Error(err)
 }
 
+func (suite *HelpersTestSuite) TestWarnIfDeprecated() {
+	entry := newMockEntry("foo")
+	w := httptest.NewRecorder()
+	warnIfDeprecated(context.Background(), w, entry, "/foo", plugin.ReadAction())
+	suite.Empty(w.Header().Get(apitypes.WarningHeader))
+
+	entry.DeprecateAction(plugin.ReadAction(), plugin.ActionDeprecation{Message: "use stream instead", RemovedIn: "2.0"})
+	warnIfDeprecated(context.Background(), w, entry, "/foo", plugin.ReadAction())
+	warning := apitypes.ParseWarning(w.Header().Get(apitypes.WarningHeader))
+	suite.Equal("the read action on /foo is deprecated and will be removed in 2.0: use stream instead", warning)
+}
+
+func (suite *HelpersTestSuite) TestWriteCacheableJSON() {
+	v := map[string]interface{}{"state": "running"}
+
+	r := httptest.NewRequest(http.MethodGet, "/fs/metadata", nil)
+	w := httptest.NewRecorder()
+	suite.NoError(writeCacheableJSON(w, r, v, time.Minute))
+	suite.Equal("application/json", w.Header().Get("Content-Type"))
+	suite.Equal("{\"state\":\"running\"}\n", w.Body.String())
+
+	// The response's ETag revalidates it
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	suite.NoError(writeCacheableJSON(w, r, v, time.Minute))
+	suite.Equal(http.StatusNotModified, w.Code)
+}
+
 func TestHelpers(t *testing.T) {
 	suite.Run(t, new(HelpersTestSuite))
 }
@@ -180,3 +180,54 @@ var historyEntryHandler handler = func(w http.ResponseWriter, r *http.Request) *
 	}
 	return nil
 }
+
+// swagger:response
+//nolint:deadcode,unused
+type execTranscriptsResponse struct {
+	// in: body
+	Transcripts []activity.ExecTranscript
+}
+
+// swagger:route GET /history/{id}/exec journal getExecTranscripts
+//
+// Get the exec transcripts for a particular entry in history
+//
+// Get the transcripts of the commands that were executed by a particular
+// command run via 'wash', requested by index within its activity history.
+// Each transcript includes the command's timestamped stdout/stderr output
+// and its exit code.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: execTranscriptsResponse
+//       400: errorResp
+//       404: errorResp
+//       500: errorResp
+var historyExecHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	history := activity.History()
+	index := mux.Vars(r)["index"]
+
+	idx, err := strconv.Atoi(index)
+	if err != nil || idx < 0 || idx >= len(history) {
+		if err == nil {
+			err = fmt.Errorf("index out of bounds")
+		}
+		return outOfBoundsRequest(len(history), err.Error())
+	}
+
+	journal := history[idx]
+	transcripts, err := journal.Transcripts()
+	if err != nil {
+		return journalUnavailableResponse(journal.String(), err.Error())
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(transcripts); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the exec transcripts of %v: %v", journal, err))
+	}
+	return nil
+}
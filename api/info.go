@@ -4,6 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
 )
 
 // swagger:route GET /fs/info info entryInfo
@@ -37,3 +43,76 @@ var infoHandler handler = func(w http.ResponseWriter, r *http.Request) *errorRes
 	}
 	return nil
 }
+
+// swagger:route GET /fs/info/capabilities info entryCapabilities
+//
+// Capabilities of the entry at path
+//
+// Returns an EntryCapabilities object describing the entry's supported
+// actions, the attributes it sets, how its ops are cached, and the plugin
+// it belongs to. Nothing is fetched from the plugin to build it.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: EntryCapabilities
+//       400: errorResp
+//       404: errorResp
+//       500: errorResp
+var capabilitiesHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	entry, path, errResp := getEntryFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+
+	capabilities := apitypes.EntryCapabilities{
+		Path:              path,
+		TypeID:            plugin.TypeID(entry),
+		Actions:           plugin.SupportedActionsOf(entry),
+		DeprecatedActions: plugin.DeprecatedActionsOf(entry),
+		CustomActions:     plugin.CustomActionsOf(entry),
+		Attributes:        []string{},
+		Cache:             make(map[string]apitypes.OpCache),
+	}
+	attr := plugin.Attributes(entry)
+	for name := range attr.ToMap(false) {
+		capabilities.Attributes = append(capabilities.Attributes, name)
+	}
+	sort.Strings(capabilities.Attributes)
+
+	if plugin.ListAction().IsSupportedOn(entry) {
+		capabilities.Cache["list"] = toOpCache(plugin.TTLOf(entry, plugin.ListOp), plugin.IsCached(entry, plugin.ListOp))
+	}
+	if plugin.ReadAction().IsSupportedOn(entry) {
+		capabilities.Cache["read"] = toOpCache(plugin.TTLOf(entry, plugin.OpenOp), plugin.IsCached(entry, plugin.OpenOp))
+	}
+	capabilities.Cache["metadata"] = toOpCache(plugin.TTLOf(entry, plugin.MetadataOp), plugin.IsCached(entry, plugin.MetadataOp))
+	capabilities.StreamingList = plugin.SupportsStreamingList(entry)
+
+	if washPath, errResp := toWashPath(r.Context(), path); errResp == nil {
+		capabilities.Plugin.Name = strings.Split(strings.Trim(washPath, "/"), "/")[0]
+	}
+	if info, ok := plugin.ExternalPluginInfoOf(entry); ok {
+		capabilities.Plugin.External = true
+		capabilities.Plugin.Script = info.Script
+		capabilities.Plugin.Version = info.Version
+		capabilities.Plugin.ProtocolVersion = info.ProtocolVersion
+	}
+
+	if err := json.NewEncoder(w).Encode(&capabilities); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the capabilities of %v: %v", path, err))
+	}
+	return nil
+}
+
+func toOpCache(ttl time.Duration, cached bool) apitypes.OpCache {
+	if ttl < 0 {
+		// Caching's disabled, which is reported as -1 since TTLs of less
+		// than one second would otherwise round to 0
+		return apitypes.OpCache{TTL: -1}
+	}
+	return apitypes.OpCache{TTL: int64(ttl.Seconds()), Cached: cached}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type CapabilitiesHandlerTestSuite struct {
+	suite.Suite
+	router   *mux.Router
+	ctx      context.Context
+	registry *plugin.Registry
+	root     *globTestsDir
+}
+
+func (suite *CapabilitiesHandlerTestSuite) SetupTest() {
+	plugin.SetTestCache(datastore.NewMemCache())
+	suite.router = mux.NewRouter()
+	suite.router.Handle("/fs/info/capabilities", capabilitiesHandler).Methods(http.MethodGet)
+
+	container := newListTestsMetadataEntry("foo", func(context.Context) (plugin.JSONObject, error) {
+		return plugin.JSONObject{"state": "running"}, nil
+	})
+	container.DisableCachingFor(plugin.MetadataOp)
+	container.Attributes().SetMtime(time.Now()).SetSize(10)
+	suite.root = newGlobTestsDir("docker", container)
+	suite.root.SetTTLOf(plugin.ListOp, 30*time.Second)
+	suite.root.SetTestID("/docker")
+	suite.registry = plugin.NewRegistry()
+	suite.NoError(suite.registry.RegisterPlugin(suite.root, nil))
+	suite.ctx = context.WithValue(context.Background(), pluginRegistryKey, suite.registry)
+	suite.ctx = context.WithValue(suite.ctx, mountpointKey, "/mnt")
+}
+
+func (suite *CapabilitiesHandlerTestSuite) TearDownTest() {
+	plugin.UnsetTestCache()
+}
+
+func (suite *CapabilitiesHandlerTestSuite) capabilities(path string) apitypes.EntryCapabilities {
+	params := url.Values{"path": []string{path}}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/fs/info/capabilities?"+params.Encode(), nil).WithContext(suite.ctx)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	if !suite.Equal(http.StatusOK, w.Code, w.Body.String()) {
+		suite.FailNow("getting the capabilities failed")
+	}
+	var capabilities apitypes.EntryCapabilities
+	suite.NoError(json.Unmarshal(w.Body.Bytes(), &capabilities))
+	return capabilities
+}
+
+func (suite *CapabilitiesHandlerTestSuite) TestParent() {
+	capabilities := suite.capabilities("/mnt/docker")
+	suite.Equal("/mnt/docker", capabilities.Path)
+	suite.Equal([]string{"list"}, capabilities.Actions)
+	suite.Equal(apitypes.PluginIdentity{Name: "docker"}, capabilities.Plugin)
+	suite.Equal(apitypes.OpCache{TTL: 30}, capabilities.Cache["list"])
+	suite.NotContains(capabilities.Cache, "read")
+	suite.Contains(capabilities.Cache, "metadata")
+	suite.False(capabilities.StreamingList)
+
+	_, err := plugin.CachedList(suite.ctx, suite.root)
+	suite.NoError(err)
+	capabilities = suite.capabilities("/mnt/docker")
+	suite.Equal(apitypes.OpCache{TTL: 30, Cached: true}, capabilities.Cache["list"])
+}
+
+func (suite *CapabilitiesHandlerTestSuite) TestChild() {
+	capabilities := suite.capabilities("/mnt/docker/foo")
+	suite.Equal([]string{}, capabilities.Actions)
+	suite.Equal([]string{"mtime", "size"}, capabilities.Attributes)
+	suite.Equal(map[string]apitypes.OpCache{"metadata": {TTL: -1}}, capabilities.Cache)
+	suite.Equal("docker", capabilities.Plugin.Name)
+	suite.False(capabilities.Plugin.External)
+}
+
+func TestCapabilitiesHandler(t *testing.T) {
+	suite.Run(t, new(CapabilitiesHandlerTestSuite))
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/limits"
+)
+
+func toAPILimit(l *limits.Limit) apitypes.Limit {
+	return apitypes.Limit{
+		Name:        l.Name(),
+		Description: l.Description(),
+		Value:       l.Value(),
+	}
+}
+
+// swagger:route GET /limits limits listLimits
+//
+// Get the limits
+//
+// Get a list of the server's concurrency and rate limits, including their
+// current values.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: LimitsResponse
+//       500: errorResp
+var limitsHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	all := limits.All()
+	result := make([]apitypes.Limit, 0, len(all))
+	for _, l := range all {
+		result = append(result, toAPILimit(l))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the limits: %v", err))
+	}
+	return nil
+}
+
+// swagger:route PUT /limits/{name} limits tuneLimit
+//
+// Tune a limit
+//
+// Sets the limit's value. The new value takes effect immediately. If persist
+// is true, then the new value is also written to the config so that it's
+// used the next time the server starts.
+// Requests must set the Authorization header to "Bearer <token>", where
+// <token> is the content of the admin token file.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: Limit
+//       400: errorResp
+//       401: errorResp
+//       404: errorResp
+//       500: errorResp
+var limitHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	name := mux.Vars(r)["name"]
+	l, ok := limits.Get(name)
+	if !ok {
+		return limitNotFoundResponse(name)
+	}
+
+	if r.Body == nil {
+		return badRequestResponse("Please send a JSON request body")
+	}
+	var body apitypes.LimitBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return badRequestResponse(err.Error())
+	}
+
+	if _, err := limits.Set(name, body.Value); err != nil {
+		return unknownErrorResponse(err)
+	}
+	activity.Record(r.Context(), "API: Set limit %v to %v", name, body.Value)
+	if body.Persist {
+		if err := limits.Persist(l); err != nil {
+			return unknownErrorResponse(fmt.Errorf("Could not persist limit %v: %v", name, err))
+		}
+		activity.Record(r.Context(), "API: Persisted limit %v", name)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(toAPILimit(l)); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal limit %v: %v", name, err))
+	}
+	return nil
+}
@@ -1,16 +1,36 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"sort"
+	"time"
 
 	"github.com/puppetlabs/wash/activity"
 	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/limits"
 	"github.com/puppetlabs/wash/plugin"
 )
 
+// These limits bound fetching the children's metadata (or telemetry) when
+// listing with metadata so that a few slow children can't hold up the whole
+// listing
+var listMetadataTimeout = limits.Register(
+	"api.list_metadata_timeout_ms",
+	"How many milliseconds listing with metadata or telemetry (see `/fs/list`) waits for the children's metadata or telemetry. Children whose metadata isn't fetched in time are marked as stale. 0 disables the timeout.",
+	5000,
+	nil,
+)
+
+var maxParallelListMetadata = limits.Register(
+	"api.max_parallel_list_metadata",
+	"The maximum number of children whose metadata (or telemetry) is fetched concurrently when listing with metadata or telemetry (see `/fs/list`). 0 means unlimited.",
+	10,
+	nil,
+)
+
 // swagger:response
 //nolint:deadcode,unused
 type entryList struct {
@@ -23,9 +43,40 @@ type entryList struct {
 // Lists children of a path
 //
 // Returns a list of Entry objects describing children of the given path.
+// Paths with more children than the plugins.partition_threshold limit are
+// listed as synthetic partitions of their children unless flat is true.
+//
+// If metadata is true, then each child's metadata is fetched in parallel and
+// included in its Entry object. Children whose metadata isn't fetched within
+// the api.list_metadata_timeout_ms limit are marked as stale, and children
+// that errored (including the error entries of partially failed listings)
+// include the error. The listing only fails if strict is true, in which case
+// the first such error fails it.
+//
+// If telemetry is true, then the telemetry of each child that supports the
+// telemetry action is fetched in parallel and included in its Entry object.
+// Telemetry's best-effort, so children whose telemetry errored or isn't
+// fetched in time are listed without it. The listing's cached for the shorter
+// of the parent's list TTL and the plugins.telemetry_ttl_ms limit.
+//
+// If stream is true, then the children are streamed as newline-delimited
+// ListPacket objects as they're listed instead of being returned once they're
+// all listed. That's useful for parents with lots of children, like S3
+// buckets. Streaming listings aren't partitioned (like flat) and can't include
+// metadata or telemetry. If the listing fails after some children were streamed, then its
+// last packet is the error.
+//
+// If paged is true, then only a page of the children is listed, and it's
+// returned as a ListPage object whose next_page is the token of the next page
+// (if there is one). Pass that token as the page parameter to list the next
+// page; page implies paged. Only parents whose listings are actually paged,
+// like an external plugin entry whose script pages its list results, have
+// more than one page. Pages aren't partitioned (like flat) or cached, and they
+// can't be streamed.
 //
 //     Produces:
 //     - application/json
+//     - application/x-ndjson
 //
 //     Schemes: http
 //
@@ -34,6 +85,7 @@ type entryList struct {
 //       400: errorResp
 //       404: errorResp
 //       500: errorResp
+//       504: errorResp
 var listHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
 	ctx := r.Context()
 	entry, path, errResp := getEntryFromRequest(r)
@@ -42,32 +94,334 @@ var listHandler handler = func(w http.ResponseWriter, r *http.Request) *errorRes
 	}
 
 	if !plugin.ListAction().IsSupportedOn(entry) {
-		return unsupportedActionResponse(path, plugin.ListAction())
+		return unsupportedActionResponse(path, entry, plugin.ListAction())
+	}
+	warnIfDeprecated(ctx, w, entry, path, plugin.ListAction())
+
+	flat, errResp := getBoolParam(r.URL, "flat")
+	if errResp != nil {
+		return errResp
+	}
+	withMetadata, errResp := getBoolParam(r.URL, "metadata")
+	if errResp != nil {
+		return errResp
+	}
+	withTelemetry, errResp := getBoolParam(r.URL, "telemetry")
+	if errResp != nil {
+		return errResp
+	}
+	strict, errResp := getBoolParam(r.URL, "strict")
+	if errResp != nil {
+		return errResp
+	}
+
+	stream, errResp := getBoolParam(r.URL, "stream")
+	if errResp != nil {
+		return errResp
 	}
 
+	paged, errResp := getBoolParam(r.URL, "paged")
+	if errResp != nil {
+		return errResp
+	}
+	page := r.URL.Query().Get("page")
+	paged = paged || page != ""
+
 	parent := entry.(plugin.Parent)
-	entries, err := plugin.List(ctx, parent)
+	if stream {
+		if paged {
+			return badRequestResponse("paged listings can't be streamed")
+		}
+		if withMetadata {
+			return badRequestResponse("streaming listings can't include metadata")
+		}
+		if withTelemetry {
+			return badRequestResponse("streaming listings can't include telemetry")
+		}
+		return streamList(ctx, w, parent, path, strict)
+	}
+	list := plugin.PartitionedList
+	if flat {
+		list = plugin.List
+	}
+	var nextPage string
+	if paged {
+		list = func(ctx context.Context, p plugin.Parent) (map[string]plugin.Entry, error) {
+			entries, next, err := plugin.ListPage(ctx, p, page)
+			nextPage = next
+			return entries, err
+		}
+	}
+	entries, err := list(ctx, parent)
 	if err != nil {
 		if cnameErr, ok := err.(plugin.DuplicateCNameErr); ok {
 			return duplicateCNameResponse(cnameErr)
 		}
 
-		return erroredActionResponse(path, plugin.ListAction(), err.Error())
+		return actionErrorResponse(path, plugin.ListAction(), err)
 	}
 
 	result := make([]apitypes.Entry, 0, len(entries))
+	listed := make([]plugin.Entry, 0, len(entries))
 	for _, entry := range entries {
 		apiEntry := toAPIEntry(entry)
 		apiEntry.Path = path + "/" + apiEntry.CName
+		if errorEntry, ok := entry.(*plugin.ErrorEntry); ok {
+			errResp := erroredActionResponse(apiEntry.Path, plugin.ListAction(), errorEntry.Err().Error())
+			if strict {
+				return errResp
+			}
+			apiEntry.Error = errResp.body
+		}
 		result = append(result, apiEntry)
+		listed = append(listed, entry)
+	}
+	if withMetadata {
+		if errResp := fetchListMetadata(ctx, listed, result, strict); errResp != nil {
+			return errResp
+		}
+	}
+	if withTelemetry {
+		fetchListTelemetry(ctx, listed, result)
 	}
 	// Sort entries so they have a deterministic order.
 	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
 	activity.Record(ctx, "API: List %v %+v", path, result)
 
-	jsonEncoder := json.NewEncoder(w)
-	if err = jsonEncoder.Encode(result); err != nil {
+	if paged {
+		// Pages aren't cached, so they should always be revalidated
+		if err = writeCacheableJSON(w, r, apitypes.ListPage{Entries: result, NextPage: nextPage}, -1); err != nil {
+			return unknownErrorResponse(fmt.Errorf("Could not marshal the list page of %v: %v", path, err))
+		}
+		return nil
+	}
+
+	ttl := plugin.TTLOf(entry, plugin.ListOp)
+	if withTelemetry && ttl >= 0 {
+		if telemetryTTL := plugin.TelemetryTTL(); telemetryTTL < ttl {
+			ttl = telemetryTTL
+		}
+	}
+	for _, apiEntry := range result {
+		if apiEntry.Stale || apiEntry.Error != nil {
+			// Partial results should be revalidated so that clients pick up
+			// the missing metadata once it's available
+			ttl = -1
+			break
+		}
+	}
+	if err = writeCacheableJSON(w, r, result, ttl); err != nil {
 		return unknownErrorResponse(fmt.Errorf("Could not marshal list results for %v: %v", path, err))
 	}
 	return nil
 }
+
+// fetchForEntries calls fetch on each of the entries at the given indices in
+// parallel, and calls handle with each of the fetched results in the calling
+// goroutine. At most plugins.max_parallel_list_metadata are fetched at a time,
+// and they're fetched within plugins.list_metadata_timeout_ms. It stops once
+// handle returns false. Otherwise, it returns the indices of the entries whose
+// results aren't fetched in time.
+func fetchForEntries(
+	ctx context.Context,
+	entries []plugin.Entry,
+	indices []int,
+	fetch func(context.Context, plugin.Entry) (plugin.JSONObject, error),
+	handle func(index int, value plugin.JSONObject, err error) bool,
+) []int {
+	if len(indices) == 0 {
+		return nil
+	}
+	var cancel context.CancelFunc
+	if timeout := listMetadataTimeout.Value(); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	type fetchResult struct {
+		index int
+		value plugin.JSONObject
+		err   error
+	}
+	// resultCh is buffered so that the workers never block on it, even after
+	// we've stopped waiting for them
+	resultCh := make(chan fetchResult, len(indices))
+	indexCh := make(chan int)
+	workers := maxParallelListMetadata.Value()
+	if workers <= 0 || workers > len(indices) {
+		workers = len(indices)
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for index := range indexCh {
+				value, err := fetch(ctx, entries[index])
+				resultCh <- fetchResult{index, value, err}
+			}
+		}()
+	}
+	go func() {
+		defer close(indexCh)
+		for _, index := range indices {
+			select {
+			case indexCh <- index:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	fetched := make(map[int]bool)
+	for len(fetched) < len(indices) && ctx.Err() == nil {
+		select {
+		case r := <-resultCh:
+			if r.err != nil && ctx.Err() != nil {
+				// The fetch was cut short by the timeout
+				continue
+			}
+			fetched[r.index] = true
+			if !handle(r.index, r.value, r.err) {
+				return nil
+			}
+		case <-ctx.Done():
+		}
+	}
+	var unfetched []int
+	for _, index := range indices {
+		if !fetched[index] {
+			unfetched = append(unfetched, index)
+		}
+	}
+	return unfetched
+}
+
+// fetchListMetadata fetches the metadata of each of the listed entries in
+// parallel and adds it to their corresponding API entry in result. API entries
+// whose metadata isn't fetched in time are marked as stale, and those whose
+// metadata errored include the error. If strict is true, then the first such
+// entry's error is returned instead.
+func fetchListMetadata(ctx context.Context, entries []plugin.Entry, result []apitypes.Entry, strict bool) *errorResponse {
+	var indices []int
+	for index, entry := range entries {
+		if _, ok := entry.(*plugin.ErrorEntry); !ok {
+			indices = append(indices, index)
+		}
+	}
+
+	var errResp *errorResponse
+	unfetched := fetchForEntries(ctx, entries, indices, plugin.CachedMetadata, func(index int, metadata plugin.JSONObject, err error) bool {
+		apiEntry := &result[index]
+		if err == nil {
+			apiEntry.Metadata = metadata
+			return true
+		}
+		resp := metadataErrorResponse(apiEntry.Path, err)
+		if strict {
+			errResp = resp
+			return false
+		}
+		apiEntry.Error = resp.body
+		return true
+	})
+	if errResp != nil {
+		return errResp
+	}
+	if len(unfetched) == 0 {
+		return nil
+	}
+	if strict {
+		return timeoutResponse(result[unfetched[0]].Path, "could not fetch its metadata in time")
+	}
+	for _, index := range unfetched {
+		result[index].Stale = true
+	}
+	activity.Warnf(ctx, "API: Could not fetch the metadata of %v of the %v listed entries in time", len(unfetched), len(indices))
+	return nil
+}
+
+// fetchListTelemetry fetches the telemetry of each of the listed entries that
+// supports the telemetry action in parallel, and adds it to their
+// corresponding API entry in result. Entries whose telemetry errored or isn't
+// fetched in time are skipped.
+func fetchListTelemetry(ctx context.Context, entries []plugin.Entry, result []apitypes.Entry) {
+	var indices []int
+	for index, entry := range entries {
+		if _, ok := entry.(plugin.TelemetryReporter); ok && plugin.TelemetryAction().IsSupportedOn(entry) {
+			indices = append(indices, index)
+		}
+	}
+	fetch := func(ctx context.Context, entry plugin.Entry) (plugin.JSONObject, error) {
+		return plugin.CachedTelemetry(ctx, entry.(plugin.TelemetryReporter))
+	}
+	fetchForEntries(ctx, entries, indices, fetch, func(index int, telemetry plugin.JSONObject, err error) bool {
+		if err != nil {
+			activity.Warnf(ctx, "API: Could not fetch the telemetry of %v: %v", result[index].Path, err)
+			return true
+		}
+		result[index].Telemetry = telemetry
+		return true
+	})
+}
+
+// streamList streams parent's children as they're listed. The response's
+// header is sent with the first child so that the listing can still fail
+// with the appropriate status until then.
+func streamList(ctx context.Context, w http.ResponseWriter, parent plugin.Parent, path string, strict bool) *errorResponse {
+	fw, ok := w.(flushableWriter)
+	if !ok {
+		return unknownErrorResponse(fmt.Errorf("Cannot stream the listing of %v, response handler does not support flushing", path))
+	}
+	enc := json.NewEncoder(&streamableResponseWriter{fw})
+	started := false
+	streamed := 0
+	_, err := plugin.StreamingList(ctx, parent, func(entry plugin.Entry) error {
+		if err := ctx.Err(); err != nil {
+			// The client hung up, so stop listing
+			return err
+		}
+		apiEntry := toAPIEntry(entry)
+		apiEntry.Path = path + "/" + apiEntry.CName
+		if errorEntry, ok := entry.(*plugin.ErrorEntry); ok {
+			errResp := erroredActionResponse(apiEntry.Path, plugin.ListAction(), errorEntry.Err().Error())
+			if strict {
+				return errResp
+			}
+			apiEntry.Error = errResp.body
+		}
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		streamed++
+		return enc.Encode(apitypes.ListPacket{Entry: &apiEntry})
+	})
+	activity.Record(ctx, "API: Streamed %v of the children of %v", streamed, path)
+	if err == nil {
+		if !started {
+			// There weren't any children
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+		}
+		return nil
+	}
+
+	errResp, ok := err.(*errorResponse)
+	if !ok {
+		if cnameErr, ok := err.(plugin.DuplicateCNameErr); ok {
+			errResp = duplicateCNameResponse(cnameErr)
+		} else {
+			errResp = actionErrorResponse(path, plugin.ListAction(), err)
+		}
+	}
+	if !started {
+		return errResp
+	}
+	if ctx.Err() == nil {
+		if encErr := enc.Encode(apitypes.ListPacket{Err: errResp.body}); encErr != nil {
+			activity.Record(ctx, "API: Could not send the error that streaming the listing of %v failed with: %v", path, encErr)
+		}
+	}
+	return nil
+}
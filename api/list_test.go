@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type listTestsMetadataEntry struct {
+	plugin.EntryBase
+	metadata func(context.Context) (plugin.JSONObject, error)
+}
+
+func newListTestsMetadataEntry(name string, metadata func(context.Context) (plugin.JSONObject, error)) *listTestsMetadataEntry {
+	return &listTestsMetadataEntry{EntryBase: plugin.NewEntry(name), metadata: metadata}
+}
+
+func (e *listTestsMetadataEntry) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+func (e *listTestsMetadataEntry) Metadata(ctx context.Context) (plugin.JSONObject, error) {
+	return e.metadata(ctx)
+}
+
+type ListHandlerTestSuite struct {
+	suite.Suite
+	router *mux.Router
+	ctx    context.Context
+}
+
+func (suite *ListHandlerTestSuite) SetupTest() {
+	plugin.SetTestCache(datastore.NewMemCache())
+	suite.router = mux.NewRouter()
+	suite.router.Handle("/fs/list", listHandler).Methods(http.MethodGet)
+
+	fast := newListTestsMetadataEntry("fast", func(context.Context) (plugin.JSONObject, error) {
+		return plugin.JSONObject{"state": "running"}, nil
+	})
+	failing := newListTestsMetadataEntry("failing", func(context.Context) (plugin.JSONObject, error) {
+		return nil, fmt.Errorf("failed")
+	})
+	slow := newListTestsMetadataEntry("slow", func(ctx context.Context) (plugin.JSONObject, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	root := newGlobTestsDir("docker", fast, failing, slow, plugin.NewErrorEntry("us-west-1", fmt.Errorf("unreachable")))
+	root.SetTestID("/docker")
+	registry := plugin.NewRegistry()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+	suite.ctx = context.WithValue(context.Background(), pluginRegistryKey, registry)
+	suite.ctx = context.WithValue(suite.ctx, mountpointKey, "/mnt")
+
+	_, err := limits.Set(listMetadataTimeout.Name(), 50)
+	suite.NoError(err)
+}
+
+func (suite *ListHandlerTestSuite) TearDownTest() {
+	plugin.UnsetTestCache()
+	_, err := limits.Set(listMetadataTimeout.Name(), 5000)
+	suite.NoError(err)
+}
+
+func (suite *ListHandlerTestSuite) list(params url.Values) *httptest.ResponseRecorder {
+	params.Set("path", "/mnt/docker")
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/fs/list?"+params.Encode(), nil).WithContext(suite.ctx)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *ListHandlerTestSuite) listEntries(params url.Values) map[string]apitypes.Entry {
+	w := suite.list(params)
+	if !suite.Equal(http.StatusOK, w.Code, w.Body.String()) {
+		suite.FailNow("the listing failed")
+	}
+	var entries []apitypes.Entry
+	suite.NoError(json.Unmarshal(w.Body.Bytes(), &entries))
+	result := make(map[string]apitypes.Entry)
+	for _, entry := range entries {
+		result[entry.Name] = entry
+	}
+	return result
+}
+
+func (suite *ListHandlerTestSuite) assertErrorKind(params url.Values, statusCode int, kind string) {
+	w := suite.list(params)
+	suite.Equal(statusCode, w.Code)
+	var errResp apitypes.ErrorObj
+	if suite.NoError(json.Unmarshal(w.Body.Bytes(), &errResp)) {
+		suite.Equal(kind, errResp.Kind)
+	}
+}
+
+func (suite *ListHandlerTestSuite) TestIncludesErrorEntryErrors() {
+	entries := suite.listEntries(url.Values{})
+	suite.Len(entries, 4)
+	if suite.NotNil(entries["us-west-1"].Error) {
+		suite.Equal(apitypes.ErroredAction, entries["us-west-1"].Error.Kind)
+		suite.Regexp("unreachable", entries["us-west-1"].Error.Msg)
+	}
+	suite.Nil(entries["fast"].Error)
+	suite.Nil(entries["fast"].Metadata)
+}
+
+func (suite *ListHandlerTestSuite) TestWithMetadata_ReturnsPartialResults() {
+	entries := suite.listEntries(url.Values{"metadata": []string{"true"}})
+	suite.Len(entries, 4)
+	suite.Equal(plugin.JSONObject{
+		"state":                  "running",
+		plugin.CommonMetadataKey: map[string]interface{}{"name": "fast", "state": "running"},
+	}, entries["fast"].Metadata)
+	suite.False(entries["fast"].Stale)
+	suite.Nil(entries["fast"].Error)
+
+	if suite.NotNil(entries["failing"].Error) {
+		suite.Regexp("failed", entries["failing"].Error.Msg)
+	}
+	suite.True(entries["slow"].Stale)
+	suite.Nil(entries["slow"].Error)
+	suite.NotNil(entries["us-west-1"].Error)
+}
+
+func (suite *ListHandlerTestSuite) TestStrict() {
+	suite.assertErrorKind(url.Values{"strict": []string{"true"}}, http.StatusInternalServerError, apitypes.ErroredAction)
+}
+
+func (suite *ListHandlerTestSuite) TestStrictWithMetadata_TimesOut() {
+	// The error entry would fail the listing before any metadata's fetched,
+	// so use a parent without one
+	root := newGlobTestsDir("docker", newListTestsMetadataEntry("slow", func(ctx context.Context) (plugin.JSONObject, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+	root.SetTestID("/docker")
+	registry := plugin.NewRegistry()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+	suite.ctx = context.WithValue(suite.ctx, pluginRegistryKey, registry)
+
+	suite.assertErrorKind(url.Values{"metadata": []string{"true"}, "strict": []string{"true"}}, http.StatusGatewayTimeout, apitypes.Timeout)
+}
+
+func (suite *ListHandlerTestSuite) TestStream() {
+	w := suite.list(url.Values{"stream": []string{"true"}})
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Equal("application/x-ndjson", w.Header().Get("Content-Type"))
+
+	var names []string
+	decoder := json.NewDecoder(w.Body)
+	for decoder.More() {
+		var pkt apitypes.ListPacket
+		if !suite.NoError(decoder.Decode(&pkt)) || !suite.NotNil(pkt.Entry) {
+			return
+		}
+		suite.Equal("/mnt/docker/"+pkt.Entry.CName, pkt.Entry.Path)
+		if pkt.Entry.Name == "us-west-1" {
+			suite.NotNil(pkt.Entry.Error)
+		}
+		names = append(names, pkt.Entry.Name)
+	}
+	suite.Equal([]string{"fast", "failing", "slow", "us-west-1"}, names)
+}
+
+func (suite *ListHandlerTestSuite) TestStreamStrict() {
+	// The error entry's streamed after the other children, so the listing's
+	// error is the last packet
+	w := suite.list(url.Values{"stream": []string{"true"}, "strict": []string{"true"}})
+	suite.Equal(http.StatusOK, w.Code)
+
+	var last apitypes.ListPacket
+	decoder := json.NewDecoder(w.Body)
+	for decoder.More() {
+		last = apitypes.ListPacket{}
+		suite.NoError(decoder.Decode(&last))
+	}
+	if suite.NotNil(last.Err) {
+		suite.Equal(apitypes.ErroredAction, last.Err.Kind)
+	}
+}
+
+func (suite *ListHandlerTestSuite) TestStreamWithMetadata() {
+	suite.assertErrorKind(url.Values{"stream": []string{"true"}, "metadata": []string{"true"}}, http.StatusBadRequest, apitypes.BadRequest)
+}
+
+func (suite *ListHandlerTestSuite) TestPaged() {
+	// The root's listing isn't paged, so its first page has all of its children
+	w := suite.list(url.Values{"paged": []string{"true"}})
+	if !suite.Equal(http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	suite.Equal("no-cache", w.Header().Get("Cache-Control"))
+	var page apitypes.ListPage
+	if suite.NoError(json.Unmarshal(w.Body.Bytes(), &page)) {
+		suite.Len(page.Entries, 4)
+		suite.Equal("", page.NextPage)
+		suite.Equal("/mnt/docker/failing", page.Entries[0].Path)
+	}
+
+	w = suite.list(url.Values{"page": []string{"p2"}})
+	suite.Equal(http.StatusInternalServerError, w.Code)
+	suite.Regexp("isn't paged", w.Body.String())
+}
+
+func (suite *ListHandlerTestSuite) TestPagedStream() {
+	suite.assertErrorKind(url.Values{"stream": []string{"true"}, "paged": []string{"true"}}, http.StatusBadRequest, apitypes.BadRequest)
+}
+
+func TestListHandler(t *testing.T) {
+	suite.Run(t, new(ListHandlerTestSuite))
+}
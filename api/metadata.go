@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
 	"github.com/puppetlabs/wash/plugin"
 )
 
@@ -40,13 +41,67 @@ var metadataHandler handler = func(w http.ResponseWriter, r *http.Request) *erro
 	metadata, err := plugin.CachedMetadata(ctx, entry)
 
 	if err != nil {
-		return unknownErrorResponse(err)
+		return metadataErrorResponse(path, err)
 	}
 	activity.Record(ctx, "API: Metadata %v %+v", path, metadata)
 
-	jsonEncoder := json.NewEncoder(w)
-	if err = jsonEncoder.Encode(metadata); err != nil {
+	if err = writeCacheableJSON(w, r, metadata, plugin.TTLOf(entry, plugin.MetadataOp)); err != nil {
 		return unknownErrorResponse(fmt.Errorf("Could not marshal metadata for %v: %v", path, err))
 	}
 	return nil
 }
+
+// swagger:response
+//nolint:deadcode,unused
+type metadataHistory struct {
+	// in: body
+	Snapshots []apitypes.MetadataSnapshot
+}
+
+// swagger:route GET /fs/metadata/history metadata getMetadataHistory
+//
+// Get metadata history
+//
+// Get the retained snapshots of the specified entry's metadata, from oldest
+// to newest. The entry's current metadata is fetched first so that it's
+// included. The result is empty if metadata history is disabled via the
+// metadata_history config key.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: metadataHistory
+//       404: errorResp
+//       500: errorResp
+var metadataHistoryHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	ctx := r.Context()
+	entry, path, errResp := getEntryFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+
+	if _, err := plugin.CachedMetadata(ctx, entry); err != nil {
+		if errResp := classifiedErrorResponse(path, err); errResp != nil {
+			return errResp
+		}
+		return unknownErrorResponse(err)
+	}
+	snapshots := plugin.MetadataHistory(entry)
+	activity.Record(ctx, "API: MetadataHistory %v: %v snapshots", path, len(snapshots))
+
+	result := make([]apitypes.MetadataSnapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		result = append(result, apitypes.MetadataSnapshot{
+			Time:         snapshot.Time,
+			LastObserved: snapshot.LastObserved,
+			Metadata:     snapshot.Metadata,
+		})
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the metadata history of %v: %v", path, err))
+	}
+	return nil
+}
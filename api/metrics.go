@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/puppetlabs/wash/metrics"
+)
+
+// swagger:route GET /metrics metrics getMetrics
+//
+// Get the metrics
+//
+// Get a snapshot of the server's recent activity, including its FUSE ops/sec,
+// each plugin's call latency, the cache's hit rate, the active streams and
+// execs, and the most recent errors. Rates are averaged over the last 10
+// seconds.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: Metrics
+//       500: errorResp
+var metricsHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(metrics.Take()); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the metrics: %v", err))
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+func toAPIOperation(o *plugin.Operation) apitypes.Operation {
+	info := o.Info()
+	return apitypes.Operation{
+		ID:       info.ID,
+		Action:   info.Action,
+		Path:     info.Path,
+		Status:   info.Status,
+		Started:  info.Started,
+		Finished: info.Finished,
+		Done:     info.Done,
+		Total:    info.Total,
+		Err:      info.Err,
+	}
+}
+
+// writeOperation writes the operation as the response with the given status
+// code
+func writeOperation(w http.ResponseWriter, statusCode int, o *plugin.Operation) *errorResponse {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(toAPIOperation(o)); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal operation %v: %v", o.ID(), err))
+	}
+	return nil
+}
+
+// swagger:parameters getOperation getOperationResult cancelOperation
+//nolint:deadcode,unused
+type operationParams struct {
+	// the operation's ID
+	//
+	// in: path
+	ID string
+}
+
+// swagger:route GET /operations operations listOperations
+//
+// Get the operations
+//
+// Get a list of the running and recently finished asynchronous operations,
+// sorted by when they started. Finished operations are retained for an
+// hour.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: OperationsResponse
+//       500: errorResp
+var operationsHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	ops := plugin.Operations()
+	result := make([]apitypes.Operation, 0, len(ops))
+	for _, o := range ops {
+		result = append(result, toAPIOperation(o))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the operations: %v", err))
+	}
+	return nil
+}
+
+// swagger:route GET /operations/{id} operations getOperation
+//
+// Get an operation
+//
+// Get the operation's status and progress.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: Operation
+//       404: errorResp
+//       500: errorResp
+var operationHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	id := mux.Vars(r)["id"]
+	o, ok := plugin.FindOperation(id)
+	if !ok {
+		return operationNotFoundResponse(id)
+	}
+	return writeOperation(w, http.StatusOK, o)
+}
+
+// swagger:route DELETE /operations/{id} operations cancelOperation
+//
+// Cancel an operation
+//
+// Cancels the operation if it's running. Returns the operation, which may
+// still be running if it hasn't responded to the cancellation yet.
+// Requests must set the Authorization header to "Bearer <token>", where
+// <token> is the content of the admin token file.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: Operation
+//       401: errorResp
+//       404: errorResp
+//       500: errorResp
+var cancelOperationHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	id := mux.Vars(r)["id"]
+	o, ok := plugin.FindOperation(id)
+	if !ok {
+		return operationNotFoundResponse(id)
+	}
+	activity.Record(r.Context(), "API: Cancel operation %v", id)
+	o.Cancel()
+	return writeOperation(w, http.StatusOK, o)
+}
+
+// swagger:route GET /operations/{id}/result operations getOperationResult
+//
+// Get an operation's result
+//
+// Streams the result of a successful operation, e.g. the content of an
+// asynchronous read.
+//
+//     Produces:
+//     - application/json
+//     - application/octet-stream
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: octetResponse
+//       404: errorResp
+//       409: errorResp
+//       500: errorResp
+var operationResultHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	o, ok := plugin.FindOperation(id)
+	if !ok {
+		return operationNotFoundResponse(id)
+	}
+	info := o.Info()
+	if info.Status != plugin.OperationSucceeded {
+		return operationNotDoneResponse(info)
+	}
+	result, err := o.Result()
+	if err != nil {
+		return unknownErrorResponse(err)
+	}
+	defer func() {
+		if err := result.Close(); err != nil {
+			activity.Warnf(ctx, "API: Failed to close the result of operation %v: %v", id, err)
+		}
+	}()
+
+	activity.Record(ctx, "API: Reading the result of operation %v", id)
+	if _, err := io.Copy(w, result); err != nil {
+		// The response was already started, so all we can do is log the error
+		activity.Warnf(ctx, "API: Reading the result of operation %v failed: %v", id, err)
+	}
+	return nil
+}
+
+// progressWriter reports the number of bytes that were written through it as
+// the operation's progress
+type progressWriter struct {
+	io.Writer
+	op    *plugin.Operation
+	done  int64
+	total int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.done += int64(n)
+	w.op.SetProgress(w.done, w.total)
+	return n, err
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// swagger:route PUT /cache/pins cache pinEntry
+//
+// Pin an entry
+//
+// Pins the specified entry and its children. Their cached results are kept
+// warm by refreshing them every ttl (e.g. 30s), and they're never evicted.
+// Pinning a pinned entry updates its ttl.
+// Requests must set the Authorization header to "Bearer <token>", where
+// <token> is the content of the admin token file.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: Pin
+//       400: errorResp
+//       401: errorResp
+//       404: errorResp
+//       500: errorResp
+var pinHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	// Only Wash entries are cached, so local files can't be pinned
+	if _, errResp := getWashPathFromRequest(r); errResp != nil {
+		return errResp
+	}
+	entry, path, errResp := getEntryFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+
+	ttl := plugin.DefaultPinTTL
+	if ttlStr := r.URL.Query().Get("ttl"); ttlStr != "" {
+		var err error
+		if ttl, err = time.ParseDuration(ttlStr); err != nil {
+			return badRequestResponse(fmt.Sprintf("invalid ttl %v: %v", ttlStr, err))
+		}
+	}
+
+	pin, err := plugin.Pin(plugin.ID(entry), ttl)
+	if err != nil {
+		return badRequestResponse(err.Error())
+	}
+	activity.Record(r.Context(), "API: Pinned %v (refreshed every %v)", path, ttl)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pin); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the pin of %v: %v", path, err))
+	}
+	return nil
+}
+
+// swagger:route DELETE /cache/pins cache unpinEntry
+//
+// Unpin an entry
+//
+// Unpins the specified entry and its children, and removes their cached
+// results so that they're cached like any other entry's.
+// Requests must set the Authorization header to "Bearer <token>", where
+// <token> is the content of the admin token file.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: Pin
+//       401: errorResp
+//       404: errorResp
+//       500: errorResp
+var unpinHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	// The entry may no longer exist, so unpin its path instead
+	path, errResp := getWashPathFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+
+	pin, ok, err := plugin.Unpin(path)
+	if !ok {
+		return pinNotFoundResponse(path)
+	}
+	if err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not clear the cache for %v: %v", path, err))
+	}
+	activity.Record(r.Context(), "API: Unpinned %v", path)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pin); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the pin of %v: %v", path, err))
+	}
+	return nil
+}
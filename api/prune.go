@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/retention"
+)
+
+// swagger:route POST /prune prune pruneStores
+//
+// Prune Wash's on-disk stores
+//
+// Deletes the activity journals and cache snapshots that exceed their
+// retention policy's max age or max size. The request body optionally
+// overrides the configured policies.
+// Requests must set the Authorization header to "Bearer <token>", where
+// <token> is the content of the admin token file.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: PruneResult
+//       400: errorResp
+//       401: errorResp
+//       500: errorResp
+var pruneHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	var override *apitypes.PruneBody
+	if r.Body != nil && r.ContentLength != 0 {
+		var body apitypes.PruneBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return badRequestResponse(err.Error())
+		}
+		if body.MaxAgeDays < 0 || body.MaxSizeMB < 0 {
+			return badRequestResponse("max_age_days and max_size_mb must not be negative")
+		}
+		override = &body
+	}
+
+	results, err := retention.PruneAll(override)
+	if err != nil {
+		return unknownErrorResponse(err)
+	}
+	for _, result := range results {
+		activity.Record(r.Context(), "API: Pruned %v %v (%v bytes)", len(result.Removed), result.Store, result.RemovedBytes)
+	}
+	if results == nil {
+		results = []apitypes.PruneResult{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the prune results: %v", err))
+	}
+	return nil
+}
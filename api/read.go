@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"io"
 	"net/http"
 
@@ -8,11 +9,23 @@ import (
 	"github.com/puppetlabs/wash/plugin"
 )
 
+// swagger:parameters readContent
+//nolint:deadcode,unused
+type readParams struct {
+	// read in the background and return an operation
+	//
+	// in: query
+	Async bool
+}
+
 // swagger:route GET /fs/read read readContent
 //
 // Read content
 //
-// Read content from the specified entry.
+// Read content from the specified entry. If async is true, then the read
+// happens in the background and an operation is returned (with a 202 status)
+// instead. Its result is the content. The response's Content-Type is the
+// entry's content type if it's known.
 //
 //     Produces:
 //     - application/json
@@ -22,6 +35,8 @@ import (
 //
 //     Responses:
 //       200: octetResponse
+//       202: Operation
+//       400: errorResp
 //       404: errorResp
 //       500: errorResp
 var readHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
@@ -32,22 +47,46 @@ var readHandler handler = func(w http.ResponseWriter, r *http.Request) *errorRes
 	}
 
 	if !plugin.ReadAction().IsSupportedOn(entry) {
-		return unsupportedActionResponse(path, plugin.ReadAction())
+		return unsupportedActionResponse(path, entry, plugin.ReadAction())
+	}
+	warnIfDeprecated(ctx, w, entry, path, plugin.ReadAction())
+
+	async, errResp := getBoolParam(r.URL, "async")
+	if errResp != nil {
+		return errResp
+	}
+	if async {
+		o := plugin.StartOperation(ctx, plugin.ReadAction().Name, path, func(ctx context.Context, o *plugin.Operation, w io.Writer) error {
+			content, err := plugin.Open(ctx, entry.(plugin.Readable))
+			if err != nil {
+				return err
+			}
+			pw := &progressWriter{Writer: w, op: o, total: content.Size()}
+			_, err = io.Copy(pw, io.NewSectionReader(content, 0, content.Size()))
+			return err
+		})
+		activity.Record(ctx, "API: Reading %v as operation %v", path, o.ID())
+		return writeOperation(w, http.StatusAccepted, o)
 	}
 
 	content, err := plugin.Open(ctx, entry.(plugin.Readable))
 
 	if err != nil {
-		return erroredActionResponse(path, plugin.ReadAction(), err.Error())
+		return actionErrorResponse(path, plugin.ReadAction(), err)
 	}
 	activity.Record(ctx, "API: Reading %v", path)
 
+	contentType, ok := plugin.ContentTypeOf(entry)
+	if !ok {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
 	n, err := io.Copy(w, io.NewSectionReader(content, 0, content.Size()))
 	if n != content.Size() {
 		activity.Record(ctx, "API: Reading %v incomplete: %v/%v", path, n, content.Size())
 	}
 	if err != nil {
-		return erroredActionResponse(path, plugin.ReadAction(), err.Error())
+		return actionErrorResponse(path, plugin.ReadAction(), err)
 	}
 	return nil
 }
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// maxSymlinkHops bounds the number of symlinks that canonicalPath expands
+// so that symlink loops error instead of hanging
+const maxSymlinkHops = 255
+
+// canonicalPaths caches canonicalPath's results so that requests don't Lstat
+// each of their path's segments. Symlinks outside of Wash rarely change, so a
+// short TTL is enough to pick up the changes.
+var canonicalPaths = datastore.NewMemCache().Limit(1000)
+
+const canonicalPathTTL = 10 * time.Second
+
+// swagger:route GET /fs/resolve resolve resolvePath
+//
+// Resolve a path
+//
+// Normalizes the given path and expands any symlinks in it, including the
+// symlinks that are outside of Wash (e.g. a ~/wash symlink to the mountpoint)
+// and the plugins' symlink entries (e.g. a "latest" image tag). Returns an
+// Entry object describing the resolved entry, including its supported
+// actions. The entry's path is the canonical path.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: Entry
+//       400: errorResp
+//       404: errorResp
+//       500: errorResp
+var resolveHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	ctx := r.Context()
+	path, errResp := getPathFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+	path, err := canonicalWashPath(ctx, ctx.Value(mountpointKey).(string), path)
+	if err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not resolve %v: %v", r.URL.Query().Get("path"), err))
+	}
+
+	entry, path, errResp := getEntryFromPath(ctx, path)
+	if errResp != nil {
+		return errResp
+	}
+
+	apiEntry := toAPIEntry(entry)
+	apiEntry.Path = path
+	if err := json.NewEncoder(w).Encode(&apiEntry); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal %v: %v", path, err))
+	}
+	return nil
+}
+
+// canonicalPath cleans the given absolute path and expands the symlinks in
+// it. Expansion stops once the path's within the mountpoint because the
+// remaining segments are Wash entries, and Lstat'ing those would go through
+// FUSE. Segments that don't exist are kept as-is so that callers can report
+// the missing entry.
+func canonicalPath(mountpoint string, path string) (string, error) {
+	// The mountpoint itself may be reached via a symlink in its parent
+	// directory (e.g. /tmp on macOS)
+	realMountpoint := mountpoint
+	if parent, err := filepath.EvalSymlinks(filepath.Dir(mountpoint)); err == nil {
+		realMountpoint = filepath.Join(parent, filepath.Base(mountpoint))
+	}
+
+	hops := 0
+	resolved := "/"
+	segments := splitPath(path)
+	for i := 0; i < len(segments); i++ {
+		next := filepath.Join(resolved, segments[i])
+		for _, mp := range []string{mountpoint, realMountpoint} {
+			if isWithin(next, mp) {
+				rest := append([]string{mountpoint, strings.TrimPrefix(next, mp)}, segments[i+1:]...)
+				return filepath.Join(rest...), nil
+			}
+		}
+
+		info, err := os.Lstat(next)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.Join(append([]string{next}, segments[i+1:]...)...), nil
+			}
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", fmt.Errorf("too many levels of symbolic links")
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(resolved, target)
+		}
+		// Resolve the rest of the path relative to the symlink's target
+		segments = append(splitPath(target), segments[i+1:]...)
+		resolved = "/"
+		i = -1
+	}
+	return resolved, nil
+}
+
+// cachedCanonicalPath is a cached canonicalPath
+func cachedCanonicalPath(mountpoint string, path string) (string, error) {
+	resolved, err := canonicalPaths.GetOrUpdate(mountpoint, path, canonicalPathTTL, false, func() (interface{}, error) {
+		return canonicalPath(mountpoint, path)
+	})
+	if err != nil {
+		return "", err
+	}
+	return resolved.(string), nil
+}
+
+// canonicalWashPath is canonicalPath, except that it also expands the symlink
+// entries within the mountpoint. Each symlink's target is resolved like a
+// real symlink's target, so it may lead outside of the mountpoint. Segments
+// that can't be found are kept as-is so that callers can report the error.
+func canonicalWashPath(ctx context.Context, mountpoint string, path string) (string, error) {
+	registry := ctx.Value(pluginRegistryKey).(*plugin.Registry)
+	hops := 0
+	for {
+		canonical, err := cachedCanonicalPath(mountpoint, path)
+		if err != nil || !isWithin(canonical, mountpoint) {
+			return canonical, err
+		}
+
+		var parent plugin.Entry = registry
+		resolved := mountpoint
+		segments := splitPath(strings.TrimPrefix(canonical, mountpoint))
+		target := ""
+		for i, segment := range segments {
+			var entry plugin.Entry
+			if parent == plugin.Entry(registry) {
+				entry = registry.Plugins()[segment]
+			} else if found, err := plugin.FindEntry(ctx, parent, []string{segment}); err == nil {
+				entry = found
+			}
+			if entry == nil {
+				return filepath.Join(append([]string{resolved}, segments[i:]...)...), nil
+			}
+
+			linkTarget, ok := plugin.SymlinkTargetOf(entry)
+			if !ok {
+				resolved = filepath.Join(resolved, segment)
+				parent = entry
+				continue
+			}
+			if !filepath.IsAbs(linkTarget) {
+				linkTarget = filepath.Join(resolved, linkTarget)
+			}
+			// Resolve the rest of the path relative to the symlink's target
+			target = filepath.Join(append([]string{linkTarget}, segments[i+1:]...)...)
+			break
+		}
+		if target == "" {
+			return resolved, nil
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", fmt.Errorf("too many levels of symbolic links")
+		}
+		path = target
+	}
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(filepath.Clean(path), "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// isWithin returns true if path is dir or one of its descendants
+func isWithin(path string, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
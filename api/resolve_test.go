@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type resolveTestsSymlink struct {
+	plugin.EntryBase
+	target string
+}
+
+func (s *resolveTestsSymlink) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+func (s *resolveTestsSymlink) SymlinkTarget() string {
+	return s.target
+}
+
+type ResolveTestSuite struct {
+	suite.Suite
+	dir        string
+	mountpoint string
+}
+
+func (suite *ResolveTestSuite) SetupTest() {
+	dir, err := filepath.EvalSymlinks(suite.T().TempDir())
+	suite.NoError(err)
+	suite.dir = dir
+	suite.mountpoint = filepath.Join(dir, "mnt")
+	suite.NoError(os.Mkdir(suite.mountpoint, 0750))
+	suite.NoError(os.Mkdir(filepath.Join(dir, "local"), 0750))
+}
+
+func (suite *ResolveTestSuite) symlink(target string, name string) string {
+	path := filepath.Join(suite.dir, name)
+	suite.NoError(os.Symlink(target, path))
+	return path
+}
+
+func (suite *ResolveTestSuite) TestCleansPaths() {
+	path, err := canonicalPath(suite.mountpoint, suite.mountpoint+"//docker/./containers/../containers/")
+	if suite.NoError(err) {
+		suite.Equal(suite.mountpoint+"/docker/containers", path)
+	}
+}
+
+func (suite *ResolveTestSuite) TestExpandsSymlinksToTheMountpoint() {
+	link := suite.symlink(suite.mountpoint, "wash")
+	path, err := canonicalPath(suite.mountpoint, link+"/docker/containers")
+	if suite.NoError(err) {
+		suite.Equal(suite.mountpoint+"/docker/containers", path)
+	}
+
+	// Relative symlinks are resolved relative to their directory
+	link = suite.symlink("mnt/docker", "docker")
+	path, err = canonicalPath(suite.mountpoint, link+"/containers")
+	if suite.NoError(err) {
+		suite.Equal(suite.mountpoint+"/docker/containers", path)
+	}
+}
+
+func (suite *ResolveTestSuite) TestExpandsLocalSymlinks() {
+	link := suite.symlink(filepath.Join(suite.dir, "local"), "link")
+	path, err := canonicalPath(suite.mountpoint, link+"/file")
+	if suite.NoError(err) {
+		suite.Equal(suite.dir+"/local/file", path)
+	}
+}
+
+func (suite *ResolveTestSuite) TestErrorsOnSymlinkLoops() {
+	suite.symlink(filepath.Join(suite.dir, "b"), "a")
+	link := suite.symlink(filepath.Join(suite.dir, "a"), "b")
+	_, err := canonicalPath(suite.mountpoint, link)
+	suite.Error(err)
+}
+
+// washContext returns a context whose registry has a "vms" plugin with the
+// given children
+func (suite *ResolveTestSuite) washContext(children ...plugin.Entry) context.Context {
+	plugin.SetTestCache(datastore.NewMemCache())
+	suite.T().Cleanup(plugin.UnsetTestCache)
+	root := newGlobTestsDir("vms", children...)
+	root.SetTestID("/vms")
+	registry := plugin.NewRegistry()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+	return context.WithValue(context.Background(), pluginRegistryKey, registry)
+}
+
+func (suite *ResolveTestSuite) TestExpandsSymlinkEntries() {
+	ctx := suite.washContext(
+		newGlobTestsDir("v1", newGlobTestsDir("disk")),
+		&resolveTestsSymlink{EntryBase: plugin.NewEntry("latest"), target: "v1"},
+		&resolveTestsSymlink{EntryBase: plugin.NewEntry("abs"), target: suite.mountpoint + "/vms/latest"},
+		&resolveTestsSymlink{EntryBase: plugin.NewEntry("local"), target: filepath.Join(suite.dir, "local")},
+	)
+	for _, link := range []string{"latest", "abs"} {
+		path, err := canonicalWashPath(ctx, suite.mountpoint, suite.mountpoint+"/vms/"+link+"/disk")
+		if suite.NoError(err) {
+			suite.Equal(suite.mountpoint+"/vms/v1/disk", path)
+		}
+	}
+
+	// Targets outside of the mountpoint are expanded like local paths
+	path, err := canonicalWashPath(ctx, suite.mountpoint, suite.mountpoint+"/vms/local/file")
+	if suite.NoError(err) {
+		suite.Equal(suite.dir+"/local/file", path)
+	}
+
+	// Missing entries are kept as-is
+	path, err = canonicalWashPath(ctx, suite.mountpoint, suite.mountpoint+"/vms/latest/missing/file")
+	if suite.NoError(err) {
+		suite.Equal(suite.mountpoint+"/vms/v1/missing/file", path)
+	}
+}
+
+func (suite *ResolveTestSuite) TestErrorsOnSymlinkEntryLoops() {
+	ctx := suite.washContext(
+		&resolveTestsSymlink{EntryBase: plugin.NewEntry("a"), target: "b"},
+		&resolveTestsSymlink{EntryBase: plugin.NewEntry("b"), target: "a"},
+	)
+	_, err := canonicalWashPath(ctx, suite.mountpoint, suite.mountpoint+"/vms/a")
+	suite.Error(err)
+}
+
+func (suite *ResolveTestSuite) TestIsWithin() {
+	suite.True(isWithin("/mnt", "/mnt"))
+	suite.True(isWithin("/mnt/docker", "/mnt"))
+	suite.True(isWithin("/mnt", "/"))
+	suite.False(isWithin("/mntfoo", "/mnt"))
+	suite.False(isWithin("/", "/mnt"))
+}
+
+func TestResolve(t *testing.T) {
+	suite.Run(t, new(ResolveTestSuite))
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// swagger:parameters runEntry
+//nolint:deadcode,unused
+type runBody struct {
+	// in: body
+	Body apitypes.RunBody
+	// run in the background and return an operation
+	//
+	// in: query
+	Async bool
+}
+
+// swagger:response
+//nolint:deadcode,unused
+type runResult struct {
+	// in: body
+	Body apitypes.RunResult
+}
+
+// swagger:route POST /fs/run run runEntry
+//
+// Performs one of an entry's custom actions
+//
+// Performs the specified custom action (e.g. snapshot or reboot), which must
+// be one of the entry's custom_actions, and returns its output. The entry's
+// parent's cached results are cleared so that the entry's new state is listed.
+// Like exec, custom actions are governed by the exec policy, which opts
+// satisfies.
+// If async is true, then the action runs in the background and an operation is
+// returned (with a 202 status) instead. Its result is the runResult.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: runResult
+//       202: Operation
+//       400: errorResp
+//       403: errorResp
+//       404: errorResp
+//       428: errorResp
+//       500: errorResp
+var runHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	ctx := r.Context()
+	entry, path, errResp := getEntryFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+
+	if !plugin.RunAction().IsSupportedOn(entry) {
+		return unsupportedActionResponse(path, entry, plugin.RunAction())
+	}
+	warnIfDeprecated(ctx, w, entry, path, plugin.RunAction())
+
+	if r.Body == nil {
+		return badActionRequestResponse(path, plugin.RunAction(), "Please send a JSON request body")
+	}
+	var body apitypes.RunBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return badActionRequestResponse(path, plugin.RunAction(), err.Error())
+	}
+	if !hasCustomAction(entry, body.Action) {
+		return badActionRequestResponse(
+			path,
+			plugin.RunAction(),
+			fmt.Sprintf("%q is not one of the entry's custom actions %v", body.Action, plugin.CustomActionsOf(entry)),
+		)
+	}
+
+	async, errResp := getBoolParam(r.URL, "async")
+	if errResp != nil {
+		return errResp
+	}
+
+	policyReq := plugin.ExecPolicyRequest{
+		Path:          path,
+		Cmd:           body.Action,
+		Args:          body.Args,
+		CustomAction:  true,
+		Justification: body.Opts.Justification,
+	}
+	if err := plugin.CheckExecPolicy(ctx, policyReq, body.Opts.Consented); err != nil {
+		return execPolicyResponse(path, err)
+	}
+	if async {
+		o := plugin.StartOperation(ctx, plugin.RunAction().Name, path, func(ctx context.Context, o *plugin.Operation, w io.Writer) error {
+			output, err := plugin.Run(ctx, entry.(plugin.Runnable), body.Action, body.Args)
+			if err != nil {
+				return err
+			}
+			return json.NewEncoder(w).Encode(apitypes.RunResult{Output: string(output)})
+		})
+		activity.Record(ctx, "API: Running %v on %v with %v as operation %v", body.Action, path, body.Args, o.ID())
+		return writeOperation(w, http.StatusAccepted, o)
+	}
+
+	output, err := plugin.Run(ctx, entry.(plugin.Runnable), body.Action, body.Args)
+	if err != nil {
+		return actionErrorResponse(path, plugin.RunAction(), err)
+	}
+	activity.Record(ctx, "API: Ran %v on %v with %v", body.Action, path, body.Args)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(apitypes.RunResult{Output: string(output)}); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the output of %v on %v: %v", body.Action, path, err))
+	}
+	return nil
+}
+
+func hasCustomAction(entry plugin.Entry, action string) bool {
+	for _, a := range plugin.CustomActionsOf(entry) {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
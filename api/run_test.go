@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type runTestsEntry struct {
+	plugin.EntryBase
+	runs []string
+}
+
+func (e *runTestsEntry) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+func (e *runTestsEntry) CustomActions() []string {
+	return []string{"snapshot", "fail"}
+}
+
+func (e *runTestsEntry) Run(ctx context.Context, action string, args []string) ([]byte, error) {
+	if action == "fail" {
+		return nil, fmt.Errorf("the snapshot quota's exhausted")
+	}
+	e.runs = append(e.runs, action+" "+strings.Join(args, " "))
+	return []byte("created " + strings.Join(args, " ")), nil
+}
+
+type RunHandlerTestSuite struct {
+	suite.Suite
+	router *mux.Router
+	ctx    context.Context
+	vm     *runTestsEntry
+}
+
+func (suite *RunHandlerTestSuite) SetupTest() {
+	plugin.SetTestCache(datastore.NewMemCache())
+	suite.router = mux.NewRouter()
+	suite.router.Handle("/fs/run", runHandler).Methods(http.MethodPost)
+
+	suite.vm = &runTestsEntry{EntryBase: plugin.NewEntry("vm")}
+	root := newGlobTestsDir("vms", suite.vm, newGlobTestsDir("dir"))
+	root.SetTestID("/vms")
+	registry := plugin.NewRegistry()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+	suite.ctx = context.WithValue(context.Background(), pluginRegistryKey, registry)
+	suite.ctx = context.WithValue(suite.ctx, mountpointKey, "/mnt")
+}
+
+func (suite *RunHandlerTestSuite) TearDownTest() {
+	plugin.UnsetTestCache()
+}
+
+func (suite *RunHandlerTestSuite) run(path string, body apitypes.RunBody) *httptest.ResponseRecorder {
+	return suite.runWith(url.Values{"path": []string{path}}, body)
+}
+
+func (suite *RunHandlerTestSuite) runWith(params url.Values, body apitypes.RunBody) *httptest.ResponseRecorder {
+	jsonBody, err := json.Marshal(body)
+	suite.NoError(err)
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/fs/run?"+params.Encode(), strings.NewReader(string(jsonBody))).WithContext(suite.ctx)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *RunHandlerTestSuite) errorKind(w *httptest.ResponseRecorder) string {
+	var errObj apitypes.ErrorObj
+	suite.NoError(json.Unmarshal(w.Body.Bytes(), &errObj))
+	return errObj.Kind
+}
+
+func (suite *RunHandlerTestSuite) TestRunsTheCustomAction() {
+	w := suite.run("/mnt/vms/vm", apitypes.RunBody{Action: "snapshot", Args: []string{"nightly"}})
+	if suite.Equal(http.StatusOK, w.Code, w.Body.String()) {
+		var result apitypes.RunResult
+		suite.NoError(json.Unmarshal(w.Body.Bytes(), &result))
+		suite.Equal("created nightly", result.Output)
+	}
+	suite.Equal([]string{"snapshot nightly"}, suite.vm.runs)
+}
+
+func (suite *RunHandlerTestSuite) TestRejectsUnknownActions() {
+	w := suite.run("/mnt/vms/vm", apitypes.RunBody{Action: "reboot"})
+	suite.Equal(http.StatusBadRequest, w.Code)
+	suite.Equal(apitypes.BadActionRequest, suite.errorKind(w))
+	suite.Empty(suite.vm.runs)
+}
+
+func (suite *RunHandlerTestSuite) TestRejectsEntriesWithoutCustomActions() {
+	w := suite.run("/mnt/vms/dir", apitypes.RunBody{Action: "snapshot"})
+	suite.Equal(http.StatusNotFound, w.Code)
+	suite.Equal(apitypes.UnsupportedAction, suite.errorKind(w))
+}
+
+func (suite *RunHandlerTestSuite) TestReportsTheActionsError() {
+	w := suite.run("/mnt/vms/vm", apitypes.RunBody{Action: "fail"})
+	suite.Equal(http.StatusInternalServerError, w.Code)
+	suite.Equal(apitypes.ErroredAction, suite.errorKind(w))
+	suite.Contains(w.Body.String(), "the snapshot quota's exhausted")
+}
+
+func (suite *RunHandlerTestSuite) TestRunsTheCustomActionAsynchronously() {
+	params := url.Values{"path": []string{"/mnt/vms/vm"}, "async": []string{"true"}}
+	w := suite.runWith(params, apitypes.RunBody{Action: "snapshot", Args: []string{"nightly"}})
+	if !suite.Equal(http.StatusAccepted, w.Code, w.Body.String()) {
+		return
+	}
+	var op apitypes.Operation
+	suite.NoError(json.Unmarshal(w.Body.Bytes(), &op))
+	suite.Equal("run", op.Action)
+
+	o, ok := plugin.FindOperation(op.ID)
+	if !suite.True(ok) {
+		return
+	}
+	for i := 0; i < 100 && o.Info().Status == plugin.OperationRunning; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	result, err := o.Result()
+	if suite.NoError(err) {
+		defer result.Close()
+		var runResult apitypes.RunResult
+		suite.NoError(json.NewDecoder(result).Decode(&runResult))
+		suite.Equal("created nightly", runResult.Output)
+	}
+	suite.Equal([]string{"snapshot nightly"}, suite.vm.runs)
+}
+
+func (suite *RunHandlerTestSuite) TestEnforcesTheExecPolicy() {
+	suite.NoError(plugin.SetExecPolicy(plugin.ExecPolicy{Banner: "Sessions are recorded"}))
+	defer func() {
+		suite.NoError(plugin.SetExecPolicy(plugin.ExecPolicy{}))
+	}()
+
+	w := suite.run("/mnt/vms/vm", apitypes.RunBody{Action: "snapshot", Args: []string{"nightly"}})
+	suite.Equal(http.StatusPreconditionRequired, w.Code)
+	suite.Equal(apitypes.ExecConsentRequired, suite.errorKind(w))
+	suite.Empty(suite.vm.runs)
+
+	body := apitypes.RunBody{Action: "snapshot", Args: []string{"nightly"}, Opts: apitypes.RunOptions{Consented: true}}
+	w = suite.run("/mnt/vms/vm", body)
+	suite.Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Equal([]string{"snapshot nightly"}, suite.vm.runs)
+}
+
+func TestRunHandler(t *testing.T) {
+	suite.Run(t, new(RunHandlerTestSuite))
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/puppetlabs/wash/activity"
 	"github.com/puppetlabs/wash/analytics"
 	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/metrics"
 	"github.com/puppetlabs/wash/plugin"
 
 	log "github.com/sirupsen/logrus"
@@ -25,7 +26,7 @@ const (
 	mountpointKey
 )
 
-// swagger:parameters cacheDelete listEntries entryInfo executeCommand getMetadata readContent streamUpdates
+// swagger:parameters cacheDelete listEntries entryInfo entryCapabilities executeCommand getMetadata readContent streamUpdates getArchive resolvePath
 //nolint:deadcode,unused
 type params struct {
 	// uniquely identifies an entry
@@ -48,6 +49,9 @@ func (handle handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if err := handle(w, r); err != nil {
 		activity.Record(r.Context(), "API: %v %v: %v", r.Method, r.URL, err)
+		if err.statusCode >= http.StatusInternalServerError {
+			metrics.RecordError(fmt.Sprintf("API: %v %v: %v", r.Method, r.URL, err))
+		}
 		w.WriteHeader(err.statusCode)
 
 		// NOTE: Do not set these headers in the middleware because not
@@ -76,6 +80,7 @@ func StartAPI(
 	mountpoint string,
 	socketPath string,
 	analyticsClient analytics.Client,
+	devMode bool,
 ) (chan<- context.Context, <-chan struct{}, error) {
 	log.Infof("API: Listening at %s", socketPath)
 
@@ -92,6 +97,12 @@ func StartAPI(
 		}
 	}
 
+	tokenPath := apitypes.AdminTokenPath(socketPath)
+	adminToken, err := newAdminToken(tokenPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create the admin token: %v", err)
+	}
+
 	server, err := net.Listen("unix", socketPath)
 	if err != nil {
 		return nil, nil, err
@@ -107,6 +118,8 @@ func StartAPI(
 			)
 			newctx = context.WithValue(newctx, activity.JournalKey, journal)
 			newctx = context.WithValue(newctx, analytics.ClientKey, analyticsClient)
+			newctx, untrack := inFlight.track(newctx, journal.ID)
+			defer untrack()
 
 			// Call the next handler, which can be another middleware in the chain, or the final handler.
 			next.ServeHTTP(w, r.WithContext(newctx))
@@ -117,15 +130,47 @@ func StartAPI(
 
 	r.Handle("/analytics/screenview", screenviewHandler).Methods(http.MethodPost)
 	r.Handle("/fs/info", infoHandler).Methods(http.MethodGet)
+	r.Handle("/fs/info/capabilities", capabilitiesHandler).Methods(http.MethodGet)
+	r.Handle("/fs/resolve", resolveHandler).Methods(http.MethodGet)
+	r.Handle("/fs/glob", globHandler).Methods(http.MethodGet)
 	r.Handle("/fs/list", listHandler).Methods(http.MethodGet)
 	r.Handle("/fs/metadata", metadataHandler).Methods(http.MethodGet)
+	r.Handle("/fs/metadata/history", metadataHistoryHandler).Methods(http.MethodGet)
+	r.Handle("/fs/telemetry", telemetryHandler).Methods(http.MethodGet)
 	r.Handle("/fs/read", readHandler).Methods(http.MethodGet)
+	r.Handle("/fs/archive", archiveHandler).Methods(http.MethodGet)
 	r.Handle("/fs/stream", streamHandler).Methods(http.MethodGet)
 	r.Handle("/fs/exec", execHandler).Methods(http.MethodPost)
+	r.Handle("/fs/delete", deleteHandler).Methods(http.MethodDelete)
+	r.Handle("/fs/signal", signalHandler).Methods(http.MethodPost)
+	r.Handle("/fs/run", runHandler).Methods(http.MethodPost)
 	r.Handle("/fs/schema", schemaHandler).Methods(http.MethodGet)
-	r.Handle("/cache", cacheHandler).Methods(http.MethodDelete)
+	r.Handle("/fs/whereami", whereamiHandler).Methods(http.MethodGet)
+	r.Handle("/fs/translate", translateHandler).Methods(http.MethodGet)
+	r.Handle("/cache", requireAdmin(adminToken, cacheHandler)).Methods(http.MethodDelete)
+	r.Handle("/cache/pins", requireAdmin(adminToken, pinHandler)).Methods(http.MethodPut)
+	r.Handle("/cache/pins", requireAdmin(adminToken, unpinHandler)).Methods(http.MethodDelete)
+	r.Handle("/snapshots", requireAdmin(adminToken, snapshotHandler)).Methods(http.MethodPost)
+	r.Handle("/prune", requireAdmin(adminToken, pruneHandler)).Methods(http.MethodPost)
 	r.Handle("/history", historyHandler).Methods(http.MethodGet)
 	r.Handle("/history/{index:[0-9]+}", historyEntryHandler).Methods(http.MethodGet)
+	r.Handle("/history/{index:[0-9]+}/exec", historyExecHandler).Methods(http.MethodGet)
+	r.Handle("/cancel", requireAdmin(adminToken, cancelHandler)).Methods(http.MethodPost)
+	r.Handle("/operations", operationsHandler).Methods(http.MethodGet)
+	r.Handle("/operations/{id:[0-9]+}", operationHandler).Methods(http.MethodGet)
+	r.Handle("/operations/{id:[0-9]+}", requireAdmin(adminToken, cancelOperationHandler)).Methods(http.MethodDelete)
+	r.Handle("/operations/{id:[0-9]+}/result", operationResultHandler).Methods(http.MethodGet)
+	r.Handle("/faults", faultsHandler).Methods(http.MethodGet)
+	r.Handle("/faults", requireAdmin(adminToken, requireDevMode(devMode, setFaultsHandler))).Methods(http.MethodPut)
+	r.Handle("/plugins/{name}/help", pluginHelpHandler).Methods(http.MethodGet)
+	r.Handle("/limits", limitsHandler).Methods(http.MethodGet)
+	r.Handle("/limits/{name}", requireAdmin(adminToken, limitHandler)).Methods(http.MethodPut)
+	r.Handle("/features", featuresHandler).Methods(http.MethodGet)
+	r.Handle("/features/{name}", requireAdmin(adminToken, featureHandler)).Methods(http.MethodPut)
+	r.Handle("/credentials", requireAdmin(adminToken, credentialsHandler)).Methods(http.MethodGet)
+	r.Handle("/metrics", metricsHandler).Methods(http.MethodGet)
+	r.Handle("/admin/credentials/{name:.+}", requireCredentialAccess(adminToken, credentialHandler)).Methods(http.MethodGet)
+	r.PathPrefix("/admin/debug/pprof/").Handler(requireAdmin(adminToken, pprofHandler())).Methods(http.MethodGet)
 
 	r.Use(prepareContextMiddleWare)
 
@@ -155,6 +200,9 @@ func StartAPI(
 		}
 
 		<-serverStoppedCh
+		if err := os.Remove(tokenPath); err != nil && !os.IsNotExist(err) {
+			log.Warnf("API: Failed to remove the admin token: %v", err)
+		}
 	}()
 
 	return stopCh, serverStoppedCh, nil
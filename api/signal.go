@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// swagger:parameters signalEntry
+//nolint:deadcode,unused
+type signalBody struct {
+	// in: body
+	Body apitypes.SignalBody
+}
+
+// swagger:route POST /fs/signal signal signalEntry
+//
+// Sends a signal to an entry
+//
+// Sends the specified signal (e.g. start, stop, restart or kill) to the
+// entry. The entry's parent's cached results are cleared so that the entry's
+// new state is listed.
+//
+//     Consumes:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       204:
+//       400: errorResp
+//       404: errorResp
+//       500: errorResp
+var signalHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	ctx := r.Context()
+	entry, path, errResp := getEntryFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+
+	if !plugin.SignalAction().IsSupportedOn(entry) {
+		return unsupportedActionResponse(path, entry, plugin.SignalAction())
+	}
+	warnIfDeprecated(ctx, w, entry, path, plugin.SignalAction())
+
+	if r.Body == nil {
+		return badActionRequestResponse(path, plugin.SignalAction(), "Please send a JSON request body")
+	}
+	var body apitypes.SignalBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return badActionRequestResponse(path, plugin.SignalAction(), err.Error())
+	}
+	if body.Signal == "" {
+		return badActionRequestResponse(path, plugin.SignalAction(), "Please specify the signal to send")
+	}
+
+	if err := plugin.Signal(ctx, entry.(plugin.Signalable), body.Signal); err != nil {
+		return actionErrorResponse(path, plugin.SignalAction(), err)
+	}
+	activity.Record(ctx, "API: Sent %v to %v", body.Signal, path)
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
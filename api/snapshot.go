@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/puppetlabs/wash/plugin/snapshot"
+)
+
+// swagger:route POST /snapshots snapshots createSnapshot
+//
+// Snapshot the cache
+//
+// Captures the cached parts of the plugin tree (listings, content and
+// metadata) and saves them so that they can be mounted later via
+// `wash mount --snapshot`. Capturing a snapshot doesn't invoke any plugins.
+// Requests must set the Authorization header to "Bearer <token>", where
+// <token> is the content of the admin token file.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: Snapshot
+//       401: errorResp
+//       500: errorResp
+var snapshotHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	registry := r.Context().Value(pluginRegistryKey).(*plugin.Registry)
+	s := plugin.CaptureSnapshot(registry, snapshot.NewID(time.Now()))
+	path, err := snapshot.Save(s)
+	if err != nil {
+		return unknownErrorResponse(err)
+	}
+	activity.Record(r.Context(), "API: Saved snapshot %v with %v entries to %v", s.ID, len(s.Entries), path)
+
+	result := apitypes.Snapshot{ID: s.ID, Time: s.Time, Entries: len(s.Entries), Path: path}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal snapshot %v: %v", s.ID, err))
+	}
+	return nil
+}
@@ -1,11 +1,16 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/metrics"
 	"github.com/puppetlabs/wash/plugin"
 )
 
@@ -13,7 +18,16 @@ import (
 //
 // Stream updates
 //
-// Get a stream of new updates to the specified entry.
+// Get a stream of new updates to the specified entry. Once the stream ends,
+// the Wash-Stream-End trailer describes why it ended (e.g. that the
+// container exited, along with its exit code).
+//
+// Entries whose stream is resumable can start the stream at a position in its
+// history instead: either at the byte offset given by offset, or with the
+// output since the RFC3339 timestamp given by since. Resumed streams report
+// the offset of their output in the Wash-Stream-Offset header if it's known,
+// as do shared streams, so that clients that lose their connection can
+// resume them where they left off.
 //
 //     Produces:
 //     - application/json
@@ -23,6 +37,7 @@ import (
 //
 //     Responses:
 //       200: octetResponse
+//       400: errorResp
 //       404: errorResp
 //       500: errorResp
 var streamHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
@@ -32,21 +47,49 @@ var streamHandler handler = func(w http.ResponseWriter, r *http.Request) *errorR
 	}
 
 	if !plugin.StreamAction().IsSupportedOn(entry) {
-		return unsupportedActionResponse(path, plugin.StreamAction())
+		return unsupportedActionResponse(path, entry, plugin.StreamAction())
 	}
+	warnIfDeprecated(r.Context(), w, entry, path, plugin.StreamAction())
 
 	f, ok := w.(flushableWriter)
 	if !ok {
 		return unknownErrorResponse(fmt.Errorf("Cannot stream %v, response handler does not support flushing", path))
 	}
 
+	pos, resume, errResp := getStreamPosition(r)
+	if errResp != nil {
+		return errResp
+	}
+
 	ctx := r.Context()
-	rdr, err := plugin.Stream(ctx, entry.(plugin.Streamable))
+	var rdr io.ReadCloser
+	var err error
+	if resume {
+		rs, ok := entry.(plugin.ResumableStreamable)
+		if !ok || !rs.SupportsResumableStream() {
+			return badActionRequestResponse(path, plugin.StreamAction(), "its stream can't be resumed")
+		}
+		rdr, err = plugin.StreamFrom(ctx, rs, pos)
+	} else {
+		rdr, err = plugin.Stream(ctx, entry.(plugin.Streamable))
+	}
 
 	if err != nil {
-		return erroredActionResponse(path, plugin.StreamAction(), err.Error())
+		return actionErrorResponse(path, plugin.StreamAction(), err)
+	}
+	if resume {
+		activity.Record(ctx, "API: Streaming %v from the %v", path, pos)
+	} else {
+		activity.Record(ctx, "API: Streaming %v", path)
+	}
+	defer metrics.StartStream()()
+
+	// Announce the trailer before the header's sent so that it can be set once
+	// the stream ends.
+	w.Header().Set("Trailer", apitypes.StreamEndTrailer)
+	if offset, ok := plugin.StreamOffsetOf(rdr); ok {
+		w.Header().Set(apitypes.StreamOffsetHeader, strconv.FormatInt(offset, 10))
 	}
-	activity.Record(ctx, "API: Streaming %v", path)
 
 	// Do an initial flush to send the header.
 	w.WriteHeader(http.StatusOK)
@@ -56,9 +99,47 @@ var streamHandler handler = func(w http.ResponseWriter, r *http.Request) *errorR
 	streamCleanup(ctx, "Stream "+path, rdr.Close)
 
 	// Ensure every write is a flush with streamableResponseWriter.
-	if _, err := io.Copy(&streamableResponseWriter{f}, rdr); err != nil {
-		// Common for copy to error when the caller closes the connection.
-		activity.Record(ctx, "API: Streaming %v errored: %v", path, err)
+	_, err = io.Copy(&streamableResponseWriter{f}, rdr)
+	if ctx.Err() != nil {
+		// The caller closed the connection, so there's no one to tell why the
+		// stream ended.
+		activity.Record(ctx, "API: Streaming %v was cancelled: %v", path, ctx.Err())
+		return nil
+	}
+	if err == nil {
+		// io.Copy doesn't return io.EOF
+		err = io.EOF
+	}
+	end := plugin.StreamEndOf(rdr, err)
+	activity.Record(ctx, "API: Streaming %v ended: %v", path, end)
+	if endJSON, err := json.Marshal(end); err == nil {
+		w.Header().Set(apitypes.StreamEndTrailer, string(endJSON))
 	}
 	return nil
 }
+
+// getStreamPosition returns the position that the request's stream should be
+// resumed at. The returned bool is false if the stream shouldn't be resumed.
+func getStreamPosition(r *http.Request) (plugin.StreamPosition, bool, *errorResponse) {
+	var pos plugin.StreamPosition
+	since, offset := r.URL.Query().Get("since"), r.URL.Query().Get("offset")
+	switch {
+	case since != "" && offset != "":
+		return pos, false, badRequestResponse("since and offset can't both be set")
+	case since != "":
+		var err error
+		if pos.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return pos, false, badRequestResponse(fmt.Sprintf("The since parameter must be an RFC3339 timestamp, not %v", since))
+		}
+		return pos, true, nil
+	case offset != "":
+		var err error
+		if pos.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil || pos.Offset < 0 {
+			return pos, false, badRequestResponse(fmt.Sprintf("The offset parameter must be a non-negative integer, not %v", offset))
+		}
+		pos.HasOffset = true
+		return pos, true, nil
+	default:
+		return pos, false, nil
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type StreamTestSuite struct {
+	suite.Suite
+}
+
+func (suite *StreamTestSuite) TestGetStreamPosition() {
+	pos, ok, errResp := getStreamPosition(httptest.NewRequest("GET", "/fs/stream/foo", nil))
+	suite.Nil(errResp)
+	suite.False(ok)
+
+	pos, ok, errResp = getStreamPosition(httptest.NewRequest("GET", "/fs/stream/foo?offset=42", nil))
+	if suite.Nil(errResp) {
+		suite.True(ok)
+		suite.Equal(plugin.StreamPosition{Offset: 42, HasOffset: true}, pos)
+	}
+
+	pos, ok, errResp = getStreamPosition(httptest.NewRequest("GET", "/fs/stream/foo?since=2019-05-17T10:15:00Z", nil))
+	if suite.Nil(errResp) {
+		suite.True(ok)
+		suite.True(time.Date(2019, 5, 17, 10, 15, 0, 0, time.UTC).Equal(pos.Since))
+		suite.False(pos.HasOffset)
+	}
+
+	for _, query := range []string{"offset=-1", "offset=foo", "since=yesterday", "offset=1&since=2019-05-17T10:15:00Z"} {
+		_, _, errResp = getStreamPosition(httptest.NewRequest("GET", "/fs/stream/foo?"+query, nil))
+		suite.NotNil(errResp, query)
+	}
+}
+
+func TestStream(t *testing.T) {
+	suite.Run(t, new(StreamTestSuite))
+}
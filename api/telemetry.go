@@ -0,0 +1,56 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// swagger:response
+//nolint:deadcode,unused
+type entryTelemetry struct {
+	JSONObject plugin.JSONObject
+}
+
+// swagger:route GET /fs/telemetry telemetry getTelemetry
+//
+// Get telemetry
+//
+// Get the specified entry's live telemetry, e.g. its CPU usage. Unlike
+// metadata, telemetry's only cached for the plugins.telemetry_ttl_ms limit.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: entryTelemetry
+//       400: errorResp
+//       404: errorResp
+//       500: errorResp
+var telemetryHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	ctx := r.Context()
+	entry, path, errResp := getEntryFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+
+	if !plugin.TelemetryAction().IsSupportedOn(entry) {
+		return unsupportedActionResponse(path, entry, plugin.TelemetryAction())
+	}
+	warnIfDeprecated(ctx, w, entry, path, plugin.TelemetryAction())
+
+	telemetry, err := plugin.CachedTelemetry(ctx, entry.(plugin.TelemetryReporter))
+	if err != nil {
+		return actionErrorResponse(path, plugin.TelemetryAction(), err)
+	}
+	activity.Record(ctx, "API: Telemetry %v %+v", path, telemetry)
+
+	if err = writeCacheableJSON(w, r, telemetry, plugin.TelemetryTTL()); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal telemetry for %v: %v", path, err))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type telemetryTestsEntry struct {
+	plugin.EntryBase
+	telemetry func(context.Context) (plugin.JSONObject, error)
+}
+
+func newTelemetryTestsEntry(name string, telemetry func(context.Context) (plugin.JSONObject, error)) *telemetryTestsEntry {
+	return &telemetryTestsEntry{EntryBase: plugin.NewEntry(name), telemetry: telemetry}
+}
+
+func (e *telemetryTestsEntry) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+func (e *telemetryTestsEntry) Telemetry(ctx context.Context) (plugin.JSONObject, error) {
+	return e.telemetry(ctx)
+}
+
+type TelemetryHandlerTestSuite struct {
+	suite.Suite
+	router *mux.Router
+	ctx    context.Context
+}
+
+func (suite *TelemetryHandlerTestSuite) SetupTest() {
+	plugin.SetTestCache(datastore.NewMemCache())
+	suite.router = mux.NewRouter()
+	suite.router.Handle("/fs/telemetry", telemetryHandler).Methods(http.MethodGet)
+	suite.router.Handle("/fs/list", listHandler).Methods(http.MethodGet)
+
+	vm := newTelemetryTestsEntry("vm", func(context.Context) (plugin.JSONObject, error) {
+		return plugin.JSONObject{"cpu_percent": 12.5}, nil
+	})
+	failing := newTelemetryTestsEntry("failing", func(context.Context) (plugin.JSONObject, error) {
+		return nil, fmt.Errorf("the agent is down")
+	})
+	root := newGlobTestsDir("vms", vm, failing, newGlobTestsDir("dir"))
+	root.SetTestID("/vms")
+	registry := plugin.NewRegistry()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+	suite.ctx = context.WithValue(context.Background(), pluginRegistryKey, registry)
+	suite.ctx = context.WithValue(suite.ctx, mountpointKey, "/mnt")
+}
+
+func (suite *TelemetryHandlerTestSuite) TearDownTest() {
+	plugin.UnsetTestCache()
+}
+
+func (suite *TelemetryHandlerTestSuite) get(endpoint string, params url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com"+endpoint+"?"+params.Encode(), nil).WithContext(suite.ctx)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *TelemetryHandlerTestSuite) TestTelemetry() {
+	w := suite.get("/fs/telemetry", url.Values{"path": []string{"/mnt/vms/vm"}})
+	if suite.Equal(http.StatusOK, w.Code, w.Body.String()) {
+		var telemetry plugin.JSONObject
+		suite.NoError(json.Unmarshal(w.Body.Bytes(), &telemetry))
+		suite.Equal(plugin.JSONObject{"cpu_percent": 12.5}, telemetry)
+	}
+
+	w = suite.get("/fs/telemetry", url.Values{"path": []string{"/mnt/vms/failing"}})
+	suite.Equal(http.StatusInternalServerError, w.Code)
+
+	w = suite.get("/fs/telemetry", url.Values{"path": []string{"/mnt/vms/dir"}})
+	suite.Equal(http.StatusNotFound, w.Code)
+	var errObj apitypes.ErrorObj
+	if suite.NoError(json.Unmarshal(w.Body.Bytes(), &errObj)) {
+		suite.Equal(apitypes.UnsupportedAction, errObj.Kind)
+	}
+}
+
+func (suite *TelemetryHandlerTestSuite) TestListWithTelemetry() {
+	w := suite.get("/fs/list", url.Values{"path": []string{"/mnt/vms"}, "telemetry": []string{"true"}})
+	if !suite.Equal(http.StatusOK, w.Code, w.Body.String()) {
+		return
+	}
+	var entries []apitypes.Entry
+	suite.NoError(json.Unmarshal(w.Body.Bytes(), &entries))
+	telemetries := make(map[string]plugin.JSONObject)
+	for _, entry := range entries {
+		telemetries[entry.Name] = entry.Telemetry
+	}
+	// Telemetry's best-effort, so the failing entry's listed without it
+	suite.Equal(map[string]plugin.JSONObject{
+		"dir":     nil,
+		"failing": nil,
+		"vm":      {"cpu_percent": 12.5},
+	}, telemetries)
+
+	w = suite.get("/fs/list", url.Values{"path": []string{"/mnt/vms"}, "telemetry": []string{"true"}, "stream": []string{"true"}})
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestTelemetryHandler(t *testing.T) {
+	suite.Run(t, new(TelemetryHandlerTestSuite))
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+)
+
+// swagger:route GET /fs/translate translate translatePath
+//
+// Translate a path
+//
+// Translates between an OS path under Wash's mountpoint and its Wash path,
+// e.g. ~/mnt/wash/docker/containers and /docker/containers. Exactly one of
+// the path (an OS path) or wash_path (a Wash path) query parameters must be
+// given. OS paths that reach the mountpoint via a symlink are resolved like
+// they are for the other /fs endpoints. The entry doesn't need to exist.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: PathTranslation
+//       400: errorResp
+//       500: errorResp
+var translateHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	mountpoint := r.Context().Value(mountpointKey).(string)
+	translation := apitypes.PathTranslation{Mountpoint: mountpoint}
+
+	query := r.URL.Query()
+	_, hasPath := query["path"]
+	_, hasWashPath := query["wash_path"]
+	switch {
+	case hasPath && hasWashPath:
+		return badRequestResponse("Request must include one of the 'path' or 'wash_path' query parameters, not both")
+	case hasWashPath:
+		washPath := query.Get("wash_path")
+		if washPath == "" {
+			return badRequestResponse("The 'wash_path' query parameter must not be empty")
+		}
+		translation.Path = apitypes.ToMountpointPath(mountpoint, washPath)
+		// Cleans the Wash path
+		translation.WashPath, _ = apitypes.ToWashPath(mountpoint, translation.Path)
+	default:
+		path, errResp := getPathFromRequest(r)
+		if errResp != nil {
+			return errResp
+		}
+		washPath, err := apitypes.ToWashPath(mountpoint, path)
+		if err != nil {
+			return nonWashPathResponse(path)
+		}
+		translation.Path = apitypes.ToMountpointPath(mountpoint, washPath)
+		translation.WashPath = washPath
+	}
+
+	if err := json.NewEncoder(w).Encode(&translation); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the translation of %v: %v", r.URL.RawQuery, err))
+	}
+	return nil
+}
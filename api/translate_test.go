@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/stretchr/testify/suite"
+)
+
+type TranslateTestSuite struct {
+	suite.Suite
+}
+
+func (suite *TranslateTestSuite) translate(params url.Values) *httptest.ResponseRecorder {
+	ctx := context.WithValue(context.Background(), mountpointKey, "/mnt/wash")
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/fs/translate?"+params.Encode(), nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	translateHandler.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *TranslateTestSuite) TestTranslatesPaths() {
+	w := suite.translate(url.Values{"path": []string{"/mnt/wash/docker/./containers/"}})
+	suite.Equal(http.StatusOK, w.Code)
+	var translation apitypes.PathTranslation
+	if suite.NoError(json.Unmarshal(w.Body.Bytes(), &translation)) {
+		suite.Equal(apitypes.PathTranslation{
+			Mountpoint: "/mnt/wash",
+			Path:       "/mnt/wash/docker/containers",
+			WashPath:   "/docker/containers",
+		}, translation)
+	}
+}
+
+func (suite *TranslateTestSuite) TestTranslatesWashPaths() {
+	w := suite.translate(url.Values{"wash_path": []string{"docker/containers/"}})
+	suite.Equal(http.StatusOK, w.Code)
+	var translation apitypes.PathTranslation
+	if suite.NoError(json.Unmarshal(w.Body.Bytes(), &translation)) {
+		suite.Equal(apitypes.PathTranslation{
+			Mountpoint: "/mnt/wash",
+			Path:       "/mnt/wash/docker/containers",
+			WashPath:   "/docker/containers",
+		}, translation)
+	}
+}
+
+func (suite *TranslateTestSuite) TestErrors() {
+	var errResp apitypes.ErrorObj
+	w := suite.translate(url.Values{"path": []string{"/mnt/other"}})
+	suite.Equal(http.StatusBadRequest, w.Code)
+	if suite.NoError(json.Unmarshal(w.Body.Bytes(), &errResp)) {
+		suite.Equal(apitypes.NonWashPath, errResp.Kind)
+	}
+
+	w = suite.translate(url.Values{"path": []string{"/mnt/wash"}, "wash_path": []string{"/"}})
+	suite.Equal(http.StatusBadRequest, w.Code)
+	if suite.NoError(json.Unmarshal(w.Body.Bytes(), &errResp)) {
+		suite.Regexp("not both", errResp.Msg)
+	}
+
+	w = suite.translate(url.Values{})
+	suite.Equal(http.StatusBadRequest, w.Code)
+	if suite.NoError(json.Unmarshal(w.Body.Bytes(), &errResp)) {
+		suite.Equal(apitypes.InvalidPaths, errResp.Kind)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	suite.Run(t, new(TranslateTestSuite))
+}
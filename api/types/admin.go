@@ -0,0 +1,19 @@
+package apitypes
+
+// AdminTokenHeader is the header that carries the admin token in requests to
+// the admin endpoints, e.g. "Authorization: Bearer <token>"
+const AdminTokenHeader = "Authorization"
+
+// AdminTokenPath returns the path of the file that the server listening at
+// socketPath saves its admin token to. The file's only readable by the user
+// that started the server.
+func AdminTokenPath(socketPath string) string {
+	return socketPath + ".token"
+}
+
+// These are the profiles that can be fetched from the admin API's
+// /admin/debug/pprof/ endpoints via the client
+const (
+	CPUProfile  = "cpu"
+	HeapProfile = "heap"
+)
@@ -0,0 +1,11 @@
+package apitypes
+
+// CancelResult describes the result returned by the `/cancel` endpoint.
+// Requests is the number of in-flight requests that were cancelled, and
+// Operations are the operations that were cancelled.
+//
+// swagger:response
+type CancelResult struct {
+	Requests   int         `json:"requests"`
+	Operations []Operation `json:"operations"`
+}
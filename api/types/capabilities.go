@@ -0,0 +1,46 @@
+package apitypes
+
+import "github.com/puppetlabs/wash/plugin"
+
+// EntryCapabilities describes what an entry supports, and how Wash caches it.
+//
+// swagger:response
+type EntryCapabilities struct {
+	Path              string                              `json:"path"`
+	TypeID            string                              `json:"type_id"`
+	Actions           []string                            `json:"actions"`
+	DeprecatedActions map[string]plugin.ActionDeprecation `json:"deprecated_actions,omitempty"`
+	// CustomActions are the entry's custom actions, which are performed via
+	// /fs/run
+	CustomActions []string `json:"custom_actions,omitempty"`
+	// Attributes are the names of the attributes that the entry sets, e.g.
+	// mtime and size.
+	Attributes []string `json:"attributes"`
+	// Cache describes how the results of the entry's list, read and metadata
+	// ops are cached. It only includes the ops that the entry supports.
+	Cache map[string]OpCache `json:"cache"`
+	// StreamingList is true if the entry's children can be streamed via
+	// /fs/list's stream parameter as they're listed.
+	StreamingList bool           `json:"streaming_list,omitempty"`
+	Plugin        PluginIdentity `json:"plugin"`
+}
+
+// OpCache describes how the result of an entry's op is cached
+type OpCache struct {
+	// TTL is how long the op's result is cached for, in seconds. It's
+	// negative if caching is disabled for the op.
+	TTL int64 `json:"ttl"`
+	// Cached is true if the op's result is cached, i.e. if the next call
+	// to the op won't invoke the plugin.
+	Cached bool `json:"cached"`
+}
+
+// PluginIdentity identifies the plugin that an entry belongs to. Script,
+// Version and ProtocolVersion are only set for external plugins.
+type PluginIdentity struct {
+	Name            string `json:"name"`
+	External        bool   `json:"external"`
+	Script          string `json:"script,omitempty"`
+	Version         string `json:"version,omitempty"`
+	ProtocolVersion int    `json:"protocol_version,omitempty"`
+}
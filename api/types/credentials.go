@@ -0,0 +1,38 @@
+package apitypes
+
+import "time"
+
+// CredentialStatus describes a plugin's credential without revealing it.
+//
+// swagger:response
+type CredentialStatus struct {
+	Name string `json:"name"`
+	// Generation is incremented each time the credential's refreshed
+	Generation  int       `json:"generation"`
+	RefreshedAt time.Time `json:"refreshed_at,omitempty"`
+	// ExpiresAt is the zero time if the credential never expires
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	Refreshing bool      `json:"refreshing"`
+	// LastError is the error of the last refresh if it failed
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Credential is a plugin's credential, e.g. the resolved secrets of an external
+// plugin.
+//
+// swagger:response
+type Credential struct {
+	Name       string      `json:"name"`
+	Generation int         `json:"generation"`
+	ExpiresAt  time.Time   `json:"expires_at,omitempty"`
+	Value      interface{} `json:"value"`
+}
+
+// CredentialsResponse describes the result returned by the `/credentials`
+// endpoint.
+//
+// swagger:response
+type CredentialsResponse struct {
+	// in: body
+	Credentials []CredentialStatus
+}
@@ -6,12 +6,31 @@ import "github.com/puppetlabs/wash/plugin"
 //
 // swagger:response
 type Entry struct {
-	TypeID     string                 `json:"type_id"`
-	Path       string                 `json:"path"`
-	Actions    []string               `json:"actions"`
-	Name       string                 `json:"name"`
-	CName      string                 `json:"cname"`
-	Attributes plugin.EntryAttributes `json:"attributes"`
+	TypeID            string                              `json:"type_id"`
+	Path              string                              `json:"path"`
+	Actions           []string                            `json:"actions"`
+	DeprecatedActions map[string]plugin.ActionDeprecation `json:"deprecated_actions,omitempty"`
+	Name              string                              `json:"name"`
+	CName             string                              `json:"cname"`
+	Attributes        plugin.EntryAttributes              `json:"attributes"`
+	// CustomActions are the entry's custom, plugin-defined actions, which are
+	// performed via the run action
+	CustomActions []string `json:"custom_actions,omitempty"`
+	// SymlinkTarget is the entry's target if it's a symlink
+	SymlinkTarget string `json:"symlink_target,omitempty"`
+	// Metadata is only included when it's requested, e.g. via /fs/list's
+	// metadata parameter.
+	Metadata plugin.JSONObject `json:"metadata,omitempty"`
+	// Telemetry is only included when it's requested, e.g. via /fs/list's
+	// telemetry parameter.
+	Telemetry plugin.JSONObject `json:"telemetry,omitempty"`
+	// Stale is true if the entry's metadata couldn't be fetched in time, in
+	// which case Metadata is empty and Attributes are from its parent's
+	// listing.
+	Stale bool `json:"stale,omitempty"`
+	// Error is set if the entry couldn't be listed (e.g. it's a region that
+	// errored) or if its metadata couldn't be fetched.
+	Error *ErrorObj `json:"error,omitempty"`
 }
 
 // Supports returns true if e supports the given action, false
@@ -24,3 +43,25 @@ func (e *Entry) Supports(action plugin.Action) bool {
 	}
 	return false
 }
+
+// Deprecation returns the action's deprecation if the action's deprecated
+// on e. The returned bool is false if the action's not deprecated.
+func (e *Entry) Deprecation(action string) (plugin.ActionDeprecation, bool) {
+	d, ok := e.DeprecatedActions[action]
+	return d, ok
+}
+
+// ListPacket is a line of a streaming listing (see /fs/list's stream
+// parameter). It's either one of the children, or the error that the listing
+// failed with after some of the children were streamed.
+type ListPacket struct {
+	Entry *Entry    `json:"entry,omitempty"`
+	Err   *ErrorObj `json:"error,omitempty"`
+}
+
+// ListPage is a page of a paged listing (see /fs/list's paged parameter).
+// NextPage is the next page's token, which is empty if it's the last page.
+type ListPage struct {
+	Entries  []Entry `json:"entries"`
+	NextPage string  `json:"next_page,omitempty"`
+}
@@ -207,6 +207,12 @@ func (s *EntrySchema) SetActions(actions []string) *EntrySchema {
 	return s
 }
 
+// DeprecatedActions returns the entry's deprecated actions as a map of
+// <action_name> => <deprecation>
+func (s *EntrySchema) DeprecatedActions() map[string]plugin.ActionDeprecation {
+	return s.EntrySchema.DeprecatedActions
+}
+
 // MetaAttributeSchema returns the entry's meta attribute
 // schema
 func (s *EntrySchema) MetaAttributeSchema() *plugin.JSONSchema {
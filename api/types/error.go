@@ -12,17 +12,28 @@ type ErrorFields = map[string]interface{}
 type ErrorObj struct {
 	// Identifies the kind of error.
 	Kind string `json:"kind"`
+	// The kind's stable code (see ErrorCatalog). It's empty in the errors of
+	// older servers, which is why code() falls back to the kind's code.
+	Code string `json:"code,omitempty"`
 	// A description of what failed.
 	Msg string `json:"msg"`
 	// Additional structured data that may be useful in responding to the error.
 	Fields ErrorFields `json:"fields"`
 }
 
-// Provides a nicely formatted string representation of the error. Omits Fields.
-// If you want to use fields, either access them directly or serialize the whole thing
-// to JSON as part of a larger set of JSON output.
+// Provides a nicely formatted string representation of the error, prefixed
+// by its code. Omits Fields. If you want to use fields, either access them
+// directly or serialize the whole thing to JSON as part of a larger set of
+// JSON output.
 func (e *ErrorObj) Error() string {
-	return fmt.Sprintf("%v: %v", e.Kind, e.Msg)
+	return fmt.Sprintf("[%v] %v: %v", e.code(), e.Kind, e.Msg)
+}
+
+func (e *ErrorObj) code() string {
+	if e.Code != "" {
+		return e.Code
+	}
+	return ErrorCodeOf(e.Kind)
 }
 
 // Define error kinds returned by the API
@@ -42,4 +53,43 @@ const (
 	OutOfBounds        = "puppetlabs.wash/out-of-bounds"
 	NonWashPath        = "puppetlabs.wash/non-wash-path"
 	InvalidBool        = "puppetlabs.wash/invalid-bool"
+	LimitNotFound      = "puppetlabs.wash/limit-not-found"
+	PermissionDenied   = "puppetlabs.wash/permission-denied"
+	Timeout            = "puppetlabs.wash/timeout"
+	OperationNotFound  = "puppetlabs.wash/operation-not-found"
+	// PatternTooBroad is returned when expanding a glob pattern exceeds the
+	// glob limits
+	PatternTooBroad = "puppetlabs.wash/pattern-too-broad"
+	// OperationNotDone is returned when requesting the result of an
+	// operation that's still running or that didn't succeed
+	OperationNotDone = "puppetlabs.wash/operation-not-done"
+	// ExecConsentRequired is returned when executing a command before
+	// consenting to the exec policy's banner, which is in the banner field
+	ExecConsentRequired = "puppetlabs.wash/exec-consent-required"
+	// ExecJustificationRequired is returned when the exec policy requires a
+	// justification for the command, but none was supplied
+	ExecJustificationRequired = "puppetlabs.wash/exec-justification-required"
+	// ExecDenied is returned when the exec policy denies the command
+	ExecDenied = "puppetlabs.wash/exec-denied"
+	// Unauthorized is returned when an admin endpoint is requested without
+	// the server's admin token
+	Unauthorized = "puppetlabs.wash/unauthorized"
+	// PinNotFound is returned when unpinning a subtree that isn't pinned
+	PinNotFound = "puppetlabs.wash/pin-not-found"
+	// CredentialNotFound is returned when requesting a credential that isn't
+	// registered
+	CredentialNotFound = "puppetlabs.wash/credential-not-found"
+	// FeatureNotFound is returned when toggling a feature flag that isn't
+	// registered
+	FeatureNotFound = "puppetlabs.wash/feature-not-found"
+	// PluginQuarantined is returned by the entries of an external plugin
+	// that's quarantined because it repeatedly failed its health checks
+	PluginQuarantined = "puppetlabs.wash/plugin-quarantined"
+	// DevModeRequired is returned when a development endpoint (e.g. setting
+	// the fault injection rules) is requested while the server isn't in dev
+	// mode
+	DevModeRequired = "puppetlabs.wash/dev-mode-required"
+	// CredentialForbidden is returned when a plugin's credentials token is
+	// used to request another plugin's credential
+	CredentialForbidden = "puppetlabs.wash/credential-forbidden"
 )
@@ -0,0 +1,94 @@
+package apitypes
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// ErrorCatalogEntry describes one kind of the errors that Wash reports. Its
+// code is stable: it never changes once it's released, and it's never reused
+// for another kind. Thus, tooling can match on it (and translations can be
+// keyed by it) even when the error's message changes between releases.
+type ErrorCatalogEntry struct {
+	Code    string `json:"code"`
+	Kind    string `json:"kind"`
+	Summary string `json:"summary"`
+}
+
+// errorCatalog lists every error kind. New kinds are appended with the next
+// code. Kinds that are removed keep their entry so that their code isn't
+// reused.
+var errorCatalog = []ErrorCatalogEntry{
+	{"WASH1000", UnknownError, "The request failed for an unclassified reason"},
+	{"WASH1001", UnsupportedAction, "The entry does not support the action"},
+	{"WASH1002", StreamingError, "The output could not be streamed"},
+	{"WASH1003", EntryNotFound, "The entry does not exist"},
+	{"WASH1004", PluginDoesNotExist, "The plugin is not loaded"},
+	{"WASH1005", BadRequest, "The request is malformed"},
+	{"WASH1006", BadActionRequest, "The action's request is malformed"},
+	{"WASH1007", JournalUnavailable, "The journal could not be read"},
+	{"WASH1008", ErroredAction, "The plugin failed to perform the action"},
+	{"WASH1009", DuplicateCName, "Two of the parent's children have the same cname"},
+	{"WASH1010", RelativePath, "The path must be absolute"},
+	{"WASH1011", InvalidPaths, "The request's paths are invalid"},
+	{"WASH1012", OutOfBounds, "The requested range is outside of the entry's content"},
+	{"WASH1013", NonWashPath, "The path is not in the Wash filesystem"},
+	{"WASH1014", InvalidBool, "The parameter must be a boolean"},
+	{"WASH1015", LimitNotFound, "The limit does not exist"},
+	{"WASH1016", PermissionDenied, "The plugin denied access to the entry"},
+	{"WASH1017", Timeout, "The request timed out"},
+	{"WASH1018", OperationNotFound, "The operation does not exist"},
+	{"WASH1019", PatternTooBroad, "The glob pattern matches too many entries"},
+	{"WASH1020", OperationNotDone, "The operation is still running or did not succeed"},
+	{"WASH1021", ExecConsentRequired, "The exec policy's banner must be consented to"},
+	{"WASH1022", ExecJustificationRequired, "The exec policy requires a justification"},
+	{"WASH1023", ExecDenied, "The exec policy denied the command"},
+	{"WASH1024", Unauthorized, "The request requires the server's admin token"},
+	{"WASH1025", PinNotFound, "The subtree is not pinned"},
+	{"WASH1026", CredentialNotFound, "The credential does not exist"},
+	{"WASH1027", FeatureNotFound, "The feature flag does not exist"},
+	{"WASH1028", PluginQuarantined, "The plugin is quarantined because it failed its health checks"},
+	{"WASH1029", DevModeRequired, "The request requires the server to be in dev mode"},
+	{"WASH1030", CredentialForbidden, "The plugin's credentials token does not grant access to the credential"},
+}
+
+// ErrorCatalog returns the error catalog, sorted by code
+func ErrorCatalog() []ErrorCatalogEntry {
+	return append([]ErrorCatalogEntry(nil), errorCatalog...)
+}
+
+// ErrorCodeOf returns the code of the error kind. Kinds that aren't in the
+// catalog (e.g. the kinds of a newer server) get UnknownError's code.
+func ErrorCodeOf(kind string) string {
+	for _, entry := range errorCatalog {
+		if entry.Kind == kind {
+			return entry.Code
+		}
+	}
+	return errorCatalog[0].Code
+}
+
+// ErrorCodeFor returns the code of err. API errors have their kind's code.
+// Other errors (e.g. the plugin errors that the FUSE filesystem logs) are
+// classified like the API classifies them.
+func ErrorCodeFor(err error) string {
+	var errObj *ErrorObj
+	var quarantinedErr plugin.QuarantinedErr
+	switch {
+	case errors.As(err, &errObj):
+		return errObj.code()
+	case errors.As(err, &quarantinedErr):
+		return ErrorCodeOf(PluginQuarantined)
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorCodeOf(Timeout)
+	case errors.Is(err, os.ErrPermission):
+		return ErrorCodeOf(PermissionDenied)
+	case errors.Is(err, os.ErrNotExist):
+		return ErrorCodeOf(EntryNotFound)
+	default:
+		return ErrorCodeOf(ErroredAction)
+	}
+}
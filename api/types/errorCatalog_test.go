@@ -0,0 +1,56 @@
+package apitypes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorCatalogTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ErrorCatalogTestSuite) TestCatalogCodesAreUnique() {
+	codes := make(map[string]bool)
+	kinds := make(map[string]bool)
+	for _, entry := range ErrorCatalog() {
+		suite.Regexp(regexp.MustCompile(`^WASH\d{4}$`), entry.Code)
+		suite.False(codes[entry.Code], "%v is used twice", entry.Code)
+		suite.False(kinds[entry.Kind], "%v is cataloged twice", entry.Kind)
+		suite.NotEmpty(entry.Summary, entry.Kind)
+		codes[entry.Code] = true
+		kinds[entry.Kind] = true
+	}
+}
+
+func (suite *ErrorCatalogTestSuite) TestErrorCodeOf() {
+	suite.Equal("WASH1003", ErrorCodeOf(EntryNotFound))
+	suite.Equal("WASH1000", ErrorCodeOf("puppetlabs.wash/from-the-future"))
+}
+
+func (suite *ErrorCatalogTestSuite) TestErrorCodeFor() {
+	suite.Equal("WASH1003", ErrorCodeFor(fmt.Errorf("open failed: %w", os.ErrNotExist)))
+	suite.Equal("WASH1016", ErrorCodeFor(&os.PathError{Op: "open", Path: "/foo", Err: os.ErrPermission}))
+	suite.Equal("WASH1017", ErrorCodeFor(context.DeadlineExceeded))
+	suite.Equal("WASH1008", ErrorCodeFor(fmt.Errorf("failed")))
+	suite.Equal("WASH1028", ErrorCodeFor(fmt.Errorf("list failed: %w", plugin.QuarantinedErr{Plugin: "foo"})))
+	suite.Equal("WASH1015", ErrorCodeFor(fmt.Errorf("wrapped: %w", &ErrorObj{Kind: LimitNotFound})))
+}
+
+func (suite *ErrorCatalogTestSuite) TestErrorObjIsPrefixedByItsCode() {
+	err := &ErrorObj{Kind: EntryNotFound, Code: "WASH1003", Msg: "Could not find entry /foo"}
+	suite.Equal("[WASH1003] puppetlabs.wash/entry-not-found: Could not find entry /foo", err.Error())
+
+	// The errors of older servers don't include their code
+	err = &ErrorObj{Kind: Timeout, Msg: "timed out"}
+	suite.Equal("[WASH1017] puppetlabs.wash/timeout: timed out", err.Error())
+}
+
+func TestErrorCatalog(t *testing.T) {
+	suite.Run(t, new(ErrorCatalogTestSuite))
+}
@@ -1,17 +1,38 @@
 package apitypes
 
 import (
+	"io"
 	"time"
 
 	"github.com/puppetlabs/wash/plugin"
 )
 
 // ExecOptions are options that can be passed as part of an Exec call.
-// These are not identical to plugin.ExecOptions because initially the API only
-// supports receiving a string of input, not a reader.
+// These are not identical to plugin.ExecOptions because the API can receive
+// input either as a string or as a stream that follows the request's JSON
+// payload.
 type ExecOptions struct {
 	// Input to pass on stdin when executing the command
 	Input string `json:"input"`
+	// Stdin is streamed to the command's stdin until it reaches EOF. It's
+	// sent after the JSON payload, so it can't be used with Input.
+	Stdin io.Reader `json:"-"`
+	// StreamStdin indicates that the rest of the request body (after the JSON
+	// payload) should be streamed to the command's stdin. Clients set it when
+	// Stdin is set.
+	StreamStdin bool `json:"stream_stdin"`
+	// Env are environment variables to set for the command on the remote
+	// side. Entries must honor the env exec option to support it.
+	Env map[string]string `json:"env"`
+	// Cwd is the remote working directory to run the command in. Entries must
+	// honor the cwd exec option to support it.
+	Cwd string `json:"cwd"`
+	// Consented indicates that the user consented to the exec policy's
+	// banner
+	Consented bool `json:"consented"`
+	// Justification is why the user's running the command. It's passed to
+	// the exec policy, and recorded in the exec's transcript.
+	Justification string `json:"justification"`
 }
 
 // ExecBody encapsulates the payload for a call to a plugin's Exec function
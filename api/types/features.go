@@ -0,0 +1,30 @@
+package apitypes
+
+// Feature describes a feature flag, which gates an experimental behavior.
+//
+// swagger:response
+type Feature struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	// Overrides maps plugins to their override of the flag's value
+	Overrides map[string]bool `json:"overrides,omitempty"`
+}
+
+// FeatureBody encapsulates the payload for a request to toggle a feature flag
+type FeatureBody struct {
+	// Enable or disable the flag
+	Enabled bool `json:"enabled"`
+	// Only toggle the flag for this plugin
+	Plugin string `json:"plugin,omitempty"`
+	// Persist the new value to the config so that it survives restarts
+	Persist bool `json:"persist"`
+}
+
+// FeaturesResponse describes the result returned by the `/features` endpoint.
+//
+// swagger:response
+type FeaturesResponse struct {
+	// in: body
+	Features []Feature
+}
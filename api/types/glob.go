@@ -0,0 +1,9 @@
+package apitypes
+
+import "strings"
+
+// IsGlob returns true if the path contains any of the wildcards that are
+// expanded by the /fs/glob endpoint (*, ?, [...] and **)
+func IsGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
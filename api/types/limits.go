@@ -0,0 +1,26 @@
+package apitypes
+
+// Limit describes a tunable concurrency or rate limit.
+//
+// swagger:response
+type Limit struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Value       int    `json:"value"`
+}
+
+// LimitBody encapsulates the payload for a request to tune a limit
+type LimitBody struct {
+	// The limit's new value
+	Value int `json:"value"`
+	// Persist the new value to the config so that it survives restarts
+	Persist bool `json:"persist"`
+}
+
+// LimitsResponse describes the result returned by the `/limits` endpoint.
+//
+// swagger:response
+type LimitsResponse struct {
+	// in: body
+	Limits []Limit
+}
@@ -0,0 +1,18 @@
+package apitypes
+
+import (
+	"time"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// MetadataSnapshot describes an observation of an entry's metadata. Time is
+// when the metadata was first observed, and LastObserved is when it was last
+// observed without changes.
+//
+// swagger:response
+type MetadataSnapshot struct {
+	Time         time.Time         `json:"time"`
+	LastObserved time.Time         `json:"last_observed"`
+	Metadata     plugin.JSONObject `json:"metadata"`
+}
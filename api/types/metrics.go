@@ -0,0 +1,9 @@
+package apitypes
+
+import "github.com/puppetlabs/wash/metrics"
+
+// Metrics describes the server's recent activity, e.g. its FUSE ops/sec and
+// each plugin's call latency.
+//
+// swagger:response
+type Metrics = metrics.Snapshot
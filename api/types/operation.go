@@ -0,0 +1,33 @@
+package apitypes
+
+import (
+	"time"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// Operation describes an asynchronous operation, e.g. a read that was
+// started with async=true. Done and Total describe its progress. Total is 0
+// if it isn't known.
+//
+// swagger:response
+type Operation struct {
+	ID       string                 `json:"id"`
+	Action   string                 `json:"action"`
+	Path     string                 `json:"path"`
+	Status   plugin.OperationStatus `json:"status"`
+	Started  time.Time              `json:"started"`
+	Finished time.Time              `json:"finished,omitempty"`
+	Done     int64                  `json:"done"`
+	Total    int64                  `json:"total"`
+	Err      string                 `json:"error,omitempty"`
+}
+
+// OperationsResponse describes the result returned by the `/operations`
+// endpoint.
+//
+// swagger:response
+type OperationsResponse struct {
+	// in: body
+	Operations []Operation
+}
@@ -0,0 +1,102 @@
+package apitypes
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PathTranslation describes an entry's path on the OS, i.e. under Wash's
+// mountpoint, and its Wash path, i.e. its path relative to the mountpoint.
+//
+// swagger:response
+type PathTranslation struct {
+	Mountpoint string `json:"mountpoint"`
+	Path       string `json:"path"`
+	// WashPath always uses forward slashes, e.g. "/docker/containers". "/" is
+	// the mountpoint itself.
+	WashPath string `json:"wash_path"`
+}
+
+// ToWashPath returns the Wash path of the given OS path, which must be under
+// the mountpoint. Windows mountpoints (e.g. "W:\" or "C:\Users\me\wash") are
+// supported regardless of the OS that ToWashPath is called on. Their paths can
+// use either separator, and are matched case-insensitively.
+func ToWashPath(mountpoint string, osPath string) (string, error) {
+	windows := isWindowsPath(mountpoint)
+	mp, p := normalizePath(mountpoint, windows), normalizePath(osPath, windows)
+	if !windows && !strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("%v is not an absolute path", osPath)
+	}
+	// Strip the root's trailing slash so that all of the mountpoint's paths
+	// are of the form <mp>/<wash path>
+	mp = strings.TrimSuffix(mp, "/")
+	if len(p) < len(mp) || !pathsEqual(p[:len(mp)], mp, windows) {
+		return "", fmt.Errorf("%v is not in Wash's mountpoint %v", osPath, mountpoint)
+	}
+	rest := p[len(mp):]
+	if rest == "" {
+		return "/", nil
+	}
+	if !strings.HasPrefix(rest, "/") {
+		return "", fmt.Errorf("%v is not in Wash's mountpoint %v", osPath, mountpoint)
+	}
+	return rest, nil
+}
+
+// ToMountpointPath returns the OS path of the given Wash path. Windows paths
+// use backslashes. washPath is cleaned, so the returned path is always under
+// the mountpoint.
+func ToMountpointPath(mountpoint string, washPath string) string {
+	washPath = path.Clean("/" + strings.Replace(washPath, `\`, "/", -1))
+	if !isWindowsPath(mountpoint) {
+		if washPath == "/" {
+			return mountpoint
+		}
+		return strings.TrimSuffix(mountpoint, "/") + washPath
+	}
+
+	mp := strings.TrimRight(mountpoint, `\/`)
+	if washPath == "/" {
+		if isDrive(mp) {
+			// "W:" is the current directory on the W drive, not its root
+			return mp + `\`
+		}
+		return mp
+	}
+	return mp + strings.Replace(washPath, "/", `\`, -1)
+}
+
+// isWindowsPath returns true if p has a drive letter (e.g. "W:\wash") or is a
+// UNC path (e.g. "\\server\share")
+func isWindowsPath(p string) bool {
+	return (len(p) >= 2 && isDrive(p[:2])) || strings.HasPrefix(p, `\\`)
+}
+
+func isDrive(p string) bool {
+	if len(p) != 2 || p[1] != ':' {
+		return false
+	}
+	letter := p[0]
+	return ('a' <= letter && letter <= 'z') || ('A' <= letter && letter <= 'Z')
+}
+
+// normalizePath cleans p. Windows paths are converted to forward slashes first.
+func normalizePath(p string, windows bool) string {
+	if !windows {
+		return path.Clean(p)
+	}
+	p = strings.Replace(p, `\`, "/", -1)
+	if strings.HasPrefix(p, "//") {
+		// path.Clean would collapse the UNC path's leading slashes
+		return "/" + path.Clean(p[1:])
+	}
+	return path.Clean(p)
+}
+
+func pathsEqual(a string, b string, windows bool) bool {
+	if windows {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
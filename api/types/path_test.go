@@ -0,0 +1,69 @@
+package apitypes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PathTestSuite struct {
+	suite.Suite
+}
+
+func (suite *PathTestSuite) TestToWashPath() {
+	cases := []struct {
+		mountpoint string
+		path       string
+		washPath   string
+	}{
+		{"/home/me/mnt/wash", "/home/me/mnt/wash", "/"},
+		{"/home/me/mnt/wash/", "/home/me/mnt/wash/docker/containers/", "/docker/containers"},
+		{"/home/me/mnt/wash", "/home/me/mnt/wash//docker/./containers/../containers", "/docker/containers"},
+		{"/", "/docker", "/docker"},
+		{`W:\`, `W:\`, "/"},
+		{`W:`, `w:\docker\containers`, "/docker/containers"},
+		{`C:\Users\me\wash`, `c:/users/ME/wash/docker`, "/docker"},
+		{`\\server\share\wash`, `\\server\share\wash\docker\containers`, "/docker/containers"},
+	}
+	for _, c := range cases {
+		washPath, err := ToWashPath(c.mountpoint, c.path)
+		if suite.NoError(err, c.path) {
+			suite.Equal(c.washPath, washPath, c.path)
+		}
+	}
+}
+
+func (suite *PathTestSuite) TestToWashPathErrorsForPathsOutsideOfTheMountpoint() {
+	_, err := ToWashPath("/home/me/mnt/wash", "/home/me/mnt/washer")
+	suite.EqualError(err, "/home/me/mnt/washer is not in Wash's mountpoint /home/me/mnt/wash")
+	_, err = ToWashPath("/home/me/mnt/wash", "/home/me/mnt/wash/../other")
+	suite.EqualError(err, "/home/me/mnt/wash/../other is not in Wash's mountpoint /home/me/mnt/wash")
+	_, err = ToWashPath("/", "docker")
+	suite.EqualError(err, "docker is not an absolute path")
+	_, err = ToWashPath(`W:\`, `C:\docker`)
+	suite.EqualError(err, `C:\docker is not in Wash's mountpoint W:\`)
+}
+
+func (suite *PathTestSuite) TestToMountpointPath() {
+	cases := []struct {
+		mountpoint string
+		washPath   string
+		path       string
+	}{
+		{"/home/me/mnt/wash", "/", "/home/me/mnt/wash"},
+		{"/home/me/mnt/wash/", "/docker/containers/", "/home/me/mnt/wash/docker/containers"},
+		{"/home/me/mnt/wash", "docker/../../etc", "/home/me/mnt/wash/etc"},
+		{"/", "/docker", "/docker"},
+		{`W:`, "/", `W:\`},
+		{`W:\`, "/docker/containers", `W:\docker\containers`},
+		{`C:\Users\me\wash\`, `\docker`, `C:\Users\me\wash\docker`},
+		{`\\server\share\wash`, "/", `\\server\share\wash`},
+	}
+	for _, c := range cases {
+		suite.Equal(c.path, ToMountpointPath(c.mountpoint, c.washPath), c.washPath)
+	}
+}
+
+func TestPath(t *testing.T) {
+	suite.Run(t, new(PathTestSuite))
+}
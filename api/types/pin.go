@@ -0,0 +1,9 @@
+package apitypes
+
+import "github.com/puppetlabs/wash/plugin"
+
+// Pin describes a pinned subtree. Pinned entries are kept warm by background
+// refresh, and they're never evicted from the cache.
+//
+// swagger:response
+type Pin = plugin.PinInfo
@@ -0,0 +1,14 @@
+package apitypes
+
+import "github.com/puppetlabs/wash/retention"
+
+// PruneBody encapsulates the payload for a request to prune Wash's on-disk
+// stores. Its non-zero fields override the corresponding fields of each
+// store's configured retention policy.
+type PruneBody = retention.Policy
+
+// PruneResult describes what pruning one of Wash's on-disk stores (e.g. its
+// activity journals) deleted
+//
+// swagger:response
+type PruneResult = retention.Result
@@ -0,0 +1,35 @@
+package apitypes
+
+import "strings"
+
+// Resource describes one of the resources that a path is nested under, e.g.
+// the Kubernetes context, namespace and pod of a pod's file.
+type Resource struct {
+	// Kind is the label of the resource's entry schema, e.g. "pod"
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// ResourceContext describes the resource context of a path.
+//
+// swagger:response
+type ResourceContext struct {
+	Path string `json:"path"`
+	// Plugin is empty if the path is not in a plugin
+	Plugin    string     `json:"plugin"`
+	Resources []Resource `json:"resources"`
+}
+
+// String returns a prompt-friendly representation of the resource context,
+// e.g. "kubernetes context=minikube namespace=default pod=nginx". It returns an
+// empty string if the path is not in a plugin.
+func (c ResourceContext) String() string {
+	if c.Plugin == "" {
+		return ""
+	}
+	parts := []string{c.Plugin}
+	for _, resource := range c.Resources {
+		parts = append(parts, resource.Kind+"="+resource.Name)
+	}
+	return strings.Join(parts, " ")
+}
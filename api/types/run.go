@@ -0,0 +1,29 @@
+package apitypes
+
+// RunBody encapsulates the payload for a call to a plugin's Run function
+type RunBody struct {
+	// Action is the custom action to perform, e.g. snapshot or reboot. It must
+	// be one of the entry's custom_actions.
+	Action string `json:"action"`
+	// Args are the action's arguments
+	Args []string `json:"args"`
+	// Opts are the options that satisfy the exec policy, which also governs
+	// custom actions
+	Opts RunOptions `json:"opts"`
+}
+
+// RunOptions are the exec policy options of a custom action. See ExecOptions.
+type RunOptions struct {
+	// Consented indicates that the user consented to the exec policy's
+	// banner
+	Consented bool `json:"consented"`
+	// Justification is why the user's performing the action. It's passed to
+	// the exec policy.
+	Justification string `json:"justification"`
+}
+
+// RunResult is the result of performing a custom action
+type RunResult struct {
+	// Output is what the action printed
+	Output string `json:"output"`
+}
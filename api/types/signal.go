@@ -0,0 +1,8 @@
+package apitypes
+
+// SignalBody encapsulates the payload for a call to a plugin's Signal function
+type SignalBody struct {
+	// Signal to send, e.g. start, stop, restart or kill. Which signals are
+	// supported is up to the entry.
+	Signal string `json:"signal"`
+}
@@ -0,0 +1,15 @@
+package apitypes
+
+import "time"
+
+// Snapshot describes a saved snapshot of the server's cache.
+//
+// swagger:response
+type Snapshot struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// The number of entries that were captured
+	Entries int `json:"entries"`
+	// The path of the saved snapshot
+	Path string `json:"path"`
+}
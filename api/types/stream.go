@@ -0,0 +1,21 @@
+package apitypes
+
+import "github.com/puppetlabs/wash/plugin"
+
+// StreamEndTrailer is the name of the HTTP trailer that the stream endpoint
+// uses to report why a stream ended. Its value is a JSON-serialized
+// StreamEnd.
+const StreamEndTrailer = "Wash-Stream-End"
+
+// StreamOffsetHeader is the name of the HTTP header that the stream endpoint
+// uses to report the offset (in the stream's history) of the stream's output.
+// It's only set if the offset's known, in which case clients can resume the
+// stream at the offset plus the number of bytes that they've read.
+const StreamOffsetHeader = "Wash-Stream-Offset"
+
+// StreamPosition is the position that a resumed stream starts at
+type StreamPosition = plugin.StreamPosition
+
+// StreamEnd describes why a stream ended. The client's streams return it
+// from Read instead of io.EOF.
+type StreamEnd = plugin.StreamEnd
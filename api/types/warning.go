@@ -0,0 +1,32 @@
+package apitypes
+
+import (
+	"strconv"
+	"strings"
+)
+
+// WarningHeader is the name of the HTTP Header used to return warnings (e.g.
+// that the invoked action is deprecated) alongside a successful response.
+const WarningHeader = "Warning"
+
+// warningPrefix is the warn-code and warn-agent of the warnings returned
+// by the API. 299 is the "Miscellaneous persistent warning" code from RFC 7234.
+const warningPrefix = "299 wash "
+
+// FormatWarning formats msg as a Warning header value.
+func FormatWarning(msg string) string {
+	return warningPrefix + strconv.Quote(msg)
+}
+
+// ParseWarning parses the message out of a Warning header value. If the
+// value wasn't produced by FormatWarning, then it is returned as-is.
+func ParseWarning(value string) string {
+	if !strings.HasPrefix(value, warningPrefix) {
+		return value
+	}
+	msg, err := strconv.Unquote(strings.TrimPrefix(value, warningPrefix))
+	if err != nil {
+		return value
+	}
+	return msg
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// swagger:route GET /fs/whereami whereami resourceContext
+//
+// Resource context of path
+//
+// Returns the resources that the given path is nested under, e.g. the
+// Kubernetes context, namespace and pod of a pod's file. A resource is an
+// ancestor entry (or the entry itself) with a non-singleton schema. Paths
+// outside of Wash have an empty context, so this is safe to call from
+// shell prompts.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: ResourceContext
+//       400: errorResp
+//       404: errorResp
+//       500: errorResp
+var whereamiHandler handler = func(w http.ResponseWriter, r *http.Request) *errorResponse {
+	path, errResp := getPathFromRequest(r)
+	if errResp != nil {
+		return errResp
+	}
+
+	resourceContext := apitypes.ResourceContext{
+		Path:      path,
+		Resources: []apitypes.Resource{},
+	}
+	ctx := r.Context()
+	if trimmedPath, errResp := toWashPath(ctx, path); errResp == nil {
+		segments := strings.Split(strings.Trim(trimmedPath, "/"), "/")
+		if segments[0] != "" {
+			registry := ctx.Value(pluginRegistryKey).(*plugin.Registry)
+			pluginName := segments[0]
+			root, ok := registry.Plugins()[pluginName]
+			if !ok {
+				return pluginDoesNotExistResponse(pluginName)
+			}
+			resourceContext.Plugin = pluginName
+
+			// Walk the path one segment at a time so that we visit each of the
+			// entry's ancestors. The lookups go through the list cache, so this
+			// is cheap for recently visited paths.
+			var entry plugin.Entry = root
+			for _, segment := range segments[1:] {
+				var err error
+				entry, err = plugin.FindEntry(ctx, entry, []string{segment})
+				if err != nil {
+					if cnameErr, ok := err.(plugin.DuplicateCNameErr); ok {
+						return duplicateCNameResponse(cnameErr)
+					}
+					return entryNotFoundResponse(path, err.Error())
+				}
+				if resource, ok := toAPIResource(entry); ok {
+					resourceContext.Resources = append(resourceContext.Resources, resource)
+				}
+			}
+		}
+	}
+
+	jsonEncoder := json.NewEncoder(w)
+	if err := jsonEncoder.Encode(&resourceContext); err != nil {
+		return unknownErrorResponse(fmt.Errorf("Could not marshal the resource context of %v: %v", path, err))
+	}
+	return nil
+}
+
+// toAPIResource returns the entry as a resource. The returned bool is false if
+// the entry isn't a resource, which is the case for singletons (e.g. the "pods"
+// directory) and for entries with an unknown schema.
+func toAPIResource(e plugin.Entry) (apitypes.Resource, bool) {
+	schema, err := plugin.Schema(e)
+	if err != nil || schema == nil || schema.Singleton {
+		return apitypes.Resource{}, false
+	}
+	return apitypes.Resource{
+		Kind: schema.Label,
+		Name: plugin.CName(e),
+	}, true
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+)
+
+// cancelTimeout is how long an interrupted command waits for the server to
+// cancel its in-flight requests before it exits anyway
+const cancelTimeout = 2 * time.Second
+
+// cancelOnInterrupt makes the command cancel its in-flight requests and
+// operations on the server (via the /cancel endpoint) when it's interrupted,
+// then exit. Otherwise, the server-side work (e.g. plugin script invocations
+// and cloud API calls) would keep running until it's done. The returned
+// function stops listening for interrupts; call it once the command's done.
+func cancelOnInterrupt() func() {
+	sigCh := make(chan os.Signal, 1)
+	doneCh := make(chan struct{})
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigCh:
+			signal.Stop(sigCh)
+			cancelledCh := make(chan struct{})
+			go func() {
+				defer close(cancelledCh)
+				// There's nothing left to do if the cancellation fails; the
+				// server's journal says why.
+				_, _ = cmdutil.NewClient().Cancel()
+			}()
+			select {
+			case <-cancelledCh:
+			case <-time.After(cancelTimeout):
+			}
+			// Follow the shell convention of exiting with 128 + the signal
+			os.Exit(128 + int(sig.(syscall.Signal)))
+		case <-doneCh:
+		}
+	}()
+	return func() {
+		signal.Stop(sigCh)
+		close(doneCh)
+	}
+}
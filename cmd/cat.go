@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/Benchkram/errz"
+	"github.com/mattn/go-isatty"
+	"github.com/puppetlabs/wash/api/client"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/cmd/internal/render"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/spf13/cobra"
+)
+
+func catCommand() *cobra.Command {
+	catCmd := &cobra.Command{
+		Use:   "cat [--raw] [<path>...]",
+		Short: "Prints the content of the entries at the specified paths",
+		Long: `Prints the content of the entries at the specified paths. If the entry's content type is known
+(JSON, YAML, CSV, TSV or logs), then the content is pretty-printed: JSON is indented, YAML keys are
+colored, CSV and TSV are printed as tables, and log lines are colored by their level. The content
+type is the entry's content_type attribute, or it's inferred from the entry's extension (e.g.
+.json) if the entry doesn't have one. Content that isn't valid for its type is printed as-is.
+
+Content's only pretty-printed if stdout is a terminal so that piping cat's output to another
+command gets the raw content. Specify the --raw flag to always print the raw content.
+
+Paths outside of Wash are read like normal files, and cat reads stdin if no paths are specified.
+
+<path> can be a glob pattern (quote it so that your shell doesn't expand it), e.g.
+'gcp/*/storage/*/*.json', in which case each matching entry's content is printed.`,
+		RunE: toRunE(catMain),
+	}
+	catCmd.Flags().Bool("raw", false, "Print the raw content")
+	return completeWith(catCmd, plugin.ReadAction(), "1-")
+}
+
+func catMain(cmd *cobra.Command, args []string) exitCode {
+	raw, err := cmd.Flags().GetBool("raw")
+	if err != nil {
+		panic(err.Error())
+	}
+	if !raw && !isatty.IsTerminal(os.Stdout.Fd()) && !isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+		raw = true
+	}
+
+	if len(args) == 0 {
+		if _, err := io.Copy(cmdutil.Stdout, os.Stdin); err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		return exitCode{0}
+	}
+
+	conn := cmdutil.NewClient()
+	paths, err := cmdutil.ExpandPaths(conn, args)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	var errs []error
+	for _, path := range paths {
+		if err := cat(conn, path, raw); err != nil {
+			cmdutil.ErrPrintf("%v: %v\n", path, err)
+			errs = append(errs, err)
+		}
+	}
+	return exitCodeForAll(errs, len(paths))
+}
+
+// cat prints the content of the entry at path. It renders the content unless
+// raw is true.
+func cat(conn client.Client, path string, raw bool) error {
+	content, contentType, name, err := readContent(conn, path)
+	if err != nil {
+		return err
+	}
+	if !raw {
+		if r, ok := render.For(contentType, name); ok {
+			var rendered bytes.Buffer
+			if err := r(&rendered, content); err == nil {
+				content = rendered.Bytes()
+			}
+		}
+	}
+	_, err = cmdutil.Stdout.Write(content)
+	return err
+}
+
+// readContent returns the content of the entry at path, its content type and
+// its name. Paths outside of Wash are read from the local filesystem.
+func readContent(conn client.Client, path string) ([]byte, string, string, error) {
+	entry, err := conn.Info(path)
+	if err != nil {
+		var errObj *apitypes.ErrorObj
+		if errors.As(err, &errObj) && errObj.Kind == apitypes.NonWashPath {
+			content, err := ioutil.ReadFile(path)
+			return content, "", path, err
+		}
+		return nil, "", "", err
+	}
+	if !entry.Supports(plugin.ReadAction()) {
+		return nil, "", "", errors.New("the entry does not support the read action")
+	}
+
+	content, err := conn.Read(path)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer func() { errz.Log(content.Close()) }()
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return data, entry.Attributes.ContentType(), entry.Name, nil
+}
@@ -35,7 +35,7 @@ func clearMain(cmd *cobra.Command, args []string) exitCode {
 	cleared, err := conn.Clear(path)
 	if err != nil {
 		cmdutil.ErrPrintf("%v\n", err)
-		return exitCode{1}
+		return exitCodeFor(err)
 	}
 
 	if verbose {
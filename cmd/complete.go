@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/puppetlabs/wash/api/client"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/spf13/cobra"
+)
+
+// The annotations that configure how the Wash shell completes a command's
+// args. completeActionAnnotation is the action that the completed entries
+// must support. completePathsAnnotation is the position of the command's
+// path arg, or of its first path arg if it's followed by "-" (e.g. signal's
+// "2-"), in which case every positional arg from there on is a path.
+const (
+	completeActionAnnotation = "wash_complete_action"
+	completePathsAnnotation  = "wash_complete_paths"
+)
+
+// completeWith configures the Wash shell to complete cmd's path args with the
+// entries that support action
+func completeWith(cmd *cobra.Command, action plugin.Action, paths string) *cobra.Command {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[completeActionAnnotation] = action.Name
+	cmd.Annotations[completePathsAnnotation] = paths
+	return cmd
+}
+
+func completeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:    "__complete <command> [<arg>...]",
+		Hidden: true,
+		Short:  "Filters the Wash shell's completions of a command's next arg",
+		Long: `Reads the shell's candidates for the command's next arg from stdin, one per
+line, then prints the ones that the command can use. <arg>... are the args
+that precede the completed one.
+
+If the completed arg is one of the command's paths, then the candidates are
+filtered by their entries' capabilities: an entry's printed if it supports
+the command's action, or if it's a parent so that its children can be
+completed. Candidates that aren't entries are always printed.`,
+		Args:               cobra.MinimumNArgs(1),
+		DisableFlagParsing: true,
+		RunE:               toRunE(completeMain),
+	}
+}
+
+func completeMain(cmd *cobra.Command, args []string) exitCode {
+	action := ""
+	if target, _, err := cmd.Root().Find(args[:1]); err == nil && target != cmd.Root() {
+		if isCompletedPath(target.Annotations[completePathsAnnotation], positionOf(target, args[1:])) {
+			action = target.Annotations[completeActionAnnotation]
+		}
+	}
+
+	var conn client.Client
+	if action != "" {
+		conn = cmdutil.NewClient()
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		candidate := scanner.Text()
+		if action == "" || completes(conn, candidate, action) {
+			cmdutil.Println(candidate)
+		}
+	}
+	return exitCode{0}
+}
+
+// positionOf returns the position of the positional arg that follows args.
+// The values of cmd's flags aren't positional args.
+func positionOf(cmd *cobra.Command, args []string) int {
+	position := 1
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return position + len(args) - i - 1
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			position++
+			continue
+		}
+		if strings.Contains(arg, "=") {
+			continue
+		}
+		flag := cmd.Flags().ShorthandLookup(arg[len(arg)-1:])
+		if strings.HasPrefix(arg, "--") {
+			flag = cmd.Flags().Lookup(arg[2:])
+		}
+		if flag != nil && flag.NoOptDefVal == "" {
+			// The flag's value is the next arg
+			i++
+		}
+	}
+	return position
+}
+
+// isCompletedPath returns true if the positional arg at position is one of the
+// command's path args, as described by its completePathsAnnotation
+func isCompletedPath(paths string, position int) bool {
+	if paths == "" {
+		return false
+	}
+	first, err := strconv.Atoi(strings.TrimSuffix(paths, "-"))
+	if err != nil {
+		panic("invalid " + completePathsAnnotation + " annotation " + paths)
+	}
+	if strings.HasSuffix(paths, "-") {
+		return position >= first
+	}
+	return position == first
+}
+
+// completes returns true if the candidate's entry supports action or list.
+// Candidates whose capabilities can't be retrieved (e.g. paths outside of
+// Wash) are left for the command to handle.
+func completes(conn client.Client, candidate string, action string) bool {
+	capabilities, err := conn.Capabilities(candidate)
+	if err != nil {
+		return true
+	}
+	for _, supported := range capabilities.Actions {
+		if supported == action || supported == plugin.ListAction().Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/puppetlabs/wash/cmd/internal/demo"
+	"github.com/puppetlabs/wash/cmd/internal/server"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/puppetlabs/wash/plugin/wash"
+	"github.com/puppetlabs/wash/sftp"
+	"github.com/spf13/cobra"
+)
+
+func demoCommand() *cobra.Command {
+	demoCmd := &cobra.Command{
+		Use:   "demo [--install <dir>]",
+		Short: "Explores Wash with example plugins",
+		Long: `Starts a Wash shell with only the example plugins mounted, so that you can try Wash without any
+cloud accounts. The example plugins are external plugins (shell scripts) that are bundled with
+Wash:
+  datacenter   a fake datacenter with VMs that you can exec on, signal and tail, and files
+               that you can read and write
+  flaky        a slow and unreliable plugin that shows off Wash's timeouts, retries and errors
+  clock        streams the time, and lists its children as they're generated
+
+Run 'help <plugin>' in the shell for what to try with each plugin. The plugins' state (e.g. which
+VMs are stopped) is discarded when the shell exits.
+
+Specify --install to write the example plugins' scripts to <dir> instead of starting a shell.
+They're a good starting point for writing your own external plugins.`,
+		Args:   cobra.NoArgs,
+		PreRun: bindServerArgs,
+		RunE:   toServerRunE(demoMain),
+	}
+	addServerArgs(demoCmd, "warn")
+	demoCmd.Flags().String("install", "", "Write the example plugins' scripts to the given directory, then exit")
+	return demoCmd
+}
+
+func demoMain(cmd *cobra.Command, args []string) exitCode {
+	installDir, err := cmd.Flags().GetString("install")
+	if err != nil {
+		panic(err.Error())
+	}
+	if installDir != "" {
+		specs, err := demo.Install(installDir)
+		if err != nil {
+			cmdutil.ErrPrintf("Could not install the example plugins: %v\n", err)
+			return exitCode{1}
+		}
+		for _, spec := range specs {
+			cmdutil.Println(spec.Path())
+		}
+		return exitCode{0}
+	}
+
+	plugin.InitInteractive(true)
+	return runShell(cmd, "", demoServerOptsFor(cmd), `Welcome to the Wash demo!
+  The example plugins are mounted at `+strings.Join(demo.Plugins(), ", ")+`.
+  Run 'help <plugin>' to see what to try with each of them, e.g. 'help datacenter'.
+  See commands run with wash via 'whistory', and logs with 'whistory <id>'.
+Try 'help'`)
+}
+
+// demoServerOptsFor returns a function that installs the example plugins in the
+// shell's run space, and returns the server options for them. Only the wash
+// plugin and the example plugins are loaded. The options that'd outlive the
+// demo (e.g. the handoff or persisting tuned limits) are disabled.
+func demoServerOptsFor(cmd *cobra.Command) func(rundir string) (map[string]plugin.Root, server.Opts, error) {
+	return func(rundir string) (map[string]plugin.Root, server.Opts, error) {
+		_, opts, err := serverOptsFor(cmd)
+		if err != nil {
+			return nil, server.Opts{}, err
+		}
+		specs, err := demo.Install(filepath.Join(rundir, "plugins"))
+		if err != nil {
+			return nil, server.Opts{}, err
+		}
+
+		plugins := map[string]plugin.Root{"wash": &wash.Root{}}
+		config := map[string]map[string]interface{}{"wash": {}}
+		for _, spec := range specs {
+			root, err := spec.Load()
+			if err != nil {
+				return nil, server.Opts{}, err
+			}
+			plugins[spec.Name()] = root
+			config[spec.Name()] = map[string]interface{}{}
+		}
+
+		opts.PluginConfig = config
+		opts.ExternalPlugins = specs
+		opts.PersistLimit = nil
+		opts.PersistFeature = nil
+		opts.Handoff = false
+		opts.SFTP = sftp.Opts{}
+		return plugins, opts, nil
+	}
+}
@@ -1,10 +1,22 @@
 package cmd
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/mattn/go-isatty"
+	"github.com/puppetlabs/wash/api/client"
 	apitypes "github.com/puppetlabs/wash/api/types"
 	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/plugin"
 	"github.com/spf13/cobra"
 )
 
@@ -16,9 +28,41 @@ func execCommand() *cobra.Command {
 		Short:   "Executes the given command on the indicated target",
 		Long: `For a Wash resource (specified by <path>) that implements the ability to execute a command, run the
 specified command and arguments. The results will be forwarded from the target on stdout, stderr,
-and exit code.`,
+and exit code.
+
+If stdin isn't a terminal, then it's forwarded to the command until it reaches EOF. Use --no-stdin
+to prevent that, e.g. when wash exec is invoked in a loop that reads from stdin.
+
+Use --env and --cwd to set environment variables and the working directory on the remote side.
+Not every resource supports them; wash exec fails instead of ignoring them if the resource doesn't.
+
+If the Wash server has an exec policy, then you're shown its banner and asked to consent to it
+before the command's executed, and you're asked for a justification if the policy requires one.
+Use --accept-banner and --justification to supply them when wash exec isn't run interactively.
+
+<path> can be a glob pattern (quote it so that your shell doesn't expand it), e.g.
+'docker/containers/web-*' or 'aws/*/resources/ec2/instances/**'. The command is executed on each
+matching resource in turn, after a '===> <path> <===' header, and wash exec exits with 7 if it
+failed on any of them.
+
+Use --report <file> to also save a report of the results (each target's status, exit code,
+duration and the SHA-256 digest of its output) for dashboards or CI gates. The report's format
+is inferred from the file's extension (.csv for CSV, .xml for JUnit XML, JSON otherwise), or it
+can be set with --report-format.`,
 		Example: `exec docker/containers/example_1 printenv USER
-  print the USER environment variable from a Docker container instance`,
+  print the USER environment variable from a Docker container instance
+
+cat script.sh | exec ssh/host bash -s
+  run a local script on a remote host
+
+exec --env FOO=1 --cwd /srv docker/containers/example_1 ls
+  list the contents of /srv in a Docker container instance, with FOO set to 1
+
+exec 'docker/containers/web-*' uptime
+  print the uptime of each Docker container whose name starts with web-
+
+exec --report uptime.xml 'docker/containers/web-*' uptime
+  same as above, but also save a JUnit XML report of the results`,
 		Args: cobra.MinimumNArgs(2),
 		RunE: toRunE(execMain),
 	}
@@ -26,11 +70,20 @@ and exit code.`,
 	// Don't interpret any flags after the first positional argument. Those should
 	// instead get interpreted by this command as normal args, not flags.
 	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().BoolP("no-stdin", "n", false, "Don't forward stdin to the command")
+	execCmd.Flags().StringArrayP("env", "e", nil, "Set an environment variable (as KEY=VALUE) for the command. Can be repeated")
+	execCmd.Flags().String("cwd", "", "Run the command in this working directory")
+	execCmd.Flags().Bool("accept-banner", false, "Consent to the exec policy's banner without being prompted")
+	execCmd.Flags().StringP("justification", "j", "", "Set why you're running the command, if the exec policy requires it")
+	execCmd.Flags().String("report", "", "Save a report of the results to this file")
+	execCmd.Flags().String("report-format", "", "The report's format: json, csv or junit. Inferred from the report's extension by default")
 
-	return execCmd
+	return completeWith(execCmd, plugin.ExecAction(), "1")
 }
 
-func printPackets(pkts <-chan apitypes.ExecPacket) (int, error) {
+// printPackets prints the exec's output. If output is set, then the output's
+// also written to it.
+func printPackets(pkts <-chan apitypes.ExecPacket, output io.Writer) (int, error) {
 	exit := 0
 	foundErroredPacket := false
 
@@ -60,6 +113,9 @@ func printPackets(pkts <-chan apitypes.ExecPacket) (int, error) {
 		case apitypes.Stderr:
 			fmt.Fprint(cmdutil.Stderr, pkt.Data)
 		}
+		if output != nil && (pkt.TypeField == apitypes.Stdout || pkt.TypeField == apitypes.Stderr) {
+			fmt.Fprint(output, pkt.Data)
+		}
 	}
 
 	if foundErroredPacket {
@@ -69,6 +125,22 @@ func printPackets(pkts <-chan apitypes.ExecPacket) (int, error) {
 	return exit, nil
 }
 
+// parseEnv parses the KEY=VALUE pairs passed to --env
+func parseEnv(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	env := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		segments := strings.SplitN(pair, "=", 2)
+		if len(segments) != 2 || segments[0] == "" {
+			return nil, fmt.Errorf("invalid --env value %q: expected KEY=VALUE", pair)
+		}
+		env[segments[0]] = segments[1]
+	}
+	return env, nil
+}
+
 func execMain(cmd *cobra.Command, args []string) exitCode {
 	var path string
 	var command string
@@ -78,18 +150,200 @@ func execMain(cmd *cobra.Command, args []string) exitCode {
 	command = args[1]
 	commandArgs = args[2:]
 
-	conn := cmdutil.NewClient()
+	noStdin, err := cmd.Flags().GetBool("no-stdin")
+	if err != nil {
+		panic(err.Error())
+	}
 
-	ch, err := conn.Exec(path, command, commandArgs, apitypes.ExecOptions{})
+	env, err := cmd.Flags().GetStringArray("env")
 	if err != nil {
+		panic(err.Error())
+	}
+	cwd, err := cmd.Flags().GetString("cwd")
+	if err != nil {
+		panic(err.Error())
+	}
+
+	acceptBanner, err := cmd.Flags().GetBool("accept-banner")
+	if err != nil {
+		panic(err.Error())
+	}
+	justification, err := cmd.Flags().GetString("justification")
+	if err != nil {
+		panic(err.Error())
+	}
+	reportPath, err := cmd.Flags().GetString("report")
+	if err != nil {
+		panic(err.Error())
+	}
+	reportFormat, err := cmd.Flags().GetString("report-format")
+	if err != nil {
+		panic(err.Error())
+	}
+	if reportPath != "" {
+		if reportFormat, err = cmdutil.ReportFormatFor(reportPath, reportFormat); err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCode{exitGeneric}
+		}
+	}
+
+	opts := apitypes.ExecOptions{Cwd: cwd, Consented: acceptBanner, Justification: justification}
+	if opts.Env, err = parseEnv(env); err != nil {
 		cmdutil.ErrPrintf("%v\n", err)
-		return exitCode{1}
+		return exitCode{exitGeneric}
+	}
+	if !noStdin && !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		opts.Stdin = os.Stdin
+	}
+
+	conn := cmdutil.NewClient()
+
+	paths := []string{path}
+	if apitypes.IsGlob(path) {
+		if paths, err = cmdutil.ExpandPaths(conn, paths); err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+	}
+	if len(paths) > 1 && opts.Stdin != nil {
+		cmdutil.ErrPrintf("%v matches %v entries, but stdin can only be forwarded to one of them. Use --no-stdin.\n", path, len(paths))
+		return exitCode{exitGeneric}
 	}
 
-	code, err := printPackets(ch)
+	report := cmdutil.ExecReport{Command: command, Args: commandArgs, Start: time.Now()}
+	if report.Args == nil {
+		report.Args = []string{}
+	}
+	code := exitCode{0}
+	for i, p := range paths {
+		if len(paths) > 1 {
+			if i > 0 {
+				cmdutil.Println()
+			}
+			cmdutil.Println("===>", p, "<===")
+		}
+		var result cmdutil.ExecResult
+		code = execOn(conn, p, command, commandArgs, &opts, &result)
+		report.Results = append(report.Results, result)
+	}
+	if reportPath != "" {
+		if err := cmdutil.SaveExecReport(reportPath, reportFormat, report); err != nil {
+			cmdutil.ErrPrintf("Could not save the report to %v: %v\n", reportPath, err)
+			return exitCode{exitGeneric}
+		}
+	}
+	if len(paths) > 1 && report.Failures() > 0 {
+		return exitCode{exitPartialFailure}
+	}
+	return code
+}
+
+// outputDigest digests an exec's output for its ExecResult
+type outputDigest struct {
+	hash  hash.Hash
+	bytes int64
+}
+
+func (d *outputDigest) Write(p []byte) (int, error) {
+	d.bytes += int64(len(p))
+	return d.hash.Write(p)
+}
+
+// execOn executes the command on path and records its result. If the exec
+// policy requires consent or a justification, then the user's prompted for it
+// and opts is updated so that it's supplied for later execs.
+func execOn(conn client.Client, path string, command string, args []string, opts *apitypes.ExecOptions, result *cmdutil.ExecResult) exitCode {
+	start := time.Now()
+	digest := &outputDigest{hash: sha256.New()}
+	defer func() {
+		result.Path = path
+		result.Duration = time.Since(start)
+		result.OutputBytes = digest.bytes
+		result.OutputDigest = "sha256:" + hex.EncodeToString(digest.hash.Sum(nil))
+	}()
+
+	ch, err := conn.Exec(path, command, args, *opts)
+	for err != nil {
+		if !satisfyExecPolicy(err, opts) {
+			cmdutil.ErrPrintf("%v\n", err)
+			result.Status, result.Error = cmdutil.ExecErrored, err.Error()
+			return exitCodeFor(err)
+		}
+		ch, err = conn.Exec(path, command, args, *opts)
+	}
+
+	code, err := printPackets(ch, digest)
 	if err != nil {
-		return exitCode{1}
+		// The exec endpoint sent streaming errors
+		result.Status, result.Error = cmdutil.ExecErrored, err.Error()
+		return exitCode{exitPluginError}
 	}
 
+	result.ExitCode = code
+	if code == 0 {
+		result.Status = cmdutil.ExecPassed
+	} else {
+		result.Status = cmdutil.ExecFailed
+	}
 	return exitCode{code}
 }
+
+// satisfyExecPolicy prompts the user for what the exec policy requires if err
+// is an exec policy error. It returns true if the exec should be retried with
+// the updated opts, which isn't the case if the user can't be prompted or if
+// they declined.
+func satisfyExecPolicy(err error, opts *apitypes.ExecOptions) bool {
+	var errObj *apitypes.ErrorObj
+	if !errors.As(err, &errObj) || opts.Stdin != nil || !canPrompt() {
+		return false
+	}
+	switch errObj.Kind {
+	case apitypes.ExecConsentRequired:
+		if opts.Consented {
+			return false
+		}
+		banner, _ := errObj.Fields["banner"].(string)
+		cmdutil.ErrPrintf("%v\n", strings.TrimSuffix(banner, "\n"))
+		answer, err := prompt("Do you consent? [y/N] ")
+		if answer = strings.ToLower(answer); err != nil || (answer != "y" && answer != "yes") {
+			return false
+		}
+		opts.Consented = true
+	case apitypes.ExecJustificationRequired:
+		if opts.Justification != "" {
+			return false
+		}
+		if reason, _ := errObj.Fields["reason"].(string); reason != "" {
+			cmdutil.ErrPrintf("%v\n", reason)
+		}
+		justification, err := prompt("Justification: ")
+		if err != nil || justification == "" {
+			return false
+		}
+		opts.Justification = justification
+	default:
+		return false
+	}
+	return true
+}
+
+// canPrompt returns true if the user can be prompted for input on stdin
+func canPrompt() bool {
+	isTerminal := func(f *os.File) bool {
+		return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	}
+	return isTerminal(os.Stdin) && isTerminal(os.Stderr)
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// prompt prints msg to stderr, then returns the (trimmed) line that the user
+// entered
+func prompt(msg string) (string, error) {
+	cmdutil.ErrPrintf("%v", msg)
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
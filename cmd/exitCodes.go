@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+)
+
+// These exit codes are a stable contract so that scripts wrapping wash can
+// branch on the class of failure. Commands that run a command on an entry
+// (e.g. wash exec) exit with that command's exit code instead.
+const (
+	// exitGeneric is used for failures that don't fit any of the other
+	// classes, e.g. invalid arguments.
+	exitGeneric          = 1
+	exitNotFound         = 3
+	exitPermissionDenied = 4
+	exitPluginError      = 5
+	exitTimeout          = 6
+	// exitPartialFailure is used when a command that operates on multiple
+	// entries failed on some (but not all) of them.
+	exitPartialFailure = 7
+)
+
+// exitCodeFor returns the exit code for the class of failure that err
+// represents
+func exitCodeFor(err error) exitCode {
+	var errObj *apitypes.ErrorObj
+	if errors.As(err, &errObj) {
+		switch errObj.Kind {
+		case apitypes.EntryNotFound,
+			apitypes.PluginDoesNotExist,
+			apitypes.LimitNotFound,
+			apitypes.OutOfBounds,
+			apitypes.JournalUnavailable,
+			apitypes.OperationNotFound,
+			apitypes.PinNotFound,
+			apitypes.CredentialNotFound,
+			apitypes.FeatureNotFound:
+			return exitCode{exitNotFound}
+		case apitypes.PermissionDenied,
+			apitypes.ExecConsentRequired,
+			apitypes.ExecJustificationRequired,
+			apitypes.ExecDenied,
+			apitypes.Unauthorized,
+			apitypes.DevModeRequired,
+			apitypes.CredentialForbidden:
+			return exitCode{exitPermissionDenied}
+		case apitypes.Timeout:
+			return exitCode{exitTimeout}
+		case apitypes.ErroredAction,
+			apitypes.UnknownError,
+			apitypes.StreamingError,
+			apitypes.DuplicateCName,
+			apitypes.PluginQuarantined:
+			return exitCode{exitPluginError}
+		default:
+			return exitCode{exitGeneric}
+		}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return exitCode{exitNotFound}
+	case errors.Is(err, os.ErrPermission):
+		return exitCode{exitPermissionDenied}
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return exitCode{exitTimeout}
+	default:
+		return exitCode{exitGeneric}
+	}
+}
+
+// exitCodeForAll returns the exit code for a command that operated on total
+// entries and failed on some of them with errs. It's exitPartialFailure if the
+// command succeeded on at least one entry. Otherwise, it's the exit code of
+// the first error.
+func exitCodeForAll(errs []error, total int) exitCode {
+	switch {
+	case len(errs) == 0:
+		return exitCode{0}
+	case len(errs) < total:
+		return exitCode{exitPartialFailure}
+	default:
+		return exitCodeFor(errs[0])
+	}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/spf13/cobra"
+)
+
+func featuresCommand() *cobra.Command {
+	featuresCmd := &cobra.Command{
+		Use:   "features [<name> on|off]",
+		Short: "Prints or toggles the Wash server's feature flags",
+		Long: `Prints the Wash server's feature flags, which gate experimental behaviors. If <name> and on or
+off are specified, then the named flag is enabled or disabled. Use --plugin to only toggle it for
+that plugin. The new value takes effect immediately. Use --persist to also write the new value to
+the config so that it's used the next time the server starts.`,
+		Args: cobra.RangeArgs(0, 2),
+		RunE: toRunE(featuresMain),
+	}
+	featuresCmd.Flags().String("plugin", "", "Only toggle the flag for this plugin")
+	featuresCmd.Flags().Bool("persist", false, "Persist the new value to the config")
+	return featuresCmd
+}
+
+func featuresMain(cmd *cobra.Command, args []string) exitCode {
+	plugin, err := cmd.Flags().GetString("plugin")
+	if err != nil {
+		panic(err.Error())
+	}
+	persist, err := cmd.Flags().GetBool("persist")
+	if err != nil {
+		panic(err.Error())
+	}
+
+	conn := cmdutil.NewClient()
+	switch len(args) {
+	case 0:
+		fs, err := conn.Features()
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		cmdutil.Print(formatFeatures(fs))
+	case 1:
+		cmdutil.ErrPrintf("Please specify whether the %v feature flag should be on or off\n", args[0])
+		return exitCode{1}
+	default:
+		var enabled bool
+		switch args[1] {
+		case "on":
+			enabled = true
+		case "off":
+			enabled = false
+		default:
+			cmdutil.ErrPrintf("%v is not a valid feature flag value: it must be on or off\n", args[1])
+			return exitCode{1}
+		}
+		f, err := conn.SetFeature(args[0], plugin, enabled, persist)
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		cmdutil.Print(formatFeatures([]apitypes.Feature{f}))
+	}
+	return exitCode{0}
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
+func formatFeatures(fs []apitypes.Feature) string {
+	headers := []cmdutil.ColumnHeader{
+		{ShortName: "name", FullName: "NAME"},
+		{ShortName: "enabled", FullName: "ENABLED"},
+		{ShortName: "overrides", FullName: "OVERRIDES"},
+		{ShortName: "description", FullName: "DESCRIPTION"},
+	}
+	table := make([][]string, len(fs))
+	for i, f := range fs {
+		plugins := make([]string, 0, len(f.Overrides))
+		for plugin := range f.Overrides {
+			plugins = append(plugins, plugin)
+		}
+		sort.Strings(plugins)
+		overrides := make([]string, len(plugins))
+		for j, plugin := range plugins {
+			overrides[j] = fmt.Sprintf("%v=%v", plugin, onOff(f.Overrides[plugin]))
+		}
+		table[i] = []string{f.Name, onOff(f.Enabled), strings.Join(overrides, ","), f.Description}
+	}
+	return cmdutil.NewTableWithHeaders(headers, table).Format()
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"strings"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/spf13/cobra"
+)
+
+// helpCommand replaces Cobra's help command so that it can also print the
+// plugins' help documents
+func helpCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "help [<command> | plugin <name> | errors]",
+		Short: "Help about any command or plugin",
+		Long: `Prints the help of the specified command. 'wash help plugin <name>' prints the help document
+that the named plugin supplied, which is also readable as the .help file at the plugin's root.
+'wash help errors' prints the catalog of the error codes that prefix Wash's errors.`,
+		RunE: toRunE(helpMain),
+	}
+}
+
+func helpMain(cmd *cobra.Command, args []string) exitCode {
+	if len(args) > 0 && args[0] == "plugin" {
+		if len(args) != 2 {
+			cmdutil.ErrPrintf("Please specify the plugin's name, e.g. 'wash help plugin docker'\n")
+			return exitCode{1}
+		}
+		help, err := cmdutil.NewClient().PluginHelp(args[1])
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		if !strings.HasSuffix(help, "\n") {
+			help += "\n"
+		}
+		cmdutil.Print(help)
+		return exitCode{0}
+	}
+
+	if len(args) == 1 && args[0] == "errors" {
+		cmdutil.Print(formatErrorCatalog(apitypes.ErrorCatalog()))
+		return exitCode{0}
+	}
+
+	c, _, err := cmd.Root().Find(args)
+	if c == nil || err != nil {
+		cmdutil.ErrPrintf("Unknown help topic %#q\n", args)
+		_ = cmd.Root().Usage()
+		return exitCode{1}
+	}
+	c.InitDefaultHelpFlag()
+	if err := c.Help(); err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCode{1}
+	}
+	return exitCode{0}
+}
+
+func formatErrorCatalog(catalog []apitypes.ErrorCatalogEntry) string {
+	headers := []cmdutil.ColumnHeader{
+		{ShortName: "code", FullName: "CODE"},
+		{ShortName: "kind", FullName: "KIND"},
+		{ShortName: "summary", FullName: "SUMMARY"},
+	}
+	table := make([][]string, len(catalog))
+	for i, entry := range catalog {
+		table[i] = []string{entry.Code, entry.Kind, entry.Summary}
+	}
+	return cmdutil.NewTableWithHeaders(headers, table).Format()
+}
@@ -3,12 +3,14 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Benchkram/errz"
 	"github.com/kr/logfmt"
+	"github.com/puppetlabs/wash/activity"
 	cmdutil "github.com/puppetlabs/wash/cmd/util"
 	"github.com/spf13/cobra"
 )
@@ -25,9 +27,25 @@ func historyCommand() *cobra.Command {
 		RunE: toRunE(historyMain),
 	}
 	historyCmd.Flags().BoolP("follow", "f", false, "Follow new updates")
+	historyCmd.AddCommand(historyExecCommand())
 	return historyCmd
 }
 
+func historyExecCommand() *cobra.Command {
+	execCmd := &cobra.Command{
+		Use:   "exec <id> [--replay-output]",
+		Short: "Prints the transcripts of the commands executed by a particular item in history",
+		Long: `Prints the transcripts of the commands that were executed on entries by the history item with
+the given <id>. Each transcript includes the command's timestamped, interleaved stdout and stderr
+output, and its exit code. Use --replay-output to play back the commands' output with its original
+timing instead.`,
+		Args: cobra.ExactArgs(1),
+		RunE: toRunE(historyExecMain),
+	}
+	execCmd.Flags().Bool("replay-output", false, "Play back the commands' output with its original timing")
+	return execCmd
+}
+
 type logFmtLine struct {
 	Time, Level, Msg string
 }
@@ -85,6 +103,99 @@ func printJournalEntry(index string, follow bool) error {
 	return nil
 }
 
+func printTranscript(index int, transcript activity.ExecTranscript) {
+	cmdutil.Printf(
+		"Exec %v: %v on %v\n",
+		index,
+		strings.Join(append([]string{transcript.Cmd}, transcript.Args...), " "),
+		transcript.Path,
+	)
+	if transcript.Justification != "" {
+		cmdutil.Printf("Justification: %v\n", transcript.Justification)
+	}
+	for _, event := range transcript.Events {
+		timeStr := event.Time.Format(time.StampMilli)
+		var msg string
+		switch event.Type {
+		case activity.TranscriptStart:
+			msg = "started"
+		case activity.TranscriptExit:
+			if event.Err != "" {
+				msg = fmt.Sprintf("failed to get the exit code: %v", event.Err)
+			} else {
+				msg = fmt.Sprintf("exited with %v after %v", event.ExitCode, cmdutil.FormatDuration(event.Time.Sub(transcript.Start())))
+			}
+		default:
+			msg = event.Type + ": " + strings.TrimSuffix(event.Data, "\n")
+		}
+		lines := strings.Split(msg, "\n")
+		cmdutil.Println(timeStr, lines[0])
+		prefix := strings.Repeat(" ", len(timeStr))
+		for _, l := range lines[1:] {
+			cmdutil.Println(prefix, l)
+		}
+	}
+}
+
+// replayTranscript writes the transcript's stdout and stderr output to
+// cmdutil.Stdout and cmdutil.Stderr, respectively. It waits between writes
+// so that the output is played back with its original timing.
+func replayTranscript(transcript activity.ExecTranscript) {
+	last := transcript.Start()
+	for _, event := range transcript.Events {
+		var w io.Writer
+		switch event.Type {
+		case activity.TranscriptStdout:
+			w = cmdutil.Stdout
+		case activity.TranscriptStderr:
+			w = cmdutil.Stderr
+		default:
+			continue
+		}
+		if delay := event.Time.Sub(last); delay > 0 {
+			time.Sleep(delay)
+		}
+		last = event.Time
+		fmt.Fprint(w, event.Data)
+	}
+}
+
+func historyExecMain(cmd *cobra.Command, args []string) exitCode {
+	replayOutput, err := cmd.Flags().GetBool("replay-output")
+	if err != nil {
+		panic(err.Error())
+	}
+	idx, err := strconv.Atoi(args[0])
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	conn := cmdutil.NewClient()
+	// Translate from 1-indexing for history entries
+	transcripts, err := conn.ExecTranscripts(idx - 1)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+	if len(transcripts) == 0 {
+		cmdutil.ErrPrintf("History item %v did not execute any commands\n", idx)
+		return exitCode{1}
+	}
+
+	for i, transcript := range transcripts {
+		if replayOutput {
+			replayTranscript(transcript)
+			continue
+		}
+		if i > 0 {
+			cmdutil.Println()
+		}
+		printTranscript(i+1, transcript)
+	}
+	return exitCode{0}
+}
+
 func printHistory(follow bool) error {
 	conn := cmdutil.NewClient()
 	history, err := conn.History(follow)
@@ -117,7 +228,7 @@ func historyMain(cmd *cobra.Command, args []string) exitCode {
 
 	if err != nil {
 		cmdutil.ErrPrintf("%v\n", err)
-		return exitCode{1}
+		return exitCodeFor(err)
 	}
 
 	return exitCode{0}
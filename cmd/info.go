@@ -11,11 +11,16 @@ func infoCommand() *cobra.Command {
 		Use:     use + " <path>",
 		Aliases: aliases,
 		Short:   "Prints the entry's info at the specified path",
-		Long:    `Print all info Wash has about the specified path, including filesystem attributes and metadata.`,
-		Args:    cobra.ExactArgs(1),
-		RunE:    toRunE(infoMain),
+		Long: `Print all info Wash has about the specified path, including filesystem attributes and metadata.
+
+With --capabilities, print what the entry supports instead: its actions, the
+attributes it sets, how its list, read and metadata results are cached (and
+whether they're cached right now), and the plugin it belongs to.`,
+		Args: cobra.ExactArgs(1),
+		RunE: toRunE(infoMain),
 	}
 	infoCmd.Flags().StringP("output", "o", "json", "Set the output format (json or yaml)")
+	infoCmd.Flags().Bool("capabilities", false, "Print the entry's capabilities")
 	return infoCmd
 }
 
@@ -26,6 +31,11 @@ func infoMain(cmd *cobra.Command, args []string) exitCode {
 		panic(err.Error())
 	}
 
+	capabilities, err := cmd.Flags().GetBool("capabilities")
+	if err != nil {
+		panic(err.Error())
+	}
+
 	marshaller, err := cmdutil.NewMarshaller(output)
 	if err != nil {
 		cmdutil.ErrPrintf(err.Error())
@@ -34,16 +44,32 @@ func infoMain(cmd *cobra.Command, args []string) exitCode {
 
 	conn := cmdutil.NewClient()
 
-	entry, err := conn.Info(path)
+	if capabilities {
+		result, err := conn.Capabilities(path)
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		marshalled, err := marshaller.Marshal(result)
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		cmdutil.Println(marshalled)
+		return exitCode{0}
+	}
+
+	// Resolve the path so that the printed info includes its canonical path
+	entry, err := conn.Resolve(path)
 	if err != nil {
 		cmdutil.ErrPrintf("%v\n", err)
-		return exitCode{1}
+		return exitCodeFor(err)
 	}
 
 	marshalledEntry, err := marshaller.Marshal(entry)
 	if err != nil {
 		cmdutil.ErrPrintf("%v\n", err)
-		return exitCode{1}
+		return exitCodeFor(err)
 	}
 
 	cmdutil.Println(marshalledEntry)
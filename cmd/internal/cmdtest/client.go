@@ -2,9 +2,11 @@ package cmdtest
 
 import (
 	"io"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 
+	"github.com/puppetlabs/wash/activity"
 	"github.com/puppetlabs/wash/analytics"
 	apitypes "github.com/puppetlabs/wash/api/types"
 )
@@ -20,24 +22,144 @@ func (c *MockClient) Info(path string) (apitypes.Entry, error) {
 	return args.Get(0).(apitypes.Entry), args.Error(1)
 }
 
+// Capabilities mocks Client#Capabilities
+func (c *MockClient) Capabilities(path string) (apitypes.EntryCapabilities, error) {
+	args := c.Called(path)
+	return args.Get(0).(apitypes.EntryCapabilities), args.Error(1)
+}
+
+// Resolve mocks Client#Resolve
+func (c *MockClient) Resolve(path string) (apitypes.Entry, error) {
+	args := c.Called(path)
+	return args.Get(0).(apitypes.Entry), args.Error(1)
+}
+
+// Whereami mocks Client#Whereami
+func (c *MockClient) Whereami(path string) (apitypes.ResourceContext, error) {
+	args := c.Called(path)
+	return args.Get(0).(apitypes.ResourceContext), args.Error(1)
+}
+
+// PluginHelp mocks Client#PluginHelp
+func (c *MockClient) PluginHelp(name string) (string, error) {
+	args := c.Called(name)
+	return args.String(0), args.Error(1)
+}
+
+// TranslatePath mocks Client#TranslatePath
+func (c *MockClient) TranslatePath(path string) (apitypes.PathTranslation, error) {
+	args := c.Called(path)
+	return args.Get(0).(apitypes.PathTranslation), args.Error(1)
+}
+
+// TranslateWashPath mocks Client#TranslateWashPath
+func (c *MockClient) TranslateWashPath(washPath string) (apitypes.PathTranslation, error) {
+	args := c.Called(washPath)
+	return args.Get(0).(apitypes.PathTranslation), args.Error(1)
+}
+
 // List mocks Client#List
 func (c *MockClient) List(path string) ([]apitypes.Entry, error) {
 	args := c.Called(path)
 	return args.Get(0).([]apitypes.Entry), args.Error(1)
 }
 
+// ListFlat mocks Client#ListFlat
+func (c *MockClient) ListFlat(path string) ([]apitypes.Entry, error) {
+	args := c.Called(path)
+	return args.Get(0).([]apitypes.Entry), args.Error(1)
+}
+
+// ListWithTelemetry mocks Client#ListWithTelemetry
+func (c *MockClient) ListWithTelemetry(path string, flat bool) ([]apitypes.Entry, error) {
+	args := c.Called(path, flat)
+	return args.Get(0).([]apitypes.Entry), args.Error(1)
+}
+
+// ListWithMetadata mocks Client#ListWithMetadata
+func (c *MockClient) ListWithMetadata(path string, strict bool) ([]apitypes.Entry, error) {
+	args := c.Called(path, strict)
+	return args.Get(0).([]apitypes.Entry), args.Error(1)
+}
+
+// ListStream mocks Client#ListStream
+func (c *MockClient) ListStream(path string) (<-chan apitypes.ListPacket, error) {
+	args := c.Called(path)
+	return args.Get(0).(<-chan apitypes.ListPacket), args.Error(1)
+}
+
+// ListPage mocks Client#ListPage
+func (c *MockClient) ListPage(path string, page string) (apitypes.ListPage, error) {
+	args := c.Called(path, page)
+	return args.Get(0).(apitypes.ListPage), args.Error(1)
+}
+
+// Delete mocks Client#Delete
+func (c *MockClient) Delete(path string) error {
+	args := c.Called(path)
+	return args.Error(0)
+}
+
+// Signal mocks Client#Signal
+func (c *MockClient) Signal(path string, signal string) error {
+	args := c.Called(path, signal)
+	return args.Error(0)
+}
+
+// Run mocks Client#Run
+func (c *MockClient) Run(path string, action string, args []string, opts apitypes.RunOptions) (string, error) {
+	retArgs := c.Called(path, action, args, opts)
+	return retArgs.String(0), retArgs.Error(1)
+}
+
+// RunAsync mocks Client#RunAsync
+func (c *MockClient) RunAsync(path string, action string, args []string, opts apitypes.RunOptions) (apitypes.Operation, error) {
+	retArgs := c.Called(path, action, args, opts)
+	return retArgs.Get(0).(apitypes.Operation), retArgs.Error(1)
+}
+
+// Glob mocks Client#Glob
+func (c *MockClient) Glob(pattern string) ([]apitypes.Entry, error) {
+	args := c.Called(pattern)
+	return args.Get(0).([]apitypes.Entry), args.Error(1)
+}
+
 // Metadata mocks Client#Metadata
 func (c *MockClient) Metadata(path string) (map[string]interface{}, error) {
 	args := c.Called(path)
 	return args.Get(0).(map[string]interface{}), args.Error(1)
 }
 
+// MetadataHistory mocks Client#MetadataHistory
+func (c *MockClient) MetadataHistory(path string) ([]apitypes.MetadataSnapshot, error) {
+	args := c.Called(path)
+	return args.Get(0).([]apitypes.MetadataSnapshot), args.Error(1)
+}
+
+// Telemetry mocks Client#Telemetry
+func (c *MockClient) Telemetry(path string) (map[string]interface{}, error) {
+	args := c.Called(path)
+	return args.Get(0).(map[string]interface{}), args.Error(1)
+}
+
 // Stream mocks Client#Stream
 func (c *MockClient) Stream(path string) (io.ReadCloser, error) {
 	args := c.Called(path)
 	return args.Get(0).(io.ReadCloser), args.Error(1)
 }
 
+// ResumeStream mocks Client#ResumeStream
+func (c *MockClient) ResumeStream(path string, pos apitypes.StreamPosition) (io.ReadCloser, error) {
+	args := c.Called(path, pos)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+// Archive mocks Client#Archive
+func (c *MockClient) Archive(path string, format string) (io.ReadCloser, error) {
+	args := c.Called(path, format)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
 // Exec mocks Client#Exec
 func (c *MockClient) Exec(path string, command string, args []string, opts apitypes.ExecOptions) (<-chan apitypes.ExecPacket, error) {
 	margs := c.Called(path, command, args, opts)
@@ -56,12 +178,30 @@ func (c *MockClient) ActivityJournal(index int, follow bool) (io.ReadCloser, err
 	return args.Get(0).(io.ReadCloser), args.Error(1)
 }
 
+// ExecTranscripts mocks Client#ExecTranscripts
+func (c *MockClient) ExecTranscripts(index int) ([]activity.ExecTranscript, error) {
+	args := c.Called(index)
+	return args.Get(0).([]activity.ExecTranscript), args.Error(1)
+}
+
 // Clear mocks Client#Clear
 func (c *MockClient) Clear(path string) ([]string, error) {
 	args := c.Called(path)
 	return args.Get(0).([]string), args.Error(1)
 }
 
+// Pin mocks Client#Pin
+func (c *MockClient) Pin(path string, ttl time.Duration) (apitypes.Pin, error) {
+	args := c.Called(path, ttl)
+	return args.Get(0).(apitypes.Pin), args.Error(1)
+}
+
+// Unpin mocks Client#Unpin
+func (c *MockClient) Unpin(path string) (apitypes.Pin, error) {
+	args := c.Called(path)
+	return args.Get(0).(apitypes.Pin), args.Error(1)
+}
+
 // Schema mocks Client#Schema
 func (c *MockClient) Schema(path string) (*apitypes.EntrySchema, error) {
 	args := c.Called(path)
@@ -73,3 +213,93 @@ func (c *MockClient) Screenview(name string, params analytics.Params) error {
 	args := c.Called(name, params)
 	return args.Error(1)
 }
+
+// Limits mocks Client#Limits
+func (c *MockClient) Limits() ([]apitypes.Limit, error) {
+	args := c.Called()
+	return args.Get(0).([]apitypes.Limit), args.Error(1)
+}
+
+// SetLimit mocks Client#SetLimit
+func (c *MockClient) SetLimit(name string, value int, persist bool) (apitypes.Limit, error) {
+	args := c.Called(name, value, persist)
+	return args.Get(0).(apitypes.Limit), args.Error(1)
+}
+
+// Metrics mocks Client#Metrics
+func (c *MockClient) Metrics() (apitypes.Metrics, error) {
+	args := c.Called()
+	return args.Get(0).(apitypes.Metrics), args.Error(1)
+}
+
+// Features mocks Client#Features
+func (c *MockClient) Features() ([]apitypes.Feature, error) {
+	args := c.Called()
+	return args.Get(0).([]apitypes.Feature), args.Error(1)
+}
+
+// SetFeature mocks Client#SetFeature
+func (c *MockClient) SetFeature(name string, plugin string, enabled bool, persist bool) (apitypes.Feature, error) {
+	args := c.Called(name, plugin, enabled, persist)
+	return args.Get(0).(apitypes.Feature), args.Error(1)
+}
+
+// Read mocks Client#Read
+func (c *MockClient) Read(path string) (io.ReadCloser, error) {
+	args := c.Called(path)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+// ReadAsync mocks Client#ReadAsync
+func (c *MockClient) ReadAsync(path string) (apitypes.Operation, error) {
+	args := c.Called(path)
+	return args.Get(0).(apitypes.Operation), args.Error(1)
+}
+
+// Operations mocks Client#Operations
+func (c *MockClient) Operations() ([]apitypes.Operation, error) {
+	args := c.Called()
+	return args.Get(0).([]apitypes.Operation), args.Error(1)
+}
+
+// Operation mocks Client#Operation
+func (c *MockClient) Operation(id string) (apitypes.Operation, error) {
+	args := c.Called(id)
+	return args.Get(0).(apitypes.Operation), args.Error(1)
+}
+
+// OperationResult mocks Client#OperationResult
+func (c *MockClient) OperationResult(id string) (io.ReadCloser, error) {
+	args := c.Called(id)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+// CancelOperation mocks Client#CancelOperation
+func (c *MockClient) CancelOperation(id string) (apitypes.Operation, error) {
+	args := c.Called(id)
+	return args.Get(0).(apitypes.Operation), args.Error(1)
+}
+
+// Snapshot mocks Client#Snapshot
+func (c *MockClient) Snapshot() (apitypes.Snapshot, error) {
+	args := c.Called()
+	return args.Get(0).(apitypes.Snapshot), args.Error(1)
+}
+
+// Prune mocks Client#Prune
+func (c *MockClient) Prune(override *apitypes.PruneBody) ([]apitypes.PruneResult, error) {
+	args := c.Called(override)
+	return args.Get(0).([]apitypes.PruneResult), args.Error(1)
+}
+
+// Cancel mocks Client#Cancel
+func (c *MockClient) Cancel() (apitypes.CancelResult, error) {
+	args := c.Called()
+	return args.Get(0).(apitypes.CancelResult), args.Error(1)
+}
+
+// Profile mocks Client#Profile
+func (c *MockClient) Profile(kind string, duration time.Duration) (io.ReadCloser, error) {
+	args := c.Called(kind, duration)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
 )
 
 // Contains all the keys for Wash's shared config
@@ -59,6 +60,9 @@ var defaultFileSuffix = filepath.Join(".puppetlabs", "wash", "wash.yaml")
 var defaultFileRel = filepath.Join("~", defaultFileSuffix)
 var defaultFileAbs string
 
+// file is the config file that was passed into ReadFrom
+var file string
+
 // DefaultFile returns the default config file's path
 func DefaultFile() string {
 	return defaultFileRel
@@ -67,15 +71,17 @@ func DefaultFile() string {
 // ReadFrom reads the config from the specified file.
 // If file == DefaultFile(), then ReadFrom wil not return
 // an error if file does not exist.
-func ReadFrom(file string) error {
-	if file == DefaultFile() {
+func ReadFrom(configFile string) error {
+	if configFile == DefaultFile() {
 		if defaultFileAbs == "" {
 			panic("config.ReadFrom: default file not set. Please call config.Init()")
 		}
+		file = defaultFileAbs
 		if _, err := os.Stat(defaultFileAbs); os.IsNotExist(err) {
 			return nil
 		}
-		file = defaultFileAbs
+	} else {
+		file = configFile
 	}
 	content, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -90,3 +96,55 @@ func ReadFrom(file string) error {
 func newConfigReadErr(file string, reason error) error {
 	return fmt.Errorf("could not read the config from %v: %v", file, reason)
 }
+
+// Persist sets the (dot-separated) key to value in the config file that was
+// read by ReadFrom. It creates the file if it doesn't exist. Note that the
+// config file is rewritten, so any comments in it are lost.
+func Persist(key string, value interface{}) error {
+	if file == "" {
+		panic("config.Persist: the config was not read. Please call config.ReadFrom()")
+	}
+
+	var cfg yaml.MapSlice
+	content, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return newConfigReadErr(file, err)
+	}
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return newConfigReadErr(file, err)
+	}
+	cfg = setIn(cfg, strings.Split(key, "."), value)
+
+	content, err = yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("could not marshal the config: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0750); err != nil {
+		return fmt.Errorf("could not write the config to %v: %v", file, err)
+	}
+	if err := ioutil.WriteFile(file, content, 0640); err != nil {
+		return fmt.Errorf("could not write the config to %v: %v", file, err)
+	}
+	return nil
+}
+
+// setIn sets the value at path in cfg, creating any missing intermediate maps.
+func setIn(cfg yaml.MapSlice, path []string, value interface{}) yaml.MapSlice {
+	for i, item := range cfg {
+		if fmt.Sprintf("%v", item.Key) != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			cfg[i].Value = value
+		} else {
+			child, _ := item.Value.(yaml.MapSlice)
+			cfg[i].Value = setIn(child, path[1:], value)
+		}
+		return cfg
+	}
+
+	if len(path) == 1 {
+		return append(cfg, yaml.MapItem{Key: path[0], Value: value})
+	}
+	return append(cfg, yaml.MapItem{Key: path[0], Value: setIn(nil, path[1:], value)})
+}
@@ -0,0 +1,57 @@
+// Package demo stores the example external plugins that `wash demo` mounts.
+// They're embedded in the wash binary so that the demo works without any
+// plugins (or cloud accounts) being installed.
+package demo
+
+import (
+	"embed"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+//go:embed plugins/*.sh
+var scripts embed.FS
+
+// Plugins returns the names of the example plugins, in alphabetical order
+func Plugins() []string {
+	entries, err := fs.ReadDir(scripts, "plugins")
+	if err != nil {
+		panic(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Install writes the example plugins' scripts to dir, and returns their specs
+func Install(dir string) ([]plugin.ExternalPluginSpec, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	var specs []plugin.ExternalPluginSpec
+	for _, name := range Plugins() {
+		content, err := scripts.ReadFile("plugins/" + name + ".sh")
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, name+".sh")
+		if err := ioutil.WriteFile(path, content, 0750); err != nil {
+			return nil, err
+		}
+		// WriteFile doesn't change the mode of existing files
+		if err := os.Chmod(path, 0750); err != nil {
+			return nil, err
+		}
+		specs = append(specs, plugin.ExternalPluginSpec{Script: path})
+	}
+	return specs, nil
+}
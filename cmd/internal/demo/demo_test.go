@@ -0,0 +1,97 @@
+package demo
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type DemoTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (s *DemoTestSuite) SetupTest() {
+	plugin.SetTestCache(datastore.NewMemCache())
+	dir, err := ioutil.TempDir("", "demo_tests")
+	if err != nil {
+		s.FailNow(err.Error())
+	}
+	s.dir = dir
+}
+
+func (s *DemoTestSuite) TearDownTest() {
+	plugin.UnsetTestCache()
+	s.NoError(os.RemoveAll(s.dir))
+}
+
+func (s *DemoTestSuite) TestPlugins() {
+	s.Equal([]string{"clock", "datacenter", "flaky"}, Plugins())
+}
+
+func (s *DemoTestSuite) TestInstall() {
+	specs, err := Install(s.dir)
+	if !s.NoError(err) {
+		return
+	}
+	var names []string
+	for _, spec := range specs {
+		names = append(names, spec.Name())
+		info, err := os.Stat(spec.Path())
+		if s.NoError(err) {
+			s.Equal(os.FileMode(0750), info.Mode().Perm(), spec.Path())
+		}
+	}
+	s.Equal(Plugins(), names)
+
+	// Reinstalling overwrites the scripts
+	_, err = Install(s.dir)
+	s.NoError(err)
+}
+
+func (s *DemoTestSuite) TestPluginsLoad() {
+	specs, err := Install(s.dir)
+	if !s.NoError(err) {
+		return
+	}
+	roots := make(map[string]plugin.Root)
+	config := make(map[string]map[string]interface{})
+	for _, spec := range specs {
+		root, err := spec.Load()
+		if !s.NoError(err, spec.Name()) {
+			return
+		}
+		roots[spec.Name()] = root
+		config[spec.Name()] = map[string]interface{}{}
+	}
+
+	registry := plugin.NewRegistry()
+	for _, status := range registry.RegisterPlugins(roots, config) {
+		s.Equal(plugin.PluginLoaded, status.Status, "%v: %v", status.Name, status.Reason)
+	}
+
+	ctx := context.Background()
+	datacenter, err := plugin.FindEntry(ctx, registry, []string{"datacenter"})
+	if !s.NoError(err) {
+		return
+	}
+	children, err := plugin.List(ctx, datacenter.(plugin.Parent))
+	if !s.NoError(err) {
+		return
+	}
+	var names []string
+	for name := range children {
+		names = append(names, name)
+	}
+	// The root's .help entry comes from its help string
+	s.ElementsMatch([]string{"vms", "files", ".help"}, names)
+}
+
+func TestDemo(t *testing.T) {
+	suite.Run(t, new(DemoTestSuite))
+}
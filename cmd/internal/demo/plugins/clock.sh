@@ -0,0 +1,51 @@
+#!/bin/sh
+
+# This example implements a clock in POSIX sh. It shows how to stream data
+# and how to stream a listing. It's laid out as:
+#   /clock
+#     now          the current time. It's only cached for a second.
+#     ticks        streams the current time every second
+#     countdown    a directory whose 10 children are listed half a second
+#                  apart (see `wash ls --stream`)
+
+method="$1"
+state="$3"
+
+if [ "${method}" = "init" ]; then
+  printf '{"methods":["list"],"state":"root",'
+  printf '"help":"A clock. Try\\n  cat clock/now\\n  wash tail -f clock/ticks\\n  wash ls --stream clock/countdown\\n"}\n'
+  exit 0
+fi
+
+case "${method}:${state}" in
+  list:root)
+    printf '[{"name":"now","methods":["read"],"state":"now","cache_ttls":{"read":1}},'
+    printf '{"name":"ticks","methods":["stream"],"state":"ticks"},'
+    printf '{"name":"countdown","methods":["list"],"state":"countdown","streaming_list":true,"cache_ttls":{"list":1}}]\n'
+    ;;
+  list:countdown)
+    i=10
+    while [ "${i}" -gt 0 ]; do
+      printf '{"name":"%s","methods":["read"],"state":"count:%s"}\n' "${i}" "${i}"
+      i=$((i - 1))
+      sleep 0.5
+    done
+    ;;
+  read:now)
+    date
+    ;;
+  read:count:*)
+    echo "${state#count:}"
+    ;;
+  stream:ticks)
+    echo 200
+    while true; do
+      date
+      sleep 1
+    done
+    ;;
+  *)
+    printf '{"kind":"unknown","message":"unsupported method %s"}\n' "${method}" >&2
+    exit 1
+    ;;
+esac
@@ -0,0 +1,198 @@
+#!/bin/sh
+
+# This example implements a fake datacenter in POSIX sh. It exercises most of
+# the external plugin protocol's methods, so it's a good place to start when
+# writing a plugin. The datacenter is laid out as:
+#   /datacenter
+#     vms
+#       web-1
+#       web-2
+#       db-1
+#     files
+#       motd
+#       config.json
+#       app.log
+#
+# The VMs support metadata, exec, signal (start, stop and restart), delete,
+# telemetry and stream (their logs). The files support read, and motd and
+# config.json also support write. Changes are saved in the plugin's workspace
+# (WASH_PLUGIN_WORKSPACE), so they last until the Wash server stops.
+#
+# Each entry's state says what it is (e.g. "vm:web-1"), so the script never
+# has to parse the entry's path.
+
+workspace="${WASH_PLUGIN_WORKSPACE:-${TMPDIR:-/tmp}/wash-demo-datacenter}"
+vms="${workspace}/vms"
+files="${workspace}/files"
+
+# setup creates the datacenter's VMs and files the first time that the
+# script's invoked
+setup() {
+  if [ -d "${vms}" ]; then
+    return
+  fi
+  mkdir -p "${vms}" "${files}"
+  for vm in web-1 web-2 db-1; do
+    echo running > "${vms}/${vm}"
+  done
+  echo 'Welcome to the demo datacenter! Edit me with your favorite editor.' > "${files}/motd"
+  printf '{"replicas":3,"region":"demo-1","features":["fast","cheap"]}\n' > "${files}/config.json"
+  printf '2019-06-01 10:00:00 INFO server started\n2019-06-01 10:00:05 WARN disk is 80%% full\n2019-06-01 10:01:00 ERROR db-1 is unreachable\n' > "${files}/app.log"
+}
+
+# fail prints a structured error, then exits
+fail() {
+  kind="$1"
+  message="$2"
+  printf '{"kind":"%s","message":"%s"}\n' "${kind}" "${message}" >&2
+  exit 1
+}
+
+vm_state() {
+  if [ ! -f "${vms}/$1" ]; then
+    fail not_found "the VM $1 no longer exists"
+  fi
+  cat "${vms}/$1"
+}
+
+lifecycle_of() {
+  if [ "$1" = "running" ]; then
+    echo running
+  else
+    echo terminated
+  fi
+}
+
+print_vm() {
+  vm="$1"
+  state="$(vm_state "${vm}")"
+  printf '{"name":"%s","methods":["metadata","exec","signal","delete","telemetry","stream"],' "${vm}"
+  printf '"attributes":{"lifecycle":"%s","meta":{"id":"vm-%s","state":"%s","zone":"demo-1a"}},' "$(lifecycle_of "${state}")" "${vm}" "${state}"
+  printf '"state":"vm:%s"}' "${vm}"
+}
+
+print_file() {
+  name="$1"
+  methods='"read","write"'
+  content_type='text/plain'
+  case "${name}" in
+    *.json) content_type='application/json' ;;
+    *.log)
+      methods='"read","stream"'
+      content_type='text/x-log'
+      ;;
+  esac
+  size="$(wc -c < "${files}/${name}" | tr -d ' ')"
+  printf '{"name":"%s","methods":[%s],"attributes":{"size":%s,"content_type":"%s"},"state":"file:%s"}' \
+    "${name}" "${methods}" "${size}" "${content_type}" "${name}"
+}
+
+# print_entries prints a JSON array of the entries that are printed by the
+# passed-in function for each of the arguments
+print_entries() {
+  printer="$1"
+  shift
+  printf '['
+  sep=''
+  for arg in "$@"; do
+    printf '%s' "${sep}"
+    "${printer}" "${arg}"
+    sep=','
+  done
+  printf ']\n'
+}
+
+method="$1"
+state="$3"
+setup
+
+if [ "${method}" = "init" ]; then
+  printf '{"methods":["list"],"state":"root","cache_ttls":{"list":5},'
+  printf '"help":"A fake datacenter with VMs and files. Try\\n  ls datacenter/vms\\n  wash exec datacenter/vms/web-1 uname -a\\n  wash signal stop datacenter/vms/web-1\\n  wash tail -f datacenter/vms/db-1\\n  wash list --telemetry datacenter/vms\\n  wash cat datacenter/files/config.json\\n  echo hello > datacenter/files/motd\\n"}\n'
+  exit 0
+fi
+
+case "${method}:${state}" in
+  list:root)
+    echo '[{"name":"vms","methods":["list"],"state":"vms"},{"name":"files","methods":["list"],"state":"files"}]'
+    ;;
+  list:vms)
+    # shellcheck disable=SC2046
+    print_entries print_vm $(ls "${vms}")
+    ;;
+  list:files)
+    # shellcheck disable=SC2046
+    print_entries print_file $(ls "${files}")
+    ;;
+  metadata:vm:*)
+    vm="${state#vm:}"
+    current="$(vm_state "${vm}")" || exit 1
+    printf '{"id":"vm-%s","name":"%s","state":"%s","zone":"demo-1a","cpus":2,"memory_mb":4096,"labels":{"team":"demo","role":"%s"}}\n' \
+      "${vm}" "${vm}" "${current}" "${vm%-*}"
+    ;;
+  telemetry:vm:*)
+    vm="${state#vm:}"
+    current="$(vm_state "${vm}")" || exit 1
+    if [ "${current}" != "running" ]; then
+      echo '{"cpu_percent":0,"requests_per_second":0}'
+      exit 0
+    fi
+    awk -v seed="$$" 'BEGIN { srand(seed); printf "{\"cpu_percent\":%.1f,\"requests_per_second\":%d}\n", rand() * 100, rand() * 500 }'
+    ;;
+  exec:vm:*)
+    vm="${state#vm:}"
+    current="$(vm_state "${vm}")" || exit 1
+    if [ "${current}" != "running" ]; then
+      echo "${vm} is stopped. Start it with 'wash signal <path> start'." >&2
+      exit 1
+    fi
+    # Skip the path, state and exec options. The VMs are fake, so their
+    # commands run locally, in a directory of their own.
+    shift 4
+    mkdir -p "${workspace}/homes/${vm}"
+    cd "${workspace}/homes/${vm}" || exit 1
+    case "$1" in
+      hostname) echo "${vm}.demo.internal" ;;
+      *) exec "$@" ;;
+    esac
+    ;;
+  signal:vm:*)
+    vm="${state#vm:}"
+    vm_state "${vm}" > /dev/null
+    case "$4" in
+      start|restart) echo running > "${vms}/${vm}" ;;
+      stop) echo stopped > "${vms}/${vm}" ;;
+      *) printf '{"error":"unsupported signal %s. The VMs support start, stop and restart"}\n' "$4" ;;
+    esac
+    ;;
+  delete:vm:*)
+    vm="${state#vm:}"
+    vm_state "${vm}" > /dev/null
+    rm -f "${vms}/${vm}"
+    ;;
+  stream:vm:*)
+    vm="${state#vm:}"
+    vm_state "${vm}" > /dev/null
+    echo 200
+    while true; do
+      echo "$(date '+%Y-%m-%d %H:%M:%S') INFO ${vm} handled a request"
+      sleep 1
+    done
+    ;;
+  read:file:*)
+    name="${state#file:}"
+    cat "${files}/${name}" 2> /dev/null || fail not_found "the file ${name} no longer exists"
+    ;;
+  write:file:*)
+    name="${state#file:}"
+    cat > "${files}/${name}"
+    ;;
+  stream:file:*)
+    name="${state#file:}"
+    echo 200
+    exec tail -n 10 -f "${files}/${name}"
+    ;;
+  *)
+    fail unknown "unsupported method ${method}"
+    ;;
+esac
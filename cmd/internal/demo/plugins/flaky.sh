@@ -0,0 +1,76 @@
+#!/bin/sh
+
+# This example implements a slow and flaky plugin in POSIX sh. It shows how
+# Wash handles a misbehaving backend: slow listings, transient (retryable)
+# errors, permanent errors and hung invocations. It's laid out as:
+#   /flaky
+#     slow         a directory that takes 3 seconds to list
+#     unreliable   a directory whose listing fails half of the time
+#     forbidden    a file that can't be read
+#     hung         a file whose read never finishes (it's timed out)
+#
+# Run `whistory` after exploring it to see the retries and timeouts in the
+# activity journal.
+
+# fail prints a structured error, then exits
+fail() {
+  printf '{"kind":"%s","message":"%s","retryable":%s}\n' "$1" "$2" "$3" >&2
+  exit 1
+}
+
+# print_items prints a JSON array of $1 readable files named item-<n>
+print_items() {
+  printf '['
+  i=1
+  while [ "${i}" -le "$1" ]; do
+    if [ "${i}" -gt 1 ]; then
+      printf ','
+    fi
+    printf '{"name":"item-%s","methods":["read"],"state":"item"}' "${i}"
+    i=$((i + 1))
+  done
+  printf ']\n'
+}
+
+method="$1"
+state="$3"
+
+if [ "${method}" = "init" ]; then
+  printf '{"methods":["list"],"state":"root","cache_ttls":{"list":10},"timeouts":{"read":2},'
+  printf '"help":"A slow and flaky plugin. Try\\n  time ls flaky/slow\\n  ls flaky/unreliable\\n  cat flaky/forbidden\\n  cat flaky/hung\\nthen run whistory to see what happened.\\n"}\n'
+  exit 0
+fi
+
+case "${method}:${state}" in
+  list:root)
+    printf '[{"name":"slow","methods":["list"],"state":"slow"},'
+    printf '{"name":"unreliable","methods":["list"],"state":"unreliable","cache_ttls":{"list":2}},'
+    printf '{"name":"forbidden","methods":["read"],"state":"forbidden"},'
+    printf '{"name":"hung","methods":["read"],"state":"hung"}]\n'
+    ;;
+  list:slow)
+    sleep 3
+    print_items 5
+    ;;
+  list:unreliable)
+    if awk -v seed="$$" 'BEGIN { srand(seed); exit rand() < 0.5 }'; then
+      fail unavailable "the backend is overloaded, try again" true
+    fi
+    print_items 3
+    ;;
+  read:item)
+    echo "This is $2"
+    ;;
+  read:forbidden)
+    fail permission_denied "you aren't allowed to read $2" false
+    ;;
+  read:hung)
+    # Wash terminates the invocation once the read's 2-second timeout expires
+    while true; do
+      sleep 1
+    done
+    ;;
+  *)
+    fail unknown "unsupported method ${method}" false
+    ;;
+esac
@@ -14,10 +14,11 @@ func info(c client.Client, path string) (types.Entry, error) {
 	return types.NewEntry(e, path), nil
 }
 
-// list is a wrapper to c.List that handles normalizing the children's
-// path relative to e's normalized path
+// list is a wrapper to c.ListFlat that handles normalizing the children's
+// path relative to e's normalized path. Find walks the flat listings so that
+// it doesn't visit the synthetic partitions of giant parents.
 func list(c client.Client, e types.Entry) ([]types.Entry, error) {
-	rawChildren, err := c.List(e.Path)
+	rawChildren, err := c.ListFlat(e.Path)
 	if err != nil {
 		return nil, err
 	}
@@ -42,15 +42,21 @@ func Main(args []string) int {
 		)
 	}
 
-	// Do the walk
+	// Expand any glob patterns, then do the walk
 	conn := cmdutil.NewClient()
+	paths, err := cmdutil.ExpandPaths(conn, result.Paths)
+	if err != nil {
+		cmdutil.ErrPrintf("find: %v\n", err)
+		return 1
+	}
 	walker := newWalker(result, conn)
 	exitCode := 0
-	for _, path := range result.Paths {
+	for _, path := range paths {
 		if !walker.Walk(path) {
 			exitCode = 1
 		}
 	}
+	walker.Finish()
 	return exitCode
 }
 
@@ -2,8 +2,10 @@
 package meta
 
 import (
+	"github.com/ekinanp/jsonschema"
 	"github.com/puppetlabs/wash/cmd/internal/find/parser/predicate"
 	"github.com/puppetlabs/wash/cmd/internal/find/types"
+	"github.com/puppetlabs/wash/plugin"
 )
 
 // The functionality here is tested in primary/meta_test.go
@@ -23,6 +25,21 @@ func Parse(tokens []string) (types.EntryPredicate, []string, error) {
 	return entryP, tokens, err
 }
 
+// ParseTelemetry is the telemetry primary's parse function. Its expressions
+// are the same as the meta primary's, except that they're on the entry's
+// telemetry. Telemetry doesn't have a schema, so its predicates are always
+// true for entry schemas.
+func ParseTelemetry(tokens []string) (types.EntryPredicate, []string, error) {
+	p, tokens, err := parseExpression(tokens)
+	var entryP types.EntryPredicate
+	if p != nil {
+		entryP = types.ToEntryP(func(e types.Entry) bool {
+			return p.IsSatisfiedBy(e.Telemetry)
+		})
+	}
+	return entryP, tokens, err
+}
+
 // entrySchemaPredicate is the meta primary's entry schema predicate.
 type entrySchemaPredicate struct {
 	p schemaPredicate
@@ -49,5 +66,18 @@ func (p *entrySchemaPredicate) P(s *types.EntrySchema) bool {
 		// a better UX.
 		return true
 	}
-	return p.p.IsSatisfiedBy(newSchema(s.MetadataSchema()))
+	return p.p.IsSatisfiedBy(newSchema(withCommonMetadata(s.MetadataSchema())))
+}
+
+// withCommonMetadata adds the common metadata's schema to s. Plugins' schemas
+// don't include it since it's added by Wash.
+func withCommonMetadata(s *plugin.JSONSchema) *plugin.JSONSchema {
+	if s.Type == nil || s.Type.Type != "object" {
+		return s
+	}
+	if s.Type.Properties == nil {
+		s.Type.Properties = make(map[string]*jsonschema.Type)
+	}
+	s.Type.Properties[plugin.CommonMetadataKey] = plugin.CommonMetadataSchema()
+	return s
 }
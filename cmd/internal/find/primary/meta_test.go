@@ -83,6 +83,8 @@ func (s *MetaPrimaryTestSuite) TestMetaPrimaryValidInputTrueSchemaPredicates() {
 	s.RSTC(".networkInterfaces[?] .association -exists -primary", "-primary", s.s)
 	// Should pass b/c objects in securityGroups do have a groupID key
 	s.RSTC(".securityGroups[?] ( .groupID 4 -o foo 10 ) -primary", "-primary", s.s)
+	// Should pass b/c every entry's metadata includes the common metadata
+	s.RSTC("._common.region us-west-1 -primary", "-primary", s.s)
 	// Should be true
 	noMetaSchema := &types.EntrySchema{}
 	s.RSTC("-empty -primary", "-primary", noMetaSchema)
@@ -106,6 +108,8 @@ func (s *MetaPrimaryTestSuite) TestMetaPrimaryValidInputFalsePredicates() {
 func (s *MetaPrimaryTestSuite) TestMetaPrimaryValidInputFalseSchemaPredicates() {
 	// Should fail b/c the arch key does not exist
 	s.RNSTC(".arch x86_64 -primary", "-primary", s.s)
+	// Should fail b/c the common metadata has no "arch" key
+	s.RNSTC("._common.arch x86_64 -primary", "-primary", s.s)
 	// Should fail b/c "platform" is a primitive value, not an empty object/array
 	s.RNSTC(".platform -empty -primary", "-primary", s.s)
 	// Should fail b/c "placement" cannot be an empty object
@@ -26,22 +26,24 @@ func (s *ParserTestSuite) TestRegisteredPrimaries() {
 		Atime,
 		Crtime,
 		Kind,
+		Telemetry,
 	}
 	expectedMp := map[string]*Primary{
-		"-action": Action,
-		"-true":   True,
-		"-false":  False,
-		"-meta":   Meta,
-		"-m":      Meta,
-		"-name":   Name,
-		"-path":   Path,
-		"-size":   Size,
-		"-ctime":  Ctime,
-		"-mtime":  Mtime,
-		"-atime":  Atime,
-		"-crtime": Crtime,
-		"-kind":   Kind,
-		"-k":      Kind,
+		"-action":    Action,
+		"-true":      True,
+		"-false":     False,
+		"-meta":      Meta,
+		"-m":         Meta,
+		"-name":      Name,
+		"-path":      Path,
+		"-size":      Size,
+		"-ctime":     Ctime,
+		"-mtime":     Mtime,
+		"-atime":     Atime,
+		"-crtime":    Crtime,
+		"-kind":      Kind,
+		"-k":         Kind,
+		"-telemetry": Telemetry,
 	}
 
 	s.ElementsMatch(expectedList, Parser.primaries)
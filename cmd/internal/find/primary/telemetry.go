@@ -0,0 +1,35 @@
+package primary
+
+import (
+	"github.com/puppetlabs/wash/cmd/internal/find/primary/meta"
+)
+
+// Telemetry is the telemetry primary
+//
+// telemetryPrimary => -telemetry Expression
+//
+// Expression is the same as the meta primary's Expression.
+//nolint
+var Telemetry = Parser.add(&Primary{
+	Description:         "Returns true if the entry's telemetry satisfies the expression",
+	DetailedDescription: telemetryDetailedDescription,
+	name:                "telemetry",
+	args:                "<expression>",
+	parseFunc:           meta.ParseTelemetry,
+})
+
+const telemetryDetailedDescription = `
+The telemetry primary constructs a predicate on the entry's live telemetry,
+e.g. a VM's CPU usage. Its expressions are the same as the meta primary's
+(type "wash find -h meta" for more details), except that they're applied
+on the entry's telemetry instead of its metadata. Entries that don't
+support the telemetry action don't have any telemetry.
+
+Like the "fullmeta" option, find makes O(N) API requests to retrieve
+the telemetry (N = the number of visited entries that support the
+telemetry action), so consider using it with the kind primary.
+
+EXAMPLES:
+find aws -k '*ec2*instance' -telemetry .cpu_percent +80
+    Returns all EC2 instances whose CPU usage is above 80%
+`
@@ -0,0 +1,44 @@
+package primary
+
+import (
+	"testing"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/cmd/internal/find/types"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type TelemetryPrimaryTestSuite struct {
+	primaryTestSuite
+}
+
+func (s *TelemetryPrimaryTestSuite) TestTelemetryPrimaryErrors() {
+	s.RETC("", "expected a key sequence")
+	s.RETC("foo", "key sequences must begin with a '.'")
+	s.RETC(".key", "expected a predicate expression")
+}
+
+func (s *TelemetryPrimaryTestSuite) TestTelemetryPrimaryValidInput() {
+	s.RTC(".cpu_percent +80 -primary", "-primary", plugin.JSONObject{"cpu_percent": float64(92.5)}, plugin.JSONObject{"cpu_percent": float64(12)})
+	s.RTC(".status healthy", "", plugin.JSONObject{"status": "healthy"}, plugin.JSONObject{"status": "degraded"})
+	// Entries without telemetry don't satisfy key sequences
+	s.RNTC(".cpu_percent +80", "", plugin.JSONObject(nil))
+}
+
+func (s *TelemetryPrimaryTestSuite) TestTelemetryPrimaryIgnoresMetadata() {
+	e := types.NewEntry(apitypes.Entry{}, "")
+	e.Metadata = plugin.JSONObject{"cpu_percent": float64(92.5)}
+	s.Suite.RNTC(".cpu_percent +80", "", e)
+}
+
+func TestTelemetryPrimary(t *testing.T) {
+	s := new(TelemetryPrimaryTestSuite)
+	s.Parser = Telemetry
+	s.ConstructEntry = func(v interface{}) types.Entry {
+		e := types.NewEntry(apitypes.Entry{}, "")
+		e.Telemetry = v.(plugin.JSONObject)
+		return e
+	}
+	suite.Run(t, s)
+}
@@ -0,0 +1,229 @@
+package find
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// progress tracks the walk's progress for the progress option. If it's live,
+// then it continually redraws a status line on stderr with the number of
+// visited entries, the number of errors, and the entry that's currently being
+// visited so that users know a long-running walk is still alive. Its summary
+// includes the time spent waiting on each plugin so that users know where the
+// time went.
+//
+// All of the walker's output goes through the progress so that it doesn't
+// clobber the status line. A nil progress prints the output as-is.
+type progress struct {
+	mux       sync.Mutex
+	live      bool
+	width     int
+	started   time.Time
+	visited   int
+	errors    int
+	current   string
+	plugins   map[string]*pluginTiming
+	drawn     bool
+	stopCh    chan struct{}
+	stoppedCh chan struct{}
+}
+
+type pluginTiming struct {
+	calls   int
+	elapsed time.Duration
+}
+
+const progressRedrawInterval = 200 * time.Millisecond
+
+// newProgress returns a new progress. The progress is live if stderr is a
+// terminal.
+func newProgress() *progress {
+	p := &progress{
+		plugins: make(map[string]*pluginTiming),
+	}
+	if fd := int(os.Stderr.Fd()); cmdutil.Stderr == os.Stderr && terminal.IsTerminal(fd) {
+		p.live = true
+		if width, _, err := terminal.GetSize(fd); err == nil {
+			p.width = width
+		}
+	}
+	return p
+}
+
+// start starts tracking the walk's progress
+func (p *progress) start() {
+	if p == nil {
+		return
+	}
+	p.started = time.Now()
+	if !p.live {
+		return
+	}
+	p.stopCh, p.stoppedCh = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(p.stoppedCh)
+		ticker := time.NewTicker(progressRedrawInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+				p.mux.Lock()
+				p.draw()
+				p.mux.Unlock()
+			}
+		}
+	}()
+}
+
+// visiting records that the walk's visiting path
+func (p *progress) visiting(path string) {
+	if p == nil {
+		return
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.visited++
+	p.current = path
+}
+
+// timeCall records the time spent on an API call for an entry with the given
+// type ID. Core and external plugin type IDs are namespaced by their plugin.
+func (p *progress) timeCall(typeID string, started time.Time) {
+	if p == nil {
+		return
+	}
+	elapsed := time.Since(started)
+	plugin := typeID
+	if ix := strings.Index(typeID, "::"); ix >= 0 {
+		plugin = typeID[:ix]
+	}
+	if plugin == "" {
+		plugin = "unknown"
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	timing, ok := p.plugins[plugin]
+	if !ok {
+		timing = &pluginTiming{}
+		p.plugins[plugin] = timing
+	}
+	timing.calls++
+	timing.elapsed += elapsed
+}
+
+// printf prints a satisfying entry on stdout
+func (p *progress) printf(msg string, a ...interface{}) {
+	if p == nil {
+		cmdutil.Printf(msg, a...)
+		return
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.clear()
+	cmdutil.Printf(msg, a...)
+}
+
+// errPrintf prints an error on stderr. Errors are counted even if they're
+// not fatal, e.g. when an entry's children couldn't be listed.
+func (p *progress) errPrintf(msg string, a ...interface{}) {
+	if p == nil {
+		cmdutil.ErrPrintf(msg, a...)
+		return
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.errors++
+	p.clear()
+	cmdutil.ErrPrintf(msg, a...)
+}
+
+// finish stops the status line and prints the summary on stderr
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	if p.live {
+		close(p.stopCh)
+		<-p.stoppedCh
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.clear()
+	writeProgressSummary(cmdutil.Stderr, p.visited, p.errors, time.Since(p.started), p.plugins)
+}
+
+// draw redraws the status line. It must be called with p.mux held.
+func (p *progress) draw() {
+	status := fmt.Sprintf(
+		"find: visited %v entries, %v errors, %v elapsed: ",
+		p.visited,
+		p.errors,
+		cmdutil.FormatDuration(time.Since(p.started)),
+	)
+	current := p.current
+	// Truncate the start of the current path so that the status line doesn't
+	// wrap, which would prevent clear from erasing it
+	if p.width > 0 {
+		if max := p.width - len(status) - 1; len(current) > max {
+			if max > 3 {
+				current = "..." + current[len(current)-max+3:]
+			} else {
+				current = ""
+			}
+		}
+	}
+	fmt.Fprint(cmdutil.Stderr, "\r\033[K"+status+current)
+	p.drawn = true
+}
+
+// clear erases the status line. It must be called with p.mux held.
+func (p *progress) clear() {
+	if p.drawn {
+		fmt.Fprint(cmdutil.Stderr, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+func writeProgressSummary(w io.Writer, visited int, errors int, elapsed time.Duration, plugins map[string]*pluginTiming) {
+	fmt.Fprintf(w, "find: visited %v entries with %v errors in %v\n", visited, errors, cmdutil.FormatDuration(elapsed))
+	if len(plugins) == 0 {
+		return
+	}
+
+	// Show the plugins that took the longest first
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ti, tj := plugins[names[i]], plugins[names[j]]
+		if ti.elapsed != tj.elapsed {
+			return ti.elapsed > tj.elapsed
+		}
+		return names[i] < names[j]
+	})
+	rows := make([][]string, len(names))
+	for i, name := range names {
+		timing := plugins[name]
+		rows[i] = []string{name, fmt.Sprint(timing.calls), cmdutil.FormatDuration(timing.elapsed)}
+	}
+	table := cmdutil.NewTableWithHeaders(
+		[]cmdutil.ColumnHeader{
+			{ShortName: "plugin", FullName: "PLUGIN"},
+			{ShortName: "calls", FullName: "CALLS"},
+			{ShortName: "time", FullName: "TIME"},
+		},
+		rows,
+	)
+	fmt.Fprint(w, table.Format())
+}
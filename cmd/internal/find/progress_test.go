@@ -0,0 +1,79 @@
+package find
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/cmd/internal/cmdtest"
+	"github.com/stretchr/testify/suite"
+)
+
+type ProgressTestSuite struct {
+	*cmdtest.Suite
+}
+
+func (s *ProgressTestSuite) TestNilProgress() {
+	var p *progress
+	p.start()
+	p.visiting("./foo")
+	p.timeCall("docker::container", time.Now())
+	p.printf("%v\n", "./foo")
+	p.errPrintf("%v\n", "failed")
+	p.finish()
+	s.Equal("./foo\n", s.Stdout())
+	s.Regexp("failed", s.Stderr())
+	s.NotRegexp("visited", s.Stderr())
+}
+
+func (s *ProgressTestSuite) TestNewProgress_IsNotLiveIfStderrIsNotATerminal() {
+	s.False(newProgress().live)
+}
+
+func (s *ProgressTestSuite) TestDraw_TruncatesTheCurrentPath() {
+	p := newProgress()
+	p.started = time.Now()
+	p.width = 80
+	p.current = "./" + strings.Repeat("a", 100) + "/end"
+	p.draw()
+	line := strings.TrimPrefix(s.Stderr(), "\r\033[K")
+	s.Regexp(`^find: visited 0 entries, 0 errors, 00:00.00 elapsed: \.\.\.a+/end$`, line)
+	s.Len(line, 79)
+
+	p.clear()
+	s.True(strings.HasSuffix(s.Stderr(), "\r\033[K"))
+	s.False(p.drawn)
+}
+
+func (s *ProgressTestSuite) TestTimeCall() {
+	p := newProgress()
+	p.timeCall("docker::container", time.Now().Add(-1*time.Second))
+	p.timeCall("docker::volume", time.Now().Add(-2*time.Second))
+	p.timeCall("mountpoint", time.Now())
+	p.timeCall("", time.Now())
+	s.Equal(2, p.plugins["docker"].calls)
+	s.True(p.plugins["docker"].elapsed >= 3*time.Second)
+	s.Equal(1, p.plugins["mountpoint"].calls)
+	s.Equal(1, p.plugins["unknown"].calls)
+}
+
+func (s *ProgressTestSuite) TestWriteProgressSummary_SortsBySlowestPlugin() {
+	var out strings.Builder
+	writeProgressSummary(&out, 10, 2, 3*time.Second, map[string]*pluginTiming{
+		"docker": {calls: 5, elapsed: time.Second},
+		"aws":    {calls: 2, elapsed: 2 * time.Second},
+	})
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if s.Len(lines, 4) {
+		s.Equal("find: visited 10 entries with 2 errors in 00:03.00", lines[0])
+		s.Regexp(`^PLUGIN\s+CALLS\s+TIME`, lines[1])
+		s.Regexp(`^aws\s+2\s+00:02.00`, lines[2])
+		s.Regexp(`^docker\s+5\s+00:01.00`, lines[3])
+	}
+}
+
+func TestProgress(t *testing.T) {
+	s := new(ProgressTestSuite)
+	s.Suite = new(cmdtest.Suite)
+	suite.Run(t, s)
+}
@@ -10,8 +10,10 @@ type Entry struct {
 	apitypes.Entry
 	NormalizedPath string
 	Metadata       plugin.JSONObject
-	SchemaKnown    bool
-	Schema         *EntrySchema
+	// Telemetry is only set if the telemetry primary's used
+	Telemetry   plugin.JSONObject
+	SchemaKnown bool
+	Schema      *EntrySchema
 }
 
 // NewEntry constructs a new `wash find` entry
@@ -20,6 +20,8 @@ func Usage() string {
 	u += "Usage:\n"
 	u += "  "+use+" [paths] [options] [expression]\n"
 	u += "\n"
+	u += "Paths can be glob patterns (quote them so that your shell doesn't expand them),\n"
+	u += "e.g. 'aws/*/resources/ec2/**'. Patterns are expanded by the Wash server.\n"
 
 	t := types.OptionsTable()
 	addEmptyRow := func() {
@@ -1,11 +1,12 @@
 package find
 
 import (
+	"time"
+
 	"github.com/puppetlabs/wash/api/client"
 	"github.com/puppetlabs/wash/cmd/internal/find/parser"
 	"github.com/puppetlabs/wash/cmd/internal/find/primary"
 	"github.com/puppetlabs/wash/cmd/internal/find/types"
-	cmdutil "github.com/puppetlabs/wash/cmd/util"
 	"github.com/puppetlabs/wash/plugin"
 )
 
@@ -13,32 +14,46 @@ type walker interface {
 	// Returns true if the walk is successful (i.e. does not
 	// have any errors), false otherwise.
 	Walk(path string) bool
+	// Finish ends the walk. It prints the walk's summary if the
+	// progress option is set.
+	Finish()
 }
 
 type walkerImpl struct {
 	p    types.EntryPredicate
 	opts types.Options
 	conn client.Client
+	// progress is nil if the progress option isn't set
+	progress *progress
 }
 
 // Make this a variable so that other tests can mock it
 var newWalker = func(r parser.Result, conn client.Client) walker {
-	return &walkerImpl{
+	w := &walkerImpl{
 		p:    r.Predicate,
 		opts: r.Options,
 		conn: conn,
 	}
+	if r.Options.Progress {
+		w.progress = newProgress()
+		w.progress.start()
+	}
+	return w
 }
 
 func (w *walkerImpl) Walk(path string) bool {
+	started := time.Now()
 	e, err := info(w.conn, path)
 	if err != nil {
-		cmdutil.ErrPrintf("%v\n", err)
+		w.progress.errPrintf("%v\n", err)
 		return false
 	}
+	w.progress.timeCall(e.TypeID, started)
+	started = time.Now()
 	s, err := w.conn.Schema(path)
+	w.progress.timeCall(e.TypeID, started)
 	if err != nil {
-		cmdutil.ErrPrintf("%v\n", err)
+		w.progress.errPrintf("%v\n", err)
 		return false
 	}
 	if s != nil {
@@ -54,6 +69,7 @@ func (w *walkerImpl) Walk(path string) bool {
 }
 
 func (w *walkerImpl) walk(e types.Entry, depth uint) bool {
+	w.progress.visiting(e.NormalizedPath)
 	// If the Depth option is set, then we visit e after visiting its children.
 	// Otherwise, we visit e first.
 	successful := true
@@ -72,9 +88,11 @@ func (w *walkerImpl) walk(e types.Entry, depth uint) bool {
 				return successful
 			}
 		}
+		started := time.Now()
 		children, err := list(w.conn, e)
+		w.progress.timeCall(e.TypeID, started)
 		if err != nil {
-			cmdutil.ErrPrintf("could not get children of %v: %v\n", e.NormalizedPath, err)
+			w.progress.errPrintf("could not get children of %v: %v\n", e.NormalizedPath, err)
 			successful = false
 		} else {
 			for _, child := range children {
@@ -92,6 +110,10 @@ func (w *walkerImpl) walk(e types.Entry, depth uint) bool {
 	return successful
 }
 
+func (w *walkerImpl) Finish() {
+	w.progress.finish()
+}
+
 func (w *walkerImpl) visit(e types.Entry, depth uint) bool {
 	if depth < w.opts.Mindepth {
 		return true
@@ -112,19 +134,31 @@ func (w *walkerImpl) visit(e types.Entry, depth uint) bool {
 			// mistypes a full metadata key. The latter could lead to a bad UX for subscription
 			// based APIs. Thus, it is safer to just require metadata schemas if the fullmeta
 			// option is set, which is what this code is doing.
-			cmdutil.ErrPrintf("%v did not provide a metadata schema so its full metadata will not be fetched\n", e.NormalizedPath)
+			w.progress.errPrintf("%v did not provide a metadata schema so its full metadata will not be fetched\n", e.NormalizedPath)
 		} else {
 			// Fetch the entry's full metadata
+			started := time.Now()
 			meta, err := w.conn.Metadata(e.Path)
+			w.progress.timeCall(e.TypeID, started)
 			if err != nil {
-				cmdutil.ErrPrintf("could not get full metadata of %v: %v\n", e.NormalizedPath, err)
+				w.progress.errPrintf("could not get full metadata of %v: %v\n", e.NormalizedPath, err)
 				return false
 			}
 			e.Metadata = meta
 		}
 	}
+	if primary.IsSet(primary.Telemetry) && e.Supports(plugin.TelemetryAction()) {
+		started := time.Now()
+		telemetry, err := w.conn.Telemetry(e.Path)
+		w.progress.timeCall(e.TypeID, started)
+		if err != nil {
+			w.progress.errPrintf("could not get telemetry of %v: %v\n", e.NormalizedPath, err)
+			return false
+		}
+		e.Telemetry = telemetry
+	}
 	if w.p.P(e) {
-		cmdutil.Printf("%v\n", e.NormalizedPath)
+		w.progress.printf("%v\n", e.NormalizedPath)
 	}
 	return true
 }
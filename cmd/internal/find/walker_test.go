@@ -197,6 +197,36 @@ func (s *WalkerTestSuite) TestWalk_VisitErrors() {
 	s.assertPrintedTree()
 }
 
+func (s *WalkerTestSuite) TestWalk_ProgressSet() {
+	s.walker.progress = newProgress()
+	s.walker.progress.start()
+	s.setupMocksForWalk(nil, map[string][]apitypes.Entry{
+		".": []apitypes.Entry{
+			s.toEntry("./foo", true, "docker::container"),
+			s.toEntry("./bar", true, "aws::profile"),
+		},
+		"./foo": []apitypes.Entry{
+			s.toEntry("./foo/1", false, "docker::file"),
+		},
+	})
+	s.mockList("./bar", false, nil, fmt.Errorf("failed to list"))
+
+	s.False(s.walker.Walk("."))
+	s.walker.Finish()
+	s.assertPrintedTree(
+		".",
+		"./foo",
+		"./foo/1",
+		"./bar",
+	)
+	s.Regexp("find: visited 4 entries with 1 errors in", s.Stderr())
+	s.Regexp(`PLUGIN\s+CALLS\s+TIME`, s.Stderr())
+	// The root's calls are attributed to its type ID, "."
+	s.Regexp(`\.\s+3\s+`, s.Stderr())
+	s.Regexp(`docker\s+1\s+`, s.Stderr())
+	s.Regexp(`aws\s+1\s+`, s.Stderr())
+}
+
 func (s *WalkerTestSuite) TestVisit_MindepthSet() {
 	s.walker.opts.Mindepth = 1
 	e := newMockEntryForVisit()
@@ -306,6 +336,31 @@ func (s *WalkerTestSuite) TestVisit_FullmetaSet_MetaPrimarySet_HasMetadataSchema
 	s.assertPrintedEntry(e)
 }
 
+func (s *WalkerTestSuite) TestVisit_TelemetryPrimarySet_FetchesTelemetry() {
+	primary.Parser.SetPrimaries[primary.Telemetry] = true
+
+	telemetry := plugin.JSONObject{"cpu_percent": 12.5}
+	s.walker.p = types.ToEntryP(func(entry types.Entry) bool {
+		return s.Equal(telemetry, entry.Telemetry)
+	})
+
+	e := newMockEntryForVisit()
+	e.Actions = []string{plugin.TelemetryAction().Name}
+	s.Client.On("Telemetry", e.Path).Return(telemetry, nil).Once()
+
+	s.walker.visit(e, 0)
+	s.Client.AssertCalled(s.T(), "Telemetry", e.Path)
+	s.assertPrintedEntry(e)
+}
+
+func (s *WalkerTestSuite) TestVisit_TelemetryPrimarySet_UnsupportedTelemetry_DoesNotFetchTelemetry() {
+	primary.Parser.SetPrimaries[primary.Telemetry] = true
+	e := newMockEntryForVisit()
+	s.walker.visit(e, 0)
+	s.Client.AssertNotCalled(s.T(), "Telemetry")
+	s.assertPrintedEntry(e)
+}
+
 func (s *WalkerTestSuite) TestVisit_PrintsSatisfyingEntry() {
 	e := newMockEntryForVisit()
 	s.True(s.walker.visit(e, 0))
@@ -349,11 +404,11 @@ func (s *WalkerTestSuite) mockList(path string, previouslyMocked bool, children
 	absPath := s.toAbsPath(path)
 	if previouslyMocked {
 		// Erase the existing mocks by invoking them
-		_, _ = s.Client.List(path)
-		_, _ = s.Client.List(absPath)
+		_, _ = s.Client.ListFlat(path)
+		_, _ = s.Client.ListFlat(absPath)
 	}
-	s.Client.On("List", path).Return(children, err).Once()
-	s.Client.On("List", absPath).Return(children, err).Once()
+	s.Client.On("ListFlat", path).Return(children, err).Once()
+	s.Client.On("ListFlat", absPath).Return(children, err).Once()
 }
 
 func (s *WalkerTestSuite) toAbsPath(path string) string {
@@ -0,0 +1,121 @@
+// Package pick stores all the logic for `wash pick`, an fzf-style fuzzy finder
+// over the entries under a path. We make it a separate package to decouple it
+// from cmd. This makes testing easier.
+package pick
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Match is a candidate that matched the query
+type Match struct {
+	Path  string
+	Score int
+	// Positions are the indices of the matched runes in Path
+	Positions []int
+}
+
+const (
+	matchScore = 16
+	// Consecutive matches are worth more so that "logs" prefers "logs" over
+	// "l/o/g/s"
+	consecutiveBonus = 8
+	// Matches at the start of a path segment or word (e.g. after "/", "-",
+	// "_" or ".") are worth more so that "kp" prefers "kube/pods"
+	boundaryBonus = 12
+	// Unmatched runes between two matches cost a little so that tighter
+	// matches win
+	gapPenalty = 1
+)
+
+// FuzzyMatch matches query against path. The query's runes must appear in path
+// in order, but not necessarily consecutively. Matching is case-insensitive
+// unless the query contains an uppercase rune (smart-case). The returned match
+// is nil if path doesn't match.
+func FuzzyMatch(query string, path string) *Match {
+	caseSensitive := strings.IndexFunc(query, unicode.IsUpper) >= 0
+	normalize := func(r rune) rune {
+		if caseSensitive {
+			return r
+		}
+		return unicode.ToLower(r)
+	}
+
+	q := []rune(query)
+	p := []rune(path)
+	m := &Match{Path: path}
+	if len(q) == 0 {
+		return m
+	}
+
+	// Greedily find the earliest match, then tighten it by matching backwards
+	// from its end. The backward pass prefers later (and thus tighter)
+	// positions for the earlier runes.
+	qi, end := 0, -1
+	for pi := 0; pi < len(p) && qi < len(q); pi++ {
+		if normalize(p[pi]) == normalize(q[qi]) {
+			qi++
+			end = pi
+		}
+	}
+	if qi < len(q) {
+		return nil
+	}
+	positions := make([]int, len(q))
+	qi = len(q) - 1
+	for pi := end; pi >= 0 && qi >= 0; pi-- {
+		if normalize(p[pi]) == normalize(q[qi]) {
+			positions[qi] = pi
+			qi--
+		}
+	}
+
+	for i, pos := range positions {
+		m.Score += matchScore
+		if pos == 0 || isBoundary(p[pos-1], p[pos]) {
+			m.Score += boundaryBonus
+		}
+		if i > 0 {
+			if gap := pos - positions[i-1] - 1; gap == 0 {
+				m.Score += consecutiveBonus
+			} else {
+				m.Score -= gap * gapPenalty
+			}
+		}
+	}
+	m.Positions = positions
+	return m
+}
+
+func isBoundary(prev rune, cur rune) bool {
+	switch prev {
+	case '/', '-', '_', '.', ' ', ':':
+		return true
+	}
+	// camelCase
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
+}
+
+// Filter returns the paths that match query, from best to worst match. Ties
+// are broken by preferring shorter paths, then alphabetically.
+func Filter(query string, paths []string) []Match {
+	matches := make([]Match, 0, len(paths))
+	for _, path := range paths {
+		if m := FuzzyMatch(query, path); m != nil {
+			matches = append(matches, *m)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		mi, mj := matches[i], matches[j]
+		if mi.Score != mj.Score {
+			return mi.Score > mj.Score
+		}
+		if len(mi.Path) != len(mj.Path) {
+			return len(mi.Path) < len(mj.Path)
+		}
+		return mi.Path < mj.Path
+	})
+	return matches
+}
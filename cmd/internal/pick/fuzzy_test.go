@@ -0,0 +1,82 @@
+package pick
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FuzzyTestSuite struct {
+	suite.Suite
+}
+
+func (s *FuzzyTestSuite) TestFuzzyMatch() {
+	s.Nil(FuzzyMatch("xyz", "kubernetes/pods"))
+	s.Nil(FuzzyMatch("sdop", "kubernetes/pods"))
+
+	m := FuzzyMatch("kpod", "kubernetes/pods")
+	if s.NotNil(m) {
+		s.Equal([]int{0, 11, 12, 13}, m.Positions)
+	}
+
+	// The empty query matches everything
+	m = FuzzyMatch("", "foo")
+	if s.NotNil(m) {
+		s.Equal(0, m.Score)
+		s.Empty(m.Positions)
+	}
+}
+
+func (s *FuzzyTestSuite) TestFuzzyMatch_SmartCase() {
+	s.NotNil(FuzzyMatch("pods", "kubernetes/Pods"))
+	s.NotNil(FuzzyMatch("Pods", "kubernetes/Pods"))
+	s.Nil(FuzzyMatch("Pods", "kubernetes/pods"))
+}
+
+func (s *FuzzyTestSuite) TestFuzzyMatch_PrefersTighterMatches() {
+	// The backward pass tightens "ab" to the adjacent "ab" instead of the
+	// first "a"
+	m := FuzzyMatch("ab", "a_xxab")
+	if s.NotNil(m) {
+		s.Equal([]int{4, 5}, m.Positions)
+	}
+
+	consecutive := FuzzyMatch("logs", "docker/logs")
+	scattered := FuzzyMatch("logs", "docker/lxoxgxs")
+	if s.NotNil(consecutive) && s.NotNil(scattered) {
+		s.True(consecutive.Score > scattered.Score)
+	}
+
+	boundary := FuzzyMatch("p", "kubernetes/pods")
+	middle := FuzzyMatch("p", "kubernetes/apods")
+	if s.NotNil(boundary) && s.NotNil(middle) {
+		s.True(boundary.Score > middle.Score)
+	}
+}
+
+func (s *FuzzyTestSuite) TestFilter() {
+	paths := []string{
+		"docker/containers/web/log",
+		"docker/volumes",
+		"aws/profile/logs",
+		"docker/containers/db/log",
+		"kubernetes",
+	}
+	var filtered []string
+	for _, m := range Filter("log", paths) {
+		filtered = append(filtered, m.Path)
+	}
+	s.Equal([]string{
+		// Shorter paths break ties
+		"aws/profile/logs",
+		"docker/containers/db/log",
+		"docker/containers/web/log",
+	}, filtered)
+
+	s.Len(Filter("", paths), len(paths))
+	s.Empty(Filter("nope", paths))
+}
+
+func TestFuzzy(t *testing.T) {
+	suite.Run(t, new(FuzzyTestSuite))
+}
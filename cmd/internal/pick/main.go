@@ -0,0 +1,96 @@
+package pick
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// redrawInterval is how often the picker's redrawn while its source is still
+// listing entries (so that its status stays current) and how often the
+// terminal's size is re-checked
+const redrawInterval = 100 * time.Millisecond
+
+// Run runs the fuzzy finder on tty, which is usually /dev/tty so that the
+// picker works even when Wash's stdout is captured (e.g. by `$(wash pick)`).
+// query is the initial query. Run returns the selected path, or "" if the user
+// cancelled the picker.
+func Run(tty *os.File, source *Source, query string) (string, error) {
+	fd := int(tty.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", fmt.Errorf("%v is not a terminal", tty.Name())
+	}
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = terminal.Restore(fd, state) }()
+
+	// Use the alternate screen so that the picker doesn't clobber the
+	// terminal's scrollback
+	fmt.Fprint(tty, "\033[?1049h")
+	defer fmt.Fprint(tty, "\033[?1049l")
+
+	keysCh := make(chan []key)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := tty.Read(buf)
+			if err != nil {
+				close(keysCh)
+				return
+			}
+			keysCh <- parseKeys(buf[:n])
+		}
+	}()
+
+	p := newPicker(source, query)
+	draw := func() {
+		width, height, err := terminal.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		p.render(tty, width, height)
+	}
+	draw()
+	ticker := time.NewTicker(redrawInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case keys, ok := <-keysCh:
+			if !ok {
+				return "", nil
+			}
+			for _, k := range keys {
+				if done, path := p.handle(k); done {
+					return path, nil
+				}
+			}
+		case <-source.Updated():
+			p.refresh()
+		case <-ticker.C:
+			p.refresh()
+		}
+		draw()
+	}
+}
+
+// FilterAll waits for source to finish listing, then returns the listed paths
+// that match query from best to worst match. It's the non-interactive
+// version of Run.
+func FilterAll(source *Source, query string) (paths []string, errors int) {
+	for {
+		_, _, done := source.Snapshot()
+		if done {
+			break
+		}
+		<-source.Updated()
+	}
+	allPaths, errors, _ := source.Snapshot()
+	for _, m := range Filter(query, allPaths) {
+		paths = append(paths, m.Path)
+	}
+	return paths, errors
+}
@@ -0,0 +1,256 @@
+package pick
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyEnter
+	keyBackspace
+	keyDeleteWord
+	keyClear
+	keyUp
+	keyDown
+	keyCancel
+	keyUnknown
+)
+
+type key struct {
+	code keyCode
+	r    rune
+}
+
+// parseKeys parses the keys in input, which was read from a terminal in raw
+// mode. Terminals send escape sequences (e.g. for the arrow keys) in a single
+// write, so an escape that's followed by other bytes is part of a sequence.
+func parseKeys(input []byte) []key {
+	var keys []key
+	for len(input) > 0 {
+		b := input[0]
+		switch {
+		case b == 0x1b:
+			if len(input) == 1 {
+				keys = append(keys, key{code: keyCancel})
+				input = input[1:]
+				continue
+			}
+			if len(input) >= 3 && (input[1] == '[' || input[1] == 'O') {
+				switch input[2] {
+				case 'A':
+					keys = append(keys, key{code: keyUp})
+				case 'B':
+					keys = append(keys, key{code: keyDown})
+				default:
+					keys = append(keys, key{code: keyUnknown})
+				}
+				input = input[3:]
+				continue
+			}
+			keys = append(keys, key{code: keyUnknown})
+			input = input[2:]
+		case b == '\r' || b == '\n':
+			keys = append(keys, key{code: keyEnter})
+			input = input[1:]
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, key{code: keyBackspace})
+			input = input[1:]
+		case b == 0x17:
+			// Ctrl-W
+			keys = append(keys, key{code: keyDeleteWord})
+			input = input[1:]
+		case b == 0x15:
+			// Ctrl-U
+			keys = append(keys, key{code: keyClear})
+			input = input[1:]
+		case b == 0x10 || b == 0x0b:
+			// Ctrl-P and Ctrl-K
+			keys = append(keys, key{code: keyUp})
+			input = input[1:]
+		case b == 0x0e || b == '\t':
+			// Ctrl-N and Tab
+			keys = append(keys, key{code: keyDown})
+			input = input[1:]
+		case b == 0x03 || b == 0x04 || b == 0x07:
+			// Ctrl-C, Ctrl-D and Ctrl-G
+			keys = append(keys, key{code: keyCancel})
+			input = input[1:]
+		case b < 0x20:
+			keys = append(keys, key{code: keyUnknown})
+			input = input[1:]
+		default:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, key{code: keyRune, r: r})
+			input = input[size:]
+		}
+	}
+	return keys
+}
+
+// picker is the state of the fuzzy finder's UI
+type picker struct {
+	source  *Source
+	query   []rune
+	matches []Match
+	// selected is the index of the selected match, and offset is the index of
+	// the first displayed match
+	selected int
+	offset   int
+	errors   int
+	done     bool
+	// The query and the number of paths that matches was computed for
+	filteredQuery string
+	filteredCount int
+}
+
+func newPicker(source *Source, query string) *picker {
+	p := &picker{source: source, query: []rune(query), filteredCount: -1}
+	p.refresh()
+	return p
+}
+
+// refresh re-filters the source's paths if the query changed or if more paths
+// were listed
+func (p *picker) refresh() {
+	paths, errors, done := p.source.Snapshot()
+	p.errors, p.done = errors, done
+	query := string(p.query)
+	if query == p.filteredQuery && len(paths) == p.filteredCount {
+		return
+	}
+	queryChanged := query != p.filteredQuery
+	p.matches = Filter(query, paths)
+	p.filteredQuery, p.filteredCount = query, len(paths)
+	if queryChanged || p.selected >= len(p.matches) {
+		p.selected, p.offset = 0, 0
+	}
+}
+
+// handle handles k. It returns true (along with the selected path, which is
+// empty if the picker was cancelled) once the picker's done.
+func (p *picker) handle(k key) (bool, string) {
+	switch k.code {
+	case keyRune:
+		p.query = append(p.query, k.r)
+	case keyBackspace:
+		if len(p.query) > 0 {
+			p.query = p.query[:len(p.query)-1]
+		}
+	case keyDeleteWord:
+		query := strings.TrimRight(string(p.query), " /")
+		if ix := strings.LastIndexAny(query, " /"); ix >= 0 {
+			query = query[:ix+1]
+		} else {
+			query = ""
+		}
+		p.query = []rune(query)
+	case keyClear:
+		p.query = nil
+	case keyUp:
+		if p.selected > 0 {
+			p.selected--
+		}
+	case keyDown:
+		if p.selected < len(p.matches)-1 {
+			p.selected++
+		}
+	case keyEnter:
+		if len(p.matches) == 0 {
+			return false, ""
+		}
+		return true, p.matches[p.selected].Path
+	case keyCancel:
+		return true, ""
+	}
+	p.refresh()
+	return false, ""
+}
+
+const (
+	reverseVideo = "\033[7m"
+	bold         = "\033[1m"
+	resetStyle   = "\033[0m"
+	clearLine    = "\033[K"
+)
+
+// render draws the picker. The first line's the query, the second line's the
+// status, and the remaining lines are the best matches.
+func (p *picker) render(w io.Writer, width int, height int) {
+	var b strings.Builder
+	b.WriteString("\033[H")
+
+	b.WriteString(truncate("> "+string(p.query), width) + clearLine + "\r\n")
+	status := fmt.Sprintf("  %v/%v", len(p.matches), p.filteredCount)
+	if p.errors > 0 {
+		status += fmt.Sprintf(" (%v errors)", p.errors)
+	}
+	if !p.done {
+		status += " (listing...)"
+	}
+	b.WriteString(truncate(status, width) + clearLine + "\r\n")
+
+	rows := height - 2
+	if rows < 1 {
+		rows = 1
+	}
+	if p.selected < p.offset {
+		p.offset = p.selected
+	} else if p.selected >= p.offset+rows {
+		p.offset = p.selected - rows + 1
+	}
+	for i := p.offset; i < len(p.matches) && i < p.offset+rows; i++ {
+		if i == p.selected {
+			b.WriteString(reverseVideo + "> ")
+		} else {
+			b.WriteString("  ")
+		}
+		b.WriteString(highlight(p.matches[i], width-2, i == p.selected))
+		b.WriteString(resetStyle + clearLine + "\r\n")
+	}
+	// Clear the rest of the screen, then move the cursor to the end of the
+	// query
+	b.WriteString("\033[J")
+	fmt.Fprintf(&b, "\033[1;%vH", utf8.RuneCountInString(truncate("> "+string(p.query), width))+1)
+	_, _ = io.WriteString(w, b.String())
+}
+
+// highlight renders the match's path, truncated to width runes, with the
+// matched runes in bold
+func highlight(m Match, width int, selected bool) string {
+	positions := make(map[int]bool, len(m.Positions))
+	for _, pos := range m.Positions {
+		positions[pos] = true
+	}
+	restore := resetStyle
+	if selected {
+		restore += reverseVideo
+	}
+	var b strings.Builder
+	for i, r := range []rune(m.Path) {
+		if width > 0 && i >= width {
+			break
+		}
+		if positions[i] {
+			b.WriteString(bold + string(r) + restore)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return s
+}
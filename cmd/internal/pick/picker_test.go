@@ -0,0 +1,142 @@
+package pick
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PickerTestSuite struct {
+	suite.Suite
+}
+
+func (s *PickerTestSuite) newPicker(query string, paths ...string) *picker {
+	source := &Source{paths: paths, done: true, updateCh: make(chan struct{}, 1)}
+	return newPicker(source, query)
+}
+
+func (s *PickerTestSuite) matchedPaths(p *picker) []string {
+	var paths []string
+	for _, m := range p.matches {
+		paths = append(paths, m.Path)
+	}
+	return paths
+}
+
+func (s *PickerTestSuite) TestParseKeys() {
+	keys := parseKeys([]byte("ab\x1b[A\x1bOB\r\x7f\x17\x15\x10\x0e\t\x03\x01é"))
+	s.Equal([]key{
+		{code: keyRune, r: 'a'},
+		{code: keyRune, r: 'b'},
+		{code: keyUp},
+		{code: keyDown},
+		{code: keyEnter},
+		{code: keyBackspace},
+		{code: keyDeleteWord},
+		{code: keyClear},
+		{code: keyUp},
+		{code: keyDown},
+		{code: keyDown},
+		{code: keyCancel},
+		{code: keyUnknown},
+		{code: keyRune, r: 'é'},
+	}, keys)
+
+	// A lone escape cancels
+	s.Equal([]key{{code: keyCancel}}, parseKeys([]byte("\x1b")))
+}
+
+func (s *PickerTestSuite) TestHandle_EditsTheQuery() {
+	p := s.newPicker("", "docker/web", "docker/db", "aws")
+	s.Len(p.matches, 3)
+
+	for _, r := range "web" {
+		p.handle(key{code: keyRune, r: r})
+	}
+	s.Equal([]string{"docker/web"}, s.matchedPaths(p))
+
+	p.handle(key{code: keyBackspace})
+	s.Equal("we", string(p.query))
+
+	p.query = []rune("docker/we")
+	p.handle(key{code: keyDeleteWord})
+	s.Equal("docker/", string(p.query))
+	p.handle(key{code: keyDeleteWord})
+	s.Equal("", string(p.query))
+
+	p.query = []rune("foo")
+	p.handle(key{code: keyClear})
+	s.Empty(p.query)
+	s.Len(p.matches, 3)
+}
+
+func (s *PickerTestSuite) TestHandle_MovesTheSelection() {
+	p := s.newPicker("d", "docker/web", "docker/db")
+	s.Equal(0, p.selected)
+	p.handle(key{code: keyUp})
+	s.Equal(0, p.selected)
+	p.handle(key{code: keyDown})
+	p.handle(key{code: keyDown})
+	s.Equal(1, p.selected)
+
+	done, path := p.handle(key{code: keyEnter})
+	s.True(done)
+	s.Equal(p.matches[1].Path, path)
+
+	// Changing the query resets the selection
+	p.handle(key{code: keyRune, r: 'o'})
+	s.Equal(0, p.selected)
+}
+
+func (s *PickerTestSuite) TestHandle_EnterWithoutMatchesDoesNothing() {
+	p := s.newPicker("nope", "docker")
+	done, path := p.handle(key{code: keyEnter})
+	s.False(done)
+	s.Equal("", path)
+}
+
+func (s *PickerTestSuite) TestHandle_Cancel() {
+	p := s.newPicker("", "docker")
+	done, path := p.handle(key{code: keyCancel})
+	s.True(done)
+	s.Equal("", path)
+}
+
+func (s *PickerTestSuite) TestRefresh_PicksUpNewPaths() {
+	p := s.newPicker("", "docker")
+	p.source.paths = append(p.source.paths, "aws")
+	p.source.done = false
+	p.refresh()
+	s.Len(p.matches, 2)
+	s.False(p.done)
+}
+
+func (s *PickerTestSuite) TestRender() {
+	p := s.newPicker("wb", "docker/web", "web", "docker/db")
+	p.errors = 1
+	var out strings.Builder
+	p.render(&out, 80, 24)
+	lines := strings.Split(out.String(), "\r\n")
+	s.Equal("\033[H> wb"+clearLine, lines[0])
+	s.Equal("  2/3 (1 errors)"+clearLine, lines[1])
+	s.Equal(reverseVideo+"> "+bold+"w"+resetStyle+reverseVideo+"e"+bold+"b"+resetStyle+reverseVideo+resetStyle+clearLine, lines[2])
+	s.Equal("  docker/"+bold+"w"+resetStyle+"e"+bold+"b"+resetStyle+resetStyle+clearLine, lines[3])
+	s.Equal("\033[J\033[1;5H", lines[4])
+}
+
+func (s *PickerTestSuite) TestRender_ScrollsToTheSelection() {
+	p := s.newPicker("", "a", "b", "c", "d")
+	p.selected = 3
+	var out strings.Builder
+	// 2 rows for the query and the status, and 2 rows for the matches
+	p.render(&out, 80, 4)
+	s.Equal(2, p.offset)
+	s.NotContains(out.String(), "  a")
+	s.Contains(out.String(), "  c")
+	s.Contains(out.String(), reverseVideo+"> d")
+}
+
+func TestPicker(t *testing.T) {
+	suite.Run(t, new(PickerTestSuite))
+}
@@ -0,0 +1,152 @@
+package pick
+
+import (
+	"sync"
+
+	"github.com/puppetlabs/wash/api/client"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// maxParallelLists is the number of parents that a source lists at once
+const maxParallelLists = 10
+
+// Source lists the entries under a root in the background so that they can be
+// picked while they're still being listed. The entries are listed breadth-first,
+// so the entries that are closest to the root are available first.
+type Source struct {
+	conn     client.Client
+	maxdepth int
+	mux      sync.Mutex
+	paths    []string
+	errors   int
+	done     bool
+	stopped  bool
+	updateCh chan struct{}
+}
+
+type sourceEntry struct {
+	apitypes.Entry
+	normalizedPath string
+	depth          int
+}
+
+// NewSource starts listing the entries under root, up to maxdepth levels deep.
+// The listed paths are relative to root as it was specified, like `wash find`'s
+// output.
+func NewSource(conn client.Client, root string, maxdepth int) *Source {
+	s := &Source{
+		conn:     conn,
+		maxdepth: maxdepth,
+		updateCh: make(chan struct{}, 1),
+	}
+	go s.walk(root)
+	return s
+}
+
+func (s *Source) walk(root string) {
+	defer func() {
+		s.mux.Lock()
+		s.done = true
+		s.mux.Unlock()
+		s.notify()
+	}()
+
+	e, err := s.conn.Info(root)
+	if err != nil {
+		s.recordError()
+		return
+	}
+	pool := cmdutil.NewPool(maxParallelLists)
+	var list func(parent sourceEntry)
+	list = func(parent sourceEntry) {
+		defer pool.Done()
+		if s.isStopped() {
+			return
+		}
+		children, err := s.conn.ListFlat(parent.Path)
+		if err != nil {
+			s.recordError()
+			return
+		}
+		paths := make([]string, len(children))
+		for i, child := range children {
+			childEntry := sourceEntry{
+				Entry:          child,
+				normalizedPath: join(parent.normalizedPath, child.CName),
+				depth:          parent.depth + 1,
+			}
+			paths[i] = childEntry.normalizedPath
+			if childEntry.depth < s.maxdepth && child.Supports(plugin.ListAction()) {
+				pool.Submit(func() { list(childEntry) })
+			}
+		}
+		s.add(paths)
+	}
+	if e.Supports(plugin.ListAction()) && s.maxdepth > 0 {
+		pool.Submit(func() { list(sourceEntry{Entry: e, normalizedPath: root}) })
+	}
+	pool.Finish()
+}
+
+func join(parent string, cname string) string {
+	if parent == "/" {
+		return "/" + cname
+	}
+	return parent + "/" + cname
+}
+
+func (s *Source) add(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	s.mux.Lock()
+	s.paths = append(s.paths, paths...)
+	s.mux.Unlock()
+	s.notify()
+}
+
+func (s *Source) recordError() {
+	s.mux.Lock()
+	s.errors++
+	s.mux.Unlock()
+	s.notify()
+}
+
+// notify signals Updated without blocking. Updates are coalesced.
+func (s *Source) notify() {
+	select {
+	case s.updateCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Source) isStopped() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.stopped
+}
+
+// Updated is signalled whenever new paths are listed, a list errors, or the
+// source is done
+func (s *Source) Updated() <-chan struct{} {
+	return s.updateCh
+}
+
+// Snapshot returns the paths that have been listed so far, the number of lists
+// that errored, and whether the source is done listing. The returned slice
+// must not be modified.
+func (s *Source) Snapshot() (paths []string, errors int, done bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.paths[:len(s.paths):len(s.paths)], s.errors, s.done
+}
+
+// Stop stops listing new parents. Lists that are in-flight are left to finish
+// in the background.
+func (s *Source) Stop() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.stopped = true
+}
@@ -0,0 +1,101 @@
+package pick
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/cmd/internal/cmdtest"
+	"github.com/stretchr/testify/suite"
+)
+
+type SourceTestSuite struct {
+	*cmdtest.Suite
+}
+
+func newEntry(path string, cname string, isParent bool) apitypes.Entry {
+	e := apitypes.Entry{Path: path, CName: cname}
+	if isParent {
+		e.Actions = []string{"list"}
+	}
+	return e
+}
+
+func (s *SourceTestSuite) setupTree() {
+	s.Client.On("Info", ".").Return(newEntry("/mnt/docker", "docker", true), nil)
+	s.Client.On("ListFlat", "/mnt/docker").Return([]apitypes.Entry{
+		newEntry("/mnt/docker/containers", "containers", true),
+		newEntry("/mnt/docker/volumes", "volumes", true),
+	}, nil)
+	s.Client.On("ListFlat", "/mnt/docker/containers").Return([]apitypes.Entry{
+		newEntry("/mnt/docker/containers/web", "web", true),
+	}, nil)
+	s.Client.On("ListFlat", "/mnt/docker/containers/web").Return([]apitypes.Entry{
+		newEntry("/mnt/docker/containers/web/log", "log", false),
+	}, nil)
+	s.Client.On("ListFlat", "/mnt/docker/volumes").Return([]apitypes.Entry{}, fmt.Errorf("failed"))
+}
+
+func (s *SourceTestSuite) wait(source *Source) ([]string, int) {
+	for {
+		select {
+		case <-source.Updated():
+		case <-time.After(5 * time.Second):
+			s.Fail("timed out waiting for the source")
+			return nil, 0
+		}
+		paths, errors, done := source.Snapshot()
+		if done {
+			sorted := append([]string{}, paths...)
+			sort.Strings(sorted)
+			return sorted, errors
+		}
+	}
+}
+
+func (s *SourceTestSuite) TestSource() {
+	s.setupTree()
+	paths, errors := s.wait(NewSource(s.Client, ".", 5))
+	s.Equal([]string{
+		"./containers",
+		"./containers/web",
+		"./containers/web/log",
+		"./volumes",
+	}, paths)
+	s.Equal(1, errors)
+}
+
+func (s *SourceTestSuite) TestSource_Maxdepth() {
+	s.setupTree()
+	paths, _ := s.wait(NewSource(s.Client, ".", 1))
+	s.Equal([]string{"./containers", "./volumes"}, paths)
+	s.Client.AssertNotCalled(s.T(), "ListFlat", "/mnt/docker/containers")
+}
+
+func (s *SourceTestSuite) TestSource_InfoErrors() {
+	s.Client.On("Info", "/foo").Return(apitypes.Entry{}, fmt.Errorf("not found"))
+	paths, errors := s.wait(NewSource(s.Client, "/foo", 5))
+	s.Empty(paths)
+	s.Equal(1, errors)
+}
+
+func (s *SourceTestSuite) TestFilterAll() {
+	s.setupTree()
+	paths, errors := FilterAll(NewSource(s.Client, ".", 5), "web")
+	s.Equal([]string{"./containers/web", "./containers/web/log"}, paths)
+	s.Equal(1, errors)
+}
+
+func (s *SourceTestSuite) TestJoin() {
+	s.Equal("/docker", join("/", "docker"))
+	s.Equal("./docker", join(".", "docker"))
+	s.Equal("/kubernetes/ctx", join("/kubernetes", "ctx"))
+}
+
+func TestSource(t *testing.T) {
+	s := new(SourceTestSuite)
+	s.Suite = new(cmdtest.Suite)
+	suite.Run(t, s)
+}
@@ -0,0 +1,71 @@
+// Package render stores the content renderers of `wash cat`. A renderer
+// pretty-prints content of a known type (e.g. JSON) for the terminal. We make
+// it a separate package to decouple it from cmd. This makes testing easier.
+package render
+
+import (
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Renderer renders content to w. It returns an error if the content isn't
+// valid for the renderer's type so that the caller can fall back to the raw
+// content.
+type Renderer func(w io.Writer, content []byte) error
+
+var registryMux sync.RWMutex
+var renderers = make(map[string]Renderer)
+var extensions = make(map[string]string)
+
+// Register registers r as the renderer of the given content type. Content
+// without a declared type is rendered by r if its name has one of the
+// extensions (e.g. ".json"). If a renderer's already registered for the
+// content type, then r replaces it.
+func Register(contentType string, r Renderer, exts ...string) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	contentType = normalize(contentType)
+	renderers[contentType] = r
+	for _, ext := range exts {
+		extensions[strings.ToLower(ext)] = contentType
+	}
+}
+
+// For returns the renderer of the given content type. If contentType is
+// empty, then the renderer's found from name's extension instead.
+func For(contentType string, name string) (Renderer, bool) {
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+	if contentType == "" {
+		var ok bool
+		if contentType, ok = extensions[strings.ToLower(filepath.Ext(name))]; !ok {
+			return nil, false
+		}
+	}
+	contentType = normalize(contentType)
+	if r, ok := renderers[contentType]; ok {
+		return r, true
+	}
+	// Structured syntax suffixes (e.g. application/vnd.api+json) are rendered
+	// by the suffix's renderer
+	if ix := strings.LastIndex(contentType, "+"); ix >= 0 {
+		r, ok := renderers["application/"+contentType[ix+1:]]
+		return r, ok
+	}
+	return nil, false
+}
+
+// normalize strips contentType's parameters (e.g. "; charset=utf-8") and
+// lowercases it
+func normalize(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	if ix := strings.Index(contentType, ";"); ix >= 0 {
+		contentType = contentType[:ix]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
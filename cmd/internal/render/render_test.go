@@ -0,0 +1,106 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/suite"
+)
+
+type RenderTestSuite struct {
+	suite.Suite
+	noColor bool
+}
+
+func (s *RenderTestSuite) SetupTest() {
+	s.noColor = color.NoColor
+	color.NoColor = true
+}
+
+func (s *RenderTestSuite) TearDownTest() {
+	color.NoColor = s.noColor
+}
+
+func (s *RenderTestSuite) render(contentType string, name string, content string) string {
+	r, ok := For(contentType, name)
+	if !s.True(ok, "no renderer for %v (%v)", contentType, name) {
+		return ""
+	}
+	var out bytes.Buffer
+	s.NoError(r(&out, []byte(content)))
+	return out.String()
+}
+
+func (s *RenderTestSuite) TestFor() {
+	_, ok := For("application/octet-stream", "foo")
+	s.False(ok)
+	_, ok = For("", "foo")
+	s.False(ok)
+
+	s.Equal("{\n  \"a\": 1\n}\n", s.render("application/json; charset=utf-8", "foo", `{"a":1}`))
+	s.Equal("{\n  \"a\": 1\n}\n", s.render("Application/JSON", "foo", `{"a":1}`))
+	s.Equal("{\n  \"a\": 1\n}\n", s.render("application/vnd.api+json", "foo", `{"a":1}`))
+	// The extension's only used if the content type's unknown
+	s.Equal("{\n  \"a\": 1\n}\n", s.render("", "foo.JSON", `{"a":1}`))
+	_, ok = For("text/plain", "foo.json")
+	s.False(ok)
+}
+
+func (s *RenderTestSuite) TestRegister() {
+	Register("application/x-render-tests", func(w io.Writer, content []byte) error {
+		_, err := w.Write(bytes.ToUpper(content))
+		return err
+	}, ".rendertests")
+	s.Equal("FOO", s.render("application/x-render-tests", "", "foo"))
+	s.Equal("FOO", s.render("", "bar.rendertests", "foo"))
+}
+
+func (s *RenderTestSuite) TestJSON() {
+	s.Equal("{\n  \"a\": 1\n}\n{\n  \"b\": [\n    2\n  ]\n}\n", s.render("", "foo.ndjson", "{\"a\":1}\n{\"b\":[2]}\n"))
+
+	r, _ := For("application/json", "")
+	s.Error(r(&bytes.Buffer{}, []byte(`{"a":`)))
+}
+
+func (s *RenderTestSuite) TestYAML() {
+	content := "a: 1\nb:\n  - c: foo\n    d: \"e: f\"\n# g: h\n"
+	s.Equal(content, s.render("application/x-yaml", "", content))
+
+	r, _ := For("application/yaml", "")
+	s.Error(r(&bytes.Buffer{}, []byte("a: 1\n- b\n")))
+
+	color.NoColor = false
+	rendered := s.render("application/yaml", "", "a: 1\nb:\n  - c: foo\n")
+	s.Equal(keyColor.Sprint("a")+": 1\n"+keyColor.Sprint("b")+":\n  - "+keyColor.Sprint("c")+": foo\n", rendered)
+}
+
+func (s *RenderTestSuite) TestDelimited() {
+	s.Equal("NAME   SIZE\nfoo    1\nbar    10\n", s.render("text/csv", "", "NAME,SIZE\nfoo,1\nbar,10\n"))
+	s.Equal("NAME   SIZE\nfoo    1\n", s.render("", "foo.tsv", "NAME\tSIZE\nfoo\t1\n"))
+	s.Equal("", s.render("text/csv", "", ""))
+
+	// Records must have the same number of fields
+	r, _ := For("text/csv", "")
+	s.Error(r(&bytes.Buffer{}, []byte("a,b\nc\n")))
+}
+
+func (s *RenderTestSuite) TestLog() {
+	content := "2019-06-01 ERROR failed\nlevel=warn msg=slow\nINFO ok\nno level\n"
+	s.Equal(content, s.render("", "foo.log", content))
+
+	color.NoColor = false
+	rendered := s.render("text/x-log", "", "2019-06-01 ERROR failed\nlevel=debug msg=foo\nINFO ok\nerrors: 0")
+	s.Equal(
+		levelColors["error"].Sprint("2019-06-01 ERROR failed")+"\n"+
+			levelColors["debug"].Sprint("level=debug msg=foo")+"\n"+
+			"INFO ok\n"+
+			"errors: 0\n",
+		rendered,
+	)
+}
+
+func TestRender(t *testing.T) {
+	suite.Run(t, new(RenderTestSuite))
+}
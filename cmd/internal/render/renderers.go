@@ -0,0 +1,127 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/ghodss/yaml"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+)
+
+func init() {
+	Register("application/json", renderJSON, ".json")
+	// Newline-delimited JSON is decoded one value at a time, so it's rendered
+	// like JSON
+	Register("application/x-ndjson", renderJSON, ".ndjson", ".jsonl")
+	Register("application/yaml", renderYAML, ".yaml", ".yml")
+	Register("application/x-yaml", renderYAML)
+	Register("text/yaml", renderYAML)
+	Register("text/x-yaml", renderYAML)
+	Register("text/csv", renderDelimited(','), ".csv")
+	Register("text/tab-separated-values", renderDelimited('\t'), ".tsv")
+	Register("text/x-log", renderLog, ".log")
+}
+
+var keyColor = color.New(color.FgCyan)
+
+// renderJSON indents each of the content's JSON values
+func renderJSON(w io.Writer, content []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	var out bytes.Buffer
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := json.Indent(&out, value, "", "  "); err != nil {
+			return err
+		}
+		out.WriteString("\n")
+	}
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// yamlKeyRegex matches the key of a YAML mapping, including the key of a
+// mapping that's a sequence item
+var yamlKeyRegex = regexp.MustCompile(`^(\s*(?:-\s+)?)([^\s#'"-][^:#]*|'[^']*'|"[^"]*"):(\s|$)`)
+
+// renderYAML colors the content's mapping keys
+func renderYAML(w io.Writer, content []byte) error {
+	if _, err := yaml.YAMLToJSON(content); err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		out.WriteString(yamlKeyRegex.ReplaceAllStringFunc(line, func(match string) string {
+			groups := yamlKeyRegex.FindStringSubmatch(match)
+			return groups[1] + keyColor.Sprint(groups[2]) + ":" + groups[3]
+		}))
+	}
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// renderDelimited formats the content's records as a table. The first record
+// is the table's header.
+func renderDelimited(delimiter rune) Renderer {
+	return func(w io.Writer, content []byte) error {
+		reader := csv.NewReader(bytes.NewReader(content))
+		reader.Comma = delimiter
+		records, err := reader.ReadAll()
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		_, err = fmt.Fprint(w, cmdutil.NewTable(records...).Format())
+		return err
+	}
+}
+
+// logLevelRegex matches a log line's level. The first match is the line's
+// level.
+var logLevelRegex = regexp.MustCompile(`(?i)\b(fatal|panic|crit(?:ical)?|err(?:or)?|warn(?:ing)?|info|debug|trace)\b`)
+
+var levelColors = map[string]*color.Color{
+	"fatal":    color.New(color.FgRed, color.Bold),
+	"panic":    color.New(color.FgRed, color.Bold),
+	"crit":     color.New(color.FgRed, color.Bold),
+	"critical": color.New(color.FgRed, color.Bold),
+	"err":      color.New(color.FgRed),
+	"error":    color.New(color.FgRed),
+	"warn":     color.New(color.FgYellow),
+	"warning":  color.New(color.FgYellow),
+	"debug":    color.New(color.Faint),
+	"trace":    color.New(color.Faint),
+}
+
+// renderLog colors each of the content's lines by its log level. Lines
+// without a level (or with the info level) aren't colored.
+func renderLog(w io.Writer, content []byte) error {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if c, ok := levelColors[strings.ToLower(logLevelRegex.FindString(line))]; ok {
+			line = c.Sprint(line)
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	_, err := out.WriteTo(w)
+	return err
+}
@@ -3,16 +3,26 @@ package server
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"os"
 	"runtime/pprof"
+	"strings"
 	"time"
 
 	"github.com/Benchkram/errz"
 	"github.com/puppetlabs/wash/activity"
 	"github.com/puppetlabs/wash/analytics"
 	"github.com/puppetlabs/wash/api"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/features"
 	"github.com/puppetlabs/wash/fuse"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/ninep"
 	"github.com/puppetlabs/wash/plugin"
+	"github.com/puppetlabs/wash/retention"
+	"github.com/puppetlabs/wash/sftp"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -21,30 +31,88 @@ import (
 type Opts struct {
 	CPUProfilePath string
 	LogFile        string
+	// LogTarget can be "stdout", "file" (LogFile), "journald", or "syslog".
+	// It defaults to "file" if LogFile is set, and "stdout" otherwise.
+	LogTarget string
+	// LogRotation configures the rotation of LogFile. It's optional.
+	LogRotation LogRotation
+	// SyslogAddress is the syslog server's address for the "syslog" target,
+	// e.g. "udp://localhost:514". It defaults to the local syslog server.
+	SyslogAddress string
 	// LogLevel can be "warn", "info", "debug", or "trace".
 	LogLevel     string
 	PluginConfig map[string]map[string]interface{}
+	// Limits is a (possibly nested) map of limit names to values. See
+	// limits.Configure for more details.
+	Limits map[string]interface{}
+	// PersistLimit persists a limit that was tuned at runtime. It is optional.
+	PersistLimit func(name string, value int) error
+	// Features is a (possibly nested) map of feature flag names to booleans.
+	// See features.Configure for more details.
+	Features map[string]interface{}
+	// PersistFeature persists a feature flag that was toggled at runtime. It
+	// is optional.
+	PersistFeature func(key string, enabled bool) error
+	// Ownership maps entries' owners and groups to the local users and groups
+	// that own their FUSE files.
+	Ownership fuse.Ownership
+	// Faults are the initial fault injection rules. They're meant for testing
+	// how Wash behaves when plugins misbehave, so they require DevMode.
+	Faults []plugin.FaultRule
+	// DevMode enables the API's development endpoints, e.g. setting the fault
+	// injection rules at runtime.
+	DevMode bool
+	// Retention maps the names of Wash's on-disk stores (e.g. "journals") to
+	// their retention policy. Stores without a policy are never pruned.
+	Retention map[string]retention.Policy
+	// HTTP configures the HTTP clients that core plugins use to talk to their
+	// providers. Plugins can override it via their config's http key.
+	HTTP plugin.HTTPOptions
+	// ExecPolicy governs the commands that are executed on entries, e.g. by
+	// requiring consent to a banner. It's optional.
+	ExecPolicy plugin.ExecPolicy
+	// Handoff hands the cache off to the next server when the server stops,
+	// and re-warms the cache from the previous server's handoff when it
+	// starts. It's meant for restarts, e.g. upgrades, which would otherwise
+	// start with a cold cache.
+	Handoff bool
+	// ExternalPlugins are the specs of the external plugins. Their files are
+	// watched so that the plugins are reloaded when they change.
+	ExternalPlugins []plugin.ExternalPluginSpec
+	// ExternalPluginDirs are the directories that the plugin scripts were
+	// discovered in (see plugin.DiscoverExternalPlugins). They're watched so
+	// that new scripts are loaded without restarting the server.
+	ExternalPluginDirs []string
+	// SFTP configures the optional SFTP server, which serves the same
+	// entries as the FUSE filesystem.
+	SFTP sftp.Opts
+	// Filesystem is the filesystem server that serves the entries at the
+	// mountpoint. It can be "fuse" or "9p", and defaults to "fuse". The 9P
+	// server's meant for systems without FUSE (e.g. containers). Its
+	// filesystem must be mounted at the mountpoint separately.
+	Filesystem string
+	// NinePAddress is the address that the 9P server listens on. It
+	// defaults to ninep.DefaultAddress.
+	NinePAddress string
+	// PersistInodes keeps the FUSE files' inodes across restarts, so that
+	// a file has the same inode after the server's restarted.
+	PersistInodes bool
+	// MetadataHistory is the number of metadata snapshots that are retained
+	// per entry (see plugin.ConfigureMetadataHistory)
+	MetadataHistory int
 }
 
-// SetupLogging configures log level and output file according to configured options.
-// If an output file was configured, returns a handle for you to close later.
-func (o Opts) SetupLogging() (*os.File, error) {
+// SetupLogging configures log level and output according to configured options.
+// If the output needs to be closed (e.g. it's a file), returns a handle for you to
+// close later.
+func (o Opts) SetupLogging() (io.Closer, error) {
 	level, err := log.ParseLevel(o.LogLevel)
 	if err != nil {
 		return nil, fmt.Errorf("%v is not a valid level; use warn, info, debug, trace", o.LogLevel)
 	}
 
 	log.SetLevel(level)
-	if o.LogFile != "" {
-		logFH, err := os.Create(o.LogFile)
-		if err != nil {
-			return nil, err
-		}
-
-		log.SetOutput(logFH)
-		return logFH, nil
-	}
-	return nil, nil
+	return o.setupLogOutput()
 }
 
 type controlChannels struct {
@@ -57,11 +125,21 @@ type Server struct {
 	mountpoint      string
 	socket          string
 	opts            Opts
-	logFH           *os.File
+	logFH           io.Closer
 	api             controlChannels
 	fuse            controlChannels
 	plugins         map[string]plugin.Root
 	analyticsClient analytics.Client
+	pruner          controlChannels
+	registry        *plugin.Registry
+	rewarm          rewarm
+	pluginWatcher   *plugin.ExternalPluginWatcher
+	sftp            sftpControlChannels
+}
+
+type sftpControlChannels struct {
+	stopCh    chan<- struct{}
+	stoppedCh <-chan struct{}
 }
 
 // New creates a new Server. Accepts a list of core plugins to load.
@@ -76,6 +154,61 @@ func (s *Server) Start() error {
 		return err
 	}
 
+	if err := limits.Configure(s.opts.Limits); err != nil {
+		return fmt.Errorf("could not configure the limits: %v", err)
+	}
+	if s.opts.PersistLimit != nil {
+		limits.PersistWith(s.opts.PersistLimit)
+	}
+
+	if err := features.Configure(s.opts.Features); err != nil {
+		return fmt.Errorf("could not configure the feature flags: %v", err)
+	}
+	if s.opts.PersistFeature != nil {
+		features.PersistWith(s.opts.PersistFeature)
+	}
+
+	if len(s.opts.Faults) > 0 && !s.opts.DevMode {
+		return fmt.Errorf("the fault injection rules can only be applied in dev mode; set dev_mode or remove the faults")
+	}
+	if err := plugin.SetFaultRules(s.opts.Faults); err != nil {
+		return fmt.Errorf("could not configure the fault injection rules: %v", err)
+	}
+	if len(s.opts.Faults) > 0 {
+		log.Warnf("Fault injection is enabled. Plugin calls that match its rules will be delayed, fail, or be truncated.")
+	}
+
+	if err := plugin.SetHTTPOptions(s.opts.HTTP); err != nil {
+		return fmt.Errorf("could not configure the HTTP options: %v", err)
+	}
+
+	if err := plugin.SetExecPolicy(s.opts.ExecPolicy); err != nil {
+		return fmt.Errorf("could not configure the exec policy: %v", err)
+	}
+
+	if err := retention.Configure(s.opts.Retention); err != nil {
+		return fmt.Errorf("could not configure the retention policies: %v", err)
+	}
+
+	switch s.opts.Filesystem {
+	case "", "fuse", "9p":
+	default:
+		return fmt.Errorf("%v is not a valid filesystem; use fuse or 9p", s.opts.Filesystem)
+	}
+
+	if err := fuse.ConfigureOwnership(s.opts.Ownership); err != nil {
+		return fmt.Errorf("could not configure the ownership of Wash's files: %v", err)
+	}
+	fuse.ConfigureInodes(s.opts.PersistInodes)
+	if err := plugin.ConfigureMetadataHistory(s.opts.MetadataHistory); err != nil {
+		return fmt.Errorf("could not configure the metadata history: %v", err)
+	}
+
+	// External plugins get their workspace when they're initialized, so
+	// this needs to happen before the plugins are loaded
+	plugin.InitWorkspaces()
+	plugin.SetAPISocket(s.socket)
+
 	registry := plugin.NewRegistry()
 	s.loadPlugins(registry)
 	if len(registry.Plugins()) == 0 {
@@ -83,6 +216,17 @@ func (s *Server) Start() error {
 	}
 
 	plugin.InitCache()
+	s.registry = registry
+	if len(s.opts.ExternalPlugins) > 0 || len(s.opts.ExternalPluginDirs) > 0 {
+		// Failing to watch the plugins shouldn't fail the server. They can
+		// still be reloaded by restarting it.
+		if s.pluginWatcher, err = plugin.WatchExternalPlugins(registry, s.opts.ExternalPlugins, s.opts.ExternalPluginDirs, s.opts.PluginConfig); err != nil {
+			log.Warnf("External plugins won't be reloaded when they change: %v", err)
+		}
+	}
+	if s.opts.Handoff {
+		s.startRewarm(registry)
+	}
 
 	analyticsConfig, err := analytics.GetConfig()
 	if err != nil {
@@ -95,23 +239,30 @@ func (s *Server) Start() error {
 		s.mountpoint,
 		s.socket,
 		s.analyticsClient,
+		s.opts.DevMode,
 	)
 	if err != nil {
 		return err
 	}
 	s.api = controlChannels{stopCh: apiServerStopCh, stoppedCh: apiServerStoppedCh}
 
-	fuseServerStopCh, fuseServerStoppedCh, err := fuse.ServeFuseFS(
-		registry,
-		s.mountpoint,
-		s.analyticsClient,
-	)
+	fuseServerStopCh, fuseServerStoppedCh, err := s.serveFilesystem(registry)
 	if err != nil {
 		s.stopAPIServer()
 		return err
 	}
 	s.fuse = controlChannels{stopCh: fuseServerStopCh, stoppedCh: fuseServerStoppedCh}
 
+	if s.opts.SFTP.Enabled() {
+		sftpServerStopCh, sftpServerStoppedCh, err := sftp.ServeSFTP(registry, s.opts.SFTP)
+		if err != nil {
+			s.stopAPIServer()
+			s.stopFUSEServer()
+			return fmt.Errorf("could not start the SFTP server: %v", err)
+		}
+		s.sftp = sftpControlChannels{stopCh: sftpServerStopCh, stoppedCh: sftpServerStoppedCh}
+	}
+
 	if s.opts.CPUProfilePath != "" {
 		f, err := os.Create(s.opts.CPUProfilePath)
 		if err != nil {
@@ -120,6 +271,8 @@ func (s *Server) Start() error {
 		errz.Fatal(pprof.StartCPUProfile(f))
 	}
 
+	s.startPruner()
+
 	// Submit the initial start-up ping to GA. It's OK to do this synchronously
 	// because this is the first hit so the analytics client will not send it
 	// over the network.
@@ -130,6 +283,49 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// serveFilesystem starts the selected filesystem server. It's controlled via
+// s.fuse even if it's the 9P server.
+func (s *Server) serveFilesystem(registry *plugin.Registry) (chan<- context.Context, <-chan struct{}, error) {
+	if s.opts.Filesystem != "9p" {
+		return fuse.ServeFuseFS(registry, s.mountpoint, s.analyticsClient)
+	}
+	address := s.opts.NinePAddress
+	if address == "" {
+		var err error
+		if address, err = ninep.DefaultAddress(); err != nil {
+			return nil, nil, fmt.Errorf("could not determine the 9P server's address: %v", err)
+		}
+	}
+	// TCP clients authenticate with the API's admin token
+	adminToken, err := ioutil.ReadFile(apitypes.AdminTokenPath(s.socket))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read the admin token: %v", err)
+	}
+	stopCh, stoppedCh, err := ninep.Serve9P(registry, address, string(adminToken))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not start the 9P server: %v", err)
+	}
+	log.Warnf("The filesystem is served over 9P. Mount it at %v with %v", s.mountpoint, ninepMountCommand(address, s.mountpoint))
+	return stopCh, stoppedCh, nil
+}
+
+// ninepMountCommand returns the command that mounts the 9P filesystem with
+// Linux's 9P client
+func ninepMountCommand(address string, mountpoint string) string {
+	const opts = "version=9p2000,access=any,cache=none"
+	if socket := strings.TrimPrefix(address, "unix:"); socket != address {
+		return fmt.Sprintf("sudo mount -t 9p -o trans=unix,%v %v %v", opts, socket, mountpoint)
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Sprintf("sudo mount -t 9p -o trans=tcp,%v %v %v", opts, address, mountpoint)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return fmt.Sprintf("sudo mount -t 9p -o trans=tcp,port=%v,%v %v %v", port, opts, host, mountpoint)
+}
+
 func (s *Server) stopAPIServer() {
 	// Shutdown the API server; wait for the shutdown to finish
 	apiShutdownDeadline := time.Now().Add(3 * time.Second)
@@ -146,14 +342,41 @@ func (s *Server) stopFUSEServer() {
 	<-s.fuse.stoppedCh
 }
 
+func (s *Server) stopSFTPServer() {
+	if s.sftp.stopCh == nil {
+		return
+	}
+	// Shutdown the SFTP server; wait for the shutdown to finish
+	close(s.sftp.stopCh)
+	<-s.sftp.stoppedCh
+}
+
 func (s *Server) shutdown() {
 	if s.opts.CPUProfilePath != "" {
 		pprof.StopCPUProfile()
 	}
 
+	s.stopSFTPServer()
+	s.stopPruner()
+	if s.pluginWatcher != nil {
+		s.pluginWatcher.Stop()
+	}
+
+	// The handoff's captured before the daemons are stopped since listing
+	// their entries is what populated the cache
+	s.stopRewarm()
+	if s.opts.Handoff && s.registry != nil {
+		s.saveHandoff(s.registry)
+	}
+
 	// Close any open journals on shutdown to ensure remaining entries are flushed to disk.
 	activity.CloseAll()
 
+	plugin.StopWatches()
+	plugin.StopHealthChecks()
+	plugin.StopDaemons()
+	plugin.RemoveWorkspaces()
+
 	// Flush any outstanding analytics hits. We do this asynchronously
 	// so that the server process isn't blocked on its cleanup (in case
 	// the network is slow).
@@ -203,12 +426,6 @@ func (s *Server) Stop() {
 
 func (s *Server) loadPlugins(registry *plugin.Registry) {
 	log.Debug("Loading plugins")
-	for name, root := range s.plugins {
-		log.Infof("Loading %v", name)
-		if err := registry.RegisterPlugin(root, s.opts.PluginConfig[name]); err != nil {
-			// %+v is a convention used by some errors to print additional context such as a stack trace
-			log.Warnf("%v failed to load: %+v", name, err)
-		}
-	}
+	registry.RegisterPlugins(s.plugins, s.opts.PluginConfig)
 	log.Debug("Finished loading plugins")
 }
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/puppetlabs/wash/plugin/snapshot"
+	log "github.com/sirupsen/logrus"
+)
+
+// startRewarm re-warms the cache from the previous server's handoff (if any)
+// in the background so that the server can serve requests immediately.
+// Requests that aren't re-warmed yet are fetched like they would be
+// otherwise.
+func (s *Server) startRewarm(registry *plugin.Registry) {
+	handoff, err := snapshot.TakeHandoff()
+	if err != nil {
+		log.Warnf("Failed to load the previous server's handoff: %v", err)
+		return
+	}
+	if handoff == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.rewarm = rewarm{cancel: cancel, done: done}
+	go func() {
+		defer close(done)
+		log.Infof("Re-warming the cache from the handoff of the server that stopped at %v", handoff.Time.Format(time.RFC3339))
+		start := time.Now()
+		stats := plugin.Rewarm(ctx, registry, handoff)
+		log.Infof(
+			"Re-warmed the cache in %v: %v lists (%v of which failed), and %v contents and %v metadata imported from the handoff (%v had expired)",
+			time.Since(start).Round(time.Millisecond),
+			stats.Lists,
+			stats.Failures,
+			stats.Content,
+			stats.Metadata,
+			stats.Expired,
+		)
+	}()
+}
+
+type rewarm struct {
+	cancel context.CancelFunc
+	done   <-chan struct{}
+}
+
+func (s *Server) stopRewarm() {
+	if s.rewarm.cancel == nil {
+		return
+	}
+	s.rewarm.cancel()
+	<-s.rewarm.done
+}
+
+// saveHandoff saves the snapshot of the cache for the next server
+func (s *Server) saveHandoff(registry *plugin.Registry) {
+	handoff := plugin.CaptureSnapshot(registry, "handoff")
+	if err := snapshot.SaveHandoff(handoff); err != nil {
+		log.Warnf("Failed to save the handoff for the next server: %v", err)
+		return
+	}
+	log.Infof("Saved the handoff for the next server (%v entries)", len(handoff.Entries))
+}
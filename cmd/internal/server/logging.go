@@ -0,0 +1,373 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/syslog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+	lSyslog "github.com/sirupsen/logrus/hooks/syslog"
+)
+
+// The log targets
+const (
+	StdoutTarget   = "stdout"
+	FileTarget     = "file"
+	JournaldTarget = "journald"
+	SyslogTarget   = "syslog"
+)
+
+// LogRotation configures the rotation of the log file. A zero LogRotation
+// disables rotation.
+type LogRotation struct {
+	// MaxSizeMB rotates the log file once it's bigger than this many MB.
+	MaxSizeMB int `mapstructure:"max_size_mb"`
+	// MaxAgeDays rotates the log file once it's older than this many days,
+	// and deletes rotated log files that are older than it.
+	MaxAgeDays int `mapstructure:"max_age_days"`
+	// MaxBackups deletes the oldest rotated log files once there's more than
+	// this many of them.
+	MaxBackups int `mapstructure:"max_backups"`
+}
+
+func (r LogRotation) enabled() bool {
+	return r.MaxSizeMB > 0 || r.MaxAgeDays > 0 || r.MaxBackups > 0
+}
+
+// logTarget returns the log target, defaulting it according to whether a log
+// file was configured
+func (o Opts) logTarget() string {
+	if o.LogTarget != "" {
+		return o.LogTarget
+	}
+	if o.LogFile != "" {
+		return FileTarget
+	}
+	return StdoutTarget
+}
+
+// setupLogOutput configures the log's output according to the log target. It
+// returns the output (or hook) so that it can be closed later.
+func (o Opts) setupLogOutput() (io.Closer, error) {
+	switch target := o.logTarget(); target {
+	case StdoutTarget:
+		return nil, nil
+	case FileTarget:
+		if o.LogFile == "" {
+			return nil, fmt.Errorf("the %v log target requires a log file", target)
+		}
+		if !o.LogRotation.enabled() {
+			logFH, err := os.Create(o.LogFile)
+			if err != nil {
+				return nil, err
+			}
+			log.SetOutput(logFH)
+			return logFH, nil
+		}
+		logFile, err := newRotatingFile(o.LogFile, o.LogRotation)
+		if err != nil {
+			return nil, err
+		}
+		log.SetOutput(logFile)
+		return logFile, nil
+	case JournaldTarget:
+		hook, err := newJournaldHook(journaldSocket)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to journald: %v", err)
+		}
+		log.AddHook(hook)
+		log.SetOutput(ioutil.Discard)
+		return hook, nil
+	case SyslogTarget:
+		var network, raddr string
+		if o.SyslogAddress != "" {
+			u, err := url.Parse(o.SyslogAddress)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return nil, fmt.Errorf("%v is not a valid syslog address; use <network>://<host>:<port>, e.g. udp://localhost:514", o.SyslogAddress)
+			}
+			network, raddr = u.Scheme, u.Host
+		}
+		hook, err := lSyslog.NewSyslogHook(network, raddr, syslog.LOG_DAEMON|syslog.LOG_INFO, "wash")
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to syslog: %v", err)
+		}
+		log.AddHook(hook)
+		log.SetOutput(ioutil.Discard)
+		return hook.Writer, nil
+	default:
+		return nil, fmt.Errorf("%v is not a valid log target; use %v, %v, %v, or %v", target, StdoutTarget, FileTarget, JournaldTarget, SyslogTarget)
+	}
+}
+
+// rotatingFile is a log file that's rotated according to a LogRotation.
+// Rotated files are named <path>.<timestamp>, where the timestamp's when they
+// were rotated.
+type rotatingFile struct {
+	path     string
+	rotation LogRotation
+	mux      sync.Mutex
+	file     *os.File
+	size     int64
+	// started is when the log file was started, which is used to rotate it
+	// by age
+	started time.Time
+	now     func() time.Time
+}
+
+const rotatedFileTimeFormat = "20060102T150405.000"
+
+func newRotatingFile(path string, rotation LogRotation) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, rotation: rotation, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	f.prune()
+	return f, nil
+}
+
+// open opens the log file for appending so that restarting the server doesn't
+// lose the logs that haven't been rotated yet. An existing log file was
+// started when the previous one was rotated, or when it was opened if it
+// wasn't rotated yet.
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.started = file, info.Size(), f.now()
+	if backups := f.backups(); f.size > 0 && len(backups) > 0 && backups[0].rotated.Before(f.started) {
+		f.started = backups[0].rotated
+	}
+	return nil
+}
+
+// shouldRotate returns true if writing n more bytes to the log file makes it
+// too big, or if it's too old. Empty log files aren't rotated.
+func (f *rotatingFile) shouldRotate(n int) bool {
+	if f.size == 0 {
+		return false
+	}
+	maxSize := int64(f.rotation.MaxSizeMB) * 1024 * 1024
+	if maxSize > 0 && f.size+int64(n) > maxSize {
+		return true
+	}
+	maxAge := time.Duration(f.rotation.MaxAgeDays) * 24 * time.Hour
+	return maxAge > 0 && f.now().Sub(f.started) >= maxAge
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.shouldRotate(len(p)) {
+		if err := f.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to rotate the log file %v: %v\n", f.path, err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current log file and opens a new one. It must be called
+// with f.mux held.
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	rotatedPath := f.path + "." + f.now().Format(rotatedFileTimeFormat)
+	if err := os.Rename(f.path, rotatedPath); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+type logBackup struct {
+	path    string
+	rotated time.Time
+}
+
+// backups returns the rotated log files, newest first
+func (f *rotatingFile) backups() []logBackup {
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return nil
+	}
+	var backups []logBackup
+	for _, match := range matches {
+		rotated, err := time.ParseInLocation(rotatedFileTimeFormat, strings.TrimPrefix(match, f.path+"."), time.Local)
+		if err != nil {
+			// Not a rotated log file
+			continue
+		}
+		backups = append(backups, logBackup{match, rotated})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].rotated.After(backups[j].rotated) })
+	return backups
+}
+
+// prune deletes the rotated log files that are too old or that exceed
+// MaxBackups
+func (f *rotatingFile) prune() {
+	maxAge := time.Duration(f.rotation.MaxAgeDays) * 24 * time.Hour
+	for i, b := range f.backups() {
+		tooMany := f.rotation.MaxBackups > 0 && i >= f.rotation.MaxBackups
+		tooOld := maxAge > 0 && f.now().Sub(b.rotated) > maxAge
+		if tooMany || tooOld {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "Failed to delete the rotated log file %v: %v\n", b.path, err)
+			}
+		}
+	}
+}
+
+func (f *rotatingFile) Close() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.file.Close()
+}
+
+const journaldSocket = "/run/systemd/journal/socket"
+
+// journaldHook sends log entries, including their fields, to journald via its
+// native protocol
+type journaldHook struct {
+	conn net.Conn
+}
+
+func newJournaldHook(socket string) (*journaldHook, error) {
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return nil, err
+	}
+	return &journaldHook{conn: conn}, nil
+}
+
+func (h *journaldHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *journaldHook) Fire(entry *log.Entry) error {
+	_, err := h.conn.Write(journaldMessage(entry))
+	return err
+}
+
+func (h *journaldHook) Close() error {
+	return h.conn.Close()
+}
+
+// Datagrams that are bigger than the socket's send buffer (usually ~200KB)
+// are rejected, so journald messages are truncated to fit. Big values are
+// usually a single field (e.g. a plugin's stderr), so fields are capped before
+// the message is.
+const (
+	journaldMaxMessageSize = 128 * 1024
+	journaldMaxFieldSize   = 8 * 1024
+	journaldTruncated      = "... (truncated)"
+)
+
+// journaldMessage serializes entry in journald's native protocol. See
+// https://systemd.io/JOURNAL_NATIVE_PROTOCOL/. Messages are truncated to
+// journaldMaxMessageSize.
+func journaldMessage(entry *log.Entry) []byte {
+	var fields bytes.Buffer
+	writeJournaldField(&fields, "PRIORITY", journaldPriority(entry.Level))
+	writeJournaldField(&fields, "SYSLOG_IDENTIFIER", "wash")
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := truncateJournaldValue(fmt.Sprint(entry.Data[key]), journaldMaxFieldSize)
+		writeJournaldField(&fields, journaldFieldName(key), value)
+	}
+
+	// The message is written as <name>\n<64-bit LE length><value>\n if it's
+	// multi-line, which is the biggest that its framing can be
+	framing := len("MESSAGE\n") + 8 + len("\n")
+	var buf bytes.Buffer
+	writeJournaldField(&buf, "MESSAGE", truncateJournaldValue(entry.Message, journaldMaxMessageSize-fields.Len()-framing))
+	buf.Write(fields.Bytes())
+	return buf.Bytes()
+}
+
+// truncateJournaldValue truncates value to at most max bytes, marking it as
+// truncated. It's truncated at a UTF-8 character boundary.
+func truncateJournaldValue(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	n := max - len(journaldTruncated)
+	if n < 0 {
+		n = 0
+	}
+	for n > 0 && !utf8.RuneStart(value[n]) {
+		n--
+	}
+	return value[:n] + journaldTruncated
+}
+
+func writeJournaldField(buf *bytes.Buffer, name string, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(name + "=" + value + "\n")
+		return
+	}
+	// Values with newlines are written as <name>\n<64-bit LE length><value>\n
+	buf.WriteString(name + "\n")
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
+
+// journaldFieldName converts key into a valid journald field name, which
+// consists of uppercase letters, digits, and underscores, and which doesn't
+// start with an underscore (those are reserved for trusted fields)
+func journaldFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			name[i] = '_'
+		}
+	}
+	trimmed := strings.TrimLeft(string(name), "_")
+	if trimmed == "" || trimmed[0] >= '0' && trimmed[0] <= '9' {
+		trimmed = "FIELD_" + trimmed
+	}
+	return trimmed
+}
+
+func journaldPriority(level log.Level) string {
+	switch level {
+	case log.PanicLevel, log.FatalLevel:
+		return "2"
+	case log.ErrorLevel:
+		return "3"
+	case log.WarnLevel:
+		return "4"
+	case log.InfoLevel:
+		return "6"
+	default:
+		return "7"
+	}
+}
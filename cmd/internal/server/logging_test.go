@@ -0,0 +1,206 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoggingTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (s *LoggingTestSuite) SetupTest() {
+	var err error
+	s.dir, err = ioutil.TempDir("", "wash-logging-test")
+	s.NoError(err)
+}
+
+func (s *LoggingTestSuite) TearDownTest() {
+	s.NoError(os.RemoveAll(s.dir))
+}
+
+func (s *LoggingTestSuite) logFiles() []string {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "wash.log*"))
+	s.NoError(err)
+	sort.Strings(matches)
+	return matches
+}
+
+func (s *LoggingTestSuite) TestLogTarget() {
+	s.Equal(StdoutTarget, Opts{}.logTarget())
+	s.Equal(FileTarget, Opts{LogFile: "wash.log"}.logTarget())
+	s.Equal(JournaldTarget, Opts{LogFile: "wash.log", LogTarget: JournaldTarget}.logTarget())
+
+	_, err := Opts{LogTarget: "foo"}.setupLogOutput()
+	s.Regexp("foo is not a valid log target", err)
+	_, err = Opts{LogTarget: FileTarget}.setupLogOutput()
+	s.Regexp("requires a log file", err)
+	_, err = Opts{LogTarget: SyslogTarget, SyslogAddress: "localhost"}.setupLogOutput()
+	s.Regexp("not a valid syslog address", err)
+}
+
+func (s *LoggingTestSuite) TestRotatingFile_RotatesBySize() {
+	path := filepath.Join(s.dir, "wash.log")
+	f, err := newRotatingFile(path, LogRotation{MaxSizeMB: 1, MaxBackups: 2})
+	if !s.NoError(err) {
+		return
+	}
+	defer func() { s.NoError(f.Close()) }()
+	now := time.Date(2019, 1, 2, 3, 4, 5, 0, time.Local)
+	f.now = func() time.Time { return now }
+
+	chunk := make([]byte, 700*1024)
+	for i := 0; i < 4; i++ {
+		_, err := f.Write(chunk)
+		s.NoError(err)
+		now = now.Add(time.Second)
+	}
+
+	// 4 chunks fill 4 files, the oldest of which was pruned
+	files := s.logFiles()
+	s.Equal([]string{path, path + ".20190102T030407.000", path + ".20190102T030408.000"}, files)
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if s.NoError(err) {
+			s.Equal(int64(len(chunk)), info.Size())
+		}
+	}
+}
+
+func (s *LoggingTestSuite) TestRotatingFile_PrunesOldFiles() {
+	path := filepath.Join(s.dir, "wash.log")
+	now := time.Now()
+	old := path + "." + now.Add(-48*time.Hour).Format(rotatedFileTimeFormat)
+	recent := path + "." + now.Add(-1*time.Hour).Format(rotatedFileTimeFormat)
+	other := path + ".bak"
+	for _, file := range []string{old, recent, other} {
+		s.NoError(ioutil.WriteFile(file, []byte("foo"), 0644))
+	}
+	s.NoError(ioutil.WriteFile(path, []byte("existing\n"), 0644))
+
+	f, err := newRotatingFile(path, LogRotation{MaxAgeDays: 1})
+	if !s.NoError(err) {
+		return
+	}
+	_, err = f.Write([]byte("appended\n"))
+	s.NoError(err)
+	s.NoError(f.Close())
+
+	s.Equal([]string{path, recent, other}, s.logFiles())
+	content, err := ioutil.ReadFile(path)
+	if s.NoError(err) {
+		s.Equal("existing\nappended\n", string(content))
+	}
+}
+
+func (s *LoggingTestSuite) TestRotatingFile_RotatesByAge() {
+	path := filepath.Join(s.dir, "wash.log")
+	s.NoError(ioutil.WriteFile(path, []byte("existing\n"), 0644))
+	now := time.Date(2019, 1, 2, 3, 4, 5, 0, time.Local)
+	// The existing log file was started when the last one was rotated
+	rotated := path + "." + now.Add(-36*time.Hour).Format(rotatedFileTimeFormat)
+	s.NoError(ioutil.WriteFile(rotated, []byte("foo"), 0644))
+
+	f := &rotatingFile{path: path, rotation: LogRotation{MaxAgeDays: 2}, now: func() time.Time { return now }}
+	if !s.NoError(f.open()) {
+		return
+	}
+	defer func() { s.NoError(f.Close()) }()
+	_, err := f.Write([]byte("fresh\n"))
+	s.NoError(err)
+	s.Equal([]string{path, rotated}, s.logFiles())
+
+	now = now.Add(12 * time.Hour)
+	_, err = f.Write([]byte("old\n"))
+	s.NoError(err)
+	s.Equal([]string{path, rotated, path + ".20190102T150405.000"}, s.logFiles())
+	content, err := ioutil.ReadFile(path)
+	if s.NoError(err) {
+		s.Equal("old\n", string(content))
+	}
+	content, err = ioutil.ReadFile(path + ".20190102T150405.000")
+	if s.NoError(err) {
+		s.Equal("existing\nfresh\n", string(content))
+	}
+
+	// The new log file's age is counted from its rotation
+	now = now.Add(47 * time.Hour)
+	_, err = f.Write([]byte("recent\n"))
+	s.NoError(err)
+	s.Len(s.logFiles(), 3)
+}
+
+func (s *LoggingTestSuite) TestJournaldMessage_TruncatesBigValues() {
+	entry := log.NewEntry(log.New()).WithFields(log.Fields{
+		"stderr": strings.Repeat("x", 2*journaldMaxFieldSize),
+	})
+	entry.Message = strings.Repeat("é", journaldMaxMessageSize)
+	entry.Level = log.InfoLevel
+
+	msg := journaldMessage(entry)
+	s.True(len(msg) <= journaldMaxMessageSize, "the message is %v bytes", len(msg))
+	s.Contains(string(msg), "STDERR="+strings.Repeat("x", journaldMaxFieldSize-len(journaldTruncated))+journaldTruncated+"\n")
+	s.True(utf8.Valid(msg[len("MESSAGE="):]))
+	s.Contains(string(msg), "é"+journaldTruncated+"\nPRIORITY=6\n")
+
+	s.Equal("foo", truncateJournaldValue("foo", 3))
+	s.Equal("f"+journaldTruncated, truncateJournaldValue("foobar"+strings.Repeat("x", 20), 1+len(journaldTruncated)))
+}
+
+func (s *LoggingTestSuite) TestJournaldMessage() {
+	entry := log.NewEntry(log.New()).WithFields(log.Fields{
+		"plugin":       "docker",
+		"_private":     "x",
+		"elapsed-time": time.Second,
+	})
+	entry.Message = "line1\nline2"
+	entry.Level = log.WarnLevel
+
+	expected := "MESSAGE\n\x0b\x00\x00\x00\x00\x00\x00\x00line1\nline2\n" +
+		"PRIORITY=4\n" +
+		"SYSLOG_IDENTIFIER=wash\n" +
+		"PRIVATE=x\n" +
+		"ELAPSED_TIME=1s\n" +
+		"PLUGIN=docker\n"
+	s.Equal(expected, string(journaldMessage(entry)))
+}
+
+func (s *LoggingTestSuite) TestJournaldHook() {
+	socket := filepath.Join(s.dir, "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if !s.NoError(err) {
+		return
+	}
+	defer conn.Close()
+
+	hook, err := newJournaldHook(socket)
+	if !s.NoError(err) {
+		return
+	}
+	defer func() { s.NoError(hook.Close()) }()
+	entry := log.NewEntry(log.New())
+	entry.Message = "hello"
+	entry.Level = log.InfoLevel
+	s.NoError(hook.Fire(entry))
+
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if s.NoError(err) {
+		s.Equal("MESSAGE=hello\nPRIORITY=6\nSYSLOG_IDENTIFIER=wash\n", string(buf[:n]))
+	}
+}
+
+func TestLogging(t *testing.T) {
+	suite.Run(t, new(LoggingTestSuite))
+}
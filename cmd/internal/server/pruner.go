@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/puppetlabs/wash/retention"
+	log "github.com/sirupsen/logrus"
+)
+
+// pruneInterval is how often the pruner prunes Wash's on-disk stores
+const pruneInterval = time.Hour
+
+// startPruner starts pruning Wash's on-disk stores (e.g. its activity
+// journals) according to their retention policies, first on startup and then
+// every pruneInterval. Long-running servers would otherwise accumulate them
+// indefinitely.
+func (s *Server) startPruner() {
+	stopCh := make(chan context.Context)
+	stoppedCh := make(chan struct{})
+	s.pruner = controlChannels{stopCh: stopCh, stoppedCh: stoppedCh}
+	go func() {
+		defer close(stoppedCh)
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+		for {
+			prune()
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Server) stopPruner() {
+	if s.pruner.stopCh == nil {
+		return
+	}
+	// Wait for any in-progress prune to finish so that it doesn't race
+	// with the shutdown
+	close(s.pruner.stopCh)
+	<-s.pruner.stoppedCh
+}
+
+func prune() {
+	results, err := retention.PruneAll(nil)
+	if err != nil {
+		log.Warnf("Failed to prune Wash's on-disk stores: %v", err)
+	}
+	for _, result := range results {
+		if len(result.Removed) > 0 {
+			log.Infof("Pruned %v %v (%v bytes); %v bytes remain", len(result.Removed), result.Store, result.RemovedBytes, result.RemainingBytes)
+		}
+	}
+}
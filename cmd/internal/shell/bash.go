@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
 type bash struct {
@@ -14,7 +15,8 @@ type bash struct {
 func (b bash) Command(subcommands []string, rundir string) (*exec.Cmd, error) {
 	// Generate and invoke custom .bashenv and .bashrc files.
 	// - .bashenv will alias subcommands, then load ~/.washenv (if present).
-	// - .bashrc will load ~/.bashrc (if ~/.washrc is absent), then configure the prompt,
+	// - .bashrc will load ~/.bashrc (if ~/.washrc is absent), then configure the prompt
+	//   (including the resource context from `wash whereami`) and the subcommands' completions,
 	//   then load ~/.washrc (if present).
 
 	envpath := filepath.Join(rundir, ".bashenv")
@@ -38,12 +40,21 @@ func (b bash) Command(subcommands []string, rundir string) (*exec.Cmd, error) {
 	content = `source ` + envpath + `
 [[ -s ~/.bashrc && ! -s ~/.washrc ]] && source ~/.bashrc
 
+` + contextHook + `
 WASH_BASE=$(pwd)
 function prompter() {
-	export PS1="\e[0;36mwash $(realpath --relative-to=$WASH_BASE $(pwd))\e[0;32m ❯\e[m "
+	__wash_update_context
+	export PS1="\e[0;36mwash $(realpath --relative-to=$WASH_BASE $(pwd))\e[0;33m${WASH_CONTEXT:+ [$WASH_CONTEXT]}\e[0;32m ❯\e[m "
 }
 export PROMPT_COMMAND=prompter
 
+# Complete the subcommands' paths with the entries that they can use.
+function __wash_complete() {
+	local IFS=$'\n'
+	COMPREPLY=($(compgen -f -- "${COMP_WORDS[COMP_CWORD]}" | WASH_EMBEDDED=1 wash __complete "$1" "${COMP_WORDS[@]:1:COMP_CWORD-1}"))
+}
+complete -o filenames -F __wash_complete ` + strings.Join(subcommands, " ") + `
+
 [[ -s ~/.washrc ]] && source ~/.washrc
 `
 	if err := ioutil.WriteFile(rcpath, []byte(content), 0644); err != nil {
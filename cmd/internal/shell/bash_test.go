@@ -28,5 +28,9 @@ func TestBash(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Contains(t, string(bits), "alias help='WASH_EMBEDDED=1 wash help'")
 
-	assert.FileExists(t, filepath.Join(tmpdir, ".bashrc"))
+	bashrc := filepath.Join(tmpdir, ".bashrc")
+	assert.FileExists(t, bashrc)
+	bits, err = ioutil.ReadFile(bashrc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(bits), "complete -o filenames -F __wash_complete help\n")
 }
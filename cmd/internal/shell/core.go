@@ -21,6 +21,9 @@ type Shell interface {
 	// Additionally for interactive invocations they should:
 	//   1. if ~/.washrc does not exist, load the shell's default interactive config
 	//   1. configure the prompt
+	//   1. complete the subcommands' args with the shell's candidates that
+	//      `WASH_EMBEDDED=1 wash __complete <subcommand> <preceding args>` prints when
+	//      they're written to its stdin
 	//   1. if ~/.washrc exists, load it
 	Command(subcommands []string, rundir string) (*exec.Cmd, error)
 }
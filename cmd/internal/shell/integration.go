@@ -0,0 +1,36 @@
+package shell
+
+import "fmt"
+
+// contextHook defines a function that sets WASH_CONTEXT to the current
+// directory's resource context. It only asks Wash for the context when the
+// directory changes, so it's cheap to invoke on every prompt. It works in
+// both bash and zsh.
+const contextHook = `function __wash_update_context() {
+  if [[ "$PWD" != "$__WASH_CONTEXT_PWD" ]]; then
+    __WASH_CONTEXT_PWD="$PWD"
+    WASH_CONTEXT="$(wash whereami 2>/dev/null)"
+  fi
+}
+`
+
+// Integration returns a script that integrates the named shell with a running
+// Wash server. The script updates WASH_CONTEXT whenever the user changes
+// directories and adds it to the user's prompt. Users source it from their own
+// shell config, e.g. with `eval "$(wash whereami --init bash)"` in ~/.bashrc.
+func Integration(shellName string) (string, error) {
+	switch shellName {
+	case "bash":
+		return contextHook + `PROMPT_COMMAND="__wash_update_context${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+PS1='${WASH_CONTEXT:+[$WASH_CONTEXT] }'"$PS1"
+`, nil
+	case "zsh":
+		return contextHook + `autoload -Uz add-zsh-hook
+add-zsh-hook precmd __wash_update_context
+setopt PROMPT_SUBST
+PROMPT='${WASH_CONTEXT:+[$WASH_CONTEXT] }'"$PROMPT"
+`, nil
+	default:
+		return "", fmt.Errorf("shell integration is not supported for %v; supported shells are bash and zsh", shellName)
+	}
+}
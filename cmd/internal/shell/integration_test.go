@@ -0,0 +1,20 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegration(t *testing.T) {
+	for _, sh := range []string{"bash", "zsh"} {
+		script, err := Integration(sh)
+		if assert.NoError(t, err) {
+			assert.Contains(t, script, contextHook)
+			assert.Contains(t, script, "${WASH_CONTEXT:+[$WASH_CONTEXT] }")
+		}
+	}
+
+	_, err := Integration("fish")
+	assert.EqualError(t, err, "shell integration is not supported for fish; supported shells are bash and zsh")
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
 type zsh struct {
@@ -15,8 +16,9 @@ func (z zsh) Command(subcommands []string, rundir string) (*exec.Cmd, error) {
 	// Generate and invoke custom .zshenv and .zshrc files.
 	// - .zshenv will load ~/.zshenv (if ~/.washenv is absent), then alias subcommands,
 	//   then load ~/.washenv (if present).
-	// - .zshrc will load ~/.zshrc (if ~/.washrc is absent), then configure the prompt,
-	//   then load ~/.washrc (if present).
+	// - .zshrc will load ~/.zshrc (if ~/.washrc is absent), then configure the prompt
+	//   (including the resource context from `wash whereami`) and the subcommands' completions
+	//   (if compinit was loaded), then load ~/.washrc (if present).
 
 	cmd := exec.Command(z.sh)
 	// Override ZDOTDIR so zsh looks for our configs, but save the original ZDOTDIR so we can use it
@@ -47,14 +49,28 @@ fi
   if [[ -s "${ZDOTDIR:-$HOME}/.zshrc" ]]; then source "${ZDOTDIR:-$HOME}/.zshrc"; fi
 fi
 
+` + contextHook + `
 WASH_BASE=$(pwd)
 function prompter() {
-  PROMPT="%F{cyan}wash $(realpath --relative-to=$WASH_BASE $(pwd))%F{green} ❯%f "
+  __wash_update_context
+  PROMPT="%F{cyan}wash $(realpath --relative-to=$WASH_BASE $(pwd))%F{yellow}${WASH_CONTEXT:+ [$WASH_CONTEXT]}%F{green} ❯%f "
 }
 
 autoload -Uz add-zsh-hook
 add-zsh-hook precmd prompter
 
+# Complete the subcommands' paths with the entries that they can use. Aliases are expanded
+# before they're completed unless COMPLETE_ALIASES is set, in which case words[1] is the alias.
+function __wash_complete() {
+  local -a args candidates
+  args=(${words[1,CURRENT-1]})
+  if [[ $args[1] == wash ]]; then args=(${args[2,-1]}); fi
+  if (( $#args == 0 )); then _default; return; fi
+  candidates=(${(f)"$(print -rl -- ${PREFIX}*(N) | WASH_EMBEDDED=1 wash __complete $args)"})
+  compadd -f -- $candidates
+}
+if (( $+functions[compdef] )); then compdef __wash_complete wash ` + strings.Join(subcommands, " ") + `; fi
+
 if [[ -s ~/.washrc ]]; then source ~/.washrc; fi
 `
 	if err := ioutil.WriteFile(filepath.Join(rundir, ".zshrc"), []byte(content), 0644); err != nil {
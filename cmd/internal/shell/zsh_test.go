@@ -28,5 +28,9 @@ func TestZsh(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Contains(t, string(bits), "alias help='WASH_EMBEDDED=1 wash help'")
 
-	assert.FileExists(t, filepath.Join(tmpdir, ".zshrc"))
+	zshrc := filepath.Join(tmpdir, ".zshrc")
+	assert.FileExists(t, zshrc)
+	bits, err = ioutil.ReadFile(zshrc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(bits), "compdef __wash_complete wash help;")
 }
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"strconv"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/spf13/cobra"
+)
+
+func limitsCommand() *cobra.Command {
+	limitsCmd := &cobra.Command{
+		Use:   "limits [<name> <value>]",
+		Short: "Prints or tunes the Wash server's concurrency and rate limits",
+		Long: `Prints the Wash server's concurrency and rate limits. If <name> and <value> are specified,
+then the named limit is set to <value>. The new value takes effect immediately. Use --persist to
+also write the new value to the config so that it's used the next time the server starts.`,
+		Args: cobra.RangeArgs(0, 2),
+		RunE: toRunE(limitsMain),
+	}
+	limitsCmd.Flags().Bool("persist", false, "Persist the new value to the config")
+	return limitsCmd
+}
+
+func limitsMain(cmd *cobra.Command, args []string) exitCode {
+	persist, err := cmd.Flags().GetBool("persist")
+	if err != nil {
+		panic(err.Error())
+	}
+
+	conn := cmdutil.NewClient()
+	switch len(args) {
+	case 0:
+		ls, err := conn.Limits()
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		cmdutil.Print(formatLimits(ls))
+	case 1:
+		cmdutil.ErrPrintf("Please specify the %v limit's new value\n", args[0])
+		return exitCode{1}
+	default:
+		value, err := strconv.Atoi(args[1])
+		if err != nil {
+			cmdutil.ErrPrintf("%v is not a valid limit value: it must be an integer\n", args[1])
+			return exitCode{1}
+		}
+		l, err := conn.SetLimit(args[0], value, persist)
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		cmdutil.Print(formatLimits([]apitypes.Limit{l}))
+	}
+	return exitCode{0}
+}
+
+func formatLimits(ls []apitypes.Limit) string {
+	headers := []cmdutil.ColumnHeader{
+		{ShortName: "name", FullName: "NAME"},
+		{ShortName: "value", FullName: "VALUE"},
+		{ShortName: "description", FullName: "DESCRIPTION"},
+	}
+	table := make([][]string, len(ls))
+	for i, l := range ls {
+		table[i] = []string{l.Name, strconv.Itoa(l.Value), l.Description}
+	}
+	return cmdutil.NewTableWithHeaders(headers, table).Format()
+}
@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
+	"github.com/puppetlabs/wash/api/client"
 	apitypes "github.com/puppetlabs/wash/api/types"
 	"github.com/puppetlabs/wash/cmd/internal/config"
 	cmdutil "github.com/puppetlabs/wash/cmd/util"
@@ -21,17 +27,65 @@ func listCommand() *cobra.Command {
 		Use:     "list [<file>]",
 		Aliases: aliases,
 		Short:   "Lists the resources at the indicated path",
-		Args:    cobra.MaximumNArgs(1),
-		RunE:    toRunE(listMain),
+		Long: `Lists the resources at the indicated path.
+
+Specify the --telemetry flag to include the live telemetry (e.g. CPU usage) of the resources that
+support the telemetry action. Each telemetry value is shown in its own column. Telemetry's only
+cached briefly (see the plugins.telemetry_ttl_ms limit), so it's refreshed on each listing.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: toRunE(listMain),
 	}
-	return listCmd
+	listCmd.Flags().Bool("flat", false, "List all of the resources even if they're partitioned (see the plugins.partition_threshold limit)")
+	listCmd.Flags().Bool("telemetry", false, "Include the resources' telemetry")
+	listCmd.Flags().Bool("stream", false, "Print the names of the resources as they're listed instead of a table once they're all listed. Useful for resources with lots of children, like S3 buckets")
+	return completeWith(listCmd, plugin.ListAction(), "1")
 }
 
-func headers() []cmdutil.ColumnHeader {
-	return []cmdutil.ColumnHeader{
+func headers(withState bool, telemetryKeys []string) []cmdutil.ColumnHeader {
+	headers := []cmdutil.ColumnHeader{
 		{ShortName: "name", FullName: "NAME"},
 		{ShortName: "mtime", FullName: "MODIFIED"},
-		{ShortName: "verbs", FullName: "ACTIONS"},
+	}
+	if withState {
+		headers = append(headers, cmdutil.ColumnHeader{ShortName: "state", FullName: "STATE"})
+	}
+	for _, key := range telemetryKeys {
+		headers = append(headers, cmdutil.ColumnHeader{ShortName: key, FullName: strings.ToUpper(key)})
+	}
+	return append(headers, cmdutil.ColumnHeader{ShortName: "verbs", FullName: "ACTIONS"})
+}
+
+// telemetryKeysOf returns the sorted keys of the entries' telemetry. Each key
+// is a column of the listing.
+func telemetryKeysOf(ls []apitypes.Entry) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, entry := range ls {
+		for key := range entry.Telemetry {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatTelemetryValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
 	}
 }
 
@@ -40,6 +94,18 @@ func format(t time.Time) string {
 }
 
 func formatListEntries(ls []apitypes.Entry) string {
+	// The state column's only included if some of the entries have a
+	// lifecycle state (e.g. they're VMs or containers)
+	withState := false
+	for _, entry := range ls {
+		if entry.Attributes.HasLifecycle() {
+			withState = true
+			break
+		}
+	}
+
+	telemetryKeys := telemetryKeysOf(ls)
+
 	table := make([][]string, len(ls))
 	for i, entry := range ls {
 		var mtimeStr string
@@ -49,7 +115,14 @@ func formatListEntries(ls []apitypes.Entry) string {
 			mtimeStr = "<unknown>"
 		}
 
-		verbs := strings.Join(entry.Actions, ", ")
+		actions := make([]string, len(entry.Actions))
+		for i, action := range entry.Actions {
+			if _, ok := entry.Deprecation(action); ok {
+				action += " (deprecated)"
+			}
+			actions[i] = action
+		}
+		verbs := strings.Join(actions, ", ")
 
 		name := entry.CName
 		if len(ls) > 1 && i == 0 {
@@ -60,10 +133,48 @@ func formatListEntries(ls []apitypes.Entry) string {
 		if entry.Supports(plugin.ListAction()) {
 			name += "/"
 		}
+		if entry.SymlinkTarget != "" {
+			name += " -> " + entry.SymlinkTarget
+		}
 
-		table[i] = []string{name, mtimeStr, verbs}
+		row := []string{name, mtimeStr}
+		if withState {
+			state := "-"
+			if entry.Attributes.HasLifecycle() {
+				state = string(entry.Attributes.Lifecycle())
+			}
+			row = append(row, state)
+		}
+		for _, key := range telemetryKeys {
+			row = append(row, formatTelemetryValue(entry.Telemetry[key]))
+		}
+		table[i] = append(row, verbs)
+	}
+	formatted := cmdutil.NewTableWithHeaders(headers(withState, telemetryKeys), table).Format()
+	if !withState {
+		return formatted
+	}
+
+	// Dim the entries that aren't running, and highlight the ones that
+	// errored. The whole row's colored so that the columns stay aligned.
+	lines := strings.SplitAfter(formatted, "\n")
+	for i, entry := range ls {
+		if !entry.Attributes.HasLifecycle() {
+			continue
+		}
+		// lines[0] is the header
+		line := strings.TrimSuffix(lines[i+1], "\n")
+		switch entry.Attributes.Lifecycle() {
+		case plugin.LifecycleRunning:
+			continue
+		case plugin.LifecycleError:
+			line = color.RedString(line)
+		default:
+			line = color.New(color.Faint).Sprint(line)
+		}
+		lines[i+1] = line + "\n"
 	}
-	return cmdutil.NewTableWithHeaders(headers(), table).Format()
+	return strings.Join(lines, "")
 }
 
 func listMain(cmd *cobra.Command, args []string) exitCode {
@@ -73,18 +184,54 @@ func listMain(cmd *cobra.Command, args []string) exitCode {
 		path = args[0]
 	}
 
+	flat, err := cmd.Flags().GetBool("flat")
+	if err != nil {
+		panic(err.Error())
+	}
+	stream, err := cmd.Flags().GetBool("stream")
+	if err != nil {
+		panic(err.Error())
+	}
+	withTelemetry, err := cmd.Flags().GetBool("telemetry")
+	if err != nil {
+		panic(err.Error())
+	}
+
 	conn := cmdutil.NewClient()
+	if stream {
+		if withTelemetry {
+			cmdutil.ErrPrintf("--stream and --telemetry can't be used together\n")
+			return exitCode{1}
+		}
+		return streamListEntries(conn, path)
+	}
 	e, err := conn.Info(path)
 	if err != nil {
 		cmdutil.ErrPrintf("%v\n", err)
-		return exitCode{1}
+		return exitCodeFor(err)
+	}
+	if withTelemetry && e.Supports(plugin.TelemetryAction()) {
+		// Telemetry's best-effort, so the entry's still listed if its
+		// telemetry can't be fetched
+		if e.Telemetry, err = conn.Telemetry(path); err != nil {
+			cmdutil.ErrPrintf("could not get the telemetry of %v: %v\n", path, err)
+		}
 	}
 	entries := []apitypes.Entry{e}
 	if e.Supports(plugin.ListAction()) {
-		children, err := conn.List(path)
+		list := conn.List
+		if flat {
+			list = conn.ListFlat
+		}
+		if withTelemetry {
+			list = func(path string) ([]apitypes.Entry, error) {
+				return conn.ListWithTelemetry(path, flat)
+			}
+		}
+		children, err := list(path)
 		if err != nil {
 			cmdutil.ErrPrintf("%v\n", err)
-			return exitCode{1}
+			return exitCodeFor(err)
 		}
 		entries = append(entries, children...)
 	}
@@ -92,3 +239,25 @@ func listMain(cmd *cobra.Command, args []string) exitCode {
 	cmdutil.Print(formatListEntries(entries))
 	return exitCode{0}
 }
+
+// streamListEntries prints the names of the children of the resource at path
+// as they're listed
+func streamListEntries(conn client.Client, path string) exitCode {
+	packets, err := conn.ListStream(path)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+	for pkt := range packets {
+		if pkt.Err != nil {
+			cmdutil.ErrPrintf("%v\n", pkt.Err)
+			return exitCodeFor(pkt.Err)
+		}
+		name := pkt.Entry.CName
+		if pkt.Entry.Supports(plugin.ListAction()) {
+			name += "/"
+		}
+		cmdutil.Println(name)
+	}
+	return exitCode{0}
+}
@@ -1,6 +1,10 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
 	cmdutil "github.com/puppetlabs/wash/cmd/util"
 	"github.com/spf13/cobra"
 )
@@ -11,12 +15,22 @@ func metaCommand() *cobra.Command {
 		Short: "Prints the entry's metadata",
 		Long:  `Prints the entry's metadata. By default, meta prints the full metadata as returned by the
 metadata endpoint. Specify the --attribute flag to instead print the meta attribute, a
-(possibly) reduced set of metadata that's returned when entries are enumerated.`,
+(possibly) reduced set of metadata that's returned when entries are enumerated.
+
+Specify the --history flag to instead print what changed about the entry's metadata between the
+snapshots that Wash retained, from oldest to newest. Metadata history is disabled by default;
+enable it by setting the metadata_history config key to the number of snapshots to retain
+per entry. If --output is also specified, then the snapshots are printed in that format.
+
+<path> can be a glob pattern (quote it so that your shell doesn't expand it), e.g.
+'docker/containers/web-*'. In that case, meta prints an object that maps each matching entry's
+path to its metadata.`,
 		Args:  cobra.ExactArgs(1),
 		RunE:  toRunE(metaMain),
 	}
 	metaCmd.Flags().StringP("output", "o", "json", "Set the output format (json or yaml)")
 	metaCmd.Flags().BoolP("attribute", "a", false, "Print the meta attribute instead of the full metadata")
+	metaCmd.Flags().Bool("history", false, "Print the changes between the retained metadata snapshots")
 	return metaCmd
 }
 
@@ -31,6 +45,11 @@ func metaMain(cmd *cobra.Command, args []string) exitCode {
 		panic(err.Error())
 	}
 
+	showHistory, err := cmd.Flags().GetBool("history")
+	if err != nil {
+		panic(err.Error())
+	}
+
 	marshaller, err := cmdutil.NewMarshaller(output)
 	if err != nil {
 		cmdutil.ErrPrintf(err.Error())
@@ -39,29 +58,127 @@ func metaMain(cmd *cobra.Command, args []string) exitCode {
 
 	conn := cmdutil.NewClient()
 
-	var metadata map[string]interface{}
-	if showMetaAttr {
-		e, err := conn.Info(path)
-		if err != nil {
+	isGlob := apitypes.IsGlob(path)
+	paths := []string{path}
+	if isGlob {
+		if paths, err = cmdutil.ExpandPaths(conn, paths); err != nil {
 			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+	}
+
+	if showHistory {
+		if len(paths) > 1 {
+			cmdutil.ErrPrintf("%v matches %v entries, but --history only supports a single entry\n", path, len(paths))
 			return exitCode{1}
 		}
-		metadata = e.Attributes.Meta()
-	} else {
-		metadata, err = conn.Metadata(path)
+		path = paths[0]
+		snapshots, err := conn.MetadataHistory(path)
 		if err != nil {
 			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		if len(snapshots) == 0 {
+			cmdutil.ErrPrintf("No metadata history was retained for %v. Set the metadata_history config key to enable it.\n", path)
 			return exitCode{1}
 		}
+		if cmd.Flags().Changed("output") {
+			prettySnapshots, err := marshaller.Marshal(snapshots)
+			if err != nil {
+				cmdutil.ErrPrintf("%v\n", err)
+				return exitCodeFor(err)
+			}
+			cmdutil.Println(prettySnapshots)
+		} else {
+			printMetadataHistory(snapshots)
+		}
+		return exitCode{0}
+	}
+
+	getMetadata := func(path string) (map[string]interface{}, error) {
+		if showMetaAttr {
+			e, err := conn.Info(path)
+			if err != nil {
+				return nil, err
+			}
+			return e.Attributes.Meta(), nil
+		}
+		return conn.Metadata(path)
 	}
 
-	prettyMetadata, err := marshaller.Marshal(metadata)
+	// A pattern's matches are printed as an object that maps each match's
+	// path to its metadata, even if it only matched one entry, so that
+	// scripts don't need to handle that case separately
+	var result interface{}
+	code := exitCode{0}
+	if !isGlob {
+		metadata, err := getMetadata(path)
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		result = metadata
+	} else {
+		results := make(map[string]interface{}, len(paths))
+		for _, p := range paths {
+			metadata, err := getMetadata(p)
+			if err != nil {
+				cmdutil.ErrPrintf("%v\n", err)
+				code = exitCode{exitPartialFailure}
+				continue
+			}
+			results[p] = metadata
+		}
+		result = results
+	}
+
+	prettyMetadata, err := marshaller.Marshal(result)
 	if err != nil {
 		cmdutil.ErrPrintf("%v\n", err)
-		return exitCode{1}
+		return exitCodeFor(err)
 	}
 
 	cmdutil.Println(prettyMetadata)
 
-	return exitCode{0}
+	return code
+}
+
+// printMetadataHistory prints the first snapshot's metadata followed by the
+// changes made in each subsequent snapshot.
+func printMetadataHistory(snapshots []apitypes.MetadataSnapshot) {
+	const timeFormat = "2006-01-02 15:04:05"
+	for i, snapshot := range snapshots {
+		if i == 0 {
+			cmdutil.Printf("%v  first observed with %v keys\n", snapshot.Time.Format(timeFormat), len(snapshot.Metadata))
+			continue
+		}
+		changes := cmdutil.Diff(snapshots[i-1].Metadata, snapshot.Metadata)
+		cmdutil.Printf("%v  %v changes\n", snapshot.Time.Format(timeFormat), len(changes))
+		for _, change := range changes {
+			switch change.Kind {
+			case cmdutil.Added:
+				cmdutil.Printf("  %v %v: %v\n", change.Kind, change.Key, formatMetadataValue(change.New))
+			case cmdutil.Removed:
+				cmdutil.Printf("  %v %v: %v\n", change.Kind, change.Key, formatMetadataValue(change.Old))
+			default:
+				cmdutil.Printf(
+					"  %v %v: %v => %v\n",
+					change.Kind,
+					change.Key,
+					formatMetadataValue(change.Old),
+					formatMetadataValue(change.New),
+				)
+			}
+		}
+	}
+	last := snapshots[len(snapshots)-1]
+	cmdutil.Printf("%v  last observed\n", last.LastObserved.Format(timeFormat))
+}
+
+func formatMetadataValue(v interface{}) string {
+	bits, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(bits)
 }
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/puppetlabs/wash/analytics"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/fuse"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/puppetlabs/wash/plugin/snapshot"
+	"github.com/spf13/cobra"
+)
+
+func mountCommand() *cobra.Command {
+	mountCmd := &cobra.Command{
+		Use:   "mount --snapshot <timestamp|id> <mountpoint>",
+		Short: "Mounts a read-only snapshot of Wash's cache",
+		Long: `Mounts a snapshot that was saved by 'wash snapshot' at <mountpoint>. The snapshot is frozen:
+no plugins are invoked, and everything is served from the snapshot, so it works offline. Only
+what was cached when the snapshot was captured is available; listing or reading anything else
+fails.
+
+--snapshot is either a snapshot's ID or a timestamp, in which case the latest snapshot that was
+captured at or before it is mounted. Use 'wash snapshot --list' to list the saved snapshots.
+To unmount the snapshot, make sure you're not using the filesystem at <mountpoint>, then enter
+Ctrl-C.`,
+		Example: `mount --snapshot 20261014T112557Z /tmp/snapshot
+  mount the snapshot with the given ID
+
+mount --snapshot "2026-10-14 09:00" /tmp/snapshot
+  mount the latest snapshot that was captured at or before 9am`,
+		Args: cobra.ExactArgs(1),
+		RunE: toServerRunE(mountMain),
+	}
+	mountCmd.Flags().String("snapshot", "", "The snapshot to mount (an ID or a timestamp)")
+	if err := mountCmd.MarkFlagRequired("snapshot"); err != nil {
+		panic(err.Error())
+	}
+	return mountCmd
+}
+
+func mountMain(cmd *cobra.Command, args []string) exitCode {
+	ref, err := cmd.Flags().GetString("snapshot")
+	if err != nil {
+		panic(err.Error())
+	}
+	mountpoint, err := filepath.Abs(args[0])
+	if err != nil {
+		cmdutil.ErrPrintf("Could not compute the absolute path of the mountpoint %v: %v\n", args[0], err)
+		return exitCode{exitGeneric}
+	}
+
+	info, err := snapshot.Find(ref)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCode{exitNotFound}
+	}
+	s, err := snapshot.Load(info.Path)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCode{exitGeneric}
+	}
+	registry, err := snapshot.NewRegistry(s)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCode{exitGeneric}
+	}
+	plugin.InitCache()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	// Mounted snapshots are meant for offline analysis, so they don't submit
+	// any analytics
+	stopCh, stoppedCh, err := fuse.ServeFuseFS(registry, mountpoint, analytics.NewClient(analytics.Config{Disabled: true}))
+	if err != nil {
+		cmdutil.ErrPrintf("Could not mount snapshot %v: %v\n", s.ID, err)
+		return exitCode{exitGeneric}
+	}
+	cmdutil.Printf("Mounted snapshot %v (captured %v) at %v\n", s.ID, s.Time.Local().Format(time.RFC3339), mountpoint)
+
+	select {
+	case <-sigCh:
+		close(stopCh)
+		<-stoppedCh
+	case <-stoppedCh:
+		// The snapshot was unmounted externally
+	}
+	return exitCode{0}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/puppetlabs/wash/cmd/internal/pick"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/spf13/cobra"
+)
+
+func pickCommand() *cobra.Command {
+	pickCmd := &cobra.Command{
+		Use:   "pick [<path>]",
+		Short: "Interactively fuzzy-finds an entry under the specified path and prints its path",
+		Long: `Interactively fuzzy-finds an entry under the specified path (or the current directory) and prints
+the selected entry's path. The entries are listed in the background, closest to the path first, so
+they can be picked while they're still being listed. The picker is drawn on /dev/tty, so it can be
+used in command substitutions, e.g.
+
+  wash exec $(wash pick /kubernetes) bash
+
+Type to filter the entries, use the arrow keys (or Ctrl-P/Ctrl-N) to move the selection, and press
+Enter to pick the selected entry. Press Esc or Ctrl-C to cancel.
+
+Use --filter to print all of the entries that match a query from best to worst match instead.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: toRunE(pickMain),
+	}
+	pickCmd.Flags().StringP("query", "q", "", "Start the picker with the given query")
+	pickCmd.Flags().StringP("filter", "f", "", "Print the entries that match the given query instead of starting the picker")
+	pickCmd.Flags().Int("maxdepth", 5, "Only list entries up to this many levels below the path")
+	return pickCmd
+}
+
+func pickMain(cmd *cobra.Command, args []string) exitCode {
+	query, err := cmd.Flags().GetString("query")
+	if err != nil {
+		panic(err.Error())
+	}
+	filter, err := cmd.Flags().GetString("filter")
+	if err != nil {
+		panic(err.Error())
+	}
+	maxdepth, err := cmd.Flags().GetInt("maxdepth")
+	if err != nil {
+		panic(err.Error())
+	}
+
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+	source := pick.NewSource(cmdutil.NewClient(), path, maxdepth)
+	defer source.Stop()
+
+	if cmd.Flags().Changed("filter") {
+		paths, errors := pick.FilterAll(source, filter)
+		for _, match := range paths {
+			cmdutil.Println(match)
+		}
+		if errors > 0 {
+			cmdutil.ErrPrintf("could not list %v of the entries under %v\n", errors, path)
+			return exitCode{exitPartialFailure}
+		}
+		if len(paths) == 0 {
+			return exitCode{exitGeneric}
+		}
+		return exitCode{0}
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		cmdutil.ErrPrintf("wash pick requires a terminal (use --filter otherwise): %v\n", err)
+		return exitCodeFor(err)
+	}
+	defer tty.Close()
+	selected, err := pick.Run(tty, source, query)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+	if selected == "" {
+		// Cancelled
+		return exitCode{exitGeneric}
+	}
+	cmdutil.Println(selected)
+	return exitCode{0}
+}
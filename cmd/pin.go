@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"time"
+
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/spf13/cobra"
+)
+
+func pinCommand() *cobra.Command {
+	pinCmd := &cobra.Command{
+		Use:   "pin [--ttl <duration>] [--remove] <path>",
+		Short: "Keeps the cache warm for <path> and its children",
+		Long: `Pins <path> and its children so that their cached resources are refreshed in the background
+every --ttl (default 30s) instead of expiring, and so that they're never evicted from the cache.
+Use it to keep a handful of paths fresh while you're watching them constantly, e.g. during an
+incident. A pinned resource is kept warm once it's accessed. Pinning a pinned path updates
+its TTL. Use --remove to unpin <path>, which clears its cache. Pins are listed in wash/pins,
+and they last until the server stops.`,
+		Args: cobra.ExactArgs(1),
+		RunE: toRunE(pinMain),
+	}
+	pinCmd.Flags().Duration("ttl", plugin.DefaultPinTTL, "How often the pinned resources are refreshed")
+	pinCmd.Flags().Bool("remove", false, "Unpin <path>")
+	return pinCmd
+}
+
+func pinMain(cmd *cobra.Command, args []string) exitCode {
+	path := args[0]
+	ttl, err := cmd.Flags().GetDuration("ttl")
+	if err != nil {
+		panic(err.Error())
+	}
+	remove, err := cmd.Flags().GetBool("remove")
+	if err != nil {
+		panic(err.Error())
+	}
+
+	conn := cmdutil.NewClient()
+	if remove {
+		if _, err := conn.Unpin(path); err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		cmdutil.Println("Unpinned", path)
+		return exitCode{0}
+	}
+
+	if ttl < plugin.MinPinTTL {
+		cmdutil.ErrPrintf("--ttl must be at least %v\n", plugin.MinPinTTL)
+		return exitCode{exitGeneric}
+	}
+	pin, err := conn.Pin(path, ttl)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+	cmdutil.Printf("Pinned %v: it's refreshed every %v\n", path, time.Duration(pin.TTLSeconds*float64(time.Second)))
+	return exitCode{0}
+}
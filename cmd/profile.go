@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Benchkram/errz"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/spf13/cobra"
+)
+
+func profileCommand() *cobra.Command {
+	profileCmd := &cobra.Command{
+		Use:   "profile cpu|heap [--duration <duration>] [-o <file>]",
+		Short: "Saves a CPU or heap profile of the Wash server",
+		Long: `Fetches a profile of the running Wash server and saves it to a file that can be analyzed
+with 'go tool pprof', e.g. to attach it to a performance bug report. A CPU profile covers the
+next --duration (30s by default). A heap profile is a snapshot of the server's heap unless
+--duration is set, in which case it only includes the allocations made during the duration.
+
+Profiles are served by the server's admin API, so they can only be fetched by the user that
+started the server.`,
+		Args: cobra.ExactArgs(1),
+		RunE: toRunE(profileMain),
+	}
+	profileCmd.Flags().Duration("duration", 30*time.Second, "How long to profile the server for")
+	profileCmd.Flags().StringP("output", "o", "", "The file to save the profile to. Defaults to wash-<kind>-<timestamp>.pprof")
+	return profileCmd
+}
+
+func profileMain(cmd *cobra.Command, args []string) exitCode {
+	kind := args[0]
+	duration, err := cmd.Flags().GetDuration("duration")
+	if err != nil {
+		panic(err.Error())
+	}
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		panic(err.Error())
+	}
+	if kind != apitypes.CPUProfile && kind != apitypes.HeapProfile {
+		cmdutil.ErrPrintf("Unknown profile %v; it must be %v or %v\n", kind, apitypes.CPUProfile, apitypes.HeapProfile)
+		return exitCode{1}
+	}
+	if duration <= 0 {
+		cmdutil.ErrPrintf("The duration must be positive\n")
+		return exitCode{1}
+	}
+	if kind == apitypes.HeapProfile && !cmd.Flags().Changed("duration") {
+		duration = 0
+	}
+	if output == "" {
+		output = fmt.Sprintf("wash-%v-%v.pprof", kind, time.Now().Format("20060102T150405"))
+	}
+
+	if duration > 0 {
+		cmdutil.ErrPrintf("Profiling the Wash server for %v\n", duration)
+	}
+	conn := cmdutil.NewClient()
+	profile, err := conn.Profile(kind, duration)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+	defer func() { errz.Log(profile.Close()) }()
+
+	f, err := os.Create(output)
+	if err != nil {
+		cmdutil.ErrPrintf("Could not create %v: %v\n", output, err)
+		return exitCode{exitGeneric}
+	}
+	_, err = io.Copy(f, profile)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cmdutil.ErrPrintf("Could not save the profile to %v: %v\n", output, err)
+		return exitCode{exitGeneric}
+	}
+	cmdutil.Printf("Saved the %v profile to %v\n", kind, output)
+	return exitCode{0}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	apitypes "github.com/puppetlabs/wash/api/types"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/spf13/cobra"
+)
+
+func pruneCommand() *cobra.Command {
+	pruneCmd := &cobra.Command{
+		Use:   "prune [--max-age-days <days>] [--max-size-mb <MB>]",
+		Short: "Deletes old activity journals and cache snapshots",
+		Long: `Deletes the activity journals and cache snapshots that exceed their retention policy, i.e.
+the files that weren't modified in max_age_days, then the oldest files until the store's no
+bigger than max_size_mb. The policies are configured by the server config's retention key,
+and the server also prunes them in the background. Use --max-age-days and --max-size-mb to
+override the configured policies. Journals that are in use are never deleted.`,
+		Args: cobra.NoArgs,
+		RunE: toRunE(pruneMain),
+	}
+	pruneCmd.Flags().Int("max-age-days", 0, "Delete the files that weren't modified in this many days")
+	pruneCmd.Flags().Int("max-size-mb", 0, "Delete the oldest files until each store's no bigger than this many MB")
+	pruneCmd.Flags().BoolP("verbose", "v", false, "Print the files that were deleted")
+	return pruneCmd
+}
+
+func pruneMain(cmd *cobra.Command, args []string) exitCode {
+	maxAgeDays, err := cmd.Flags().GetInt("max-age-days")
+	if err != nil {
+		panic(err.Error())
+	}
+	maxSizeMB, err := cmd.Flags().GetInt("max-size-mb")
+	if err != nil {
+		panic(err.Error())
+	}
+	verbose, err := cmd.Flags().GetBool("verbose")
+	if err != nil {
+		panic(err.Error())
+	}
+	if maxAgeDays < 0 || maxSizeMB < 0 {
+		cmdutil.ErrPrintf("--max-age-days and --max-size-mb must not be negative\n")
+		return exitCode{exitGeneric}
+	}
+
+	var override *apitypes.PruneBody
+	if maxAgeDays > 0 || maxSizeMB > 0 {
+		override = &apitypes.PruneBody{MaxAgeDays: maxAgeDays, MaxSizeMB: maxSizeMB}
+	}
+
+	conn := cmdutil.NewClient()
+	results, err := conn.Prune(override)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+	if len(results) == 0 {
+		cmdutil.Println("Nothing to prune: no retention policies are configured. Use --max-age-days or --max-size-mb.")
+		return exitCode{0}
+	}
+	for _, result := range results {
+		cmdutil.Printf("Pruned %v %v (%v bytes); %v bytes remain\n", len(result.Removed), result.Store, result.RemovedBytes, result.RemainingBytes)
+		if verbose {
+			for _, name := range result.Removed {
+				cmdutil.Println("Deleted", name)
+			}
+		}
+	}
+	return exitCode{0}
+}
@@ -13,6 +13,7 @@ import (
 
 	apitypes "github.com/puppetlabs/wash/api/types"
 	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/plugin"
 )
 
 func psCommand() *cobra.Command {
@@ -22,10 +23,16 @@ func psCommand() *cobra.Command {
 		Aliases: aliases,
 		Short:   "Lists the processes running on the indicated compute instances",
 		Long: `Captures /proc/*/{cmdline,stat,statm} on each node by executing 'cat' on them. Collects the output
-to display running processes on all listed nodes. Errors on paths that don't implement exec.`,
+to display running processes on all listed nodes. Errors on paths that don't implement exec.
+
+If the Wash server has an exec policy, then you're shown its banner and asked to consent to it
+(or asked for a justification) once, before the processes are captured. Use --accept-banner and
+--justification to supply them when ps is run non-interactively.`,
 		RunE: toRunE(psMain),
 	}
-	return psCmd
+	psCmd.Flags().Bool("accept-banner", false, "Consent to the exec policy's banner without being prompted")
+	psCmd.Flags().StringP("justification", "j", "", "Set why you're capturing the processes, if the exec policy requires it")
+	return completeWith(psCmd, plugin.ExecAction(), "1-")
 }
 
 func collectOutput(ch <-chan apitypes.ExecPacket) (string, error) {
@@ -157,6 +164,15 @@ func formatStats(stats []psresult) string {
 }
 
 func psMain(cmd *cobra.Command, args []string) exitCode {
+	acceptBanner, err := cmd.Flags().GetBool("accept-banner")
+	if err != nil {
+		panic(err.Error())
+	}
+	justification, err := cmd.Flags().GetString("justification")
+	if err != nil {
+		panic(err.Error())
+	}
+
 	var paths []string
 	if len(args) > 0 {
 		paths = args
@@ -178,19 +194,52 @@ func psMain(cmd *cobra.Command, args []string) exitCode {
 		results[path] = []psresult{}
 	}
 
+	var errsMux sync.Mutex
+	var errs []error
+	recordErr := func(k string, err error) {
+		cmdutil.ErrPrintf("errored on %v: %v\n", k, err)
+		errsMux.Lock()
+		errs = append(errs, err)
+		errsMux.Unlock()
+	}
+
+	// The nodes are exec'd in parallel, so the user's only prompted for what
+	// the exec policy requires once. The other nodes' execs are retried with
+	// what they supplied.
+	var optsMux sync.Mutex
+	opts := apitypes.ExecOptions{Input: psScript, Consented: acceptBanner, Justification: justification}
+	exec := func(k string) (<-chan apitypes.ExecPacket, error) {
+		optsMux.Lock()
+		attempt := opts
+		optsMux.Unlock()
+		for {
+			ch, err := conn.Exec(k, "sh", []string{}, attempt)
+			if err == nil {
+				return ch, nil
+			}
+			optsMux.Lock()
+			if opts.Consented == attempt.Consented && opts.Justification == attempt.Justification && !satisfyExecPolicy(err, &opts) {
+				optsMux.Unlock()
+				return nil, err
+			}
+			attempt = opts
+			optsMux.Unlock()
+		}
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(len(paths))
 	for i, path := range paths {
 		go func(k string, idx int) {
 			defer wg.Done()
-			ch, err := conn.Exec(k, "sh", []string{}, apitypes.ExecOptions{Input: psScript})
+			ch, err := exec(k)
 			if err != nil {
-				cmdutil.ErrPrintf("errored on %v: %v\n", k, err)
+				recordErr(k, err)
 				return
 			}
 			out, err := collectOutput(ch)
 			if err != nil {
-				cmdutil.ErrPrintf("errored on %v: %v\n", k, err)
+				recordErr(k, err)
 			} else {
 				results[k] = parseLines(k, out)
 			}
@@ -205,5 +254,5 @@ func psMain(cmd *cobra.Command, args []string) exitCode {
 	}
 
 	cmdutil.Print(formatStats(stats))
-	return exitCode{0}
+	return exitCodeForAll(errs, len(paths))
 }
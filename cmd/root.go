@@ -31,7 +31,18 @@ func (e exitCode) Error() string {
 type commandMain func(cmd *cobra.Command, args []string) exitCode
 type runE func(cmd *cobra.Command, args []string) error
 
+// toRunE converts main into a RunE function. Interrupting the command cancels
+// its in-flight requests (see cancelOnInterrupt).
 func toRunE(main commandMain) runE {
+	return func(cmd *cobra.Command, args []string) error {
+		defer cancelOnInterrupt()()
+		return main(cmd, args)
+	}
+}
+
+// toServerRunE is toRunE for commands that run Wash's daemon or plugins
+// in-process. They handle interrupts themselves by shutting down.
+func toServerRunE(main commandMain) runE {
 	return func(cmd *cobra.Command, args []string) error {
 		return main(cmd, args)
 	}
@@ -119,7 +130,7 @@ func rootCommand() *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:    "wash [<script>]",
 		PreRun: bindServerArgs,
-		RunE:   toRunE(rootMain),
+		RunE:   toServerRunE(rootMain),
 		Long: `When invoked without arguments, enters a Wash shell. Starts the Wash daemon,
 then starts your system shell with shortcuts configured for wash subcommands.`,
 		// Need to set these so that Cobra will not output the usage +
@@ -142,12 +153,18 @@ then starts your system shell with shortcuts configured for wash subcommands.`,
 		// Omit server from embedded cases because a daemon is already running.
 		addServerArgs(rootCmd, "warn")
 		addCommand(rootCmd, serverCommand())
+		// Like server, mount runs its own FUSE server, so it's omitted from
+		// embedded cases.
+		addCommand(rootCmd, mountCommand())
 		// rootCommandFlag is used in rootMain.go.
 		rootCmd.Flags().StringVarP(&rootCommandFlag, "command", "c", "", "Run the supplied string and exit")
 
 		// Omit validate because it's meant to be run independently to test a plugin and should not be
 		// part of normal shell interaction.
 		addCommand(rootCmd, validateCommand())
+
+		// demo starts its own shell, so it's also omitted from embedded cases.
+		addCommand(rootCmd, demoCommand())
 	}
 	rootCmd = ensureGARegistration(rootCmd)
 
@@ -158,10 +175,25 @@ then starts your system shell with shortcuts configured for wash subcommands.`,
 	addCommand(rootCmd, psCommand())
 	addCommand(rootCmd, findCommand())
 	addCommand(rootCmd, clearCommand())
+	addCommand(rootCmd, pinCommand())
 	addCommand(rootCmd, tailCommand())
 	addCommand(rootCmd, historyCommand())
 	addCommand(rootCmd, infoCommand())
 	addCommand(rootCmd, streeCommand())
+	addCommand(rootCmd, limitsCommand())
+	addCommand(rootCmd, featuresCommand())
+	addCommand(rootCmd, whereamiCommand())
+	addCommand(rootCmd, pickCommand())
+	addCommand(rootCmd, snapshotCommand())
+	addCommand(rootCmd, pruneCommand())
+	addCommand(rootCmd, signalCommand())
+	addCommand(rootCmd, runCommand())
+	addCommand(rootCmd, profileCommand())
+	addCommand(rootCmd, topCommand())
+	addCommand(rootCmd, catCommand())
+	rootCmd.SetHelpCommand(ensureGARegistration(helpCommand()))
+	// __complete runs on every tab, so its invocations aren't registered to GA.
+	rootCmd.AddCommand(completeCommand())
 
 	return rootCmd
 }
@@ -23,6 +23,35 @@ var rootCommandFlag string
 // Start the wash server, then present the default system shell.
 // On exit, stop the server and return any errors.
 func rootMain(cmd *cobra.Command, args []string) exitCode {
+	var execfile string
+	if len(args) > 0 {
+		execfile = args[0]
+	}
+
+	// Set plugin interactivity to false if execfile or rootCommandFlag were specified.
+	plugin.InitInteractive(execfile == "" && rootCommandFlag == "")
+
+	// TODO: instead of running a server in-process, can we start one in a separate process that can
+	//       be shared between multiple invocations of `wash`?
+	optsFor := func(rundir string) (map[string]plugin.Root, server.Opts, error) {
+		return serverOptsFor(cmd)
+	}
+	return runShell(cmd, execfile, optsFor, `Welcome to Wash!
+  Wash includes several built-in commands: wexec, find, list, meta, tail.
+  See commands run with wash via 'whistory', and logs with 'whistory <id>'.
+Try 'help'`)
+}
+
+// runShell starts a wash server with the plugins and options returned by
+// optsFor, then presents the default system shell. optsFor is passed the shell's
+// temporary run space, which is removed when the shell exits. The welcome
+// message is printed if the shell's interactive.
+func runShell(
+	cmd *cobra.Command,
+	execfile string,
+	optsFor func(rundir string) (map[string]plugin.Root, server.Opts, error),
+	welcome string,
+) exitCode {
 	// Configure logrus to emit simple text
 	log.SetFormatter(&log.TextFormatter{DisableTimestamp: true})
 
@@ -57,17 +86,7 @@ func rootMain(cmd *cobra.Command, args []string) exitCode {
 	}
 	defer os.RemoveAll(rundir)
 
-	var execfile string
-	if len(args) > 0 {
-		execfile = args[0]
-	}
-
-	// Set plugin interactivity to false if execfile or rootCommandFlag were specified.
-	plugin.InitInteractive(execfile == "" && rootCommandFlag == "")
-
-	// TODO: instead of running a server in-process, can we start one in a separate process that can
-	//       be shared between multiple invocations of `wash`?
-	plugins, serverOpts, err := serverOptsFor(cmd)
+	plugins, serverOpts, err := optsFor(rundir)
 	if err != nil {
 		cmdutil.ErrPrintf("%v\n", err)
 		return exitCode{1}
@@ -80,17 +99,14 @@ func rootMain(cmd *cobra.Command, args []string) exitCode {
 	}
 
 	if plugin.IsInteractive() {
-		cmdutil.Println(`Welcome to Wash!
-  Wash includes several built-in commands: wexec, find, list, meta, tail.
-  See commands run with wash via 'whistory', and logs with 'whistory <id>'.
-Try 'help'`)
+		cmdutil.Println(welcome)
 	}
 
 	if !symlinkWash(rundir) {
 		return exitCode{1}
 	}
 
-	subc := flattenSubcommands(cmd.Commands())
+	subc := flattenSubcommands(cmd.Root().Commands())
 	comm, err := shell.Get().Command(subc, rundir)
 	if err != nil {
 		cmdutil.ErrPrintf("%v\n", err)
@@ -165,7 +181,7 @@ func flattenSubcommands(subcommands []*cobra.Command) []string {
 		}
 		name := tokens[0]
 		// Specifically skip server as undocumented when running in wash shell.
-		if name == "server" {
+		if name == "server" || subcommand.Hidden {
 			continue
 		}
 
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/puppetlabs/wash/api/client"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/spf13/cobra"
+)
+
+func runCommand() *cobra.Command {
+	runCmd := &cobra.Command{
+		Use:   "run <action> <path> [<arg>...]",
+		Short: "Performs a custom action on the entries at the specified path",
+		Long: `Performs one of an entry's custom, plugin-defined actions (e.g. snapshot or reboot) with the
+given args, and prints its output. 'wash info' lists the entry's custom actions.
+
+<path> can be a glob pattern (quote it so that your shell doesn't expand it), e.g.
+'myvms/web-*', in which case the action's performed on each matching entry.
+
+Custom actions are governed by the Wash server's exec policy like 'wash exec' is, so you're
+asked to consent to its banner (or for a justification) once if the policy requires it. Use
+--accept-banner and --justification to supply them when wash run isn't run interactively.`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: toRunE(runMain),
+	}
+	runCmd.Flags().SetInterspersed(false)
+	runCmd.Flags().Bool("accept-banner", false, "Consent to the exec policy's banner without being prompted")
+	runCmd.Flags().StringP("justification", "j", "", "Set why you're performing the action, if the exec policy requires it")
+	return runCmd
+}
+
+func runMain(cmd *cobra.Command, args []string) exitCode {
+	action := args[0]
+	acceptBanner, err := cmd.Flags().GetBool("accept-banner")
+	if err != nil {
+		panic(err.Error())
+	}
+	justification, err := cmd.Flags().GetString("justification")
+	if err != nil {
+		panic(err.Error())
+	}
+	opts := apitypes.RunOptions{Consented: acceptBanner, Justification: justification}
+
+	conn := cmdutil.NewClient()
+	paths, err := cmdutil.ExpandPaths(conn, args[1:2])
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	var errs []error
+	for _, path := range paths {
+		output, err := runOn(conn, path, action, args[2:], &opts)
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			errs = append(errs, err)
+			continue
+		}
+		if len(paths) > 1 {
+			cmdutil.Printf("==> %v <==\n", path)
+		}
+		if output != "" && !strings.HasSuffix(output, "\n") {
+			output += "\n"
+		}
+		cmdutil.Print(output)
+	}
+	return exitCodeForAll(errs, len(paths))
+}
+
+// runOn performs the custom action on path. If the exec policy requires
+// consent or a justification, then the user's prompted for it and opts is
+// updated so that it's supplied for the other paths.
+func runOn(conn client.Client, path string, action string, args []string, opts *apitypes.RunOptions) (string, error) {
+	for {
+		output, err := conn.Run(path, action, args, *opts)
+		if err == nil {
+			return output, nil
+		}
+		execOpts := apitypes.ExecOptions{Consented: opts.Consented, Justification: opts.Justification}
+		if !satisfyExecPolicy(err, &execOpts) {
+			return "", err
+		}
+		opts.Consented, opts.Justification = execOpts.Consented, execOpts.Justification
+	}
+}
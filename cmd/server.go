@@ -12,11 +12,15 @@ import (
 	"github.com/puppetlabs/wash/cmd/internal/config"
 	"github.com/puppetlabs/wash/cmd/internal/server"
 	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/fuse"
 	"github.com/puppetlabs/wash/plugin"
 	"github.com/puppetlabs/wash/plugin/aws"
 	"github.com/puppetlabs/wash/plugin/docker"
 	"github.com/puppetlabs/wash/plugin/gcp"
 	"github.com/puppetlabs/wash/plugin/kubernetes"
+	"github.com/puppetlabs/wash/plugin/wash"
+	"github.com/puppetlabs/wash/retention"
+	"github.com/puppetlabs/wash/sftp"
 
 	log "github.com/sirupsen/logrus"
 
@@ -39,7 +43,7 @@ func serverCommand() *cobra.Command {
 To stop it, make sure you're not using the filesystem at <mountpoint>, then enter Ctrl-C.`,
 		Args:   cobra.MinimumNArgs(1),
 		PreRun: bindServerArgs,
-		RunE:   toRunE(serverMain),
+		RunE:   toServerRunE(serverMain),
 	}
 	addServerArgs(serverCmd, "info")
 
@@ -81,15 +85,21 @@ func serverMain(cmd *cobra.Command, args []string) exitCode {
 func addServerArgs(cmd *cobra.Command, defaultLogLevel string) {
 	cmd.Flags().String("loglevel", defaultLogLevel, "Set the logging level")
 	cmd.Flags().String("logfile", "", "Set the log file's location. Defaults to stdout")
+	cmd.Flags().String("logtarget", "", "Set where logs are written: stdout, file (the logfile), journald, or syslog. Defaults to file if a logfile is set, and stdout otherwise")
 	cmd.Flags().String("cpuprofile", "", "Write cpu profile to file")
 	cmd.Flags().String("config-file", config.DefaultFile(), "Set the config file's location")
+	cmd.Flags().Bool("handoff", false, "Hand the cache off to the next server when stopping, and re-warm the cache from the previous server's handoff when starting")
+	cmd.Flags().Bool("dev-mode", false, "Enable the API's development endpoints, e.g. setting the fault injection rules")
 }
 
 func bindServerArgs(cmd *cobra.Command, args []string) {
 	// Only bind config lookup when invoking the specific command as viper bindings are global.
 	errz.Fatal(viper.BindPFlag("loglevel", cmd.Flags().Lookup("loglevel")))
 	errz.Fatal(viper.BindPFlag("logfile", cmd.Flags().Lookup("logfile")))
+	errz.Fatal(viper.BindPFlag("logtarget", cmd.Flags().Lookup("logtarget")))
 	errz.Fatal(viper.BindPFlag("cpuprofile", cmd.Flags().Lookup("cpuprofile")))
+	errz.Fatal(viper.BindPFlag("handoff", cmd.Flags().Lookup("handoff")))
+	errz.Fatal(viper.BindPFlag("dev_mode", cmd.Flags().Lookup("dev-mode")))
 }
 
 // serverOptsFor returns map of plugins and server.Opts for the given command.
@@ -127,31 +137,125 @@ func serverOptsFor(cmd *cobra.Command) (map[string]plugin.Root, server.Opts, err
 		}
 	}
 
+	// The wash plugin exposes Wash's own state (e.g. its cache stats), so it's
+	// always loaded.
+	plugins["wash"] = &wash.Root{}
+
 	// Ensure external plugins are valid scripts and convert them to plugin.Root types.
+	// Plugins that fail to load are still registered so that the failure's
+	// reported in wash/status.
 	for _, spec := range externalPlugins {
 		intPlugin, err := spec.Load()
 		if err != nil {
-			log.Warnf("%v failed to load: %+v", spec.Script, err)
-			continue
+			log.Warnf("%v failed to load: %+v", spec.Path(), err)
+			intPlugin = plugin.FailedExternalPlugin(spec.Name(), err)
 		}
 
 		name := plugin.Name(intPlugin)
 		if _, ok := plugins[name]; ok {
-			log.Warnf("Overriding plugin %s with external plugin %s", name, spec.Script)
+			log.Warnf("Overriding plugin %s with external plugin %s", name, spec.Path())
 		}
 		plugins[name] = intPlugin
 	}
 
+	// Discovered plugins don't override the core plugins or the configured
+	// external plugins
+	pluginDirs := append([]string{plugin.DefaultExternalPluginDir}, viper.GetStringSlice("external-plugin-dirs")...)
+	for _, spec := range plugin.DiscoverExternalPlugins(pluginDirs, externalPlugins) {
+		if _, ok := plugins[spec.Name()]; ok {
+			log.Warnf("Skipping the discovered plugin %v: the %v plugin is already loaded", spec.Path(), spec.Name())
+			continue
+		}
+		intPlugin, err := spec.Load()
+		if err != nil {
+			log.Warnf("%v failed to load: %+v", spec.Path(), err)
+			intPlugin = plugin.FailedExternalPlugin(spec.Name(), err)
+		}
+		plugins[spec.Name()] = intPlugin
+		externalPlugins = append(externalPlugins, spec)
+	}
+
+	var ownership fuse.Ownership
+	if err := viper.UnmarshalKey("ownership", &ownership); err != nil {
+		return nil, server.Opts{}, fmt.Errorf("failed to unmarshal the ownership key: %v", err)
+	}
+
+	var logRotation server.LogRotation
+	if err := viper.UnmarshalKey("logrotate", &logRotation); err != nil {
+		return nil, server.Opts{}, fmt.Errorf("failed to unmarshal the logrotate key: %v", err)
+	}
+
+	var faults []plugin.FaultRule
+	if err := viper.UnmarshalKey("faults", &faults); err != nil {
+		return nil, server.Opts{}, fmt.Errorf("failed to unmarshal the faults key: %v", err)
+	}
+
+	var retentionPolicies map[string]retention.Policy
+	if err := viper.UnmarshalKey("retention", &retentionPolicies); err != nil {
+		return nil, server.Opts{}, fmt.Errorf("failed to unmarshal the retention key: %v", err)
+	}
+
+	var httpOpts plugin.HTTPOptions
+	if err := viper.UnmarshalKey("http", &httpOpts); err != nil {
+		return nil, server.Opts{}, fmt.Errorf("failed to unmarshal the http key: %v", err)
+	}
+
+	var execPolicy plugin.ExecPolicy
+	if err := viper.UnmarshalKey("exec_policy", &execPolicy); err != nil {
+		return nil, server.Opts{}, fmt.Errorf("failed to unmarshal the exec_policy key: %v", err)
+	}
+
+	var sftpOpts sftp.Opts
+	if err := viper.UnmarshalKey("sftp", &sftpOpts); err != nil {
+		return nil, server.Opts{}, fmt.Errorf("failed to unmarshal the sftp key: %v", err)
+	}
+
+	// Tuned limits are persisted to the config so that they survive restarts
+	persistLimit := func(name string, value int) error {
+		return config.Persist("limits."+name, value)
+	}
+	persistFeature := func(key string, enabled bool) error {
+		return config.Persist("features."+key, enabled)
+	}
+
 	config := make(map[string]map[string]interface{})
 	for name := range plugins {
 		config[name] = viper.GetStringMap(name)
 	}
+	// External plugins that failed to load can still be loaded once they're
+	// fixed (see plugin.WatchExternalPlugins), so they need their config too
+	for _, spec := range externalPlugins {
+		if _, ok := config[spec.Name()]; !ok {
+			config[spec.Name()] = viper.GetStringMap(spec.Name())
+		}
+	}
 
 	// Return the options
 	return plugins, server.Opts{
-		CPUProfilePath: viper.GetString("cpuprofile"),
-		LogFile:        viper.GetString("logfile"),
-		LogLevel:       viper.GetString("loglevel"),
-		PluginConfig:   config,
+		CPUProfilePath:     viper.GetString("cpuprofile"),
+		LogFile:            viper.GetString("logfile"),
+		LogTarget:          viper.GetString("logtarget"),
+		LogRotation:        logRotation,
+		SyslogAddress:      viper.GetString("syslog_address"),
+		LogLevel:           viper.GetString("loglevel"),
+		PluginConfig:       config,
+		Limits:             viper.GetStringMap("limits"),
+		PersistLimit:       persistLimit,
+		Features:           viper.GetStringMap("features"),
+		PersistFeature:     persistFeature,
+		Ownership:          ownership,
+		Faults:             faults,
+		DevMode:            viper.GetBool("dev_mode"),
+		Retention:          retentionPolicies,
+		HTTP:               httpOpts,
+		ExecPolicy:         execPolicy,
+		Handoff:            viper.GetBool("handoff"),
+		ExternalPlugins:    externalPlugins,
+		ExternalPluginDirs: pluginDirs,
+		SFTP:               sftpOpts,
+		Filesystem:         viper.GetString("filesystem"),
+		NinePAddress:       viper.GetString("9p_address"),
+		PersistInodes:      viper.GetBool("persist_inodes"),
+		MetadataHistory:    viper.GetInt("metadata_history"),
 	}, nil
 }
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/spf13/cobra"
+)
+
+func signalCommand() *cobra.Command {
+	signalCmd := &cobra.Command{
+		Use:   "signal <signal> <path> [<path>...]",
+		Short: "Sends a signal to the entries at the specified paths",
+		Long: `Sends a signal (e.g. start, stop, restart or kill) to the entries at the specified paths, like
+containers or VMs. Which signals are supported is up to the entry's plugin; entries that don't
+support the signal error.
+
+<path> can be a glob pattern (quote it so that your shell doesn't expand it), e.g.
+'docker/containers/web-*', in which case the signal's sent to each matching entry.`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: toRunE(signalMain),
+	}
+	return completeWith(signalCmd, plugin.SignalAction(), "2-")
+}
+
+func signalMain(cmd *cobra.Command, args []string) exitCode {
+	signal := args[0]
+	conn := cmdutil.NewClient()
+	paths, err := cmdutil.ExpandPaths(conn, args[1:])
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	code := exitCode{0}
+	for _, path := range paths {
+		if err := conn.Signal(path, signal); err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			code = exitCodeFor(err)
+			continue
+		}
+		cmdutil.Println("Sent", signal, "to", path)
+	}
+	return code
+}
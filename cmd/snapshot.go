@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"time"
+
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/plugin/snapshot"
+	"github.com/spf13/cobra"
+)
+
+func snapshotCommand() *cobra.Command {
+	snapshotCmd := &cobra.Command{
+		Use:   "snapshot [--list]",
+		Short: "Saves a snapshot of Wash's cache",
+		Long: `Captures the cached parts of the plugin tree (listings, content and metadata) and saves them
+as a snapshot. Capturing a snapshot doesn't invoke any plugins, so it only includes what's
+currently cached. Use 'wash mount --snapshot <timestamp|id> <mountpoint>' to mount a snapshot
+later, e.g. for offline analysis.`,
+		Args: cobra.NoArgs,
+		RunE: toRunE(snapshotMain),
+	}
+	snapshotCmd.Flags().BoolP("list", "l", false, "List the saved snapshots instead of capturing one")
+	return snapshotCmd
+}
+
+func snapshotMain(cmd *cobra.Command, args []string) exitCode {
+	list, err := cmd.Flags().GetBool("list")
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if list {
+		infos, err := snapshot.List()
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCode{exitGeneric}
+		}
+		for _, info := range infos {
+			cmdutil.Printf("%v\t%v\n", info.ID, info.Time.Local().Format(time.RFC3339))
+		}
+		return exitCode{0}
+	}
+
+	conn := cmdutil.NewClient()
+	s, err := conn.Snapshot()
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+	cmdutil.Printf("Saved snapshot %v with %v entries to %v\n", s.ID, s.Entries, s.Path)
+	return exitCode{0}
+}
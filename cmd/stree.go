@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/xlab/treeprint"
 
@@ -32,7 +34,7 @@ func streeMain(cmd *cobra.Command, args []string) exitCode {
 		schema, err := conn.Schema(path)
 		if err != nil {
 			cmdutil.ErrPrintf("%v\n", err)
-			return exitCode{1}
+			return exitCodeFor(err)
 		}
 		if schema == nil {
 			cmdutil.ErrPrintf("%v: schema unknown\n", path)
@@ -54,6 +56,14 @@ func fill(stree treeprint.Tree, schema *apitypes.EntrySchema, visited map[string
 	if !schema.Singleton() {
 		value = fmt.Sprintf("[%v]", value)
 	}
+	if deprecations := schema.DeprecatedActions(); len(deprecations) > 0 {
+		var deprecatedActions []string
+		for action := range deprecations {
+			deprecatedActions = append(deprecatedActions, action)
+		}
+		sort.Strings(deprecatedActions)
+		value = fmt.Sprintf("%v (deprecated: %v)", value, strings.Join(deprecatedActions, ", "))
+	}
 	stree.SetValue(value)
 	if visited[schema.Path()] {
 		return stree
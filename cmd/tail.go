@@ -1,11 +1,13 @@
 package cmd
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Benchkram/errz"
@@ -13,6 +15,7 @@ import (
 	"github.com/puppetlabs/wash/api/client"
 	apitypes "github.com/puppetlabs/wash/api/types"
 	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/puppetlabs/wash/plugin"
 	"github.com/spf13/cobra"
 )
 
@@ -21,11 +24,19 @@ func tailCommand() *cobra.Command {
 		Use:   "tail -f [<file>...]",
 		Short: "Displays new output of files or resources with the stream action",
 		Long: `Output any new updates to files and/or resources (that support the stream action). Mimics
-'tail -f' for remote logs, and calls '/usr/bin/tail' if '-f' is omitted.`,
+'tail -f' for remote logs, and calls '/usr/bin/tail' if '-f' is omitted.
+
+With '-f', each <file> can be a glob pattern (quote it so that your shell doesn't expand it), e.g.
+'docker/containers/web-*/log', to follow all of the matching resources.
+
+Resources whose stream is resumable can also show their output since a time in the past via
+'--since', e.g. '--since 10m'. Their streams are resumed where they left off if the connection to
+the server's lost, instead of missing the output from while it was lost.`,
 		RunE: toRunE(tailMain),
 	}
 	tailCmd.Flags().BoolP("follow", "f", false, "Follow new output")
-	return tailCmd
+	tailCmd.Flags().String("since", "", "With '-f', start with the output since the given duration ago (e.g. 10m) or RFC3339 timestamp, for resources whose stream is resumable")
+	return completeWith(tailCmd, plugin.StreamAction(), "1-")
 }
 
 type line struct {
@@ -44,31 +55,204 @@ func (w lineWriter) Write(b []byte) (int, error) {
 	return len(b), nil
 }
 
-// Streams output via API to aggregator channel.
+// positionWriter tracks the position of a followed stream's output as it's
+// written so that the stream can be resumed where it left off
+type positionWriter struct {
+	io.Writer
+	pos apitypes.StreamPosition
+}
+
+// start starts tracking the (re)connected stream's position. resumedAt is the
+// position that the stream was resumed at, or nil if it wasn't resumed. A
+// stream that was resumed at an offset is still at that offset if it doesn't
+// report its own.
+func (w *positionWriter) start(stream io.Reader, resumedAt *apitypes.StreamPosition) {
+	offset, ok := client.StreamOffsetOf(stream)
+	if !ok && resumedAt != nil && resumedAt.HasOffset {
+		offset, ok = resumedAt.Offset, true
+	}
+	w.pos.Offset, w.pos.HasOffset = offset, ok
+}
+
+func (w *positionWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	w.pos.Offset += int64(n)
+	w.pos.Since = time.Now()
+	return n, err
+}
+
+// isNotResumable returns true if err is the error of resuming a stream that
+// isn't resumable
+func isNotResumable(err error) bool {
+	errObj, ok := err.(*apitypes.ErrorObj)
+	return ok && errObj.Kind == apitypes.BadActionRequest
+}
+
+// Streams output via API to aggregator channel. If since is set, then the
+// stream starts with the output since then.
 // Returns nil if streaming's not supported on this path.
-func tailStream(conn client.Client, agg chan line, path string) io.Closer {
-	stream, err := conn.Stream(path)
+func tailStream(conn client.Client, agg chan line, path string, since time.Time) io.Closer {
+	var stream io.ReadCloser
+	var err error
+	if since.IsZero() {
+		stream, err = conn.Stream(path)
+	} else {
+		stream, err = conn.ResumeStream(path, apitypes.StreamPosition{Since: since})
+		if isNotResumable(err) {
+			cmdutil.ErrPrintf("%v: its stream isn't resumable, so only its new output is shown\n", path)
+			stream, err = conn.Stream(path)
+		}
+	}
 	if err != nil {
 		if errObj, ok := err.(*apitypes.ErrorObj); ok {
 			if errObj.Kind == apitypes.UnsupportedAction {
 				// The resource exists but does not support the streaming action
 				return nil
 			}
-			cmdutil.ErrPrintf("%v\n", errObj.Msg)
+			cmdutil.ErrPrintf("%v\n", errObj)
 		} else {
 			cmdutil.ErrPrintf("%v\n", err)
 		}
 		return ioutil.NopCloser(nil)
 	}
 
-	// Start copying the stream to the aggregate channel
+	// Start copying the stream to the aggregate channel. Streams that end
+	// report why they ended (e.g. that the container exited), which is
+	// reported like any other error. Streams that error instead lost their
+	// connection to the server (e.g. because it restarted), so they're
+	// reconnected, and resumed where they left off if they're resumable.
+	followed := &followedStream{stream: stream}
 	go func() {
-		_, err := io.Copy(lineWriter{name: path, out: agg}, stream)
-		if err != nil {
+		reportErr := func(err error) {
 			agg <- line{Line: tail.Line{Time: time.Now(), Err: err}, source: path}
 		}
+		w := &positionWriter{Writer: lineWriter{name: path, out: agg}}
+		w.pos.Since = since
+		if since.IsZero() {
+			w.pos.Since = time.Now()
+		}
+		var resumedAt *apitypes.StreamPosition
+		for {
+			w.start(stream, resumedAt)
+			_, err := io.Copy(w, stream)
+			if followed.isClosed() {
+				return
+			}
+			if err == nil {
+				if end, ok := client.StreamEndOf(stream); ok {
+					reportErr(fmt.Errorf("stream ended: %v", end))
+				}
+				return
+			}
+			reportErr(fmt.Errorf("lost the connection to the server: %v. Reconnecting", err))
+			if stream, resumedAt, err = reconnectStream(conn, path, w.pos); err != nil {
+				reportErr(err)
+				return
+			}
+			if !followed.replace(stream) {
+				return
+			}
+			if resumedAt != nil {
+				reportErr(fmt.Errorf("reconnected. Resumed the stream at the %v", *resumedAt))
+			} else {
+				reportErr(fmt.Errorf("reconnected. Any output from while the connection was lost was missed"))
+			}
+		}
 	}()
-	return stream
+	return followed
+}
+
+// reconnectTimeout is how long tail waits for the server to come back after
+// a stream loses its connection to it
+const reconnectTimeout = 30 * time.Second
+
+// reconnectStream reconnects the stream. Resumable streams are resumed at pos,
+// i.e. via its offset if it's known, or else via its time. Streams that can't
+// be resumed at pos's offset are resumed at its time instead, since the
+// offset may not be known to the server (e.g. the stream's plugin only
+// supports resuming by time). It returns the position that the stream was
+// resumed at, or nil if it wasn't resumed.
+func reconnectStream(conn client.Client, path string, pos apitypes.StreamPosition) (io.ReadCloser, *apitypes.StreamPosition, error) {
+	deadline := time.Now().Add(reconnectTimeout)
+	positions := []apitypes.StreamPosition{pos}
+	if pos.HasOffset {
+		positions = append(positions, apitypes.StreamPosition{Since: pos.Since})
+	}
+	for {
+		time.Sleep(time.Second)
+		var stream io.ReadCloser
+		var err error
+		for len(positions) > 0 {
+			if stream, err = conn.ResumeStream(path, positions[0]); !isNotResumable(err) {
+				break
+			}
+			positions = positions[1:]
+		}
+		var resumedAt *apitypes.StreamPosition
+		if len(positions) > 0 {
+			resumedAt = &positions[0]
+		} else {
+			stream, err = conn.Stream(path)
+		}
+		if err == nil {
+			return stream, resumedAt, nil
+		}
+		if errObj, ok := err.(*apitypes.ErrorObj); ok {
+			// The server's back, but it refused the stream (e.g. because the
+			// resource no longer exists)
+			return nil, nil, fmt.Errorf("could not reconnect: %v", errObj.Msg)
+		}
+		if time.Now().After(deadline) {
+			return nil, nil, fmt.Errorf("could not reconnect within %v: %v", reconnectTimeout, err)
+		}
+	}
+}
+
+// followedStream is a stream that's replaced when it's reconnected
+type followedStream struct {
+	mux    sync.Mutex
+	stream io.ReadCloser
+	closed bool
+}
+
+func (s *followedStream) isClosed() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.closed
+}
+
+// replace replaces the stream with the reconnected stream. It returns false
+// (and closes the reconnected stream) if the stream was closed in the
+// meantime.
+func (s *followedStream) replace(stream io.ReadCloser) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		errz.Log(stream.Close())
+		return false
+	}
+	s.stream = stream
+	return true
+}
+
+func (s *followedStream) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+	return s.stream.Close()
+}
+
+// parseSince parses --since, which is either a duration ago or an RFC3339
+// timestamp
+func parseSince(since string) (time.Time, error) {
+	if d, err := time.ParseDuration(since); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--since must be a duration (e.g. 10m) or an RFC3339 timestamp, not %v", since)
+	}
+	return t, nil
 }
 
 var endOfFileLocation = tail.SeekInfo{Offset: 0, Whence: 2}
@@ -117,6 +301,21 @@ func tailMain(cmd *cobra.Command, args []string) exitCode {
 	if err != nil {
 		panic(err.Error())
 	}
+	sinceStr, err := cmd.Flags().GetString("since")
+	if err != nil {
+		panic(err.Error())
+	}
+	var since time.Time
+	if sinceStr != "" {
+		if !follow {
+			cmdutil.ErrPrintf("--since requires -f\n")
+			return exitCode{1}
+		}
+		if since, err = parseSince(sinceStr); err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCode{1}
+		}
+	}
 
 	if !follow {
 		// Defer to `/usr/bin/tail`
@@ -140,11 +339,16 @@ func tailMain(cmd *cobra.Command, args []string) exitCode {
 	}
 
 	conn := cmdutil.NewClient()
+	args, err = cmdutil.ExpandPaths(conn, args)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
 	agg := make(chan line)
 
 	// Try streaming as a resource, then as a file if that failed for predictable reasons
 	for _, path := range args {
-		if closer := tailStream(conn, agg, path); closer != nil {
+		if closer := tailStream(conn, agg, path, since); closer != nil {
 			defer func() { errz.Log(closer.Close()) }()
 			continue
 		}
@@ -159,7 +363,7 @@ func tailMain(cmd *cobra.Command, args []string) exitCode {
 	var last string
 	for ln := range agg {
 		if ln.Err != nil {
-			cmdutil.ErrPrintf("%v\n", ln.source, ln.Err)
+			cmdutil.ErrPrintf("%v: %v\n", ln.source, ln.Err)
 			continue
 		}
 
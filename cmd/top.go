@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	apitypes "github.com/puppetlabs/wash/api/types"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/spf13/cobra"
+)
+
+// clearScreen moves the cursor to the top-left corner and clears the screen
+const clearScreen = "\033[H\033[2J"
+
+func topCommand() *cobra.Command {
+	topCmd := &cobra.Command{
+		Use:   "top",
+		Short: "Displays a live dashboard of the Wash server's activity",
+		Long: `Displays a live dashboard of the Wash server's activity, refreshing it every --interval until
+it's interrupted. The dashboard includes the FUSE ops/sec, each plugin's call latency, the cache's hit
+rate, the active streams and execs, the concurrency limits' queues (a limit with waiting requests
+is saturated), and the most recent errors. Rates are averaged over the last 10
+seconds. Use --once to print the dashboard once instead, e.g. to save it to a file.`,
+		Args: cobra.NoArgs,
+		RunE: toRunE(topMain),
+	}
+	topCmd.Flags().Duration("interval", 1*time.Second, "How often the dashboard's refreshed")
+	topCmd.Flags().Bool("once", false, "Print the dashboard once, then exit")
+	return topCmd
+}
+
+func topMain(cmd *cobra.Command, args []string) exitCode {
+	interval, err := cmd.Flags().GetDuration("interval")
+	if err != nil {
+		panic(err.Error())
+	}
+	once, err := cmd.Flags().GetBool("once")
+	if err != nil {
+		panic(err.Error())
+	}
+	if interval <= 0 {
+		cmdutil.ErrPrintf("The interval must be positive\n")
+		return exitCode{1}
+	}
+
+	conn := cmdutil.NewClient()
+	for {
+		m, err := conn.Metrics()
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		if once {
+			cmdutil.Print(formatMetrics(m))
+			return exitCode{0}
+		}
+		cmdutil.Print(clearScreen + formatMetrics(m))
+		time.Sleep(interval)
+	}
+}
+
+func formatMetrics(m apitypes.Metrics) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "wash top - %v\n\n", m.Time.Local().Format("15:04:05"))
+
+	fmt.Fprintf(&b, "FUSE: %.1f ops/sec", m.FUSEOpsPerSecond)
+	ops := make([]string, 0, len(m.FUSEOps))
+	for op := range m.FUSEOps {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for i, op := range ops {
+		if i == 0 {
+			b.WriteString(" (")
+		} else {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%v %.1f", op, m.FUSEOps[op])
+		if i == len(ops)-1 {
+			b.WriteString(")")
+		}
+	}
+	b.WriteString("\n")
+
+	cacheHitRate := "-"
+	if m.Cache.Hits+m.Cache.Misses > 0 {
+		cacheHitRate = fmt.Sprintf("%.0f%%", 100*m.Cache.HitRate)
+	}
+	fmt.Fprintf(&b, "Cache: %v hit rate (%v hits, %v misses)\n", cacheHitRate, m.Cache.Hits, m.Cache.Misses)
+	fmt.Fprintf(&b, "Active: %v streams, %v execs\n\n", m.ActiveStreams, m.ActiveExecs)
+
+	if len(m.Calls) > 0 {
+		headers := []cmdutil.ColumnHeader{
+			{ShortName: "plugin", FullName: "PLUGIN"},
+			{ShortName: "action", FullName: "ACTION"},
+			{ShortName: "calls", FullName: "CALLS"},
+			{ShortName: "rate", FullName: "CALLS/SEC"},
+			{ShortName: "avg", FullName: "AVG"},
+			{ShortName: "max", FullName: "MAX"},
+			{ShortName: "last", FullName: "LAST"},
+		}
+		table := make([][]string, len(m.Calls))
+		for i, c := range m.Calls {
+			table[i] = []string{
+				c.Plugin,
+				c.Action,
+				fmt.Sprint(c.Count),
+				fmt.Sprintf("%.1f", c.PerSecond),
+				fmt.Sprintf("%.1fms", c.AvgMS),
+				fmt.Sprintf("%vms", c.MaxMS),
+				fmt.Sprintf("%vms", c.LastMS),
+			}
+		}
+		b.WriteString(cmdutil.NewTableWithHeaders(headers, table).Format())
+		b.WriteString("\n")
+	}
+
+	if len(m.Queues) > 0 {
+		headers := []cmdutil.ColumnHeader{
+			{ShortName: "limit", FullName: "LIMIT"},
+			{ShortName: "max", FullName: "MAX"},
+			{ShortName: "inuse", FullName: "IN USE"},
+			{ShortName: "waiting", FullName: "WAITING"},
+			{ShortName: "queued", FullName: "QUEUED"},
+			{ShortName: "wait", FullName: "AVG WAIT"},
+		}
+		table := make([][]string, len(m.Queues))
+		for i, q := range m.Queues {
+			max := "-"
+			if q.Limit > 0 {
+				max = fmt.Sprint(q.Limit)
+			}
+			waiting := fmt.Sprint(q.Waiting)
+			if q.Waiting > 0 {
+				waiting += " (saturated)"
+			}
+			table[i] = []string{
+				q.Name,
+				max,
+				fmt.Sprint(q.InUse),
+				waiting,
+				fmt.Sprint(q.Queued),
+				fmt.Sprintf("%.1fms", q.AvgWaitMS),
+			}
+		}
+		b.WriteString(cmdutil.NewTableWithHeaders(headers, table).Format())
+		b.WriteString("\n")
+	}
+
+	if len(m.RecentErrors) > 0 {
+		b.WriteString("Recent errors:\n")
+		// Show the most recent errors first
+		for i := len(m.RecentErrors) - 1; i >= 0; i-- {
+			e := m.RecentErrors[i]
+			fmt.Fprintf(&b, "  %v %v\n", e.Time.Local().Format("15:04:05"), e.Message)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package cmdutil
+
+import (
+	"reflect"
+	"sort"
+)
+
+// ChangeKind describes how a value changed between two JSON objects
+type ChangeKind string
+
+// These are the possible change kinds
+const (
+	Added   ChangeKind = "+"
+	Removed ChangeKind = "-"
+	Changed ChangeKind = "~"
+)
+
+// Change describes a changed key. Nested keys are joined with a ".", e.g.
+// "tags.owner".
+type Change struct {
+	Kind     ChangeKind
+	Key      string
+	Old, New interface{}
+}
+
+// Diff returns the changes from before to after, sorted by key. Nested objects are
+// diffed key-by-key. Other values (including arrays) are compared as a whole.
+func Diff(before, after map[string]interface{}) []Change {
+	var changes []Change
+	diff("", before, after, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+func diff(prefix string, before, after map[string]interface{}, changes *[]Change) {
+	for key, oldValue := range before {
+		fullKey := prefix + key
+		newValue, ok := after[key]
+		if !ok {
+			*changes = append(*changes, Change{Kind: Removed, Key: fullKey, Old: oldValue})
+			continue
+		}
+		oldObj, oldIsObj := oldValue.(map[string]interface{})
+		newObj, newIsObj := newValue.(map[string]interface{})
+		if oldIsObj && newIsObj {
+			diff(fullKey+".", oldObj, newObj, changes)
+		} else if !reflect.DeepEqual(oldValue, newValue) {
+			*changes = append(*changes, Change{Kind: Changed, Key: fullKey, Old: oldValue, New: newValue})
+		}
+	}
+	for key, newValue := range after {
+		if _, ok := before[key]; !ok {
+			*changes = append(*changes, Change{Kind: Added, Key: prefix + key, New: newValue})
+		}
+	}
+}
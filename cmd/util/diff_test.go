@@ -0,0 +1,35 @@
+package cmdutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	before := map[string]interface{}{
+		"state": "running",
+		"ip":    "10.0.0.1",
+		"ports": []interface{}{80.0},
+		"tags": map[string]interface{}{
+			"env":  "prod",
+			"team": "web",
+		},
+	}
+	after := map[string]interface{}{
+		"state": "stopped",
+		"ports": []interface{}{80.0},
+		"tags": map[string]interface{}{
+			"env":   "prod",
+			"owner": "alice",
+		},
+	}
+	assert.Equal(t, []Change{
+		{Kind: Removed, Key: "ip", Old: "10.0.0.1"},
+		{Kind: Changed, Key: "state", Old: "running", New: "stopped"},
+		{Kind: Added, Key: "tags.owner", New: "alice"},
+		{Kind: Removed, Key: "tags.team", Old: "web"},
+	}, Diff(before, after))
+
+	assert.Empty(t, Diff(before, before))
+}
@@ -0,0 +1,223 @@
+package cmdutil
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// These are the supported exec report formats
+const (
+	ReportJSON  = "json"
+	ReportCSV   = "csv"
+	ReportJUnit = "junit"
+)
+
+// These are the statuses of an ExecResult. A command passed if it exited with
+// 0, and failed if it exited with anything else. It errored if it couldn't be
+// executed, or if the exec endpoint errored while it was running.
+const (
+	ExecPassed  = "passed"
+	ExecFailed  = "failed"
+	ExecErrored = "errored"
+)
+
+// ExecResult is the result of executing a command on one of an ExecReport's
+// targets. OutputDigest is the SHA-256 digest of the command's combined
+// stdout and stderr, so that reports can be compared to spot the targets whose
+// output changed without including the output itself.
+type ExecResult struct {
+	Path         string        `json:"path"`
+	Status       string        `json:"status"`
+	ExitCode     int           `json:"exit_code"`
+	Duration     time.Duration `json:"-"`
+	OutputBytes  int64         `json:"output_bytes"`
+	OutputDigest string        `json:"output_digest"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// MarshalJSON includes the duration in (fractional) seconds, which is easier
+// for dashboards to consume than a Go duration
+func (r ExecResult) MarshalJSON() ([]byte, error) {
+	type alias ExecResult
+	return json.Marshal(struct {
+		alias
+		Duration float64 `json:"duration_seconds"`
+	}{alias(r), r.Duration.Seconds()})
+}
+
+// ExecReport summarizes executing a command on several targets, e.g. via a
+// glob pattern
+type ExecReport struct {
+	Command string       `json:"command"`
+	Args    []string     `json:"args"`
+	Start   time.Time    `json:"start"`
+	Results []ExecResult `json:"results"`
+}
+
+// Failures returns the number of results that failed or errored
+func (r ExecReport) Failures() int {
+	failures := 0
+	for _, result := range r.Results {
+		if result.Status != ExecPassed {
+			failures++
+		}
+	}
+	return failures
+}
+
+// ReportFormatFor returns the format of the report at path. format is the
+// explicitly requested format, if any; otherwise, it's inferred from path's
+// extension and defaults to JSON.
+func ReportFormatFor(path string, format string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			return ReportCSV, nil
+		case ".xml":
+			return ReportJUnit, nil
+		default:
+			return ReportJSON, nil
+		}
+	}
+	switch format {
+	case ReportJSON, ReportCSV, ReportJUnit:
+		return format, nil
+	default:
+		return "", fmt.Errorf("the %v report format is not supported. Supported formats are '%v', '%v' or '%v'", format, ReportJSON, ReportCSV, ReportJUnit)
+	}
+}
+
+// SaveExecReport writes r to the file at path in the given format
+func SaveExecReport(path string, format string, r ExecReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = WriteExecReport(f, format, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// WriteExecReport writes r to w in the given format
+func WriteExecReport(w io.Writer, format string, r ExecReport) error {
+	switch format {
+	case ReportJSON:
+		return writeJSONReport(w, r)
+	case ReportCSV:
+		return writeCSVReport(w, r)
+	case ReportJUnit:
+		return writeJUnitReport(w, r)
+	default:
+		return fmt.Errorf("the %v report format is not supported", format)
+	}
+}
+
+func writeJSONReport(w io.Writer, r ExecReport) error {
+	if r.Results == nil {
+		r.Results = []ExecResult{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func writeCSVReport(w io.Writer, r ExecReport) error {
+	cw := csv.NewWriter(w)
+	records := [][]string{{"path", "status", "exit_code", "duration_seconds", "output_bytes", "output_digest", "error"}}
+	for _, result := range r.Results {
+		records = append(records, []string{
+			result.Path,
+			result.Status,
+			strconv.Itoa(result.ExitCode),
+			strconv.FormatFloat(result.Duration.Seconds(), 'f', 3, 64),
+			strconv.FormatInt(result.OutputBytes, 10),
+			result.OutputDigest,
+			result.Error,
+		})
+	}
+	return cw.WriteAll(records)
+}
+
+// The JUnit XML schema, as understood by the common CI servers. Each target's
+// a test case, so failed targets show up as failed tests.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+func junitTime(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+func writeJUnitReport(w io.Writer, r ExecReport) error {
+	name := strings.Join(append([]string{r.Command}, r.Args...), " ")
+	suite := junitTestSuite{
+		Name:      name,
+		Tests:     len(r.Results),
+		Timestamp: r.Start.UTC().Format("2006-01-02T15:04:05"),
+	}
+	var total time.Duration
+	for _, result := range r.Results {
+		total += result.Duration
+		tc := junitTestCase{
+			Name:      result.Path,
+			ClassName: name,
+			Time:      junitTime(result.Duration),
+			SystemOut: fmt.Sprintf("output_bytes: %v, output_digest: %v", result.OutputBytes, result.OutputDigest),
+		}
+		switch result.Status {
+		case ExecFailed:
+			suite.Failures++
+			tc.Failure = &junitMessage{Message: fmt.Sprintf("exited with %v", result.ExitCode)}
+		case ExecErrored:
+			suite.Errors++
+			tc.Error = &junitMessage{Message: result.Error}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = junitTime(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
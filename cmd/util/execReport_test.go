@@ -0,0 +1,106 @@
+package cmdutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestExecReport() ExecReport {
+	return ExecReport{
+		Command: "uptime",
+		Args:    []string{"-p"},
+		Start:   time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC),
+		Results: []ExecResult{
+			{Path: "/docker/containers/web-1", Status: ExecPassed, Duration: 1500 * time.Millisecond, OutputBytes: 3, OutputDigest: "sha256:abc"},
+			{Path: "/docker/containers/web-2", Status: ExecFailed, ExitCode: 2, Duration: time.Second, OutputDigest: "sha256:def"},
+			{Path: "/docker/containers/web-3", Status: ExecErrored, Error: "the exec endpoint errored", OutputDigest: "sha256:ghi"},
+		},
+	}
+}
+
+func TestReportFormatFor(t *testing.T) {
+	for path, expected := range map[string]string{
+		"report.json": ReportJSON,
+		"report.csv":  ReportCSV,
+		"report.XML":  ReportJUnit,
+		"report":      ReportJSON,
+	} {
+		format, err := ReportFormatFor(path, "")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, format, path)
+	}
+
+	format, err := ReportFormatFor("report.xml", ReportCSV)
+	assert.NoError(t, err)
+	assert.Equal(t, ReportCSV, format)
+
+	_, err = ReportFormatFor("report", "yaml")
+	assert.EqualError(t, err, "the yaml report format is not supported. Supported formats are 'json', 'csv' or 'junit'")
+}
+
+func TestExecReportFailures(t *testing.T) {
+	assert.Equal(t, 2, newTestExecReport().Failures())
+	assert.Equal(t, 0, ExecReport{}.Failures())
+}
+
+func TestWriteJSONExecReport(t *testing.T) {
+	var buf bytes.Buffer
+	if !assert.NoError(t, WriteExecReport(&buf, ReportJSON, newTestExecReport())) {
+		return
+	}
+	var decoded map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded)) {
+		return
+	}
+	assert.Equal(t, "uptime", decoded["command"])
+	results := decoded["results"].([]interface{})
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, map[string]interface{}{
+			"path":             "/docker/containers/web-1",
+			"status":           ExecPassed,
+			"exit_code":        0.0,
+			"duration_seconds": 1.5,
+			"output_bytes":     3.0,
+			"output_digest":    "sha256:abc",
+		}, results[0])
+		assert.Equal(t, "the exec endpoint errored", results[2].(map[string]interface{})["error"])
+	}
+}
+
+func TestWriteCSVExecReport(t *testing.T) {
+	var buf bytes.Buffer
+	if assert.NoError(t, WriteExecReport(&buf, ReportCSV, newTestExecReport())) {
+		assert.Equal(t, `path,status,exit_code,duration_seconds,output_bytes,output_digest,error
+/docker/containers/web-1,passed,0,1.500,3,sha256:abc,
+/docker/containers/web-2,failed,2,1.000,0,sha256:def,
+/docker/containers/web-3,errored,0,0.000,0,sha256:ghi,the exec endpoint errored
+`, buf.String())
+	}
+}
+
+func TestWriteJUnitExecReport(t *testing.T) {
+	var buf bytes.Buffer
+	if assert.NoError(t, WriteExecReport(&buf, ReportJUnit, newTestExecReport())) {
+		assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="uptime -p" tests="3" failures="1" errors="1" time="2.500" timestamp="2019-10-01T12:00:00">
+    <testcase name="/docker/containers/web-1" classname="uptime -p" time="1.500">
+      <system-out>output_bytes: 3, output_digest: sha256:abc</system-out>
+    </testcase>
+    <testcase name="/docker/containers/web-2" classname="uptime -p" time="1.000">
+      <failure message="exited with 2"></failure>
+      <system-out>output_bytes: 0, output_digest: sha256:def</system-out>
+    </testcase>
+    <testcase name="/docker/containers/web-3" classname="uptime -p" time="0.000">
+      <error message="the exec endpoint errored"></error>
+      <system-out>output_bytes: 0, output_digest: sha256:ghi</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+	}
+}
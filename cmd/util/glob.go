@@ -0,0 +1,44 @@
+package cmdutil
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/puppetlabs/wash/api/client"
+	apitypes "github.com/puppetlabs/wash/api/types"
+)
+
+// ExpandPaths expands the glob patterns in paths (see apitypes.IsGlob) into
+// the paths of the entries that they match. The expansion's done by the Wash
+// server, so it works for quoted patterns and for ** (which most shells don't
+// support). Paths that aren't patterns are returned as-is, and the matches of
+// relative patterns are relative to the current directory.
+func ExpandPaths(conn client.Client, paths []string) ([]string, error) {
+	var expanded []string
+	for _, path := range paths {
+		if !apitypes.IsGlob(path) {
+			expanded = append(expanded, path)
+			continue
+		}
+		matches, err := conn.Glob(path)
+		if err != nil {
+			return nil, err
+		}
+		var cwd string
+		if !filepath.IsAbs(path) {
+			if cwd, err = os.Getwd(); err != nil {
+				return nil, err
+			}
+		}
+		for _, match := range matches {
+			matchPath := match.Path
+			if cwd != "" {
+				if rel, err := filepath.Rel(cwd, matchPath); err == nil {
+					matchPath = rel
+				}
+			}
+			expanded = append(expanded, matchPath)
+		}
+	}
+	return expanded, nil
+}
@@ -0,0 +1,41 @@
+package cmdutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/puppetlabs/wash/api/client"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// globClient is a client whose Glob returns the configured matches
+type globClient struct {
+	client.Client
+	matches map[string][]string
+}
+
+func (c globClient) Glob(pattern string) ([]apitypes.Entry, error) {
+	var entries []apitypes.Entry
+	for _, path := range c.matches[pattern] {
+		entries = append(entries, apitypes.Entry{Path: path})
+	}
+	return entries, nil
+}
+
+func TestExpandPaths(t *testing.T) {
+	cwd, err := os.Getwd()
+	if !assert.NoError(t, err) {
+		return
+	}
+	conn := globClient{matches: map[string][]string{
+		"/docker/containers/web-*": {"/docker/containers/web-1", "/docker/containers/web-2"},
+		"web-*":                    {filepath.Join(cwd, "web-1")},
+	}}
+
+	paths, err := ExpandPaths(conn, []string{"/docker/containers/web-*", "/docker/containers/db", "web-*"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"/docker/containers/web-1", "/docker/containers/web-2", "/docker/containers/db", "web-1"}, paths)
+	}
+}
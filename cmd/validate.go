@@ -38,7 +38,7 @@ Each line represents validation of an entry type. The 'lrsx' fields represent su
 method.`,
 		Args:   cobra.ExactArgs(1),
 		PreRun: bindServerArgs,
-		RunE:   toRunE(validateMain),
+		RunE:   toServerRunE(validateMain),
 	}
 	validateCmd.Flags().IntP("parallel", "p", 10, "Number of entries to validate in parallel")
 	validateCmd.Flags().BoolP("all", "a", false, "Validate all entries rather than an example at each level of hierarchy")
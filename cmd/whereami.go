@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/puppetlabs/wash/cmd/internal/shell"
+	cmdutil "github.com/puppetlabs/wash/cmd/util"
+	"github.com/spf13/cobra"
+)
+
+func whereamiCommand() *cobra.Command {
+	whereamiCmd := &cobra.Command{
+		Use:   "whereami [<path>]",
+		Short: "Prints the resource context of the specified path",
+		Long: `Prints the resources that the specified path (or the current directory) is nested under, e.g. the
+Kubernetes context, namespace and pod of a pod's file. Nothing is printed for paths outside of Wash.
+
+Use --init to print a script that adds the resource context to your shell's prompt. For example,
+add 'eval "$(wash whereami --init bash)"' to your ~/.bashrc. Wash's shell already does this.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: toRunE(whereamiMain),
+	}
+	whereamiCmd.Flags().StringP("output", "o", "text", "Set the output format (text, json or yaml)")
+	whereamiCmd.Flags().String("init", "", "Print the shell integration script for the given shell (bash or zsh)")
+	return whereamiCmd
+}
+
+func whereamiMain(cmd *cobra.Command, args []string) exitCode {
+	initShell, err := cmd.Flags().GetString("init")
+	if err != nil {
+		panic(err.Error())
+	}
+	if initShell != "" {
+		script, err := shell.Integration(initShell)
+		if err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+		cmdutil.Print(script)
+		return exitCode{0}
+	}
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		panic(err.Error())
+	}
+	var marshaller cmdutil.Marshaller
+	if output != "text" {
+		if marshaller, err = cmdutil.NewMarshaller(output); err != nil {
+			cmdutil.ErrPrintf("%v\n", err)
+			return exitCodeFor(err)
+		}
+	}
+
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	conn := cmdutil.NewClient()
+	resourceContext, err := conn.Whereami(path)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	if marshaller == nil {
+		if str := resourceContext.String(); str != "" {
+			cmdutil.Println(str)
+		}
+		return exitCode{0}
+	}
+	marshalledContext, err := marshaller.Marshal(resourceContext)
+	if err != nil {
+		cmdutil.ErrPrintf("%v\n", err)
+		return exitCodeFor(err)
+	}
+	cmdutil.Println(marshalledContext)
+	return exitCode{0}
+}
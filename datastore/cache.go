@@ -18,6 +18,7 @@ import (
 type Cache interface {
 	GetOrUpdate(category, key string, ttl time.Duration, resetTTLOnHit bool, generateValue func() (interface{}, error)) (interface{}, error)
 	Get(category, key string) (interface{}, error)
+	Refresh(category, key string, ttl time.Duration, generateValue func() (interface{}, error)) error
 	Flush()
 	Delete(matcher *regexp.Regexp) []string
 }
@@ -91,6 +92,18 @@ func (cache *MemCache) Get(category, key string) (interface{}, error) {
 	return nil, nil
 }
 
+// uncachedError wraps an error that shouldn't be cached.
+type uncachedError struct {
+	error
+}
+
+// DoNotCache wraps err so that GetOrUpdate returns it without caching it. Use it
+// for transient errors, e.g. errors caused by a cancelled context, that shouldn't
+// be returned to subsequent callers.
+func DoNotCache(err error) error {
+	return uncachedError{err}
+}
+
 // GetOrUpdate attempts to retrieve the value stored at the given key.
 // If the value does not exist, then it generates the value using
 // the generateValue function and stores it with the specified ttl.
@@ -134,6 +147,9 @@ func (cache *MemCache) GetOrUpdate(category, key string, ttl time.Duration, rese
 	}
 
 	value, err := generateValue()
+	if uerr, ok := err.(uncachedError); ok {
+		return nil, uerr.error
+	}
 	// Cache error responses as well. These are often authentication or availability failures
 	// and we don't want to continually query the API on failures.
 	if err != nil {
@@ -145,6 +161,41 @@ func (cache *MemCache) GetOrUpdate(category, key string, ttl time.Duration, rese
 	return value, nil
 }
 
+// Refresh regenerates the value stored at the given key and stores it with the
+// specified ttl. The existing value is still returned by Get and GetOrUpdate while
+// the new value's being generated. Unlike GetOrUpdate, errors aren't cached.
+// Instead, the existing value is kept and the error is returned. Refresh is a
+// no-op if the key isn't cached, e.g. because it expired or was deleted while the
+// new value was being generated.
+func (cache *MemCache) Refresh(category, key string, ttl time.Duration, generateValue func() (interface{}, error)) error {
+	if _, found := cache.instance.Get(formKey(category, key)); !found {
+		return nil
+	}
+
+	value, err := generateValue()
+	if uerr, ok := err.(uncachedError); ok {
+		err = uerr.error
+	}
+	if err != nil {
+		return err
+	}
+
+	cache.mux.RLock()
+	defer cache.mux.RUnlock()
+
+	l := cache.lockForKey(category, key)
+	l.Lock()
+	defer l.Unlock()
+
+	key = formKey(category, key)
+	if _, found := cache.instance.Get(key); !found {
+		return nil
+	}
+	log.Debugf("Refreshed %v", key)
+	cache.instance.Set(key, value, ttl)
+	return nil
+}
+
 func (cache *MemCache) deleteClosestToExpiration() {
 	var candidate string
 	now := time.Now().UnixNano()
@@ -99,6 +99,39 @@ func (suite *MemCacheTestSuite) TestGetOrUpdateWithReset() {
 	suite.thing.AssertNumberOfCalls(suite.T(), "update", 2)
 }
 
+func (suite *MemCacheTestSuite) TestGetOrUpdateDoNotCache() {
+	suite.thing.On("update").Return(nil, DoNotCache(errors.New("cancelled"))).Once()
+	_, err := suite.mem.GetOrUpdate("cat", "an entry", time.Second, false, suite.update)
+	suite.EqualError(err, "cancelled")
+	_, ok := suite.mem.instance.Get("cat::an entry")
+	suite.False(ok)
+
+	// The failed update wasn't cached, so this should call update again.
+	suite.thing.On("update").Return(anything, nil).Once()
+	suite.validate(suite.mem.GetOrUpdate("cat", "an entry", time.Second, false, suite.update))
+	suite.thing.AssertNumberOfCalls(suite.T(), "update", 2)
+}
+
+func (suite *MemCacheTestSuite) TestRefresh() {
+	// Refresh is a no-op if the key isn't cached
+	suite.NoError(suite.mem.Refresh("cat", "an entry", time.Second, suite.update))
+	suite.thing.AssertNotCalled(suite.T(), "update")
+
+	suite.mem.instance.Set("cat::an entry", "old", time.Second)
+	suite.thing.On("update").Return(nil, errors.New("failed")).Once()
+	suite.EqualError(suite.mem.Refresh("cat", "an entry", time.Second, suite.update), "failed")
+	// The error shouldn't replace the existing value
+	val, err := suite.mem.Get("cat", "an entry")
+	suite.NoError(err)
+	suite.Equal("old", val)
+
+	suite.thing.On("update").Return(anything, nil).Once()
+	suite.NoError(suite.mem.Refresh("cat", "an entry", time.Second, suite.update))
+	val, err = suite.mem.Get("cat", "an entry")
+	suite.NoError(err)
+	suite.Equal(anything, val)
+}
+
 func (suite *MemCacheTestSuite) TestGet() {
 	val, err := suite.mem.Get("foo", "bar")
 	suite.Nil(val)
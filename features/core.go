@@ -0,0 +1,259 @@
+// Package features implements Wash's feature flags. Flags gate experimental
+// behaviors so that they can ship disabled by default. They're registered by
+// the packages that implement the gated behavior and can be toggled at runtime
+// (e.g. via the API) without rebuilding or restarting the Wash server. Each
+// flag can also be overridden for specific plugins, e.g. to try a behavior out
+// on one plugin before enabling it everywhere.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// pluginsPrefix prefixes the names of a flag's per-plugin overrides. For
+// example, the docker plugin's override of the foo flag is named
+// plugins.docker.foo.
+const pluginsPrefix = "plugins."
+
+// Flag represents a feature flag
+type Flag struct {
+	name        string
+	description string
+	mux         sync.RWMutex
+	enabled     bool
+	overrides   map[string]bool
+}
+
+// Name returns the flag's name
+func (f *Flag) Name() string {
+	return f.name
+}
+
+// Description returns the flag's description
+func (f *Flag) Description() string {
+	return f.description
+}
+
+// Enabled returns true if the flag's enabled for the plugin. The plugin's
+// override is used if it has one. Otherwise, the flag's (global) value is
+// used. An empty plugin returns the flag's value.
+func (f *Flag) Enabled(plugin string) bool {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+	if enabled, ok := f.overrides[plugin]; ok {
+		return enabled
+	}
+	return f.enabled
+}
+
+// Overrides returns the flag's per-plugin overrides
+func (f *Flag) Overrides() map[string]bool {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+	overrides := make(map[string]bool, len(f.overrides))
+	for plugin, enabled := range f.overrides {
+		overrides[plugin] = enabled
+	}
+	return overrides
+}
+
+func (f *Flag) set(plugin string, enabled bool) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if plugin == "" {
+		f.enabled = enabled
+	} else {
+		f.overrides[plugin] = enabled
+	}
+}
+
+var registryMux sync.Mutex
+var registry = make(map[string]*Flag)
+
+// configured contains the values set by Configure, keyed by the flag's name
+// and then by plugin. The empty plugin is the flag's value. They override the
+// default values of newly registered flags.
+var configured = make(map[string]map[string]bool)
+
+// Register registers a flag with the given name, description and default
+// value. Experimental behaviors should default to false. If a flag with the
+// same name was already registered, then Register returns the existing flag.
+func Register(name string, description string, defaultValue bool) *Flag {
+	if strings.HasPrefix(name, pluginsPrefix) {
+		panic(fmt.Sprintf("features.Register: flag %v cannot start with %v since that's reserved for the per-plugin overrides", name, pluginsPrefix))
+	}
+
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	if f, ok := registry[name]; ok {
+		return f
+	}
+	f := &Flag{
+		name:        name,
+		description: description,
+		enabled:     defaultValue,
+		overrides:   make(map[string]bool),
+	}
+	for plugin, enabled := range configured[name] {
+		f.set(plugin, enabled)
+	}
+	registry[name] = f
+	return f
+}
+
+// Get returns the flag with the given name
+func Get(name string) (*Flag, bool) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	f, ok := registry[name]
+	return f, ok
+}
+
+// All returns all of the registered flags, sorted by name
+func All() []*Flag {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	fs := make([]*Flag, 0, len(registry))
+	for _, f := range registry {
+		fs = append(fs, f)
+	}
+	sort.Slice(fs, func(i, j int) bool { return fs[i].name < fs[j].name })
+	return fs
+}
+
+// Set enables or disables the named flag. If plugin is set, then only the
+// plugin's override is set.
+func Set(name string, plugin string, enabled bool) (*Flag, error) {
+	f, ok := Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a registered feature flag", name)
+	}
+	f.set(plugin, enabled)
+	return f, nil
+}
+
+// Configure sets the flags according to cfg, which is a (possibly nested) map
+// of flag names to booleans. The per-plugin overrides are in the plugins key.
+// For example,
+//
+//	{"fuse": {"foo": true}, "plugins": {"docker": {"fuse": {"foo": false}}}}
+//
+// enables the fuse.foo flag for every plugin except docker. Flags that aren't
+// registered yet are set when they're registered. Since flags are registered
+// when Wash starts, a warning's logged for each of them in case its name has a
+// typo.
+func Configure(cfg map[string]interface{}) error {
+	unknown, err := configure(cfg)
+	if err != nil {
+		return err
+	}
+	for _, name := range unknown {
+		log.Warnf("Configured the unknown feature flag %v. Run `wash features` to see the available flags.", name)
+	}
+	return nil
+}
+
+// configure is Configure. It returns the names of the configured flags that
+// aren't registered, sorted by name.
+func configure(cfg map[string]interface{}) ([]string, error) {
+	values := make(map[string]bool)
+	if err := flatten("", cfg, values); err != nil {
+		return nil, err
+	}
+
+	type setting struct {
+		name    string
+		plugin  string
+		enabled bool
+	}
+	settings := make([]setting, 0, len(values))
+	for key, enabled := range values {
+		s := setting{name: key, enabled: enabled}
+		if strings.HasPrefix(key, pluginsPrefix) {
+			segments := strings.SplitN(strings.TrimPrefix(key, pluginsPrefix), ".", 2)
+			if len(segments) < 2 {
+				return nil, fmt.Errorf("feature flag override %v must be named %v<plugin>.<flag>", key, pluginsPrefix)
+			}
+			s.plugin, s.name = segments[0], segments[1]
+		}
+		settings = append(settings, s)
+	}
+
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	unknown := make(map[string]bool)
+	for _, s := range settings {
+		if configured[s.name] == nil {
+			configured[s.name] = make(map[string]bool)
+		}
+		configured[s.name][s.plugin] = s.enabled
+		if f, ok := registry[s.name]; ok {
+			f.set(s.plugin, s.enabled)
+		} else {
+			unknown[s.name] = true
+		}
+	}
+	names := make([]string, 0, len(unknown))
+	for name := range unknown {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func flatten(prefix string, cfg map[string]interface{}, values map[string]bool) error {
+	for key, rawValue := range cfg {
+		name := strings.TrimPrefix(prefix+"."+key, ".")
+		switch value := rawValue.(type) {
+		case bool:
+			values[name] = value
+		case map[string]interface{}:
+			if err := flatten(name, value, values); err != nil {
+				return err
+			}
+		case map[interface{}]interface{}:
+			mp := make(map[string]interface{}, len(value))
+			for k, v := range value {
+				mp[fmt.Sprintf("%v", k)] = v
+			}
+			if err := flatten(name, mp, values); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("feature flag %v must be true or false, not %v", name, value)
+		}
+	}
+	return nil
+}
+
+var persister func(key string, enabled bool) error
+
+// PersistWith sets the function that's used by Persist to persist a flag's
+// value, e.g. to Wash's config file. The key is the flag's name, or the
+// override's name (plugins.<plugin>.<flag>) for a plugin's override.
+func PersistWith(f func(key string, enabled bool) error) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	persister = f
+}
+
+// Persist persists the flag's current value (or the plugin's override if
+// plugin is set) so that it's used the next time the Wash server starts.
+func Persist(f *Flag, plugin string) error {
+	registryMux.Lock()
+	p := persister
+	registryMux.Unlock()
+	if p == nil {
+		return fmt.Errorf("feature flags cannot be persisted")
+	}
+	key := f.Name()
+	if plugin != "" {
+		key = pluginsPrefix + plugin + "." + key
+	}
+	return p(key, f.Enabled(plugin))
+}
@@ -0,0 +1,155 @@
+package features
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FeaturesTestSuite struct {
+	suite.Suite
+}
+
+func (suite *FeaturesTestSuite) TearDownTest() {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	registry = make(map[string]*Flag)
+	configured = make(map[string]map[string]bool)
+	persister = nil
+}
+
+func (suite *FeaturesTestSuite) TestRegister() {
+	f := Register("fuse.foo", "a flag", false)
+	suite.Equal("fuse.foo", f.Name())
+	suite.Equal("a flag", f.Description())
+	suite.False(f.Enabled(""))
+	suite.False(f.Enabled("docker"))
+
+	// Registering the same flag again should return the existing flag
+	suite.True(f == Register("fuse.foo", "another flag", true))
+	suite.False(f.Enabled(""))
+
+	got, ok := Get("fuse.foo")
+	suite.True(ok)
+	suite.True(f == got)
+	_, ok = Get("fuse.bar")
+	suite.False(ok)
+
+	suite.Panics(func() { Register("plugins.foo", "", false) })
+}
+
+func (suite *FeaturesTestSuite) TestAll() {
+	b := Register("b", "", false)
+	a := Register("a", "", false)
+	suite.Equal([]*Flag{a, b}, All())
+}
+
+func (suite *FeaturesTestSuite) TestSet() {
+	Register("foo", "", false)
+
+	f, err := Set("foo", "", true)
+	if suite.NoError(err) {
+		suite.True(f.Enabled(""))
+		suite.True(f.Enabled("docker"))
+	}
+
+	// Plugins' overrides take precedence over the flag's value
+	_, err = Set("foo", "docker", false)
+	if suite.NoError(err) {
+		suite.True(f.Enabled(""))
+		suite.True(f.Enabled("aws"))
+		suite.False(f.Enabled("docker"))
+		suite.Equal(map[string]bool{"docker": false}, f.Overrides())
+	}
+
+	_, err = Set("bar", "", true)
+	suite.EqualError(err, "bar is not a registered feature flag")
+}
+
+func (suite *FeaturesTestSuite) TestConfigure() {
+	foo := Register("fuse.foo", "", false)
+
+	err := Configure(map[string]interface{}{
+		"fuse": map[string]interface{}{
+			"foo": true,
+		},
+		"plugins": map[interface{}]interface{}{
+			"docker": map[string]interface{}{
+				"fuse.foo": false,
+				"bar":      true,
+			},
+		},
+	})
+	if !suite.NoError(err) {
+		return
+	}
+	suite.True(foo.Enabled(""))
+	suite.False(foo.Enabled("docker"))
+
+	// Flags that are registered after Configure should use the configured
+	// values instead of their default value
+	bar := Register("bar", "", false)
+	suite.False(bar.Enabled(""))
+	suite.True(bar.Enabled("docker"))
+}
+
+func (suite *FeaturesTestSuite) TestConfigure_ReturnsUnknownFlags() {
+	Register("fuse.foo", "", false)
+	unknown, err := configure(map[string]interface{}{
+		"fuse": map[string]interface{}{
+			"foo": true,
+			"fo":  true,
+		},
+		"plugins": map[string]interface{}{
+			"docker": map[string]interface{}{
+				"fuse.foo": false,
+				"bar":      true,
+			},
+		},
+		"baz": false,
+	})
+	if suite.NoError(err) {
+		suite.Equal([]string{"bar", "baz", "fuse.fo"}, unknown)
+	}
+}
+
+func (suite *FeaturesTestSuite) TestConfigure_Errors() {
+	err := Configure(map[string]interface{}{
+		"fuse": map[string]interface{}{
+			"foo": "yes",
+		},
+	})
+	suite.EqualError(err, "feature flag fuse.foo must be true or false, not yes")
+
+	err = Configure(map[string]interface{}{
+		"plugins": map[string]interface{}{
+			"docker": true,
+		},
+	})
+	suite.EqualError(err, "feature flag override plugins.docker must be named plugins.<plugin>.<flag>")
+}
+
+func (suite *FeaturesTestSuite) TestPersist() {
+	f := Register("foo", "", true)
+	suite.EqualError(Persist(f, ""), "feature flags cannot be persisted")
+
+	persisted := make(map[string]bool)
+	PersistWith(func(key string, enabled bool) error {
+		if key == "bar" {
+			return fmt.Errorf("failed to persist %v", key)
+		}
+		persisted[key] = enabled
+		return nil
+	})
+	_, err := Set("foo", "docker", false)
+	suite.NoError(err)
+	suite.NoError(Persist(f, ""))
+	suite.NoError(Persist(f, "docker"))
+	suite.Equal(map[string]bool{"foo": true, "plugins.docker.foo": false}, persisted)
+	suite.EqualError(Persist(Register("bar", "", false), ""), "failed to persist bar")
+}
+
+func TestFeatures(t *testing.T) {
+	suite.Run(t, new(FeaturesTestSuite))
+}
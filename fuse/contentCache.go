@@ -0,0 +1,259 @@
+package fuse
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// contentCacheSize is the maximum size of the content cache
+var contentCacheSize = limits.Register(
+	"fuse.content_cache_mb",
+	"The maximum size (in megabytes) of the on-disk cache of the content of recently read files. Repeated reads of a file whose plugin sets validators (e.g. an ETag) are served from it for as long as the validators don't change. 0 disables the content cache.",
+	512,
+	nil,
+)
+
+// contentCacheDir is where the content cache's files are written. Each file's
+// unlinked as soon as it's mapped into memory, so the files never outlive the
+// Wash server (even if it crashes).
+var contentCacheDir = func() string {
+	cdir, err := os.UserCacheDir()
+	if err != nil {
+		cdir = os.TempDir()
+	}
+	return filepath.Join(cdir, "wash", "content")
+}()
+
+// cachedContent is a file's content, mapped into memory from the content
+// cache's file.
+type cachedContent struct {
+	id         string
+	validators plugin.Validators
+	data       []byte
+	// refs is the number of open readers, plus one while the content's
+	// cached. The content's unmapped once it drops to 0.
+	refs int
+	elem *list.Element
+}
+
+// contentCache caches the content of recently read files on disk. The cached
+// content is only served if the file's current validators (see
+// plugin.ContentValidators) match the ones it was cached with, so files whose
+// plugins don't set validators are never cached. Once the cache exceeds the
+// fuse.content_cache_mb limit, the least recently read content is evicted.
+type contentCache struct {
+	mux     sync.Mutex
+	entries map[string]*cachedContent
+	// lru's front is the most recently read content
+	lru        *list.List
+	size       int64
+	populating map[string]bool
+}
+
+func newContentCache() *contentCache {
+	return &contentCache{
+		entries:    make(map[string]*cachedContent),
+		lru:        list.New(),
+		populating: make(map[string]bool),
+	}
+}
+
+var fileContents = newContentCache()
+
+func maxContentCacheSize() int64 {
+	return int64(contentCacheSize.Value()) * 1024 * 1024
+}
+
+// lookup returns a reader of the cached content of the entry with the given
+// ID if it was cached with the given validators. Stale content is evicted.
+func (c *contentCache) lookup(id string, v plugin.Validators) (plugin.SizedReader, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.lookupLocked(id, v)
+}
+
+// lookupLocked is lookup. It must be called with c.mux held.
+func (c *contentCache) lookupLocked(id string, v plugin.Validators) (plugin.SizedReader, bool) {
+	cached, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	if cached.validators.Equal(v) && maxContentCacheSize() > 0 {
+		cached.refs++
+		c.lru.MoveToFront(cached.elem)
+		return &contentReader{cache: c, content: cached}, true
+	}
+	// The cached content is stale
+	c.evict(cached)
+	return nil, false
+}
+
+// readerFor is like lookup, except that it populates the cache in the
+// background if the content isn't cached. The caller still owns content, so it
+// should close content if it's served the cached content instead. The cache's
+// populated from a separate reader that's opened with open, since content is
+// read by the caller's handle in the meantime. Content that's fetched on
+// demand (see plugin.PartialReader) isn't cached because populating the cache
+// would read all of it.
+func (c *contentCache) readerFor(
+	ctx context.Context,
+	id string,
+	v plugin.Validators,
+	content plugin.SizedReader,
+	open func(context.Context) (plugin.SizedReader, error),
+) (plugin.SizedReader, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if cached, ok := c.lookupLocked(id, v); ok {
+		return cached, true
+	}
+
+	if pr, ok := content.(plugin.PartialReader); ok && pr.SupportsPartialReads() {
+		return nil, false
+	}
+	size := content.Size()
+	if size <= 0 || size > maxContentCacheSize() || c.populating[id] {
+		return nil, false
+	}
+	c.populating[id] = true
+	// The population outlives the request that opened the content
+	ctx = plugin.DetachedContext(ctx)
+	go func() {
+		data, err := openAndMapContent(ctx, open)
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		delete(c.populating, id)
+		if err != nil {
+			activity.Warnf(ctx, "FUSE: Could not cache the content of %v: %v", id, err)
+			return
+		}
+		c.add(&cachedContent{id: id, validators: v, data: data, refs: 1})
+		activity.Record(ctx, "FUSE: Cached %v bytes of content for %v", len(data), id)
+	}()
+	return nil, false
+}
+
+// add adds content to the cache, evicting the least recently read content
+// until it fits. It must be called with c.mux held.
+func (c *contentCache) add(content *cachedContent) {
+	if previous, ok := c.entries[content.id]; ok {
+		c.evict(previous)
+	}
+	size := int64(len(content.data))
+	for c.lru.Len() > 0 && c.size+size > maxContentCacheSize() {
+		c.evict(c.lru.Back().Value.(*cachedContent))
+	}
+	content.elem = c.lru.PushFront(content)
+	c.entries[content.id] = content
+	c.size += size
+}
+
+// evict removes content from the cache. It must be called with c.mux held.
+func (c *contentCache) evict(content *cachedContent) {
+	c.lru.Remove(content.elem)
+	delete(c.entries, content.id)
+	c.size -= int64(len(content.data))
+	c.release(content)
+}
+
+// release drops a reference to content, unmapping it if it was the last one.
+// It must be called with c.mux held.
+func (c *contentCache) release(content *cachedContent) {
+	content.refs--
+	if content.refs > 0 {
+		return
+	}
+	if err := unix.Munmap(content.data); err != nil {
+		log.Warnf("FUSE: Could not unmap the cached content of %v: %v", content.id, err)
+	}
+}
+
+// openAndMapContent opens a reader of the content with open, then maps it with
+// mapContent
+func openAndMapContent(ctx context.Context, open func(context.Context) (plugin.SizedReader, error)) ([]byte, error) {
+	content, err := open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := content.(io.Closer); ok {
+		defer closer.Close()
+	}
+	return mapContent(content)
+}
+
+// mapContent writes content to a file in the content cache's directory, then
+// maps the file into memory.
+func mapContent(content plugin.SizedReader) ([]byte, error) {
+	if err := os.MkdirAll(contentCacheDir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(contentCacheDir, "content-")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// The mapping keeps the file's data around until it's unmapped
+	if err := os.Remove(f.Name()); err != nil {
+		return nil, err
+	}
+
+	size := content.Size()
+	n, err := io.Copy(f, io.NewSectionReader(content, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	if n != size {
+		return nil, fmt.Errorf("read %v bytes of content, expected %v", n, size)
+	}
+	return unix.Mmap(int(f.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+}
+
+// contentReader reads cached content. It holds a reference to the content
+// until it's closed so that evicting the content doesn't unmap it while it's
+// still being read.
+type contentReader struct {
+	cache   *contentCache
+	content *cachedContent
+	once    sync.Once
+}
+
+func (r *contentReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %v", off)
+	}
+	data := r.content.data
+	if off >= int64(len(data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *contentReader) Size() int64 {
+	return int64(len(r.content.data))
+}
+
+// Close releases the reader's reference to the content
+func (r *contentReader) Close() error {
+	r.once.Do(func() {
+		r.cache.mux.Lock()
+		defer r.cache.mux.Unlock()
+		r.cache.release(r.content)
+	})
+	return nil
+}
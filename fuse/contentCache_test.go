@@ -0,0 +1,176 @@
+package fuse
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type ContentCacheTestSuite struct {
+	suite.Suite
+	cache       *contentCache
+	originalDir string
+}
+
+func (suite *ContentCacheTestSuite) SetupTest() {
+	suite.cache = newContentCache()
+	suite.originalDir = contentCacheDir
+	contentCacheDir = suite.T().TempDir()
+}
+
+func (suite *ContentCacheTestSuite) TearDownTest() {
+	contentCacheDir = suite.originalDir
+	_, err := limits.Set(contentCacheSize.Name(), 512)
+	suite.NoError(err)
+}
+
+// opener returns a function that opens a reader of content
+func opener(content []byte) func(context.Context) (plugin.SizedReader, error) {
+	return func(context.Context) (plugin.SizedReader, error) {
+		return bytes.NewReader(content), nil
+	}
+}
+
+// populate caches content and waits until it's cached
+func (suite *ContentCacheTestSuite) populate(id string, v plugin.Validators, content []byte) {
+	_, ok := suite.cache.readerFor(context.Background(), id, v, bytes.NewReader(content), opener(content))
+	suite.False(ok)
+	for i := 0; i < 100; i++ {
+		suite.cache.mux.Lock()
+		populating := suite.cache.populating[id]
+		suite.cache.mux.Unlock()
+		if !populating {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	suite.FailNow("the content wasn't cached in time")
+}
+
+func (suite *ContentCacheTestSuite) read(r plugin.SizedReader) string {
+	bits, err := ioutil.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+	suite.NoError(err)
+	return string(bits)
+}
+
+func (suite *ContentCacheTestSuite) TestServesFreshContent() {
+	v1 := plugin.Validators{ETag: "v1"}
+	suite.populate("/foo", v1, []byte("cached"))
+
+	r, ok := suite.cache.readerFor(context.Background(), "/foo", v1, bytes.NewReader([]byte("other")), opener(nil))
+	if suite.True(ok) {
+		suite.Equal("cached", suite.read(r))
+		suite.NoError(r.(io.Closer).Close())
+	}
+
+	// Content with different validators is stale, so it's evicted
+	v2 := plugin.Validators{ETag: "v2"}
+	suite.populate("/foo", v2, []byte("updated"))
+	r, ok = suite.cache.readerFor(context.Background(), "/foo", v2, bytes.NewReader([]byte("other")), opener(nil))
+	if suite.True(ok) {
+		suite.Equal("updated", suite.read(r))
+		suite.NoError(r.(io.Closer).Close())
+	}
+	suite.Equal(int64(len("updated")), suite.cache.size)
+}
+
+func (suite *ContentCacheTestSuite) TestLookup() {
+	_, ok := suite.cache.lookup("/foo", plugin.Validators{ETag: "v1"})
+	suite.False(ok)
+	suite.Empty(suite.cache.populating)
+
+	suite.populate("/foo", plugin.Validators{ETag: "v1"}, []byte("cached"))
+	r, ok := suite.cache.lookup("/foo", plugin.Validators{ETag: "v1"})
+	if suite.True(ok) {
+		suite.Equal("cached", suite.read(r))
+		suite.NoError(r.(io.Closer).Close())
+	}
+	_, ok = suite.cache.lookup("/foo", plugin.Validators{ETag: "v2"})
+	suite.False(ok)
+	suite.Empty(suite.cache.entries)
+}
+
+func (suite *ContentCacheTestSuite) TestEvictsTheLeastRecentlyReadContent() {
+	_, err := limits.Set(contentCacheSize.Name(), 1)
+	suite.NoError(err)
+	v := plugin.Validators{ETag: "v1"}
+	content := make([]byte, 600*1024)
+
+	suite.populate("/foo", v, content)
+	// Readers of evicted content can keep reading it
+	r, ok := suite.cache.readerFor(context.Background(), "/foo", v, bytes.NewReader(content), opener(nil))
+	suite.True(ok)
+	suite.populate("/bar", v, content)
+
+	_, ok = suite.cache.readerFor(context.Background(), "/foo", v, bytes.NewReader(nil), opener(nil))
+	suite.False(ok)
+	if r != nil {
+		suite.Equal(len(content), len(suite.read(r)))
+		suite.NoError(r.(io.Closer).Close())
+	}
+	_, ok = suite.cache.readerFor(context.Background(), "/bar", v, bytes.NewReader(nil), opener(nil))
+	suite.True(ok)
+
+	// Content that's larger than the cache isn't cached
+	_, ok = suite.cache.readerFor(context.Background(), "/baz", v, bytes.NewReader(make([]byte, 2*1024*1024)), opener(nil))
+	suite.False(ok)
+	suite.False(suite.cache.populating["/baz"])
+}
+
+func (suite *ContentCacheTestSuite) TestDisabled() {
+	_, err := limits.Set(contentCacheSize.Name(), 0)
+	suite.NoError(err)
+	_, ok := suite.cache.readerFor(context.Background(), "/foo", plugin.Validators{ETag: "v1"}, bytes.NewReader([]byte("content")), opener(nil))
+	suite.False(ok)
+	suite.Empty(suite.cache.populating)
+}
+
+type partialReader struct {
+	*bytes.Reader
+}
+
+func (partialReader) SupportsPartialReads() bool {
+	return true
+}
+
+func (suite *ContentCacheTestSuite) TestDoesntCachePartialReads() {
+	content := []byte("content")
+	_, ok := suite.cache.readerFor(context.Background(), "/foo", plugin.Validators{ETag: "v1"}, partialReader{bytes.NewReader(content)}, opener(content))
+	suite.False(ok)
+	suite.Empty(suite.cache.populating)
+}
+
+func (suite *ContentCacheTestSuite) TestPopulatesFromASeparateReader() {
+	// The handle's reader isn't read by the population
+	handleContent := &readCountingReader{Reader: bytes.NewReader([]byte("cached"))}
+	_, ok := suite.cache.readerFor(context.Background(), "/foo", plugin.Validators{ETag: "v1"}, handleContent, opener([]byte("cached")))
+	suite.False(ok)
+	suite.populate("/foo", plugin.Validators{ETag: "v1"}, []byte("cached"))
+	suite.Zero(handleContent.reads)
+	r, ok := suite.cache.lookup("/foo", plugin.Validators{ETag: "v1"})
+	if suite.True(ok) {
+		suite.Equal("cached", suite.read(r))
+		suite.NoError(r.(io.Closer).Close())
+	}
+}
+
+type readCountingReader struct {
+	*bytes.Reader
+	reads int
+}
+
+func (r *readCountingReader) ReadAt(p []byte, off int64) (int, error) {
+	r.reads++
+	return r.Reader.ReadAt(p, off)
+}
+
+func TestContentCache(t *testing.T) {
+	suite.Run(t, new(ContentCacheTestSuite))
+}
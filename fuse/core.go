@@ -3,15 +3,19 @@ package fuse
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/user"
 	"strconv"
+	"strings"
 	"time"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
 	"github.com/puppetlabs/wash/activity"
 	"github.com/puppetlabs/wash/analytics"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/metrics"
 	"github.com/puppetlabs/wash/plugin"
 	log "github.com/sirupsen/logrus"
 )
@@ -81,6 +85,9 @@ func (f *fuseNode) applyAttr(a *fuse.Attr, attr *plugin.EntryAttributes, isdir b
 	// TODO: tie this to actual hard links in plugins
 	a.Nlink = 1
 
+	owners := currentOwnership()
+	id := f.String()
+	a.Inode = inodes.of(id)
 	if attr.HasMode() {
 		a.Mode = attr.Mode()
 		// bazil/fuse appears to assume that character device implies device, and requires
@@ -88,10 +95,18 @@ func (f *fuseNode) applyAttr(a *fuse.Attr, attr *plugin.EntryAttributes, isdir b
 		if a.Mode&os.ModeCharDevice == os.ModeCharDevice {
 			a.Mode |= os.ModeDevice
 		}
+		// Some backends only report the entry's type, which would make it inaccessible
+		if a.Mode.Perm() == 0 {
+			a.Mode |= owners.defaultPerm(id, a.Mode.IsDir())
+		}
 	} else if isdir {
-		a.Mode = os.ModeDir | 0550
+		a.Mode = os.ModeDir | owners.defaultPerm(id, true)
 	} else {
-		a.Mode = 0440
+		a.Mode = owners.defaultPerm(id, false)
+		// Writable files can be written by their owner
+		if isWritable(f.entry) {
+			a.Mode |= 0200
+		}
 	}
 
 	const blockSize = 4096
@@ -120,8 +135,7 @@ func (f *fuseNode) applyAttr(a *fuse.Attr, attr *plugin.EntryAttributes, isdir b
 		a.Crtime = attr.Crtime()
 	}
 	a.BlockSize = blockSize
-	a.Uid = uid
-	a.Gid = gid
+	a.Uid, a.Gid = owners.owners(id, attr)
 }
 
 // Re-discovers the source ancestor of the current node to get fresh data. It returns that ancestor
@@ -149,6 +163,21 @@ func (f *fuseNode) refind(ctx context.Context) (plugin.Entry, error) {
 	return plugin.FindEntry(ctx, parent, segments)
 }
 
+// attrRefresherParent returns f's parent if f's attributes should be refreshed
+// instead of re-finding f. That's the case if f can refresh its attributes and
+// its parent's listing expired. Entries with prefetched ancestors are always
+// re-found since their source ancestor's listing includes them.
+func (f *fuseNode) attrRefresherParent() (plugin.Parent, bool) {
+	if !plugin.RefreshesAttributes(f.entry) {
+		return nil, false
+	}
+	parent, segments := f.getSource()
+	if parent == nil || len(segments) != 1 || plugin.IsCached(parent, plugin.ListOp) {
+		return nil, false
+	}
+	return parent, true
+}
+
 func (f *fuseNode) Attr(ctx context.Context, a *fuse.Attr) error {
 	// Attr is not a particularly interesting call and happens a lot. Log it to debug like other
 	// activity, but leave it out of activity because it introduces history entries for lots of
@@ -157,13 +186,31 @@ func (f *fuseNode) Attr(ctx context.Context, a *fuse.Attr) error {
 
 	// FUSE caches nodes for a long time, meaning there's a chance that
 	// f's attributes are outdated. 'refind' requests the entry from its
-	// parent to ensure it has updated attributes.
-	updatedEntry, err := f.refind(ctx)
-	if err != nil {
-		activity.Warnf(ctx, "FUSE: Attr errored %v, %v", f, err)
-		return err
+	// parent to ensure it has updated attributes, unless f can refresh them
+	// on its own.
+	updatedEntry := f.entry
+	var attr plugin.EntryAttributes
+	if parent, ok := f.attrRefresherParent(); ok {
+		// Refreshing the attributes is cheaper than re-listing the parent
+		refreshed, err := runInterruptible(ctx, "Attr "+f.String(), func(ctx context.Context) (interface{}, error) {
+			return plugin.CachedAttributes(ctx, parent, f.entry.(plugin.AttrRefresher))
+		})
+		if err != nil {
+			activity.Warnf(ctx, "FUSE: [%v] Attr errored %v, %v", apitypes.ErrorCodeFor(err), f, err)
+			return err
+		}
+		attr = refreshed.(plugin.EntryAttributes)
+	} else {
+		entry, err := runInterruptible(ctx, "Attr "+f.String(), func(ctx context.Context) (interface{}, error) {
+			return f.refind(ctx)
+		})
+		if err != nil {
+			activity.Warnf(ctx, "FUSE: [%v] Attr errored %v, %v", apitypes.ErrorCodeFor(err), f, err)
+			return err
+		}
+		updatedEntry = entry.(plugin.Entry)
+		attr = plugin.Attributes(updatedEntry)
 	}
-	attr := plugin.Attributes(updatedEntry)
 	// NOTE: We could set f.entry to updatedEntry, but doing so would require
 	// a separate mutex which may hinder performance. Since updating f.entry
 	// is not strictly necessary for the other FUSE operations, we choose to
@@ -174,6 +221,13 @@ func (f *fuseNode) Attr(ctx context.Context, a *fuse.Attr) error {
 	return nil
 }
 
+// opName returns the type of the FUSE request, e.g. Lookup for a
+// *fuse.LookupRequest
+func opName(req fuse.Request) string {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", req), "*fuse.")
+	return strings.TrimSuffix(name, "Request")
+}
+
 // ServeFuseFS starts serving a fuse filesystem that lists the registered plugins.
 // It returns three values:
 //   1. A channel to initiate the shutdown (stopCh).
@@ -203,6 +257,7 @@ func ServeFuseFS(
 	go func() {
 		serverConfig := &fs.Config{
 			WithContext: func(ctx context.Context, req fuse.Request) context.Context {
+				metrics.RecordFUSEOp(opName(req))
 				pid := int(req.Hdr().Pid)
 				newctx := context.WithValue(ctx, activity.JournalKey, activity.JournalForPID(pid))
 				newctx = context.WithValue(newctx, analytics.ClientKey, analyticsClient)
@@ -210,6 +265,7 @@ func ServeFuseFS(
 			},
 		}
 		server := fs.New(fuseConn, serverConfig)
+		files.serve(server)
 		root := newRoot(filesys)
 		if err := server.Serve(&root); err != nil {
 			log.Warnf("FUSE: fs.Serve errored with: %v", err)
@@ -227,6 +283,9 @@ func ServeFuseFS(
 		defer close(serverExitedCh)
 	}()
 
+	saveCtx, stopSaving := context.WithCancel(context.Background())
+	go saveInodesPeriodically(saveCtx)
+
 	// Clean-up
 	stopCh := make(chan context.Context)
 	stoppedCh := make(chan struct{})
@@ -251,6 +310,8 @@ func ServeFuseFS(
 		if err != nil {
 			log.Infof("FUSE: Error closing the connection: %v", err)
 		}
+		stopSaving()
+		saveInodes()
 		log.Infof("FUSE: Server shutdown complete")
 		close(stoppedCh)
 	}()
@@ -2,10 +2,13 @@ package fuse
 
 import (
 	"context"
+	"strings"
+	"syscall"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
 	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
 	"github.com/puppetlabs/wash/plugin"
 	log "github.com/sirupsen/logrus"
 )
@@ -19,25 +22,44 @@ type dir struct {
 var _ fs.Node = (*dir)(nil)
 var _ = fs.NodeRequestLookuper(&dir{})
 var _ = fs.HandleReadDirAller(&dir{})
+var _ = fs.NodeRemover(&dir{})
+var _ = fs.NodeCreater(&dir{})
+var _ = fs.NodeMkdirer(&dir{})
+var _ = fs.NodeRenamer(&dir{})
+var _ = fs.NodeForgetter(&dir{})
 
 func newDir(p *dir, e plugin.Parent) *dir {
-	return &dir{newFuseNode("d", p, e)}
+	d := &dir{newFuseNode("d", p, e)}
+	files.addDir(d)
+	return d
+}
+
+// Forget stops tracking the directory once the kernel's forgotten it
+func (d *dir) Forget() {
+	files.removeDir(d)
 }
 
 func (d *dir) children(ctx context.Context) (map[string]plugin.Entry, error) {
-	// Check for an updated entry in case it has static state.
-	updatedEntry, err := d.refind(ctx)
+	entries, err := runInterruptible(ctx, "List "+d.String(), func(ctx context.Context) (interface{}, error) {
+		// Check for an updated entry in case it has static state.
+		updatedEntry, err := d.refind(ctx)
+		if err != nil {
+			activity.Warnf(ctx, "FUSE: [%v] List errored %v, %v", apitypes.ErrorCodeFor(err), d, err)
+			return nil, err
+		}
+
+		// Cache List requests. FUSE often lists the contents then immediately calls find on individual entries.
+		// Giant directories are listed as partitions so that readdir remains usable.
+		if plugin.ListAction().IsSupportedOn(updatedEntry) {
+			return plugin.PartitionedList(ctx, updatedEntry.(plugin.Parent))
+		}
+
+		return nil, fuse.ENOENT
+	})
 	if err != nil {
-		activity.Warnf(ctx, "FUSE: List errored %v, %v", d, err)
 		return nil, err
 	}
-
-	// Cache List requests. FUSE often lists the contents then immediately calls find on individual entries.
-	if plugin.ListAction().IsSupportedOn(updatedEntry) {
-		return plugin.List(ctx, updatedEntry.(plugin.Parent))
-	}
-
-	return nil, fuse.ENOENT
+	return entries.(map[string]plugin.Entry), nil
 }
 
 // Lookup searches a directory for children.
@@ -45,10 +67,16 @@ func (d *dir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.Lo
 	// Find is only occasionally useful and happens a lot. Log it to debug like other activity, but
 	// leave it out of activity because it introduces history entries for miscellaneous shell commands.
 	log.Debugf("FUSE: Find %v in %v", req.Name, d)
+	if plugin.IsKnownMissing(d.entry.(plugin.Parent), req.Name) {
+		log.Debugf("FUSE: %v was recently not found in %v", req.Name, d)
+		return nil, fuse.ENOENT
+	}
 
 	entries, err := d.children(ctx)
-	if err != nil {
-		activity.Warnf(ctx, "FUSE: Find %v in %v errored: %v", req.Name, d, err)
+	if err == fuse.EINTR {
+		return nil, err
+	} else if err != nil {
+		activity.Warnf(ctx, "FUSE: [%v] Find %v in %v errored: %v", apitypes.ErrorCodeFor(err), req.Name, d, err)
 		return nil, fuse.ENOENT
 	}
 
@@ -56,9 +84,15 @@ func (d *dir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.Lo
 	entry, ok := entries[cname]
 	if !ok {
 		log.Debugf("FUSE: %v not found in %v", req.Name, d)
+		plugin.RecordMissing(d.entry.(plugin.Parent), req.Name)
 		return nil, fuse.ENOENT
 	}
 
+	if _, ok := plugin.SymlinkTargetOf(entry); ok {
+		log.Debugf("FUSE: Found symlink %v/%v", d, cname)
+		return newSymlink(d, entry), nil
+	}
+
 	if plugin.ListAction().IsSupportedOn(entry) {
 		childdir := newDir(d, entry.(plugin.Parent))
 		log.Debugf("FUSE: Found directory %v", childdir)
@@ -75,7 +109,7 @@ func (d *dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 
 	entries, err := d.children(ctx)
 	if err != nil {
-		activity.Warnf(ctx, "FUSE: List %v errored: %v", d, err)
+		activity.Warnf(ctx, "FUSE: [%v] List %v errored: %v", apitypes.ErrorCodeFor(err), d, err)
 		return nil, err
 	}
 
@@ -83,7 +117,10 @@ func (d *dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 	for cname, entry := range entries {
 		var de fuse.Dirent
 		de.Name = cname
-		if plugin.ListAction().IsSupportedOn(entry) {
+		de.Inode = inodes.of(plugin.ID(entry))
+		if _, ok := plugin.SymlinkTargetOf(entry); ok {
+			de.Type = fuse.DT_Link
+		} else if plugin.ListAction().IsSupportedOn(entry) {
 			de.Type = fuse.DT_Dir
 		}
 		res = append(res, de)
@@ -91,3 +128,187 @@ func (d *dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 	activity.Record(ctx, "FUSE: Listed in %v: %+v", d, res)
 	return res, nil
 }
+
+// writesEnabled returns true if the fuse.writes flag's enabled for the
+// directory's plugin, warning about op if it isn't
+func (d *dir) writesEnabled(ctx context.Context, op string, name string) bool {
+	if !plugin.FeatureEnabled(writesFlag, d.entry) {
+		activity.Warnf(ctx, "FUSE: %v %v in %v: the %v feature flag is disabled", op, name, d, writesFlag.Name())
+		return false
+	}
+	return true
+}
+
+// isDir returns true if the entry's represented as a directory
+func isDir(entry plugin.Entry) bool {
+	if _, ok := plugin.SymlinkTargetOf(entry); ok {
+		return false
+	}
+	return plugin.ListAction().IsSupportedOn(entry)
+}
+
+// Remove deletes a child (via `rm` or `rmdir`). Only deletable entries can be
+// removed. Like on a local filesystem, `rmdir` only removes directories and
+// `rm` only removes files.
+func (d *dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	activity.Record(ctx, "FUSE: Remove %v from %v", req.Name, d)
+	if !d.writesEnabled(ctx, "Remove", req.Name) {
+		return fuse.EPERM
+	}
+
+	entries, err := d.children(ctx)
+	if err != nil {
+		activity.Warnf(ctx, "FUSE: [%v] Remove %v from %v errored: %v", apitypes.ErrorCodeFor(err), req.Name, d, err)
+		return err
+	}
+	entry, ok := entries[req.Name]
+	if !ok {
+		return fuse.ENOENT
+	}
+	if req.Dir && !isDir(entry) {
+		return fuse.Errno(syscall.ENOTDIR)
+	} else if !req.Dir && isDir(entry) {
+		return fuse.Errno(syscall.EISDIR)
+	}
+	if !plugin.DeleteAction().IsSupportedOn(entry) {
+		activity.Warnf(ctx, "FUSE: [%v] Remove %v from %v: the delete action isn't supported", apitypes.ErrorCodeOf(apitypes.UnsupportedAction), req.Name, d)
+		return fuse.EPERM
+	}
+
+	_, err = runInterruptible(ctx, "Remove "+req.Name+" from "+d.String(), func(ctx context.Context) (interface{}, error) {
+		if err := plugin.Delete(ctx, entry.(plugin.Deletable)); err != nil {
+			activity.Warnf(ctx, "FUSE: [%v] Remove %v from %v errored: %v", apitypes.ErrorCodeFor(err), req.Name, d, err)
+			return nil, err
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+	activity.Record(ctx, "FUSE: Removed %v from %v", req.Name, d)
+	return nil
+}
+
+// Rename renames a child via the rename action (e.g. via `mv`). Children can
+// only be renamed within their directory, so moving them to another directory
+// fails with EXDEV (which makes mv copy them instead). Existing children aren't
+// replaced since plugins can't replace them atomically.
+func (d *dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	activity.Record(ctx, "FUSE: Rename %v in %v to %v", req.OldName, d, req.NewName)
+	if nd, ok := newDir.(*dir); !ok || nd.String() != d.String() {
+		activity.Warnf(ctx, "FUSE: Rename %v in %v: entries can't be moved to another directory", req.OldName, d)
+		return fuse.Errno(syscall.EXDEV)
+	}
+	if !d.writesEnabled(ctx, "Rename", req.OldName) {
+		return fuse.EPERM
+	}
+
+	entries, err := d.children(ctx)
+	if err != nil {
+		activity.Warnf(ctx, "FUSE: [%v] Rename %v in %v errored: %v", apitypes.ErrorCodeFor(err), req.OldName, d, err)
+		return err
+	}
+	entry, ok := entries[req.OldName]
+	if !ok {
+		return fuse.ENOENT
+	}
+	if req.NewName == req.OldName {
+		return nil
+	}
+	if _, ok := entries[req.NewName]; ok {
+		activity.Warnf(ctx, "FUSE: Rename %v in %v: %v already exists", req.OldName, d, req.NewName)
+		return fuse.EEXIST
+	}
+	if !plugin.RenameAction().IsSupportedOn(entry) {
+		activity.Warnf(ctx, "FUSE: [%v] Rename %v in %v: the rename action isn't supported", apitypes.ErrorCodeOf(apitypes.UnsupportedAction), req.OldName, d)
+		return fuse.EPERM
+	}
+
+	_, err = runInterruptible(ctx, "Rename "+req.OldName+" in "+d.String(), func(ctx context.Context) (interface{}, error) {
+		if err := plugin.Rename(ctx, entry.(plugin.Renamable), req.NewName); err != nil {
+			activity.Warnf(ctx, "FUSE: [%v] Rename %v in %v errored: %v", apitypes.ErrorCodeFor(err), req.OldName, d, err)
+			return nil, err
+		}
+		// The renamed file keeps its inode, like it would on a local
+		// filesystem
+		id := plugin.ID(entry)
+		inodes.move(id, strings.TrimSuffix(id, req.OldName)+req.NewName)
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+	activity.Record(ctx, "FUSE: Renamed %v in %v to %v", req.OldName, d, req.NewName)
+	return nil
+}
+
+// createChild creates a child of the directory via the create action (see
+// plugin.ChildCreator)
+func (d *dir) createChild(ctx context.Context, name string, isParent bool) (plugin.Entry, error) {
+	child, err := runInterruptible(ctx, "Create "+name+" in "+d.String(), func(ctx context.Context) (interface{}, error) {
+		updatedEntry, err := d.refind(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !plugin.CreateAction().IsSupportedOn(updatedEntry) {
+			activity.Warnf(ctx, "FUSE: [%v] Create %v in %v: the create action isn't supported", apitypes.ErrorCodeOf(apitypes.UnsupportedAction), name, d)
+			return nil, fuse.EPERM
+		}
+		return plugin.CreateChild(ctx, updatedEntry.(plugin.ChildCreator), name, isParent)
+	})
+	if err != nil {
+		if err != fuse.EPERM {
+			activity.Warnf(ctx, "FUSE: [%v] Create %v in %v errored: %v", apitypes.ErrorCodeFor(err), name, d, err)
+		}
+		return nil, err
+	}
+	activity.Record(ctx, "FUSE: Created %v in %v", name, d)
+	return child.(plugin.Entry), nil
+}
+
+// Mkdir creates a child directory via the create action
+func (d *dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	activity.Record(ctx, "FUSE: Mkdir %v in %v", req.Name, d)
+	if !d.writesEnabled(ctx, "Mkdir", req.Name) {
+		return nil, fuse.EPERM
+	}
+	child, err := d.createChild(ctx, req.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	return newDir(d, child.(plugin.Parent)), nil
+}
+
+// Create opens a child for writing (e.g. via `echo foo > file`). The kernel
+// only creates children that it couldn't look up, so the child's usually new.
+// New children are created via the create action, so they can only be created
+// in directories whose entries support it. A child that appeared since the
+// kernel's lookup is opened like an existing file.
+func (d *dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	activity.Record(ctx, "FUSE: Create %v in %v", req.Name, d)
+	if !d.writesEnabled(ctx, "Create", req.Name) {
+		return nil, nil, fuse.EPERM
+	}
+
+	entries, err := d.children(ctx)
+	if err != nil {
+		activity.Warnf(ctx, "FUSE: [%v] Create %v in %v errored: %v", apitypes.ErrorCodeFor(err), req.Name, d, err)
+		return nil, nil, err
+	}
+	entry, ok := entries[req.Name]
+	if !ok {
+		if entry, err = d.createChild(ctx, req.Name, false); err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, ok := plugin.SymlinkTargetOf(entry); ok || plugin.ListAction().IsSupportedOn(entry) {
+		return nil, nil, fuse.EEXIST
+	}
+
+	f := newFile(d, entry)
+	fh, err := f.openForWriting(ctx, req.Flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, fh, nil
+}
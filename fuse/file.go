@@ -3,78 +3,412 @@ package fuse
 import (
 	"context"
 	"io"
+	"sync"
+	"sync/atomic"
+	"syscall"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
 	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
 	"github.com/puppetlabs/wash/plugin"
+	log "github.com/sirupsen/logrus"
 )
 
 // ==== FUSE file Interface ====
 
 type file struct {
 	*fuseNode
+	// stale is set once the file's entry was removed or moved (see
+	// fileNodes.markStale)
+	stale int32
+	// writeMux guards wb, which buffers the writes of the file's handles
+	// that are open for writing
+	writeMux sync.Mutex
+	wb       *writeBuffer
 }
 
 var _ fs.Node = (*file)(nil)
 var _ = fs.NodeOpener(&file{})
+var _ = fs.NodeForgetter(&file{})
+var _ = fs.NodeSetattrer(&file{})
+var _ = fs.NodeFsyncer(&file{})
 
 func newFile(p *dir, e plugin.Entry) *file {
-	return &file{newFuseNode("f", p, e)}
+	f := &file{fuseNode: newFuseNode("f", p, e)}
+	files.add(f)
+	return f
 }
 
-// Open a file for reading.
+// errStale is returned for stale files. Their entry no longer exists at their
+// path, so their content can't be served.
+var errStale = fuse.Errno(syscall.ESTALE)
+
+func (f *file) markStale() {
+	atomic.StoreInt32(&f.stale, 1)
+}
+
+func (f *file) isStale() bool {
+	return atomic.LoadInt32(&f.stale) == 1
+}
+
+// Forget stops tracking the file once the kernel's forgotten it
+func (f *file) Forget() {
+	files.remove(f)
+}
+
+// Open a file. Files that are opened for writing buffer their writes until
+// they're flushed (see openForWriting).
 func (f *file) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	if !req.Flags.IsReadOnly() {
+		return f.openForWriting(ctx, req.Flags)
+	}
 	activity.Record(ctx, "FUSE: Open %v", f)
+	if f.isStale() {
+		activity.Warnf(ctx, "FUSE: Open %v errored: the entry was removed or moved", f)
+		return nil, errStale
+	}
+
+	content, err := runInterruptible(ctx, "Open "+f.String(), func(ctx context.Context) (interface{}, error) {
+		// Check for an updated entry in case it has static state.
+		updatedEntry, err := f.refind(ctx)
+		if err != nil {
+			activity.Warnf(ctx, "FUSE: [%v] Open errored %v, %v", apitypes.ErrorCodeFor(err), f, err)
+			return nil, err
+		}
+
+		// Initiate content request and return a channel providing the results.
+		if plugin.ReadAction().IsSupportedOn(updatedEntry) {
+			id := plugin.ID(updatedEntry)
+			// The validators of content that's cached in memory are current,
+			// so the entry doesn't need to be opened if the content cache has
+			// the same content
+			if plugin.IsCached(updatedEntry, plugin.OpenOp) {
+				if v, ok := plugin.ContentValidators(updatedEntry); ok {
+					if cached, ok := fileContents.lookup(id, v); ok {
+						activity.Record(ctx, "FUSE: Serving %v from the content cache", f)
+						return cached, nil
+					}
+				}
+			}
 
-	// Check for an updated entry in case it has static state.
-	updatedEntry, err := f.refind(ctx)
+			content, err := plugin.Open(ctx, updatedEntry.(plugin.Readable))
+			if err != nil {
+				activity.Warnf(ctx, "FUSE: [%v] Open %v errored: %v", apitypes.ErrorCodeFor(err), f, err)
+				return nil, err
+			}
+			// Serve the content from the content cache if it's still fresh.
+			// plugin.Open is cheap in that case since the plugin only had to
+			// revalidate the content.
+			if v, ok := plugin.ContentValidators(updatedEntry); ok {
+				open := func(ctx context.Context) (plugin.SizedReader, error) {
+					return plugin.Open(ctx, updatedEntry.(plugin.Readable))
+				}
+				if cached, ok := fileContents.readerFor(ctx, id, v, content, open); ok {
+					activity.Record(ctx, "FUSE: Serving %v from the content cache", f)
+					if closer, ok := content.(io.Closer); ok {
+						if err := closer.Close(); err != nil {
+							activity.Warnf(ctx, "FUSE: Could not close the unused content of %v: %v", f, err)
+						}
+					}
+					return cached, nil
+				}
+			}
+			return content, nil
+		}
+		activity.Record(ctx, "FUSE: Open unsupported on %v", f)
+		return nil, fuse.ENOTSUP
+	})
 	if err != nil {
-		activity.Warnf(ctx, "FUSE: Open errored %v, %v", f, err)
 		return nil, err
 	}
 
-	// Initiate content request and return a channel providing the results.
-	if plugin.ReadAction().IsSupportedOn(updatedEntry) {
-		content, err := plugin.Open(ctx, updatedEntry.(plugin.Readable))
+	activity.Record(ctx, "FUSE: Opened %v", f)
+	return &fileHandle{r: newReaderFor(content.(plugin.SizedReader)), id: f.String(), f: f}, nil
+}
+
+// openForWriting opens the file for writing. Plugins can only replace an
+// entry's entire content (see plugin.Writable), so the handle's writes are
+// buffered until it's flushed (e.g. closed), at which point the entry's content
+// is replaced with the buffer's.
+func (f *file) openForWriting(ctx context.Context, flags fuse.OpenFlags) (fs.Handle, error) {
+	activity.Record(ctx, "FUSE: Open %v for writing", f)
+	if f.isStale() {
+		activity.Warnf(ctx, "FUSE: Open %v errored: the entry was removed or moved", f)
+		return nil, errStale
+	}
+
+	entry, err := runInterruptible(ctx, "Open "+f.String(), func(ctx context.Context) (interface{}, error) {
+		return f.refind(ctx)
+	})
+	if err != nil {
+		activity.Warnf(ctx, "FUSE: [%v] Open errored %v, %v", apitypes.ErrorCodeFor(err), f, err)
+		return nil, err
+	}
+	updatedEntry := entry.(plugin.Entry)
+	if !plugin.WriteAction().IsSupportedOn(updatedEntry) {
+		activity.Warnf(ctx, "FUSE: [%v] Open %v for writing: the write action isn't supported", apitypes.ErrorCodeOf(apitypes.UnsupportedAction), f)
+		return nil, fuse.EPERM
+	}
+	if !isWritable(updatedEntry) {
+		activity.Warnf(ctx, "FUSE: Open %v for writing: the %v feature flag is disabled", f, writesFlag.Name())
+		return nil, fuse.EPERM
+	}
+
+	fh := &fileHandle{id: f.String(), f: f, wb: f.acquireWriteBuffer(updatedEntry.(plugin.Writable))}
+	if flags&fuse.OpenTruncate != 0 {
+		// Truncating to 0 bytes doesn't load the content, so it can't fail
+		_ = fh.wb.truncate(ctx, 0)
+	}
+	activity.Record(ctx, "FUSE: Opened %v for writing", f)
+	return fh, nil
+}
+
+// acquireWriteBuffer returns the file's write buffer. It's created if none of
+// the file's handles are open for writing.
+func (f *file) acquireWriteBuffer(w plugin.Writable) *writeBuffer {
+	f.writeMux.Lock()
+	defer f.writeMux.Unlock()
+	if f.wb == nil {
+		f.wb = newWriteBuffer(w)
+	}
+	f.wb.handles++
+	return f.wb
+}
+
+// releaseWriteBuffer releases a handle's write buffer. The buffer's discarded
+// once all of the handles that share it are released.
+func (f *file) releaseWriteBuffer(wb *writeBuffer) {
+	f.writeMux.Lock()
+	defer f.writeMux.Unlock()
+	wb.handles--
+	if wb.handles == 0 && f.wb == wb {
+		f.wb = nil
+	}
+}
+
+// writeBuffer returns the file's write buffer. It's nil if none of the file's
+// handles are open for writing.
+func (f *file) writeBuffer() *writeBuffer {
+	f.writeMux.Lock()
+	defer f.writeMux.Unlock()
+	return f.wb
+}
+
+// Attr reports the size of the file's buffered writes (if any) so that writes
+// that grow the file are seen before they're flushed.
+func (f *file) Attr(ctx context.Context, a *fuse.Attr) error {
+	if err := f.fuseNode.Attr(ctx, a); err != nil {
+		return err
+	}
+	if wb := f.writeBuffer(); wb != nil {
+		if size, ok := wb.size(); ok {
+			a.Size = size
+		}
+	}
+	return nil
+}
+
+// Setattr truncates the file if its size is set (e.g. via truncate(2)). The
+// other attributes can't be set, so they're ignored; that way, commands like
+// touch don't fail.
+func (f *file) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if req.Valid.Size() {
+		activity.Record(ctx, "FUSE: Truncate %v to %v bytes", f, req.Size)
+		if err := f.truncate(ctx, int64(req.Size)); err != nil {
+			activity.Warnf(ctx, "FUSE: [%v] Truncate %v errored: %v", apitypes.ErrorCodeFor(err), f, err)
+			return err
+		}
+	}
+	return f.Attr(ctx, &resp.Attr)
+}
+
+// truncate truncates the file's buffered writes if it's open for writing.
+// Otherwise, the entry's content is replaced with its truncated content.
+func (f *file) truncate(ctx context.Context, size int64) error {
+	if f.isStale() {
+		return errStale
+	}
+	if wb := f.writeBuffer(); wb != nil {
+		_, err := runInterruptible(ctx, "Truncate "+f.String(), func(ctx context.Context) (interface{}, error) {
+			return nil, wb.truncate(ctx, size)
+		})
+		return err
+	}
+	_, err := runInterruptible(ctx, "Truncate "+f.String(), func(ctx context.Context) (interface{}, error) {
+		updatedEntry, err := f.refind(ctx)
 		if err != nil {
-			activity.Warnf(ctx, "FUSE: Open %v errored: %v", f, err)
 			return nil, err
 		}
+		if !isWritable(updatedEntry) {
+			return nil, fuse.EPERM
+		}
+		wb := newWriteBuffer(updatedEntry.(plugin.Writable))
+		if err := wb.truncate(ctx, size); err != nil {
+			return nil, err
+		}
+		return nil, wb.flush(ctx)
+	})
+	return err
+}
 
-		activity.Record(ctx, "FUSE: Opened %v", f)
-		return &fileHandle{r: content, id: f.String()}, nil
+// Fsync commits the file's buffered writes
+func (f *file) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	wb := f.writeBuffer()
+	if wb == nil {
+		return nil
 	}
-	activity.Record(ctx, "FUSE: Open unsupported on %v", f)
-	return nil, fuse.ENOTSUP
+	activity.Record(ctx, "FUSE: Fsync %v", f)
+	_, err := runInterruptible(ctx, "Fsync "+f.String(), func(ctx context.Context) (interface{}, error) {
+		return nil, wb.flush(ctx)
+	})
+	if err != nil {
+		activity.Warnf(ctx, "FUSE: [%v] Fsync %v errored: %v", apitypes.ErrorCodeFor(err), f, err)
+	}
+	return err
 }
 
 type fileHandle struct {
 	r  io.ReaderAt
 	id string
+	f  *file
+	// wb buffers the handle's writes if it's open for writing, in which
+	// case the handle's reads are served from it instead of r
+	wb *writeBuffer
+	// interruptedRead is the last read that was interrupted by the kernel.
+	// Reads are idempotent, so it's reused if the same read is retried.
+	mux             sync.Mutex
+	interruptedRead *pendingRead
 }
 
 var _ fs.Handle = (*fileHandle)(nil)
-var _ = fs.HandleReleaser(fileHandle{})
-var _ = fs.HandleReader(fileHandle{})
+var _ = fs.HandleReleaser(&fileHandle{})
+var _ = fs.HandleReader(&fileHandle{})
+var _ = fs.HandleWriter(&fileHandle{})
+var _ = fs.HandleFlusher(&fileHandle{})
 
 // Release closes the open file.
-func (fh fileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+func (fh *fileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
 	activity.Record(ctx, "FUSE: Release %v", fh.id)
+	if fh.wb != nil {
+		// The kernel flushes the handle before releasing it
+		fh.f.releaseWriteBuffer(fh.wb)
+		return nil
+	}
 	if closer, ok := fh.r.(io.Closer); ok {
 		return closer.Close()
 	}
 	return nil
 }
 
+// pendingRead represents a (possibly in-flight) ReadAt call.
+type pendingRead struct {
+	offset int64
+	size   int
+	doneCh chan struct{}
+	data   []byte
+	err    error
+}
+
+func (fh *fileHandle) startRead(offset int64, size int) *pendingRead {
+	pr := &pendingRead{
+		offset: offset,
+		size:   size,
+		doneCh: make(chan struct{}),
+	}
+	go func() {
+		defer maxRequests.Release()
+		buf := make([]byte, size)
+		n, err := fh.r.ReadAt(buf, offset)
+		if err == io.EOF {
+			err = nil
+		}
+		pr.data, pr.err = buf[:n], err
+		close(pr.doneCh)
+	}()
+	return pr
+}
+
 // Read fills a buffer with the requested amount of data from the file.
-func (fh fileHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
-	buf := make([]byte, req.Size)
-	n, err := fh.r.ReadAt(buf, req.Offset)
-	if err == io.EOF {
-		err = nil
-	}
-	activity.Record(ctx, "FUSE: Read %v/%v bytes starting at %v from %v: %v", n, req.Size, req.Offset, fh.id, err)
-	resp.Data = buf[:n]
+func (fh *fileHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	if fh.f.isStale() {
+		activity.Warnf(ctx, "FUSE: Read from %v errored: the entry was removed or moved", fh.id)
+		return errStale
+	}
+	if fh.wb != nil {
+		data, err := runInterruptible(ctx, "Read "+fh.id, func(ctx context.Context) (interface{}, error) {
+			return fh.wb.readAt(ctx, req.Size, req.Offset)
+		})
+		if err != nil {
+			return err
+		}
+		log.Debugf("FUSE: Read %v/%v buffered bytes starting at %v from %v", len(data.([]byte)), req.Size, req.Offset, fh.id)
+		resp.Data = data.([]byte)
+		return nil
+	}
+
+	fh.mux.Lock()
+	pr := fh.interruptedRead
+	fh.interruptedRead = nil
+	fh.mux.Unlock()
+
+	if pr != nil && pr.offset == req.Offset && pr.size == req.Size {
+		activity.Record(ctx, "FUSE: Reusing interrupted read of %v bytes starting at %v from %v", req.Size, req.Offset, fh.id)
+	} else {
+		if err := maxRequests.Acquire(ctx); err != nil {
+			return fuse.EINTR
+		}
+		pr = fh.startRead(req.Offset, req.Size)
+	}
+
+	select {
+	case <-pr.doneCh:
+		activity.Record(ctx, "FUSE: Read %v/%v bytes starting at %v from %v: %v", len(pr.data), req.Size, req.Offset, fh.id, pr.err)
+		resp.Data = pr.data
+		return pr.err
+	case <-ctx.Done():
+		activity.Record(ctx, "FUSE: Read of %v bytes starting at %v from %v interrupted", req.Size, req.Offset, fh.id)
+		fh.mux.Lock()
+		fh.interruptedRead = pr
+		fh.mux.Unlock()
+		return fuse.EINTR
+	}
+}
+
+// Write buffers the data until the handle's flushed
+func (fh *fileHandle) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	if fh.f.isStale() {
+		activity.Warnf(ctx, "FUSE: Write to %v errored: the entry was removed or moved", fh.id)
+		return errStale
+	}
+	if fh.wb == nil {
+		return fuse.Errno(syscall.EBADF)
+	}
+	_, err := runInterruptible(ctx, "Write "+fh.id, func(ctx context.Context) (interface{}, error) {
+		return nil, fh.wb.writeAt(ctx, req.Data, req.Offset)
+	})
+	if err != nil {
+		activity.Warnf(ctx, "FUSE: [%v] Write of %v bytes starting at %v to %v errored: %v", apitypes.ErrorCodeFor(err), len(req.Data), req.Offset, fh.id, err)
+		return err
+	}
+	log.Debugf("FUSE: Buffered %v bytes starting at %v for %v", len(req.Data), req.Offset, fh.id)
+	resp.Size = len(req.Data)
+	return nil
+}
+
+// Flush commits the handle's buffered writes by replacing the entry's content
+// with them. It's called each time one of the handle's file descriptors is
+// closed.
+func (fh *fileHandle) Flush(ctx context.Context, req *fuse.FlushRequest) error {
+	if fh.wb == nil {
+		return nil
+	}
+	activity.Record(ctx, "FUSE: Flush %v", fh.id)
+	_, err := runInterruptible(ctx, "Flush "+fh.id, func(ctx context.Context) (interface{}, error) {
+		return nil, fh.wb.flush(ctx)
+	})
+	if err != nil {
+		activity.Warnf(ctx, "FUSE: [%v] Flush %v errored: %v", apitypes.ErrorCodeFor(err), fh.id, err)
+	}
 	return err
 }
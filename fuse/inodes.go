@@ -0,0 +1,268 @@
+package fuse
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxInodes is the maximum size of the inode table
+var maxInodes = limits.Register(
+	"fuse.max_inodes",
+	"The maximum number of paths whose inodes are remembered. Once it's exceeded, the least recently used path's inode is forgotten, so the path gets a new inode the next time that it's used. 0 disables the limit.",
+	1000000,
+	nil,
+)
+
+// inodeSaveInterval is how often a persisted inode table's saved while the
+// filesystem's mounted, so that a crashed server doesn't lose it
+var inodeSaveInterval = 5 * time.Minute
+
+// inodesFile is where the inode table's saved if it's persisted (see
+// ConfigureInodes)
+var inodesFile = func() string {
+	cdir, err := os.UserCacheDir()
+	if err != nil {
+		cdir = os.TempDir()
+	}
+	return filepath.Join(cdir, "wash", "inodes.json")
+}()
+
+// rootInode is the inode of the filesystem's root, which is also its FUSE node
+// ID
+const rootInode = 1
+
+// inodeTable allocates the files' inodes. Each inode's keyed by its entry's
+// path, so a file keeps its inode when its node's forgotten by the kernel, when
+// its entry's evicted from the cache and when its entry's re-created (e.g. a
+// container that's replaced with one of the same name). That way, tools that
+// track files by their inode (e.g. tail -F and editors) keep working. Inodes
+// are never reused, and renamed or moved entries keep theirs. The table's
+// bounded by the fuse.max_inodes limit. The root's inode is never forgotten.
+type inodeTable struct {
+	mux    sync.Mutex
+	byPath map[string]*list.Element
+	// lru's front is the most recently used path
+	lru  *list.List
+	next uint64
+	// persist is true if the table's saved to inodesFile while the filesystem's
+	// mounted. dirty is true if it's changed since it was last saved.
+	persist bool
+	dirty   bool
+}
+
+// inodeRecord is a path's inode
+type inodeRecord struct {
+	path  string
+	inode uint64
+}
+
+func newInodeTable() *inodeTable {
+	return &inodeTable{
+		byPath: make(map[string]*list.Element),
+		lru:    list.New(),
+		next:   rootInode + 1,
+	}
+}
+
+var inodes = newInodeTable()
+
+func init() {
+	plugin.OnChildChange(inodes.onChildChange)
+}
+
+// of returns the inode of the entry with the given ID, allocating it if the
+// entry doesn't have one yet
+func (t *inodeTable) of(id string) uint64 {
+	if id == "/" {
+		return rootInode
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if elem, ok := t.byPath[id]; ok {
+		t.lru.MoveToFront(elem)
+		return elem.Value.(*inodeRecord).inode
+	}
+	inode := t.next
+	t.next++
+	t.setLocked(id, inode)
+	return inode
+}
+
+// setLocked sets path's inode, and forgets the least recently used paths'
+// inodes if the table's full. t.mux must be held.
+func (t *inodeTable) setLocked(path string, inode uint64) {
+	t.deleteLocked(path)
+	t.byPath[path] = t.lru.PushFront(&inodeRecord{path: path, inode: inode})
+	if max := maxInodes.Value(); max > 0 {
+		for t.lru.Len() > max {
+			t.deleteLocked(t.lru.Back().Value.(*inodeRecord).path)
+		}
+	}
+	t.dirty = true
+}
+
+// deleteLocked forgets path's inode. t.mux must be held.
+func (t *inodeTable) deleteLocked(path string) {
+	if elem, ok := t.byPath[path]; ok {
+		t.lru.Remove(elem)
+		delete(t.byPath, path)
+		t.dirty = true
+	}
+}
+
+// move moves the inodes of the entry with the given ID (and of its
+// descendants) to newID. They replace the inodes of the paths that they're
+// moved to since those paths are now the moved entries'.
+func (t *inodeTable) move(id string, newID string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	prefix := strings.TrimRight(id, "/") + "/"
+	moved := make(map[string]uint64)
+	for path, elem := range t.byPath {
+		var newPath string
+		if path == id {
+			newPath = newID
+		} else if strings.HasPrefix(path, prefix) {
+			newPath = strings.TrimRight(newID, "/") + "/" + strings.TrimPrefix(path, prefix)
+		} else {
+			continue
+		}
+		moved[newPath] = elem.Value.(*inodeRecord).inode
+		t.deleteLocked(path)
+	}
+	for newPath, inode := range moved {
+		t.setLocked(newPath, inode)
+	}
+}
+
+// onChildChange moves the inodes of moved children. Removed children keep
+// theirs in case they're re-created.
+func (t *inodeTable) onChildChange(change plugin.ChildChange) {
+	if change.Kind == plugin.ChildMoved {
+		t.move(change.ID, change.NewID)
+	}
+}
+
+// savedInodes is the inode table's on-disk format
+type savedInodes struct {
+	Next   uint64            `json:"next"`
+	Inodes map[string]uint64 `json:"inodes"`
+}
+
+// load replaces the table with the one that was saved to path. A missing file
+// is an empty table.
+func (t *inodeTable) load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not read the inode table: %v", err)
+	}
+	var saved savedInodes
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("could not decode the inode table: %v", err)
+	}
+	for path, inode := range saved.Inodes {
+		if path == "/" {
+			continue
+		}
+		if inode <= rootInode || inode >= saved.Next {
+			return fmt.Errorf("the inode table's inode %v of %v is invalid", inode, path)
+		}
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.byPath, t.lru, t.next = make(map[string]*list.Element), list.New(), saved.Next
+	for path, inode := range saved.Inodes {
+		if path != "/" {
+			t.setLocked(path, inode)
+		}
+	}
+	t.dirty = false
+	return nil
+}
+
+// save saves the table to path
+func (t *inodeTable) save(path string) error {
+	t.mux.Lock()
+	saved := savedInodes{Next: t.next, Inodes: make(map[string]uint64, len(t.byPath))}
+	for path, elem := range t.byPath {
+		saved.Inodes[path] = elem.Value.(*inodeRecord).inode
+	}
+	t.dirty = false
+	t.mux.Unlock()
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("could not encode the inode table: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("could not create the inode table's directory: %v", err)
+	}
+	// Write to a temporary file first so that the next server never reads a
+	// partially written table
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("could not save the inode table: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("could not save the inode table: %v", err)
+	}
+	return nil
+}
+
+// ConfigureInodes configures whether the files' inodes persist across
+// restarts. If they do, the inode table's loaded from the previous server's
+// table, and it's saved periodically while the filesystem's mounted and when
+// it's unmounted. A table that can't be loaded is replaced.
+func ConfigureInodes(persist bool) {
+	inodes.mux.Lock()
+	inodes.persist = persist
+	inodes.mux.Unlock()
+	if !persist {
+		return
+	}
+	if err := inodes.load(inodesFile); err != nil {
+		log.Warnf("FUSE: Starting with new inodes: %v", err)
+	}
+}
+
+// saveInodes saves the inode table if it's persisted and it's changed since
+// it was last saved
+func saveInodes() {
+	inodes.mux.Lock()
+	save := inodes.persist && inodes.dirty
+	inodes.mux.Unlock()
+	if !save {
+		return
+	}
+	if err := inodes.save(inodesFile); err != nil {
+		log.Warnf("FUSE: %v", err)
+	}
+}
+
+// saveInodesPeriodically saves the inode table every inodeSaveInterval until
+// ctx is cancelled
+func saveInodesPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(inodeSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			saveInodes()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package fuse
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type InodesTestSuite struct {
+	suite.Suite
+}
+
+func (suite *InodesTestSuite) TestOf() {
+	t := newInodeTable()
+	suite.Equal(uint64(rootInode), t.of("/"))
+	foo := t.of("/foo")
+	suite.NotEqual(uint64(rootInode), foo)
+	suite.Equal(foo, t.of("/foo"))
+	suite.NotEqual(foo, t.of("/bar"))
+}
+
+func (suite *InodesTestSuite) TestMove() {
+	t := newInodeTable()
+	dir, file, other := t.of("/a"), t.of("/a/file"), t.of("/aa")
+	t.move("/a", "/b")
+	suite.Equal(dir, t.of("/b"))
+	suite.Equal(file, t.of("/b/file"))
+	suite.Equal(other, t.of("/aa"))
+	// The old paths get new inodes if they're re-created
+	suite.NotEqual(dir, t.of("/a"))
+
+	// The moved entries replace the inodes of the paths that they're moved to
+	existing := t.of("/c")
+	t.move("/b", "/c")
+	suite.Equal(dir, t.of("/c"))
+	suite.Equal(file, t.of("/c/file"))
+	suite.NotEqual(existing, t.of("/c"))
+
+	t.onChildChange(plugin.ChildChange{Kind: plugin.ChildRemoved, ID: "/c"})
+	suite.Equal(dir, t.of("/c"))
+	t.onChildChange(plugin.ChildChange{Kind: plugin.ChildMoved, ID: "/c", NewID: "/d"})
+	suite.Equal(dir, t.of("/d"))
+}
+
+func (suite *InodesTestSuite) TestOf_ForgetsTheLeastRecentlyUsedPaths() {
+	_, err := limits.Set(maxInodes.Name(), 2)
+	suite.NoError(err)
+	defer func() {
+		_, err := limits.Set(maxInodes.Name(), 1000000)
+		suite.NoError(err)
+	}()
+
+	t := newInodeTable()
+	foo, bar := t.of("/foo"), t.of("/bar")
+	suite.Equal(foo, t.of("/foo"))
+	t.of("/baz")
+	suite.Equal(foo, t.of("/foo"))
+	// /bar was forgotten, so it gets a new inode
+	suite.NotEqual(bar, t.of("/bar"))
+	suite.Equal(2, t.lru.Len())
+	// The root's never forgotten
+	suite.Equal(uint64(rootInode), t.of("/"))
+}
+
+func (suite *InodesTestSuite) TestSaveAndLoad() {
+	path := filepath.Join(suite.T().TempDir(), "inodes.json")
+	t := newInodeTable()
+	foo := t.of("/foo")
+	suite.NoError(t.save(path))
+	suite.False(t.dirty)
+
+	loaded := newInodeTable()
+	suite.NoError(loaded.load(path))
+	suite.Equal(foo, loaded.of("/foo"))
+	suite.Equal(uint64(rootInode), loaded.of("/"))
+	// New inodes don't reuse the saved ones
+	suite.Equal(t.of("/bar"), loaded.of("/bar"))
+
+	// A missing table is an empty table
+	suite.NoError(newInodeTable().load(filepath.Join(filepath.Dir(path), "missing.json")))
+
+	suite.NoError(ioutil.WriteFile(path, []byte(`{"next":3,"inodes":{"/foo":5}}`), 0600))
+	suite.Regexp("inode 5 of /foo is invalid", newInodeTable().load(path))
+	suite.NoError(ioutil.WriteFile(path, []byte("bad"), 0600))
+	suite.Regexp("could not decode the inode table", newInodeTable().load(path))
+}
+
+func TestInodes(t *testing.T) {
+	suite.Run(t, new(InodesTestSuite))
+}
@@ -0,0 +1,59 @@
+package fuse
+
+import (
+	"context"
+	"time"
+
+	"bazil.org/fuse"
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// maxRequests limits the number of FUSE requests that are processed concurrently
+var maxRequests = limits.NewSemaphore(
+	"fuse.max_requests",
+	"The maximum number of FUSE requests that are processed concurrently. 0 means unlimited.",
+	0,
+)
+
+// interruptedOpTimeout is how long an interrupted op is allowed to keep running
+// in the background before it is cancelled.
+var interruptedOpTimeout = 30 * time.Second
+
+type interruptibleOp func(context.Context) (interface{}, error)
+
+// runInterruptible runs op in the background with a context that has ctx's values
+// but not its cancellation. If the kernel interrupts the FUSE request (which
+// cancels ctx), then runInterruptible returns fuse.EINTR without waiting for op
+// to finish. The op is left running (up to interruptedOpTimeout) so that it can
+// finish and cache its result instead of leaving the plugin's work half-done. An
+// immediately retried request will then reuse that result, either by waiting on
+// the in-flight op or by hitting the cache.
+func runInterruptible(ctx context.Context, desc string, op interruptibleOp) (interface{}, error) {
+	if err := maxRequests.Acquire(ctx); err != nil {
+		return nil, fuse.EINTR
+	}
+	opCtx, cancel := context.WithCancel(plugin.DetachedContext(ctx))
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		defer maxRequests.Release()
+		defer cancel()
+		value, err := op(opCtx)
+		resultCh <- result{value, err}
+	}()
+
+	select {
+	case r := <-resultCh:
+		return r.value, r.err
+	case <-ctx.Done():
+		activity.Record(ctx, "FUSE: %v interrupted, finishing it in the background", desc)
+		time.AfterFunc(interruptedOpTimeout, cancel)
+		return nil, fuse.EINTR
+	}
+}
@@ -0,0 +1,185 @@
+package fuse
+
+import (
+	"path"
+	"strings"
+	"sync"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/puppetlabs/wash/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// fileNodes tracks the file and directory nodes that the kernel knows about
+// so that their cached attributes and content can be invalidated once their
+// entry's content changes. That way, tools that keep a file open or re-stat it
+// (e.g. editors and tail -F) see the new content. A directory's content is its
+// listing. The file nodes of removed or moved entries are marked stale instead
+// so that their open files fail with ESTALE, and the kernel's cached entries
+// for them are invalidated in their parent directories.
+type fileNodes struct {
+	mux    sync.Mutex
+	server *fs.Server
+	byID   map[string]map[*file]struct{}
+	dirs   map[string]map[*dir]struct{}
+}
+
+var files = &fileNodes{
+	byID: make(map[string]map[*file]struct{}),
+	dirs: make(map[string]map[*dir]struct{}),
+}
+
+func init() {
+	plugin.OnContentChange(files.invalidate)
+	plugin.OnChildChange(files.markStale)
+}
+
+// serve sets the server whose kernel caches are invalidated
+func (n *fileNodes) serve(server *fs.Server) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.server = server
+}
+
+func (n *fileNodes) add(f *file) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	id := f.String()
+	if n.byID[id] == nil {
+		n.byID[id] = make(map[*file]struct{})
+	}
+	n.byID[id][f] = struct{}{}
+}
+
+func (n *fileNodes) remove(f *file) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	id := f.String()
+	delete(n.byID[id], f)
+	if len(n.byID[id]) == 0 {
+		delete(n.byID, id)
+	}
+}
+
+func (n *fileNodes) addDir(d *dir) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	id := d.String()
+	if n.dirs[id] == nil {
+		n.dirs[id] = make(map[*dir]struct{})
+	}
+	n.dirs[id][d] = struct{}{}
+}
+
+func (n *fileNodes) removeDir(d *dir) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	id := d.String()
+	delete(n.dirs[id], d)
+	if len(n.dirs[id]) == 0 {
+		delete(n.dirs, id)
+	}
+}
+
+// nodesOf returns the nodes of the entry with the given ID
+func (n *fileNodes) nodesOf(id string) []fs.Node {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	nodes := make([]fs.Node, 0, len(n.byID[id])+len(n.dirs[id]))
+	for f := range n.byID[id] {
+		nodes = append(nodes, f)
+	}
+	for d := range n.dirs[id] {
+		nodes = append(nodes, d)
+	}
+	return nodes
+}
+
+func (n *fileNodes) getServer() *fs.Server {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	return n.server
+}
+
+// invalidate invalidates the kernel's cached attributes and content of the
+// nodes of the entry with the given ID
+func (n *fileNodes) invalidate(id string) {
+	server := n.getServer()
+	if server == nil {
+		return
+	}
+	for _, node := range n.nodesOf(id) {
+		log.Debugf("FUSE: Invalidating %v because its content changed", node)
+		invalidateNodeData(server, node)
+	}
+}
+
+func invalidateNodeData(server *fs.Server, node fs.Node) {
+	if err := server.InvalidateNodeData(node); err != nil && err != fuse.ErrNotCached {
+		log.Warnf("FUSE: Could not invalidate %v: %v", node, err)
+	}
+}
+
+// invalidateEntry invalidates the kernel's cached entry for the child with the
+// given name in each of the parent's directory nodes, so that the child's
+// looked up again
+func (n *fileNodes) invalidateEntry(server *fs.Server, parentID string, name string) {
+	for _, node := range n.nodesOf(parentID) {
+		d, ok := node.(*dir)
+		if !ok {
+			continue
+		}
+		if err := server.InvalidateEntry(d, name); err != nil && err != fuse.ErrNotCached {
+			log.Warnf("FUSE: Could not invalidate the entry of %v in %v: %v", name, d, err)
+		}
+	}
+}
+
+// markStale marks the file nodes of the changed child (and of its descendants)
+// as stale, and invalidates the kernel's cached data of the child's nodes and
+// of its parent's directory nodes. The kernel's cached entries for the child
+// (and for its new name if it moved) are invalidated too so that the child's
+// path is looked up again.
+func (n *fileNodes) markStale(change plugin.ChildChange) {
+	prefix := strings.TrimRight(change.ID, "/") + "/"
+	n.mux.Lock()
+	server := n.server
+	var nodes []fs.Node
+	for id, byID := range n.byID {
+		if id != change.ID && !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		for f := range byID {
+			nodes = append(nodes, f)
+		}
+	}
+	for id, byID := range n.dirs {
+		if id != change.ID && !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		for d := range byID {
+			nodes = append(nodes, d)
+		}
+	}
+	n.mux.Unlock()
+	for _, node := range nodes {
+		if f, ok := node.(*file); ok {
+			log.Debugf("FUSE: Marking %v as stale because it was %v", f, change.Kind)
+			f.markStale()
+		}
+		if server != nil {
+			invalidateNodeData(server, node)
+		}
+	}
+	if server == nil {
+		return
+	}
+	for _, parent := range n.nodesOf(change.ParentID) {
+		invalidateNodeData(server, parent)
+	}
+	n.invalidateEntry(server, change.ParentID, path.Base(change.ID))
+	if change.NewID != "" {
+		n.invalidateEntry(server, path.Dir(change.NewID), path.Base(change.NewID))
+	}
+}
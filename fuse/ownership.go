@@ -0,0 +1,226 @@
+package fuse
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// Ownership configures how entries' owner and group attributes, which are
+// names in the plugin's backend, are mapped to the local users and groups
+// that own their files. Users and groups can be specified by name or by ID.
+type Ownership struct {
+	// User and Group own the entries that aren't mapped by a rule. They
+	// default to the user running the Wash server and its primary group.
+	User  string
+	Group string
+	// Plugins contains each plugin's mapping rules, keyed by the plugin's
+	// name.
+	Plugins map[string]OwnershipRules
+}
+
+// OwnershipRules are a plugin's ownership mapping rules. A "*" key in Users
+// or Groups matches any owner or group that isn't mapped by another key. The
+// keys match owners and groups case-insensitively since the config's keys are
+// lowercased when they're read.
+type OwnershipRules struct {
+	// User and Group override Ownership's User and Group for the plugin's
+	// entries.
+	User   string
+	Group  string
+	Users  map[string]string
+	Groups map[string]string
+	// DirMode and FileMode are the permissions of the plugin's directories
+	// and files that don't have any. They default to 0550 and 0440.
+	DirMode  uint32 `mapstructure:"dir_mode"`
+	FileMode uint32 `mapstructure:"file_mode"`
+}
+
+const (
+	defaultDirMode  = 0550
+	defaultFileMode = 0440
+)
+
+type resolvedOwnershipRules struct {
+	uid, gid          uint32
+	users, groups     map[string]uint32
+	dirMode, fileMode os.FileMode
+}
+
+// ownershipMapper is a resolved Ownership
+type ownershipMapper struct {
+	defaults resolvedOwnershipRules
+	plugins  map[string]resolvedOwnershipRules
+}
+
+var ownershipMux sync.RWMutex
+var ownership = &ownershipMapper{
+	defaults: resolvedOwnershipRules{
+		uid:      uid,
+		gid:      gid,
+		dirMode:  defaultDirMode,
+		fileMode: defaultFileMode,
+	},
+}
+
+// ConfigureOwnership configures the ownership of the FUSE filesystem's files.
+// It returns an error if any of the users or groups don't exist.
+func ConfigureOwnership(o Ownership) error {
+	mapper, err := newOwnershipMapper(o)
+	if err != nil {
+		return err
+	}
+	ownershipMux.Lock()
+	defer ownershipMux.Unlock()
+	ownership = mapper
+	return nil
+}
+
+func currentOwnership() *ownershipMapper {
+	ownershipMux.RLock()
+	defer ownershipMux.RUnlock()
+	return ownership
+}
+
+func newOwnershipMapper(o Ownership) (*ownershipMapper, error) {
+	defaults := resolvedOwnershipRules{
+		uid:      uid,
+		gid:      gid,
+		dirMode:  defaultDirMode,
+		fileMode: defaultFileMode,
+	}
+	var err error
+	if o.User != "" {
+		if defaults.uid, err = lookupUID(o.User); err != nil {
+			return nil, err
+		}
+	}
+	if o.Group != "" {
+		if defaults.gid, err = lookupGID(o.Group); err != nil {
+			return nil, err
+		}
+	}
+
+	mapper := &ownershipMapper{defaults: defaults, plugins: make(map[string]resolvedOwnershipRules)}
+	for name, rules := range o.Plugins {
+		resolved, err := resolveOwnershipRules(rules, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ownership rules for the %v plugin: %v", name, err)
+		}
+		mapper.plugins[name] = resolved
+	}
+	return mapper, nil
+}
+
+func resolveOwnershipRules(rules OwnershipRules, defaults resolvedOwnershipRules) (resolvedOwnershipRules, error) {
+	resolved := defaults
+	resolved.users = make(map[string]uint32)
+	resolved.groups = make(map[string]uint32)
+	var err error
+	if rules.User != "" {
+		if resolved.uid, err = lookupUID(rules.User); err != nil {
+			return resolved, err
+		}
+	}
+	if rules.Group != "" {
+		if resolved.gid, err = lookupGID(rules.Group); err != nil {
+			return resolved, err
+		}
+	}
+	for owner, localUser := range rules.Users {
+		if resolved.users[strings.ToLower(owner)], err = lookupUID(localUser); err != nil {
+			return resolved, err
+		}
+	}
+	for group, localGroup := range rules.Groups {
+		if resolved.groups[strings.ToLower(group)], err = lookupGID(localGroup); err != nil {
+			return resolved, err
+		}
+	}
+	if rules.DirMode != 0 {
+		resolved.dirMode = os.FileMode(rules.DirMode).Perm()
+	}
+	if rules.FileMode != 0 {
+		resolved.fileMode = os.FileMode(rules.FileMode).Perm()
+	}
+	return resolved, nil
+}
+
+func lookupUID(name string) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse the uid of user %v: %v", name, err)
+	}
+	return uint32(id), nil
+}
+
+func lookupGID(name string) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse the gid of group %v: %v", name, err)
+	}
+	return uint32(id), nil
+}
+
+// rulesFor returns the rules for the plugin that contains the entry with the
+// given ID
+func (m *ownershipMapper) rulesFor(id string) resolvedOwnershipRules {
+	pluginName := strings.SplitN(strings.TrimPrefix(id, "/"), "/", 2)[0]
+	if rules, ok := m.plugins[pluginName]; ok {
+		return rules
+	}
+	return m.defaults
+}
+
+// owners returns the uid and gid of the entry with the given ID and attributes
+func (m *ownershipMapper) owners(id string, attr *plugin.EntryAttributes) (uint32, uint32) {
+	rules := m.rulesFor(id)
+	uid, gid := rules.uid, rules.gid
+	if mapped, ok := lookupRule(rules.users, attr.Owner()); ok {
+		uid = mapped
+	}
+	if mapped, ok := lookupRule(rules.groups, attr.Group()); ok {
+		gid = mapped
+	}
+	return uid, gid
+}
+
+func lookupRule(rules map[string]uint32, name string) (uint32, bool) {
+	if name == "" {
+		return 0, false
+	}
+	if id, ok := rules[strings.ToLower(name)]; ok {
+		return id, true
+	}
+	id, ok := rules["*"]
+	return id, ok
+}
+
+// defaultPerm returns the permissions of the entry with the given ID if it
+// doesn't have any
+func (m *ownershipMapper) defaultPerm(id string, isdir bool) os.FileMode {
+	rules := m.rulesFor(id)
+	if isdir {
+		return rules.dirMode
+	}
+	return rules.fileMode
+}
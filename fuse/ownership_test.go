@@ -0,0 +1,89 @@
+package fuse
+
+import (
+	"os"
+	"testing"
+
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type OwnershipTestSuite struct {
+	suite.Suite
+}
+
+func (suite *OwnershipTestSuite) TestDefaults() {
+	mapper, err := newOwnershipMapper(Ownership{})
+	if !suite.NoError(err) {
+		return
+	}
+	attr := plugin.EntryAttributes{}
+	attr.SetOwner("ubuntu")
+	actualUID, actualGID := mapper.owners("/docker/containers/foo", &attr)
+	suite.Equal(uid, actualUID)
+	suite.Equal(gid, actualGID)
+	suite.Equal(os.FileMode(0550), mapper.defaultPerm("/docker", true))
+	suite.Equal(os.FileMode(0440), mapper.defaultPerm("/docker/containers/foo/log", false))
+}
+
+func (suite *OwnershipTestSuite) TestPluginRules() {
+	mapper, err := newOwnershipMapper(Ownership{
+		User:  "1000",
+		Group: "1000",
+		Plugins: map[string]OwnershipRules{
+			"aws": {
+				Group:    "2000",
+				Users:    map[string]string{"ec2-user": "1001", "*": "1002"},
+				Groups:   map[string]string{"Admins": "3000"},
+				DirMode:  0750,
+				FileMode: 0640,
+			},
+		},
+	})
+	if !suite.NoError(err) {
+		return
+	}
+
+	attr := plugin.EntryAttributes{}
+	uid, gid := mapper.owners("/aws/profile/resources", &attr)
+	suite.Equal(uint32(1000), uid)
+	suite.Equal(uint32(2000), gid)
+
+	attr.SetOwner("ec2-user").SetGroup("admins")
+	uid, gid = mapper.owners("/aws/profile/resources", &attr)
+	suite.Equal(uint32(1001), uid)
+	suite.Equal(uint32(3000), gid)
+
+	// Owners and groups are matched case-insensitively
+	attr.SetOwner("EC2-User").SetGroup("ADMINS")
+	uid, gid = mapper.owners("/aws/profile/resources", &attr)
+	suite.Equal(uint32(1001), uid)
+	suite.Equal(uint32(3000), gid)
+
+	attr.SetOwner("someone-else").SetGroup("developers")
+	uid, gid = mapper.owners("/aws/profile/resources", &attr)
+	suite.Equal(uint32(1002), uid)
+	suite.Equal(uint32(2000), gid)
+	suite.Equal(os.FileMode(0750), mapper.defaultPerm("/aws", true))
+	suite.Equal(os.FileMode(0640), mapper.defaultPerm("/aws/profile/file", false))
+
+	// Other plugins use the defaults
+	uid, gid = mapper.owners("/docker/containers/foo", &attr)
+	suite.Equal(uint32(1000), uid)
+	suite.Equal(uint32(1000), gid)
+	suite.Equal(os.FileMode(0550), mapper.defaultPerm("/docker", true))
+}
+
+func (suite *OwnershipTestSuite) TestUnknownUser() {
+	_, err := newOwnershipMapper(Ownership{
+		Plugins: map[string]OwnershipRules{
+			"aws": {Users: map[string]string{"ec2-user": "no-such-wash-user"}},
+		},
+	})
+	suite.Error(err)
+	suite.Contains(err.Error(), "aws")
+}
+
+func TestOwnership(t *testing.T) {
+	suite.Run(t, new(OwnershipTestSuite))
+}
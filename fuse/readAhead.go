@@ -0,0 +1,168 @@
+package fuse
+
+import (
+	"io"
+	"sync"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// readAheadWindowSize is the amount of content fetched by each read-ahead
+// request. It's larger than the kernel's read size so that sequential reads
+// are served with fewer requests to the plugin's API.
+var readAheadWindowSize int64 = 1024 * 1024
+
+// readAheadDepth is the number of read-ahead windows that are fetched ahead of
+// the window that's being read
+var readAheadDepth = limits.Register(
+	"fuse.read_ahead_depth",
+	"The number of 1MiB read-ahead windows that are fetched ahead of sequential reads of large files. 0 disables read-ahead.",
+	1,
+	nil,
+)
+
+// sequentialReadThreshold is the number of consecutive sequential reads
+// after which read-ahead kicks in.
+const sequentialReadThreshold = 2
+
+// readAheadWindow represents a (possibly in-flight) read-ahead request.
+type readAheadWindow struct {
+	offset int64
+	doneCh chan struct{}
+	data   []byte
+	err    error
+}
+
+func (w *readAheadWindow) contains(off int64) bool {
+	return w.offset <= off && off < w.offset+readAheadWindowSize
+}
+
+// readAheadReader wraps a partial reader. Once it detects sequential reads, it
+// serves them from read-ahead windows. It keeps the window after the one that's
+// being read in-flight so that it's ready by the time the reader gets to it.
+// Non-sequential reads are passed through to the partial reader.
+type readAheadReader struct {
+	plugin.SizedReader
+	mux             sync.Mutex
+	nextOffset      int64
+	sequentialReads int
+	windows         []*readAheadWindow
+}
+
+// newReaderFor returns a read-ahead reader if r supports partial reads.
+// Otherwise, it returns r.
+func newReaderFor(r plugin.SizedReader) plugin.SizedReader {
+	if pr, ok := r.(plugin.PartialReader); ok && pr.SupportsPartialReads() {
+		return &readAheadReader{SizedReader: r}
+	}
+	return r
+}
+
+// Close closes the partial reader if it's an io.Closer
+func (r *readAheadReader) Close() error {
+	if closer, ok := r.SizedReader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (r *readAheadReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mux.Lock()
+	if off == r.nextOffset {
+		r.sequentialReads++
+	} else {
+		r.sequentialReads = 0
+		r.windows = nil
+	}
+	r.nextOffset = off + int64(len(p))
+	readAhead := r.sequentialReads >= sequentialReadThreshold && readAheadDepth.Value() > 0
+	r.mux.Unlock()
+
+	if !readAhead {
+		return r.SizedReader.ReadAt(p, off)
+	}
+
+	n := 0
+	for n < len(p) {
+		w := r.windowFor(off + int64(n))
+		<-w.doneCh
+		start := off + int64(n) - w.offset
+		if start >= int64(len(w.data)) {
+			// The window was cut short by an error or by the end of the file
+			if w.err != nil {
+				return n, w.err
+			}
+			return n, io.EOF
+		}
+		n += copy(p[n:], w.data[start:])
+	}
+	return n, nil
+}
+
+// windowFor returns the read-ahead window containing off, fetching it if
+// needed. It also fetches the following windows (up to the read-ahead depth)
+// if they aren't already in-flight.
+func (r *readAheadReader) windowFor(off int64) *readAheadWindow {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var w *readAheadWindow
+	windows := r.windows[:0]
+	for _, win := range r.windows {
+		if win.offset+readAheadWindowSize <= off {
+			// We've read past this window, so discard it
+			continue
+		}
+		windows = append(windows, win)
+		if win.contains(off) {
+			w = win
+		}
+	}
+	if w == nil {
+		w = r.fetch(off)
+		windows = append(windows, w)
+	}
+	depth := int64(readAheadDepth.Value())
+	for i := int64(1); i <= depth; i++ {
+		nextOffset := w.offset + i*readAheadWindowSize
+		if nextOffset >= r.Size() {
+			break
+		}
+		if !hasWindowAt(windows, nextOffset) {
+			windows = append(windows, r.fetch(nextOffset))
+		}
+	}
+	r.windows = windows
+	return w
+}
+
+func hasWindowAt(windows []*readAheadWindow, off int64) bool {
+	for _, w := range windows {
+		if w.offset == off {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *readAheadReader) fetch(off int64) *readAheadWindow {
+	w := &readAheadWindow{
+		offset: off,
+		doneCh: make(chan struct{}),
+	}
+	go func() {
+		defer close(w.doneCh)
+		size := readAheadWindowSize
+		if remaining := r.Size() - off; remaining > 0 && remaining < size {
+			size = remaining
+		}
+		buf := make([]byte, size)
+		n, err := r.SizedReader.ReadAt(buf, off)
+		if err == io.EOF {
+			err = nil
+		}
+		w.data, w.err = buf[:n], err
+	}()
+	return w
+}
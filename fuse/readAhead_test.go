@@ -0,0 +1,121 @@
+package fuse
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type mockPartialReader struct {
+	*bytes.Reader
+	mux   sync.Mutex
+	reads []int64
+}
+
+func newMockPartialReader(size int) *mockPartialReader {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 256)
+	}
+	return &mockPartialReader{Reader: bytes.NewReader(content)}
+}
+
+func (r *mockPartialReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mux.Lock()
+	r.reads = append(r.reads, off)
+	r.mux.Unlock()
+	return r.Reader.ReadAt(p, off)
+}
+
+func (r *mockPartialReader) SupportsPartialReads() bool {
+	return true
+}
+
+func (r *mockPartialReader) numReads() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return len(r.reads)
+}
+
+type ReadAheadTestSuite struct {
+	suite.Suite
+	originalWindowSize int64
+}
+
+func (suite *ReadAheadTestSuite) SetupSuite() {
+	suite.originalWindowSize = readAheadWindowSize
+	readAheadWindowSize = 16
+}
+
+func (suite *ReadAheadTestSuite) TearDownSuite() {
+	readAheadWindowSize = suite.originalWindowSize
+}
+
+func (suite *ReadAheadTestSuite) TestNewReaderFor() {
+	r := bytes.NewReader([]byte("foo"))
+	suite.Equal(r, newReaderFor(r))
+
+	pr := newMockPartialReader(10)
+	suite.IsType(&readAheadReader{}, newReaderFor(pr))
+}
+
+func (suite *ReadAheadTestSuite) TestReadAt_SequentialReads() {
+	pr := newMockPartialReader(100)
+	r := newReaderFor(pr)
+
+	// Read the whole file sequentially, in chunks of 4 bytes. This should
+	// produce the same content as the underlying reader.
+	var content []byte
+	buf := make([]byte, 4)
+	for off := int64(0); ; off += 4 {
+		n, err := r.ReadAt(buf, off)
+		content = append(content, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if !suite.NoError(err) {
+			return
+		}
+	}
+	expected := make([]byte, 100)
+	_, _ = pr.Reader.ReadAt(expected, 0)
+	suite.Equal(expected, content)
+
+	// The first read's passed through. The remaining reads are served from
+	// 16-byte windows, so there should be far fewer than 25 reads.
+	suite.True(pr.numReads() < 25/2, "expected read-ahead to reduce the number of reads, got %v", pr.numReads())
+}
+
+func (suite *ReadAheadTestSuite) TestReadAt_NonSequentialReadsArePassedThrough() {
+	pr := newMockPartialReader(100)
+	r := newReaderFor(pr)
+
+	buf := make([]byte, 4)
+	for _, off := range []int64{40, 0, 80, 20} {
+		_, err := r.ReadAt(buf, off)
+		suite.NoError(err)
+	}
+	suite.Equal([]int64{40, 0, 80, 20}, pr.reads)
+}
+
+func (suite *ReadAheadTestSuite) TestReadAt_ReadSpansWindows() {
+	pr := newMockPartialReader(100)
+	r := newReaderFor(pr)
+
+	buf := make([]byte, 10)
+	for off := int64(0); off < 30; off += 10 {
+		n, err := r.ReadAt(buf, off)
+		if suite.NoError(err) && suite.Equal(10, n) {
+			for i := range buf {
+				suite.Equal(byte(int(off)+i), buf[i])
+			}
+		}
+	}
+}
+
+func TestReadAhead(t *testing.T) {
+	suite.Run(t, new(ReadAheadTestSuite))
+}
@@ -0,0 +1,58 @@
+package fuse
+
+import (
+	"context"
+	"os"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// ==== FUSE symlink Interface ====
+
+type symlink struct {
+	*fuseNode
+}
+
+var _ fs.Node = (*symlink)(nil)
+var _ = fs.NodeReadlinker(&symlink{})
+
+func newSymlink(p *dir, e plugin.Entry) *symlink {
+	return &symlink{newFuseNode("l", p, e)}
+}
+
+// Attr returns the symlink's attributes. Symlinks' permissions are ignored,
+// so the mode's always 0777 like it is for real symlinks.
+func (s *symlink) Attr(ctx context.Context, a *fuse.Attr) error {
+	if err := s.fuseNode.Attr(ctx, a); err != nil {
+		return err
+	}
+	target, _ := plugin.SymlinkTargetOf(s.entry)
+	a.Mode = os.ModeSymlink | 0777
+	a.Size = uint64(len(target))
+	return nil
+}
+
+// Readlink returns the symlink's target
+func (s *symlink) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
+	activity.Record(ctx, "FUSE: Readlink %v", s)
+
+	entry, err := runInterruptible(ctx, "Readlink "+s.String(), func(ctx context.Context) (interface{}, error) {
+		// Check for an updated entry in case its target changed
+		return s.refind(ctx)
+	})
+	if err != nil {
+		activity.Warnf(ctx, "FUSE: [%v] Readlink errored %v, %v", apitypes.ErrorCodeFor(err), s, err)
+		return "", err
+	}
+	target, ok := plugin.SymlinkTargetOf(entry.(plugin.Entry))
+	if !ok {
+		activity.Warnf(ctx, "FUSE: Readlink %v: the entry is no longer a symlink", s)
+		return "", fuse.ENOENT
+	}
+	activity.Record(ctx, "FUSE: Readlink %v: %v", s, target)
+	return target, nil
+}
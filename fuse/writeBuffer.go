@@ -0,0 +1,202 @@
+package fuse
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"syscall"
+
+	"bazil.org/fuse"
+	"github.com/puppetlabs/wash/features"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxWriteSize is the maximum size of a file's buffered writes
+var maxWriteSize = limits.Register(
+	"fuse.max_write_mb",
+	"The maximum size (in megabytes) of a file's content when it's written via the FUSE filesystem. The writes are buffered in memory until the file's flushed (e.g. closed), at which point its content is replaced with the buffer's. 0 means unlimited.",
+	64,
+	nil,
+)
+
+// writesFlag gates writing files via the FUSE filesystem
+var writesFlag = features.Register(
+	"fuse.writes",
+	"Allows the files of entries that support the write action to be written (and created and truncated) via the FUSE filesystem, and entries to be created, removed and renamed via it. Their writes are buffered until the file's flushed, at which point the entry's content is replaced with the buffer's.",
+	false,
+)
+
+// isWritable returns true if the entry supports the write action and the
+// fuse.writes flag's enabled for its plugin
+func isWritable(e plugin.Entry) bool {
+	return plugin.WriteAction().IsSupportedOn(e) && plugin.FeatureEnabled(writesFlag, e)
+}
+
+// errFileTooLarge is returned for writes that exceed the fuse.max_write_mb
+// limit
+var errFileTooLarge = fuse.Errno(syscall.EFBIG)
+
+// writeBuffer buffers the writes to a file until they're flushed, at which
+// point the file's entire content is replaced with the buffer's (see
+// plugin.Writable). Since plugins can only replace the content, the buffer
+// starts with the file's current content unless the file's truncated first
+// (e.g. by `echo foo > file`). It's shared by the file's open handles so that
+// they see each other's writes, like they would on a local filesystem.
+type writeBuffer struct {
+	mux sync.Mutex
+	w   plugin.Writable
+	// load returns the file's current content
+	load func(ctx context.Context) ([]byte, error)
+	data []byte
+	// loaded is true once data has the file's content
+	loaded bool
+	// dirty is true if data has writes that weren't flushed
+	dirty bool
+	// handles is the number of the file's open handles that share the
+	// buffer. It's guarded by the file's writeMux.
+	handles int
+}
+
+func newWriteBuffer(w plugin.Writable) *writeBuffer {
+	return &writeBuffer{w: w, load: func(ctx context.Context) ([]byte, error) {
+		return loadContent(ctx, w)
+	}}
+}
+
+// loadContent returns the entry's current content. Entries that can't be read
+// are empty.
+func loadContent(ctx context.Context, e plugin.Entry) ([]byte, error) {
+	if !plugin.ReadAction().IsSupportedOn(e) {
+		return nil, nil
+	}
+	content, err := plugin.Open(ctx, e.(plugin.Readable))
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := content.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				log.Debugf("FUSE: Could not close the content of %v: %v", plugin.ID(e), err)
+			}
+		}()
+	}
+	return ioutil.ReadAll(io.NewSectionReader(content, 0, content.Size()))
+}
+
+// ensureLoaded loads the file's content if it isn't already loaded. It must
+// be called with wb.mux held.
+func (wb *writeBuffer) ensureLoaded(ctx context.Context) error {
+	if wb.loaded {
+		return nil
+	}
+	data, err := wb.load(ctx)
+	if err != nil {
+		return err
+	}
+	wb.data, wb.loaded = data, true
+	return nil
+}
+
+// resize grows (by zero-filling) or shrinks data to size. It must be called
+// with wb.mux held.
+func (wb *writeBuffer) resize(size int64) error {
+	if max := int64(maxWriteSize.Value()) * 1024 * 1024; max > 0 && size > max {
+		return errFileTooLarge
+	}
+	if size <= int64(len(wb.data)) {
+		wb.data = wb.data[:size]
+		return nil
+	}
+	if size <= int64(cap(wb.data)) {
+		tail := wb.data[len(wb.data):size]
+		for i := range tail {
+			tail[i] = 0
+		}
+		wb.data = wb.data[:size]
+		return nil
+	}
+	grown := make([]byte, size, 2*size)
+	copy(grown, wb.data)
+	wb.data = grown
+	return nil
+}
+
+// writeAt writes p to the buffer at off
+func (wb *writeBuffer) writeAt(ctx context.Context, p []byte, off int64) error {
+	wb.mux.Lock()
+	defer wb.mux.Unlock()
+	if err := wb.ensureLoaded(ctx); err != nil {
+		return err
+	}
+	if end := off + int64(len(p)); end > int64(len(wb.data)) {
+		if err := wb.resize(end); err != nil {
+			return err
+		}
+	}
+	copy(wb.data[off:], p)
+	wb.dirty = true
+	return nil
+}
+
+// readAt reads up to size bytes of the buffer starting at off
+func (wb *writeBuffer) readAt(ctx context.Context, size int, off int64) ([]byte, error) {
+	wb.mux.Lock()
+	defer wb.mux.Unlock()
+	if err := wb.ensureLoaded(ctx); err != nil {
+		return nil, err
+	}
+	if off >= int64(len(wb.data)) {
+		return nil, nil
+	}
+	end := off + int64(size)
+	if end > int64(len(wb.data)) {
+		end = int64(len(wb.data))
+	}
+	data := make([]byte, end-off)
+	copy(data, wb.data[off:end])
+	return data, nil
+}
+
+// truncate resizes the buffer to size. Truncating the file to 0 bytes doesn't
+// load its content.
+func (wb *writeBuffer) truncate(ctx context.Context, size int64) error {
+	wb.mux.Lock()
+	defer wb.mux.Unlock()
+	if size == 0 {
+		wb.data, wb.loaded = nil, true
+	} else if err := wb.ensureLoaded(ctx); err != nil {
+		return err
+	} else if err := wb.resize(size); err != nil {
+		return err
+	}
+	wb.dirty = true
+	return nil
+}
+
+// size returns the size of the buffered content. The returned bool is false
+// if the content wasn't loaded, in which case the file's size is its entry's.
+func (wb *writeBuffer) size() (uint64, bool) {
+	wb.mux.Lock()
+	defer wb.mux.Unlock()
+	return uint64(len(wb.data)), wb.loaded
+}
+
+// flush replaces the entry's content with the buffer's if it has writes that
+// weren't flushed
+func (wb *writeBuffer) flush(ctx context.Context) error {
+	wb.mux.Lock()
+	defer wb.mux.Unlock()
+	if !wb.dirty {
+		return nil
+	}
+	data := make([]byte, len(wb.data))
+	copy(data, wb.data)
+	if err := plugin.Write(ctx, wb.w, data); err != nil {
+		return err
+	}
+	wb.dirty = false
+	return nil
+}
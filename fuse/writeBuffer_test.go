@@ -0,0 +1,150 @@
+package fuse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type writeTestsEntry struct {
+	plugin.EntryBase
+	content []byte
+	writes  int
+	err     error
+}
+
+func (e *writeTestsEntry) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+func (e *writeTestsEntry) Open(ctx context.Context) (plugin.SizedReader, error) {
+	return bytes.NewReader(e.content), nil
+}
+
+func (e *writeTestsEntry) Write(ctx context.Context, data []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.content = data
+	e.writes++
+	return nil
+}
+
+func newWriteTestsEntry(content string) *writeTestsEntry {
+	e := &writeTestsEntry{EntryBase: plugin.NewEntry("foo"), content: []byte(content)}
+	e.SetTestID("/write/foo")
+	return e
+}
+
+type WriteBufferTestSuite struct {
+	suite.Suite
+}
+
+func (suite *WriteBufferTestSuite) SetupTest() {
+	plugin.SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *WriteBufferTestSuite) TearDownTest() {
+	plugin.UnsetTestCache()
+	_, err := limits.Set(maxWriteSize.Name(), 64)
+	suite.NoError(err)
+}
+
+func (suite *WriteBufferTestSuite) readAll(wb *writeBuffer) string {
+	data, err := wb.readAt(context.Background(), 1024, 0)
+	suite.NoError(err)
+	return string(data)
+}
+
+func (suite *WriteBufferTestSuite) TestWritesOverTheContent() {
+	ctx := context.Background()
+	e := newWriteTestsEntry("hello world")
+	wb := newWriteBuffer(e)
+
+	suite.NoError(wb.writeAt(ctx, []byte("HELLO"), 0))
+	suite.Equal("HELLO world", suite.readAll(wb))
+	// Writes past the end zero-fill the gap
+	suite.NoError(wb.writeAt(ctx, []byte("!"), 12))
+	suite.Equal("HELLO world\x00!", suite.readAll(wb))
+	size, ok := wb.size()
+	suite.True(ok)
+	suite.Equal(uint64(13), size)
+
+	// The writes are only committed once they're flushed
+	suite.Equal("hello world", string(e.content))
+	suite.NoError(wb.flush(ctx))
+	suite.Equal("HELLO world\x00!", string(e.content))
+	suite.NoError(wb.flush(ctx))
+	suite.Equal(1, e.writes)
+}
+
+func (suite *WriteBufferTestSuite) TestTruncate() {
+	ctx := context.Background()
+	e := newWriteTestsEntry("hello world")
+	wb := newWriteBuffer(e)
+	wb.load = func(context.Context) ([]byte, error) {
+		return nil, errors.New("the content shouldn't be loaded")
+	}
+	_, ok := wb.size()
+	suite.False(ok)
+
+	// Truncating to 0 bytes replaces the content instead of loading it
+	suite.NoError(wb.truncate(ctx, 0))
+	suite.NoError(wb.writeAt(ctx, []byte("foo\n"), 0))
+	suite.NoError(wb.flush(ctx))
+	suite.Equal("foo\n", string(e.content))
+
+	wb = newWriteBuffer(e)
+	suite.NoError(wb.truncate(ctx, 2))
+	suite.Equal("fo", suite.readAll(wb))
+	suite.NoError(wb.truncate(ctx, 4))
+	suite.Equal("fo\x00\x00", suite.readAll(wb))
+	suite.NoError(wb.flush(ctx))
+	suite.Equal("fo\x00\x00", string(e.content))
+}
+
+func (suite *WriteBufferTestSuite) TestFailedFlushesAreRetried() {
+	ctx := context.Background()
+	e := newWriteTestsEntry("")
+	e.err = errors.New("the file is read-only")
+	wb := newWriteBuffer(e)
+	suite.NoError(wb.writeAt(ctx, []byte("foo"), 0))
+	suite.EqualError(wb.flush(ctx), "the file is read-only")
+
+	e.err = nil
+	suite.NoError(wb.flush(ctx))
+	suite.Equal("foo", string(e.content))
+}
+
+func (suite *WriteBufferTestSuite) TestMaxWriteSize() {
+	_, err := limits.Set(maxWriteSize.Name(), 1)
+	suite.NoError(err)
+	wb := newWriteBuffer(newWriteTestsEntry(""))
+	suite.Equal(errFileTooLarge, wb.writeAt(context.Background(), []byte("foo"), 1024*1024))
+	suite.Equal(errFileTooLarge, wb.truncate(context.Background(), 1024*1024+1))
+	suite.NoError(wb.truncate(context.Background(), 1024*1024))
+}
+
+func (suite *WriteBufferTestSuite) TestFileHandlesShareTheBuffer() {
+	e := newWriteTestsEntry("hello")
+	f := &file{fuseNode: newFuseNode("f", nil, e)}
+	first := f.acquireWriteBuffer(e)
+	second := f.acquireWriteBuffer(e)
+	suite.True(first == second)
+
+	f.releaseWriteBuffer(first)
+	suite.True(f.writeBuffer() == second)
+	f.releaseWriteBuffer(second)
+	suite.Nil(f.writeBuffer())
+	suite.False(f.acquireWriteBuffer(e) == first)
+}
+
+func TestWriteBuffer(t *testing.T) {
+	suite.Run(t, new(WriteBufferTestSuite))
+}
@@ -0,0 +1,214 @@
+package fuse
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// lifecycleXattr is the extended attribute that holds the entry's lifecycle
+// state (see plugin.Lifecycle), e.g. getfattr -n user.wash.lifecycle <path>
+const lifecycleXattr = "user.wash.lifecycle"
+
+// metaXattrPrefix prefixes the names of the extended attributes that hold the
+// entry's flattened metadata. Nested keys are joined with a '.', and array
+// elements are keyed by their index, e.g. user.wash.meta.State.Name or
+// user.wash.meta.Tags.0.Key.
+const metaXattrPrefix = "user.wash.meta."
+
+// maxXattrNameSize is the maximum size of an extended attribute's name. Longer
+// metadata keys are skipped.
+const maxXattrNameSize = 255
+
+// maxXattrListSize is the maximum size of an entry's list of extended
+// attributes (the kernel's XATTR_LIST_MAX). Each name takes its length plus a
+// NUL.
+const maxXattrListSize = 64 * 1024
+
+// userXattrPrefix prefixes the names of the entry's own extended attributes
+// (see plugin.EntryAttributes#Xattrs), which are in the user namespace
+const userXattrPrefix = "user."
+
+var _ = fs.NodeGetxattrer(&fuseNode{})
+var _ = fs.NodeListxattrer(&fuseNode{})
+
+// Getxattr gets the entry's extended attributes
+func (f *fuseNode) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	// Skip the other namespaces (e.g. security.selinux) since the entry
+	// can't have them
+	if !strings.HasPrefix(req.Name, userXattrPrefix) {
+		return fuse.ErrNoXattr
+	}
+	log.Debugf("FUSE: Getxattr %v %v", f, req.Name)
+
+	entry, err := f.xattrEntry(ctx, "Getxattr")
+	if err != nil {
+		return err
+	}
+	var xattrs map[string]string
+	if strings.HasPrefix(req.Name, metaXattrPrefix) {
+		meta, err := runInterruptible(ctx, "Getxattr "+f.String(), func(ctx context.Context) (interface{}, error) {
+			return plugin.CachedMetadata(ctx, entry)
+		})
+		if err != nil {
+			activity.Warnf(ctx, "FUSE: [%v] Getxattr errored %v, %v", apitypes.ErrorCodeFor(err), f, err)
+			return err
+		}
+		xattrs = metaXattrsOf(meta.(plugin.JSONObject))
+	} else {
+		xattrs = xattrsOf(plugin.Attributes(entry))
+	}
+	value, ok := xattrs[req.Name]
+	if !ok {
+		return fuse.ErrNoXattr
+	}
+	resp.Xattr = []byte(value)
+	return nil
+}
+
+// Listxattr lists the entry's extended attributes. Tools like ls list them for
+// each file, so the entry's metadata isn't fetched; its metadata xattrs are
+// from its cached metadata, or from its partial metadata (see
+// plugin.EntryAttributes#Meta) if its metadata isn't cached. The list's
+// truncated to maxXattrListSize (see xattrNames) since the kernel rejects
+// longer lists.
+func (f *fuseNode) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
+	log.Debugf("FUSE: Listxattr %v", f)
+
+	entry, err := f.xattrEntry(ctx, "Listxattr")
+	if err != nil {
+		return err
+	}
+	attr := plugin.Attributes(entry)
+	meta := plugin.NormalizeMetadata(entry, attr.Meta())
+	if plugin.IsCached(entry, plugin.MetadataOp) {
+		// The metadata's cached, so this doesn't invoke the plugin
+		if cached, err := plugin.CachedMetadata(ctx, entry); err == nil {
+			meta = cached
+		}
+	}
+	names := xattrNames(xattrsOf(attr), metaXattrsOf(meta))
+	resp.Append(names...)
+	return nil
+}
+
+// xattrNames returns the sorted names of the entry's xattrs and of its
+// metadata xattrs. If their list is bigger than maxXattrListSize, then the last
+// metadata xattrs are left out since they're the least useful. They can still
+// be read by name.
+func xattrNames(xattrs map[string]string, metaXattrs map[string]string) []string {
+	var names []string
+	size := 0
+	add := func(xattrs map[string]string) bool {
+		sorted := make([]string, 0, len(xattrs))
+		for name := range xattrs {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			if size+len(name)+1 > maxXattrListSize {
+				return false
+			}
+			size += len(name) + 1
+			names = append(names, name)
+		}
+		return true
+	}
+	if add(xattrs) {
+		for name := range xattrs {
+			// The entry's xattrs take precedence over its metadata's
+			delete(metaXattrs, name)
+		}
+		if !add(metaXattrs) {
+			log.Debugf("FUSE: Truncated the list of %v metadata xattrs to %v bytes", len(metaXattrs), maxXattrListSize)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// xattrsOf returns the entry's extended attributes, keyed by their FUSE
+// names. The lifecycle xattr takes precedence over an entry's xattr of the
+// same name.
+func xattrsOf(attr plugin.EntryAttributes) map[string]string {
+	xattrs := make(map[string]string, len(attr.Xattrs())+1)
+	for name, value := range attr.Xattrs() {
+		xattrs[userXattrPrefix+name] = value
+	}
+	if attr.HasLifecycle() {
+		xattrs[lifecycleXattr] = string(attr.Lifecycle())
+	}
+	return xattrs
+}
+
+// metaXattrsOf returns the extended attributes of the entry's flattened
+// metadata, keyed by their FUSE names. String values are the string itself;
+// other values are JSON-encoded.
+func metaXattrsOf(meta plugin.JSONObject) map[string]string {
+	xattrs := make(map[string]string)
+	// Round-trip the metadata through JSON so that it only has JSON types,
+	// e.g. times are strings and structs are objects
+	bits, err := json.Marshal(meta)
+	if err != nil {
+		log.Debugf("FUSE: Could not encode the metadata as xattrs: %v", err)
+		return xattrs
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(bits, &normalized); err != nil {
+		log.Debugf("FUSE: Could not encode the metadata as xattrs: %v", err)
+		return xattrs
+	}
+	flattenMeta(strings.TrimSuffix(metaXattrPrefix, "."), normalized, xattrs)
+	return xattrs
+}
+
+func flattenMeta(name string, value interface{}, xattrs map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) > 0 {
+			for key, value := range v {
+				flattenMeta(name+"."+key, value, xattrs)
+			}
+			return
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			for i, value := range v {
+				flattenMeta(name+"."+strconv.Itoa(i), value, xattrs)
+			}
+			return
+		}
+	case string:
+		if len(name) <= maxXattrNameSize {
+			xattrs[name] = v
+		}
+		return
+	}
+	if len(name) > maxXattrNameSize {
+		return
+	}
+	bits, _ := json.Marshal(value)
+	xattrs[name] = string(bits)
+}
+
+// xattrEntry returns the entry's current version. Like Attr, it re-discovers
+// the entry since FUSE caches nodes for a long time.
+func (f *fuseNode) xattrEntry(ctx context.Context, op string) (plugin.Entry, error) {
+	entry, err := runInterruptible(ctx, op+" "+f.String(), func(ctx context.Context) (interface{}, error) {
+		return f.refind(ctx)
+	})
+	if err != nil {
+		activity.Warnf(ctx, "FUSE: [%v] %v errored %v, %v", apitypes.ErrorCodeFor(err), op, f, err)
+		return nil, err
+	}
+	return entry.(plugin.Entry), nil
+}
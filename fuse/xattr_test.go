@@ -0,0 +1,179 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"bazil.org/fuse"
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type xattrTestsEntry struct {
+	plugin.EntryBase
+}
+
+func (e *xattrTestsEntry) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+func newXattrTestsNode(lifecycle plugin.Lifecycle) *fuseNode {
+	return newXattrTestsNodeWithXattrs(lifecycle, nil)
+}
+
+func newXattrTestsNodeWithXattrs(lifecycle plugin.Lifecycle, xattrs map[string]string) *fuseNode {
+	e := &xattrTestsEntry{EntryBase: plugin.NewEntry("foo")}
+	e.SetTestID("/docker/containers/foo")
+	if lifecycle != "" {
+		e.Attributes().SetLifecycle(lifecycle)
+	}
+	e.Attributes().SetXattrs(xattrs)
+	return newFuseNode("f", nil, e)
+}
+
+type XattrTestSuite struct {
+	suite.Suite
+}
+
+func (suite *XattrTestSuite) SetupTest() {
+	plugin.SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *XattrTestSuite) TearDownTest() {
+	plugin.UnsetTestCache()
+}
+
+func (suite *XattrTestSuite) TestGetxattr() {
+	ctx := context.Background()
+	node := newXattrTestsNode(plugin.LifecycleProvisioning)
+	var resp fuse.GetxattrResponse
+	if suite.NoError(node.Getxattr(ctx, &fuse.GetxattrRequest{Name: lifecycleXattr}, &resp)) {
+		suite.Equal("provisioning", string(resp.Xattr))
+	}
+	suite.Equal(fuse.ErrNoXattr, node.Getxattr(ctx, &fuse.GetxattrRequest{Name: "security.selinux"}, &resp))
+
+	node = newXattrTestsNode("")
+	suite.Equal(fuse.ErrNoXattr, node.Getxattr(ctx, &fuse.GetxattrRequest{Name: lifecycleXattr}, &resp))
+}
+
+// withoutMetaXattrs returns the listed xattrs, minus the metadata xattrs
+func withoutMetaXattrs(resp fuse.ListxattrResponse) string {
+	var names []string
+	for _, name := range strings.Split(string(resp.Xattr), "\x00") {
+		if name != "" && !strings.HasPrefix(name, metaXattrPrefix) {
+			names = append(names, name+"\x00")
+		}
+	}
+	return strings.Join(names, "")
+}
+
+func (suite *XattrTestSuite) TestListxattr() {
+	ctx := context.Background()
+	var resp fuse.ListxattrResponse
+	if suite.NoError(newXattrTestsNode(plugin.LifecycleRunning).Listxattr(ctx, &fuse.ListxattrRequest{}, &resp)) {
+		suite.Equal(lifecycleXattr+"\x00", withoutMetaXattrs(resp))
+	}
+
+	resp = fuse.ListxattrResponse{}
+	if suite.NoError(newXattrTestsNode("").Listxattr(ctx, &fuse.ListxattrRequest{}, &resp)) {
+		suite.Empty(withoutMetaXattrs(resp))
+	}
+}
+
+func (suite *XattrTestSuite) TestMetaXattrs() {
+	ctx := context.Background()
+	node := newXattrTestsNode("")
+	node.entry.(*xattrTestsEntry).Attributes().SetMeta(plugin.JSONObject{
+		"State": map[string]interface{}{"Name": "running", "Code": 16},
+		"Tags":  []interface{}{map[string]interface{}{"Key": "env", "Value": "prod"}},
+		"Empty": []interface{}{},
+		"Ready": true,
+	})
+
+	var listResp fuse.ListxattrResponse
+	if suite.NoError(node.Listxattr(ctx, &fuse.ListxattrRequest{}, &listResp)) {
+		suite.Equal(
+			"user.wash.meta.Empty\x00user.wash.meta.Ready\x00user.wash.meta.State.Code\x00user.wash.meta.State.Name\x00user.wash.meta.Tags.0.Key\x00user.wash.meta.Tags.0.Value\x00user.wash.meta._common.labels.env\x00user.wash.meta._common.name\x00user.wash.meta._common.state\x00",
+			string(listResp.Xattr),
+		)
+	}
+
+	for name, expected := range map[string]string{
+		"user.wash.meta.State.Name":   "running",
+		"user.wash.meta.State.Code":   "16",
+		"user.wash.meta.Tags.0.Value": "prod",
+		"user.wash.meta.Empty":        "[]",
+		"user.wash.meta.Ready":        "true",
+		"user.wash.meta._common.name": "foo",
+		// The common metadata's found from the metadata
+		"user.wash.meta._common.state":      "running",
+		"user.wash.meta._common.labels.env": "prod",
+	} {
+		var resp fuse.GetxattrResponse
+		if suite.NoError(node.Getxattr(ctx, &fuse.GetxattrRequest{Name: name}, &resp), name) {
+			suite.Equal(expected, string(resp.Xattr), name)
+		}
+	}
+	var resp fuse.GetxattrResponse
+	suite.Equal(fuse.ErrNoXattr, node.Getxattr(ctx, &fuse.GetxattrRequest{Name: "user.wash.meta.State"}, &resp))
+}
+
+func (suite *XattrTestSuite) TestMetaXattrsSkipLongNames() {
+	xattrs := metaXattrsOf(plugin.JSONObject{strings.Repeat("a", maxXattrNameSize): "foo", "b": "bar"})
+	suite.Equal(map[string]string{"user.wash.meta.b": "bar"}, xattrs)
+}
+
+func (suite *XattrTestSuite) TestXattrNames() {
+	suite.Equal(
+		[]string{"user.a", "user.b", "user.wash.meta.c"},
+		xattrNames(map[string]string{"user.b": "", "user.a": ""}, map[string]string{"user.wash.meta.c": "", "user.a": ""}),
+	)
+
+	// The list's truncated to maxXattrListSize, leaving out the last metadata
+	// xattrs
+	metaXattrs := make(map[string]string)
+	for i := 0; i < 5000; i++ {
+		metaXattrs[fmt.Sprintf("%v%04d", metaXattrPrefix, i)] = ""
+	}
+	names := xattrNames(map[string]string{lifecycleXattr: ""}, metaXattrs)
+	size := 0
+	for _, name := range names {
+		size += len(name) + 1
+	}
+	suite.True(size <= maxXattrListSize)
+	suite.True(size > maxXattrListSize-len(metaXattrPrefix)-5)
+	suite.Contains(names, lifecycleXattr)
+	suite.Contains(names, metaXattrPrefix+"0000")
+	suite.NotContains(names, metaXattrPrefix+"4999")
+}
+
+func (suite *XattrTestSuite) TestEntryXattrs() {
+	ctx := context.Background()
+	node := newXattrTestsNodeWithXattrs(plugin.LifecycleRunning, map[string]string{
+		"image":          "nginx",
+		"wash.lifecycle": "overridden",
+	})
+
+	var getResp fuse.GetxattrResponse
+	if suite.NoError(node.Getxattr(ctx, &fuse.GetxattrRequest{Name: "user.image"}, &getResp)) {
+		suite.Equal("nginx", string(getResp.Xattr))
+	}
+	getResp = fuse.GetxattrResponse{}
+	if suite.NoError(node.Getxattr(ctx, &fuse.GetxattrRequest{Name: lifecycleXattr}, &getResp)) {
+		suite.Equal("running", string(getResp.Xattr))
+	}
+	suite.Equal(fuse.ErrNoXattr, node.Getxattr(ctx, &fuse.GetxattrRequest{Name: "image"}, &getResp))
+	suite.Equal(fuse.ErrNoXattr, node.Getxattr(ctx, &fuse.GetxattrRequest{Name: "user.missing"}, &getResp))
+
+	var listResp fuse.ListxattrResponse
+	if suite.NoError(node.Listxattr(ctx, &fuse.ListxattrRequest{}, &listResp)) {
+		suite.Equal("user.image\x00"+lifecycleXattr+"\x00", withoutMetaXattrs(listResp))
+	}
+}
+
+func TestXattr(t *testing.T) {
+	suite.Run(t, new(XattrTestSuite))
+}
@@ -0,0 +1,78 @@
+// Package netserver accepts and tracks the connections of Wash's network
+// filesystem servers (9P and SFTP), so that shutting a server down
+// disconnects its clients.
+package netserver
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Server serves the connections that are accepted by a listener
+type Server struct {
+	// name prefixes the server's logs, e.g. "9P"
+	name   string
+	handle func(net.Conn)
+	mux    sync.Mutex
+	conns  map[net.Conn]bool
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New returns a server that serves each connection with handle. The
+// connection's closed once handle returns.
+func New(name string, handle func(net.Conn)) *Server {
+	return &Server{name: name, handle: handle, conns: make(map[net.Conn]bool)}
+}
+
+// Accept serves the listener's connections until the listener's closed
+func (s *Server) Accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !strings.Contains(err.Error(), "use of closed network connection") {
+				log.Warnf("%v: Stopped accepting connections: %v", s.name, err)
+			}
+			return
+		}
+		s.mux.Lock()
+		if s.closed {
+			s.mux.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mux.Unlock()
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+			s.mux.Lock()
+			delete(s.conns, conn)
+			s.mux.Unlock()
+		}()
+	}
+}
+
+// Shutdown closes the listener, disconnects the clients and waits for their
+// connections to be served
+func (s *Server) Shutdown(listener net.Listener) {
+	log.Infof("%v: Shutting down the server", s.name)
+	listener.Close()
+	s.closeAll()
+	s.wg.Wait()
+	log.Infof("%v: Shutdown complete", s.name)
+}
+
+func (s *Server) closeAll() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
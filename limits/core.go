@@ -0,0 +1,197 @@
+// Package limits implements Wash's concurrency and rate limits. Limits are
+// registered by the packages that enforce them and can be tuned at runtime
+// (e.g. via the API) without restarting the Wash server.
+package limits
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Limit represents a tunable limit. A value <= 0 typically means that the
+// limit is disabled, but see the limit's description for its semantics.
+type Limit struct {
+	name        string
+	description string
+	mux         sync.RWMutex
+	value       int
+	onChange    func(int)
+	// setMux serializes the sets so that their onChange callbacks are applied
+	// in the same order as their values, i.e. so that the last callback's
+	// value is the limit's value
+	setMux sync.Mutex
+}
+
+// Name returns the limit's name
+func (l *Limit) Name() string {
+	return l.name
+}
+
+// Description returns the limit's description
+func (l *Limit) Description() string {
+	return l.description
+}
+
+// Value returns the limit's current value
+func (l *Limit) Value() int {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+	return l.value
+}
+
+func (l *Limit) set(value int) {
+	l.setMux.Lock()
+	defer l.setMux.Unlock()
+	l.mux.Lock()
+	l.value = value
+	onChange := l.onChange
+	l.mux.Unlock()
+	if onChange != nil {
+		onChange(value)
+	}
+}
+
+var registryMux sync.Mutex
+var registry = make(map[string]*Limit)
+
+// configured contains the values set by Configure. They override the default
+// values of newly registered limits.
+var configured = make(map[string]int)
+
+// Register registers a limit with the given name, description and default
+// value. onChange is optional. If set, it's invoked whenever the limit's value
+// changes (including on registration). If a limit with the same name was already
+// registered, then Register returns the existing limit.
+func Register(name string, description string, defaultValue int, onChange func(int)) *Limit {
+	registryMux.Lock()
+	if l, ok := registry[name]; ok {
+		registryMux.Unlock()
+		return l
+	}
+	value := defaultValue
+	if v, ok := configured[name]; ok {
+		value = v
+	}
+	l := &Limit{
+		name:        name,
+		description: description,
+		onChange:    onChange,
+	}
+	registry[name] = l
+	registryMux.Unlock()
+
+	l.set(value)
+	return l
+}
+
+// Get returns the limit with the given name
+func Get(name string) (*Limit, bool) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	l, ok := registry[name]
+	return l, ok
+}
+
+// All returns all of the registered limits, sorted by name
+func All() []*Limit {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	ls := make([]*Limit, 0, len(registry))
+	for _, l := range registry {
+		ls = append(ls, l)
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].name < ls[j].name })
+	return ls
+}
+
+// Set sets the named limit's value
+func Set(name string, value int) (*Limit, error) {
+	l, ok := Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a registered limit", name)
+	}
+	l.set(value)
+	return l, nil
+}
+
+// Configure sets the limits according to cfg, which is a (possibly nested) map
+// of limit names to values. For example, {"fuse": {"max_requests": 10}} sets
+// the fuse.max_requests limit to 10. Limits that aren't registered yet are set
+// when they're registered.
+func Configure(cfg map[string]interface{}) error {
+	values := make(map[string]int)
+	if err := flatten("", cfg, values); err != nil {
+		return err
+	}
+
+	registryMux.Lock()
+	var registered []*Limit
+	for name, value := range values {
+		configured[name] = value
+		if l, ok := registry[name]; ok {
+			registered = append(registered, l)
+		}
+	}
+	registryMux.Unlock()
+
+	for _, l := range registered {
+		l.set(values[l.name])
+	}
+	return nil
+}
+
+func flatten(prefix string, cfg map[string]interface{}, values map[string]int) error {
+	for key, rawValue := range cfg {
+		name := strings.TrimPrefix(prefix+"."+key, ".")
+		switch value := rawValue.(type) {
+		case int:
+			values[name] = value
+		case int64:
+			values[name] = int(value)
+		case float64:
+			if value != float64(int(value)) {
+				return fmt.Errorf("limit %v must be an integer, not %v", name, value)
+			}
+			values[name] = int(value)
+		case map[string]interface{}:
+			if err := flatten(name, value, values); err != nil {
+				return err
+			}
+		case map[interface{}]interface{}:
+			mp := make(map[string]interface{}, len(value))
+			for k, v := range value {
+				mp[fmt.Sprintf("%v", k)] = v
+			}
+			if err := flatten(name, mp, values); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("limit %v must be an integer, not %v", name, value)
+		}
+	}
+	return nil
+}
+
+var persister func(name string, value int) error
+
+// PersistWith sets the function that's used by Persist to persist a limit's
+// value, e.g. to Wash's config file.
+func PersistWith(f func(name string, value int) error) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	persister = f
+}
+
+// Persist persists the limit's current value so that it's used the next time
+// the Wash server starts.
+func Persist(l *Limit) error {
+	registryMux.Lock()
+	f := persister
+	registryMux.Unlock()
+	if f == nil {
+		return fmt.Errorf("limits cannot be persisted")
+	}
+	return f(l.Name(), l.Value())
+}
@@ -0,0 +1,142 @@
+package limits
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LimitsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *LimitsTestSuite) TearDownTest() {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	registry = make(map[string]*Limit)
+	configured = make(map[string]int)
+	persister = nil
+}
+
+func (suite *LimitsTestSuite) TestRegister() {
+	var changes []int
+	l := Register("foo.bar", "a limit", 5, func(v int) { changes = append(changes, v) })
+	suite.Equal("foo.bar", l.Name())
+	suite.Equal("a limit", l.Description())
+	suite.Equal(5, l.Value())
+	suite.Equal([]int{5}, changes)
+
+	// Registering the same limit again should return the existing limit
+	suite.Equal(l, Register("foo.bar", "another limit", 10, nil))
+	suite.Equal(5, l.Value())
+
+	got, ok := Get("foo.bar")
+	suite.True(ok)
+	suite.Equal(l, got)
+	_, ok = Get("foo.baz")
+	suite.False(ok)
+}
+
+func (suite *LimitsTestSuite) TestAll() {
+	b := Register("b", "", 0, nil)
+	a := Register("a", "", 0, nil)
+	suite.Equal([]*Limit{a, b}, All())
+}
+
+func (suite *LimitsTestSuite) TestSet() {
+	var changes []int
+	Register("foo", "", 5, func(v int) { changes = append(changes, v) })
+
+	l, err := Set("foo", 10)
+	if suite.NoError(err) {
+		suite.Equal(10, l.Value())
+		suite.Equal([]int{5, 10}, changes)
+	}
+
+	_, err = Set("bar", 10)
+	suite.EqualError(err, "bar is not a registered limit")
+}
+
+func (suite *LimitsTestSuite) TestConfigure() {
+	foo := Register("plugins.foo.max_invocations", "", 0, nil)
+
+	err := Configure(map[string]interface{}{
+		"plugins": map[string]interface{}{
+			"foo": map[interface{}]interface{}{
+				"max_invocations": 4,
+			},
+		},
+		"fuse": map[string]interface{}{
+			"max_requests": float64(50),
+		},
+	})
+	if !suite.NoError(err) {
+		return
+	}
+	suite.Equal(4, foo.Value())
+
+	// Limits that are registered after Configure should use the configured
+	// value instead of their default value
+	maxRequests := Register("fuse.max_requests", "", 0, nil)
+	suite.Equal(50, maxRequests.Value())
+}
+
+func (suite *LimitsTestSuite) TestConfigure_ErrorsOnNonIntegerValues() {
+	err := Configure(map[string]interface{}{
+		"fuse": map[string]interface{}{
+			"max_requests": "foo",
+		},
+	})
+	suite.EqualError(err, "limit fuse.max_requests must be an integer, not foo")
+
+	err = Configure(map[string]interface{}{"foo": 1.5})
+	suite.EqualError(err, "limit foo must be an integer, not 1.5")
+}
+
+func (suite *LimitsTestSuite) TestPersist() {
+	l := Register("foo", "", 5, nil)
+	suite.EqualError(Persist(l), "limits cannot be persisted")
+
+	persisted := make(map[string]int)
+	PersistWith(func(name string, value int) error {
+		if name == "bar" {
+			return fmt.Errorf("failed to persist %v", name)
+		}
+		persisted[name] = value
+		return nil
+	})
+	if suite.NoError(Persist(l)) {
+		suite.Equal(map[string]int{"foo": 5}, persisted)
+	}
+	suite.EqualError(Persist(Register("bar", "", 0, nil)), "failed to persist bar")
+}
+
+func (suite *LimitsTestSuite) TestConcurrentSetsApplyTheirCallbacksInOrder() {
+	var mux sync.Mutex
+	var last int
+	l := Register("foo", "a limit", 0, func(v int) {
+		mux.Lock()
+		last = v
+		mux.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := Set("foo", i)
+			suite.NoError(err)
+		}(i)
+	}
+	wg.Wait()
+	mux.Lock()
+	defer mux.Unlock()
+	suite.Equal(l.Value(), last)
+}
+
+func TestLimits(t *testing.T) {
+	suite.Run(t, new(LimitsTestSuite))
+}
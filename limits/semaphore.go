@@ -0,0 +1,161 @@
+package limits
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Semaphore limits the number of concurrent holders to a limit's value. The
+// limit can be changed while the semaphore's in use. Increasing it wakes up
+// any waiting acquirers; decreasing it takes effect as holders release the
+// semaphore. A value <= 0 means that the semaphore is unlimited.
+type Semaphore struct {
+	name    string
+	mux     sync.Mutex
+	limit   int
+	inUse   int
+	waiters []chan struct{}
+	// queued is the number of acquisitions that had to wait, and waited is
+	// how long they waited in total
+	queued int64
+	waited time.Duration
+}
+
+// SemaphoreStats describes a semaphore's usage. A semaphore that has waiting
+// acquirers is saturated.
+type SemaphoreStats struct {
+	Name    string
+	Limit   int
+	InUse   int
+	Waiting int
+	Queued  int64
+	// AvgWait is how long the queued acquisitions waited on average
+	AvgWait time.Duration
+}
+
+var semaphoresMux sync.Mutex
+var semaphores = make(map[string]*Semaphore)
+
+// NewSemaphore registers a limit with the given name, description and default
+// value, then returns a semaphore that's tied to it. If a semaphore with the
+// same name already exists, then NewSemaphore returns it.
+func NewSemaphore(name string, description string, defaultValue int) *Semaphore {
+	semaphoresMux.Lock()
+	defer semaphoresMux.Unlock()
+	if s, ok := semaphores[name]; ok {
+		return s
+	}
+	if _, ok := Get(name); ok {
+		panic(fmt.Sprintf("limits.NewSemaphore: %v is already registered as a limit", name))
+	}
+	s := &Semaphore{name: name}
+	Register(name, description, defaultValue, s.setLimit)
+	semaphores[name] = s
+	return s
+}
+
+func (s *Semaphore) setLimit(limit int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.limit = limit
+	s.grant()
+}
+
+func (s *Semaphore) available() bool {
+	return s.limit <= 0 || s.inUse < s.limit
+}
+
+// grant hands the semaphore to waiters while it's available. It must be
+// called with s.mux held.
+func (s *Semaphore) grant() {
+	for len(s.waiters) > 0 && s.available() {
+		ch := s.waiters[0]
+		s.waiters = s.waiters[1:]
+		s.inUse++
+		close(ch)
+	}
+}
+
+// Acquire acquires the semaphore, blocking until it's available or until
+// ctx is cancelled. It returns ctx.Err() in the latter case.
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	s.mux.Lock()
+	if len(s.waiters) == 0 && s.available() {
+		s.inUse++
+		s.mux.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	s.waiters = append(s.waiters, ch)
+	s.mux.Unlock()
+
+	start := time.Now()
+	select {
+	case <-ch:
+		s.mux.Lock()
+		s.queued++
+		s.waited += time.Since(start)
+		s.mux.Unlock()
+		return nil
+	case <-ctx.Done():
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		for i, waiter := range s.waiters {
+			if waiter == ch {
+				s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// We were granted the semaphore after ctx was cancelled, so
+		// release it.
+		s.inUse--
+		s.grant()
+		return ctx.Err()
+	}
+}
+
+// Release releases the semaphore
+func (s *Semaphore) Release() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.inUse--
+	s.grant()
+}
+
+// Stats returns the semaphore's stats
+func (s *Semaphore) Stats() SemaphoreStats {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	stats := SemaphoreStats{
+		Name:    s.name,
+		Limit:   s.limit,
+		InUse:   s.inUse,
+		Waiting: len(s.waiters),
+		Queued:  s.queued,
+	}
+	if s.queued > 0 {
+		stats.AvgWait = s.waited / time.Duration(s.queued)
+	}
+	return stats
+}
+
+// AllSemaphoreStats returns the stats of all of the semaphores, sorted by
+// name
+func AllSemaphoreStats() []SemaphoreStats {
+	semaphoresMux.Lock()
+	all := make([]*Semaphore, 0, len(semaphores))
+	for _, s := range semaphores {
+		all = append(all, s)
+	}
+	semaphoresMux.Unlock()
+
+	stats := make([]SemaphoreStats, len(all))
+	for i, s := range all {
+		stats[i] = s.Stats()
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
@@ -0,0 +1,129 @@
+package limits
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SemaphoreTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SemaphoreTestSuite) TearDownTest() {
+	semaphoresMux.Lock()
+	semaphores = make(map[string]*Semaphore)
+	semaphoresMux.Unlock()
+	registryMux.Lock()
+	registry = make(map[string]*Limit)
+	configured = make(map[string]int)
+	registryMux.Unlock()
+}
+
+func (suite *SemaphoreTestSuite) acquireInBackground(s *Semaphore) chan error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- s.Acquire(context.Background())
+	}()
+	return ch
+}
+
+func (suite *SemaphoreTestSuite) assertBlocked(ch chan error) {
+	select {
+	case <-ch:
+		suite.Fail("expected Acquire to block")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func (suite *SemaphoreTestSuite) assertAcquired(ch chan error) {
+	select {
+	case err := <-ch:
+		suite.NoError(err)
+	case <-time.After(time.Second):
+		suite.Fail("expected Acquire to return")
+	}
+}
+
+func (suite *SemaphoreTestSuite) TestNewSemaphore() {
+	s := NewSemaphore("foo", "a semaphore", 1)
+	suite.Equal(s, NewSemaphore("foo", "a semaphore", 1))
+
+	l, ok := Get("foo")
+	if suite.True(ok) {
+		suite.Equal(1, l.Value())
+	}
+
+	Register("bar", "a limit", 1, nil)
+	suite.Panics(func() { NewSemaphore("bar", "a semaphore", 1) })
+}
+
+func (suite *SemaphoreTestSuite) TestAcquire_Unlimited() {
+	s := NewSemaphore("foo", "", 0)
+	for i := 0; i < 100; i++ {
+		suite.NoError(s.Acquire(context.Background()))
+	}
+}
+
+func (suite *SemaphoreTestSuite) TestAcquire_EnforcesLimit() {
+	s := NewSemaphore("foo", "", 1)
+	suite.NoError(s.Acquire(context.Background()))
+
+	ch := suite.acquireInBackground(s)
+	suite.assertBlocked(ch)
+	s.Release()
+	suite.assertAcquired(ch)
+}
+
+func (suite *SemaphoreTestSuite) TestAcquire_RaisingTheLimitWakesWaiters() {
+	s := NewSemaphore("foo", "", 1)
+	suite.NoError(s.Acquire(context.Background()))
+
+	ch := suite.acquireInBackground(s)
+	suite.assertBlocked(ch)
+	_, err := Set("foo", 2)
+	suite.NoError(err)
+	suite.assertAcquired(ch)
+}
+
+func (suite *SemaphoreTestSuite) TestAcquire_CancelledContext() {
+	s := NewSemaphore("foo", "", 1)
+	suite.NoError(s.Acquire(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	suite.Equal(context.Canceled, s.Acquire(ctx))
+
+	// The cancelled acquirer shouldn't hold up the acquirers behind it
+	s.Release()
+	suite.NoError(s.Acquire(context.Background()))
+}
+
+func (suite *SemaphoreTestSuite) TestStats() {
+	s := NewSemaphore("foo", "", 1)
+	NewSemaphore("bar", "", 0)
+	suite.NoError(s.Acquire(context.Background()))
+	ch := suite.acquireInBackground(s)
+	suite.assertBlocked(ch)
+	suite.Equal(SemaphoreStats{Name: "foo", Limit: 1, InUse: 1, Waiting: 1}, s.Stats())
+
+	s.Release()
+	suite.assertAcquired(ch)
+	stats := s.Stats()
+	suite.Equal(1, stats.InUse)
+	suite.Equal(0, stats.Waiting)
+	suite.Equal(int64(1), stats.Queued)
+	suite.True(stats.AvgWait >= 10*time.Millisecond)
+
+	all := AllSemaphoreStats()
+	if suite.Len(all, 2) {
+		suite.Equal("bar", all[0].Name)
+		suite.Equal("foo", all[1].Name)
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	suite.Run(t, new(SemaphoreTestSuite))
+}
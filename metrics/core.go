@@ -0,0 +1,281 @@
+// Package metrics collects the server's live activity, e.g. how many FUSE
+// requests it's serving and how long each plugin's calls take. Unlike the
+// activity journals, metrics are aggregated in memory and only describe the
+// recent past. They're served by the API's /metrics endpoint, which is what
+// `wash top` displays.
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+)
+
+// Window is how far back the rates (e.g. FUSE ops/sec) are averaged over
+const Window = 10 * time.Second
+
+// maxRecentErrors is how many of the most recent errors are kept
+const maxRecentErrors = 20
+
+// Snapshot describes the server's activity when it was taken
+type Snapshot struct {
+	Time time.Time `json:"time"`
+	// FUSEOpsPerSecond is the rate of all FUSE requests. FUSEOps breaks it
+	// down by the type of request, e.g. Lookup or Read.
+	FUSEOpsPerSecond float64            `json:"fuse_ops_per_second"`
+	FUSEOps          map[string]float64 `json:"fuse_ops"`
+	// Calls are the stats of each plugin's actions, sorted by plugin and
+	// action
+	Calls []CallStats `json:"calls"`
+	Cache CacheStats  `json:"cache"`
+	// ActiveStreams and ActiveExecs are the streams and execs that are
+	// currently being served by the API
+	ActiveStreams int64 `json:"active_streams"`
+	ActiveExecs   int64 `json:"active_execs"`
+	// Queues are the stats of the concurrency limits (e.g. each external
+	// plugin's maximum invocations) that are enforced, sorted by name. A
+	// queue with waiting requests is saturated.
+	Queues []QueueStats `json:"queues"`
+	// RecentErrors are the most recent errors, oldest first
+	RecentErrors []Error `json:"recent_errors"`
+}
+
+// CallStats describes the calls to a plugin's action
+type CallStats struct {
+	Plugin string `json:"plugin"`
+	Action string `json:"action"`
+	// Count is the total number of calls
+	Count     int64   `json:"count"`
+	PerSecond float64 `json:"per_second"`
+	// AvgMS is the calls' average latency in milliseconds. MaxMS is the
+	// latency of the slowest call, and LastMS is the latency of the most
+	// recent call.
+	AvgMS  float64 `json:"avg_ms"`
+	MaxMS  int64   `json:"max_ms"`
+	LastMS int64   `json:"last_ms"`
+}
+
+// CacheStats describes the cache lookups
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// HitRate is the fraction of the lookups in the last Window that were
+	// hits. It's zero if there weren't any lookups.
+	HitRate float64 `json:"hit_rate"`
+}
+
+// QueueStats describes the requests that are limited by a concurrency limit
+type QueueStats struct {
+	Name  string `json:"name"`
+	Limit int    `json:"limit"`
+	// InUse is the number of requests that are being served, and Waiting is
+	// the number of requests that are waiting to be served
+	InUse   int `json:"in_use"`
+	Waiting int `json:"waiting"`
+	// Queued is the total number of requests that had to wait. AvgWaitMS is
+	// how long they waited on average, in milliseconds.
+	Queued    int64   `json:"queued"`
+	AvgWaitMS float64 `json:"avg_wait_ms"`
+}
+
+// Error is one of the recent errors
+type Error struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// rate counts events in one-second buckets so that their rate over the last
+// Window can be computed
+type rate struct {
+	counts  [int(Window / time.Second)]int64
+	seconds [int(Window / time.Second)]int64
+}
+
+func (r *rate) add(now time.Time, n int64) {
+	second := now.Unix()
+	i := int(second % int64(len(r.counts)))
+	if r.seconds[i] != second {
+		r.seconds[i], r.counts[i] = second, 0
+	}
+	r.counts[i] += n
+}
+
+// total returns the number of events in the last Window
+func (r *rate) total(now time.Time) int64 {
+	second := now.Unix()
+	var total int64
+	for i, s := range r.seconds {
+		if second-s < int64(len(r.counts)) {
+			total += r.counts[i]
+		}
+	}
+	return total
+}
+
+func (r *rate) perSecond(now time.Time) float64 {
+	return float64(r.total(now)) / Window.Seconds()
+}
+
+type callStats struct {
+	CallStats
+	totalMS float64
+	rate    rate
+}
+
+var mux sync.Mutex
+var fuseOps = make(map[string]*rate)
+var fuseOpsTotal rate
+var calls = make(map[string]*callStats)
+var cacheHits, cacheMisses int64
+var cacheHitRate, cacheMissRate rate
+var activeStreams, activeExecs int64
+var recentErrors []Error
+
+// now is stubbed by the tests
+var now = time.Now
+
+// RecordFUSEOp records a FUSE request of the given type, e.g. Lookup
+func RecordFUSEOp(op string) {
+	mux.Lock()
+	defer mux.Unlock()
+	t := now()
+	r, ok := fuseOps[op]
+	if !ok {
+		r = &rate{}
+		fuseOps[op] = r
+	}
+	r.add(t, 1)
+	fuseOpsTotal.add(t, 1)
+}
+
+// RecordCall records that a call to the plugin's action took elapsed
+func RecordCall(plugin string, action string, elapsed time.Duration) {
+	mux.Lock()
+	defer mux.Unlock()
+	key := plugin + "/" + action
+	stats, ok := calls[key]
+	if !ok {
+		stats = &callStats{CallStats: CallStats{Plugin: plugin, Action: action}}
+		calls[key] = stats
+	}
+	ms := elapsed.Milliseconds()
+	stats.Count++
+	stats.totalMS += float64(elapsed) / float64(time.Millisecond)
+	if ms > stats.MaxMS {
+		stats.MaxMS = ms
+	}
+	stats.LastMS = ms
+	stats.rate.add(now(), 1)
+}
+
+// RecordCacheLookup records a cache lookup. hit is true if the lookup found
+// a cached result.
+func RecordCacheLookup(hit bool) {
+	mux.Lock()
+	defer mux.Unlock()
+	if hit {
+		cacheHits++
+		cacheHitRate.add(now(), 1)
+	} else {
+		cacheMisses++
+		cacheMissRate.add(now(), 1)
+	}
+}
+
+// StartStream records that a stream started. The returned function records
+// that it ended.
+func StartStream() func() {
+	return startActive(&activeStreams)
+}
+
+// StartExec records that an exec started. The returned function records that
+// it ended.
+func StartExec() func() {
+	return startActive(&activeExecs)
+}
+
+func startActive(gauge *int64) func() {
+	mux.Lock()
+	*gauge++
+	mux.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mux.Lock()
+			*gauge--
+			mux.Unlock()
+		})
+	}
+}
+
+// RecordError records an error so that it's included in the recent errors
+func RecordError(msg string) {
+	mux.Lock()
+	defer mux.Unlock()
+	recentErrors = append(recentErrors, Error{Time: now(), Message: msg})
+	if len(recentErrors) > maxRecentErrors {
+		recentErrors = append([]Error(nil), recentErrors[len(recentErrors)-maxRecentErrors:]...)
+	}
+}
+
+// Take returns a snapshot of the metrics
+func Take() Snapshot {
+	mux.Lock()
+	defer mux.Unlock()
+	t := now()
+	snapshot := Snapshot{
+		Time:             t,
+		FUSEOpsPerSecond: fuseOpsTotal.perSecond(t),
+		FUSEOps:          make(map[string]float64, len(fuseOps)),
+		Calls:            make([]CallStats, 0, len(calls)),
+		Cache:            CacheStats{Hits: cacheHits, Misses: cacheMisses},
+		Queues:           queueStats(),
+		ActiveStreams:    activeStreams,
+		ActiveExecs:      activeExecs,
+		RecentErrors:     append([]Error{}, recentErrors...),
+	}
+	for op, r := range fuseOps {
+		if perSecond := r.perSecond(t); perSecond > 0 {
+			snapshot.FUSEOps[op] = perSecond
+		}
+	}
+	for _, stats := range calls {
+		s := stats.CallStats
+		s.PerSecond = stats.rate.perSecond(t)
+		s.AvgMS = stats.totalMS / float64(stats.Count)
+		snapshot.Calls = append(snapshot.Calls, s)
+	}
+	sort.Slice(snapshot.Calls, func(i, j int) bool {
+		a, b := snapshot.Calls[i], snapshot.Calls[j]
+		if a.Plugin != b.Plugin {
+			return a.Plugin < b.Plugin
+		}
+		return a.Action < b.Action
+	})
+	if hits, misses := cacheHitRate.total(t), cacheMissRate.total(t); hits+misses > 0 {
+		snapshot.Cache.HitRate = float64(hits) / float64(hits+misses)
+	}
+	return snapshot
+}
+
+// queueStats returns the stats of the semaphores that have a limit (or that
+// had one)
+func queueStats() []QueueStats {
+	queues := []QueueStats{}
+	for _, s := range limits.AllSemaphoreStats() {
+		if s.Limit <= 0 && s.Queued == 0 {
+			continue
+		}
+		queues = append(queues, QueueStats{
+			Name:      s.Name,
+			Limit:     s.Limit,
+			InUse:     s.InUse,
+			Waiting:   s.Waiting,
+			Queued:    s.Queued,
+			AvgWaitMS: float64(s.AvgWait) / float64(time.Millisecond),
+		})
+	}
+	return queues
+}
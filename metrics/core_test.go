@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+	time time.Time
+}
+
+func (suite *MetricsTestSuite) SetupTest() {
+	suite.time = time.Unix(1000, 0)
+	now = func() time.Time {
+		return suite.time
+	}
+}
+
+func (suite *MetricsTestSuite) TearDownTest() {
+	now = time.Now
+	mux.Lock()
+	defer mux.Unlock()
+	fuseOps = make(map[string]*rate)
+	fuseOpsTotal = rate{}
+	calls = make(map[string]*callStats)
+	cacheHits, cacheMisses = 0, 0
+	cacheHitRate, cacheMissRate = rate{}, rate{}
+	activeStreams, activeExecs = 0, 0
+	recentErrors = nil
+}
+
+func (suite *MetricsTestSuite) TestFUSEOpsAreAveragedOverTheWindow() {
+	for i := 0; i < 20; i++ {
+		RecordFUSEOp("Lookup")
+	}
+	suite.time = suite.time.Add(time.Second)
+	for i := 0; i < 10; i++ {
+		RecordFUSEOp("Read")
+	}
+	snapshot := Take()
+	suite.Equal(3.0, snapshot.FUSEOpsPerSecond)
+	suite.Equal(map[string]float64{"Lookup": 2, "Read": 1}, snapshot.FUSEOps)
+
+	// The Lookups fall out of the window first
+	suite.time = suite.time.Add(Window - time.Second)
+	snapshot = Take()
+	suite.Equal(1.0, snapshot.FUSEOpsPerSecond)
+	suite.Equal(map[string]float64{"Read": 1}, snapshot.FUSEOps)
+
+	suite.time = suite.time.Add(time.Second)
+	suite.Equal(0.0, Take().FUSEOpsPerSecond)
+}
+
+func (suite *MetricsTestSuite) TestCalls() {
+	RecordCall("docker", "list", 10*time.Millisecond)
+	RecordCall("docker", "list", 30*time.Millisecond)
+	RecordCall("aws", "read", 5*time.Millisecond)
+	calls := Take().Calls
+	if suite.Len(calls, 2) {
+		suite.Equal(CallStats{Plugin: "aws", Action: "read", Count: 1, PerSecond: 0.1, AvgMS: 5, MaxMS: 5, LastMS: 5}, calls[0])
+		suite.Equal(CallStats{Plugin: "docker", Action: "list", Count: 2, PerSecond: 0.2, AvgMS: 20, MaxMS: 30, LastMS: 30}, calls[1])
+	}
+}
+
+func (suite *MetricsTestSuite) TestCacheHitRate() {
+	suite.Equal(CacheStats{}, Take().Cache)
+	RecordCacheLookup(true)
+	RecordCacheLookup(true)
+	RecordCacheLookup(true)
+	RecordCacheLookup(false)
+	suite.Equal(CacheStats{Hits: 3, Misses: 1, HitRate: 0.75}, Take().Cache)
+
+	// The totals are kept once the lookups fall out of the window
+	suite.time = suite.time.Add(Window)
+	suite.Equal(CacheStats{Hits: 3, Misses: 1}, Take().Cache)
+}
+
+func (suite *MetricsTestSuite) TestActiveStreamsAndExecs() {
+	endStream := StartStream()
+	StartStream()
+	endExec := StartExec()
+	snapshot := Take()
+	suite.Equal(int64(2), snapshot.ActiveStreams)
+	suite.Equal(int64(1), snapshot.ActiveExecs)
+
+	// Ending a stream twice only counts once
+	endStream()
+	endStream()
+	endExec()
+	snapshot = Take()
+	suite.Equal(int64(1), snapshot.ActiveStreams)
+	suite.Equal(int64(0), snapshot.ActiveExecs)
+}
+
+func (suite *MetricsTestSuite) TestRecentErrors() {
+	for i := 0; i < maxRecentErrors+5; i++ {
+		RecordError(fmt.Sprintf("error %v", i))
+	}
+	errs := Take().RecentErrors
+	if suite.Len(errs, maxRecentErrors) {
+		suite.Equal("error 5", errs[0].Message)
+		suite.Equal(fmt.Sprintf("error %v", maxRecentErrors+4), errs[maxRecentErrors-1].Message)
+		suite.Equal(suite.time, errs[0].Time)
+	}
+}
+
+func (suite *MetricsTestSuite) TestQueues() {
+	limited := limits.NewSemaphore("metrics_tests.limited", "", 1)
+	limits.NewSemaphore("metrics_tests.unlimited", "", 0)
+	suite.NoError(limited.Acquire(context.Background()))
+	defer limited.Release()
+
+	var queues []QueueStats
+	for _, q := range Take().Queues {
+		if strings.HasPrefix(q.Name, "metrics_tests.") {
+			queues = append(queues, q)
+		}
+	}
+	suite.Equal([]QueueStats{{Name: "metrics_tests.limited", Limit: 1, InUse: 1}}, queues)
+}
+
+func TestMetrics(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
@@ -0,0 +1,641 @@
+package ninep
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"os/user"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// The errors that are sent to clients. Linux maps them to their errno, so
+// they must match its strings. The actual error's logged instead.
+const (
+	enoent      = "No such file or directory"
+	eacces      = "Permission denied"
+	eisdir      = "Is a directory"
+	enotdir     = "Not a directory"
+	eopnotsupp  = "Operation not supported"
+	eio         = "Input/output error"
+	ebadfid     = "fid unknown or out of range"
+	einuse      = "fid already in use"
+	ebadmessage = "Bad message"
+	efbig       = "File too large"
+	einval      = "Invalid argument"
+)
+
+var startTime = time.Now()
+
+// conn serves the 9P requests of a single client. Requests are served in
+// order.
+type conn struct {
+	registry *plugin.Registry
+	rw       io.ReadWriter
+	msize    uint32
+	fids     map[uint32]*fid
+	owner    string
+	// authToken is the token that the client must authenticate with before
+	// it can attach. It's empty if the client doesn't need to authenticate.
+	authToken string
+}
+
+// fid is a file (or directory) that the client walked to
+type fid struct {
+	path  string
+	entry plugin.Entry
+	open  bool
+	// auth is set for the authentication fids (see Tauth). The client
+	// writes the token to them, which is buffered in content.
+	auth bool
+	// reader is set for the files that are only opened for reading
+	reader plugin.SizedReader
+	// writable is set for the files that are opened for writing. Writes
+	// are buffered in content, which is written to the entry when the fid's
+	// clunked since entries can only replace their entire content.
+	writable plugin.Writable
+	content  []byte
+	dirty    bool
+	// children are the encoded stats of a directory's children. They're
+	// set when the directory's opened. A directory's read from its first
+	// child (offset 0) or from where the previous read ended.
+	children   [][]byte
+	nextChild  int
+	nextOffset uint64
+}
+
+// newConn returns a connection that serves the registry's entries to the
+// client at the other end of rw. If authToken isn't empty, then the client
+// must authenticate with it before it can attach.
+func newConn(registry *plugin.Registry, rw io.ReadWriter, authToken string) *conn {
+	owner := "wash"
+	if u, err := user.Current(); err == nil {
+		owner = u.Username
+	}
+	return &conn{
+		registry:  registry,
+		rw:        rw,
+		msize:     maxMessageSize,
+		fids:      make(map[uint32]*fid),
+		owner:     owner,
+		authToken: authToken,
+	}
+}
+
+// serve serves the connection's requests until the client disconnects
+func (c *conn) serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer c.clunkAll()
+
+	for {
+		msg, err := readMessage(c.rw, c.msize)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := c.handle(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *conn) handle(ctx context.Context, msg message) error {
+	d := &decoder{buf: msg.body}
+	switch msg.typ {
+	case tversion:
+		msize, clientVersion := d.uint32(), d.string()
+		if d.err != nil {
+			return c.error(msg.tag, ebadmessage)
+		}
+		if msize < minMessageSize {
+			return c.error(msg.tag, fmt.Sprintf("the msize must be at least %v", minMessageSize))
+		}
+		if msize < c.msize {
+			c.msize = msize
+		}
+		// A version resets the session
+		c.clunkAll()
+		negotiated := "unknown"
+		if strings.HasPrefix(clientVersion, version) {
+			negotiated = version
+		}
+		return c.reply(rversion, msg.tag, new(encoder).uint32(c.msize).string(negotiated).buf)
+	case tauth:
+		if c.authToken == "" {
+			return c.error(msg.tag, "authentication is not required")
+		}
+		afidID := d.uint32()
+		if d.err != nil {
+			return c.error(msg.tag, ebadmessage)
+		}
+		if _, ok := c.fids[afidID]; ok {
+			return c.error(msg.tag, einuse)
+		}
+		c.fids[afidID] = &fid{auth: true}
+		return c.reply(rauth, msg.tag, new(encoder).qid(qid{typ: qtauth}).buf)
+	case tattach:
+		fidID, afidID := d.uint32(), d.uint32()
+		if d.err != nil {
+			return c.error(msg.tag, ebadmessage)
+		}
+		if _, ok := c.fids[fidID]; ok {
+			return c.error(msg.tag, einuse)
+		}
+		if c.authToken != "" && !c.authenticated(afidID) {
+			log.Warnf("9P: Refused an unauthenticated attach")
+			return c.error(msg.tag, eacces)
+		}
+		root := &fid{path: "/", entry: c.registry}
+		c.fids[fidID] = root
+		return c.reply(rattach, msg.tag, new(encoder).qid(qidOf(root.path, root.entry)).buf)
+	case tflush:
+		// Requests are served in order, so the flushed request's already
+		// been answered
+		return c.reply(rflush, msg.tag, nil)
+	case twalk:
+		return c.walk(ctx, msg.tag, d)
+	case topen:
+		f, ok := c.fids[d.uint32()]
+		mode := d.uint8()
+		if d.err != nil {
+			return c.error(msg.tag, ebadmessage)
+		}
+		if !ok || f.auth {
+			return c.error(msg.tag, ebadfid)
+		}
+		return c.open(ctx, msg.tag, f, mode)
+	case tread:
+		f, ok := c.fids[d.uint32()]
+		offset, count := d.uint64(), d.uint32()
+		if d.err != nil {
+			return c.error(msg.tag, ebadmessage)
+		}
+		if ok && f.auth {
+			// There's nothing to read since the token's all the client
+			// has to write
+			return c.reply(rread, msg.tag, new(encoder).uint32(0).buf)
+		}
+		if !ok || !f.open {
+			return c.error(msg.tag, ebadfid)
+		}
+		if max := c.msize - ioHeaderSize; count > max {
+			count = max
+		}
+		if offset > math.MaxInt64 {
+			return c.error(msg.tag, einval)
+		}
+		return c.read(msg.tag, f, offset, count)
+	case twrite:
+		f, ok := c.fids[d.uint32()]
+		offset, count := d.uint64(), d.uint32()
+		data := d.next(int(count))
+		if d.err != nil {
+			return c.error(msg.tag, ebadmessage)
+		}
+		if ok && f.auth {
+			if len(f.content)+len(data) > maxAuthTokenSize {
+				return c.error(msg.tag, efbig)
+			}
+			f.content = append(f.content, data...)
+			return c.reply(rwrite, msg.tag, new(encoder).uint32(count).buf)
+		}
+		if !ok || !f.open {
+			return c.error(msg.tag, ebadfid)
+		}
+		if f.writable == nil {
+			return c.error(msg.tag, eacces)
+		}
+		// Checking the offset first keeps the end from overflowing
+		if offset > maxWriteSize || offset+uint64(len(data)) > maxWriteSize {
+			return c.error(msg.tag, efbig)
+		}
+		if end := int(offset) + len(data); end > len(f.content) {
+			f.content = append(f.content, make([]byte, end-len(f.content))...)
+		}
+		copy(f.content[offset:], data)
+		f.dirty = true
+		return c.reply(rwrite, msg.tag, new(encoder).uint32(count).buf)
+	case tclunk:
+		fidID := d.uint32()
+		f, ok := c.fids[fidID]
+		if !ok {
+			return c.error(msg.tag, ebadfid)
+		}
+		delete(c.fids, fidID)
+		if err := c.clunk(ctx, f); err != nil {
+			return c.error(msg.tag, enameFor(err))
+		}
+		return c.reply(rclunk, msg.tag, nil)
+	case tremove:
+		// remove clunks the fid even if it fails
+		fidID := d.uint32()
+		if f, ok := c.fids[fidID]; ok {
+			delete(c.fids, fidID)
+			c.clunk(ctx, f)
+		}
+		return c.error(msg.tag, eopnotsupp)
+	case tstat:
+		f, ok := c.fids[d.uint32()]
+		if !ok || f.auth {
+			return c.error(msg.tag, ebadfid)
+		}
+		s := new(encoder).stat(c.statOf(f.path, f.entry))
+		return c.reply(rstat, msg.tag, new(encoder).uint16(uint16(len(s.buf))).buf, s.buf)
+	case twstat:
+		f, ok := c.fids[d.uint32()]
+		d.uint16()
+		s := d.stat()
+		if d.err != nil {
+			return c.error(msg.tag, ebadmessage)
+		}
+		if !ok || f.auth {
+			return c.error(msg.tag, ebadfid)
+		}
+		return c.wstat(ctx, msg.tag, f, s)
+	default:
+		return c.error(msg.tag, eopnotsupp)
+	}
+}
+
+// authenticated returns true if the client wrote the auth token to the
+// authentication fid afidID. Trailing whitespace is ignored so that the
+// token can be written with e.g. echo.
+func (c *conn) authenticated(afidID uint32) bool {
+	afid, ok := c.fids[afidID]
+	if !ok || !afid.auth {
+		return false
+	}
+	token := bytes.TrimRight(afid.content, " \t\r\n")
+	return subtle.ConstantTimeCompare(token, []byte(c.authToken)) == 1
+}
+
+func (c *conn) walk(ctx context.Context, tag uint16, d *decoder) error {
+	fidID, newFidID, nwname := d.uint32(), d.uint32(), d.uint16()
+	names := make([]string, nwname)
+	for i := range names {
+		names[i] = d.string()
+	}
+	if d.err != nil {
+		return c.error(tag, ebadmessage)
+	}
+	f, ok := c.fids[fidID]
+	if !ok || f.auth {
+		return c.error(tag, ebadfid)
+	}
+	if _, ok := c.fids[newFidID]; ok && newFidID != fidID {
+		return c.error(tag, einuse)
+	}
+
+	p, entry := f.path, f.entry
+	e := new(encoder)
+	var qids []qid
+	for i, name := range names {
+		next, nextEntry, err := c.walkOne(ctx, p, entry, name)
+		if err != nil {
+			log.Debugf("9P: Walk %v errored: %v", path.Join(p, name), err)
+			if i == 0 {
+				return c.error(tag, enameFor(err))
+			}
+			// The client's told how far the walk got
+			break
+		}
+		p, entry = next, nextEntry
+		qids = append(qids, qidOf(p, entry))
+	}
+	if len(qids) == len(names) {
+		c.fids[newFidID] = &fid{path: p, entry: entry}
+	}
+	e.uint16(uint16(len(qids)))
+	for _, q := range qids {
+		e.qid(q)
+	}
+	return c.reply(rwalk, tag, e.buf)
+}
+
+func (c *conn) walkOne(ctx context.Context, p string, entry plugin.Entry, name string) (string, plugin.Entry, error) {
+	if name == ".." {
+		if p == "/" {
+			return p, entry, nil
+		}
+		parent := path.Dir(p)
+		parentEntry, err := c.find(ctx, parent)
+		return parent, parentEntry, err
+	}
+	if !plugin.ListAction().IsSupportedOn(entry) {
+		return "", nil, errNotDir
+	}
+	child := path.Join(p, name)
+	if entry == plugin.Entry(c.registry) {
+		root, ok := c.registry.Plugins()[name]
+		if !ok {
+			return "", nil, os.ErrNotExist
+		}
+		return child, root, nil
+	}
+	childEntry, err := plugin.FindEntry(ctx, entry, []string{name})
+	if err != nil {
+		if os.IsPermission(err) {
+			return "", nil, err
+		}
+		return "", nil, notFoundError{err}
+	}
+	return child, childEntry, nil
+}
+
+// find returns the entry at p, which is an absolute, clean path
+func (c *conn) find(ctx context.Context, p string) (plugin.Entry, error) {
+	var entry plugin.Entry = c.registry
+	cur := "/"
+	if p == "/" {
+		return entry, nil
+	}
+	for _, name := range strings.Split(strings.TrimPrefix(p, "/"), "/") {
+		var err error
+		if cur, entry, err = c.walkOne(ctx, cur, entry, name); err != nil {
+			return nil, err
+		}
+	}
+	return entry, nil
+}
+
+func (c *conn) open(ctx context.Context, tag uint16, f *fid, mode uint8) error {
+	if f.open {
+		return c.error(tag, einuse)
+	}
+	log.Debugf("9P: Open %v", f.path)
+	iounit := c.msize - ioHeaderSize
+	access := mode & 3
+
+	if plugin.ListAction().IsSupportedOn(f.entry) {
+		if access != oread && access != oexec {
+			return c.error(tag, eisdir)
+		}
+		children, err := plugin.List(ctx, f.entry.(plugin.Parent))
+		if err != nil {
+			activity.Warnf(ctx, "9P: [%v] Open %v errored: %v", apitypes.ErrorCodeFor(err), f.path, err)
+			return c.error(tag, enameFor(err))
+		}
+		cnames := make([]string, 0, len(children))
+		for cname := range children {
+			cnames = append(cnames, cname)
+		}
+		sort.Strings(cnames)
+		for _, cname := range cnames {
+			s := c.statOf(path.Join(f.path, cname), children[cname])
+			f.children = append(f.children, new(encoder).stat(s).buf)
+		}
+		f.open = true
+		return c.reply(ropen, tag, new(encoder).qid(qidOf(f.path, f.entry)).uint32(iounit).buf)
+	}
+
+	if access == owrite || access == ordwr {
+		if !plugin.WriteAction().IsSupportedOn(f.entry) {
+			return c.error(tag, eacces)
+		}
+		f.writable = f.entry.(plugin.Writable)
+		if mode&otrunc != 0 {
+			// Truncating the file replaces its content even if nothing's
+			// written
+			f.dirty = true
+		} else if plugin.ReadAction().IsSupportedOn(f.entry) {
+			// The writes only change part of the content, so the rest of
+			// it needs to be written back
+			content, err := readAll(ctx, f.entry.(plugin.Readable))
+			if err != nil {
+				activity.Warnf(ctx, "9P: [%v] Open %v errored: %v", apitypes.ErrorCodeFor(err), f.path, err)
+				return c.error(tag, enameFor(err))
+			}
+			f.content = content
+		}
+	} else {
+		if !plugin.ReadAction().IsSupportedOn(f.entry) {
+			return c.error(tag, eacces)
+		}
+		reader, err := plugin.Open(ctx, f.entry.(plugin.Readable))
+		if err != nil {
+			activity.Warnf(ctx, "9P: [%v] Open %v errored: %v", apitypes.ErrorCodeFor(err), f.path, err)
+			return c.error(tag, enameFor(err))
+		}
+		f.reader = reader
+	}
+	f.open = true
+	return c.reply(ropen, tag, new(encoder).qid(qidOf(f.path, f.entry)).uint32(iounit).buf)
+}
+
+func (c *conn) read(tag uint16, f *fid, offset uint64, count uint32) error {
+	if f.children != nil || plugin.ListAction().IsSupportedOn(f.entry) {
+		if offset == 0 {
+			f.nextChild, f.nextOffset = 0, 0
+		} else if offset != f.nextOffset {
+			return c.error(tag, "bad offset in directory read")
+		}
+		var data []byte
+		for f.nextChild < len(f.children) {
+			s := f.children[f.nextChild]
+			if len(data)+len(s) > int(count) {
+				break
+			}
+			data = append(data, s...)
+			f.nextChild++
+		}
+		f.nextOffset += uint64(len(data))
+		return c.reply(rread, tag, new(encoder).uint32(uint32(len(data))).buf, data)
+	}
+
+	var data []byte
+	if f.writable != nil {
+		if offset < uint64(len(f.content)) {
+			end := offset + uint64(count)
+			if end > uint64(len(f.content)) {
+				end = uint64(len(f.content))
+			}
+			data = f.content[offset:end]
+		}
+	} else if int64(offset) < f.reader.Size() {
+		buf := make([]byte, count)
+		n, err := f.reader.ReadAt(buf, int64(offset))
+		if err != nil && err != io.EOF {
+			log.Debugf("9P: Read %v errored: %v", f.path, err)
+			return c.error(tag, enameFor(err))
+		}
+		data = buf[:n]
+	}
+	return c.reply(rread, tag, new(encoder).uint32(uint32(len(data))).buf, data)
+}
+
+// wstat only supports truncating files, which is how clients truncate the
+// files that they don't open with otrunc. Other changes are rejected.
+func (c *conn) wstat(ctx context.Context, tag uint16, f *fid, s stat) error {
+	const dontTouch32 = ^uint32(0)
+	unchanged := s.mode == dontTouch32 && s.atime == dontTouch32 && s.mtime == dontTouch32 && s.name == "" && s.uid == "" && s.gid == ""
+	if !unchanged {
+		return c.error(tag, eopnotsupp)
+	}
+	switch s.length {
+	case ^uint64(0):
+		return c.reply(rwstat, tag, nil)
+	case 0:
+		if f.writable != nil {
+			f.content, f.dirty = nil, true
+			return c.reply(rwstat, tag, nil)
+		}
+		if !plugin.WriteAction().IsSupportedOn(f.entry) {
+			return c.error(tag, eacces)
+		}
+		if err := c.write(ctx, f.path, f.entry.(plugin.Writable), []byte{}); err != nil {
+			return c.error(tag, enameFor(err))
+		}
+		return c.reply(rwstat, tag, nil)
+	default:
+		return c.error(tag, eopnotsupp)
+	}
+}
+
+func (c *conn) clunk(ctx context.Context, f *fid) error {
+	if closer, ok := f.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	if f.writable == nil || !f.dirty {
+		return nil
+	}
+	return c.write(ctx, f.path, f.writable, f.content)
+}
+
+func (c *conn) write(ctx context.Context, p string, w plugin.Writable, content []byte) error {
+	activity.Record(ctx, "9P: Writing %v bytes to %v", len(content), p)
+	if err := plugin.Write(ctx, w, content); err != nil {
+		activity.Warnf(ctx, "9P: [%v] Write %v errored: %v", apitypes.ErrorCodeFor(err), p, err)
+		return err
+	}
+	return nil
+}
+
+// clunkAll clunks the fids that the client left open when it disconnected.
+// Their unflushed writes are discarded since the client never clunked them.
+func (c *conn) clunkAll() {
+	for id, f := range c.fids {
+		if closer, ok := f.reader.(io.Closer); ok {
+			closer.Close()
+		}
+		delete(c.fids, id)
+	}
+}
+
+func (c *conn) reply(typ byte, tag uint16, body []byte, data ...[]byte) error {
+	for _, d := range data {
+		body = append(body, d...)
+	}
+	return writeMessage(c.rw, typ, tag, body)
+}
+
+func (c *conn) error(tag uint16, ename string) error {
+	return c.reply(rerror, tag, new(encoder).string(ename).buf)
+}
+
+var errNotDir = fmt.Errorf("the entry is not a directory")
+
+// notFoundError is returned when a walk's child doesn't exist
+type notFoundError struct {
+	err error
+}
+
+func (e notFoundError) Error() string {
+	return e.err.Error()
+}
+
+func enameFor(err error) string {
+	switch {
+	case err == errNotDir:
+		return enotdir
+	case os.IsPermission(err):
+		return eacces
+	case os.IsNotExist(err):
+		return enoent
+	}
+	if _, ok := err.(notFoundError); ok {
+		return enoent
+	}
+	return eio
+}
+
+func readAll(ctx context.Context, r plugin.Readable) ([]byte, error) {
+	content, err := plugin.Open(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := content.(io.Closer); ok {
+		defer closer.Close()
+	}
+	data := make([]byte, content.Size())
+	if _, err := content.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// qidOf returns the entry's qid. Its path is derived from the entry's path
+// since that's what identifies an entry.
+func qidOf(p string, entry plugin.Entry) qid {
+	h := fnv.New64a()
+	h.Write([]byte(p))
+	q := qid{typ: qtfile, path: h.Sum64()}
+	if plugin.ListAction().IsSupportedOn(entry) {
+		q.typ = qtdir
+	}
+	return q
+}
+
+// statOf returns the entry's stat. Like the FUSE filesystem, it falls back
+// to sensible defaults for the attributes that the plugin doesn't know.
+func (c *conn) statOf(p string, entry plugin.Entry) stat {
+	attr := plugin.Attributes(entry)
+	isDir := plugin.ListAction().IsSupportedOn(entry)
+
+	var perms os.FileMode
+	if attr.HasMode() {
+		perms = attr.Mode().Perm()
+	}
+	if perms == 0 {
+		if isDir {
+			perms = 0555
+		} else {
+			if plugin.ReadAction().IsSupportedOn(entry) {
+				perms |= 0444
+			}
+			if plugin.WriteAction().IsSupportedOn(entry) {
+				perms |= 0200
+			}
+		}
+	}
+
+	s := stat{qid: qidOf(p, entry), mode: uint32(perms), name: path.Base(p), uid: c.owner, gid: c.owner}
+	if isDir {
+		s.mode |= dmdir
+	} else if attr.HasSize() {
+		s.length = attr.Size()
+	}
+	s.mtime = uint32(startTime.Unix())
+	if attr.HasMtime() {
+		s.mtime = uint32(attr.Mtime().Unix())
+	}
+	s.atime = s.mtime
+	if attr.HasAtime() {
+		s.atime = uint32(attr.Atime().Unix())
+	}
+	return s
+}
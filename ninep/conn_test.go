@@ -0,0 +1,397 @@
+package ninep
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type ninepTestsDir struct {
+	plugin.EntryBase
+	children []plugin.Entry
+}
+
+func newNinepTestsDir(name string, children ...plugin.Entry) *ninepTestsDir {
+	return &ninepTestsDir{EntryBase: plugin.NewEntry(name), children: children}
+}
+
+func (d *ninepTestsDir) Init(map[string]interface{}) error {
+	return nil
+}
+
+func (d *ninepTestsDir) List(context.Context) ([]plugin.Entry, error) {
+	return d.children, nil
+}
+
+func (d *ninepTestsDir) ChildSchemas() []*plugin.EntrySchema {
+	return nil
+}
+
+func (d *ninepTestsDir) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+type ninepTestsFile struct {
+	plugin.EntryBase
+	content []byte
+	writes  []string
+}
+
+func newNinepTestsFile(name string, content string) *ninepTestsFile {
+	f := &ninepTestsFile{EntryBase: plugin.NewEntry(name), content: []byte(content)}
+	f.DisableDefaultCaching()
+	return f
+}
+
+func (f *ninepTestsFile) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+func (f *ninepTestsFile) Open(context.Context) (plugin.SizedReader, error) {
+	return bytes.NewReader(f.content), nil
+}
+
+func (f *ninepTestsFile) Write(ctx context.Context, data []byte) error {
+	f.writes = append(f.writes, string(data))
+	f.content = data
+	return nil
+}
+
+type ninepTestsReadOnlyFile struct {
+	plugin.EntryBase
+}
+
+func (f *ninepTestsReadOnlyFile) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+func (f *ninepTestsReadOnlyFile) Open(context.Context) (plugin.SizedReader, error) {
+	return bytes.NewReader([]byte("read only")), nil
+}
+
+type ConnTestSuite struct {
+	suite.Suite
+	registry *plugin.Registry
+	file     *ninepTestsFile
+	conn     net.Conn
+	nextTag  uint16
+	nextFid  uint32
+	done     chan error
+}
+
+func (suite *ConnTestSuite) SetupTest() {
+	plugin.SetTestCache(datastore.NewMemCache())
+	suite.file = newNinepTestsFile("motd", "hello world")
+	readOnly := &ninepTestsReadOnlyFile{EntryBase: plugin.NewEntry("readonly")}
+	root := newNinepTestsDir("vms", newNinepTestsDir("vm", suite.file, readOnly))
+	root.SetTestID("/vms")
+	suite.registry = plugin.NewRegistry()
+	suite.NoError(suite.registry.RegisterPlugin(root, nil))
+	suite.connect("")
+
+	typ, d := suite.request(tversion, new(encoder).uint32(8192).string("9P2000"))
+	if suite.Equal(byte(rversion), typ) {
+		suite.Equal(uint32(8192), d.uint32())
+		suite.Equal("9P2000", d.string())
+	}
+	typ, _ = suite.request(tattach, new(encoder).uint32(0).uint32(^uint32(0)).string("wash").string(""))
+	suite.Equal(byte(rattach), typ)
+	suite.nextFid = 1
+}
+
+// connect connects suite.conn to a new connection whose clients must
+// authenticate with authToken
+func (suite *ConnTestSuite) connect(authToken string) {
+	serverConn, clientConn := net.Pipe()
+	suite.conn = clientConn
+	suite.done = make(chan error, 1)
+	go func() {
+		suite.done <- newConn(suite.registry, serverConn, authToken).serve(context.Background())
+		serverConn.Close()
+	}()
+}
+
+func (suite *ConnTestSuite) TearDownTest() {
+	suite.conn.Close()
+	suite.NoError(<-suite.done)
+	plugin.UnsetTestCache()
+}
+
+// request sends a request with the given body, and returns the type of the
+// reply along with a decoder for its body
+func (suite *ConnTestSuite) request(typ byte, body *encoder) (byte, *decoder) {
+	suite.nextTag++
+	if !suite.NoError(writeMessage(suite.conn, typ, suite.nextTag, body.buf)) {
+		suite.FailNow("could not send the request")
+	}
+	msg, err := readMessage(suite.conn, maxMessageSize)
+	if !suite.NoError(err) {
+		suite.FailNow("could not read the reply")
+	}
+	suite.Equal(suite.nextTag, msg.tag)
+	return msg.typ, &decoder{buf: msg.body}
+}
+
+func (suite *ConnTestSuite) requestOK(replyTyp byte, typ byte, body *encoder) *decoder {
+	respTyp, d := suite.request(typ, body)
+	if respTyp == rerror {
+		suite.FailNow("the request failed", d.string())
+	}
+	suite.Equal(replyTyp, respTyp)
+	return d
+}
+
+func (suite *ConnTestSuite) assertError(ename string, typ byte, body *encoder) {
+	respTyp, d := suite.request(typ, body)
+	if suite.Equal(byte(rerror), respTyp) {
+		suite.Equal(ename, d.string())
+	}
+}
+
+// walk walks a new fid from the root to the given names
+func (suite *ConnTestSuite) walk(names ...string) uint32 {
+	fid := suite.nextFid
+	suite.nextFid++
+	e := new(encoder).uint32(0).uint32(fid).uint16(uint16(len(names)))
+	for _, name := range names {
+		e.string(name)
+	}
+	d := suite.requestOK(rwalk, twalk, e)
+	suite.Equal(uint16(len(names)), d.uint16())
+	return fid
+}
+
+func (suite *ConnTestSuite) stat(fid uint32) stat {
+	d := suite.requestOK(rstat, tstat, new(encoder).uint32(fid))
+	d.uint16()
+	s := d.stat()
+	suite.NoError(d.err)
+	return s
+}
+
+func (suite *ConnTestSuite) open(fid uint32, mode uint8) {
+	suite.requestOK(ropen, topen, new(encoder).uint32(fid).uint8(mode))
+}
+
+func (suite *ConnTestSuite) read(fid uint32, offset uint64, count uint32) []byte {
+	d := suite.requestOK(rread, tread, new(encoder).uint32(fid).uint64(offset).uint32(count))
+	return d.next(int(d.uint32()))
+}
+
+// writeOf returns the body of a request that writes data to the start of fid
+func writeOf(fid uint32, data string) *encoder {
+	e := new(encoder).uint32(fid).uint64(0).uint32(uint32(len(data)))
+	e.buf = append(e.buf, data...)
+	return e
+}
+
+func (suite *ConnTestSuite) TestAttachRequiresTheAuthToken() {
+	suite.conn.Close()
+	suite.NoError(<-suite.done)
+	suite.connect("token")
+	suite.requestOK(rversion, tversion, new(encoder).uint32(8192).string("9P2000"))
+
+	attach := func(fid uint32, afid uint32) *encoder {
+		return new(encoder).uint32(fid).uint32(afid).string("wash").string("")
+	}
+	suite.assertError(eacces, tattach, attach(0, ^uint32(0)))
+
+	// A wrong token's refused
+	d := suite.requestOK(rauth, tauth, new(encoder).uint32(1).string("wash").string(""))
+	suite.Equal(uint8(qtauth), d.qid().typ)
+	suite.requestOK(rwrite, twrite, writeOf(1, "wrong"))
+	suite.assertError(eacces, tattach, attach(0, 1))
+	suite.requestOK(rclunk, tclunk, new(encoder).uint32(1))
+
+	// The auth fid can't be used like a file
+	suite.requestOK(rauth, tauth, new(encoder).uint32(1).string("wash").string(""))
+	suite.assertError(ebadfid, twalk, new(encoder).uint32(1).uint32(2).uint16(0))
+	suite.assertError(ebadfid, tstat, new(encoder).uint32(1))
+
+	suite.requestOK(rwrite, twrite, writeOf(1, "token\n"))
+	suite.requestOK(rattach, tattach, attach(0, 1))
+	suite.requestOK(rstat, tstat, new(encoder).uint32(0))
+}
+
+func (suite *ConnTestSuite) TestAuthIsntRequiredWithoutAToken() {
+	suite.assertError("authentication is not required", tauth, new(encoder).uint32(1).string("wash").string(""))
+}
+
+func (suite *ConnTestSuite) TestVersionRejectsUnknownVersions() {
+	typ, d := suite.request(tversion, new(encoder).uint32(8192).string("9P1"))
+	if suite.Equal(byte(rversion), typ) {
+		d.uint32()
+		suite.Equal("unknown", d.string())
+	}
+	// Clients that ask for an extension of 9P2000 get 9P2000
+	typ, d = suite.request(tversion, new(encoder).uint32(8192).string("9P2000.u"))
+	if suite.Equal(byte(rversion), typ) {
+		d.uint32()
+		suite.Equal("9P2000", d.string())
+	}
+}
+
+func (suite *ConnTestSuite) TestStat() {
+	s := suite.stat(0)
+	suite.Equal("/", s.name)
+	suite.Equal(byte(qtdir), s.qid.typ)
+	suite.Equal(uint32(dmdir|0555), s.mode)
+
+	s = suite.stat(suite.walk("vms", "vm", "motd"))
+	suite.Equal("motd", s.name)
+	suite.Equal(byte(qtfile), s.qid.typ)
+	suite.Equal(uint32(0644), s.mode)
+
+	s = suite.stat(suite.walk("vms", "vm", "readonly"))
+	suite.Equal(uint32(0444), s.mode)
+}
+
+func (suite *ConnTestSuite) TestWalk() {
+	// Walking to the same entry returns the same qid
+	d := suite.requestOK(rwalk, twalk, new(encoder).uint32(0).uint32(1).uint16(3).string("vms").string("..").string("vms"))
+	if suite.Equal(uint16(3), d.uint16()) {
+		vms, root, vmsAgain := d.qid(), d.qid(), d.qid()
+		suite.Equal(vms, vmsAgain)
+		suite.NotEqual(vms, root)
+	}
+	suite.Equal("vms", suite.stat(1).name)
+
+	// A walk with no names clones the fid
+	d = suite.requestOK(rwalk, twalk, new(encoder).uint32(1).uint32(2).uint16(0))
+	suite.Equal(uint16(0), d.uint16())
+	suite.Equal("vms", suite.stat(2).name)
+
+	suite.assertError(einuse, twalk, new(encoder).uint32(0).uint32(2).uint16(0))
+	suite.assertError(ebadfid, twalk, new(encoder).uint32(42).uint32(3).uint16(0))
+}
+
+func (suite *ConnTestSuite) TestWalkToMissingEntries() {
+	suite.assertError(enoent, twalk, new(encoder).uint32(0).uint32(1).uint16(1).string("missing"))
+	motd := suite.walk("vms", "vm", "motd")
+	suite.assertError(enotdir, twalk, new(encoder).uint32(motd).uint32(motd+1).uint16(1).string("foo"))
+
+	// Partial walks return the qids of the entries that were found, and
+	// don't create the new fid
+	d := suite.requestOK(rwalk, twalk, new(encoder).uint32(0).uint32(motd+1).uint16(3).string("vms").string("missing").string("foo"))
+	suite.Equal(uint16(1), d.uint16())
+	suite.assertError(ebadfid, tstat, new(encoder).uint32(motd+1))
+}
+
+func (suite *ConnTestSuite) TestReadDirectory() {
+	fid := suite.walk("vms", "vm")
+	suite.open(fid, oread)
+
+	// Each read returns whole stats
+	data := suite.read(fid, 0, 100)
+	d := &decoder{buf: data}
+	suite.Equal("motd", d.stat().name)
+	suite.Empty(d.buf)
+
+	offset := uint64(len(data))
+	data = suite.read(fid, offset, 8192)
+	d = &decoder{buf: data}
+	suite.Equal("readonly", d.stat().name)
+	suite.Empty(d.buf)
+	offset += uint64(len(data))
+	suite.Empty(suite.read(fid, offset, 8192))
+
+	// Reading from the start restarts the listing
+	d = &decoder{buf: suite.read(fid, 0, 8192)}
+	suite.Equal("motd", d.stat().name)
+	suite.Equal("readonly", d.stat().name)
+
+	suite.assertError(eisdir, topen, new(encoder).uint32(suite.walk("vms")).uint8(owrite))
+}
+
+func (suite *ConnTestSuite) TestReadFile() {
+	fid := suite.walk("vms", "vm", "motd")
+	suite.assertError(ebadfid, tread, new(encoder).uint32(fid).uint64(0).uint32(5))
+	suite.open(fid, oread)
+	suite.Equal("hello", string(suite.read(fid, 0, 5)))
+	suite.Equal(" world", string(suite.read(fid, 5, 100)))
+	suite.Empty(suite.read(fid, 11, 100))
+	suite.requestOK(rclunk, tclunk, new(encoder).uint32(fid))
+	suite.Empty(suite.file.writes)
+
+	fid = suite.walk("vms", "vm", "motd")
+	suite.open(fid, oread)
+	suite.assertError(eacces, twrite, new(encoder).uint32(fid).uint64(0).uint32(0))
+}
+
+func (suite *ConnTestSuite) TestWriteFile() {
+	// Writes are buffered until the fid's clunked
+	fid := suite.walk("vms", "vm", "motd")
+	suite.open(fid, owrite|otrunc)
+	d := suite.requestOK(rwrite, twrite, new(encoder).uint32(fid).uint64(0).uint32(3).uint8('b').uint8('y').uint8('e'))
+	suite.Equal(uint32(3), d.uint32())
+	suite.Empty(suite.file.writes)
+	suite.requestOK(rclunk, tclunk, new(encoder).uint32(fid))
+	suite.Equal([]string{"bye"}, suite.file.writes)
+
+	// Writes without otrunc keep the rest of the content
+	fid = suite.walk("vms", "vm", "motd")
+	suite.open(fid, ordwr)
+	suite.requestOK(rwrite, twrite, new(encoder).uint32(fid).uint64(1).uint32(1).uint8('Y'))
+	suite.Equal("bYe", string(suite.read(fid, 0, 100)))
+	suite.requestOK(rclunk, tclunk, new(encoder).uint32(fid))
+	suite.Equal([]string{"bye", "bYe"}, suite.file.writes)
+
+	suite.assertError(eacces, topen, new(encoder).uint32(suite.walk("vms", "vm", "readonly")).uint8(owrite))
+}
+
+func (suite *ConnTestSuite) TestWriteAndReadBeyondTheBounds() {
+	fid := suite.walk("vms", "vm", "motd")
+	suite.open(fid, ordwr|otrunc)
+	for _, offset := range []uint64{maxWriteSize, math.MaxUint64} {
+		suite.assertError(efbig, twrite, new(encoder).uint32(fid).uint64(offset).uint32(1).uint8('a'))
+	}
+	suite.assertError(einval, tread, new(encoder).uint32(fid).uint64(math.MaxUint64).uint32(5))
+	suite.requestOK(rclunk, tclunk, new(encoder).uint32(fid))
+
+	fid = suite.walk("vms", "vm", "motd")
+	suite.open(fid, oread)
+	suite.assertError(einval, tread, new(encoder).uint32(fid).uint64(math.MaxUint64).uint32(5))
+}
+
+func (suite *ConnTestSuite) TestWstat() {
+	unchanged := stat{mode: ^uint32(0), atime: ^uint32(0), mtime: ^uint32(0), length: ^uint64(0)}
+	fid := suite.walk("vms", "vm", "motd")
+	suite.requestOK(rwstat, twstat, suite.wstatBody(fid, unchanged))
+	suite.Empty(suite.file.writes)
+
+	// Truncating a file writes its empty content
+	truncate := unchanged
+	truncate.length = 0
+	suite.requestOK(rwstat, twstat, suite.wstatBody(fid, truncate))
+	suite.Equal([]string{""}, suite.file.writes)
+
+	touch := unchanged
+	touch.mtime = 0
+	suite.assertError(eopnotsupp, twstat, suite.wstatBody(fid, touch))
+	suite.assertError(eacces, twstat, suite.wstatBody(suite.walk("vms", "vm", "readonly"), truncate))
+}
+
+func (suite *ConnTestSuite) wstatBody(fid uint32, s stat) *encoder {
+	st := new(encoder).stat(s)
+	e := new(encoder).uint32(fid).uint16(uint16(len(st.buf)))
+	e.buf = append(e.buf, st.buf...)
+	return e
+}
+
+func (suite *ConnTestSuite) TestUnsupportedRequests() {
+	fid := suite.walk("vms", "vm", "motd")
+	suite.assertError(eopnotsupp, tremove, new(encoder).uint32(fid))
+	// Removes clunk the fid even though they fail
+	suite.assertError(ebadfid, tstat, new(encoder).uint32(fid))
+	suite.assertError("authentication is not required", tauth, new(encoder).uint32(1).string("wash").string(""))
+}
+
+func TestConn(t *testing.T) {
+	suite.Run(t, new(ConnTestSuite))
+}
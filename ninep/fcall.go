@@ -0,0 +1,237 @@
+package ninep
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The 9P2000 message types. See http://man.cat-v.org/plan_9/5/intro.
+const (
+	tversion = 100
+	rversion = 101
+	tauth    = 102
+	rauth    = 103
+	tattach  = 104
+	rattach  = 105
+	rerror   = 107
+	tflush   = 108
+	rflush   = 109
+	twalk    = 110
+	rwalk    = 111
+	topen    = 112
+	ropen    = 113
+	tread    = 116
+	rread    = 117
+	twrite   = 118
+	rwrite   = 119
+	tclunk   = 120
+	rclunk   = 121
+	tremove  = 122
+	tstat    = 124
+	rstat    = 125
+	twstat   = 126
+	rwstat   = 127
+)
+
+const version = "9P2000"
+
+// The open modes
+const (
+	oread  = 0
+	owrite = 1
+	ordwr  = 2
+	oexec  = 3
+	otrunc = 0x10
+)
+
+// The types of qids
+const (
+	qtdir  = 0x80
+	qtauth = 0x08
+	qtfile = 0x00
+)
+
+// dmdir is the mode bit of directories
+const dmdir = 0x80000000
+
+// maxMessageSize is the largest msize that's negotiated with clients. The
+// Linux client asks for 512KB by default.
+const maxMessageSize = 1024 * 1024
+
+// maxAuthTokenSize bounds what's buffered for the authentication fids
+const maxAuthTokenSize = 1024
+
+// minMessageSize is the smallest msize that's accepted. Read and write
+// replies have a header of 11 bytes, and stats are usually around 100.
+const minMessageSize = 256
+
+// ioHeaderSize is the size of the header of read and write messages
+const ioHeaderSize = 24
+
+// maxWriteSize bounds the content of the files that are opened for writing,
+// since it's buffered in memory until they're clunked
+const maxWriteSize = 64 * 1024 * 1024
+
+var errShortMessage = fmt.Errorf("the message is too short")
+
+type qid struct {
+	typ     byte
+	version uint32
+	path    uint64
+}
+
+// stat is a file's 9P stat. Times are in Unix seconds.
+type stat struct {
+	qid    qid
+	mode   uint32
+	atime  uint32
+	mtime  uint32
+	length uint64
+	name   string
+	uid    string
+	gid    string
+}
+
+// message is a decoded request
+type message struct {
+	typ  byte
+	tag  uint16
+	body []byte
+}
+
+func readMessage(r io.Reader, msize uint32) (message, error) {
+	var header [7]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return message{}, err
+	}
+	size := binary.LittleEndian.Uint32(header[:4])
+	if size < 7 || size > msize {
+		return message{}, fmt.Errorf("invalid message size %v", size)
+	}
+	body := make([]byte, size-7)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return message{}, err
+	}
+	return message{typ: header[4], tag: binary.LittleEndian.Uint16(header[5:7]), body: body}, nil
+}
+
+func writeMessage(w io.Writer, typ byte, tag uint16, body []byte) error {
+	buf := make([]byte, 7, 7+len(body))
+	binary.LittleEndian.PutUint32(buf, uint32(7+len(body)))
+	buf[4] = typ
+	binary.LittleEndian.PutUint16(buf[5:], tag)
+	_, err := w.Write(append(buf, body...))
+	return err
+}
+
+// decoder reads the fields of a message's body
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || len(d.buf) < n {
+		d.err = errShortMessage
+		return make([]byte, n)
+	}
+	v := d.buf[:n]
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) uint8() uint8 {
+	return d.next(1)[0]
+}
+
+func (d *decoder) uint16() uint16 {
+	return binary.LittleEndian.Uint16(d.next(2))
+}
+
+func (d *decoder) uint32() uint32 {
+	return binary.LittleEndian.Uint32(d.next(4))
+}
+
+func (d *decoder) uint64() uint64 {
+	return binary.LittleEndian.Uint64(d.next(8))
+}
+
+func (d *decoder) string() string {
+	n := d.uint16()
+	return string(d.next(int(n)))
+}
+
+func (d *decoder) qid() qid {
+	return qid{typ: d.uint8(), version: d.uint32(), path: d.uint64()}
+}
+
+func (d *decoder) stat() stat {
+	d.uint16() // size
+	d.uint16() // type
+	d.uint32() // dev
+	s := stat{qid: d.qid(), mode: d.uint32(), atime: d.uint32(), mtime: d.uint32(), length: d.uint64()}
+	s.name, s.uid, s.gid = d.string(), d.string(), d.string()
+	d.string() // muid
+	return s
+}
+
+// encoder builds a message's body
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint8(v uint8) *encoder {
+	e.buf = append(e.buf, v)
+	return e
+}
+
+func (e *encoder) uint16(v uint16) *encoder {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+	return e
+}
+
+func (e *encoder) uint32(v uint32) *encoder {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+	return e
+}
+
+func (e *encoder) uint64(v uint64) *encoder {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+	return e
+}
+
+func (e *encoder) string(v string) *encoder {
+	e.uint16(uint16(len(v)))
+	e.buf = append(e.buf, v...)
+	return e
+}
+
+func (e *encoder) qid(q qid) *encoder {
+	return e.uint8(q.typ).uint32(q.version).uint64(q.path)
+}
+
+// stat encodes s, including its leading size
+func (e *encoder) stat(s stat) *encoder {
+	body := new(encoder).
+		uint16(0). // type
+		uint32(0). // dev
+		qid(s.qid).
+		uint32(s.mode).
+		uint32(s.atime).
+		uint32(s.mtime).
+		uint64(s.length).
+		string(s.name).
+		string(s.uid).
+		string(s.gid).
+		string(s.uid) // muid
+	e.uint16(uint16(len(body.buf)))
+	e.buf = append(e.buf, body.buf...)
+	return e
+}
@@ -0,0 +1,82 @@
+package ninep
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// tcpPeerUID returns the UID of the user that owns the client's end of the
+// loopback TCP connection conn. It's found in /proc/net/tcp (or tcp6), which
+// lists the sockets of every local connection along with their owners.
+func tcpPeerUID(conn net.Conn) (int, bool) {
+	local, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return 0, false
+	}
+	remote, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok || !remote.IP.IsLoopback() {
+		return 0, false
+	}
+	// The client's socket is the one whose local address is the server's
+	// remote address and vice versa. IPv4 connections are listed in tcp6
+	// (with IPv4-mapped addresses) if they're accepted by an IPv6 socket.
+	if local.IP.To4() != nil {
+		if uid, ok := findSocketOwner("/proc/net/tcp", procNetAddr(remote, net.IPv4len), procNetAddr(local, net.IPv4len)); ok {
+			return uid, true
+		}
+	}
+	return findSocketOwner("/proc/net/tcp6", procNetAddr(remote, net.IPv6len), procNetAddr(local, net.IPv6len))
+}
+
+// procNetAddr formats addr like /proc/net/tcp does, i.e. as the hex of the
+// IP's 32-bit words in the host's byte order, then a colon and the hex port.
+// The IP's formatted as an IPv4 address if size is net.IPv4len.
+func procNetAddr(addr *net.TCPAddr, size int) string {
+	ip := addr.IP.To16()
+	if size == net.IPv4len {
+		ip = addr.IP.To4()
+	}
+	var words strings.Builder
+	for i := 0; i+4 <= len(ip); i += 4 {
+		fmt.Fprintf(&words, "%08X", nativeEndian.Uint32(ip[i:i+4]))
+	}
+	return fmt.Sprintf("%v:%04X", words.String(), addr.Port)
+}
+
+func findSocketOwner(table string, localAddr string, remoteAddr string) (int, bool) {
+	f, err := os.Open(table)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	// Skip the header
+	scanner.Scan()
+	for scanner.Scan() {
+		// The fields are sl, local_address, rem_address, st, tx/rx_queue,
+		// tr/tm->when, retrnsmt and uid
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || !strings.EqualFold(fields[1], localAddr) || !strings.EqualFold(fields[2], remoteAddr) {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[7])
+		return uid, err == nil
+	}
+	return 0, false
+}
+
+// nativeEndian is the host's byte order, which /proc/net/tcp prints the IPs'
+// words in
+var nativeEndian = func() binary.ByteOrder {
+	var word uint16 = 1
+	if *(*byte)(unsafe.Pointer(&word)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package ninep
+
+import "net"
+
+// tcpPeerUID always returns false since the owners of TCP connections can
+// only be looked up on Linux
+func tcpPeerUID(conn net.Conn) (int, bool) {
+	return 0, false
+}
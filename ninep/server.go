@@ -0,0 +1,160 @@
+// Package ninep serves the Wash filesystem over 9P2000, so that it can be
+// mounted without FUSE (e.g. inside containers) with the kernel's 9P client
+// or any other 9P client.
+package ninep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/puppetlabs/wash/internal/netserver"
+	"github.com/puppetlabs/wash/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultAddress returns the default address of the 9P server, which is a
+// Unix socket at <user_cache_dir>/wash/wash-9p.sock. A Unix socket's used by
+// default since only the current user can connect to it.
+func DefaultAddress() (string, error) {
+	cdir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return "unix:" + filepath.Join(cdir, "wash", "wash-9p.sock"), nil
+}
+
+// Serve9P starts serving the registry's entries over 9P. The address is
+// either a Unix socket ("unix:<path>") or a TCP address ("<host>:<port>").
+// Like fuse.ServeFuseFS, it returns a channel to initiate the shutdown (by
+// closing it) and a channel that's closed once the server's shutdown.
+//
+// Only the current user can connect to a Unix socket, but any local user can
+// connect to a TCP address, so TCP clients must authenticate with authToken
+// (i.e. the API's admin token). They do that by writing it to the fid that
+// they pass to Tauth, then attaching with that fid. On Linux, clients that
+// are run by the current user or by root (e.g. the kernel's 9P client, which
+// doesn't support authentication) are identified by their connection's owner
+// instead, so they don't need to authenticate.
+func Serve9P(registry *plugin.Registry, address string, authToken string) (chan<- context.Context, <-chan struct{}, error) {
+	isTCP := !strings.HasPrefix(address, "unix:")
+	if isTCP && authToken == "" {
+		return nil, nil, errors.New("9P clients that connect over TCP must authenticate, but there's no token to authenticate them with")
+	}
+	listener, err := listen(address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not listen on %v: %v", address, err)
+	}
+	log.Infof("9P: Listening on %v", address)
+	if !isTCP {
+		authToken = ""
+	}
+
+	stopCh := make(chan context.Context)
+	stoppedCh := make(chan struct{})
+	conns := netserver.New("9P", func(conn net.Conn) {
+		log.Infof("9P: Client connected from %v", conn.RemoteAddr())
+		if err := newConn(registry, conn, authTokenFor(conn, authToken)).serve(context.Background()); err != nil {
+			log.Debugf("9P: Connection from %v closed: %v", conn.RemoteAddr(), err)
+		}
+	})
+	go conns.Accept(listener)
+	go func() {
+		<-stopCh
+		conns.Shutdown(listener)
+		close(stoppedCh)
+	}()
+	return stopCh, stoppedCh, nil
+}
+
+// authTokenFor returns the token that the client at the other end of conn
+// must authenticate with. It's empty if the client doesn't need to
+// authenticate.
+func authTokenFor(conn net.Conn, authToken string) string {
+	if authToken == "" {
+		return ""
+	}
+	if uid, ok := tcpPeerUID(conn); ok && (uid == os.Getuid() || uid == 0) {
+		log.Debugf("9P: The client at %v is run by UID %v, so it doesn't need to authenticate", conn.RemoteAddr(), uid)
+		return ""
+	}
+	return authToken
+}
+
+func listen(address string) (net.Listener, error) {
+	if !strings.HasPrefix(address, "unix:") {
+		if err := checkLoopback(address); err != nil {
+			return nil, err
+		}
+		return net.Listen("tcp", address)
+	}
+	socket := strings.TrimPrefix(address, "unix:")
+	if err := os.MkdirAll(filepath.Dir(socket), 0750); err != nil {
+		return nil, err
+	}
+	// A previous server may have left its socket behind
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// The socket's created in a private (0700) directory and only moved
+	// into place once it's restricted to the current user, so other users
+	// can't connect to it in the meantime
+	dir, err := ioutil.TempDir(filepath.Dir(socket), ".wash-9p-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	privateSocket := filepath.Join(dir, "sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: privateSocket, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(privateSocket, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(privateSocket, socket); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	// The listener would unlink the private path when it's closed
+	listener.SetUnlinkOnClose(false)
+	return &unixListener{UnixListener: listener, socket: socket}, nil
+}
+
+// unixListener removes its socket when it's closed
+type unixListener struct {
+	*net.UnixListener
+	socket string
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	if rmErr := os.Remove(l.socket); rmErr != nil && !os.IsNotExist(rmErr) && err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// checkLoopback returns an error if the TCP address isn't a loopback
+// address. 9P doesn't encrypt its traffic, so the auth token (and everything
+// that Wash can access) would be exposed to the network otherwise.
+func checkLoopback(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return errors.New("9P connections are not encrypted, so the server can only listen on a Unix socket or a loopback address")
+}
@@ -0,0 +1,138 @@
+package ninep
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServerTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *ServerTestSuite) SetupTest() {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "wash-9p")
+	suite.NoError(err)
+}
+
+func (suite *ServerTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *ServerTestSuite) serve(address string) func() {
+	stopCh, stoppedCh, err := Serve9P(plugin.NewRegistry(), address, "token")
+	if !suite.NoError(err) {
+		suite.FailNow("could not start the server")
+	}
+	return func() {
+		close(stopCh)
+		<-stoppedCh
+	}
+}
+
+func (suite *ServerTestSuite) assertServes(network string, address string) {
+	conn, err := net.Dial(network, address)
+	if !suite.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	suite.NoError(writeMessage(conn, tversion, 0xFFFF, new(encoder).uint32(8192).string(version).buf))
+	msg, err := readMessage(conn, maxMessageSize)
+	if suite.NoError(err) {
+		suite.Equal(byte(rversion), msg.typ)
+	}
+}
+
+func (suite *ServerTestSuite) TestServesOverUnixSockets() {
+	socket := filepath.Join(suite.dir, "wash", "wash-9p.sock")
+	// A stale socket's replaced
+	suite.NoError(os.MkdirAll(filepath.Dir(socket), 0750))
+	suite.NoError(ioutil.WriteFile(socket, nil, 0600))
+
+	stop := suite.serve("unix:" + socket)
+	info, err := os.Stat(socket)
+	if suite.NoError(err) {
+		suite.Equal(os.FileMode(0600), info.Mode().Perm())
+	}
+	suite.assertServes("unix", socket)
+	// The private directory that the socket was created in is gone
+	files, err := ioutil.ReadDir(filepath.Dir(socket))
+	if suite.NoError(err) && suite.Len(files, 1) {
+		suite.Equal("wash-9p.sock", files[0].Name())
+	}
+
+	// Stopping the server disconnects its clients and removes the socket
+	conn, err := net.Dial("unix", socket)
+	if suite.NoError(err) {
+		defer conn.Close()
+		stop()
+		_, err = readMessage(conn, maxMessageSize)
+		suite.Error(err)
+	}
+	_, err = os.Stat(socket)
+	suite.True(os.IsNotExist(err))
+}
+
+func (suite *ServerTestSuite) TestServesOverTCP() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !suite.NoError(err) {
+		return
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	stop := suite.serve(address)
+	defer stop()
+	suite.assertServes("tcp", address)
+}
+
+func (suite *ServerTestSuite) TestAuthenticatesTCPClientsByTheirOwner() {
+	if runtime.GOOS != "linux" {
+		suite.T().Skip("the owners of TCP connections can only be looked up on Linux")
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !suite.NoError(err) {
+		return
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	stop := suite.serve(address)
+	defer stop()
+	conn, err := net.Dial("tcp", address)
+	if !suite.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	// The test's run by the server's user, so it can attach without
+	// authenticating
+	suite.NoError(writeMessage(conn, tattach, 1, new(encoder).uint32(0).uint32(^uint32(0)).string("wash").string("").buf))
+	msg, err := readMessage(conn, maxMessageSize)
+	if suite.NoError(err) {
+		suite.Equal(byte(rattach), msg.typ, string(msg.body))
+	}
+}
+
+func (suite *ServerTestSuite) TestRequiresATokenForTCP() {
+	_, _, err := Serve9P(plugin.NewRegistry(), "127.0.0.1:5640", "")
+	suite.Regexp("must authenticate", err)
+}
+
+func (suite *ServerTestSuite) TestRefusesNonLoopbackAddresses() {
+	for _, address := range []string{":5640", "0.0.0.0:5640", "192.168.1.1:5640", "example.com:5640"} {
+		_, _, err := Serve9P(plugin.NewRegistry(), address, "token")
+		suite.Regexp("only listen on a Unix socket or a loopback address", err)
+	}
+}
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
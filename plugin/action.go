@@ -1,11 +1,107 @@
 package plugin
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/puppetlabs/wash/cmd/version"
+)
+
 // Action represents a Wash action.
 type Action struct {
 	Name     string `json:"name"`
 	Protocol string `json:"protocol"`
 }
 
+// ActionDeprecation describes an action that's deprecated on an entry. Plugin
+// authors should use it to warn users about an action that will eventually
+// be removed (or replaced) instead of removing it outright.
+type ActionDeprecation struct {
+	// Message describes why the action's deprecated, and what users
+	// should do instead.
+	Message string `json:"message"`
+	// Since is the (plugin) version that deprecated the action. It is
+	// optional.
+	Since string `json:"since,omitempty"`
+	// RemovedIn is the (plugin) version that will remove the action. It
+	// is optional. Once the plugin reaches it, Wash no longer invokes the
+	// action (see IsRemovedIn).
+	RemovedIn string `json:"removed_in,omitempty"`
+}
+
+// IsRemovedIn returns true if the action's removed in the given (plugin)
+// version, i.e. if the version is at least RemovedIn. Versions are compared
+// by their dotted numeric components (e.g. "v1.10.0" is newer than "1.9"),
+// and a pre-release (e.g. "2.0.0-rc1") comes before its release. It returns
+// false if either version can't be parsed (e.g. a development build's
+// "unknown" version).
+func (d ActionDeprecation) IsRemovedIn(version string) bool {
+	removedIn, removedInPrerelease, ok := parseVersion(d.RemovedIn)
+	if !ok {
+		return false
+	}
+	v, prerelease, ok := parseVersion(version)
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(v) || i < len(removedIn); i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(removedIn) {
+			b = removedIn[i]
+		}
+		if a != b {
+			return a > b
+		}
+	}
+	return !prerelease || removedInPrerelease
+}
+
+// parseVersion parses a version like "v1.2.3" into its numeric components.
+// The returned prerelease bool is true if the version has a pre-release
+// suffix (e.g. "-rc1"). Build suffixes (e.g. "+abc") are ignored.
+func parseVersion(v string) (components []int, prerelease bool, ok bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, "-"); i >= 0 {
+		v, prerelease = v[:i], true
+	}
+	if v == "" {
+		return nil, false, false
+	}
+	parts := strings.Split(v, ".")
+	components = make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false, false
+		}
+		components[i] = n
+	}
+	return components, prerelease, true
+}
+
+// Warning returns a human-readable warning describing the deprecation of
+// the given action on the entry at path.
+func (d ActionDeprecation) Warning(path string, action string) string {
+	msg := fmt.Sprintf("the %v action on %v is deprecated", action, path)
+	if d.Since != "" {
+		msg += fmt.Sprintf(" since %v", d.Since)
+	}
+	if d.RemovedIn != "" {
+		msg += fmt.Sprintf(" and will be removed in %v", d.RemovedIn)
+	}
+	if d.Message != "" {
+		msg += ": " + d.Message
+	}
+	return msg
+}
+
 var actions = make(map[string]Action)
 
 func newAction(name string, protocol string) Action {
@@ -29,10 +125,46 @@ func (a Action) IsSupportedOn(entry Entry) bool {
 	return false
 }
 
+// IsDeprecatedOn returns the action's deprecation if the action's deprecated
+// on the specified entry. The returned bool is false if the action's not
+// deprecated.
+func (a Action) IsDeprecatedOn(entry Entry) (ActionDeprecation, bool) {
+	d, ok := entry.deprecatedActions()[a.Name]
+	return d, ok
+}
+
+// IsRemovedFrom returns the action's deprecation if the action was removed
+// from the specified entry, i.e. if the entry's plugin reached the
+// deprecation's RemovedIn version. Removed actions aren't supported.
+func (a Action) IsRemovedFrom(entry Entry) (ActionDeprecation, bool) {
+	d, ok := a.IsDeprecatedOn(entry)
+	if !ok || !d.IsRemovedIn(pluginVersionOf(entry)) {
+		return ActionDeprecation{}, false
+	}
+	return d, true
+}
+
+// pluginVersionOf returns the version of the entry's plugin. External
+// plugins declare theirs in their root's version key, and core plugins are
+// versioned with Wash.
+func pluginVersionOf(entry Entry) string {
+	if ext, ok := entry.(interface{ pluginVersion() string }); ok {
+		return ext.pluginVersion()
+	}
+	return version.BuildVersion
+}
+
 var listAction = newAction("list", "Parent")
 var readAction = newAction("read", "Readable")
 var streamAction = newAction("stream", "Streamable")
 var execAction = newAction("exec", "Execable")
+var writeAction = newAction("write", "Writable")
+var deleteAction = newAction("delete", "Deletable")
+var createAction = newAction("create", "ChildCreator")
+var renameAction = newAction("rename", "Renamable")
+var signalAction = newAction("signal", "Signalable")
+var runAction = newAction("run", "Runnable")
+var telemetryAction = newAction("telemetry", "TelemetryReporter")
 
 // ListAction represents the list action
 func ListAction() Action {
@@ -54,6 +186,43 @@ func ExecAction() Action {
 	return execAction
 }
 
+// WriteAction represents the write action
+func WriteAction() Action {
+	return writeAction
+}
+
+// DeleteAction represents the delete action
+func DeleteAction() Action {
+	return deleteAction
+}
+
+// CreateAction represents the create action, which creates one of the entry's
+// children
+func CreateAction() Action {
+	return createAction
+}
+
+// RenameAction represents the rename action
+func RenameAction() Action {
+	return renameAction
+}
+
+// SignalAction represents the signal action
+func SignalAction() Action {
+	return signalAction
+}
+
+// RunAction represents the run action, which performs one of the entry's
+// custom actions (see CustomActionsOf)
+func RunAction() Action {
+	return runAction
+}
+
+// TelemetryAction represents the telemetry action
+func TelemetryAction() Action {
+	return telemetryAction
+}
+
 // Actions returns all of the available Wash actions as a map
 // of <action_name> => <action_object>.
 func Actions() map[string]Action {
@@ -67,11 +236,30 @@ func Actions() map[string]Action {
 }
 
 // SupportedActionsOf returns all of the given
-// entry's supported actions.
+// entry's supported actions. Deprecated actions that were removed from the
+// entry (see Action#IsRemovedFrom) aren't included.
 func SupportedActionsOf(entry Entry) []string {
+	actions := implementedActionsOf(entry)
+	if len(entry.deprecatedActions()) == 0 {
+		return actions
+	}
+	supported := actions[:0]
+	for _, name := range actions {
+		if _, removed := (Action{Name: name}).IsRemovedFrom(entry); !removed {
+			supported = append(supported, name)
+		}
+	}
+	return supported
+}
+
+func implementedActionsOf(entry Entry) []string {
 	switch t := entry.(type) {
 	case externalPlugin:
-		return t.supportedMethods()
+		actions := t.supportedMethods()
+		if len(CustomActionsOf(entry)) > 0 {
+			actions = append(actions, RunAction().Name)
+		}
+		return actions
 	default:
 		actions := make([]string, 0)
 
@@ -90,7 +278,52 @@ func SupportedActionsOf(entry Entry) []string {
 		if _, ok := entry.(Execable); ok {
 			actions = append(actions, ExecAction().Name)
 		}
+		if _, ok := entry.(Writable); ok {
+			actions = append(actions, WriteAction().Name)
+		}
+		if _, ok := entry.(Deletable); ok {
+			actions = append(actions, DeleteAction().Name)
+		}
+		if _, ok := entry.(ChildCreator); ok {
+			actions = append(actions, CreateAction().Name)
+		}
+		if _, ok := entry.(Renamable); ok {
+			actions = append(actions, RenameAction().Name)
+		}
+		if _, ok := entry.(Signalable); ok {
+			actions = append(actions, SignalAction().Name)
+		}
+		if len(CustomActionsOf(entry)) > 0 {
+			actions = append(actions, RunAction().Name)
+		}
+		if _, ok := entry.(TelemetryReporter); ok {
+			actions = append(actions, TelemetryAction().Name)
+		}
 
 		return actions
 	}
 }
+
+// CustomActionsOf returns the names of the given entry's custom actions. It
+// returns nil if the entry doesn't have any.
+func CustomActionsOf(entry Entry) []string {
+	r, ok := entry.(Runnable)
+	if !ok {
+		return nil
+	}
+	return r.CustomActions()
+}
+
+// DeprecatedActionsOf returns all of the given entry's deprecated
+// actions as a map of <action_name> => <deprecation>.
+func DeprecatedActionsOf(entry Entry) map[string]ActionDeprecation {
+	deprecations := entry.deprecatedActions()
+	if len(deprecations) == 0 {
+		return nil
+	}
+	mp := make(map[string]ActionDeprecation, len(deprecations))
+	for k, v := range deprecations {
+		mp[k] = v
+	}
+	return mp
+}
@@ -2,7 +2,11 @@ package plugin
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/puppetlabs/wash/activity"
 )
@@ -22,19 +26,161 @@ func Open(ctx context.Context, r Readable) (SizedReader, error) {
 }
 
 // Stream is a wrapper to s#Stream. Use it when you need to report a 'Stream'
-// invocation to analytics. Otherwise, use s#Stream
+// invocation to analytics. Otherwise, use s#Stream. Concurrent calls on the
+// same entry share a single s#Stream, whose output is fanned out to each of
+// their readers.
 func Stream(ctx context.Context, s Streamable) (io.ReadCloser, error) {
 	submitMethodInvocation(ctx, s, "Stream")
-	return s.Stream(ctx)
+	defer trackLatency(ctx, s, StreamAction().Name, time.Now())
+	if s.id() == "" {
+		// s isn't a plugin entry (e.g. it's a local file), so there's no ID to
+		// share its stream by
+		return s.Stream(ctx)
+	}
+	return subscribeToStream(ctx, s)
+}
+
+// StreamFrom is like Stream, except that s's stream starts at pos. Resumed
+// streams aren't shared since each of their clients starts at its own
+// position.
+func StreamFrom(ctx context.Context, s ResumableStreamable, pos StreamPosition) (io.ReadCloser, error) {
+	submitMethodInvocation(ctx, s, "Stream")
+	defer trackLatency(ctx, s, StreamAction().Name, time.Now())
+	return s.StreamFrom(ctx, pos)
 }
 
 // Exec is a wrapper to e#Exec. Use it when you need to report an 'Exec'
 // invocation to analytics. Otherwise, use e#Exec.
 func Exec(ctx context.Context, e Execable, cmd string, args []string, opts ExecOptions) (ExecCommand, error) {
 	submitMethodInvocation(ctx, e, "Exec")
+	defer trackLatency(ctx, e, ExecAction().Name, time.Now())
 	return e.Exec(ctx, cmd, args, opts)
 }
 
+// Write is a wrapper to w#Write. Use it when you need to report a 'Write'
+// invocation to analytics. Otherwise, use w#Write. A successful write clears
+// w's cached results so that its new content is read back, and notifies the
+// OnContentChange listeners.
+func Write(ctx context.Context, w Writable, data []byte) error {
+	submitMethodInvocation(ctx, w, "Write")
+	defer trackLatency(ctx, w, WriteAction().Name, time.Now())
+	if err := w.Write(ctx, data); err != nil {
+		return err
+	}
+	if w.id() == "" {
+		return nil
+	}
+	if cache != nil {
+		if _, err := ClearCacheFor(w.id()); err != nil {
+			activity.Warnf(ctx, "could not clear the cache for %v: %v", w.id(), err)
+		}
+	}
+	notifyContentChange(w.id())
+	return nil
+}
+
+// Delete is a wrapper to d#Delete. Use it when you need to report a 'Delete'
+// invocation to analytics. Otherwise, use d#Delete. A successful delete clears
+// the cached results of d's parent so that d's no longer listed.
+func Delete(ctx context.Context, d Deletable) error {
+	submitMethodInvocation(ctx, d, "Delete")
+	defer trackLatency(ctx, d, DeleteAction().Name, time.Now())
+	if err := d.Delete(ctx); err != nil {
+		return err
+	}
+	if cache != nil && d.id() != "" {
+		if _, err := ClearCacheFor(path.Dir(d.id())); err != nil {
+			activity.Warnf(ctx, "could not clear the cache for %v: %v", path.Dir(d.id()), err)
+		}
+	}
+	return nil
+}
+
+// CreateChild is a wrapper to p#CreateChild. Use it when you need to report a
+// 'CreateChild' invocation to analytics. Otherwise, use p#CreateChild. The
+// created child's ID is set, and a successful creation clears the cached
+// results of p so that the child is listed.
+func CreateChild(ctx context.Context, p ChildCreator, name string, isParent bool) (Entry, error) {
+	submitMethodInvocation(ctx, p, "CreateChild")
+	defer trackLatency(ctx, p, CreateAction().Name, time.Now())
+	child, err := p.CreateChild(ctx, name, isParent)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil && p.id() != "" {
+		if _, err := ClearCacheFor(p.id()); err != nil {
+			activity.Warnf(ctx, "could not clear the cache for %v: %v", p.id(), err)
+		}
+	}
+	if child == nil {
+		return nil, fmt.Errorf("the creation of %v in %v didn't return the child", name, p.id())
+	}
+	if cname := CName(child); cname != name {
+		return nil, fmt.Errorf("the creation of %v in %v returned the child %v instead", name, p.id(), cname)
+	}
+	if isParent && !ListAction().IsSupportedOn(child) {
+		return nil, fmt.Errorf("the creation of %v in %v returned a child that isn't a parent", name, p.id())
+	}
+	child.setID(strings.TrimRight(p.id(), "/") + "/" + name)
+	passAlongWrappedTypes(p, child)
+	return child, nil
+}
+
+// Rename is a wrapper to r#Rename. Use it when you need to report a 'Rename'
+// invocation to analytics. Otherwise, use r#Rename. A successful rename clears
+// the cached results of r's parent so that r's listed under its new name.
+func Rename(ctx context.Context, r Renamable, newName string) error {
+	submitMethodInvocation(ctx, r, "Rename")
+	defer trackLatency(ctx, r, RenameAction().Name, time.Now())
+	if newName == "" || strings.Contains(newName, "/") {
+		return fmt.Errorf("could not rename %v: %q is not a valid name", r.id(), newName)
+	}
+	if err := r.Rename(ctx, newName); err != nil {
+		return err
+	}
+	if cache != nil && r.id() != "" {
+		if _, err := ClearCacheFor(path.Dir(r.id())); err != nil {
+			activity.Warnf(ctx, "could not clear the cache for %v: %v", path.Dir(r.id()), err)
+		}
+	}
+	return nil
+}
+
+// Signal is a wrapper to s#Signal. Use it when you need to report a 'Signal'
+// invocation to analytics. Otherwise, use s#Signal. A successful signal clears
+// the cached results of s's parent so that s's new state is listed.
+func Signal(ctx context.Context, s Signalable, signal string) error {
+	submitMethodInvocation(ctx, s, "Signal")
+	defer trackLatency(ctx, s, SignalAction().Name, time.Now())
+	if err := s.Signal(ctx, signal); err != nil {
+		return err
+	}
+	if cache != nil && s.id() != "" {
+		if _, err := ClearCacheFor(path.Dir(s.id())); err != nil {
+			activity.Warnf(ctx, "could not clear the cache for %v: %v", path.Dir(s.id()), err)
+		}
+	}
+	return nil
+}
+
+// Run performs the entry's custom action with the given args, and returns its
+// output. Like Signal, it clears the entry's parent's cached results since
+// actions like reboot change the entry's state.
+func Run(ctx context.Context, r Runnable, action string, args []string) ([]byte, error) {
+	submitMethodInvocation(ctx, r, "Run")
+	defer trackLatency(ctx, r, RunAction().Name, time.Now())
+	output, err := r.Run(ctx, action, args)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil && r.id() != "" {
+		if _, err := ClearCacheFor(path.Dir(r.id())); err != nil {
+			activity.Warnf(ctx, "could not clear the cache for %v: %v", path.Dir(r.id()), err)
+		}
+	}
+	return output, nil
+}
+
 func submitMethodInvocation(ctx context.Context, e Entry, method string) {
 	isCorePluginEntry := e.Schema() != nil
 	if !isCorePluginEntry {
@@ -0,0 +1,38 @@
+package plugin
+
+import "context"
+
+// attributesOpName is the name that refreshed attributes are cached under
+const attributesOpName = "Attributes"
+
+// RefreshesAttributes returns true if the entry's attributes can be refreshed
+// without re-listing its parent
+func RefreshesAttributes(e Entry) bool {
+	switch t := e.(type) {
+	case externalPlugin:
+		for _, method := range t.supportedMethods() {
+			if method == "attributes" {
+				return true
+			}
+		}
+		return false
+	default:
+		_, ok := e.(AttrRefresher)
+		return ok
+	}
+}
+
+// CachedAttributes returns the refreshed attributes of parent's child r. They
+// describe the same thing as the attributes that parent lists, so they're
+// cached for as long as parent's listing would be. Callers should only use it
+// once parent's listing has expired (see IsCached); otherwise, the listed
+// attributes are just as fresh.
+func CachedAttributes(ctx context.Context, parent Parent, r AttrRefresher) (EntryAttributes, error) {
+	attr, err := cachedOp(ctx, attributesOpName, r, parent.getTTLOf(ListOp), func() (interface{}, error) {
+		return r.RefreshAttributes(ctx)
+	}, nil)
+	if err != nil {
+		return EntryAttributes{}, err
+	}
+	return attr.(EntryAttributes), nil
+}
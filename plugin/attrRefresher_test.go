@@ -0,0 +1,103 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/stretchr/testify/suite"
+)
+
+type attrRefresherTestsParent struct {
+	EntryBase
+}
+
+func (p *attrRefresherTestsParent) List(ctx context.Context) ([]Entry, error) {
+	return nil, nil
+}
+
+func (p *attrRefresherTestsParent) ChildSchemas() []*EntrySchema {
+	return nil
+}
+
+func (p *attrRefresherTestsParent) Schema() *EntrySchema {
+	return nil
+}
+
+type attrRefresherTestsEntry struct {
+	EntryBase
+	refreshes int
+}
+
+func (e *attrRefresherTestsEntry) RefreshAttributes(ctx context.Context) (EntryAttributes, error) {
+	e.refreshes++
+	var attr EntryAttributes
+	attr.SetSize(uint64(e.refreshes))
+	return attr, nil
+}
+
+func (e *attrRefresherTestsEntry) Schema() *EntrySchema {
+	return nil
+}
+
+type AttrRefresherTestSuite struct {
+	suite.Suite
+}
+
+func (suite *AttrRefresherTestSuite) SetupTest() {
+	SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *AttrRefresherTestSuite) TearDownTest() {
+	UnsetTestCache()
+}
+
+func (suite *AttrRefresherTestSuite) newEntries() (*attrRefresherTestsParent, *attrRefresherTestsEntry) {
+	parent := &attrRefresherTestsParent{EntryBase: NewEntry("parent")}
+	parent.SetTestID("/parent")
+	entry := &attrRefresherTestsEntry{EntryBase: NewEntry("file")}
+	entry.SetTestID("/parent/file")
+	return parent, entry
+}
+
+func (suite *AttrRefresherTestSuite) TestRefreshesAttributes() {
+	_, entry := suite.newEntries()
+	suite.True(RefreshesAttributes(entry))
+	suite.False(RefreshesAttributes(&attrRefresherTestsParent{EntryBase: NewEntry("parent")}))
+}
+
+func (suite *AttrRefresherTestSuite) TestCachedAttributesAreCachedForTheParentsListTTL() {
+	parent, entry := suite.newEntries()
+	parent.SetTTLOf(ListOp, time.Hour)
+	for i := 0; i < 2; i++ {
+		attr, err := CachedAttributes(context.Background(), parent, entry)
+		if suite.NoError(err) {
+			suite.Equal(uint64(1), attr.Size())
+		}
+	}
+	suite.Equal(1, entry.refreshes)
+
+	// Clearing the entry's cache refreshes them again
+	_, err := ClearCacheFor("/parent/file")
+	suite.NoError(err)
+	attr, err := CachedAttributes(context.Background(), parent, entry)
+	if suite.NoError(err) {
+		suite.Equal(uint64(2), attr.Size())
+	}
+}
+
+func (suite *AttrRefresherTestSuite) TestCachedAttributesArentCachedIfTheParentsListingIsnt() {
+	parent, entry := suite.newEntries()
+	parent.DisableCachingFor(ListOp)
+	for i := 1; i <= 2; i++ {
+		attr, err := CachedAttributes(context.Background(), parent, entry)
+		if suite.NoError(err) {
+			suite.Equal(uint64(i), attr.Size())
+		}
+	}
+}
+
+func TestAttrRefresher(t *testing.T) {
+	suite.Run(t, new(AttrRefresherTestSuite))
+}
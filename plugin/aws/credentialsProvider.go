@@ -0,0 +1,62 @@
+package aws
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// credentialsRenewalWindow is how long before a profile's credentials expire
+// that they're renewed
+const credentialsRenewalWindow = 5 * time.Minute
+
+// credentialsProvider is a credentials.Provider implementation that retrieves a
+// profile's credentials from a plugin.Credentials. That way, the profile's
+// concurrent requests share a single refresh when its credentials expire, and
+// the credentials are renewed before they expire.
+type credentialsProvider struct {
+	credentials *plugin.Credentials
+}
+
+// newCredentialsProvider returns a provider for the profile's credentials,
+// which are retrieved from creds. expire forces creds to retrieve new
+// credentials when they're renewed.
+func newCredentialsProvider(profile string, creds *credentials.Credentials, expire func()) *credentialsProvider {
+	renewing := false
+	refresh := func(ctx context.Context) (plugin.Credential, error) {
+		// The first refresh can use the cached credentials. Later refreshes
+		// happen because they're about to expire, so they need new ones.
+		if renewing {
+			expire()
+		}
+		value, err := creds.Get()
+		if err != nil {
+			return plugin.Credential{}, err
+		}
+		renewing = true
+		// Credentials whose provider doesn't support expiration never expire
+		expiresAt, _ := creds.ExpiresAt()
+		return plugin.Credential{Value: value, ExpiresAt: expiresAt}, nil
+	}
+	return &credentialsProvider{
+		credentials: plugin.RegisterCredentials("aws/"+profile, credentialsRenewalWindow, refresh),
+	}
+}
+
+// Retrieve implements the Provider interface
+func (p *credentialsProvider) Retrieve() (credentials.Value, error) {
+	credential, err := p.credentials.Get(context.Background())
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	return credential.Value.(credentials.Value), nil
+}
+
+// IsExpired implements the Provider interface. The plugin.Credentials tracks
+// the credentials' expiration, so this always returns true to ensure that
+// every request goes through it.
+func (p *credentialsProvider) IsExpired() bool {
+	return true
+}
@@ -17,7 +17,7 @@ type ec2Dir struct {
 	entries []plugin.Entry
 }
 
-func newEC2Dir(session *session.Session) *ec2Dir {
+func newEC2Dir(session *session.Session, regions []string) *ec2Dir {
 	ec2Dir := &ec2Dir{
 		EntryBase: plugin.NewEntry("ec2"),
 	}
@@ -26,7 +26,7 @@ func newEC2Dir(session *session.Session) *ec2Dir {
 	ec2Dir.client = ec2Client.New(session)
 
 	ec2Dir.entries = []plugin.Entry{
-		newEC2InstancesDir(ec2Dir.session, ec2Dir.client),
+		newEC2InstancesDir(ec2Dir.session, ec2Dir.client, regions),
 	}
 
 	return ec2Dir
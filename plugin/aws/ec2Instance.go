@@ -151,10 +151,32 @@ func getAttributes(inst *ec2Client.Instance) plugin.EntryAttributes {
 		SetCrtime(crtime).
 		SetMtime(mtime).
 		SetMeta(meta)
+	if lifecycle, ok := ec2InstanceLifecycle(inst); ok {
+		attr.SetLifecycle(lifecycle)
+	}
 
 	return attr
 }
 
+func ec2InstanceLifecycle(inst *ec2Client.Instance) (plugin.Lifecycle, bool) {
+	if inst.State == nil {
+		return "", false
+	}
+	// The high byte of the state's code is for internal use by AWS
+	switch awsSDK.Int64Value(inst.State.Code) & 0xff {
+	case EC2InstancePendingState:
+		return plugin.LifecycleProvisioning, true
+	case EC2InstanceRunningState:
+		return plugin.LifecycleRunning, true
+	case EC2InstanceShuttingDownState, EC2InstanceStopping:
+		return plugin.LifecycleStopping, true
+	case EC2InstanceTerminated, EC2InstanceStopped:
+		return plugin.LifecycleTerminated, true
+	default:
+		return "", false
+	}
+}
+
 func (inst *ec2Instance) Schema() *plugin.EntrySchema {
 	return plugin.
 		NewEntrySchema(inst, "instance").
@@ -253,6 +275,11 @@ func (inst *ec2Instance) checkLatestConsoleOutput(ctx context.Context) (*ec2Inst
 	return nil, fmt.Errorf("could not access the latest console log: %v", err)
 }
 
+// HonoredExecOptions implements plugin.ExecOptionHonorer
+func (inst *ec2Instance) HonoredExecOptions() []string {
+	return transport.SSHExecOptions
+}
+
 func (inst *ec2Instance) Exec(ctx context.Context, cmd string, args []string, opts plugin.ExecOptions) (plugin.ExecCommand, error) {
 	meta, err := inst.Metadata(ctx)
 	if err != nil {
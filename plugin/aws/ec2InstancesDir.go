@@ -19,14 +19,31 @@ type ec2InstancesDir struct {
 	plugin.EntryBase
 	session *session.Session
 	client  *ec2Client.EC2
+	// regionalSessions and regionalClients are keyed by region. They're
+	// only set if the instances are listed across multiple regions. Each
+	// region's instances get its session so that the clients they create
+	// from it are in their region too.
+	regionalSessions map[string]*session.Session
+	regionalClients  map[string]*ec2Client.EC2
+	regions          []string
 }
 
-func newEC2InstancesDir(session *session.Session, client *ec2Client.EC2) *ec2InstancesDir {
+func newEC2InstancesDir(sess *session.Session, client *ec2Client.EC2, regions []string) *ec2InstancesDir {
 	ec2InstancesDir := &ec2InstancesDir{
 		EntryBase: plugin.NewEntry("instances"),
 	}
-	ec2InstancesDir.session = session
+	ec2InstancesDir.session = sess
 	ec2InstancesDir.client = client
+	if len(regions) > 0 {
+		ec2InstancesDir.regions = regions
+		ec2InstancesDir.regionalSessions = make(map[string]*session.Session, len(regions))
+		ec2InstancesDir.regionalClients = make(map[string]*ec2Client.EC2, len(regions))
+		for _, region := range regions {
+			regionalSession := sess.Copy(awsSDK.NewConfig().WithRegion(region))
+			ec2InstancesDir.regionalSessions[region] = regionalSession
+			ec2InstancesDir.regionalClients[region] = ec2Client.New(regionalSession)
+		}
+	}
 	return ec2InstancesDir
 }
 
@@ -37,16 +54,29 @@ func (is *ec2InstancesDir) Schema() *plugin.EntrySchema {
 func (is *ec2InstancesDir) ChildSchemas() []*plugin.EntrySchema {
 	return []*plugin.EntrySchema{
 		(&ec2Instance{}).Schema(),
+		(&plugin.ErrorEntry{}).Schema(),
 	}
 }
 
 func (is *ec2InstancesDir) List(ctx context.Context) ([]plugin.Entry, error) {
-	resp, err := is.client.DescribeInstancesWithContext(ctx, nil)
+	if len(is.regions) == 0 {
+		return is.listInstances(ctx, is.session, is.client)
+	}
+
+	// Regions that fail to list (e.g. because they're disabled for the account)
+	// are shown as error entries.
+	return plugin.ParallelList(ctx, is.regions, func(ctx context.Context, region string) ([]plugin.Entry, error) {
+		return is.listInstances(ctx, is.regionalSessions[region], is.regionalClients[region])
+	})
+}
+
+func (is *ec2InstancesDir) listInstances(ctx context.Context, sess *session.Session, client *ec2Client.EC2) ([]plugin.Entry, error) {
+	resp, err := client.DescribeInstancesWithContext(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	activity.Record(ctx, "Listing %v EC2 reservations", len(resp.Reservations))
+	activity.Record(ctx, "Listing %v EC2 reservations in %v", len(resp.Reservations), awsSDK.StringValue(client.Config.Region))
 
 	var entries []plugin.Entry
 	for _, reservation := range resp.Reservations {
@@ -62,8 +92,8 @@ func (is *ec2InstancesDir) List(ctx context.Context) ([]plugin.Entry, error) {
 			instances[i] = newEC2Instance(
 				ctx,
 				instance,
-				is.session,
-				is.client,
+				sess,
+				client,
 			)
 		}
 
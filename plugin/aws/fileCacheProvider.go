@@ -92,6 +92,13 @@ func (f *FileCacheProvider) Retrieve() (credentials.Value, error) {
 	return credential, err
 }
 
+// expire expires the cached credential and the underlying Provider's credential
+// so that the next Retrieve fetches a new one
+func (f *FileCacheProvider) expire() {
+	f.cachedCredential = cachedCredential{}
+	f.credentials.Expire()
+}
+
 // IsExpired implements the Provider interface, deferring to the cached credential first,
 // but fall back to the underlying Provider if it is expired.
 func (f *FileCacheProvider) IsExpired() bool {
@@ -3,8 +3,10 @@ package aws
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"time"
 
+	awsSDK "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/puppetlabs/wash/activity"
@@ -18,7 +20,7 @@ type profile struct {
 	resourcesDir []plugin.Entry
 }
 
-func newProfile(ctx context.Context, name string) (*profile, error) {
+func newProfile(ctx context.Context, name string, regions []string, httpClient *http.Client) (*profile, error) {
 	profile := &profile{
 		EntryBase: plugin.NewEntry(name),
 	}
@@ -34,6 +36,7 @@ func newProfile(ctx context.Context, name string) (*profile, error) {
 	// Create the session. SharedConfigEnable tells AWS to load the profile
 	// config from the ~/.aws/credentials and ~/.aws/config files
 	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:                  awsSDK.Config{HTTPClient: httpClient},
 		Profile:                 name,
 		AssumeRoleTokenProvider: tokenProvider,
 		// TODO: make this configurable. Different IAM configs may allow different durations.
@@ -45,11 +48,18 @@ func newProfile(ctx context.Context, name string) (*profile, error) {
 		return nil, err
 	}
 
-	if cacheProvider, err := newFileCacheProvider(ctx, name, sess.Config.Credentials); err == nil {
-		sess.Config.Credentials = credentials.NewCredentials(&cacheProvider)
+	creds := sess.Config.Credentials
+	expire := creds.Expire
+	if cacheProvider, err := newFileCacheProvider(ctx, name, creds); err == nil {
+		creds = credentials.NewCredentials(&cacheProvider)
+		expire = func() {
+			cacheProvider.expire()
+			creds.Expire()
+		}
 	} else {
 		activity.Record(ctx, "Unable to use cached credentials for %v profile: %v", name, err)
 	}
+	sess.Config.Credentials = credentials.NewCredentials(newCredentialsProvider(name, creds, expire))
 
 	// Force retrieving credentials now to expose errors early.
 	if _, err := sess.Config.Credentials.Get(); err != nil {
@@ -57,7 +67,7 @@ func newProfile(ctx context.Context, name string) (*profile, error) {
 	}
 
 	profile.session = sess
-	profile.resourcesDir = []plugin.Entry{newResourcesDir(sess)}
+	profile.resourcesDir = []plugin.Entry{newResourcesDir(sess, regions)}
 
 	return profile, nil
 }
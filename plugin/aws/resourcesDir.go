@@ -14,7 +14,7 @@ type resourcesDir struct {
 	resources []plugin.Entry
 }
 
-func newResourcesDir(session *session.Session) *resourcesDir {
+func newResourcesDir(session *session.Session, regions []string) *resourcesDir {
 	resourcesDir := &resourcesDir{
 		EntryBase: plugin.NewEntry("resources"),
 	}
@@ -23,7 +23,7 @@ func newResourcesDir(session *session.Session) *resourcesDir {
 
 	resourcesDir.resources = []plugin.Entry{
 		newS3Dir(resourcesDir.session),
-		newEC2Dir(resourcesDir.session),
+		newEC2Dir(resourcesDir.session, regions),
 	}
 
 	return resourcesDir
@@ -7,8 +7,10 @@ package aws
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,7 +22,9 @@ import (
 // Root of the AWS plugin
 type Root struct {
 	plugin.EntryBase
-	profs map[string]struct{}
+	profs      map[string]struct{}
+	regions    []string
+	httpClient *http.Client
 }
 
 func awsCredentialsFile() (string, error) {
@@ -81,8 +85,32 @@ func (r *Root) Init(cfg map[string]interface{}) error {
 		}
 	}
 
+	if regionsI, ok := cfg["regions"]; ok {
+		regions, ok := regionsI.([]interface{})
+		if !ok {
+			return fmt.Errorf("aws.regions config must be an array of strings, not %s", regionsI)
+		}
+
+		r.regions = make([]string, len(regions))
+		for i, elem := range regions {
+			region, ok := elem.(string)
+			if !ok {
+				return fmt.Errorf("aws.regions config must be an array of strings, not %s", regions)
+			}
+			r.regions[i] = region
+		}
+	}
+
+	httpOpts, err := plugin.HTTPOptionsFor(cfg)
+	if err != nil {
+		return fmt.Errorf("aws: %v", err)
+	}
+	if r.httpClient, err = httpOpts.NewClient(); err != nil {
+		return fmt.Errorf("aws: %v", err)
+	}
+
 	// Force authorizing profiles on startup
-	_, err := r.List(context.Background())
+	_, err = r.List(context.Background())
 	return err
 }
 
@@ -90,6 +118,7 @@ func (r *Root) Init(cfg map[string]interface{}) error {
 func (r *Root) ChildSchemas() []*plugin.EntrySchema {
 	return []*plugin.EntrySchema{
 		(&profile{}).Schema(),
+		(&plugin.ErrorEntry{}).Schema(),
 	}
 }
 
@@ -141,7 +170,7 @@ func (r *Root) List(ctx context.Context) ([]plugin.Entry, error) {
 		names[strings.TrimPrefix(section.Name(), "profile ")] = struct{}{}
 	}
 
-	profiles := make([]plugin.Entry, 0, len(names))
+	profileNames := make([]string, 0, len(names))
 	for name := range names {
 		if name == "DEFAULT" {
 			continue
@@ -151,14 +180,19 @@ func (r *Root) List(ctx context.Context) ([]plugin.Entry, error) {
 			continue
 		}
 
-		profile, err := newProfile(ctx, name)
-		if err != nil {
-			activity.Warnf(ctx, err.Error())
-			continue
-		}
-
-		profiles = append(profiles, profile)
+		profileNames = append(profileNames, name)
 	}
+	sort.Strings(profileNames)
 
-	return profiles, nil
+	// Creating a profile retrieves its credentials, so create them in parallel.
+	// Profiles whose credentials can't be retrieved are shown as error entries,
+	// even if none of them could be retrieved. Otherwise, Init would fail.
+	entries, _ := plugin.ParallelList(ctx, profileNames, func(ctx context.Context, name string) ([]plugin.Entry, error) {
+		profile, err := newProfile(ctx, name, r.regions, r.httpClient)
+		if err != nil {
+			return nil, err
+		}
+		return []plugin.Entry{profile}, nil
+	})
+	return entries, nil
 }
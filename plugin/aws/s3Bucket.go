@@ -185,6 +185,12 @@ func (b *s3Bucket) List(ctx context.Context) ([]plugin.Entry, error) {
 	return listObjects(ctx, b.client, b.Name(), "")
 }
 
+// CreateChild implements plugin.ChildCreator. Only prefixes can be created
+// (see createPrefix).
+func (b *s3Bucket) CreateChild(ctx context.Context, name string, isParent bool) (plugin.Entry, error) {
+	return createPrefix(ctx, b.client, b.Name(), "", name, isParent)
+}
+
 type bucketMetadata struct {
 	TagSet []*s3Client.Tag
 	Region string
@@ -128,6 +128,11 @@ func (s *s3ObjectReader) ReadAt(p []byte, off int64) (int, error) {
 	return io.ReadFull(content, p)
 }
 
+// SupportsPartialReads returns true because ReadAt fetches a range of the object.
+func (s *s3ObjectReader) SupportsPartialReads() bool {
+	return true
+}
+
 func (s *s3ObjectReader) Size() int64 {
 	attr := plugin.Attributes(s.o)
 	return int64(attr.Size())
@@ -2,12 +2,37 @@ package aws
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/puppetlabs/wash/activity"
 	"github.com/puppetlabs/wash/plugin"
 
+	awsSDK "github.com/aws/aws-sdk-go/aws"
 	s3Client "github.com/aws/aws-sdk-go/service/s3"
 )
 
+// createPrefix is a helper that creates the named prefix within the given
+// prefix. It's shared by s3Bucket and s3ObjectPrefix for the same reasons as
+// listObjects. S3 doesn't have directories, so the prefix is created as an
+// empty "<prefix><name>/" object (like the AWS console's folders). listObjects
+// skips that object, so it lists the new prefix as empty. Objects can't be
+// created since they can't be written to.
+func createPrefix(ctx context.Context, client *s3Client.S3, bucket string, prefix string, name string, isParent bool) (plugin.Entry, error) {
+	if !isParent {
+		return nil, fmt.Errorf("only prefixes can be created in S3 bucket %v, not objects", bucket)
+	}
+	key := prefix + name + "/"
+	activity.Record(ctx, "(Bucket %v): Creating prefix %v", bucket, key)
+	request := &s3Client.PutObjectInput{
+		Bucket: awsSDK.String(bucket),
+		Key:    awsSDK.String(key),
+	}
+	if _, err := client.PutObjectWithContext(ctx, request); err != nil {
+		return nil, err
+	}
+	return newS3ObjectPrefix(name, bucket, key, client), nil
+}
+
 // s3ObjectPrefix represents a common prefix shared by a group of
 // S3 objects. Prefixes allow one to view an S3 bucket's contents
 // hierarchically. See https://docs.aws.amazon.com/AmazonS3/latest/dev/ListingKeysHierarchy.html
@@ -45,3 +70,35 @@ func (d *s3ObjectPrefix) ChildSchemas() []*plugin.EntrySchema {
 func (d *s3ObjectPrefix) List(ctx context.Context) ([]plugin.Entry, error) {
 	return listObjects(ctx, d.client, d.bucket, d.prefix)
 }
+
+// CreateChild implements plugin.ChildCreator. Only prefixes can be created
+// (see createPrefix).
+func (d *s3ObjectPrefix) CreateChild(ctx context.Context, name string, isParent bool) (plugin.Entry, error) {
+	return createPrefix(ctx, d.client, d.bucket, d.prefix, name, isParent)
+}
+
+// Delete implements plugin.Deletable. Like rmdir, it only deletes empty
+// prefixes, i.e. prefixes whose only object is the empty "<prefix>" object
+// that createPrefix creates.
+func (d *s3ObjectPrefix) Delete(ctx context.Context) error {
+	request := &s3Client.ListObjectsInput{
+		Bucket:  awsSDK.String(d.bucket),
+		Prefix:  awsSDK.String(d.prefix),
+		MaxKeys: awsSDK.Int64(2),
+	}
+	resp, err := d.client.ListObjectsWithContext(ctx, request)
+	if err != nil {
+		return err
+	}
+	for _, o := range resp.Contents {
+		if awsSDK.StringValue(o.Key) != d.prefix {
+			return fmt.Errorf("prefix %v of S3 bucket %v isn't empty", d.prefix, d.bucket)
+		}
+	}
+	activity.Record(ctx, "(Bucket %v): Deleting prefix %v", d.bucket, d.prefix)
+	_, err = d.client.DeleteObjectWithContext(ctx, &s3Client.DeleteObjectInput{
+		Bucket: awsSDK.String(d.bucket),
+		Key:    awsSDK.String(d.prefix),
+	})
+	return err
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/metrics"
 )
 
 // KeyType is used to create a unique key type for looking up context values.
@@ -22,6 +23,9 @@ var cache datastore.Cache
 func InitCache() {
 	if notRunningTests() {
 		cache = datastore.NewMemCache()
+		popularity = newPopularityTracker()
+		go popularity.run(context.Background())
+		go pins.run(context.Background())
 	} else {
 		panic("InitCache can only be called in production. Tests should call SetTestCache instead.")
 	}
@@ -84,9 +88,51 @@ func ClearCacheFor(path string) ([]string, error) {
 		return nil, err
 	}
 
+	// Validated results are dropped too so that cleared results are refetched
+	// instead of revalidated
+	validatedResults.Delete(rx)
+	clearReadBlocks(rx)
 	return cache.Delete(rx), nil
 }
 
+// clearCachedAction removes the cached result of the action on the entry at
+// path. Unlike ClearCacheFor, the cached results of the entry's children are
+// kept, except for its missing children (see RecordMissing) and its partitions
+// if the action's list. The action can be list, read or metadata. If successful, returns an
+// array of deleted keys.
+func clearCachedAction(path string, action string) ([]string, error) {
+	var opName string
+	switch action {
+	case ListAction().Name:
+		opName = defaultOpCodeToNameMap[ListOp]
+	case ReadAction().Name:
+		opName = defaultOpCodeToNameMap[OpenOp]
+	case "metadata":
+		opName = defaultOpCodeToNameMap[MetadataOp]
+	default:
+		return nil, fmt.Errorf("the cached results of the %v action can't be cleared. Valid actions are %v", action, strings.Join(externalPluginInvalidationActions, ", "))
+	}
+	expr := "^" + opName + "::" + regexp.QuoteMeta(path) + "$"
+	if action == ListAction().Name {
+		// The entry's missing children may have been added, and its partitions
+		// are a view of its listing. Both are keyed by <path>/<segment>.
+		trimmed := regexp.QuoteMeta(strings.TrimRight(path, "/"))
+		expr = "^(?:" + opName + "::" + regexp.QuoteMeta(path) + "|(?:" + missingOpName + "|" + partitionsOpName + ")::" + trimmed + "/[^/]+)$"
+	}
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	validatedResults.Delete(rx)
+	deleted := cache.Delete(rx)
+	if action == ReadAction().Name {
+		// The blocks are keyed by <path>/<generation>/<index>
+		clearReadBlocks(regexp.MustCompile("^" + readBlockCategory + "::" + regexp.QuoteMeta(path) + "/[0-9]+/[0-9]+$"))
+	}
+	return deleted, nil
+}
+
 type opFunc func() (interface{}, error)
 
 // CachedOp caches the given op's result for the duration specified by the
@@ -113,7 +159,7 @@ func CachedOp(ctx context.Context, opName string, entry Entry, ttl time.Duration
 		panic("plugin.CachedOp: received a negative TTL")
 	}
 
-	return cachedOp(ctx, opName, entry, ttl, op)
+	return cachedOp(ctx, opName, entry, ttl, op, nil)
 }
 
 // DuplicateCNameErr represents a duplicate cname error, which
@@ -150,56 +196,122 @@ func (c DuplicateCNameErr) Error() string {
 // CachedList returns a map of <entry_cname> => <entry_object> to optimize
 // querying a specific entry.
 func CachedList(ctx context.Context, p Parent) (map[string]Entry, error) {
-	cachedEntries, err := cachedDefaultOp(ctx, ListOp, p, func() (interface{}, error) {
-		// Including the entry's ID allows plugin authors to use any Cached* methods defined on the
-		// children after their creation. This is necessary when the child's Cached* methods are used
-		// to calculate its attributes. Note that the child's ID is set in cachedOp.
-		entries, err := p.List(context.WithValue(ctx, parentID, p.id()))
+	if pe, ok := p.(*partitionEntry); ok {
+		// Partitions are views of their parent's cached listing, so their
+		// children are already cached and have IDs
+		return pe.children, nil
+	}
+
+	cachedEntries, err := cachedDefaultOp(ctx, ListOp, p, func(ctx context.Context) (interface{}, error) {
+		return listChildren(ctx, p, nil)
+	})
+	handleChildChanges(ctx, p.id())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cachedEntries.(map[string]Entry), nil
+}
+
+// listChildren lists p's children, keyed by their cname. If onEntry is set,
+// then it's called with each child once the child's ID is set. Parents that
+// support streaming lists are streamed in that case, so onEntry is called as
+// the children are listed.
+func listChildren(ctx context.Context, p Parent, onEntry func(Entry) error) (map[string]Entry, error) {
+	searchedEntries := make(map[string]Entry)
+	addEntry := newChildAdder(p, searchedEntries, onEntry)
+
+	// Including the entry's ID allows plugin authors to use any Cached* methods defined on the
+	// children after their creation. This is necessary when the child's Cached* methods are used
+	// to calculate its attributes. Note that the child's ID is set in cachedOp.
+	listCtx := context.WithValue(ctx, parentID, p.id())
+	if onEntry != nil && SupportsStreamingList(p) {
+		if err := p.(StreamingLister).ListStreaming(listCtx, addEntry); err != nil {
+			return nil, err
+		}
+	} else {
+		entries, err := p.List(listCtx)
 		if err != nil {
 			return nil, err
 		}
-
-		searchedEntries := make(map[string]Entry)
 		for _, entry := range entries {
-			cname := CName(entry)
-
-			if duplicateEntry, ok := searchedEntries[cname]; ok {
-				return nil, DuplicateCNameErr{
-					ParentID:                 p.id(),
-					FirstChildName:           duplicateEntry.name(),
-					FirstChildSlashReplacer:  duplicateEntry.slashReplacer(),
-					SecondChildName:          entry.name(),
-					SecondChildSlashReplacer: entry.slashReplacer(),
-					CName:                    cname,
-				}
+			if err := addEntry(entry); err != nil {
+				return nil, err
 			}
-			searchedEntries[cname] = entry
+		}
+	}
+	if err := addHelpEntry(p, addEntry); err != nil {
+		return nil, err
+	}
 
-			// Ensure ID is set on all entries so that we can use it for caching later in places
-			// where the context doesn't include the parent's ID.
-			id := strings.TrimRight(p.id(), "/") + "/" + cname
-			entry.setID(id)
+	recordListing(p.id(), searchedEntries)
+	return searchedEntries, nil
+}
 
-			passAlongWrappedTypes(p, entry)
+// newChildAdder returns a function that adds a child of p to searchedEntries,
+// keyed by its cname. It sets the child's ID before calling onEntry (if it's
+// set) with it.
+func newChildAdder(p Parent, searchedEntries map[string]Entry, onEntry func(Entry) error) func(Entry) error {
+	return func(entry Entry) error {
+		cname := CName(entry)
+		if _, ok := entry.(*HelpEntry); ok {
+			if _, ok := searchedEntries[cname]; ok {
+				return nil
+			}
 		}
 
-		return searchedEntries, nil
-	})
+		if duplicateEntry, ok := searchedEntries[cname]; ok {
+			return DuplicateCNameErr{
+				ParentID:                 p.id(),
+				FirstChildName:           duplicateEntry.name(),
+				FirstChildSlashReplacer:  duplicateEntry.slashReplacer(),
+				SecondChildName:          entry.name(),
+				SecondChildSlashReplacer: entry.slashReplacer(),
+				CName:                    cname,
+			}
+		}
+		searchedEntries[cname] = entry
 
-	if err != nil {
-		return nil, err
+		// Ensure ID is set on all entries so that we can use it for caching later in places
+		// where the context doesn't include the parent's ID.
+		id := strings.TrimRight(p.id(), "/") + "/" + cname
+		entry.setID(id)
+
+		passAlongWrappedTypes(p, entry)
+		if onEntry != nil {
+			return onEntry(entry)
+		}
+		return nil
 	}
+}
 
-	return cachedEntries.(map[string]Entry), nil
+// addHelpEntry adds a documented plugin root's help document via addEntry,
+// unless the root already has an entry with the same cname
+func addHelpEntry(p Parent, addEntry func(Entry) error) error {
+	if root, ok := p.(Root); ok {
+		if help := Help(root); help != "" {
+			return addEntry(newHelpEntry(help))
+		}
+	}
+	return nil
 }
 
 // CachedOpen caches a Readable object's Open method.
 // When using the reader returned by this method, use idempotent read operations
 // such as ReadAt or wrap it in a SectionReader. Using Read operations on the cached
 // reader will change it and make subsequent uses of the cached reader invalid.
+//
+// If the content supports partial reads, then it's also cached in blocks (see
+// the plugins.read_block_kb limit) so that re-reading a range of the content
+// doesn't re-fetch it.
 func CachedOpen(ctx context.Context, r Readable) (SizedReader, error) {
-	cachedContent, err := cachedDefaultOp(ctx, OpenOp, r, func() (interface{}, error) {
-		return r.Open(ctx)
+	cachedContent, err := cachedDefaultOp(ctx, OpenOp, r, func(ctx context.Context) (interface{}, error) {
+		content, err := r.Open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newBlockCachedReader(r, r.getTTLOf(OpenOp), content), nil
 	})
 
 	if err != nil {
@@ -209,10 +321,16 @@ func CachedOpen(ctx context.Context, r Readable) (SizedReader, error) {
 	return cachedContent.(SizedReader), nil
 }
 
-// CachedMetadata caches an entry's Metadata method
+// CachedMetadata caches an entry's Metadata method. The metadata includes
+// the entry's CommonMetadata (see NormalizeMetadata).
 func CachedMetadata(ctx context.Context, e Entry) (JSONObject, error) {
-	cachedMetadata, err := cachedDefaultOp(ctx, MetadataOp, e, func() (interface{}, error) {
-		return e.Metadata(ctx)
+	cachedMetadata, err := cachedDefaultOp(ctx, MetadataOp, e, func(ctx context.Context) (interface{}, error) {
+		metadata, err := e.Metadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		recordMetadata(e, metadata)
+		return NormalizeMetadata(e, metadata), nil
 	})
 
 	if err != nil {
@@ -222,16 +340,30 @@ func CachedMetadata(ctx context.Context, e Entry) (JSONObject, error) {
 	return cachedMetadata.(JSONObject), nil
 }
 
-// Common helper for CachedList, CachedOpen and CachedMetadata
-func cachedDefaultOp(ctx context.Context, opCode defaultOpCode, entry Entry, op opFunc) (interface{}, error) {
+// Common helper for CachedList, CachedOpen and CachedMetadata. Unlike CachedOp's
+// op, op takes a context so that hot entries can be refreshed in the background
+// after the request that accessed them is done.
+func cachedDefaultOp(ctx context.Context, opCode defaultOpCode, entry Entry, op func(context.Context) (interface{}, error)) (interface{}, error) {
 	opName := defaultOpCodeToNameMap[opCode]
 	ttl := entry.getTTLOf(opCode)
-
-	return cachedOp(ctx, opName, entry, ttl, op)
+	op = validatedOp(opName, entry, op)
+	op = faultyOp(opCode, entry, op)
+	op = trackedOp(opCode, entry, op)
+
+	refreshOp := func() (interface{}, error) {
+		refreshCtx, cancel := context.WithTimeout(DetachedContext(ctx), ttl)
+		defer cancel()
+		return op(refreshCtx)
+	}
+	return cachedOp(ctx, opName, entry, ttl, func() (interface{}, error) {
+		return op(ctx)
+	}, refreshOp)
 }
 
-// Common helper for CachedOp and cachedDefaultOp.
-func cachedOp(ctx context.Context, opName string, entry Entry, ttl time.Duration, op opFunc) (interface{}, error) {
+// Common helper for CachedOp and cachedDefaultOp. If refreshOp is set, then
+// it's used to refresh the op's cached result in the background if the entry
+// is hot.
+func cachedOp(ctx context.Context, opName string, entry Entry, ttl time.Duration, op opFunc, refreshOp opFunc) (interface{}, error) {
 	if cache == nil {
 		if notRunningTests() {
 			panic("The cache was not initialized. You can initialize the cache by invoking plugin.InitCache()")
@@ -254,5 +386,40 @@ func cachedOp(ctx context.Context, opName string, entry Entry, ttl time.Duration
 		}
 	}
 
-	return cache.GetOrUpdate(opName, entry.id(), ttl, false, op)
+	// Pinned results are refreshed by their pin instead of expiring, so
+	// their popularity doesn't affect their TTL
+	pinned := refreshOp != nil && pins.keepWarm(entry.id(), opName, refreshOp)
+	if popularity != nil {
+		score := popularity.record(entry.id())
+		if !pinned {
+			ttl = popularity.ttlFor(score, ttl)
+			if refreshOp != nil {
+				popularity.keepWarm(entry.id(), opName, ttl, refreshOp)
+			}
+		}
+	}
+	if pinned {
+		ttl = noExpiration
+	}
+
+	hit := true
+	defer func() {
+		metrics.RecordCacheLookup(hit)
+	}()
+	return cache.GetOrUpdate(opName, entry.id(), ttl, false, func() (interface{}, error) {
+		hit = false
+		value, err := op()
+		if err != nil && ctx.Err() != nil {
+			// The op was (likely) cancelled, so don't cache the error. Otherwise,
+			// a retried request would return the cancellation error until the
+			// TTL expires.
+			return nil, datastore.DoNotCache(err)
+		}
+		if IsRetryable(err) {
+			// The error's (likely) transient, so the next request should try
+			// again instead of returning it until the TTL expires
+			return nil, datastore.DoNotCache(err)
+		}
+		return value, err
+	})
 }
@@ -0,0 +1,161 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/datastore"
+)
+
+// Validators identify a version of an op's result, like HTTP's ETag and
+// Last-Modified headers. Plugins set them via SetValidators when they list or
+// read an entry. When Wash refreshes the cached result, it passes the previous
+// validators back so that the plugin can cheaply check whether the result
+// changed. If it didn't, the plugin returns ErrUnchanged and Wash keeps
+// serving the previous result.
+type Validators struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified,omitempty"`
+}
+
+// IsZero returns true if v doesn't contain any validators
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified.IsZero()
+}
+
+// ErrUnchanged is returned by List, Open and Metadata when the result identified
+// by PreviousValidators hasn't changed.
+var ErrUnchanged = errors.New("unchanged")
+
+// validatedResultTTL is how long a validated result is retained after it was
+// last fetched or revalidated. It can be served past its op's TTL, but it's
+// always revalidated first.
+const validatedResultTTL = 1 * time.Hour
+
+// maxValidatedResults is the maximum number of retained validated results
+const maxValidatedResults = 10000
+
+type validatedResult struct {
+	mux        sync.Mutex
+	value      interface{}
+	validators Validators
+}
+
+var validatedResults = datastore.NewMemCache().Limit(maxValidatedResults)
+
+type validatorsKeyType int
+
+const validatorsKey validatorsKeyType = iota
+
+// validatorsSlot is threaded through an op's context so that the op can read
+// the previous validators and set the new ones
+type validatorsSlot struct {
+	mux      sync.Mutex
+	previous Validators
+	current  Validators
+	// file is the file that an external plugin's script writes its
+	// validators to
+	file string
+}
+
+// PreviousValidators returns the validators that were set for the result that's
+// being refreshed. It returns false if there aren't any, e.g. if this is the
+// first time the result's being fetched.
+func PreviousValidators(ctx context.Context) (Validators, bool) {
+	slot, ok := ctx.Value(validatorsKey).(*validatorsSlot)
+	if !ok || slot.previous.IsZero() {
+		return Validators{}, false
+	}
+	return slot.previous, true
+}
+
+// SetValidators sets the validators of the result that's being fetched. It's a
+// no-op if ctx isn't the context of a List, Open or Metadata call.
+func SetValidators(ctx context.Context, v Validators) {
+	if slot, ok := ctx.Value(validatorsKey).(*validatorsSlot); ok {
+		slot.mux.Lock()
+		defer slot.mux.Unlock()
+		slot.current = v
+	}
+}
+
+// ContentValidators returns the validators of e's content, i.e. the ones that
+// its plugin set when the content was last read or revalidated. It returns
+// false if there aren't any. Callers that keep their own copy of the content
+// (e.g. FUSE's content cache) use them to check that their copy's still fresh.
+func ContentValidators(e Entry) (Validators, bool) {
+	obj, err := validatedResults.Get(defaultOpCodeToNameMap[OpenOp], e.id())
+	if err != nil || obj == nil {
+		return Validators{}, false
+	}
+	result := obj.(*validatedResult)
+	result.mux.Lock()
+	defer result.mux.Unlock()
+	return result.validators, !result.validators.IsZero()
+}
+
+// Equal returns true if v and other identify the same version of a result
+func (v Validators) Equal(other Validators) bool {
+	return v.ETag == other.ETag && v.LastModified.Equal(other.LastModified)
+}
+
+// validatedOp wraps op so that it can use validators to skip refetching results
+// that haven't changed.
+func validatedOp(opName string, entry Entry, op func(context.Context) (interface{}, error)) func(context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		var previous *validatedResult
+		if obj, err := validatedResults.Get(opName, entry.id()); err == nil && obj != nil {
+			previous = obj.(*validatedResult)
+		}
+
+		slot := &validatorsSlot{}
+		var previousValue interface{}
+		if previous != nil {
+			previous.mux.Lock()
+			slot.previous, previousValue = previous.validators, previous.value
+			previous.mux.Unlock()
+		}
+
+		value, err := op(context.WithValue(ctx, validatorsKey, slot))
+		slot.mux.Lock()
+		current := slot.current
+		slot.mux.Unlock()
+		if errors.Is(err, ErrUnchanged) {
+			if previous == nil {
+				return nil, fmt.Errorf("%v reported that the result of %v is unchanged, but there's no previous result", entry.id(), opName)
+			}
+			value, err = previousValue, nil
+			if current.IsZero() {
+				current = slot.previous
+			}
+		}
+		if err == nil && current.IsZero() && previous != nil {
+			// The new result doesn't have validators, so the previous ones
+			// no longer identify it (see ContentValidators)
+			validatedResults.Delete(regexp.MustCompile("^" + opName + "::" + regexp.QuoteMeta(entry.id()) + "$"))
+		}
+		if err != nil || current.IsZero() {
+			return value, err
+		}
+		if opName == defaultOpCodeToNameMap[OpenOp] && !slot.previous.IsZero() && !current.Equal(slot.previous) {
+			notifyContentChange(entry.id())
+		}
+
+		obj, err := validatedResults.GetOrUpdate(opName, entry.id(), validatedResultTTL, true, func() (interface{}, error) {
+			return &validatedResult{}, nil
+		})
+		if err != nil {
+			// This should never happen since the generator doesn't error
+			panic(err)
+		}
+		result := obj.(*validatedResult)
+		result.mux.Lock()
+		defer result.mux.Unlock()
+		result.value, result.validators = value, current
+		return value, nil
+	}
+}
@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CacheValidatorsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *CacheValidatorsTestSuite) TearDownTest() {
+	validatedResults.Flush()
+}
+
+func (suite *CacheValidatorsTestSuite) TestValidatedOp() {
+	entry := newCacheTestsMockEntry("foo")
+	entry.SetTestID("/foo")
+
+	calls := 0
+	var previous []Validators
+	op := validatedOp("List", entry, func(ctx context.Context) (interface{}, error) {
+		calls++
+		v, _ := PreviousValidators(ctx)
+		previous = append(previous, v)
+		switch calls {
+		case 1:
+			SetValidators(ctx, Validators{ETag: "v1"})
+			return "first", nil
+		case 2:
+			return nil, ErrUnchanged
+		default:
+			SetValidators(ctx, Validators{ETag: "v2"})
+			return "second", nil
+		}
+	})
+
+	value, err := op(context.Background())
+	if suite.NoError(err) {
+		suite.Equal("first", value)
+	}
+
+	// The previous result should be returned if the op reports that it's
+	// unchanged
+	value, err = op(context.Background())
+	if suite.NoError(err) {
+		suite.Equal("first", value)
+	}
+
+	value, err = op(context.Background())
+	if suite.NoError(err) {
+		suite.Equal("second", value)
+	}
+	suite.Equal([]Validators{{}, {ETag: "v1"}, {ETag: "v1"}}, previous)
+}
+
+func (suite *CacheValidatorsTestSuite) TestValidatedOpErrors() {
+	entry := newCacheTestsMockEntry("foo")
+	entry.SetTestID("/foo")
+
+	// ErrUnchanged without a previous result is an error
+	op := validatedOp("List", entry, func(ctx context.Context) (interface{}, error) {
+		return nil, ErrUnchanged
+	})
+	_, err := op(context.Background())
+	suite.Error(err)
+
+	// Errors aren't retained
+	mockErr := fmt.Errorf("an error")
+	op = validatedOp("List", entry, func(ctx context.Context) (interface{}, error) {
+		SetValidators(ctx, Validators{ETag: "v1"})
+		return nil, mockErr
+	})
+	_, err = op(context.Background())
+	suite.Equal(mockErr, err)
+	obj, err := validatedResults.Get("List", entry.id())
+	suite.NoError(err)
+	suite.Nil(obj)
+}
+
+func (suite *CacheValidatorsTestSuite) TestSetValidatorsWithoutOp() {
+	// This should be a no-op
+	SetValidators(context.Background(), Validators{ETag: "v1"})
+	_, ok := PreviousValidators(context.Background())
+	suite.False(ok)
+}
+
+func (suite *CacheValidatorsTestSuite) TestContentValidators() {
+	entry := newCacheTestsMockEntry("foo")
+	entry.SetTestID("/foo")
+
+	_, ok := ContentValidators(entry)
+	suite.False(ok)
+
+	lastModified := time.Now()
+	op := validatedOp("Open", entry, func(ctx context.Context) (interface{}, error) {
+		SetValidators(ctx, Validators{ETag: "v1", LastModified: lastModified})
+		return "content", nil
+	})
+	_, err := op(context.Background())
+	suite.NoError(err)
+	v, ok := ContentValidators(entry)
+	if suite.True(ok) {
+		suite.True(v.Equal(Validators{ETag: "v1", LastModified: lastModified.UTC()}))
+		suite.False(v.Equal(Validators{ETag: "v2", LastModified: lastModified}))
+	}
+
+	// Content that's refetched without validators no longer has any
+	op = validatedOp("Open", entry, func(ctx context.Context) (interface{}, error) {
+		return "updated", nil
+	})
+	_, err = op(context.Background())
+	suite.NoError(err)
+	_, ok = ContentValidators(entry)
+	suite.False(ok)
+}
+
+func (suite *CacheValidatorsTestSuite) TestValidatedOpNotifiesContentChanges() {
+	entry := newCacheTestsMockEntry("foo")
+	entry.SetTestID("/changing")
+	changes := make(chan string, 10)
+	OnContentChange(func(id string) {
+		if id == "/changing" {
+			changes <- id
+		}
+	})
+
+	etag := "v1"
+	op := validatedOp("Open", entry, func(ctx context.Context) (interface{}, error) {
+		SetValidators(ctx, Validators{ETag: etag})
+		return "content", nil
+	})
+	// Neither the first fetch nor a refetch with the same validators is a
+	// change
+	for i := 0; i < 2; i++ {
+		_, err := op(context.Background())
+		suite.NoError(err)
+	}
+	etag = "v2"
+	_, err := op(context.Background())
+	suite.NoError(err)
+
+	select {
+	case id := <-changes:
+		suite.Equal("/changing", id)
+	case <-time.After(time.Second):
+		suite.Fail("the content change wasn't notified")
+	}
+	select {
+	case <-changes:
+		suite.Fail("unexpected content change notification")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCacheValidators(t *testing.T) {
+	suite.Run(t, new(CacheValidatorsTestSuite))
+}
@@ -26,6 +26,11 @@ func (m *cacheTestsMockCache) GetOrUpdate(cat, key string, ttl time.Duration, re
 	return args.Get(0), args.Error(1)
 }
 
+func (m *cacheTestsMockCache) Refresh(cat, key string, ttl time.Duration, generateValue func() (interface{}, error)) error {
+	args := m.Called(cat, key, ttl, generateValue)
+	return args.Error(0)
+}
+
 func (m *cacheTestsMockCache) Flush() {
 	// Don't need anything for Flush, so leave it alone for now
 }
@@ -352,7 +357,8 @@ func (suite *CacheTestSuite) TestCachedOpen() {
 
 func (suite *CacheTestSuite) TestCachedMetadata() {
 	mockJSONObject := JSONObject{"foo": "bar"}
-	suite.testCachedDefaultOp(MetadataOp, "Metadata", mockJSONObject, mockJSONObject, func(ctx context.Context, e Entry) (interface{}, error) {
+	normalizedJSONObject := JSONObject{"foo": "bar", CommonMetadataKey: map[string]interface{}{"name": "mock"}}
+	suite.testCachedDefaultOp(MetadataOp, "Metadata", mockJSONObject, normalizedJSONObject, func(ctx context.Context, e Entry) (interface{}, error) {
 		return CachedMetadata(ctx, e)
 	})
 }
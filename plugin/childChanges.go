@@ -0,0 +1,255 @@
+package plugin
+
+import (
+	"container/list"
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+	log "github.com/sirupsen/logrus"
+)
+
+var maxTrackedListings = limits.Register(
+	"plugins.max_tracked_listings",
+	"The maximum number of parents whose last listing is tracked to detect their removed and moved children. Once it's exceeded, the least recently listed parent stops being tracked, so the changes between its last listing and its next one aren't detected. 0 disables the limit.",
+	10000,
+	nil,
+)
+
+// These are the kinds of ChildChanges
+const (
+	ChildRemoved = "removed"
+	ChildMoved   = "moved"
+)
+
+// ChildChange describes a child that disappeared from its parent between two
+// of the parent's listings. A removed child is a tombstone: it no longer
+// exists. A moved child was renamed, so it's now at NewID.
+type ChildChange struct {
+	Kind     string
+	ParentID string
+	ID       string
+	NewID    string
+}
+
+var childChangeListeners struct {
+	mux       sync.Mutex
+	listeners []func(ChildChange)
+}
+
+// OnChildChange registers listener to be called with each ChildChange that
+// Wash detects. Changes are detected by comparing a parent's listing with its
+// previous listing, so children that disappear and reappear between two
+// listings aren't detected. A child's considered moved if a new child has the
+// same common metadata ID (see CommonMetadata) as it did.
+//
+// By the time that listener's called, the cached results of the child (and of
+// its descendants) have been cleared. listener is called in its own
+// goroutine. It's meant for the FUSE filesystem, which uses it to fail the
+// child's open files instead of serving the wrong content.
+func OnChildChange(listener func(ChildChange)) {
+	childChangeListeners.mux.Lock()
+	defer childChangeListeners.mux.Unlock()
+	childChangeListeners.listeners = append(childChangeListeners.listeners, listener)
+}
+
+func notifyChildChange(change ChildChange) {
+	childChangeListeners.mux.Lock()
+	defer childChangeListeners.mux.Unlock()
+	for _, listener := range childChangeListeners.listeners {
+		go listener(change)
+	}
+}
+
+// childListings tracks each parent's last listing so that its removed and
+// moved children can be detected. Each child's tracked by its ID and its
+// common metadata ID (which is empty if it doesn't have one).
+//
+// Listings are compared while they're being cached, but the changes are only
+// handled once they're cached (see handleChildChanges). Otherwise, clearing the
+// children's cached results could deadlock with the cached listing.
+//
+// Each listing's also assigned a generation, which is unique among all of the
+// listings, so that views of a parent's listing (e.g. its partitions) can tell
+// whether it's been relisted.
+//
+// The listings outlive the parents' cached listings (that's how the next
+// listing's compared with them), so they're bounded by the
+// plugins.max_tracked_listings limit instead.
+var childListings = struct {
+	mux      sync.Mutex
+	listings map[string]*list.Element
+	// lru's front is the most recently recorded listing
+	lru        *list.List
+	pending    map[string][]ChildChange
+	generation uint64
+}{
+	listings: make(map[string]*list.Element),
+	lru:      list.New(),
+	pending:  make(map[string][]ChildChange),
+}
+
+// trackedListing is a parent's last listing
+type trackedListing struct {
+	parentID string
+	// children maps the children's IDs to their common metadata IDs
+	children   map[string]string
+	entries    map[string]Entry
+	generation uint64
+}
+
+// lastListingLocked returns parentID's tracked listing, or nil if it isn't
+// tracked. childListings.mux must be held.
+func lastListingLocked(parentID string) *trackedListing {
+	if elem, ok := childListings.listings[parentID]; ok {
+		return elem.Value.(*trackedListing)
+	}
+	return nil
+}
+
+// forgetListingLocked stops tracking parentID's listing. childListings.mux
+// must be held.
+func forgetListingLocked(parentID string) {
+	if elem, ok := childListings.listings[parentID]; ok {
+		childListings.lru.Remove(elem)
+		delete(childListings.listings, parentID)
+	}
+}
+
+// lastListingGeneration returns the generation of parentID's last listing, or
+// 0 if it hasn't been listed (or it's no longer tracked)
+func lastListingGeneration(parentID string) uint64 {
+	childListings.mux.Lock()
+	defer childListings.mux.Unlock()
+	if last := lastListingLocked(parentID); last != nil {
+		return last.generation
+	}
+	return 0
+}
+
+// generationOfListing returns the generation of parentID's listing of the
+// given entries, or 0 if they aren't its last listing
+func generationOfListing(parentID string, entries map[string]Entry) uint64 {
+	childListings.mux.Lock()
+	defer childListings.mux.Unlock()
+	last := lastListingLocked(parentID)
+	if last == nil || reflect.ValueOf(last.entries).Pointer() != reflect.ValueOf(entries).Pointer() {
+		return 0
+	}
+	return last.generation
+}
+
+// recordListing records parentID's listing, and queues the changes between
+// it and the previous listing
+func recordListing(parentID string, entries map[string]Entry) {
+	current := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		current[entry.id()] = commonIDOf(entry)
+	}
+
+	childListings.mux.Lock()
+	defer childListings.mux.Unlock()
+	var previous map[string]string
+	last := lastListingLocked(parentID)
+	if last != nil {
+		previous = last.children
+		forgetListingLocked(parentID)
+	}
+	childListings.generation++
+	childListings.listings[parentID] = childListings.lru.PushFront(&trackedListing{
+		parentID:   parentID,
+		children:   current,
+		entries:    entries,
+		generation: childListings.generation,
+	})
+	if max := maxTrackedListings.Value(); max > 0 {
+		for childListings.lru.Len() > max {
+			forgetListingLocked(childListings.lru.Back().Value.(*trackedListing).parentID)
+		}
+	}
+	if last == nil {
+		return
+	}
+
+	// Moves are matched by the common ID, which must be unique among the
+	// removed and the added children
+	added := make(map[string][]string)
+	for id, commonID := range current {
+		if _, ok := previous[id]; !ok && commonID != "" {
+			added[commonID] = append(added[commonID], id)
+		}
+	}
+	removed := make(map[string]int)
+	for id, commonID := range previous {
+		if _, ok := current[id]; !ok && commonID != "" {
+			removed[commonID]++
+		}
+	}
+	for id, commonID := range previous {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		change := ChildChange{Kind: ChildRemoved, ParentID: parentID, ID: id}
+		if newIDs := added[commonID]; commonID != "" && len(newIDs) == 1 && removed[commonID] == 1 {
+			change.Kind = ChildMoved
+			change.NewID = newIDs[0]
+		}
+		childListings.pending[parentID] = append(childListings.pending[parentID], change)
+	}
+}
+
+// handleChildChanges clears the cached results of parentID's removed and moved
+// children, and notifies the OnChildChange listeners about them
+func handleChildChanges(ctx context.Context, parentID string) {
+	childListings.mux.Lock()
+	changes := childListings.pending[parentID]
+	delete(childListings.pending, parentID)
+	childListings.mux.Unlock()
+
+	for _, change := range changes {
+		if change.Kind == ChildMoved {
+			activity.Record(ctx, "%v moved to %v", change.ID, change.NewID)
+		} else {
+			activity.Record(ctx, "%v was removed", change.ID)
+		}
+		if _, err := ClearCacheFor(change.ID); err != nil {
+			log.Warnf("Could not clear the cached results of %v: %v", change.ID, err)
+		}
+		forgetListings(change.ID)
+		notifyChildChange(change)
+	}
+}
+
+// forgetListings stops tracking the listings of the entry with the given ID
+// and of its descendants since they no longer exist
+func forgetListings(id string) {
+	prefix := strings.TrimRight(id, "/") + "/"
+	childListings.mux.Lock()
+	defer childListings.mux.Unlock()
+	for parentID := range childListings.listings {
+		if parentID == id || strings.HasPrefix(parentID, prefix) {
+			forgetListingLocked(parentID)
+			delete(childListings.pending, parentID)
+		}
+	}
+}
+
+// commonIDOf returns the entry's common metadata ID, or an empty string if it
+// doesn't have one. Only the entry's meta attribute is checked since its full
+// metadata's expensive to fetch.
+func commonIDOf(e Entry) string {
+	meta := e.attributes().meta
+	if meta == nil {
+		return ""
+	}
+	if common, ok := meta[CommonMetadataKey].(map[string]interface{}); ok {
+		if id, ok := common["id"].(string); ok {
+			return id
+		}
+	}
+	id, _ := lookupString(meta, commonMetadataPaths["id"])
+	return id
+}
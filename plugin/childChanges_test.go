@@ -0,0 +1,149 @@
+package plugin
+
+import (
+	"container/list"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ChildChangesTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ChildChangesTestSuite) SetupTest() {
+	SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *ChildChangesTestSuite) TearDownTest() {
+	UnsetTestCache()
+	_, err := limits.Set(maxTrackedListings.Name(), 10000)
+	suite.NoError(err)
+	childListings.mux.Lock()
+	defer childListings.mux.Unlock()
+	childListings.listings = make(map[string]*list.Element)
+	childListings.lru = list.New()
+	childListings.pending = make(map[string][]ChildChange)
+}
+
+func (suite *ChildChangesTestSuite) newChild(parentID string, name string, commonID string) Entry {
+	e := newCacheTestsMockEntry(name)
+	if commonID != "" {
+		e.Attributes().SetMeta(JSONObject{"Id": commonID})
+	}
+	e.setID(parentID + "/" + name)
+	return e
+}
+
+func (suite *ChildChangesTestSuite) pendingChanges(parentID string) []ChildChange {
+	childListings.mux.Lock()
+	defer childListings.mux.Unlock()
+	return childListings.pending[parentID]
+}
+
+func (suite *ChildChangesTestSuite) TestRecordListing() {
+	recordListing("/p", toMap([]Entry{
+		suite.newChild("/p", "a", "1"),
+		suite.newChild("/p", "b", "2"),
+		suite.newChild("/p", "c", ""),
+	}))
+	// The first listing has nothing to compare with
+	suite.Empty(suite.pendingChanges("/p"))
+
+	recordListing("/p", toMap([]Entry{
+		suite.newChild("/p", "a", "1"),
+		suite.newChild("/p", "d", "2"),
+	}))
+	suite.ElementsMatch([]ChildChange{
+		{Kind: ChildMoved, ParentID: "/p", ID: "/p/b", NewID: "/p/d"},
+		{Kind: ChildRemoved, ParentID: "/p", ID: "/p/c"},
+	}, suite.pendingChanges("/p"))
+}
+
+func (suite *ChildChangesTestSuite) TestRecordListing_AmbiguousMovesAreRemovals() {
+	recordListing("/p", toMap([]Entry{
+		suite.newChild("/p", "a", "1"),
+	}))
+	recordListing("/p", toMap([]Entry{
+		suite.newChild("/p", "b", "1"),
+		suite.newChild("/p", "c", "1"),
+	}))
+	suite.Equal([]ChildChange{
+		{Kind: ChildRemoved, ParentID: "/p", ID: "/p/a"},
+	}, suite.pendingChanges("/p"))
+}
+
+func (suite *ChildChangesTestSuite) TestRecordListing_EvictsTheLeastRecentlyRecordedListings() {
+	_, err := limits.Set(maxTrackedListings.Name(), 2)
+	suite.NoError(err)
+
+	recordListing("/p1", toMap([]Entry{suite.newChild("/p1", "a", "")}))
+	recordListing("/p2", toMap([]Entry{suite.newChild("/p2", "a", "")}))
+	recordListing("/p1", toMap([]Entry{suite.newChild("/p1", "a", "")}))
+	recordListing("/p3", toMap([]Entry{suite.newChild("/p3", "a", "")}))
+	suite.NotZero(lastListingGeneration("/p1"))
+	suite.Zero(lastListingGeneration("/p2"))
+	suite.NotZero(lastListingGeneration("/p3"))
+
+	// An evicted parent's next listing has nothing to compare with
+	recordListing("/p2", toMap([]Entry{}))
+	suite.Empty(suite.pendingChanges("/p2"))
+	suite.Zero(lastListingGeneration("/p1"))
+}
+
+func (suite *ChildChangesTestSuite) TestForgetListings() {
+	recordListing("/p", toMap([]Entry{suite.newChild("/p", "a", "")}))
+	recordListing("/p/a", toMap([]Entry{}))
+	recordListing("/pp", toMap([]Entry{}))
+	forgetListings("/p")
+	suite.Zero(lastListingGeneration("/p"))
+	suite.Zero(lastListingGeneration("/p/a"))
+	suite.NotZero(lastListingGeneration("/pp"))
+	childListings.mux.Lock()
+	defer childListings.mux.Unlock()
+	suite.Equal(1, childListings.lru.Len())
+}
+
+func (suite *ChildChangesTestSuite) TestCachedList_HandlesChildChanges() {
+	changes := make(chan ChildChange, 10)
+	OnChildChange(func(change ChildChange) {
+		if change.ParentID == "/parent" {
+			changes <- change
+		}
+	})
+
+	ctx := context.Background()
+	parent := newCacheTestsMockEntry("parent")
+	parent.SetTestID("/parent")
+	parent.DisableDefaultCaching()
+	child := newCacheTestsMockEntry("child")
+	child.On("Metadata", mock.Anything).Return(JSONObject{}, nil).Once()
+	parent.On("List", mock.Anything).Return([]Entry{child}, nil).Once()
+	_, err := CachedList(ctx, parent)
+	suite.NoError(err)
+	_, err = CachedMetadata(ctx, child)
+	suite.NoError(err)
+	suite.True(IsCached(child, MetadataOp))
+
+	parent.On("List", mock.Anything).Return([]Entry{}, nil).Once()
+	_, err = CachedList(ctx, parent)
+	suite.NoError(err)
+	// The removed child's cached results are cleared
+	suite.False(IsCached(child, MetadataOp))
+	select {
+	case change := <-changes:
+		suite.Equal(ChildChange{Kind: ChildRemoved, ParentID: "/parent", ID: "/parent/child"}, change)
+	case <-time.After(time.Second):
+		suite.Fail("the child change wasn't notified")
+	}
+	suite.Empty(suite.pendingChanges("/parent"))
+}
+
+func TestChildChanges(t *testing.T) {
+	suite.Run(t, new(ChildChangesTestSuite))
+}
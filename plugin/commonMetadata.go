@@ -0,0 +1,283 @@
+package plugin
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ekinanp/jsonschema"
+)
+
+// CommonMetadataKey is the metadata key that contains the entry's common
+// metadata
+const CommonMetadataKey = "_common"
+
+// CommonMetadata is a small schema of metadata that's common to most of the
+// plugins' resources. It's included in the entry's metadata (and meta
+// attribute) under the CommonMetadataKey so that find queries and dashboards
+// can work across plugins without knowing each provider's field names, e.g.
+// `find -m ._common.region us-west-1`.
+type CommonMetadata struct {
+	ID        string            `json:"id,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Region    string            `json:"region,omitempty"`
+	Zone      string            `json:"zone,omitempty"`
+	CreatedAt time.Time         `json:"created_at,omitempty"`
+	State     string            `json:"state,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Owner     string            `json:"owner,omitempty"`
+}
+
+// MetadataNormalizer is an entry that knows how its (provider-specific)
+// metadata maps onto the CommonMetadata. The returned fields override the
+// ones that Wash finds on its own. Zero-valued fields are ignored.
+type MetadataNormalizer interface {
+	Entry
+	NormalizeMetadata(meta JSONObject) CommonMetadata
+}
+
+// commonMetadataPaths are the well-known keys of each common field in the
+// plugins' metadata, in order of preference. They're matched
+// case-insensitively. Each path is a list of nested keys.
+var commonMetadataPaths = map[string][][]string{
+	"id":         {{"id"}, {"uid"}, {"instanceid"}, {"metadata", "uid"}},
+	"region":     {{"region"}, {"placement", "region"}},
+	"zone":       {{"zone"}, {"availabilityzone"}, {"placement", "availabilityzone"}},
+	"created_at": {{"creationtimestamp"}, {"metadata", "creationtimestamp"}, {"createdat"}, {"created"}, {"creationtime"}, {"launchtime"}},
+	"state":      {{"state"}, {"state", "name"}, {"state", "status"}, {"status"}, {"status", "phase"}},
+	"labels":     {{"labels"}, {"metadata", "labels"}, {"config", "labels"}, {"tags"}},
+	"owner":      {{"owner"}, {"ownerid"}},
+}
+
+// NormalizeMetadata returns a copy of meta that includes e's CommonMetadata
+// under the CommonMetadataKey. The common fields are found, in increasing
+// order of precedence, from
+//
+//   - the well-known keys of the plugins' metadata (e.g. Placement.AvailabilityZone
+//     for the zone or metadata.labels for the labels)
+//
+//   - e's attributes (its name, crtime, lifecycle state and owner)
+//
+//   - e's NormalizeMetadata method, if it's a MetadataNormalizer
+//
+//   - the CommonMetadataKey of meta itself, e.g. if an external plugin
+//     already normalized its metadata
+func NormalizeMetadata(e Entry, meta JSONObject) JSONObject {
+	common := CommonMetadata{Name: Name(e)}
+	if id, ok := lookupString(meta, commonMetadataPaths["id"]); ok {
+		common.ID = id
+	}
+	if region, ok := lookupString(meta, commonMetadataPaths["region"]); ok {
+		common.Region = region
+	}
+	if zone, ok := lookupString(meta, commonMetadataPaths["zone"]); ok {
+		// Some providers (e.g. GCP) use the zone's URL
+		common.Zone = zone[strings.LastIndex(zone, "/")+1:]
+	}
+	if createdAt, ok := lookupTime(meta, commonMetadataPaths["created_at"]); ok {
+		common.CreatedAt = createdAt
+	}
+	if state, ok := lookupString(meta, commonMetadataPaths["state"]); ok {
+		common.State = strings.ToLower(state)
+	}
+	if labels, ok := lookupLabels(meta, commonMetadataPaths["labels"]); ok {
+		common.Labels = labels
+	}
+	if owner, ok := lookupString(meta, commonMetadataPaths["owner"]); ok {
+		common.Owner = owner
+	}
+
+	attr := e.attributes()
+	if attr.HasCrtime() {
+		common.CreatedAt = attr.Crtime()
+	}
+	if attr.HasLifecycle() {
+		common.State = string(attr.Lifecycle())
+	}
+	if attr.HasOwner() {
+		common.Owner = attr.Owner()
+	}
+
+	if n, ok := e.(MetadataNormalizer); ok {
+		common.merge(n.NormalizeMetadata(meta))
+	}
+
+	normalized := make(JSONObject, len(meta)+1)
+	for k, v := range meta {
+		normalized[k] = v
+	}
+	commonObj := common.toMap()
+	if provided, ok := meta[CommonMetadataKey].(map[string]interface{}); ok {
+		for k, v := range provided {
+			commonObj[k] = v
+		}
+	}
+	normalized[CommonMetadataKey] = commonObj
+	return normalized
+}
+
+func (c *CommonMetadata) merge(other CommonMetadata) {
+	if other.ID != "" {
+		c.ID = other.ID
+	}
+	if other.Name != "" {
+		c.Name = other.Name
+	}
+	if other.Region != "" {
+		c.Region = other.Region
+	}
+	if other.Zone != "" {
+		c.Zone = other.Zone
+	}
+	if !other.CreatedAt.IsZero() {
+		c.CreatedAt = other.CreatedAt
+	}
+	if other.State != "" {
+		c.State = other.State
+	}
+	if other.Labels != nil {
+		c.Labels = other.Labels
+	}
+	if other.Owner != "" {
+		c.Owner = other.Owner
+	}
+}
+
+// toMap converts c to a JSONObject, omitting its zero-valued fields. The
+// values have the same types as a decoded JSON object's so that the
+// normalized metadata looks the same before and after it's serialized.
+func (c CommonMetadata) toMap() map[string]interface{} {
+	mp := make(map[string]interface{})
+	set := func(key string, value string) {
+		if value != "" {
+			mp[key] = value
+		}
+	}
+	set("id", c.ID)
+	set("name", c.Name)
+	set("region", c.Region)
+	set("zone", c.Zone)
+	if !c.CreatedAt.IsZero() {
+		mp["created_at"] = c.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	set("state", c.State)
+	if c.Labels != nil {
+		labels := make(map[string]interface{}, len(c.Labels))
+		for k, v := range c.Labels {
+			labels[k] = v
+		}
+		mp["labels"] = labels
+	}
+	set("owner", c.Owner)
+	return mp
+}
+
+// lookup returns the value of the first path that's in meta
+func lookup(meta JSONObject, paths [][]string, accept func(interface{}) bool) (interface{}, bool) {
+	for _, path := range paths {
+		var value interface{} = map[string]interface{}(meta)
+		for _, key := range path {
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = lookupKey(obj, key)
+		}
+		if value != nil && accept(value) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func lookupKey(obj map[string]interface{}, key string) interface{} {
+	for k, v := range obj {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+func lookupString(meta JSONObject, paths [][]string) (string, bool) {
+	value, ok := lookup(meta, paths, func(v interface{}) bool {
+		str, ok := v.(string)
+		return ok && str != ""
+	})
+	if !ok {
+		return "", false
+	}
+	return value.(string), true
+}
+
+func lookupTime(meta JSONObject, paths [][]string) (time.Time, bool) {
+	var t time.Time
+	_, ok := lookup(meta, paths, func(v interface{}) bool {
+		switch value := v.(type) {
+		case string:
+			parsed, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return false
+			}
+			t = parsed
+		case float64:
+			// Assume that it's a UNIX timestamp
+			if value <= 0 {
+				return false
+			}
+			t = time.Unix(int64(value), 0)
+		case time.Time:
+			t = value
+		default:
+			return false
+		}
+		return true
+	})
+	return t, ok
+}
+
+// lookupLabels accepts either a map of labels or a list of {"Key": ...,
+// "Value": ...} objects, which is how AWS represents its tags
+func lookupLabels(meta JSONObject, paths [][]string) (map[string]string, bool) {
+	var labels map[string]string
+	_, ok := lookup(meta, paths, func(v interface{}) bool {
+		labels = make(map[string]string)
+		switch value := v.(type) {
+		case map[string]interface{}:
+			for k, label := range value {
+				str, ok := label.(string)
+				if !ok {
+					return false
+				}
+				labels[k] = str
+			}
+		case []interface{}:
+			for _, tag := range value {
+				obj, ok := tag.(map[string]interface{})
+				if !ok {
+					return false
+				}
+				key, ok := lookupKey(obj, "key").(string)
+				if !ok {
+					return false
+				}
+				value, _ := lookupKey(obj, "value").(string)
+				labels[key] = value
+			}
+		default:
+			return false
+		}
+		return true
+	})
+	return labels, ok
+}
+
+// CommonMetadataSchema returns the schema of the CommonMetadataKey's value.
+// Plugins' metadata schemas don't include it since it's added by Wash, so
+// schema consumers like find should add it themselves.
+func CommonMetadataSchema() *jsonschema.Type {
+	r := jsonschema.Reflector{ExpandedStruct: true}
+	common := r.Reflect(CommonMetadata{}).Type
+	common.Version = ""
+	return common
+}
@@ -0,0 +1,114 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CommonMetadataTestSuite struct {
+	suite.Suite
+}
+
+func (suite *CommonMetadataTestSuite) TestNormalizeMetadata_WellKnownKeys() {
+	e := newCacheTestsMockEntry("foo")
+	meta := JSONObject{
+		"InstanceId": "i-1234",
+		"Placement": map[string]interface{}{
+			"AvailabilityZone": "us-west-1a",
+		},
+		"LaunchTime": "2019-06-01T10:00:00Z",
+		"State": map[string]interface{}{
+			"Code": float64(16),
+			"Name": "Running",
+		},
+		"Tags": []interface{}{
+			map[string]interface{}{"Key": "team", "Value": "wash"},
+		},
+		"OwnerId": "1234",
+	}
+	normalized := NormalizeMetadata(e, meta)
+	suite.Equal(map[string]interface{}{
+		"id":         "i-1234",
+		"name":       "foo",
+		"zone":       "us-west-1a",
+		"created_at": "2019-06-01T10:00:00Z",
+		"state":      "running",
+		"labels":     map[string]interface{}{"team": "wash"},
+		"owner":      "1234",
+	}, normalized[CommonMetadataKey])
+	suite.Equal("i-1234", normalized["InstanceId"])
+	// The original metadata's unchanged
+	suite.NotContains(meta, CommonMetadataKey)
+}
+
+func (suite *CommonMetadataTestSuite) TestNormalizeMetadata_NestedAndUnixTimes() {
+	e := newCacheTestsMockEntry("foo")
+	normalized := NormalizeMetadata(e, JSONObject{
+		"metadata": map[string]interface{}{
+			"uid":    "abcd",
+			"labels": map[string]interface{}{"app": "web"},
+		},
+		"status":  map[string]interface{}{"phase": "Pending"},
+		"Created": float64(1559383200),
+		"zone":    "https://www.googleapis.com/compute/v1/projects/foo/zones/us-east1-b",
+	})
+	suite.Equal(map[string]interface{}{
+		"id":         "abcd",
+		"name":       "foo",
+		"zone":       "us-east1-b",
+		"created_at": "2019-06-01T10:00:00Z",
+		"state":      "pending",
+		"labels":     map[string]interface{}{"app": "web"},
+	}, normalized[CommonMetadataKey])
+}
+
+func (suite *CommonMetadataTestSuite) TestNormalizeMetadata_AttributesTakePrecedence() {
+	e := newCacheTestsMockEntry("foo")
+	crtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	e.Attributes().SetCrtime(crtime).SetLifecycle(LifecycleStopping).SetOwner("root")
+	normalized := NormalizeMetadata(e, JSONObject{"state": "running", "created": "2019-06-01T10:00:00Z"})
+	suite.Equal(map[string]interface{}{
+		"name":       "foo",
+		"created_at": "2020-01-02T03:04:05Z",
+		"state":      "stopping",
+		"owner":      "root",
+	}, normalized[CommonMetadataKey])
+}
+
+type commonMetadataTestsNormalizer struct {
+	EntryBase
+}
+
+func (e *commonMetadataTestsNormalizer) Schema() *EntrySchema {
+	return nil
+}
+
+func (e *commonMetadataTestsNormalizer) NormalizeMetadata(meta JSONObject) CommonMetadata {
+	return CommonMetadata{Region: meta["loc"].(string)}
+}
+
+func (suite *CommonMetadataTestSuite) TestNormalizeMetadata_NormalizerAndProvidedCommonMetadata() {
+	e := &commonMetadataTestsNormalizer{EntryBase: NewEntry("foo")}
+	normalized := NormalizeMetadata(e, JSONObject{"loc": "eu-west-2", "region": "us-west-1"})
+	suite.Equal(map[string]interface{}{
+		"name":   "foo",
+		"region": "eu-west-2",
+	}, normalized[CommonMetadataKey])
+
+	// A plugin's own common metadata wins
+	normalized = NormalizeMetadata(e, JSONObject{
+		"loc":             "eu-west-2",
+		CommonMetadataKey: map[string]interface{}{"region": "ap-south-1", "id": "bar"},
+	})
+	suite.Equal(map[string]interface{}{
+		"id":     "bar",
+		"name":   "foo",
+		"region": "ap-south-1",
+	}, normalized[CommonMetadataKey])
+}
+
+func TestCommonMetadata(t *testing.T) {
+	suite.Run(t, new(CommonMetadataTestSuite))
+}
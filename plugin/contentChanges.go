@@ -0,0 +1,33 @@
+package plugin
+
+import "sync"
+
+var contentChangeListeners struct {
+	mux       sync.Mutex
+	listeners []func(id string)
+}
+
+// OnContentChange registers listener to be called with the ID of an entry once
+// Wash detects that the entry's content changed. Changes are detected when
+// the entry's content is refetched with different validators (see
+// Validators), when the entry's written via Write, or when an external
+// plugin's watch invalidates the entry's cached content or listing (a
+// parent's content is its listing). Without validators or a watch, changes
+// are only detected when the entry's written.
+//
+// listener is called in its own goroutine, so it can't block the read that
+// detected the change. It's meant for the FUSE filesystem, which uses it to
+// invalidate the kernel's cache of the entry.
+func OnContentChange(listener func(id string)) {
+	contentChangeListeners.mux.Lock()
+	defer contentChangeListeners.mux.Unlock()
+	contentChangeListeners.listeners = append(contentChangeListeners.listeners, listener)
+}
+
+func notifyContentChange(id string) {
+	contentChangeListeners.mux.Lock()
+	defer contentChangeListeners.mux.Unlock()
+	for _, listener := range contentChangeListeners.listeners {
+		go listener(id)
+	}
+}
@@ -0,0 +1,9 @@
+package plugin
+
+// ContentTypeOf returns the media type of the entry's content, i.e. its
+// content_type attribute. The returned bool is false if the content type's
+// unknown.
+func ContentTypeOf(e Entry) (string, bool) {
+	attr := e.attributes()
+	return attr.ContentType(), attr.HasContentType()
+}
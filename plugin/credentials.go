@@ -0,0 +1,283 @@
+package plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Credential is a token or a set of credentials that a plugin authenticates
+// with
+type Credential struct {
+	// Value is the credential itself, e.g. the token or a map of the secrets'
+	// values. It should be JSON-serializable since daemon-mode external
+	// plugins fetch it via the API.
+	Value interface{}
+	// ExpiresAt is when the credential expires. The zero value means that it
+	// never expires.
+	ExpiresAt time.Time
+	// Generation identifies the refresh that retrieved the credential. It's
+	// incremented by each refresh.
+	Generation int
+}
+
+// CredentialRefreshFunc retrieves a new credential
+type CredentialRefreshFunc func(ctx context.Context) (Credential, error)
+
+// CredentialStatus describes a Credentials' current credential without
+// revealing it
+type CredentialStatus struct {
+	Name        string
+	Generation  int
+	RefreshedAt time.Time
+	ExpiresAt   time.Time
+	Refreshing  bool
+	LastError   error
+}
+
+// credentialRefreshTimeout is how long a refresh has to retrieve the new
+// credential
+const credentialRefreshTimeout = 1 * time.Minute
+
+// credentialRenewalRetryDelay is how long a failed renewal waits before it's
+// retried. The credential's still valid in the meantime.
+const credentialRenewalRetryDelay = 5 * time.Second
+
+// Credentials manages a plugin's credential. It's safe for concurrent use.
+// Requests that find the credential expired share a single refresh instead of
+// each re-authenticating, and a credential that's about to expire is renewed
+// in the background while requests keep using it.
+type Credentials struct {
+	name          string
+	renewalWindow time.Duration
+	refresh       CredentialRefreshFunc
+
+	mux         sync.Mutex
+	current     *Credential
+	refreshedAt time.Time
+	inflight    *credentialRefresh
+	lastErr     error
+	failedAt    time.Time
+	generation  int
+}
+
+type credentialRefresh struct {
+	doneCh     chan struct{}
+	credential Credential
+	err        error
+}
+
+// apiSocketEnvVar is the environment variable that contains the path to the
+// Wash API's socket when an external plugin's script is invoked. Daemon-mode
+// plugins can fetch their refreshed credentials from the API instead of
+// re-authenticating on their own.
+const apiSocketEnvVar = "WASH_SOCKET"
+
+// credentialsTokenEnvVar is the environment variable that contains the token
+// that an external plugin's script fetches its credentials from the API with.
+// Each plugin gets its own token, which only grants access to the plugin's own
+// credentials (see IsCredentialOf).
+const credentialsTokenEnvVar = "WASH_CREDENTIALS_TOKEN"
+
+const credentialsTokenBytes = 32
+
+var apiSocket string
+
+// SetAPISocket sets the path to the API's socket that's passed to external
+// plugins. It should be called before the plugins are loaded.
+func SetAPISocket(path string) {
+	apiSocket = path
+}
+
+var credentials = make(map[string]*Credentials)
+var credentialsMux sync.Mutex
+
+// credentialsTokens are the plugins' credentials tokens, keyed by the plugins'
+// names. They're guarded by credentialsMux.
+var credentialsTokens = make(map[string]string)
+
+// CredentialsTokenFor returns the credentials token of the plugin named name,
+// generating it if the plugin doesn't have one yet
+func CredentialsTokenFor(name string) (string, error) {
+	credentialsMux.Lock()
+	defer credentialsMux.Unlock()
+	if token, ok := credentialsTokens[name]; ok {
+		return token, nil
+	}
+	b := make([]byte, credentialsTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate the credentials token of the %v plugin: %v", name, err)
+	}
+	token := hex.EncodeToString(b)
+	credentialsTokens[name] = token
+	return token, nil
+}
+
+// CredentialsTokenOwner returns the name of the plugin that was issued token
+func CredentialsTokenOwner(token string) (string, bool) {
+	credentialsMux.Lock()
+	defer credentialsMux.Unlock()
+	owner, found := "", false
+	// Every token's compared so that the comparisons' timing doesn't reveal
+	// which tokens exist
+	for name, issued := range credentialsTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(issued)) == 1 {
+			owner, found = name, true
+		}
+	}
+	return owner, found
+}
+
+// IsCredentialOf returns true if the credential named credential belongs to
+// the plugin named pluginName, i.e. if it's named after the plugin (e.g.
+// aws/<profile> belongs to the aws plugin)
+func IsCredentialOf(credential string, pluginName string) bool {
+	return credential == pluginName || strings.HasPrefix(credential, pluginName+"/")
+}
+
+// RegisterCredentials returns the Credentials named name, which uses refresh
+// to retrieve the credential. The credential is renewed once it's within
+// renewalWindow of expiring. The name's shown by the API, so it should start
+// with the plugin's name, e.g. aws/<profile>. A Credentials that was
+// registered with the same name is replaced, e.g. because its plugin was
+// reloaded.
+func RegisterCredentials(name string, renewalWindow time.Duration, refresh CredentialRefreshFunc) *Credentials {
+	c := &Credentials{name: name, renewalWindow: renewalWindow, refresh: refresh}
+	credentialsMux.Lock()
+	credentials[name] = c
+	credentialsMux.Unlock()
+	return c
+}
+
+// FindCredentials returns the Credentials named name
+func FindCredentials(name string) (*Credentials, bool) {
+	credentialsMux.Lock()
+	defer credentialsMux.Unlock()
+	c, ok := credentials[name]
+	return c, ok
+}
+
+// AllCredentials returns the registered Credentials, sorted by name
+func AllCredentials() []*Credentials {
+	credentialsMux.Lock()
+	all := make([]*Credentials, 0, len(credentials))
+	for _, c := range credentials {
+		all = append(all, c)
+	}
+	credentialsMux.Unlock()
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].name < all[j].name
+	})
+	return all
+}
+
+// Name returns the name of the credential
+func (c *Credentials) Name() string {
+	return c.name
+}
+
+// Get returns the current credential, refreshing it if it's expired. The
+// requests that find it expired wait for the same refresh.
+func (c *Credentials) Get(ctx context.Context) (Credential, error) {
+	c.mux.Lock()
+	if c.current != nil && !c.expired(time.Now()) {
+		credential := *c.current
+		if c.inflight == nil && c.needsRenewal(time.Now()) && time.Since(c.failedAt) >= credentialRenewalRetryDelay {
+			c.startRefresh()
+		}
+		c.mux.Unlock()
+		return credential, nil
+	}
+	r := c.inflight
+	if r == nil {
+		r = c.startRefresh()
+	}
+	c.mux.Unlock()
+
+	select {
+	case <-r.doneCh:
+		return r.credential, r.err
+	case <-ctx.Done():
+		return Credential{}, ctx.Err()
+	}
+}
+
+// Expire expires the credential of the given generation, e.g. because the
+// plugin's backend rejected it. The next Get refreshes it. Expiring an older
+// generation is a no-op so that the requests that were all rejected with the
+// same credential only trigger one refresh.
+func (c *Credentials) Expire(generation int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.current != nil && c.current.Generation == generation {
+		c.current = nil
+	}
+}
+
+// Status returns the credential's status
+func (c *Credentials) Status() CredentialStatus {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	status := CredentialStatus{
+		Name:        c.name,
+		RefreshedAt: c.refreshedAt,
+		Refreshing:  c.inflight != nil,
+		LastError:   c.lastErr,
+	}
+	if c.current != nil {
+		status.Generation = c.current.Generation
+		status.ExpiresAt = c.current.ExpiresAt
+	}
+	return status
+}
+
+func (c *Credentials) expired(now time.Time) bool {
+	return !c.current.ExpiresAt.IsZero() && !now.Before(c.current.ExpiresAt)
+}
+
+func (c *Credentials) needsRenewal(now time.Time) bool {
+	return !c.current.ExpiresAt.IsZero() && !now.Before(c.current.ExpiresAt.Add(-c.renewalWindow))
+}
+
+// startRefresh starts refreshing the credential. The refresh isn't tied to
+// the request that started it so that cancelling that request doesn't fail
+// the other requests that are waiting for it. c.mux must be held.
+func (c *Credentials) startRefresh() *credentialRefresh {
+	r := &credentialRefresh{doneCh: make(chan struct{})}
+	c.inflight = r
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), credentialRefreshTimeout)
+		defer cancel()
+		credential, err := c.refresh(ctx)
+
+		c.mux.Lock()
+		c.inflight = nil
+		if err == nil {
+			c.generation++
+			credential.Generation = c.generation
+			c.current = &credential
+			c.refreshedAt = time.Now()
+			c.lastErr = nil
+		} else {
+			err = fmt.Errorf("could not refresh the %v credential: %v", c.name, err)
+			c.lastErr, c.failedAt = err, time.Now()
+			if c.current != nil && !c.expired(time.Now()) {
+				// The renewal failed, but the credential's still valid
+				log.Warnf("%v", err)
+			}
+		}
+		c.mux.Unlock()
+
+		r.credential, r.err = credential, err
+		close(r.doneCh)
+	}()
+	return r
+}
@@ -0,0 +1,197 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CredentialsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *CredentialsTestSuite) TearDownTest() {
+	credentialsMux.Lock()
+	credentials = make(map[string]*Credentials)
+	credentialsTokens = make(map[string]string)
+	credentialsMux.Unlock()
+}
+
+func (suite *CredentialsTestSuite) TestConcurrentRequestsShareTheRefresh() {
+	var refreshes int32
+	releaseCh := make(chan struct{})
+	c := RegisterCredentials("mycloud", 0, func(ctx context.Context) (Credential, error) {
+		atomic.AddInt32(&refreshes, 1)
+		<-releaseCh
+		return Credential{Value: "token", ExpiresAt: time.Now().Add(time.Hour)}, nil
+	})
+
+	var wg sync.WaitGroup
+	results := make([]Credential, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			credential, err := c.Get(context.Background())
+			suite.NoError(err)
+			results[i] = credential
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(releaseCh)
+	wg.Wait()
+
+	suite.Equal(int32(1), atomic.LoadInt32(&refreshes))
+	for _, credential := range results {
+		suite.Equal("token", credential.Value)
+		suite.Equal(1, credential.Generation)
+	}
+}
+
+func (suite *CredentialsTestSuite) TestExpiredCredentialsAreRefreshed() {
+	var refreshes int32
+	c := RegisterCredentials("mycloud", 0, func(ctx context.Context) (Credential, error) {
+		n := atomic.AddInt32(&refreshes, 1)
+		return Credential{Value: fmt.Sprintf("token%v", n), ExpiresAt: time.Now().Add(-time.Second)}, nil
+	})
+	credential, err := c.Get(context.Background())
+	if suite.NoError(err) {
+		suite.Equal("token1", credential.Value)
+	}
+	credential, err = c.Get(context.Background())
+	if suite.NoError(err) {
+		suite.Equal("token2", credential.Value)
+		suite.Equal(2, credential.Generation)
+	}
+}
+
+func (suite *CredentialsTestSuite) TestCredentialsAreRenewedBeforeTheyExpire() {
+	var refreshes int32
+	c := RegisterCredentials("mycloud", time.Hour, func(ctx context.Context) (Credential, error) {
+		n := atomic.AddInt32(&refreshes, 1)
+		return Credential{Value: fmt.Sprintf("token%v", n), ExpiresAt: time.Now().Add(time.Minute)}, nil
+	})
+	_, err := c.Get(context.Background())
+	suite.NoError(err)
+
+	// The credential's within the renewal window, so it's still returned
+	// while it's renewed in the background
+	credential, err := c.Get(context.Background())
+	if suite.NoError(err) {
+		suite.Equal("token1", credential.Value)
+	}
+	for i := 0; i < 100 && c.Status().Generation < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	credential, err = c.Get(context.Background())
+	if suite.NoError(err) {
+		suite.Equal("token2", credential.Value)
+	}
+}
+
+func (suite *CredentialsTestSuite) TestCredentialsThatDontExpireArentRefreshed() {
+	var refreshes int32
+	c := RegisterCredentials("mycloud", time.Hour, func(ctx context.Context) (Credential, error) {
+		atomic.AddInt32(&refreshes, 1)
+		return Credential{Value: "token"}, nil
+	})
+	for i := 0; i < 3; i++ {
+		_, err := c.Get(context.Background())
+		suite.NoError(err)
+	}
+	suite.Equal(int32(1), atomic.LoadInt32(&refreshes))
+}
+
+func (suite *CredentialsTestSuite) TestExpireOnlyExpiresTheCurrentGeneration() {
+	var refreshes int32
+	c := RegisterCredentials("mycloud", 0, func(ctx context.Context) (Credential, error) {
+		atomic.AddInt32(&refreshes, 1)
+		return Credential{Value: "token"}, nil
+	})
+	credential, err := c.Get(context.Background())
+	suite.NoError(err)
+
+	// Requests that were rejected with the same credential only trigger one
+	// refresh
+	for i := 0; i < 3; i++ {
+		c.Expire(credential.Generation)
+		_, err = c.Get(context.Background())
+		suite.NoError(err)
+	}
+	suite.Equal(int32(2), atomic.LoadInt32(&refreshes))
+	suite.Equal(2, c.Status().Generation)
+}
+
+func (suite *CredentialsTestSuite) TestRefreshErrors() {
+	c := RegisterCredentials("mycloud", 0, func(ctx context.Context) (Credential, error) {
+		return Credential{}, fmt.Errorf("the vault is sealed")
+	})
+	_, err := c.Get(context.Background())
+	suite.Regexp("could not refresh the mycloud credential: the vault is sealed", err)
+	suite.Regexp("the vault is sealed", c.Status().LastError)
+
+	// Cancelling the request doesn't wait for the refresh
+	releaseCh := make(chan struct{})
+	defer close(releaseCh)
+	c = RegisterCredentials("slow", 0, func(ctx context.Context) (Credential, error) {
+		<-releaseCh
+		return Credential{Value: "token"}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Get(ctx)
+	suite.Equal(context.Canceled, err)
+	suite.True(c.Status().Refreshing)
+}
+
+func (suite *CredentialsTestSuite) TestRegistry() {
+	RegisterCredentials("b", 0, nil)
+	a := RegisterCredentials("a", 0, nil)
+	c, ok := FindCredentials("a")
+	suite.True(ok)
+	suite.True(a == c)
+	_, ok = FindCredentials("c")
+	suite.False(ok)
+
+	// Registering the same name replaces the credentials
+	replaced := RegisterCredentials("a", 0, nil)
+	all := AllCredentials()
+	if suite.Len(all, 2) {
+		suite.True(replaced == all[0])
+		suite.Equal("b", all[1].Name())
+	}
+}
+
+func (suite *CredentialsTestSuite) TestCredentialsTokens() {
+	token, err := CredentialsTokenFor("a")
+	suite.NoError(err)
+	suite.Len(token, 2*credentialsTokenBytes)
+	again, err := CredentialsTokenFor("a")
+	suite.NoError(err)
+	suite.Equal(token, again)
+	other, err := CredentialsTokenFor("b")
+	suite.NoError(err)
+	suite.NotEqual(token, other)
+
+	owner, ok := CredentialsTokenOwner(token)
+	suite.True(ok)
+	suite.Equal("a", owner)
+	_, ok = CredentialsTokenOwner("wrong")
+	suite.False(ok)
+}
+
+func (suite *CredentialsTestSuite) TestIsCredentialOf() {
+	suite.True(IsCredentialOf("aws", "aws"))
+	suite.True(IsCredentialOf("aws/default", "aws"))
+	suite.False(IsCredentialOf("awsx", "aws"))
+	suite.False(IsCredentialOf("gcp/default", "aws"))
+}
+
+func TestCredentials(t *testing.T) {
+	suite.Run(t, new(CredentialsTestSuite))
+}
@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"context"
+	"time"
+)
+
+// detachedContext carries its parent's values, but not its deadline or
+// cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+// DetachedContext returns a context with ctx's values that isn't canceled when
+// ctx is. It's meant for work that outlives the request that started it (e.g.
+// an asynchronous operation or a shared stream), but which should still be
+// attributed to the request's activity journal.
+func DetachedContext(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+func (c detachedContext) String() string {
+	return "plugin.DetachedContext"
+}
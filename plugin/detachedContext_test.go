@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DetachedContextTestSuite struct {
+	suite.Suite
+}
+
+type detachedContextTestsKey struct{}
+
+func (suite *DetachedContextTestSuite) TestKeepsTheValuesButNotTheCancellation() {
+	parent, cancel := context.WithTimeout(context.WithValue(context.Background(), detachedContextTestsKey{}, "value"), time.Minute)
+	ctx := DetachedContext(parent)
+	cancel()
+
+	suite.Error(parent.Err())
+	suite.NoError(ctx.Err())
+	suite.Nil(ctx.Done())
+	_, ok := ctx.Deadline()
+	suite.False(ok)
+	suite.Equal("value", ctx.Value(detachedContextTestsKey{}))
+
+	// Contexts derived from it can still be canceled
+	derived, cancel := context.WithCancel(ctx)
+	cancel()
+	suite.Error(derived.Err())
+}
+
+func TestDetachedContext(t *testing.T) {
+	suite.Run(t, new(DetachedContextTestSuite))
+}
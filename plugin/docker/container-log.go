@@ -72,8 +72,9 @@ func (clf *containerLogFile) Stream(ctx context.Context) (io.ReadCloser, error)
 		return nil, err
 	}
 
+	end := func() *plugin.StreamEnd { return clf.streamEnd(ctx) }
 	if clf.isTty(ctx) {
-		return rdr, nil
+		return &plugin.EndingReader{ReadCloser: rdr, End: end}, nil
 	}
 
 	r, w := io.Pipe()
@@ -83,5 +84,20 @@ func (clf *containerLogFile) Stream(ctx context.Context) (io.ReadCloser, error)
 		}
 		activity.Record(ctx, "Closing write pipe: %v", w.Close())
 	}()
-	return r, nil
+	return &plugin.EndingReader{ReadCloser: r, End: end}, nil
+}
+
+// streamEnd describes why the container's log stream ended. Docker ends the
+// stream once the container stops, so this reports the container's status and
+// exit code if it's no longer running.
+func (clf *containerLogFile) streamEnd(ctx context.Context) *plugin.StreamEnd {
+	meta, err := clf.client.ContainerInspect(ctx, clf.containerName)
+	if err != nil {
+		activity.Record(ctx, "Error reading info for container %v: %v", clf.containerName, err)
+		return plugin.NewStreamEnd("Docker closed the container's log stream")
+	}
+	if meta.State != nil && !meta.State.Running {
+		return plugin.NewStreamExit("the container "+meta.State.Status, meta.State.ExitCode)
+	}
+	return plugin.NewStreamEnd("Docker closed the container's log stream")
 }
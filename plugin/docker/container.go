@@ -45,10 +45,30 @@ func newContainer(inst types.Container, client *client.Client) *container {
 		SetCtime(startTime).
 		SetAtime(startTime).
 		SetMeta(inst)
+	if lifecycle, ok := containerLifecycle(inst.State); ok {
+		cont.Attributes().SetLifecycle(lifecycle)
+	}
 
 	return cont
 }
 
+func containerLifecycle(state string) (plugin.Lifecycle, bool) {
+	switch state {
+	case "created", "restarting":
+		return plugin.LifecycleProvisioning, true
+	case "running", "paused":
+		return plugin.LifecycleRunning, true
+	case "removing":
+		return plugin.LifecycleStopping, true
+	case "exited":
+		return plugin.LifecycleTerminated, true
+	case "dead":
+		return plugin.LifecycleError, true
+	default:
+		return "", false
+	}
+}
+
 func (c *container) Metadata(ctx context.Context) (plugin.JSONObject, error) {
 	// Use raw to also get the container size.
 	_, raw, err := c.client.ContainerInspectWithRaw(ctx, c.id, true)
@@ -88,11 +108,29 @@ func (c *container) List(ctx context.Context) ([]plugin.Entry, error) {
 	return []plugin.Entry{clf, cm, vol.NewFS("fs", c, 3)}, nil
 }
 
+// Rename implements plugin.Renamable
+func (c *container) Rename(ctx context.Context, newName string) error {
+	activity.Record(ctx, "Renaming container %v to %v", c.id, newName)
+	return c.client.ContainerRename(ctx, c.id, newName)
+}
+
+// HonoredExecOptions implements plugin.ExecOptionHonorer
+func (c *container) HonoredExecOptions() []string {
+	return []string{plugin.EnvExecOption, plugin.CwdExecOption}
+}
+
 func (c *container) Exec(ctx context.Context, cmd string, args []string, opts plugin.ExecOptions) (plugin.ExecCommand, error) {
 	command := append([]string{cmd}, args...)
 	activity.Record(ctx, "Exec %v on %v", command, c.Name())
 
-	cfg := types.ExecConfig{Cmd: command, AttachStdout: true, AttachStderr: true, Tty: opts.Tty}
+	cfg := types.ExecConfig{
+		Cmd:          command,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          opts.Tty,
+		Env:          opts.Environ(),
+		WorkingDir:   opts.Cwd,
+	}
 	if opts.Stdin != nil || opts.Tty {
 		cfg.AttachStdin = true
 	}
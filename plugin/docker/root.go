@@ -6,6 +6,8 @@ package docker
 
 import (
 	"context"
+	"os"
+	"strings"
 
 	"github.com/docker/docker/client"
 	"github.com/puppetlabs/wash/plugin"
@@ -36,6 +38,19 @@ func (r *Root) Init(map[string]interface{}) error {
 	return nil
 }
 
+// Requirements requires the Docker daemon's socket when the daemon's accessed
+// via a local socket
+func (r *Root) Requirements() plugin.Requirements {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = client.DefaultDockerHost
+	}
+	if !strings.HasPrefix(host, "unix://") {
+		return plugin.Requirements{}
+	}
+	return plugin.Requirements{Files: []string{strings.TrimPrefix(host, "unix://")}}
+}
+
 // Schema returns the root's schema
 func (r *Root) Schema() *plugin.EntrySchema {
 	return plugin.NewEntrySchema(r, "docker").IsSingleton()
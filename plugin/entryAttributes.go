@@ -3,7 +3,9 @@ package plugin
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/Benchkram/errz"
@@ -52,15 +54,20 @@ to do something like
 	entry.SetAttributes(attr)
 */
 type EntryAttributes struct {
-	atime   time.Time
-	mtime   time.Time
-	ctime   time.Time
-	crtime  time.Time
-	mode    os.FileMode
-	hasMode bool
-	size    uint64
-	hasSize bool
-	meta    JSONObject
+	atime       time.Time
+	mtime       time.Time
+	ctime       time.Time
+	crtime      time.Time
+	mode        os.FileMode
+	hasMode     bool
+	size        uint64
+	hasSize     bool
+	owner       string
+	group       string
+	lifecycle   Lifecycle
+	xattrs      map[string]string
+	contentType string
+	meta        JSONObject
 }
 
 // We can't just export EntryAttributes' fields because there's no way
@@ -179,6 +186,129 @@ func (a *EntryAttributes) SetSize(size uint64) *EntryAttributes {
 	return a
 }
 
+// HasOwner returns true if the entry has an owner
+func (a *EntryAttributes) HasOwner() bool {
+	return a.owner != ""
+}
+
+// Owner returns the entry's owner. It's the owner's name in the plugin's
+// backend (e.g. an IAM user or a container's user), not a local user. FUSE
+// maps it to a local user (see fuse.Ownership).
+func (a *EntryAttributes) Owner() string {
+	return a.owner
+}
+
+// SetOwner sets the entry's owner
+func (a *EntryAttributes) SetOwner(owner string) *EntryAttributes {
+	a.owner = owner
+	return a
+}
+
+// HasGroup returns true if the entry has a group
+func (a *EntryAttributes) HasGroup() bool {
+	return a.group != ""
+}
+
+// Group returns the entry's group. Like Owner, it's the group's name in the
+// plugin's backend.
+func (a *EntryAttributes) Group() string {
+	return a.group
+}
+
+// SetGroup sets the entry's group
+func (a *EntryAttributes) SetGroup(group string) *EntryAttributes {
+	a.group = group
+	return a
+}
+
+// Lifecycle is the lifecycle state of an entry that represents a resource
+// that takes a while to change state, like a VM, container or pod. It lets
+// users tell a booting instance from a broken one.
+type Lifecycle string
+
+// These are the possible lifecycle states. Plugins map their resources' own
+// states onto them, e.g. a stopped VM is terminated.
+const (
+	LifecycleProvisioning Lifecycle = "provisioning"
+	LifecycleRunning      Lifecycle = "running"
+	LifecycleStopping     Lifecycle = "stopping"
+	LifecycleTerminated   Lifecycle = "terminated"
+	LifecycleError        Lifecycle = "error"
+)
+
+var lifecycles = []Lifecycle{
+	LifecycleProvisioning,
+	LifecycleRunning,
+	LifecycleStopping,
+	LifecycleTerminated,
+	LifecycleError,
+}
+
+func isLifecycle(state Lifecycle) bool {
+	for _, lifecycle := range lifecycles {
+		if state == lifecycle {
+			return true
+		}
+	}
+	return false
+}
+
+// HasLifecycle returns true if the entry has a lifecycle state
+func (a *EntryAttributes) HasLifecycle() bool {
+	return a.lifecycle != ""
+}
+
+// Lifecycle returns the entry's lifecycle state
+func (a *EntryAttributes) Lifecycle() Lifecycle {
+	return a.lifecycle
+}
+
+// SetLifecycle sets the entry's lifecycle state. It will panic if state
+// isn't one of the Lifecycle* constants.
+func (a *EntryAttributes) SetLifecycle(state Lifecycle) *EntryAttributes {
+	if !isLifecycle(state) {
+		panic(fmt.Sprintf("plugin.EntryAttributes.SetLifecycle: unknown lifecycle state %q", state))
+	}
+	a.lifecycle = state
+	return a
+}
+
+// HasXattrs returns true if the entry has extended attributes
+func (a *EntryAttributes) HasXattrs() bool {
+	return len(a.xattrs) > 0
+}
+
+// Xattrs returns the entry's extended attributes, keyed by their names. The
+// names don't include a namespace. FUSE exposes them in the user namespace,
+// e.g. the foo extended attribute is user.foo.
+func (a *EntryAttributes) Xattrs() map[string]string {
+	return a.xattrs
+}
+
+// SetXattrs sets the entry's extended attributes
+func (a *EntryAttributes) SetXattrs(xattrs map[string]string) *EntryAttributes {
+	a.xattrs = xattrs
+	return a
+}
+
+// HasContentType returns true if the entry has a content type
+func (a *EntryAttributes) HasContentType() bool {
+	return a.contentType != ""
+}
+
+// ContentType returns the media type of the entry's content, e.g.
+// application/json
+func (a *EntryAttributes) ContentType() string {
+	return a.contentType
+}
+
+// SetContentType sets the media type of the entry's content. Commands like
+// `wash cat` use it to render the content.
+func (a *EntryAttributes) SetContentType(contentType string) *EntryAttributes {
+	a.contentType = contentType
+	return a
+}
+
 // Meta returns the entry's meta attribute. If a.SetMeta(obj) was called,
 // then this returns obj serialized to JSONObject. Otherwise, it returns
 // a.ToMap(false).
@@ -230,6 +360,21 @@ func (a *EntryAttributes) ToMap(includeMeta bool) map[string]interface{} {
 	if a.HasSize() {
 		mp["size"] = a.Size()
 	}
+	if a.HasOwner() {
+		mp["owner"] = a.Owner()
+	}
+	if a.HasGroup() {
+		mp["group"] = a.Group()
+	}
+	if a.HasLifecycle() {
+		mp["lifecycle"] = string(a.Lifecycle())
+	}
+	if a.HasXattrs() {
+		mp["xattrs"] = a.Xattrs()
+	}
+	if a.HasContentType() {
+		mp["content_type"] = a.ContentType()
+	}
 	if includeMeta {
 		mp["meta"] = a.Meta()
 	}
@@ -256,6 +401,12 @@ func (a *EntryAttributes) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return fmt.Errorf("plugin.EntryAttributes.UnmarshalJSON received a non-JSON object")
 	}
+	return a.fromMap(mp)
+}
+
+// fromMap sets the attributes in mp, which is a decoded JSON object (or an
+// object that was decoded from one of the other external plugin transports)
+func (a *EntryAttributes) fromMap(mp map[string]interface{}) error {
 	if atime, ok := mp["atime"]; ok {
 		t, err := munge.ToTime(atime)
 		if err != nil {
@@ -300,6 +451,52 @@ func (a *EntryAttributes) UnmarshalJSON(data []byte) error {
 		}
 		a.SetSize(sz)
 	}
+	if owner, ok := mp["owner"]; ok {
+		str, err := mungeOwner(owner)
+		if err != nil {
+			return attrMungeError("owner", fmt.Errorf("owner was unexpected type %T: %v", owner, owner))
+		}
+		a.SetOwner(str)
+	}
+	if group, ok := mp["group"]; ok {
+		str, err := mungeOwner(group)
+		if err != nil {
+			return attrMungeError("group", fmt.Errorf("group was unexpected type %T: %v", group, group))
+		}
+		a.SetGroup(str)
+	}
+	if lifecycle, ok := mp["lifecycle"]; ok {
+		str, isStr := lifecycle.(string)
+		if !isStr {
+			return attrMungeError("lifecycle", fmt.Errorf("lifecycle was unexpected type %T: %v", lifecycle, lifecycle))
+		}
+		if !isLifecycle(Lifecycle(str)) {
+			return attrMungeError("lifecycle", fmt.Errorf("unknown lifecycle state %q; it must be one of %v", str, lifecycles))
+		}
+		a.SetLifecycle(Lifecycle(str))
+	}
+	if rawXattrs, ok := mp["xattrs"]; ok {
+		obj, isObj := rawXattrs.(map[string]interface{})
+		if !isObj {
+			return attrMungeError("xattrs", fmt.Errorf("xattrs was unexpected type %T: %v", rawXattrs, rawXattrs))
+		}
+		xattrs := make(map[string]string, len(obj))
+		for name, value := range obj {
+			str, isStr := value.(string)
+			if !isStr {
+				return attrMungeError("xattrs", fmt.Errorf("the value of the %v xattr was unexpected type %T: %v", name, value, value))
+			}
+			xattrs[name] = str
+		}
+		a.SetXattrs(xattrs)
+	}
+	if contentType, ok := mp["content_type"]; ok {
+		str, isStr := contentType.(string)
+		if !isStr {
+			return attrMungeError("content_type", fmt.Errorf("content_type was unexpected type %T: %v", contentType, contentType))
+		}
+		a.SetContentType(str)
+	}
 	if rawMeta, ok := mp["meta"]; ok {
 		meta, isObj := rawMeta.(JSONObject)
 		if !isObj {
@@ -310,6 +507,20 @@ func (a *EntryAttributes) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// mungeOwner munges an owner or group, which is either a name or a numeric
+// ID (e.g. a uid), into a name. Numeric IDs are formatted in decimal.
+func mungeOwner(v interface{}) (string, error) {
+	switch owner := v.(type) {
+	case string:
+		return owner, nil
+	case float64:
+		if owner >= 0 && owner == math.Trunc(owner) {
+			return strconv.FormatUint(uint64(owner), 10), nil
+		}
+	}
+	return "", fmt.Errorf("expected a name or a non-negative integer")
+}
+
 func attrMungeError(name string, err error) error {
 	return fmt.Errorf("plugin.EntryAttributes.UnmarshalJSON: could not munge the %v attribute: %v", name, err)
 }
@@ -129,6 +129,69 @@ func (suite *EntryAttributesTestSuite) TestEntryAttributes() {
 	suite.Equal(expectedMp, attr.ToMap(true))
 	doUnmarshalJSONTests()
 
+	// Tests for Owner
+	suite.Equal(false, attr.HasOwner())
+	suite.Equal(expectedMp, attr.ToMap(true))
+	attr.SetOwner("ubuntu")
+	expectedMp["owner"] = "ubuntu"
+	suite.Equal("ubuntu", attr.Owner())
+	suite.Equal(true, attr.HasOwner())
+	suite.Equal(expectedMp, attr.ToMap(true))
+	doUnmarshalJSONTests()
+
+	// Tests for Group
+	suite.Equal(false, attr.HasGroup())
+	suite.Equal(expectedMp, attr.ToMap(true))
+	attr.SetGroup("admins")
+	expectedMp["group"] = "admins"
+	suite.Equal("admins", attr.Group())
+	suite.Equal(true, attr.HasGroup())
+	suite.Equal(expectedMp, attr.ToMap(true))
+	doUnmarshalJSONTests()
+
+	// Tests for Lifecycle
+	suite.Equal(false, attr.HasLifecycle())
+	suite.Equal(expectedMp, attr.ToMap(true))
+	suite.Panics(func() { attr.SetLifecycle("booting") })
+	attr.SetLifecycle(LifecycleProvisioning)
+	expectedMp["lifecycle"] = "provisioning"
+	suite.Equal(LifecycleProvisioning, attr.Lifecycle())
+	suite.Equal(true, attr.HasLifecycle())
+	suite.Equal(expectedMp, attr.ToMap(true))
+	doUnmarshalJSONTests()
+	var unmarshalled EntryAttributes
+	suite.Regexp("unknown lifecycle state \"booting\"", unmarshalled.UnmarshalJSON([]byte(`{"lifecycle":"booting"}`)))
+
+	var numericOwner EntryAttributes
+	if suite.NoError(numericOwner.UnmarshalJSON([]byte(`{"owner":1000,"group":0}`))) {
+		suite.Equal("1000", numericOwner.Owner())
+		suite.Equal("0", numericOwner.Group())
+	}
+	suite.Regexp("could not munge the owner attribute", numericOwner.UnmarshalJSON([]byte(`{"owner":-1}`)))
+
+	// Tests for Xattrs
+	suite.Equal(false, attr.HasXattrs())
+	suite.Equal(expectedMp, attr.ToMap(true))
+	xattrs := map[string]string{"wash.image": "nginx"}
+	attr.SetXattrs(xattrs)
+	expectedMp["xattrs"] = xattrs
+	suite.Equal(xattrs, attr.Xattrs())
+	suite.Equal(true, attr.HasXattrs())
+	suite.Equal(expectedMp, attr.ToMap(true))
+	doUnmarshalJSONTests()
+	suite.Regexp("value of the foo xattr was unexpected type", unmarshalled.UnmarshalJSON([]byte(`{"xattrs":{"foo":1}}`)))
+
+	// Tests for ContentType
+	suite.Equal(false, attr.HasContentType())
+	suite.Equal(expectedMp, attr.ToMap(true))
+	attr.SetContentType("application/json")
+	expectedMp["content_type"] = "application/json"
+	suite.Equal("application/json", attr.ContentType())
+	suite.Equal(true, attr.HasContentType())
+	suite.Equal(expectedMp, attr.ToMap(true))
+	doUnmarshalJSONTests()
+	suite.Regexp("content_type was unexpected type", unmarshalled.UnmarshalJSON([]byte(`{"content_type":1}`)))
+
 	// Tests for Meta
 	suite.Equal(JSONObject{}, attr.Meta())
 	meta := JSONObject{"foo": "bar"}
@@ -43,6 +43,7 @@ type EntryBase struct {
 	ttl             [3]time.Duration
 	wrappedTypesMap SchemaMap
 	prefetched      bool
+	deprecations    map[string]ActionDeprecation
 }
 
 // NewEntry creates a new entry
@@ -109,6 +110,10 @@ func (e *EntryBase) isPrefetched() bool {
 	return e.prefetched
 }
 
+func (e *EntryBase) deprecatedActions() map[string]ActionDeprecation {
+	return e.deprecations
+}
+
 // OTHER METHODS USED TO FACILITATE PLUGIN DEVELOPMENT
 // AND TESTING
 
@@ -157,6 +162,18 @@ func (e *EntryBase) SetSlashReplacer(char rune) *EntryBase {
 	return e
 }
 
+// DeprecateAction marks the entry's action as deprecated. Wash will still
+// invoke a deprecated action, but it will warn the user that the action's
+// deprecated. Note that deprecated actions should also be marked as such
+// in the entry's schema (see EntrySchema#DeprecateAction).
+func (e *EntryBase) DeprecateAction(action Action, deprecation ActionDeprecation) *EntryBase {
+	if e.deprecations == nil {
+		e.deprecations = make(map[string]ActionDeprecation)
+	}
+	e.deprecations[action.Name] = deprecation
+	return e
+}
+
 // SetTTLOf sets the specified op's TTL
 func (e *EntryBase) SetTTLOf(op defaultOpCode, ttl time.Duration) *EntryBase {
 	e.ttl[op] = ttl
@@ -37,7 +37,7 @@ func Schema(e Entry) (*EntrySchema, error) {
 		schema := NewEntrySchema(t, registrySchemaLabel).IsSingleton()
 		schema.graph = linkedhashmap.New()
 		schema.graph.Put(TypeID(t), &schema.entrySchema)
-		for _, root := range t.pluginRoots {
+		for _, root := range t.roots() {
 			childSchema, err := Schema(root)
 			if err != nil {
 				return nil, fmt.Errorf("failed to retrieve the %v plugin's schema: %v", root.name(), err)
@@ -68,12 +68,14 @@ func Schema(e Entry) (*EntrySchema, error) {
 }
 
 type entrySchema struct {
-	Label               string      `json:"label"`
-	Singleton           bool        `json:"singleton"`
-	Actions             []string    `json:"actions"`
-	MetaAttributeSchema *JSONSchema `json:"meta_attribute_schema"`
-	MetadataSchema      *JSONSchema `json:"metadata_schema"`
-	Children            []string    `json:"children"`
+	Label     string   `json:"label"`
+	Singleton bool     `json:"singleton"`
+	Actions   []string `json:"actions"`
+	// DeprecatedActions is a map of <action_name> => <deprecation>
+	DeprecatedActions   map[string]ActionDeprecation `json:"deprecated_actions,omitempty"`
+	MetaAttributeSchema *JSONSchema                  `json:"meta_attribute_schema"`
+	MetadataSchema      *JSONSchema                  `json:"metadata_schema"`
+	Children            []string                     `json:"children"`
 }
 
 // EntrySchema represents an entry's schema. Use plugin.NewEntrySchema
@@ -103,8 +105,9 @@ func NewEntrySchema(e Entry, label string) *EntrySchema {
 	}
 	s := &EntrySchema{
 		entrySchema: entrySchema{
-			Label:   label,
-			Actions: SupportedActionsOf(e),
+			Label:             label,
+			Actions:           SupportedActionsOf(e),
+			DeprecatedActions: DeprecatedActionsOf(e),
 		},
 		// The meta attribute's empty by default
 		metaAttributeSchemaObj: struct{}{},
@@ -149,6 +152,16 @@ func (s *EntrySchema) IsSingleton() *EntrySchema {
 	return s
 }
 
+// DeprecateAction marks the action as deprecated in the entry's schema.
+// See EntryBase#DeprecateAction for more details.
+func (s *EntrySchema) DeprecateAction(action Action, deprecation ActionDeprecation) *EntrySchema {
+	if s.entrySchema.DeprecatedActions == nil {
+		s.entrySchema.DeprecatedActions = make(map[string]ActionDeprecation)
+	}
+	s.entrySchema.DeprecatedActions[action.Name] = deprecation
+	return s
+}
+
 // SetMetaAttributeSchema sets the meta attribute's schema. obj is an empty struct
 // that will be marshalled into a JSON schema. SetMetaSchema will panic
 // if obj is not a struct.
@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+)
+
+// ErrorEntry represents something that couldn't be listed, e.g. a region that
+// errored when listed via ParallelList. Reading it returns the error message,
+// which is also included in its meta attribute. It lets a parent show the
+// error without hiding its other children.
+//
+// Parents that can contain error entries should include the ErrorEntry's
+// schema in their ChildSchemas, e.g.
+//	return []*plugin.EntrySchema{
+//		(&instance{}).Schema(),
+//		(&plugin.ErrorEntry{}).Schema(),
+//	}
+type ErrorEntry struct {
+	EntryBase
+	err error
+}
+
+// ErrorEntryMeta is the ErrorEntry's meta attribute
+type ErrorEntryMeta struct {
+	Error string `json:"error"`
+}
+
+// NewErrorEntry creates a new error entry with the given name
+func NewErrorEntry(name string, err error) *ErrorEntry {
+	e := &ErrorEntry{
+		EntryBase: NewEntry(name),
+		err:       err,
+	}
+	e.DisableDefaultCaching()
+	e.Attributes().
+		SetSize(uint64(len(e.content()))).
+		SetMeta(ErrorEntryMeta{Error: err.Error()})
+	return e
+}
+
+// Err returns the entry's error
+func (e *ErrorEntry) Err() error {
+	return e.err
+}
+
+func (e *ErrorEntry) content() []byte {
+	return []byte(e.err.Error() + "\n")
+}
+
+// Schema returns the error entry's schema
+func (e *ErrorEntry) Schema() *EntrySchema {
+	return NewEntrySchema(e, "error").
+		SetMetaAttributeSchema(ErrorEntryMeta{})
+}
+
+// Open returns the error message
+func (e *ErrorEntry) Open(ctx context.Context) (SizedReader, error) {
+	return bytes.NewReader(e.content()), nil
+}
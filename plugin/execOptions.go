@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// These are the exec options that entries must declare that they honor
+// before they can be used. Unlike Tty and Elevate, Wash can't safely ignore
+// them because the command would run with the wrong environment, so the API
+// rejects execs that set an option that the entry doesn't honor.
+const (
+	// EnvExecOption corresponds to ExecOptions#Env
+	EnvExecOption = "env"
+	// CwdExecOption corresponds to ExecOptions#Cwd
+	CwdExecOption = "cwd"
+)
+
+// ExecOptionHonorer is an Execable entry that honors some of the exec options
+// listed above.
+type ExecOptionHonorer interface {
+	Execable
+	HonoredExecOptions() []string
+}
+
+// HonoredExecOptions returns the exec options that e honors
+func HonoredExecOptions(e Execable) []string {
+	if h, ok := e.(ExecOptionHonorer); ok {
+		return h.HonoredExecOptions()
+	}
+	return nil
+}
+
+func isKnownExecOption(option string) bool {
+	return option == EnvExecOption || option == CwdExecOption
+}
+
+// CheckExecOptions returns an error if opts sets an exec option that e doesn't
+// honor
+func CheckExecOptions(e Execable, opts ExecOptions) error {
+	honored := make(map[string]bool)
+	for _, option := range HonoredExecOptions(e) {
+		honored[option] = true
+	}
+	var unsupported []string
+	if len(opts.Env) > 0 && !honored[EnvExecOption] {
+		unsupported = append(unsupported, EnvExecOption)
+	}
+	if opts.Cwd != "" && !honored[CwdExecOption] {
+		unsupported = append(unsupported, CwdExecOption)
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("the entry does not support the %v exec option(s)", strings.Join(unsupported, ", "))
+	if len(honored) > 0 {
+		msg += fmt.Sprintf("; it only supports %v", strings.Join(HonoredExecOptions(e), ", "))
+	}
+	return errors.New(msg)
+}
+
+// Environ returns opts.Env as a sorted list of "key=value" strings, like
+// os.Environ
+func (opts ExecOptions) Environ() []string {
+	env := make([]string, 0, len(opts.Env))
+	for k, v := range opts.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExecOptionsTestSuite struct {
+	suite.Suite
+}
+
+type mockExecOptionsEntry struct {
+	EntryBase
+	honored []string
+}
+
+func (e *mockExecOptionsEntry) Schema() *EntrySchema {
+	return nil
+}
+
+func (e *mockExecOptionsEntry) Exec(context.Context, string, []string, ExecOptions) (ExecCommand, error) {
+	return nil, nil
+}
+
+func (e *mockExecOptionsEntry) HonoredExecOptions() []string {
+	return e.honored
+}
+
+type mockPlainExecEntry struct {
+	EntryBase
+}
+
+func (e *mockPlainExecEntry) Schema() *EntrySchema {
+	return nil
+}
+
+func (e *mockPlainExecEntry) Exec(context.Context, string, []string, ExecOptions) (ExecCommand, error) {
+	return nil, nil
+}
+
+func (suite *ExecOptionsTestSuite) TestCheckExecOptions_EntriesThatDontHonorOptions() {
+	e := &mockPlainExecEntry{EntryBase: NewEntry("foo")}
+	suite.NoError(CheckExecOptions(e, ExecOptions{Tty: true, Elevate: true}))
+	suite.EqualError(
+		CheckExecOptions(e, ExecOptions{Env: map[string]string{"FOO": "1"}, Cwd: "/srv"}),
+		"the entry does not support the env, cwd exec option(s)",
+	)
+}
+
+func (suite *ExecOptionsTestSuite) TestCheckExecOptions_EntriesThatHonorSomeOptions() {
+	e := &mockExecOptionsEntry{EntryBase: NewEntry("foo"), honored: []string{CwdExecOption}}
+	suite.NoError(CheckExecOptions(e, ExecOptions{Cwd: "/srv"}))
+	suite.EqualError(
+		CheckExecOptions(e, ExecOptions{Env: map[string]string{"FOO": "1"}, Cwd: "/srv"}),
+		"the entry does not support the env exec option(s); it only supports cwd",
+	)
+
+	e.honored = []string{EnvExecOption, CwdExecOption}
+	suite.NoError(CheckExecOptions(e, ExecOptions{Env: map[string]string{"FOO": "1"}, Cwd: "/srv"}))
+}
+
+func (suite *ExecOptionsTestSuite) TestEnviron() {
+	suite.Empty(ExecOptions{}.Environ())
+	opts := ExecOptions{Env: map[string]string{"FOO": "1", "BAR": "a=b"}}
+	suite.Equal([]string{"BAR=a=b", "FOO=1"}, opts.Environ())
+}
+
+func TestExecOptions(t *testing.T) {
+	suite.Run(t, new(ExecOptionsTestSuite))
+}
@@ -0,0 +1,207 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/plugin/internal"
+)
+
+// ExecPolicy governs the commands that are executed on entries via the API.
+// It's meant for regulated environments that use Wash as a bastion. It's set
+// via the exec_policy key in wash.yaml.
+type ExecPolicy struct {
+	// Banner is shown before exec sessions, e.g. a notice that the session is
+	// recorded. Execs are refused until the user consents to it.
+	Banner string `json:"banner,omitempty" mapstructure:"banner"`
+	// Hook is the path to an executable that's invoked before each exec to
+	// allow or deny it. It's passed the ExecPolicyRequest as JSON on stdin,
+	// and prints its ExecPolicyDecision as JSON on stdout. Execs are denied
+	// if the hook fails.
+	Hook string `json:"hook,omitempty" mapstructure:"hook"`
+}
+
+func (p ExecPolicy) validate() error {
+	if p.Hook == "" {
+		return nil
+	}
+	info, err := os.Stat(p.Hook)
+	if err != nil {
+		return fmt.Errorf("invalid exec policy hook %v: %v", p.Hook, err)
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return fmt.Errorf("invalid exec policy hook %v: it isn't an executable file", p.Hook)
+	}
+	return nil
+}
+
+// ExecPolicyRequest describes a command that's about to be executed on the
+// entry at Path. Custom actions (see Runnable) are governed by the policy too,
+// in which case Cmd is the action's name.
+type ExecPolicyRequest struct {
+	Path string   `json:"path"`
+	Cmd  string   `json:"cmd"`
+	Args []string `json:"args"`
+	// CustomAction is true if Cmd is a custom action instead of a command
+	CustomAction bool `json:"custom_action,omitempty"`
+	// Justification is why the user's running the command. It's empty unless
+	// the user supplied one.
+	Justification string `json:"justification,omitempty"`
+}
+
+// These are the possible decisions of an exec policy hook
+const (
+	ExecAllowed               = "allow"
+	ExecDenied                = "deny"
+	ExecJustificationRequired = "justify"
+)
+
+// ExecPolicyDecision is an exec policy hook's decision. Reason is shown to the
+// user if the exec's denied, or if it requires a justification.
+type ExecPolicyDecision struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ExecConsentRequiredError is returned by CheckExecPolicy if the user hasn't
+// consented to the exec policy's banner
+type ExecConsentRequiredError struct {
+	Banner string
+}
+
+func (e ExecConsentRequiredError) Error() string {
+	return "you must consent to the exec banner before executing commands:\n" + e.Banner
+}
+
+// ExecJustificationRequiredError is returned by CheckExecPolicy if the exec
+// policy hook requires a justification, but the user didn't supply one
+type ExecJustificationRequiredError struct {
+	Reason string
+}
+
+func (e ExecJustificationRequiredError) Error() string {
+	msg := "the exec policy requires a justification"
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+// ExecDeniedError is returned by CheckExecPolicy if the exec policy hook
+// denied the exec
+type ExecDeniedError struct {
+	Reason string
+}
+
+func (e ExecDeniedError) Error() string {
+	msg := "the exec policy denied the command"
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+// execPolicyHookTimeout is how long the exec policy hook has to decide
+const execPolicyHookTimeout = 10 * time.Second
+
+var globalExecPolicy ExecPolicy
+var globalExecPolicyMux sync.Mutex
+
+// SetExecPolicy sets the exec policy. It's meant to be called by the Wash
+// server before it starts serving requests.
+func SetExecPolicy(p ExecPolicy) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	globalExecPolicyMux.Lock()
+	defer globalExecPolicyMux.Unlock()
+	globalExecPolicy = p
+	return nil
+}
+
+// CheckExecPolicy returns an error if req isn't allowed by the exec policy.
+// consented is true if the user consented to the policy's banner. The decision
+// (and the justification, if any) is recorded in ctx's activity journal.
+func CheckExecPolicy(ctx context.Context, req ExecPolicyRequest, consented bool) error {
+	globalExecPolicyMux.Lock()
+	policy := globalExecPolicy
+	globalExecPolicyMux.Unlock()
+
+	if policy.Banner != "" {
+		if !consented {
+			return ExecConsentRequiredError{Banner: policy.Banner}
+		}
+		activity.Record(ctx, "Exec policy: the user consented to the banner before executing %v on %v", req.Cmd, req.Path)
+	}
+	if policy.Hook == "" {
+		return nil
+	}
+
+	decision, err := invokeExecPolicyHook(ctx, policy.Hook, req)
+	if err != nil {
+		// Fail closed so that a broken hook can't let commands through
+		activity.Warnf(ctx, "Exec policy: denying %v on %v because the hook failed: %v", req.Cmd, req.Path, err)
+		return ExecDeniedError{Reason: fmt.Sprintf("the exec policy hook failed: %v", err)}
+	}
+	switch decision.Decision {
+	case ExecAllowed:
+		// Pass-thru
+	case ExecJustificationRequired:
+		if req.Justification == "" {
+			activity.Record(ctx, "Exec policy: %v on %v requires a justification: %v", req.Cmd, req.Path, decision.Reason)
+			return ExecJustificationRequiredError{Reason: decision.Reason}
+		}
+	default:
+		activity.Record(ctx, "Exec policy: denied %v on %v: %v", req.Cmd, req.Path, decision.Reason)
+		return ExecDeniedError{Reason: decision.Reason}
+	}
+	if req.Justification != "" {
+		activity.Record(ctx, "Exec policy: allowed %v on %v with the justification %q", req.Cmd, req.Path, req.Justification)
+	} else {
+		activity.Record(ctx, "Exec policy: allowed %v on %v", req.Cmd, req.Path)
+	}
+	return nil
+}
+
+const execPolicyDecisionFormat = "{\"decision\":\"allow\"}"
+
+func invokeExecPolicyHook(ctx context.Context, hook string, req ExecPolicyRequest) (ExecPolicyDecision, error) {
+	var decision ExecPolicyDecision
+	input, err := json.Marshal(req)
+	if err != nil {
+		return decision, fmt.Errorf("could not marshal the request: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, execPolicyHookTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	command := internal.NewCommand(ctx, hook)
+	command.SetStdin(bytes.NewReader(input))
+	command.SetStdout(&stdout)
+	command.SetStderr(&stderr)
+	if err := command.Run(); err != nil {
+		if ctx.Err() != nil {
+			return decision, fmt.Errorf("it didn't decide within %v", execPolicyHookTimeout)
+		}
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			return decision, fmt.Errorf("%v: %v", err, output)
+		}
+		return decision, err
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &decision); err != nil {
+		return decision, fmt.Errorf("could not decode its decision from stdout: %v. It should look like %v", err, execPolicyDecisionFormat)
+	}
+	switch decision.Decision {
+	case ExecAllowed, ExecDenied, ExecJustificationRequired:
+		return decision, nil
+	default:
+		return decision, fmt.Errorf("unknown decision %q; it must be %v, %v or %v", decision.Decision, ExecAllowed, ExecDenied, ExecJustificationRequired)
+	}
+}
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExecPolicyTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ExecPolicyTestSuite) TearDownTest() {
+	suite.NoError(SetExecPolicy(ExecPolicy{}))
+}
+
+func (suite *ExecPolicyTestSuite) check(cmd string, justification string, consented bool) error {
+	req := ExecPolicyRequest{Path: "/docker/containers/foo", Cmd: cmd, Justification: justification}
+	return CheckExecPolicy(context.Background(), req, consented)
+}
+
+func (suite *ExecPolicyTestSuite) TestSetExecPolicyValidatesTheHook() {
+	suite.Regexp("invalid exec policy hook testdata/missing", SetExecPolicy(ExecPolicy{Hook: "testdata/missing"}))
+	suite.Regexp("it isn't an executable file", SetExecPolicy(ExecPolicy{Hook: "testdata/notfile"}))
+	suite.Regexp("it isn't an executable file", SetExecPolicy(ExecPolicy{Hook: "testdata/noexec"}))
+}
+
+func (suite *ExecPolicyTestSuite) TestNoPolicyAllowsEverything() {
+	suite.NoError(suite.check("rm", "", false))
+}
+
+func (suite *ExecPolicyTestSuite) TestBannerRequiresConsent() {
+	suite.NoError(SetExecPolicy(ExecPolicy{Banner: "This session is recorded"}))
+	err := suite.check("ls", "", false)
+	if suite.IsType(ExecConsentRequiredError{}, err) {
+		suite.Equal("This session is recorded", err.(ExecConsentRequiredError).Banner)
+	}
+	suite.NoError(suite.check("ls", "", true))
+}
+
+func (suite *ExecPolicyTestSuite) TestHookDecides() {
+	suite.NoError(SetExecPolicy(ExecPolicy{Hook: "testdata/execPolicyHook.sh"}))
+
+	suite.NoError(suite.check("ls", "", false))
+	suite.Equal(ExecDeniedError{Reason: "rm is not allowed"}, suite.check("rm", "", false))
+	suite.Equal(ExecJustificationRequiredError{Reason: "reboots need a change ticket"}, suite.check("reboot", "", false))
+	suite.NoError(suite.check("reboot", "TICKET-1", false))
+}
+
+func (suite *ExecPolicyTestSuite) TestHookFailuresDeny() {
+	suite.NoError(SetExecPolicy(ExecPolicy{Hook: "testdata/execPolicyHook.sh"}))
+
+	err := suite.check("fail", "", false)
+	if suite.IsType(ExecDeniedError{}, err) {
+		suite.Regexp("the exec policy hook failed: exit status 1: boom", err)
+	}
+	err = suite.check("garbage", "", false)
+	if suite.IsType(ExecDeniedError{}, err) {
+		suite.Regexp("could not decode its decision from stdout", err)
+	}
+}
+
+func TestExecPolicy(t *testing.T) {
+	suite.Run(t, new(ExecPolicyTestSuite))
+}
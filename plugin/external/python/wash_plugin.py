@@ -0,0 +1,322 @@
+"""Helpers for writing Wash external plugin scripts in Python.
+
+Copy this file and wash_protocol.py next to your plugin script. A minimal
+plugin looks like
+
+    import wash_plugin
+
+    def init(config):
+        return wash_plugin.entry("myplugin", ["list"])
+
+    def list_(invocation):
+        return [wash_plugin.entry("foo", [["read", "some content"]])]
+
+    wash_plugin.run({"init": init, "list": list_})
+
+See https://puppetlabs.github.io/wash/docs/external-plugins for the protocol
+that these helpers implement.
+"""
+
+import base64
+import json
+import os
+import sys
+from datetime import datetime
+
+import wash_protocol as protocol
+
+
+class ProtocolError(Exception):
+    """Raised when the plugin script was invoked incorrectly, or when it
+    returned something that's not part of the protocol."""
+
+
+class PluginError(Exception):
+    """Raised by handlers to report a structured error, which lets Wash tell
+    e.g. missing entries apart from other failures. kind is one of
+    protocol.ERROR_KINDS. Set retryable if the invocation's worth retrying,
+    e.g. because the API was rate-limiting the plugin."""
+
+    def __init__(self, kind, message, retryable=False):
+        super(PluginError, self).__init__(message)
+        if kind not in protocol.ERROR_KINDS:
+            raise ProtocolError("%s is not a valid error kind. Valid kinds are %s" % (kind, ", ".join(protocol.ERROR_KINDS)))
+        self.kind = kind
+        self.message = message
+        self.retryable = retryable
+
+    def to_json(self):
+        """Returns the error as JSON, which is how it's printed to stderr"""
+        error = {"kind": self.kind, "message": self.message, "retryable": self.retryable}
+        _check_keys("error", error, protocol.ERROR_KEYS)
+        return json.dumps(error)
+
+
+class Invocation(object):
+    """A parsed plugin script invocation. init invocations have an empty
+    path and state; their only argument is the plugin's config."""
+
+    def __init__(self, method, path, state, args):
+        self.method = method
+        self.path = path
+        self.state = state
+        self.args = args
+
+
+def parse_args(argv=None, custom_actions=()):
+    """Parses the plugin script's arguments, which are
+    <method> <path> <state> <args...> (or init <config>). method can also be
+    one of the custom_actions. States that were spilled to disk are read from
+    their file."""
+    if argv is None:
+        argv = sys.argv[1:]
+    if not argv:
+        raise ProtocolError("the method must be provided")
+    method = argv[0]
+    if method not in protocol.METHODS and method not in custom_actions:
+        raise ProtocolError("unknown method %s" % method)
+    if method == "init":
+        return Invocation(method, "", "", argv[1:])
+    if len(argv) < 3:
+        raise ProtocolError("%s expects <path> <state> <args...>" % method)
+    state = argv[2]
+    state_file = os.environ.get(protocol.STATE_FILE_ENV_VAR)
+    if state_file:
+        with open(state_file) as f:
+            state = f.read()
+    return Invocation(method, argv[1], state, argv[3:])
+
+
+def check_protocol_version():
+    """Raises a ProtocolError if Wash speaks a different version of the
+    protocol than this library. Wash servers that predate protocol versions
+    don't set it, so they're assumed to speak version 1."""
+    version = int(os.environ.get(protocol.PROTOCOL_VERSION_ENV_VAR, "1"))
+    if version != protocol.PROTOCOL_VERSION:
+        raise ProtocolError(
+            "Wash uses version %d of the external plugin protocol, but this library implements version %d"
+            % (version, protocol.PROTOCOL_VERSION)
+        )
+
+
+def _check_keys(kind, obj, allowed):
+    for key in obj:
+        if key not in allowed:
+            raise ProtocolError("%s is not a valid %s key. Valid keys are %s" % (key, kind, ", ".join(allowed)))
+
+
+def _compact(obj):
+    return dict((key, value) for key, value in obj.items() if value is not None)
+
+
+def attributes(**attrs):
+    """Returns an entry's attributes. Times can be datetimes or Unix
+    seconds."""
+    _check_keys("attribute", attrs, protocol.ATTRIBUTE_KEYS)
+    for key, value in attrs.items():
+        if isinstance(value, datetime):
+            attrs[key] = int((value - datetime(1970, 1, 1, tzinfo=value.tzinfo)).total_seconds())
+    return attrs
+
+
+def cache_ttls(**ttls):
+    """Returns an entry's cache TTLs (in seconds)"""
+    _check_keys("cache_ttls", ttls, protocol.CACHE_TTL_KEYS)
+    return ttls
+
+
+def deprecation(message, since=None, removed_in=None):
+    """Returns a method's deprecation for an entry's deprecated_methods"""
+    return _compact({"message": message, "since": since, "removed_in": removed_in})
+
+
+def entry(name, methods, **keys):
+    """Returns an entry. methods is a list of method names or
+    [<method>, <result>] pairs for prefetched results. keys are the entry's
+    other (optional) keys like state or attributes. state can be a string or
+    any other JSON value, which is passed in as JSON."""
+    keys["name"] = name
+    keys["methods"] = methods
+    _check_keys("entry", keys, protocol.ENTRY_KEYS)
+    for method in methods:
+        method_name = method[0] if isinstance(method, (list, tuple)) else method
+        if method_name not in protocol.METHODS or method_name == "init":
+            raise ProtocolError("%s is not a valid entry method" % method_name)
+    return _compact(keys)
+
+
+def page(entries, next_page=None):
+    """Returns a page of a paged list result. next_page is the token that the
+    next page's list is invoked with (as invocation.args[0]), or None if it's
+    the last page."""
+    return _compact({"entries": list(entries), "next_page": next_page})
+
+
+def print_json(obj, out=None):
+    """Prints obj as JSON, which is how most methods return their result"""
+    out = out or sys.stdout
+    json.dump(obj, out)
+    out.write("\n")
+    out.flush()
+
+
+def print_entries(entries, out=None):
+    """Prints each of the entries on its own line as soon as it's produced,
+    which is how entries that set streaming_list return their children.
+    entries can be a generator. list handlers that call it should return
+    None."""
+    out = out or sys.stdout
+    for e in entries:
+        json.dump(e, out)
+        out.write("\n")
+        out.flush()
+
+
+def print_exec_event(type_, data=None, exit_code=None, error=None, out=None):
+    """Prints an exec event, which is how entries that set exec_events report
+    their command's output and exit code. type_ is one of
+    protocol.EXEC_EVENT_TYPES. stdout and stderr events take the output's
+    data; bytes are base64 encoded. The exit event takes the exit_code, and the
+    error event takes a PluginError. exec handlers that call it should print
+    an exit or error event last, then return None."""
+    if type_ not in protocol.EXEC_EVENT_TYPES:
+        raise ProtocolError("%s is not a valid exec event type. Valid types are %s" % (type_, ", ".join(protocol.EXEC_EVENT_TYPES)))
+    event = {"type": type_, "exit_code": exit_code}
+    if isinstance(data, bytes):
+        event["data"] = base64.b64encode(data).decode("ascii")
+        event["encoding"] = "base64"
+    else:
+        event["data"] = data
+    if error is not None:
+        event["error"] = json.loads(error.to_json())
+    event = _compact(event)
+    _check_keys("exec event", event, protocol.EXEC_EVENT_KEYS)
+    print_json(event, out)
+
+
+def print_invalidation_event(path, action=None, out=None):
+    """Prints an invalidation event, which is how a plugin root's watch
+    handler tells Wash that the entry at path changed in the plugin's
+    backend. action is one of protocol.INVALIDATION_ACTIONS. If it's None,
+    then the cached results of the entry and its descendants are
+    invalidated. watch handlers shouldn't return until they're terminated."""
+    if action is not None and action not in protocol.INVALIDATION_ACTIONS:
+        raise ProtocolError("%s is not a valid invalidation action. Valid actions are %s" % (action, ", ".join(protocol.INVALIDATION_ACTIONS)))
+    event = _compact({"path": path, "action": action})
+    _check_keys("invalidation event", event, protocol.INVALIDATION_EVENT_KEYS)
+    print_json(event, out)
+
+
+def config():
+    """Returns the plugin's config section from wash.yaml, which init
+    handlers are passed instead. It's empty if the plugin doesn't have
+    one."""
+    value = os.environ.get(protocol.CONFIG_ENV_VAR)
+    return json.loads(value) if value else {}
+
+
+def workspace():
+    """Returns the plugin's scratch directory, or None if Wash didn't
+    provide one"""
+    return os.environ.get(protocol.WORKSPACE_ENV_VAR)
+
+
+def previous_validators():
+    """Returns the previous result's validators as a dict with the etag
+    and last_modified keys. It's empty if the result didn't have any."""
+    validators = {}
+    if os.environ.get(protocol.ETAG_ENV_VAR):
+        validators["etag"] = os.environ[protocol.ETAG_ENV_VAR]
+    if os.environ.get(protocol.LAST_MODIFIED_ENV_VAR):
+        validators["last_modified"] = os.environ[protocol.LAST_MODIFIED_ENV_VAR]
+    return validators
+
+
+def write_validators(etag=None, last_modified=None, unchanged=False):
+    """Writes the result's validators. Set unchanged (and skip printing the
+    result) if the previous result's still valid. It's a no-op if the result
+    isn't cached. last_modified should be an RFC3339 timestamp."""
+    path = os.environ.get(protocol.VALIDATORS_FILE_ENV_VAR)
+    if not path:
+        return
+    validators = _compact({"etag": etag, "last_modified": last_modified})
+    if unchanged:
+        validators["unchanged"] = True
+    _check_keys("validators", validators, protocol.VALIDATORS_KEYS)
+    with open(path, "w") as f:
+        json.dump(validators, f)
+
+
+def _init_result(root, transport):
+    """Returns the plugin root with the version of the protocol that this
+    library speaks, which is how the version's negotiated with Wash. It also
+    declares the plugin's transport if it isn't JSON."""
+    if isinstance(root, dict) and "protocol_version" not in root:
+        root = dict(root, protocol_version=protocol.PROTOCOL_VERSION)
+    if isinstance(root, dict) and transport != "json":
+        root = dict(root, transport=transport)
+    return root
+
+
+def print_binary(obj, transport, out=None):
+    """Prints obj in the msgpack or cbor transport, which requires the msgpack
+    or cbor2 package"""
+    if transport == "msgpack":
+        import msgpack  # pylint: disable=import-outside-toplevel
+
+        payload = msgpack.packb(obj, use_bin_type=True)
+    elif transport == "cbor":
+        import cbor2  # pylint: disable=import-outside-toplevel
+
+        payload = cbor2.dumps(obj)
+    else:
+        raise ProtocolError("%s is not a binary transport" % transport)
+    out = out or getattr(sys.stdout, "buffer", sys.stdout)
+    out.write(payload)
+    out.flush()
+
+
+def run(handlers, argv=None, transport="json"):
+    """Invokes the handler for the invoked method, then prints its result as
+    JSON. list, metadata and create results are printed in transport instead, which is
+    declared in the init result (see protocol.TRANSPORTS). init handlers are passed the decoded config. Other handlers are
+    passed the Invocation. read handlers can return the content as a string.
+    Handlers that print their own output (e.g. stream, exec and watch) should
+    return None. write handlers read the new content from stdin, and return None on
+    success or {"error": <reason>} on failure. delete, rename and signal handlers
+    (which are passed the new name or the signal in invocation.args) return the
+    same. health handlers
+    return None if the plugin's healthy, and raise a PluginError if it isn't.
+    create handlers (which are passed the child's name and "dir" or "file" in
+    invocation.args) return the created child.
+    Handlers that aren't keyed by a method implement the custom actions that entries list in
+    their custom_actions. They're passed the Invocation (whose args are the
+    action's args), and can return the action's output as a string.
+    Errors are printed to stderr; PluginErrors are printed as JSON."""
+    try:
+        check_protocol_version()
+        if transport not in protocol.TRANSPORTS:
+            raise ProtocolError("unknown transport %s" % transport)
+        custom_actions = [method for method in handlers if method not in protocol.METHODS]
+        invocation = parse_args(argv, custom_actions)
+        handler = handlers.get(invocation.method)
+        if handler is None:
+            raise ProtocolError("%s is not implemented" % invocation.method)
+        if invocation.method == "init":
+            config = json.loads(invocation.args[0]) if invocation.args else {}
+            result = _init_result(handler(config), transport)
+        else:
+            result = handler(invocation)
+        if result is not None:
+            if invocation.method in ["read"] + custom_actions and isinstance(result, str):
+                sys.stdout.write(result)
+            elif invocation.method in ("list", "metadata", "create") and transport != "json":
+                print_binary(result, transport)
+            else:
+                print_json(result)
+    except PluginError as e:
+        sys.stderr.write("%s\n" % e.to_json())
+        sys.exit(1)
+    except Exception as e:  # pylint: disable=broad-except
+        sys.stderr.write("%s\n" % e)
+        sys.exit(1)
@@ -0,0 +1,306 @@
+# frozen_string_literal: true
+
+# Helpers for writing Wash external plugin scripts in Ruby.
+#
+# Copy this file and wash_protocol.rb next to your plugin script. A minimal
+# plugin looks like
+#
+#   require_relative 'wash_plugin'
+#
+#   WashPlugin.run(
+#     'init' => ->(_config) { WashPlugin.entry('myplugin', ['list']) },
+#     'list' => ->(_invocation) { [WashPlugin.entry('foo', [['read', 'some content']])] }
+#   )
+#
+# See https://puppetlabs.github.io/wash/docs/external-plugins for the protocol
+# that these helpers implement. The wash gem (https://github.com/puppetlabs/wash-ruby)
+# provides a more complete framework.
+
+require 'base64'
+require 'json'
+require 'time'
+require_relative 'wash_protocol'
+
+module WashPlugin
+  # Raised when the plugin script was invoked incorrectly, or when it returned
+  # something that's not part of the protocol.
+  class ProtocolError < StandardError; end
+
+  # Raised by handlers to report a structured error, which lets Wash tell e.g.
+  # missing entries apart from other failures. kind is one of
+  # Protocol::ERROR_KINDS. Set retryable if the invocation's worth retrying,
+  # e.g. because the API was rate-limiting the plugin.
+  class PluginError < StandardError
+    attr_reader :kind, :retryable
+
+    def initialize(kind, message, retryable: false)
+      unless Protocol::ERROR_KINDS.include?(kind.to_s)
+        raise ProtocolError, "#{kind} is not a valid error kind. Valid kinds are #{Protocol::ERROR_KINDS.join(', ')}"
+      end
+
+      super(message)
+      @kind = kind.to_s
+      @retryable = retryable
+    end
+
+    # Returns the error as JSON, which is how it's printed to stderr
+    def to_json(*args)
+      { 'kind' => kind, 'message' => message, 'retryable' => retryable }.to_json(*args)
+    end
+  end
+
+  # A parsed plugin script invocation. init invocations have an empty path and
+  # state; their only argument is the plugin's config.
+  Invocation = Struct.new(:method, :path, :state, :args)
+
+  # Parses the plugin script's arguments, which are
+  # <method> <path> <state> <args...> (or init <config>). method can also be one
+  # of the custom_actions. States that were spilled to disk are read from their
+  # file.
+  def self.parse_args(argv = ARGV, custom_actions = [])
+    raise ProtocolError, 'the method must be provided' if argv.empty?
+
+    method = argv[0]
+    unless Protocol::METHODS.include?(method) || custom_actions.include?(method)
+      raise ProtocolError, "unknown method #{method}"
+    end
+
+    return Invocation.new(method, '', '', argv[1..-1]) if method == 'init'
+    raise ProtocolError, "#{method} expects <path> <state> <args...>" if argv.length < 3
+
+    state_file = ENV[Protocol::STATE_FILE_ENV_VAR]
+    state = state_file.nil? || state_file.empty? ? argv[2] : File.read(state_file)
+    Invocation.new(method, argv[1], state, argv[3..-1])
+  end
+
+  # Raises a ProtocolError if Wash speaks a different version of the protocol
+  # than this library. Wash servers that predate protocol versions don't set
+  # it, so they're assumed to speak version 1.
+  def self.check_protocol_version
+    version = Integer(ENV.fetch(Protocol::PROTOCOL_VERSION_ENV_VAR, '1'))
+    return if version == Protocol::VERSION
+
+    raise ProtocolError, "Wash uses version #{version} of the external plugin protocol, but this library implements version #{Protocol::VERSION}"
+  end
+
+  def self.check_keys(kind, obj, allowed)
+    obj.each_key do |key|
+      next if allowed.include?(key.to_s)
+
+      raise ProtocolError, "#{key} is not a valid #{kind} key. Valid keys are #{allowed.join(', ')}"
+    end
+  end
+  private_class_method :check_keys
+
+  def self.compact(obj)
+    obj.each_with_object({}) do |(key, value), result|
+      result[key.to_s] = value unless value.nil?
+    end
+  end
+  private_class_method :compact
+
+  # Returns an entry's attributes. Times can be Time objects or Unix seconds.
+  def self.attributes(**attrs)
+    check_keys('attribute', attrs, Protocol::ATTRIBUTE_KEYS)
+    compact(attrs.transform_values { |value| value.is_a?(Time) ? value.to_i : value })
+  end
+
+  # Returns an entry's cache TTLs (in seconds)
+  def self.cache_ttls(**ttls)
+    check_keys('cache_ttls', ttls, Protocol::CACHE_TTL_KEYS)
+    compact(ttls)
+  end
+
+  # Returns a method's deprecation for an entry's deprecated_methods
+  def self.deprecation(message, since: nil, removed_in: nil)
+    compact(message: message, since: since, removed_in: removed_in)
+  end
+
+  # Returns an entry. methods is a list of method names or [<method>, <result>]
+  # pairs for prefetched results. keys are the entry's other (optional) keys
+  # like state or attributes. state can be a string or any other JSON value,
+  # which is passed in as JSON.
+  def self.entry(name, methods, **keys)
+    keys = keys.merge(name: name, methods: methods)
+    check_keys('entry', keys, Protocol::ENTRY_KEYS)
+    methods.each do |method|
+      method_name = method.is_a?(Array) ? method[0] : method
+      if !Protocol::METHODS.include?(method_name.to_s) || method_name.to_s == 'init'
+        raise ProtocolError, "#{method_name} is not a valid entry method"
+      end
+    end
+    compact(keys)
+  end
+
+  # Returns a page of a paged list result. next_page is the token that the next
+  # page's list is invoked with (as invocation.args[0]), or nil if it's the last
+  # page.
+  def self.page(entries, next_page: nil)
+    compact(entries: entries.to_a, next_page: next_page)
+  end
+
+  # Prints obj as JSON, which is how most methods return their result
+  def self.print_json(obj, out = $stdout)
+    out.puts(obj.to_json)
+    out.flush
+  end
+
+  # Prints each of the entries on its own line as soon as it's produced, which
+  # is how entries that set streaming_list return their children. entries can
+  # be any Enumerable (e.g. a lazy enumerator). list handlers that call it
+  # should return nil.
+  def self.print_entries(entries, out = $stdout)
+    entries.each do |e|
+      out.puts(e.to_json)
+      out.flush
+    end
+  end
+
+  # Prints an exec event, which is how entries that set exec_events report their
+  # command's output and exit code. type is one of Protocol::EXEC_EVENT_TYPES.
+  # stdout and stderr events take the output's data; binary strings are base64
+  # encoded. The exit event takes the exit_code, and the error event takes a
+  # PluginError. exec handlers that call it should print an exit or error event
+  # last, then return nil.
+  def self.print_exec_event(type, data: nil, exit_code: nil, error: nil, out: $stdout)
+    unless Protocol::EXEC_EVENT_TYPES.include?(type.to_s)
+      raise ProtocolError, "#{type} is not a valid exec event type. Valid types are #{Protocol::EXEC_EVENT_TYPES.join(', ')}"
+    end
+
+    event = { 'type' => type.to_s, 'exit_code' => exit_code }
+    if data&.encoding == Encoding::BINARY
+      event['data'] = Base64.strict_encode64(data)
+      event['encoding'] = 'base64'
+    else
+      event['data'] = data
+    end
+    event['error'] = JSON.parse(error.to_json) unless error.nil?
+    print_json(compact(event), out)
+  end
+
+  # Prints an invalidation event, which is how a plugin root's watch handler
+  # tells Wash that the entry at path changed in the plugin's backend. action is
+  # one of Protocol::INVALIDATION_ACTIONS. If it's nil, then the cached results
+  # of the entry and its descendants are invalidated. watch handlers shouldn't
+  # return until they're terminated.
+  def self.print_invalidation_event(path, action: nil, out: $stdout)
+    unless action.nil? || Protocol::INVALIDATION_ACTIONS.include?(action.to_s)
+      raise ProtocolError, "#{action} is not a valid invalidation action. Valid actions are #{Protocol::INVALIDATION_ACTIONS.join(', ')}"
+    end
+
+    print_json(compact('path' => path, 'action' => action&.to_s), out)
+  end
+
+  # Returns the plugin's config section from wash.yaml, which init handlers are
+  # passed instead. It's empty if the plugin doesn't have one.
+  def self.config
+    value = ENV[Protocol::CONFIG_ENV_VAR]
+    value.nil? || value.empty? ? {} : JSON.parse(value)
+  end
+
+  # Returns the plugin's scratch directory, or nil if Wash didn't provide one
+  def self.workspace
+    ENV[Protocol::WORKSPACE_ENV_VAR]
+  end
+
+  # Returns the previous result's validators as a hash with the etag and
+  # last_modified keys. It's empty if the result didn't have any.
+  def self.previous_validators
+    validators = {}
+    etag = ENV[Protocol::ETAG_ENV_VAR]
+    last_modified = ENV[Protocol::LAST_MODIFIED_ENV_VAR]
+    validators['etag'] = etag unless etag.nil? || etag.empty?
+    validators['last_modified'] = last_modified unless last_modified.nil? || last_modified.empty?
+    validators
+  end
+
+  # Writes the result's validators. Set unchanged (and skip printing the result)
+  # if the previous result's still valid. It's a no-op if the result isn't
+  # cached.
+  def self.write_validators(etag: nil, last_modified: nil, unchanged: false)
+    path = ENV[Protocol::VALIDATORS_FILE_ENV_VAR]
+    return if path.nil? || path.empty?
+
+    last_modified = last_modified.utc.iso8601 if last_modified.is_a?(Time)
+    validators = compact(etag: etag, last_modified: last_modified)
+    validators['unchanged'] = true if unchanged
+    check_keys('validators', validators, Protocol::VALIDATORS_KEYS)
+    File.write(path, validators.to_json)
+  end
+
+  # Returns the plugin root with the version of the protocol that this library
+  # speaks, which is how the version's negotiated with Wash. It also declares
+  # the plugin's transport if it isn't JSON.
+  def self.init_result(root, transport = 'json')
+    return root unless root.is_a?(Hash)
+
+    root = root.merge(protocol_version: Protocol::VERSION) unless root.key?(:protocol_version) || root.key?('protocol_version')
+    root = root.merge(transport: transport) unless transport == 'json'
+    root
+  end
+
+  # Prints obj in the msgpack or cbor transport, which requires the msgpack or
+  # cbor gem
+  def self.print_binary(obj, transport, out = $stdout)
+    payload = case transport
+              when 'msgpack'
+                require 'msgpack'
+                obj.to_msgpack
+              when 'cbor'
+                require 'cbor'
+                obj.to_cbor
+              else
+                raise ProtocolError, "#{transport} is not a binary transport"
+              end
+    out.binmode
+    out.write(payload)
+    out.flush
+  end
+
+  # Invokes the handler for the invoked method, then prints its result as JSON.
+  # list, metadata and create results are printed in transport instead, which is
+  # declared in the init result (see Protocol::TRANSPORTS).
+  # init handlers are passed the decoded config. Other handlers are passed the
+  # Invocation. read handlers can return the content as a string. Handlers that
+  # print their own output (e.g. stream and exec) should return nil. write
+  # handlers read the new content from $stdin, and return nil on success or
+  # { error: <reason> } on failure. delete, rename and signal handlers (which are
+  # passed the new name or the signal in invocation.args) return the same. health handlers return nil
+  # if the plugin's healthy, and raise a PluginError if it isn't. create
+  # handlers (which are passed the child's name and "dir" or "file" in
+  # invocation.args) return the created child. Handlers that
+  # aren't keyed by a method implement the custom actions that entries list in their
+  # custom_actions. They're passed the Invocation (whose args are the action's
+  # args), and can return the action's output as a string. Errors are printed
+  # to stderr; PluginErrors are printed as JSON.
+  def self.run(handlers, argv = ARGV, transport: 'json')
+    check_protocol_version
+    raise ProtocolError, "unknown transport #{transport}" unless Protocol::TRANSPORTS.include?(transport)
+
+    custom_actions = handlers.keys.map(&:to_s) - Protocol::METHODS
+    invocation = parse_args(argv, custom_actions)
+    handler = handlers[invocation.method]
+    raise ProtocolError, "#{invocation.method} is not implemented" if handler.nil?
+
+    result = if invocation.method == 'init'
+               init_result(handler.call(invocation.args.empty? ? {} : JSON.parse(invocation.args[0])), transport)
+             else
+               handler.call(invocation)
+             end
+    return if result.nil?
+
+    if (['read'] + custom_actions).include?(invocation.method) && result.is_a?(String)
+      $stdout.write(result)
+    elsif %w[list metadata create].include?(invocation.method) && transport != 'json'
+      print_binary(result, transport)
+    else
+      print_json(result)
+    end
+  rescue PluginError => e
+    warn e.to_json
+    exit 1
+  rescue StandardError => e
+    warn e.message
+    exit 1
+  end
+end
@@ -0,0 +1,463 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/plugin/internal"
+	log "github.com/sirupsen/logrus"
+)
+
+// daemonFlag is passed to init when the plugin script's started in daemon mode
+const daemonFlag = "--daemon"
+
+// daemonStopTimeout is how long a daemon has to exit after its stdin's closed
+// before it's terminated
+const daemonStopTimeout = 2 * time.Second
+
+// daemonInitReplayTimeout is how long a restarted daemon has to respond to
+// its replayed init request
+const daemonInitReplayTimeout = 30 * time.Second
+
+// externalPluginDaemon is the script of an external plugin that runs in daemon
+// mode. The script's started once as `<plugin_script> init --daemon`, then
+// InvokeAndWait sends each method invocation to it as a JSON-RPC 2.0 request
+// over its stdin and reads the response from its stdout. That way, plugins can
+// keep their sessions (e.g. their SDK's authenticated client) across
+// invocations. stream and exec still start a new process per invocation since
+// their output's streamed.
+//
+// If the daemon exits, then it's restarted on the next invocation and the init
+// request is replayed.
+type externalPluginDaemon struct {
+	externalPluginScriptImpl
+	mux     sync.Mutex
+	process *daemonProcess
+	// initParams are the params of the last successful init request. They're
+	// replayed when the daemon's restarted.
+	initParams *daemonParams
+}
+
+func newExternalPluginDaemon(name string, limitsName string, path string) *externalPluginDaemon {
+	d := &externalPluginDaemon{externalPluginScriptImpl: newNamespacedExternalPluginScript(name, limitsName, path)}
+	daemonsMux.Lock()
+	daemons = append(daemons, d)
+	daemonsMux.Unlock()
+	return d
+}
+
+// isDaemon returns true if script runs in daemon mode
+func isDaemon(script externalPluginScript) bool {
+	if shadowed, ok := script.(*shadowedScript); ok {
+		script = shadowed.externalPluginScript
+	}
+	_, ok := script.(*externalPluginDaemon)
+	return ok
+}
+
+var daemons []*externalPluginDaemon
+var daemonsMux sync.Mutex
+
+// stopDaemonsOf stops the daemons of root's scripts, e.g. because the root was
+// replaced
+func stopDaemonsOf(root Root) {
+	for _, r := range externalRootsOf(root) {
+		script := r.script
+		if shadowed, ok := script.(*shadowedScript); ok {
+			script = shadowed.externalPluginScript
+		}
+		d, ok := script.(*externalPluginDaemon)
+		if !ok {
+			continue
+		}
+		daemonsMux.Lock()
+		for i, daemon := range daemons {
+			if daemon == d {
+				daemons = append(daemons[:i], daemons[i+1:]...)
+				break
+			}
+		}
+		daemonsMux.Unlock()
+		d.stop()
+	}
+}
+
+// StopDaemons stops the external plugin scripts that are running in daemon
+// mode. It should be called when the Wash server shuts down.
+func StopDaemons() {
+	daemonsMux.Lock()
+	defer daemonsMux.Unlock()
+	var wg sync.WaitGroup
+	for _, d := range daemons {
+		wg.Add(1)
+		go func(d *externalPluginDaemon) {
+			defer wg.Done()
+			d.stop()
+		}(d)
+	}
+	wg.Wait()
+}
+
+type daemonRequest struct {
+	JSONRPC string       `json:"jsonrpc"`
+	ID      uint64       `json:"id"`
+	Method  string       `json:"method"`
+	Params  daemonParams `json:"params"`
+}
+
+// daemonParams are the params of a daemon request. They're the arguments that
+// the script would've been invoked with. Env contains the invocation-specific
+// environment variables (e.g. the validators), and Stdin is the (base64
+// encoded) content that would've been passed-in as stdin.
+type daemonParams struct {
+	Path  string            `json:"path,omitempty"`
+	State string            `json:"state,omitempty"`
+	Args  []string          `json:"args"`
+	Env   map[string]string `json:"env,omitempty"`
+	Stdin []byte            `json:"stdin,omitempty"`
+}
+
+// daemonResponse is a daemon's response to a request. Result is what the
+// script would've printed to stdout. It's either a JSON string containing the
+// output, or the output itself for methods that output JSON (e.g. list and
+// metadata).
+type daemonResponse struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *daemonError    `json:"error"`
+}
+
+// daemonError is a JSON-RPC 2.0 error. Its data can be a structured error
+// (like the ones that scripts print to stderr).
+type daemonError struct {
+	Code    int                         `json:"code"`
+	Message string                      `json:"message"`
+	Data    *decodedExternalPluginError `json:"data"`
+}
+
+const daemonResponseFormat = "{\"jsonrpc\":\"2.0\",\"id\":<request id>,\"result\":<output>}"
+
+// InvokeAndWait sends method's invocation on entry to the daemon and waits for
+// its response.
+func (d *externalPluginDaemon) InvokeAndWait(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	args ...string,
+) (invocation, error) {
+	return d.InvokeAndWaitWithStdin(ctx, method, entry, nil, args...)
+}
+
+// InvokeAndDecode is InvokeAndWait, except that the response's output is passed
+// to decode. It's buffered since the daemon responds once the request's
+// complete.
+func (d *externalPluginDaemon) InvokeAndDecode(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	decode func(io.Reader),
+	args ...string,
+) (invocation, error) {
+	inv, err := d.InvokeAndWait(ctx, method, entry, args...)
+	return decodeBuffered(inv, err, decode)
+}
+
+// InvokeAndWaitWithStdin is InvokeAndWait, except that stdin's content is
+// included in the request.
+func (d *externalPluginDaemon) InvokeAndWaitWithStdin(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	stdin io.Reader,
+	args ...string,
+) (invocation, error) {
+	release, err := d.acquire(ctx, method)
+	if err != nil {
+		return invocation{}, err
+	}
+	defer release()
+
+	params := daemonParams{Args: args, Env: make(map[string]string)}
+	if args == nil {
+		params.Args = []string{}
+	}
+	if method != "init" {
+		if entry == nil {
+			panic(fmt.Sprintf("d.InvokeAndWait called with method '%v' and entry == nil", method))
+		}
+		params.Path, params.State = entry.id(), entry.state
+		params.Env[protocolVersionEnvVar] = strconv.Itoa(entry.negotiatedProtocolVersion())
+		if entry.config != "" {
+			params.Env[configEnvVar] = entry.config
+		}
+	}
+	// The secrets are included so that the daemon sees them once they're
+	// rotated
+	setEnv(params.Env, append(validatorsEnv(ctx), d.env.secretsEnv(ctx)...))
+	if stdin != nil {
+		content, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return invocation{}, err
+		}
+		params.Stdin = content
+	}
+
+	process, err := d.running(ctx)
+	if err != nil {
+		return invocation{}, err
+	}
+	inv, err := invokeWithRetries(ctx, method, func() (invocation, error) {
+		return process.call(ctx, method, params)
+	})
+	if err == nil && method == "init" {
+		d.mux.Lock()
+		d.initParams = &params
+		d.mux.Unlock()
+	}
+	return inv, err
+}
+
+// setEnv sets the environment variables in envVars, which are NAME=VALUE
+// pairs
+func setEnv(env map[string]string, envVars []string) {
+	for _, envVar := range envVars {
+		segments := strings.SplitN(envVar, "=", 2)
+		env[segments[0]] = segments[1]
+	}
+}
+
+// running returns the daemon's running process, starting it (and replaying
+// init) if it isn't running
+func (d *externalPluginDaemon) running(ctx context.Context) (*daemonProcess, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.process != nil && !d.process.hasExited() {
+		return d.process, nil
+	}
+	if d.process != nil {
+		activity.Warnf(ctx, "Restarting the %v plugin's daemon: %v", d.name, d.process.exitErr())
+	}
+
+	process, err := startDaemonProcess(d.NewInvocation(context.Background(), "init", nil, daemonFlag).command, d.name)
+	if err != nil {
+		return nil, err
+	}
+	if d.initParams != nil {
+		// init's replayed with its original env and stdin, except for the
+		// secrets, which might've been rotated since
+		params := *d.initParams
+		params.Env = make(map[string]string, len(d.initParams.Env))
+		for name, value := range d.initParams.Env {
+			params.Env[name] = value
+		}
+		setEnv(params.Env, d.env.secretsEnv(ctx))
+		// The replay restarts the daemon for every request, so it isn't
+		// cancelled with the request that happened to trigger it
+		replayCtx, cancel := context.WithTimeout(DetachedContext(ctx), daemonInitReplayTimeout)
+		_, err := process.call(replayCtx, "init", params)
+		cancel()
+		if err != nil {
+			process.stop()
+			return nil, fmt.Errorf("could not re-initialize the %v plugin's daemon: %v", d.name, err)
+		}
+	}
+	d.process = process
+	return process, nil
+}
+
+func (d *externalPluginDaemon) stop() {
+	d.mux.Lock()
+	process := d.process
+	d.process = nil
+	d.mux.Unlock()
+	if process != nil {
+		process.stop()
+	}
+}
+
+// daemonProcess is a running daemon. Requests are matched with their
+// responses by their ID, so more than one request can be in flight.
+type daemonProcess struct {
+	command  *internal.Command
+	stdin    io.WriteCloser
+	writeMux sync.Mutex
+
+	pendingMux sync.Mutex
+	pending    map[uint64]chan daemonResponse
+	nextID     uint64
+	// exitedCh is closed once the daemon's exited (or stopped responding
+	// correctly), in which case err is why.
+	exitedCh chan struct{}
+	err      error
+}
+
+func startDaemonProcess(command *internal.Command, name string) (*daemonProcess, error) {
+	p := &daemonProcess{
+		command:  command,
+		pending:  make(map[uint64]chan daemonResponse),
+		exitedCh: make(chan struct{}),
+	}
+	var err error
+	if p.stdin, err = command.StdinPipe(); err != nil {
+		return nil, err
+	}
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := command.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Starting the %v plugin's daemon: %v", name, command)
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+
+	// The daemon's stderr can't be attributed to a specific invocation, so
+	// it's logged instead
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			log.Debugf("%v daemon: %v", name, scanner.Text())
+		}
+	}()
+	go p.readResponses(stdout)
+	return p, nil
+}
+
+func (p *daemonProcess) readResponses(stdout io.Reader) {
+	decoder := json.NewDecoder(stdout)
+	for {
+		var resp daemonResponse
+		if err := decoder.Decode(&resp); err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("the daemon closed its stdout")
+			} else {
+				err = fmt.Errorf("could not decode the daemon's response from stdout: %v. Responses should look like %v", err, daemonResponseFormat)
+			}
+			p.exit(err)
+			return
+		}
+		p.pendingMux.Lock()
+		respCh, ok := p.pending[resp.ID]
+		delete(p.pending, resp.ID)
+		p.pendingMux.Unlock()
+		if !ok {
+			// The request was cancelled
+			continue
+		}
+		respCh <- resp
+	}
+}
+
+// exit marks the daemon as exited with err, terminating it if it's still
+// running
+func (p *daemonProcess) exit(err error) {
+	p.pendingMux.Lock()
+	defer p.pendingMux.Unlock()
+	if p.hasExited() {
+		return
+	}
+	p.err = err
+	close(p.exitedCh)
+	p.command.Terminate()
+	go func() {
+		_ = p.command.Wait()
+	}()
+}
+
+func (p *daemonProcess) hasExited() bool {
+	select {
+	case <-p.exitedCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *daemonProcess) exitErr() error {
+	p.pendingMux.Lock()
+	defer p.pendingMux.Unlock()
+	return p.err
+}
+
+// call sends method's invocation to the daemon, then waits for its response
+func (p *daemonProcess) call(ctx context.Context, method string, params daemonParams) (invocation, error) {
+	inv := invocation{command: p.command}
+	respCh := make(chan daemonResponse, 1)
+	p.pendingMux.Lock()
+	p.nextID++
+	id := p.nextID
+	p.pending[id] = respCh
+	p.pendingMux.Unlock()
+	defer func() {
+		p.pendingMux.Lock()
+		delete(p.pending, id)
+		p.pendingMux.Unlock()
+	}()
+
+	req, err := json.Marshal(daemonRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return inv, fmt.Errorf("could not marshal the %v request into JSON: %v", method, err)
+	}
+	activity.Record(ctx, "Sending request %v to %v: %v %v %v", id, p.command, method, params.Path, params.Args)
+	p.writeMux.Lock()
+	_, err = p.stdin.Write(append(req, '\n'))
+	p.writeMux.Unlock()
+	if err != nil {
+		p.exit(fmt.Errorf("could not write to the daemon's stdin: %v", err))
+		return inv, newInvokeError(p.exitErr().Error(), inv)
+	}
+
+	select {
+	case resp := <-respCh:
+		if resp.Error != nil {
+			inv.stderr.WriteString(resp.Error.Message)
+			activity.Record(ctx, "Request %v failed: %v", id, resp.Error.Message)
+			err := newInvokeError(fmt.Sprintf("the daemon returned error code %v", resp.Error.Code), inv)
+			if data := resp.Error.Data; data != nil && data.Message != "" {
+				return inv, data.toError(err.Error())
+			}
+			return inv, err
+		}
+		stdout := &cappedWriter{w: &inv.stdout}
+		if method == "list" {
+			stdout.max = maxListOutputBytes()
+		}
+		var output string
+		if err := json.Unmarshal(resp.Result, &output); err == nil {
+			_, _ = io.WriteString(stdout, output)
+		} else if string(resp.Result) != "null" {
+			_, _ = stdout.Write(resp.Result)
+		}
+		inv.stdoutTruncated = stdout.exceeded
+		activity.Record(ctx, "Response %v: %v", id, inv.stdout.String())
+		return inv, nil
+	case <-p.exitedCh:
+		return inv, newInvokeError(p.exitErr().Error(), inv)
+	case <-ctx.Done():
+		return inv, ctx.Err()
+	}
+}
+
+// stop closes the daemon's stdin, which tells it to exit. It's terminated if
+// it doesn't exit within daemonStopTimeout.
+func (p *daemonProcess) stop() {
+	_ = p.stdin.Close()
+	select {
+	case <-p.exitedCh:
+	case <-time.After(daemonStopTimeout):
+		p.exit(fmt.Errorf("the daemon was stopped"))
+	}
+	_ = p.command.Wait()
+}
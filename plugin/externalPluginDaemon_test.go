@@ -0,0 +1,138 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginDaemonTestSuite struct {
+	suite.Suite
+	root *externalPluginRoot
+}
+
+func (suite *ExternalPluginDaemonTestSuite) SetupTest() {
+	spec := ExternalPluginSpec{Script: "testdata/daemon.sh", Daemon: true}
+	root, err := spec.Load()
+	if !suite.NoError(err) {
+		suite.FailNow("could not load the daemon")
+	}
+	suite.root = root.(*externalPluginRoot)
+	suite.root.SetTestID("/daemon")
+	if !suite.NoError(suite.root.Init(nil)) {
+		suite.FailNow("could not initialize the daemon")
+	}
+}
+
+func (suite *ExternalPluginDaemonTestSuite) TearDownTest() {
+	StopDaemons()
+	daemons = nil
+}
+
+func (suite *ExternalPluginDaemonTestSuite) children() map[string]*externalPluginEntry {
+	entries, err := suite.root.List(context.Background())
+	if !suite.NoError(err) {
+		suite.FailNow("could not list the daemon's root")
+	}
+	children := make(map[string]*externalPluginEntry)
+	for _, entry := range entries {
+		child := entry.(*externalPluginEntry)
+		child.SetTestID("/daemon/" + child.name())
+		children[child.name()] = child
+	}
+	return children
+}
+
+func (suite *ExternalPluginDaemonTestSuite) read(e *externalPluginEntry) (string, error) {
+	content, err := e.Open(context.Background())
+	if err != nil {
+		return "", err
+	}
+	bits, err := ioutil.ReadAll(io.NewSectionReader(content, 0, content.Size()))
+	return string(bits), err
+}
+
+func (suite *ExternalPluginDaemonTestSuite) TestInvocationsReuseTheDaemon() {
+	file := suite.children()["file"]
+	// The init and list requests were the first two requests
+	content, err := suite.read(file)
+	if suite.NoError(err) {
+		suite.Equal("request 3", content)
+	}
+	content, err = suite.read(file)
+	if suite.NoError(err) {
+		suite.Equal("request 4", content)
+	}
+}
+
+func (suite *ExternalPluginDaemonTestSuite) TestReturnsErrorResponses() {
+	file := suite.children()["file"]
+	_, err := file.Metadata(context.Background())
+	suite.Regexp("metadata is not implemented on /daemon/file", err)
+}
+
+func (suite *ExternalPluginDaemonTestSuite) TestRestartsTheDaemonIfItExits() {
+	children := suite.children()
+	_, err := suite.read(children["crash"])
+	suite.Regexp("the daemon closed its stdout", err)
+
+	// The restarted daemon's init request is replayed, so reading the file is
+	// its second request
+	content, err := suite.read(children["file"])
+	if suite.NoError(err) {
+		suite.Equal("request 2", content)
+	}
+}
+
+func (suite *ExternalPluginDaemonTestSuite) TestReplayedInitIsntCancelledWithTheRequest() {
+	children := suite.children()
+	_, err := suite.read(children["crash"])
+	suite.Regexp("the daemon closed its stdout", err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	daemon := suite.root.script.(*externalPluginDaemon)
+	_, err = daemon.running(ctx)
+	suite.NoError(err)
+	content, err := suite.read(children["file"])
+	if suite.NoError(err) {
+		suite.Equal("request 2", content)
+	}
+}
+
+func (suite *ExternalPluginDaemonTestSuite) TestReplaysInitWithItsEnv() {
+	StopDaemons()
+	spec := ExternalPluginSpec{
+		Script: "testdata/daemon.sh",
+		Daemon: true,
+		Env:    ExternalPluginEnv{Secrets: []string{"API_TOKEN"}, CredentialsHelper: "testdata/credentialsHelper.sh"},
+	}
+	root, err := spec.Load()
+	if !suite.NoError(err) {
+		return
+	}
+	suite.root = root.(*externalPluginRoot)
+	suite.root.SetTestID("/daemon")
+	if !suite.NoError(suite.root.Init(nil)) {
+		return
+	}
+	children := suite.children()
+	content, err := suite.read(children["token"])
+	if suite.NoError(err) {
+		suite.Equal("hunter2", content)
+	}
+
+	_, err = suite.read(children["crash"])
+	suite.Regexp("the daemon closed its stdout", err)
+	content, err = suite.read(children["token"])
+	if suite.NoError(err) {
+		suite.Equal("hunter2", content)
+	}
+}
+
+func TestExternalPluginDaemon(t *testing.T) {
+	suite.Run(t, new(ExternalPluginDaemonTestSuite))
+}
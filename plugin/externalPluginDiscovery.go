@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultExternalPluginDir is always searched for external plugins (see
+// DiscoverExternalPlugins)
+const DefaultExternalPluginDir = "~/.wash/plugins"
+
+// DiscoverExternalPlugins returns the specs of the plugin scripts in dirs,
+// i.e. their executable files. A leading ~ is expanded to the user's home
+// directory, and environment variables are expanded. Directories that don't
+// exist are skipped. Hidden files are ignored, and so are the scripts whose
+// name isn't a valid plugin name (e.g. my.plugin.sh) and the scripts whose
+// name matches one of the configured plugins (or a script that was discovered
+// first) so that the configured plugins take precedence. The discovered
+// plugins are loaded like any other plugin, so plugins that fail to load
+// (see FailedExternalPlugin) or whose init fails are reported in wash/status
+// instead of keeping the server from starting. The directories are also
+// watched for new scripts (see WatchExternalPlugins).
+func DiscoverExternalPlugins(dirs []string, configured []ExternalPluginSpec) []ExternalPluginSpec {
+	names := make(map[string]string)
+	for _, spec := range configured {
+		names[spec.Name()] = spec.Path()
+	}
+
+	var specs []ExternalPluginSpec
+	for _, dir := range dirs {
+		dir = expandPath(dir)
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Warnf("Could not search %v for external plugins: %v", dir, err)
+			}
+			continue
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+		for _, fi := range files {
+			path := filepath.Join(dir, fi.Name())
+			spec, ok := discoveredExternalPlugin(path)
+			if !ok {
+				continue
+			}
+			if otherPath, ok := names[spec.Name()]; ok {
+				if otherPath != path {
+					log.Warnf("Skipping the discovered plugin %v: %v is already named %v", path, otherPath, spec.Name())
+				}
+				continue
+			}
+			log.Debugf("Discovered the %v plugin at %v", spec.Name(), path)
+			names[spec.Name()] = path
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// discoveredExternalPlugin returns the spec of the plugin script at path, a
+// file in one of the discovery directories. The returned bool is false if the
+// file isn't a plugin script (see DiscoverExternalPlugins).
+func discoveredExternalPlugin(path string) (ExternalPluginSpec, bool) {
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return ExternalPluginSpec{}, false
+	}
+	// Stat follows symlinks, which lets plugins be installed by symlinking
+	// them into the directory
+	if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() || fi.Mode().Perm()&0100 == 0 {
+		return ExternalPluginSpec{}, false
+	}
+	spec := ExternalPluginSpec{Script: path}
+	if !pluginNameRegex.MatchString(spec.Name()) {
+		log.Warnf("Skipping the discovered plugin %v: its name %v must match %v", path, spec.Name(), pluginNameRegex)
+		return ExternalPluginSpec{}, false
+	}
+	return spec, true
+}
+
+// FailedExternalPlugin returns a root that stands in for the named external
+// plugin, which failed to load with err. Its Init returns err, so registering
+// it reports the failure in wash/status (see Registry#RegisterPlugins) without
+// registering the plugin. The plugin's still watched, so it's loaded once
+// it's fixed (see WatchExternalPlugins).
+func FailedExternalPlugin(name string, err error) Root {
+	return &failedExternalPluginRoot{EntryBase: NewEntry(name), err: err}
+}
+
+type failedExternalPluginRoot struct {
+	EntryBase
+	err error
+}
+
+func (r *failedExternalPluginRoot) Init(map[string]interface{}) error {
+	return r.err
+}
+
+func (r *failedExternalPluginRoot) Schema() *EntrySchema {
+	return nil
+}
+
+func (r *failedExternalPluginRoot) ChildSchemas() []*EntrySchema {
+	return nil
+}
+
+func (r *failedExternalPluginRoot) List(context.Context) ([]Entry, error) {
+	return nil, r.err
+}
@@ -0,0 +1,99 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginDiscoveryTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *ExternalPluginDiscoveryTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+}
+
+func (suite *ExternalPluginDiscoveryTestSuite) writeFile(name string, perm os.FileMode) string {
+	path := filepath.Join(suite.dir, name)
+	suite.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+	suite.NoError(ioutil.WriteFile(path, []byte("#!/bin/sh\necho '{}'\n"), perm))
+	return path
+}
+
+func scriptsOf(specs []ExternalPluginSpec) []string {
+	var scripts []string
+	for _, spec := range specs {
+		scripts = append(scripts, spec.Script)
+	}
+	return scripts
+}
+
+func (suite *ExternalPluginDiscoveryTestSuite) TestDiscoversExecutables() {
+	b := suite.writeFile("b.rb", 0755)
+	a := suite.writeFile("a.sh", 0700)
+	suite.writeFile("README.md", 0644)
+	suite.writeFile(".hidden.sh", 0755)
+	suite.writeFile("subdir/c.sh", 0755)
+
+	specs := DiscoverExternalPlugins([]string{suite.dir}, nil)
+	suite.Equal([]string{a, b}, scriptsOf(specs))
+	suite.Equal("a", specs[0].Name())
+}
+
+func (suite *ExternalPluginDiscoveryTestSuite) TestFollowsSymlinks() {
+	script := suite.writeFile("scripts/mycloud.sh", 0755)
+	link := filepath.Join(suite.dir, "plugins", "mycloud.sh")
+	suite.NoError(os.MkdirAll(filepath.Dir(link), 0755))
+	suite.NoError(os.Symlink(script, link))
+
+	specs := DiscoverExternalPlugins([]string{filepath.Join(suite.dir, "plugins")}, nil)
+	suite.Equal([]string{link}, scriptsOf(specs))
+}
+
+func (suite *ExternalPluginDiscoveryTestSuite) TestSkipsDuplicateNames() {
+	configured := suite.writeFile("configured/mycloud.sh", 0755)
+	suite.writeFile("first/mycloud.rb", 0755)
+	first := suite.writeFile("first/other.sh", 0755)
+	suite.writeFile("second/other.py", 0755)
+
+	dirs := []string{
+		filepath.Join(suite.dir, "configured"),
+		filepath.Join(suite.dir, "first"),
+		filepath.Join(suite.dir, "second"),
+	}
+	specs := DiscoverExternalPlugins(dirs, []ExternalPluginSpec{{Script: configured}})
+	// The configured plugin's script is in the first directory, but it's
+	// already configured
+	suite.Equal([]string{first}, scriptsOf(specs))
+}
+
+func (suite *ExternalPluginDiscoveryTestSuite) TestSkipsInvalidNames() {
+	valid := suite.writeFile("my-plugin.sh", 0755)
+	suite.writeFile("my.plugin.sh", 0755)
+	suite.writeFile("my plugin.sh", 0755)
+
+	specs := DiscoverExternalPlugins([]string{suite.dir}, nil)
+	suite.Equal([]string{valid}, scriptsOf(specs))
+}
+
+func (suite *ExternalPluginDiscoveryTestSuite) TestSkipsMissingDirs() {
+	suite.Empty(DiscoverExternalPlugins([]string{filepath.Join(suite.dir, "missing")}, nil))
+}
+
+func (suite *ExternalPluginDiscoveryTestSuite) TestExpandsPaths() {
+	script := suite.writeFile("mycloud.sh", 0755)
+	os.Setenv("WASH_TEST_PLUGIN_DIR", suite.dir)
+	defer os.Unsetenv("WASH_TEST_PLUGIN_DIR")
+
+	specs := DiscoverExternalPlugins([]string{"$WASH_TEST_PLUGIN_DIR"}, nil)
+	suite.Equal([]string{script}, scriptsOf(specs))
+}
+
+func TestExternalPluginDiscovery(t *testing.T) {
+	suite.Run(t, new(ExternalPluginDiscoveryTestSuite))
+}
@@ -4,11 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/getlantern/deepcopy"
@@ -42,13 +47,29 @@ type decodedCacheTTLs struct {
 
 // decodedExternalPluginEntry describes a decoded serialized entry.
 type decodedExternalPluginEntry struct {
-	TypeID        string           `json:"type_id"`
-	Name          string           `json:"name"`
-	Methods       []interface{}    `json:"methods"`
-	SlashReplacer string           `json:"slash_replacer"`
-	CacheTTLs     decodedCacheTTLs `json:"cache_ttls"`
-	Attributes    EntryAttributes  `json:"attributes"`
-	State         string           `json:"state"`
+	TypeID            string                       `json:"type_id"`
+	Name              string                       `json:"name"`
+	Methods           []interface{}                `json:"methods"`
+	DeprecatedMethods map[string]ActionDeprecation `json:"deprecated_methods"`
+	SlashReplacer     string                       `json:"slash_replacer"`
+	CacheTTLs         decodedCacheTTLs             `json:"cache_ttls"`
+	ExecOptions       []string                     `json:"exec_options"`
+	PartialReads      bool                         `json:"partial_reads"`
+	StreamingList     bool                         `json:"streaming_list"`
+	ResumableStream   bool                         `json:"resumable_stream"`
+	ExecEvents        bool                         `json:"exec_events"`
+	CustomActions     []string                     `json:"custom_actions"`
+	SymlinkTarget     string                       `json:"symlink_target"`
+	Timeouts          map[string]time.Duration     `json:"timeouts"`
+	Attributes        EntryAttributes              `json:"attributes"`
+	State             json.RawMessage              `json:"state"`
+	// Help, Version, ProtocolVersion, Transport and StateSpillover are only
+	// used on the plugin root, i.e. in the response to init
+	Help            string `json:"help"`
+	Version         string `json:"version"`
+	ProtocolVersion int    `json:"protocol_version"`
+	Transport       string `json:"transport"`
+	StateSpillover  bool   `json:"state_spillover"`
 }
 
 const entryMethodTypeError = "each method must be a string or tuple [<method>, <result>], not %v"
@@ -75,6 +96,49 @@ func mungeToMethods(input []interface{}) (map[string]interface{}, error) {
 	return methods, nil
 }
 
+// customActionNameRegex matches the valid names of custom actions. They're
+// script methods (and CLI arguments), so they're restricted to simple words.
+var customActionNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+func validateCustomActions(entryName string, actions []string) error {
+	seen := make(map[string]bool, len(actions))
+	for _, action := range actions {
+		if !customActionNameRegex.MatchString(action) {
+			return fmt.Errorf(
+				"entry %v has the invalid custom action %q. Custom actions must match %v",
+				entryName,
+				action,
+				customActionNameRegex,
+			)
+		}
+		if isExternalPluginMethod(action) || action == RunAction().Name {
+			return fmt.Errorf("entry %v's custom action %v has the same name as a Wash method", entryName, action)
+		}
+		if seen[action] {
+			return fmt.Errorf("entry %v has the custom action %v more than once", entryName, action)
+		}
+		seen[action] = true
+	}
+	return nil
+}
+
+// adaptTo adapts the decoded entry to the version of the protocol that its
+// script speaks. Scripts that speak a newer version than Wash can include
+// things that Wash doesn't understand yet, so those are dropped instead of
+// rejected.
+func (e *decodedExternalPluginEntry) adaptTo(version int) {
+	if version <= ExternalPluginProtocolVersion {
+		return
+	}
+	var execOptions []string
+	for _, option := range e.ExecOptions {
+		if isKnownExecOption(option) {
+			execOptions = append(execOptions, option)
+		}
+	}
+	e.ExecOptions = execOptions
+}
+
 func (e decodedExternalPluginEntry) toExternalPluginEntry(schemaKnown bool, isRoot bool) (*externalPluginEntry, error) {
 	if len(e.Name) <= 0 {
 		return nil, fmt.Errorf("the entry name must be provided")
@@ -87,6 +151,115 @@ func (e decodedExternalPluginEntry) toExternalPluginEntry(schemaKnown bool, isRo
 	if err != nil {
 		return nil, err
 	}
+	for method := range e.DeprecatedMethods {
+		if _, ok := methods[method]; !ok {
+			return nil, fmt.Errorf("entry %v deprecates the %v method, but does not implement it", e.Name, method)
+		}
+	}
+
+	for _, option := range e.ExecOptions {
+		if !isKnownExecOption(option) {
+			return nil, fmt.Errorf("entry %v honors the unknown exec option %v", e.Name, option)
+		}
+		if _, ok := methods["exec"]; !ok {
+			return nil, fmt.Errorf("entry %v honors the %v exec option, but does not implement exec", e.Name, option)
+		}
+	}
+
+	if e.PartialReads {
+		result, ok := methods["read"]
+		if !ok {
+			return nil, fmt.Errorf("entry %v supports partial reads, but does not implement read", e.Name)
+		}
+		if result != nil {
+			return nil, fmt.Errorf("entry %v supports partial reads, but it prefetched its read result", e.Name)
+		}
+		if !e.Attributes.HasSize() {
+			return nil, fmt.Errorf("entry %v supports partial reads, so its size attribute must be set", e.Name)
+		}
+	}
+
+	if e.StreamingList {
+		result, ok := methods["list"]
+		if !ok {
+			return nil, fmt.Errorf("entry %v supports streaming lists, but does not implement list", e.Name)
+		}
+		if result != nil {
+			return nil, fmt.Errorf("entry %v supports streaming lists, but it prefetched its list result", e.Name)
+		}
+	}
+
+	if e.ResumableStream {
+		if _, ok := methods["stream"]; !ok {
+			return nil, fmt.Errorf("entry %v supports resumable streams, but does not implement stream", e.Name)
+		}
+	}
+
+	if e.ExecEvents {
+		if _, ok := methods["exec"]; !ok {
+			return nil, fmt.Errorf("entry %v prints exec events, but does not implement exec", e.Name)
+		}
+	}
+
+	if result, ok := methods["attributes"]; ok && result != nil {
+		return nil, fmt.Errorf("entry %v prefetched its attributes. Set the attributes key instead", e.Name)
+	}
+
+	if result, ok := methods["create"]; ok {
+		if result != nil {
+			return nil, fmt.Errorf("entry %v prefetched its create result, but creating children cannot be prefetched", e.Name)
+		}
+		if _, ok := methods["list"]; !ok {
+			return nil, fmt.Errorf("entry %v implements create, but does not implement list", e.Name)
+		}
+	}
+
+	if result, ok := methods["telemetry"]; ok && result != nil {
+		return nil, fmt.Errorf("entry %v prefetched its telemetry, but telemetry is live data so it cannot be prefetched", e.Name)
+	}
+
+	if _, ok := methods["watch"]; ok && !isRoot {
+		return nil, fmt.Errorf("entry %v implements watch, but only plugin roots can watch for changes", e.Name)
+	}
+
+	if result, ok := methods["health"]; ok {
+		if !isRoot {
+			return nil, fmt.Errorf("entry %v implements health, but only plugin roots can check their health", e.Name)
+		}
+		if result != nil {
+			return nil, fmt.Errorf("entry %v prefetched its health, but health checks cannot be prefetched", e.Name)
+		}
+	}
+
+	if err := validateCustomActions(e.Name, e.CustomActions); err != nil {
+		return nil, err
+	}
+
+	if e.SymlinkTarget != "" {
+		// The target's children and content are accessed via the target,
+		// so a symlink can't have its own
+		for _, method := range []string{"list", "read", "write"} {
+			if _, ok := methods[method]; ok {
+				return nil, fmt.Errorf("entry %v is a symlink, so it cannot implement %v", e.Name, method)
+			}
+		}
+	}
+
+	if e.Attributes.HasContentType() {
+		// It's the Content-Type of the entry's read responses
+		if _, _, err := mime.ParseMediaType(e.Attributes.ContentType()); err != nil {
+			return nil, fmt.Errorf("entry %v's content_type attribute %q is not a valid media type: %v", e.Name, e.Attributes.ContentType(), err)
+		}
+	}
+
+	if err := validateTimeouts(e.Name, e.Timeouts); err != nil {
+		return nil, err
+	}
+
+	state, err := decodeState(e.Name, e.State)
+	if err != nil {
+		return nil, err
+	}
 
 	// INVARIANT: If root implements schema, then schemaKnown == true (and vice versa).
 	// Idea here is that entry schemas also include their descendant's schema. So if the
@@ -124,14 +297,30 @@ func (e decodedExternalPluginEntry) toExternalPluginEntry(schemaKnown bool, isRo
 	}
 
 	entry := &externalPluginEntry{
-		EntryBase:   NewEntry(e.Name),
-		methods:     methods,
-		state:       e.State,
-		schemaKnown: schemaKnown,
-		rawTypeID:   e.TypeID,
+		EntryBase:       NewEntry(e.Name),
+		methods:         methods,
+		state:           state,
+		schemaKnown:     schemaKnown,
+		rawTypeID:       e.TypeID,
+		execOptions:     e.ExecOptions,
+		partialReads:    e.PartialReads,
+		streamingList:   e.StreamingList,
+		resumableStream: e.ResumableStream,
+		execEvents:      e.ExecEvents,
+		customActions:   e.CustomActions,
+		symlinkTarget:   e.SymlinkTarget,
 	}
 	entry.SetAttributes(e.Attributes)
 	entry.setCacheTTLs(e.CacheTTLs)
+	for method, timeout := range e.Timeouts {
+		if entry.timeouts == nil {
+			entry.timeouts = make(map[string]time.Duration)
+		}
+		entry.timeouts[method] = timeout * time.Second
+	}
+	if len(e.DeprecatedMethods) > 0 {
+		entry.deprecations = e.DeprecatedMethods
+	}
 	if e.SlashReplacer != "" {
 		if len([]rune(e.SlashReplacer)) > 1 {
 			msg := fmt.Sprintf("e.SlashReplacer: received string %v instead of a character", e.SlashReplacer)
@@ -156,6 +345,28 @@ type externalPluginEntry struct {
 	methods   map[string]interface{}
 	state     string
 	rawTypeID string
+	// execOptions are the exec options that the entry honors
+	execOptions []string
+	// partialReads is true if the entry's read method can read a range of
+	// its content
+	partialReads bool
+	// streamingList is true if the entry's list method prints its children
+	// as newline-delimited JSON, one child per line
+	streamingList bool
+	// resumableStream is true if the entry's stream method can start at a
+	// position in the stream's history
+	resumableStream bool
+	// execEvents is true if the entry's exec method multiplexes the command's
+	// stdout, stderr and exit code as newline-delimited JSON events
+	execEvents bool
+	// customActions are the names of the entry's custom actions. Each action
+	// is a method of the entry's script.
+	customActions []string
+	// symlinkTarget is the entry's target if it's a symlink
+	symlinkTarget string
+	// timeouts are the timeouts of the entry's methods. They're inherited
+	// from its parent unless the entry overrides them.
+	timeouts map[string]time.Duration
 	// schemaKnown is set by the root. We use it to enforce the invariant
 	// "If the root implements schema, all entries must implement schema"
 	// when decoding external plugin entries.
@@ -163,6 +374,45 @@ type externalPluginEntry struct {
 	// schemaGraphs is a map of <type_id> => <schema_graph>. It is created
 	// by the root and passed along to child entries in list.
 	schemaGraphs map[string]*linkedhashmap.Map
+	// protocolVersion is the protocol_version that the plugin script returned
+	// from init. It's also passed along to child entries in list.
+	protocolVersion int
+	// version is the plugin's version that the plugin script returned from
+	// init. It's also passed along to child entries in list.
+	version string
+	// transport is the transport that the plugin script returns its list and
+	// metadata results in. It's set by the root.
+	transport string
+	// config is the plugin's config section from wash.yaml, as JSON. It's set
+	// by the root and passed along to child entries in list.
+	config string
+	// stateSpillover is true if the plugin's script reads the states that
+	// are too large for the <state> argument from WASH_STATE_FILE. It's set
+	// by the root and passed along to child entries in list.
+	stateSpillover bool
+}
+
+// negotiatedProtocolVersion returns the version of the protocol that Wash
+// speaks with the entry's script
+func (e *externalPluginEntry) negotiatedProtocolVersion() int {
+	version, err := negotiateProtocolVersion(e.protocolVersion)
+	if err != nil {
+		// Init rejects versions that Wash doesn't speak
+		return ExternalPluginProtocolVersion
+	}
+	return version
+}
+
+func (e *externalPluginEntry) externalPluginInfo() ExternalPluginInfo {
+	return ExternalPluginInfo{
+		Script:          e.script.Path(),
+		Version:         e.version,
+		ProtocolVersion: e.negotiatedProtocolVersion(),
+	}
+}
+
+func (e *externalPluginEntry) pluginVersion() string {
+	return e.version
 }
 
 func (e *externalPluginEntry) setCacheTTLs(ttls decodedCacheTTLs) {
@@ -264,10 +514,11 @@ func (e *externalPluginEntry) schema() (*EntrySchema, error) {
 		// plugin development because it lets plugin authors see their schema changes live
 		// without having to restart the Wash server.
 		//
-		// Entry schema generation should be fast, so pass-in a context w/ a 3 second timeout.
-		ctx, cancelFunc := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancelFunc()
-		inv, err := e.script.InvokeAndWait(ctx, "schema", e)
+		// Entry schema generation should be fast, so it times out after 3 seconds
+		// unless the plugin sets a different timeout.
+		inv, err := e.invokeWithTimeout(context.Background(), "schema", func(ctx context.Context) (invocation, error) {
+			return e.script.InvokeAndWait(ctx, "schema", e)
+		})
 		if err != nil {
 			err := fmt.Errorf(
 				"%v (%v): failed to retrieve the entry's schema: %v",
@@ -296,41 +547,214 @@ func (e *externalPluginEntry) schema() (*EntrySchema, error) {
 }
 
 const listFormat = "[{\"name\":\"entry1\",\"methods\":[\"list\"]},{\"name\":\"entry2\",\"methods\":[\"list\"]}]"
+const streamingListFormat = "{\"name\":\"entry1\",\"methods\":[\"list\"]}\n{\"name\":\"entry2\",\"methods\":[\"list\"]}"
+const pagedListFormat = "{\"entries\":[{\"name\":\"entry1\",\"methods\":[\"list\"]}],\"next_page\":\"<token>\"}"
 
 func (e *externalPluginEntry) List(ctx context.Context) ([]Entry, error) {
-	var decodedEntries []decodedExternalPluginEntry
-	if impl, ok := e.methods["list"]; ok && impl != nil {
+	var entries []Entry
+	err := e.listEntries(ctx, func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []Entry{}
+	}
+	return entries, nil
+}
+
+// ListStreaming lists the entry's children as its script prints them. It's
+// only called if the entry supports streaming lists.
+func (e *externalPluginEntry) ListStreaming(ctx context.Context, onEntry func(Entry) error) error {
+	return e.listEntries(ctx, onEntry)
+}
+
+// SupportsStreamingList returns true if the entry's list method prints its
+// children as newline-delimited JSON
+func (e *externalPluginEntry) SupportsStreamingList() bool {
+	return e.streamingList
+}
+
+// listEntries calls onChild with each of the entry's children as they're
+// decoded. If the entry's list is paged, then each of its pages is listed in
+// turn.
+func (e *externalPluginEntry) listEntries(ctx context.Context, onChild func(Entry) error) error {
+	max := maxListEntries.Value()
+	count := 0
+	onCountedChild := func(entry Entry) error {
+		count++
+		if max > 0 && count > max {
+			return fmt.Errorf("more than %v entries were returned across the list's pages. Increase the %v limit if that's expected", max, maxListEntries.Name())
+		}
+		return onChild(entry)
+	}
+
+	listed := make(map[string]bool)
+	page := ""
+	for {
+		nextPage, err := e.listPage(ctx, page, onCountedChild)
+		if err != nil || nextPage == "" {
+			return err
+		}
+		if listed[nextPage] {
+			return fmt.Errorf("the list of %v returned the next page %q more than once", e.id(), nextPage)
+		}
+		listed[nextPage] = true
+		page = nextPage
+	}
+}
+
+// newChild converts one of the entry's decoded children. The child inherits
+// the entry's script, protocol and timeouts.
+func (e *externalPluginEntry) newChild(decodedEntry decodedExternalPluginEntry) (*externalPluginEntry, error) {
+	decodedEntry.adaptTo(e.protocolVersion)
+	entry, err := decodedEntry.toExternalPluginEntry(e.schemaKnown, false)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.script = e.script
+	entry.schemaGraphs = e.schemaGraphs
+	entry.protocolVersion = e.protocolVersion
+	entry.version = e.version
+	entry.transport = e.transport
+	entry.config = e.config
+	entry.stateSpillover = e.stateSpillover
+	if err := validateStateSize(entry.Name(), entry.state, entry.stateSpillover); err != nil {
+		return nil, err
+	}
+	entry.inheritTimeouts(e)
+	return entry, nil
+}
+
+// listPage calls onChild with each of the children in the given page of the
+// entry's list as they're decoded, and returns the next page's token. The
+// first page's token is empty, as is the last page's next page. Follow-up pages
+// are listed via `<plugin_script> list <path> <state> <page>`. Only the first
+// page passes along the previous validators since they describe the whole
+// list. Entries whose list is static or streamed aren't paged.
+func (e *externalPluginEntry) listPage(ctx context.Context, page string, onChild func(Entry) error) (string, error) {
+	var conversionErr error
+	onEntry := func(decodedEntry decodedExternalPluginEntry) error {
+		entry, err := e.newChild(decodedEntry)
+		if err != nil {
+			conversionErr = err
+			return err
+		}
+		return onChild(entry)
+	}
+
+	impl := e.methods["list"]
+	static := impl != nil
+	if page != "" && (static || e.streamingList) {
+		return "", fmt.Errorf("the list of %v isn't paged, so it doesn't have the page %q", e.id(), page)
+	}
+
+	if static {
 		// Entry statically implements list. Construct new entries based on that rather than invoking the script.
 		bits, err := json.Marshal(impl)
 		if err != nil {
 			panic(fmt.Sprintf("Error remarshaling previously unmarshaled data: %v", err))
 		}
 
-		if err := json.Unmarshal(bits, &decodedEntries); err != nil {
-			return nil, fmt.Errorf("implementation of list must conform to %v, not %v", listFormat, impl)
+		if err := decodeEntries(bytes.NewReader(bits), onEntry); err != nil {
+			if conversionErr != nil {
+				return "", conversionErr
+			}
+			return "", fmt.Errorf("implementation of list must conform to %v, not %v", listFormat, impl)
 		}
-	} else {
-		inv, err := e.script.InvokeAndWait(ctx, "list", e)
-		if err != nil {
-			return nil, err
+		return "", nil
+	}
+
+	if e.streamingList {
+		if streamer, ok := e.script.(outputStreamer); ok {
+			_, err := e.invokeWithTimeout(ctx, "list", func(ctx context.Context) (invocation, error) {
+				return streamer.InvokeAndStream(ctx, "list", e, newEntryLineDecoder(onEntry))
+			})
+			if conversionErr != nil {
+				return "", conversionErr
+			}
+			return "", err
 		}
-		if err := json.Unmarshal(inv.stdout.Bytes(), &decodedEntries); err != nil {
-			return nil, newStdoutDecodeErr(ctx, "the entries", err, inv, listFormat)
+	}
+
+	decode := func(r io.Reader, onEntry func(decodedExternalPluginEntry) error) (string, error) {
+		return decodeEntriesWith(e.transport, r, onEntry)
+	}
+	example := listFormat + "\nor, if it's paged:\n" + pagedListFormat
+	if e.streamingList {
+		// The script's a daemon (or it's shadowed), so its output's buffered
+		decode = func(r io.Reader, onEntry func(decodedExternalPluginEntry) error) (string, error) {
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return "", err
+			}
+			return "", decodeEntryLines(data, onEntry)
 		}
+		example = streamingListFormat
 	}
 
-	entries := make([]Entry, len(decodedEntries))
-	for i, decodedExternalPluginEntry := range decodedEntries {
-		entry, err := decodedExternalPluginEntry.toExternalPluginEntry(e.schemaKnown, false)
-		if err != nil {
-			return nil, err
+	// The entries are decoded as the script prints them, but they're only
+	// passed along once it succeeds since a retried invocation decodes them
+	// again
+	var decodedEntries []decodedExternalPluginEntry
+	var nextPage string
+	var decodeErr error
+	decodeStdout := func(stdout io.Reader) {
+		decodedEntries = nil
+		nextPage, decodeErr = decode(stdout, func(decodedEntry decodedExternalPluginEntry) error {
+			decodedEntries = append(decodedEntries, decodedEntry)
+			return nil
+		})
+	}
+	var args []string
+	if page != "" {
+		args = []string{page}
+	}
+	invoke := func(ctx context.Context) (invocation, error) {
+		return e.invokeAndDecode(ctx, "list", decodeStdout, args...)
+	}
+	var inv invocation
+	var err error
+	if page == "" {
+		inv, err = e.invokeValidated(ctx, "list", invoke)
+	} else {
+		inv, err = e.invokeWithTimeout(ctx, "list", invoke)
+	}
+	if err != nil {
+		return "", err
+	}
+	if inv.stdoutTruncated {
+		// The truncated output's too large to include in the error
+		inv.stdout.Reset()
+		return "", newInvokeError(fmt.Sprintf(
+			"the entries exceeded %v MB. Increase the %v limit if that's expected",
+			maxListOutputSize.Value(),
+			maxListOutputSize.Name(),
+		), inv)
+	}
+	if decodeErr != nil {
+		return "", newStdoutDecodeErr(ctx, "the entries", decodeErr, inv, example)
+	}
+	for _, decodedEntry := range decodedEntries {
+		if err := onEntry(decodedEntry); err != nil {
+			return "", err
 		}
+	}
+	return nextPage, nil
+}
 
-		entry.script = e.script
-		entry.schemaGraphs = e.schemaGraphs
-		entries[i] = entry
+// invokeAndDecode invokes method, passing its stdout to decode. The stdout's
+// decoded as it's printed if the script supports it. Otherwise, it's buffered
+// and decoded once the script succeeds.
+func (e *externalPluginEntry) invokeAndDecode(ctx context.Context, method string, decode func(io.Reader), args ...string) (invocation, error) {
+	if decoder, ok := e.script.(outputDecoder); ok {
+		return decoder.InvokeAndDecode(ctx, method, e, decode, args...)
 	}
-	return entries, nil
+	inv, err := e.script.InvokeAndWait(ctx, method, e, args...)
+	return decodeBuffered(inv, err, decode)
 }
 
 func (e *externalPluginEntry) Open(ctx context.Context) (SizedReader, error) {
@@ -340,26 +764,82 @@ func (e *externalPluginEntry) Open(ctx context.Context) (SizedReader, error) {
 		}
 		return nil, fmt.Errorf("Read method must provide a string, not %v", impl)
 	}
+	if e.partialReads {
+		return &externalPluginReader{e: e, ctx: DetachedContext(ctx)}, nil
+	}
 
-	inv, err := e.script.InvokeAndWait(ctx, "read", e)
+	inv, err := e.invokeAndWaitValidated(ctx, "read")
 	if err != nil {
 		return nil, err
 	}
 	return bytes.NewReader(inv.stdout.Bytes()), nil
 }
 
+// externalPluginReader reads the content of an entry that supports partial
+// reads. Each ReadAt invokes the script's read method on the requested range.
+// The reads outlive Open's request (e.g. a FUSE open), so they keep its ctx's
+// values but not its cancellation.
+type externalPluginReader struct {
+	e   *externalPluginEntry
+	ctx context.Context
+}
+
+func (r *externalPluginReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("plugin.externalPluginReader.ReadAt: negative offset")
+	}
+	size := r.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+	length := int64(len(p))
+	if off+length > size {
+		length = size - off
+	}
+	inv, err := r.e.invokeWithTimeout(r.ctx, "read", func(ctx context.Context) (invocation, error) {
+		return r.e.script.InvokeAndWait(
+			ctx,
+			"read",
+			r.e,
+			strconv.FormatInt(off, 10),
+			strconv.FormatInt(length, 10),
+		)
+	})
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, inv.stdout.Bytes())
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// SupportsPartialReads returns true because ReadAt only reads the requested
+// range.
+func (r *externalPluginReader) SupportsPartialReads() bool {
+	return true
+}
+
+func (r *externalPluginReader) Size() int64 {
+	attr := Attributes(r.e)
+	return int64(attr.Size())
+}
+
 func (e *externalPluginEntry) Metadata(ctx context.Context) (JSONObject, error) {
 	if !e.implements("metadata") {
 		// The entry does not override the "Metadata" method so invoke
 		// the default
 		return e.EntryBase.Metadata(ctx)
 	}
-	inv, err := e.script.InvokeAndWait(ctx, "metadata", e)
+	inv, err := e.invokeWithTimeout(ctx, "metadata", func(ctx context.Context) (invocation, error) {
+		return e.script.InvokeAndWait(ctx, "metadata", e)
+	})
 	if err != nil {
 		return nil, err
 	}
-	var metadata JSONObject
-	if err := json.Unmarshal(inv.stdout.Bytes(), &metadata); err != nil {
+	metadata, err := decodeMetadata(e.transport, inv.stdout.Bytes())
+	if err != nil {
 		return nil, newStdoutDecodeErr(
 			ctx,
 			"the metadata",
@@ -371,8 +851,81 @@ func (e *externalPluginEntry) Metadata(ctx context.Context) (JSONObject, error)
 	return metadata, nil
 }
 
+// RefreshAttributes invokes the entry's attributes method, which prints the
+// entry's current attributes in the same format as the attributes key
+func (e *externalPluginEntry) RefreshAttributes(ctx context.Context) (EntryAttributes, error) {
+	inv, err := e.invokeWithTimeout(ctx, "attributes", func(ctx context.Context) (invocation, error) {
+		return e.script.InvokeAndWait(ctx, "attributes", e)
+	})
+	if err != nil {
+		return EntryAttributes{}, err
+	}
+	var attr EntryAttributes
+	if err := json.Unmarshal(inv.stdout.Bytes(), &attr); err != nil {
+		return EntryAttributes{}, newStdoutDecodeErr(
+			ctx,
+			"the attributes",
+			err,
+			inv,
+			"{\"size\":1024,\"mtime\":1550611510}",
+		)
+	}
+	return attr, nil
+}
+
+// Telemetry invokes the entry's telemetry method, which prints the entry's
+// current telemetry as a JSON object
+func (e *externalPluginEntry) Telemetry(ctx context.Context) (JSONObject, error) {
+	inv, err := e.invokeWithTimeout(ctx, "telemetry", func(ctx context.Context) (invocation, error) {
+		return e.script.InvokeAndWait(ctx, "telemetry", e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var telemetry JSONObject
+	if err := json.Unmarshal(inv.stdout.Bytes(), &telemetry); err != nil || telemetry == nil {
+		if err == nil {
+			err = fmt.Errorf("the telemetry must be a JSON object")
+		}
+		return nil, newStdoutDecodeErr(
+			ctx,
+			"the telemetry",
+			err,
+			inv,
+			"{\"cpu_percent\":12.5,\"requests_per_second\":40}",
+		)
+	}
+	return telemetry, nil
+}
+
 func (e *externalPluginEntry) Stream(ctx context.Context) (io.ReadCloser, error) {
-	inv := e.script.NewInvocation(ctx, "stream", e)
+	return e.stream(ctx)
+}
+
+// SupportsResumableStream returns true if the entry's stream method can start
+// at a position in the stream's history
+func (e *externalPluginEntry) SupportsResumableStream() bool {
+	return e.resumableStream
+}
+
+// StreamFrom invokes `<plugin_script> stream <path> <state> --offset <offset>`
+// or `<plugin_script> stream <path> <state> --since <timestamp>`, where the
+// timestamp's in RFC3339 format
+func (e *externalPluginEntry) StreamFrom(ctx context.Context, pos StreamPosition) (io.ReadCloser, error) {
+	if !e.resumableStream {
+		return nil, fmt.Errorf("the stream of %v can't be resumed", e.id())
+	}
+	if pos.HasOffset {
+		return e.stream(ctx, "--offset", strconv.FormatInt(pos.Offset, 10))
+	}
+	return e.stream(ctx, "--since", pos.Since.UTC().Format(time.RFC3339))
+}
+
+func (e *externalPluginEntry) stream(ctx context.Context, args ...string) (io.ReadCloser, error) {
+	if err := quarantineErrOf(e.id()); err != nil {
+		return nil, err
+	}
+	inv := e.script.NewInvocation(ctx, "stream", e, args...)
 	cmd := inv.command
 	stdoutR, err := cmd.StdoutPipe()
 	if err != nil {
@@ -399,23 +952,24 @@ func (e *externalPluginEntry) Stream(ctx context.Context) (io.ReadCloser, error)
 	// Wait for the header to appear on stdout. This lets us know that
 	// the plugin's ready for streaming.
 	header := "200"
+	var offset int64
+	var hasOffset bool
 	headerRdrCh := make(chan error, 1)
 	go func() {
-		headerBytes := []byte(header + "\n")
-		buf := make([]byte, len(headerBytes), cap(headerBytes))
-		n, err := stdoutR.Read(buf)
+		line, err := readStreamHeader(stdoutR)
 		if err != nil {
 			headerRdrCh <- err
 			return
 		}
-		if n != len(headerBytes) || string(buf) != string(headerBytes) {
-			headerRdrCh <- fmt.Errorf("read an invalid header: %v", string(buf))
-			return
-		}
-		headerRdrCh <- nil
+		offset, hasOffset, err = parseStreamHeader(line, e.resumableStream)
+		headerRdrCh <- err
 	}()
-	timeout := 5 * time.Second
-	timer := time.After(timeout)
+	// The stream's timeout is how long we wait for the header
+	var timer <-chan time.Time
+	timeout, ok := e.timeoutOf("stream")
+	if ok {
+		timer = time.After(timeout)
+	}
 	select {
 	case err := <-headerRdrCh:
 		if err != nil {
@@ -424,7 +978,7 @@ func (e *externalPluginEntry) Stream(ctx context.Context) (io.ReadCloser, error)
 			// Try to get more context from stderr
 			n, readErr := inv.stderr.ReadFrom(stderrR)
 			if readErr == nil && n > 0 {
-				err = fmt.Errorf(inv.stderr.String())
+				err = errors.New(inv.stderr.String())
 			}
 			return nil, newInvokeError(fmt.Sprintf("failed to read the header: %v", err), inv)
 		}
@@ -433,14 +987,14 @@ func (e *externalPluginEntry) Stream(ctx context.Context) (io.ReadCloser, error)
 		go func() {
 			_, _ = io.Copy(ioutil.Discard, stderrR)
 		}()
-		return &stdoutStreamer{cmd, stdoutR}, nil
+		return &stdoutStreamer{cmd: cmd, stdout: stdoutR, offset: offset, hasOffset: hasOffset}, nil
 	case <-timer:
 		cmd.Terminate()
 		defer wait()
 		// We timed out while waiting for the streaming header to appear.
 		// Return an appropriate error message using whatever was printed
 		// on stderr.
-		errMsgFmt := fmt.Sprintf("did not see the %v header after %v seconds:", header, timeout)
+		errMsgFmt := fmt.Sprintf("did not see the %v header after %v:", header, timeout)
 		n, err := inv.stderr.ReadFrom(stderrR)
 		if err != nil {
 			return nil, newInvokeError(fmt.Sprintf(
@@ -458,13 +1012,26 @@ func (e *externalPluginEntry) Stream(ctx context.Context) (io.ReadCloser, error)
 	}
 }
 
+// serializedExecOptions are the exec options that are passed to the plugin
+// script. Stdin is true if the command's stdin will be written to the script's
+// stdin.
+type serializedExecOptions struct {
+	ExecOptions
+	Stdin bool `json:"stdin"`
+}
+
+// HonoredExecOptions implements ExecOptionHonorer
+func (e *externalPluginEntry) HonoredExecOptions() []string {
+	return e.execOptions
+}
+
 func (e *externalPluginEntry) Exec(ctx context.Context, cmd string, args []string, opts ExecOptions) (ExecCommand, error) {
-	// Serialize opts to JSON
-	type serializedOptions struct {
-		ExecOptions
-		Stdin bool `json:"stdin"`
+	if err := quarantineErrOf(e.id()); err != nil {
+		return nil, err
 	}
-	serializedOpts := serializedOptions{
+
+	// Serialize opts to JSON
+	serializedOpts := serializedExecOptions{
 		ExecOptions: opts,
 		Stdin:       opts.Stdin != nil,
 	}
@@ -473,12 +1040,25 @@ func (e *externalPluginEntry) Exec(ctx context.Context, cmd string, args []strin
 		return nil, fmt.Errorf("could not marshal opts %v into JSON: %v", opts, err)
 	}
 
-	// Start the command.
+	// Start the command. It's killed once the exec timeout (if any) expires.
+	cancel := func() {}
+	if timeout, ok := e.timeoutOf("exec"); ok {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
 	inv := e.script.NewInvocation(ctx, "exec", e, append([]string{string(optsJSON), cmd}, args...)...)
 	cmdObj := inv.command
 	execCmd := NewExecCommand(ctx)
-	cmdObj.SetStdout(execCmd.Stdout())
-	cmdObj.SetStderr(execCmd.Stderr())
+	var events io.Reader
+	if e.execEvents {
+		if events, err = cmdObj.StdoutPipe(); err != nil {
+			cancel()
+			return nil, err
+		}
+		cmdObj.SetStderr(&cappedWriter{w: &inv.stderr, max: maxExecEventsStderr})
+	} else {
+		cmdObj.SetStdout(execCmd.Stdout())
+		cmdObj.SetStderr(execCmd.Stderr())
+	}
 	if opts.Stdin != nil {
 		cmdObj.SetStdin(opts.Stdin)
 	} else {
@@ -488,13 +1068,20 @@ func (e *externalPluginEntry) Exec(ctx context.Context, cmd string, args []strin
 	}
 	activity.Record(ctx, "Starting %v", cmdObj)
 	if err := cmdObj.Start(); err != nil {
+		cancel()
 		return nil, err
 	}
 	// internal.Command handles context-cancellation cleanup
 	// for us, so we don't have to use execCmd.SetStopFunc.
 
+	if e.execEvents {
+		go e.waitForExecEvents(ctx, cancel, &inv, events, execCmd)
+		return execCmd, nil
+	}
+
 	// Asynchronously wait for the command to finish
 	go func() {
+		defer cancel()
 		err := cmdObj.Wait()
 		execCmd.CloseStreamsWithError(nil)
 		exitCode := cmdObj.ProcessState().ExitCode()
@@ -507,13 +1094,260 @@ func (e *externalPluginEntry) Exec(ctx context.Context, cmd string, args []strin
 	return execCmd, nil
 }
 
+// waitForExecEvents decodes the exec's events until the command exits. The
+// exit code comes from the exit event instead of the script, whose stderr is
+// only reported if the exec fails.
+func (e *externalPluginEntry) waitForExecEvents(ctx context.Context, cancel func(), inv *invocation, events io.Reader, execCmd *ExecCommandImpl) {
+	defer cancel()
+	result := decodeExecEvents(events, execCmd)
+	terminated := false
+	if result.decodeErr != nil && result.decodeErr != errExecEventsEnded {
+		// The rest of the events are meaningless, so don't wait for them
+		inv.command.Terminate()
+		terminated = true
+	}
+	// Drain the events so that Wait doesn't block on a full pipe
+	_, _ = io.Copy(ioutil.Discard, events)
+	waitErr := inv.command.Wait()
+	execCmd.CloseStreamsWithError(nil)
+
+	switch {
+	case result.exitCode != nil:
+		execCmd.SetExitCode(*result.exitCode)
+	case result.execErr != nil:
+		activity.Record(ctx, "%v failed to exec: %v", ID(e), result.execErr.Message)
+		execCmd.SetExitCodeErr(result.execErr.toError(fmt.Sprintf("COMMAND: %s", inv.command)))
+	default:
+		msg := fmt.Sprintf("could not decode the exec's events: %v", result.decodeErr)
+		if waitErr != nil && !terminated && ctx.Err() == nil {
+			msg += fmt.Sprintf(" (the script failed with %v)", waitErr)
+		}
+		execCmd.SetExitCodeErr(newInvokeError(msg, *inv))
+	}
+}
+
+// decodedActionResult is what a write, delete, rename or signal invocation
+// prints to stdout. Scripts that don't print anything have succeeded.
+type decodedActionResult struct {
+	Error string `json:"error"`
+}
+
+// decodeActionResult decodes the result of an invocation of the given
+// action's method. The result's error is prefixed with failure (e.g. "could
+// not delete /foo"). example is an example of an error, which is included in
+// the error that's returned if the result can't be decoded.
+func decodeActionResult(ctx context.Context, action string, inv invocation, example string, failure string) error {
+	stdout := bytes.TrimSpace(inv.stdout.Bytes())
+	if len(stdout) == 0 {
+		return nil
+	}
+	var result decodedActionResult
+	if err := json.Unmarshal(stdout, &result); err != nil {
+		return newStdoutDecodeErr(
+			ctx,
+			"the "+action+" result",
+			err,
+			inv,
+			"{\"error\":\""+example+"\"}",
+		)
+	}
+	if result.Error != "" {
+		return fmt.Errorf("%v: %v", failure, result.Error)
+	}
+	return nil
+}
+
+// Write replaces the entry's content with data. It invokes the script's write
+// method with data as its stdin.
+func (e *externalPluginEntry) Write(ctx context.Context, data []byte) error {
+	inv, err := e.invokeWithTimeout(ctx, "write", func(ctx context.Context) (invocation, error) {
+		return e.script.InvokeAndWaitWithStdin(ctx, "write", e, bytes.NewReader(data))
+	})
+	if err != nil {
+		return err
+	}
+	return decodeActionResult(ctx, "write", inv, "the file is read-only", fmt.Sprintf("could not write to %v", ID(e)))
+}
+
+// Delete deletes the entry. It invokes the script's delete method.
+func (e *externalPluginEntry) Delete(ctx context.Context) error {
+	inv, err := e.invokeWithTimeout(ctx, "delete", func(ctx context.Context) (invocation, error) {
+		return e.script.InvokeAndWait(ctx, "delete", e)
+	})
+	if err != nil {
+		return err
+	}
+	return decodeActionResult(ctx, "delete", inv, "the instance is protected from termination", fmt.Sprintf("could not delete %v", ID(e)))
+}
+
+// createFormat is an example of a create result, which is the created child
+const createFormat = "{\"name\":\"entry1\",\"methods\":[\"list\"]}"
+
+// CreateChild creates one of the entry's children. It invokes the script's
+// create method as `<plugin_script> create <path> <state> <name> <dir|file>`,
+// which prints the created child in the same format as one of list's entries.
+func (e *externalPluginEntry) CreateChild(ctx context.Context, name string, isParent bool) (Entry, error) {
+	kind := "file"
+	if isParent {
+		kind = "dir"
+	}
+	inv, err := e.invokeWithTimeout(ctx, "create", func(ctx context.Context) (invocation, error) {
+		return e.script.InvokeAndWait(ctx, "create", e, name, kind)
+	})
+	if err != nil {
+		return nil, err
+	}
+	decodedChild, err := decodeEntryWith(e.transport, inv.stdout.Bytes())
+	if err != nil {
+		return nil, newStdoutDecodeErr(ctx, "the created child", err, inv, createFormat)
+	}
+	return e.newChild(decodedChild)
+}
+
+// Rename renames the entry. It invokes the script's rename method as
+// `<plugin_script> rename <path> <state> <new_name>`.
+func (e *externalPluginEntry) Rename(ctx context.Context, newName string) error {
+	inv, err := e.invokeWithTimeout(ctx, "rename", func(ctx context.Context) (invocation, error) {
+		return e.script.InvokeAndWait(ctx, "rename", e, newName)
+	})
+	if err != nil {
+		return err
+	}
+	return decodeActionResult(ctx, "rename", inv, "the name is already taken", fmt.Sprintf("could not rename %v to %v", ID(e), newName))
+}
+
+// Signal sends the signal to the entry. It invokes the script's signal method
+// with the signal as its argument.
+func (e *externalPluginEntry) Signal(ctx context.Context, signal string) error {
+	inv, err := e.invokeWithTimeout(ctx, "signal", func(ctx context.Context) (invocation, error) {
+		return e.script.InvokeAndWait(ctx, "signal", e, signal)
+	})
+	if err != nil {
+		return err
+	}
+	return decodeActionResult(ctx, "signal", inv, "the container is already stopped", fmt.Sprintf("could not send %v to %v", signal, ID(e)))
+}
+
+// CustomActions returns the names of the entry's custom actions
+func (e *externalPluginEntry) CustomActions() []string {
+	return e.customActions
+}
+
+// Run performs the entry's custom action. It invokes the script's <action>
+// method with args, and returns what the script printed to stdout.
+func (e *externalPluginEntry) Run(ctx context.Context, action string, args []string) ([]byte, error) {
+	if !e.hasCustomAction(action) {
+		return nil, fmt.Errorf("%v does not have the %v action", ID(e), action)
+	}
+	inv, err := e.invokeWithTimeout(ctx, RunAction().Name, func(ctx context.Context) (invocation, error) {
+		return e.script.InvokeAndWait(ctx, action, e, args...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inv.stdout.Bytes(), nil
+}
+
+func (e *externalPluginEntry) hasCustomAction(action string) bool {
+	for _, a := range e.customActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// SymlinkTarget returns the entry's symlink target. It's empty if the entry
+// isn't a symlink.
+func (e *externalPluginEntry) SymlinkTarget() string {
+	return e.symlinkTarget
+}
+
+// maxStreamHeaderSize is the maximum size of a stream's header line, so that a
+// script that doesn't print one isn't read forever
+const maxStreamHeaderSize = 32
+
+// readStreamHeader reads a stream's header line. It reads a byte at a time so
+// that none of the stream's output is consumed with the header.
+func readStreamHeader(r io.Reader) (string, error) {
+	var header []byte
+	b := make([]byte, 1)
+	for len(header) < maxStreamHeaderSize {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", err
+		}
+		if b[0] == '\n' {
+			return string(header), nil
+		}
+		header = append(header, b[0])
+	}
+	return "", fmt.Errorf("read an invalid header: %v", string(header))
+}
+
+// parseStreamHeader parses a stream's header line, which is "200". Resumable
+// streams can also include the offset of their output in the stream's history
+// as "200 <offset>". The returned bool is false if the offset's unknown.
+func parseStreamHeader(header string, resumable bool) (int64, bool, error) {
+	if header == "200" {
+		return 0, false, nil
+	}
+	offsetStr := strings.TrimPrefix(header, "200 ")
+	if !resumable || offsetStr == header {
+		return 0, false, fmt.Errorf("read an invalid header: %v", header)
+	}
+	offset, err := strconv.ParseInt(offsetStr, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, false, fmt.Errorf("read an invalid header: %v. The offset must be a non-negative integer", header)
+	}
+	return offset, true, nil
+}
+
+// stdoutStreamer reads a stream's output from its script's stdout. If the
+// output's offset in the stream's history is known, then it's tracked as the
+// output's read so that a client can resume the stream where it left off.
 type stdoutStreamer struct {
-	cmd    *internal.Command
-	stdout io.ReadCloser
+	cmd       *internal.Command
+	stdout    io.ReadCloser
+	offset    int64
+	hasOffset bool
+	// end is why the stream ended. It's set once stdout returns io.EOF.
+	end *StreamEnd
 }
 
+func (s *stdoutStreamer) streamOffset() (int64, bool) {
+	return atomic.LoadInt64(&s.offset), s.hasOffset
+}
+
+// streamExitTimeout is how long a stream's script has to exit after it closes
+// its stdout for its exit code to be reported
+const streamExitTimeout = 5 * time.Second
+
+// Read reads the script's stdout. Once the script's closed its stdout, Read
+// returns io.EOF, and the stream's StreamEnd includes the script's exit code.
 func (s *stdoutStreamer) Read(p []byte) (int, error) {
-	return s.stdout.Read(p)
+	n, err := s.stdout.Read(p)
+	atomic.AddInt64(&s.offset, int64(n))
+	if err != io.EOF || s.end != nil {
+		return n, err
+	}
+	waitCh := make(chan struct{})
+	go func() {
+		_ = s.cmd.Wait()
+		close(waitCh)
+	}()
+	s.end = NewStreamEnd("the plugin closed its stream")
+	select {
+	case <-waitCh:
+		if state := s.cmd.ProcessState(); state != nil {
+			s.end = NewStreamExit("the plugin's stream exited", state.ExitCode())
+		}
+	case <-time.After(streamExitTimeout):
+	}
+	return n, err
+}
+
+func (s *stdoutStreamer) streamEnd() *StreamEnd {
+	return s.end
 }
 
 func (s *stdoutStreamer) Close() error {
@@ -564,7 +1398,8 @@ func unmarshalSchemaGraph(e externalPlugin, stdout []byte) (*linkedhashmap.Map,
 	}
 	type decodedEntrySchema struct {
 		entrySchema
-		Methods []string `json:"methods"`
+		Methods           []string                     `json:"methods"`
+		DeprecatedMethods map[string]ActionDeprecation `json:"deprecated_methods"`
 	}
 	graph := linkedhashmap.New()
 	putNode := func(rawTypeID string, rawSchema interface{}) error {
@@ -595,6 +1430,18 @@ func unmarshalSchemaGraph(e externalPlugin, stdout []byte) (*linkedhashmap.Map,
 				break
 			}
 		}
+		for method := range node.DeprecatedMethods {
+			found := false
+			for _, m := range node.Methods {
+				if m == method {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("the schema deprecates the %v method, but does not include it in the entry's methods", method)
+			}
+		}
 		if !isParent && len(node.Children) > 0 {
 			return fmt.Errorf("entry has children even though it is not a parent. Parent entries must implement list")
 		}
@@ -620,6 +1467,7 @@ func unmarshalSchemaGraph(e externalPlugin, stdout []byte) (*linkedhashmap.Map,
 		// We don't put node itself in because doing so would marshal its "Methods"
 		// field.
 		node.Actions = node.Methods
+		node.DeprecatedActions = node.DeprecatedMethods
 		graph.Put(typeID, node.entrySchema)
 		return nil
 	}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"path"
 	"regexp"
@@ -37,6 +38,17 @@ func (m *mockExternalPluginScript) InvokeAndWait(
 	return retValues.Get(0).(invocation), retValues.Error(1)
 }
 
+func (m *mockExternalPluginScript) InvokeAndWaitWithStdin(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	stdin io.Reader,
+	args ...string,
+) (invocation, error) {
+	retValues := m.Called(ctx, method, entry, stdin, args)
+	return retValues.Get(0).(invocation), retValues.Error(1)
+}
+
 func (m *mockExternalPluginScript) NewInvocation(
 	ctx context.Context,
 	method string,
@@ -60,6 +72,16 @@ func (m *mockExternalPluginScript) OnInvokeAndWait(
 	return m.On("InvokeAndWait", ctx, method, entry, args)
 }
 
+func (m *mockExternalPluginScript) OnInvokeAndWaitWithStdin(
+	ctx interface{},
+	method string,
+	entry *externalPluginEntry,
+	stdin interface{},
+	args ...string,
+) *mock.Call {
+	return m.On("InvokeAndWaitWithStdin", ctx, method, entry, stdin, args)
+}
+
 type ExternalPluginEntryTestSuite struct {
 	suite.Suite
 }
@@ -136,6 +158,267 @@ func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithMeth
 	}
 }
 
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithExecOptions() {
+	decodedEntry := decodedExternalPluginEntry{
+		Name:        "decodedEntry",
+		Methods:     []interface{}{"exec"},
+		ExecOptions: []string{"env", "cwd"},
+	}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.Equal([]string{EnvExecOption, CwdExecOption}, HonoredExecOptions(entry))
+	}
+
+	decodedEntry.ExecOptions = []string{"user"}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry honors the unknown exec option user")
+
+	decodedEntry.Methods = []interface{}{"list"}
+	decodedEntry.ExecOptions = []string{"env"}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry honors the env exec option, but does not implement exec")
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithTimeouts() {
+	decodedEntry := decodedExternalPluginEntry{
+		Name:     "decodedEntry",
+		Methods:  []interface{}{"list", "exec"},
+		Timeouts: map[string]time.Duration{"list": 30, "exec": 0},
+	}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		timeout, ok := entry.timeoutOf("list")
+		suite.True(ok)
+		suite.Equal(30*time.Second, timeout)
+		_, ok = entry.timeoutOf("exec")
+		suite.False(ok)
+		// Defaults apply to the methods without a timeout
+		timeout, ok = entry.timeoutOf("schema")
+		suite.True(ok)
+		suite.Equal(3*time.Second, timeout)
+		_, ok = entry.timeoutOf("metadata")
+		suite.False(ok)
+	}
+
+	decodedEntry.Timeouts = map[string]time.Duration{"init": 10}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry sets a timeout for the unknown method init")
+
+	// The run timeout applies to the entry's custom actions
+	decodedEntry.Timeouts = map[string]time.Duration{"run": 60}
+	entry, err = decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		timeout, ok := entry.timeoutOf("run")
+		suite.True(ok)
+		suite.Equal(60*time.Second, timeout)
+	}
+
+	decodedEntry.Timeouts = map[string]time.Duration{"list": -1}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry sets a negative timeout for list")
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestListInheritsTimeouts() {
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		methods: map[string]interface{}{
+			"list": []interface{}{
+				map[string]interface{}{"name": "inherits", "methods": []interface{}{"read"}},
+				map[string]interface{}{"name": "overrides", "methods": []interface{}{"read"}, "timeouts": map[string]interface{}{"read": 0}},
+			},
+		},
+		timeouts: map[string]time.Duration{"read": 10 * time.Second},
+	}
+	entry.SetTestID("/foo")
+
+	entries, err := entry.List(context.Background())
+	if suite.NoError(err) && suite.Len(entries, 2) {
+		timeout, ok := entries[0].(*externalPluginEntry).timeoutOf("read")
+		suite.True(ok)
+		suite.Equal(10*time.Second, timeout)
+		_, ok = entries[1].(*externalPluginEntry).timeoutOf("read")
+		suite.False(ok)
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestMethodsTimeOut() {
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		script:    newExternalPluginScript("", "testdata/slow.sh"),
+		methods:   map[string]interface{}{"metadata": nil},
+		timeouts:  map[string]time.Duration{"metadata": 1 * time.Second},
+	}
+	entry.SetTestID("/foo")
+
+	start := time.Now()
+	_, err := entry.Metadata(context.Background())
+	suite.Regexp("^metadata timed out after 1s", err)
+	suite.True(time.Since(start) < 4*time.Second)
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithPartialReads() {
+	decodedEntry := decodedExternalPluginEntry{
+		Name:         "decodedEntry",
+		Methods:      []interface{}{"read"},
+		PartialReads: true,
+	}
+	_, err := decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry supports partial reads, so its size attribute must be set")
+
+	decodedEntry.Attributes.SetSize(10)
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.True(entry.partialReads)
+	}
+
+	decodedEntry.Methods = []interface{}{[]interface{}{"read", "content"}}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry supports partial reads, but it prefetched its read result")
+
+	decodedEntry.Methods = []interface{}{"list"}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry supports partial reads, but does not implement read")
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithStreamingList() {
+	decodedEntry := decodedExternalPluginEntry{
+		Name:          "decodedEntry",
+		Methods:       []interface{}{"list"},
+		StreamingList: true,
+	}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.True(entry.SupportsStreamingList())
+	}
+
+	decodedEntry.Methods = []interface{}{[]interface{}{"list", []interface{}{}}}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry supports streaming lists, but it prefetched its list result")
+
+	decodedEntry.Methods = []interface{}{"read"}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry supports streaming lists, but does not implement list")
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithResumableStream() {
+	decodedEntry := decodedExternalPluginEntry{
+		Name:            "decodedEntry",
+		Methods:         []interface{}{"stream"},
+		ResumableStream: true,
+	}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.True(entry.SupportsResumableStream())
+	}
+
+	decodedEntry.Methods = []interface{}{"read"}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry supports resumable streams, but does not implement stream")
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithExecEvents() {
+	decodedEntry := decodedExternalPluginEntry{
+		Name:       "decodedEntry",
+		Methods:    []interface{}{"exec"},
+		ExecEvents: true,
+	}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.True(entry.execEvents)
+	}
+
+	decodedEntry.Methods = []interface{}{"read"}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry prints exec events, but does not implement exec")
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithContentType() {
+	decodedEntry := decodedExternalPluginEntry{
+		Name:    "decodedEntry",
+		Methods: []interface{}{"read"},
+	}
+	decodedEntry.Attributes.SetContentType("application/json; charset=utf-8")
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		contentType, ok := ContentTypeOf(entry)
+		suite.True(ok)
+		suite.Equal("application/json; charset=utf-8", contentType)
+	}
+
+	decodedEntry.Attributes = EntryAttributes{}
+	entry, err = decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		_, ok := ContentTypeOf(entry)
+		suite.False(ok)
+	}
+
+	decodedEntry.Attributes.SetContentType("not a media type")
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.Regexp("entry decodedEntry's content_type attribute \"not a media type\" is not a valid media type", err)
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithSymlinkTarget() {
+	decodedEntry := decodedExternalPluginEntry{
+		Name:          "latest",
+		Methods:       []interface{}{"metadata"},
+		SymlinkTarget: "v1.2.3",
+	}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		target, ok := SymlinkTargetOf(entry)
+		suite.True(ok)
+		suite.Equal("v1.2.3", target)
+	}
+
+	decodedEntry.Methods = []interface{}{"list"}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry latest is a symlink, so it cannot implement list")
+
+	decodedEntry.Methods = []interface{}{[]interface{}{"read", "content"}}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry latest is a symlink, so it cannot implement read")
+
+	decodedEntry.Methods = []interface{}{"read"}
+	decodedEntry.SymlinkTarget = ""
+	entry, err = decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		_, ok := SymlinkTargetOf(entry)
+		suite.False(ok)
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithCustomActions() {
+	decodedEntry := decodedExternalPluginEntry{
+		Name:          "decodedEntry",
+		Methods:       []interface{}{"read"},
+		CustomActions: []string{"snapshot", "hard-reboot"},
+	}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.Equal([]string{"snapshot", "hard-reboot"}, CustomActionsOf(entry))
+		suite.Equal([]string{"read", "run"}, SupportedActionsOf(entry))
+	}
+
+	decodedEntry.CustomActions = []string{"Snapshot now"}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.Regexp("invalid custom action \"Snapshot now\"", err)
+
+	decodedEntry.CustomActions = []string{"signal"}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry's custom action signal has the same name as a Wash method")
+
+	decodedEntry.CustomActions = []string{"snapshot", "snapshot"}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.EqualError(err, "entry decodedEntry has the custom action snapshot more than once")
+
+	decodedEntry.CustomActions = nil
+	entry, err = decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.Empty(CustomActionsOf(entry))
+		suite.Equal([]string{"read"}, SupportedActionsOf(entry))
+	}
+}
+
 func newMockDecodedEntry(name string) decodedExternalPluginEntry {
 	return decodedExternalPluginEntry{
 		Name:    name,
@@ -145,11 +428,21 @@ func newMockDecodedEntry(name string) decodedExternalPluginEntry {
 
 func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithState() {
 	decodedEntry := newMockDecodedEntry("name")
-	decodedEntry.State = "some state"
+	decodedEntry.State = json.RawMessage(`"some state"`)
 	entry, err := decodedEntry.toExternalPluginEntry(false, false)
 	if suite.NoError(err) {
-		suite.Equal(decodedEntry.State, entry.state)
+		suite.Equal("some state", entry.state)
+	}
+
+	decodedEntry.State = json.RawMessage(`{"klass": "SSHFS::VM", "page": 2}`)
+	entry, err = decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.Equal(`{"klass":"SSHFS::VM","page":2}`, entry.state)
 	}
+
+	decodedEntry.State = json.RawMessage(`{"klass":`)
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.Regexp("name has an invalid state", err)
 }
 
 func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithCacheTTLs() {
@@ -189,6 +482,85 @@ func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithAttr
 	}
 }
 
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithDeprecatedMethods() {
+	decodedEntry := newMockDecodedEntry("name")
+	decodedEntry.DeprecatedMethods = map[string]ActionDeprecation{
+		"read": ActionDeprecation{Message: "use stream instead"},
+	}
+	_, err := decodedEntry.toExternalPluginEntry(false, false)
+	suite.Regexp("deprecates.*read.*not.*implement", err)
+
+	deprecation := ActionDeprecation{Message: "use ssh instead", RemovedIn: "2.0"}
+	decodedEntry.DeprecatedMethods = map[string]ActionDeprecation{"list": deprecation}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		d, ok := ListAction().IsDeprecatedOn(entry)
+		suite.True(ok)
+		suite.Equal(deprecation, d)
+		_, ok = ReadAction().IsDeprecatedOn(entry)
+		suite.False(ok)
+		suite.Equal(map[string]ActionDeprecation{"list": deprecation}, DeprecatedActionsOf(entry))
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDeprecatedMethodsAreRemovedInTheirRemovedInVersion() {
+	decodedEntry := newMockDecodedEntry("name")
+	decodedEntry.Methods = []interface{}{"list", "exec"}
+	decodedEntry.DeprecatedMethods = map[string]ActionDeprecation{
+		"exec": ActionDeprecation{Message: "use ssh instead", RemovedIn: "2.0"},
+	}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if !suite.NoError(err) {
+		return
+	}
+
+	// Deprecations are only warnings until the plugin declares a version that
+	// reaches their removed_in version
+	for _, version := range []string{"", "unknown", "1.9.3", "v2.0.0-rc1"} {
+		entry.version = version
+		suite.True(ExecAction().IsSupportedOn(entry), "version %q", version)
+		_, removed := ExecAction().IsRemovedFrom(entry)
+		suite.False(removed, "version %q", version)
+	}
+	for _, version := range []string{"2", "2.0.0", "v2.1", "10.0"} {
+		entry.version = version
+		suite.False(ExecAction().IsSupportedOn(entry), "version %q", version)
+		suite.True(ListAction().IsSupportedOn(entry), "version %q", version)
+		d, removed := ExecAction().IsRemovedFrom(entry)
+		suite.True(removed, "version %q", version)
+		suite.Equal("2.0", d.RemovedIn)
+	}
+
+	childEntry, err := entry.newChild(newMockDecodedEntry("child"))
+	if suite.NoError(err) {
+		suite.Equal("10.0", childEntry.version)
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestActionDeprecationIsRemovedIn() {
+	cases := []struct {
+		removedIn string
+		version   string
+		removed   bool
+	}{
+		{"2.0", "1.9", false},
+		{"2.0", "2.0", true},
+		{"2.0", "2.0.1", true},
+		{"1.10", "1.9", false},
+		{"1.9", "1.10", true},
+		{"v1.2.3", "1.2.3+abc", true},
+		{"2.0", "2.0.0-rc1", false},
+		{"2.0.0-rc1", "2.0.0-rc2", true},
+		{"", "2.0", false},
+		{"next", "2.0", false},
+		{"2.0", "", false},
+	}
+	for _, c := range cases {
+		d := ActionDeprecation{RemovedIn: c.removedIn}
+		suite.Equal(c.removed, d.IsRemovedIn(c.version), "removed_in %q, version %q", c.removedIn, c.version)
+	}
+}
+
 func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithSchema_SchemaUnknown_DoesNotImplementSchema() {
 	decodedEntry := decodedExternalPluginEntry{
 		Name:    "decodedEntry",
@@ -486,6 +858,13 @@ func (suite *ExternalPluginEntryTestSuite) TestList() {
 	_, err = entry.List(ctx)
 	suite.Regexp(regexp.MustCompile("stdout"), err)
 
+	// Test that List returns an error if stdout exceeded its maximum size
+	truncated := mockInvocation([]byte("[{\"name\":"))
+	truncated.stdoutTruncated = true
+	mockScript.OnInvokeAndWait(ctx, "list", entry).Return(truncated, nil).Once()
+	_, err = entry.List(ctx)
+	suite.Regexp(regexp.MustCompile("plugins.max_list_output_mb"), err)
+
 	// Test that List properly decodes the entries from stdout
 	stdout := "[" +
 		"{\"name\":\"foo\",\"methods\":[\"list\"],\"type_id\":\"bar\"}" +
@@ -508,6 +887,180 @@ func (suite *ExternalPluginEntryTestSuite) TestList() {
 	}
 }
 
+func (suite *ExternalPluginEntryTestSuite) TestListWithBufferedStreamingList() {
+	// The mock script can't stream its output, like daemons, so the entries
+	// are decoded from its buffered stdout
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase:     NewEntry("foo"),
+		script:        mockScript,
+		streamingList: true,
+	}
+	entry.SetTestID("/fooPlugin")
+
+	ctx := context.Background()
+	stdout := "{\"name\":\"bar\",\"methods\":[\"read\"]}\n\n{\"name\":\"baz\",\"methods\":[\"read\"]}\n"
+	mockScript.OnInvokeAndWait(ctx, "list", entry).Return(mockInvocation([]byte(stdout)), nil).Once()
+	entries, err := entry.List(ctx)
+	if suite.NoError(err) && suite.Len(entries, 2) {
+		suite.Equal("bar", entries[0].name())
+		suite.Equal("baz", entries[1].name())
+	}
+
+	mockScript.OnInvokeAndWait(ctx, "list", entry).Return(mockInvocation([]byte("[{\"name\":\"bar\"}]")), nil).Once()
+	_, err = entry.List(ctx)
+	suite.Regexp("could not decode entry 1", err)
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestStreamFrom() {
+	entry := &externalPluginEntry{
+		EntryBase:       NewEntry("foo"),
+		script:          newExternalPluginScript("", "testdata/resumableStream.sh"),
+		resumableStream: true,
+	}
+	entry.SetTestID("/foo")
+	// readAll reads the stream until it ends, then returns its output and
+	// its final offset
+	readAll := func(rdr io.ReadCloser) (string, int64) {
+		defer rdr.Close()
+		var buf bytes.Buffer
+		_, err := io.Copy(&buf, rdr)
+		suite.NoError(err)
+		suite.NotNil(StreamEndOf(rdr, io.EOF))
+		offset, ok := StreamOffsetOf(rdr)
+		suite.True(ok)
+		return buf.String(), offset
+	}
+
+	rdr, err := entry.Stream(context.Background())
+	if suite.NoError(err) {
+		offset, ok := StreamOffsetOf(rdr)
+		suite.True(ok)
+		suite.Equal(int64(12), offset)
+		output, offset := readAll(rdr)
+		suite.Equal("line3\n", output)
+		suite.Equal(int64(18), offset)
+	}
+
+	rdr, err = entry.StreamFrom(context.Background(), StreamPosition{Offset: 6, HasOffset: true})
+	if suite.NoError(err) {
+		output, offset := readAll(rdr)
+		suite.Equal("line2\nline3\n", output)
+		suite.Equal(int64(18), offset)
+	}
+
+	// Streams whose header doesn't include the offset don't know it
+	since := time.Date(2019, 5, 17, 12, 15, 0, 0, time.FixedZone("", 2*60*60))
+	rdr, err = entry.StreamFrom(context.Background(), StreamPosition{Since: since})
+	if suite.NoError(err) {
+		defer rdr.Close()
+		suite.Equal("since 2019-05-17T10:15:00Z\n", suite.readLine(rdr))
+		_, ok := StreamOffsetOf(rdr)
+		suite.False(ok)
+	}
+
+	entry.resumableStream = false
+	_, err = entry.StreamFrom(context.Background(), StreamPosition{Offset: 6, HasOffset: true})
+	suite.Regexp("the stream of /foo can't be resumed", err)
+	// Only resumable streams can include the offset in their header
+	_, err = entry.Stream(context.Background())
+	suite.Regexp("invalid header: 200 12", err)
+}
+
+func (suite *ExternalPluginEntryTestSuite) readLine(rdr io.Reader) string {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := rdr.Read(b); err != nil {
+			suite.FailNow(err.Error())
+		}
+		line = append(line, b[0])
+		if b[0] == '\n' {
+			return string(line)
+		}
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestParseStreamHeader() {
+	_, hasOffset, err := parseStreamHeader("200", false)
+	suite.NoError(err)
+	suite.False(hasOffset)
+
+	offset, hasOffset, err := parseStreamHeader("200 42", true)
+	if suite.NoError(err) {
+		suite.True(hasOffset)
+		suite.Equal(int64(42), offset)
+	}
+
+	_, _, err = parseStreamHeader("200 42", false)
+	suite.Regexp("invalid header", err)
+	_, _, err = parseStreamHeader("200 -1", true)
+	suite.Regexp("offset must be a non-negative integer", err)
+	_, _, err = parseStreamHeader("404", true)
+	suite.Regexp("invalid header", err)
+
+	_, err = readStreamHeader(strings.NewReader(strings.Repeat("x", maxStreamHeaderSize+1)))
+	suite.Regexp("invalid header", err)
+	header, err := readStreamHeader(strings.NewReader("200 5\nfoo"))
+	if suite.NoError(err) {
+		suite.Equal("200 5", header)
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestListStreaming() {
+	entry := &externalPluginEntry{
+		EntryBase:     NewEntry("foo"),
+		script:        newExternalPluginScript("", "testdata/streamingList.sh"),
+		streamingList: true,
+	}
+	entry.SetTestID("/fast")
+
+	var names []string
+	err := entry.ListStreaming(context.Background(), func(child Entry) error {
+		names = append(names, child.name())
+		return nil
+	})
+	if suite.NoError(err) {
+		suite.Equal([]string{"first", "second", "third"}, names)
+	}
+
+	// Test that the children are streamed before the script exits, and that
+	// the script's stopped once onEntry errors
+	entry.SetTestID("/slow")
+	names = nil
+	start := time.Now()
+	err = entry.ListStreaming(context.Background(), func(child Entry) error {
+		names = append(names, child.name())
+		if len(names) == 2 {
+			return fmt.Errorf("stop")
+		}
+		return nil
+	})
+	suite.EqualError(err, "stop")
+	suite.Equal([]string{"first", "second"}, names)
+	suite.True(time.Since(start) < 5*time.Second)
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestListPassesAlongTheProtocolVersion() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase:       NewEntry("foo"),
+		script:          mockScript,
+		protocolVersion: ExternalPluginProtocolVersion + 1,
+	}
+	entry.SetTestID("/fooPlugin")
+
+	ctx := context.Background()
+	stdout := `[{"name":"foo","methods":["exec"],"exec_options":["cwd","new_option"]}]`
+	mockScript.OnInvokeAndWait(ctx, "list", entry).Return(mockInvocation([]byte(stdout)), nil).Once()
+	entries, err := entry.List(ctx)
+	if suite.NoError(err) && suite.Len(entries, 1) {
+		child := entries[0].(*externalPluginEntry)
+		suite.Equal(entry.protocolVersion, child.protocolVersion)
+		suite.Equal([]string{"cwd"}, child.execOptions)
+	}
+}
+
 func (suite *ExternalPluginEntryTestSuite) TestOpen() {
 	mockScript := &mockExternalPluginScript{path: "plugin_script"}
 	entry := &externalPluginEntry{
@@ -537,7 +1090,62 @@ func (suite *ExternalPluginEntryTestSuite) TestOpen() {
 	}
 }
 
-func (suite *ExternalPluginEntryTestSuite) TestListOpenWithMethodResults() {
+func (suite *ExternalPluginEntryTestSuite) TestOpenWithPartialReads() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase:    NewEntry("foo"),
+		methods:      map[string]interface{}{"read": nil},
+		script:       mockScript,
+		partialReads: true,
+	}
+	entry.SetTestID("/foo")
+	entry.Attributes().SetSize(10)
+
+	// Open shouldn't invoke the script
+	type ctxKey struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "open"))
+	rdr, err := entry.Open(ctx)
+	cancel()
+	if !suite.NoError(err) {
+		return
+	}
+	suite.Equal(int64(10), rdr.Size())
+	if pr, ok := rdr.(PartialReader); suite.True(ok) {
+		suite.True(pr.SupportsPartialReads())
+	}
+
+	// Test that ReadAt reads the requested range with Open's ctx, even if it's
+	// cancelled
+	openCtx := mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Value(ctxKey{}) == "open" && ctx.Err() == nil
+	})
+	mockScript.OnInvokeAndWait(openCtx, "read", entry, "2", "3").Return(mockInvocation([]byte("cde")), nil).Once()
+	p := make([]byte, 3)
+	n, err := rdr.ReadAt(p, 2)
+	if suite.NoError(err) {
+		suite.Equal(3, n)
+		suite.Equal("cde", string(p))
+	}
+
+	// Test that ReadAt doesn't read past the end of the content
+	mockScript.OnInvokeAndWait(mock.Anything, "read", entry, "8", "2").Return(mockInvocation([]byte("ij")), nil).Once()
+	p = make([]byte, 5)
+	n, err = rdr.ReadAt(p, 8)
+	suite.Equal(io.EOF, err)
+	suite.Equal(2, n)
+	suite.Equal("ij", string(p[:n]))
+	_, err = rdr.ReadAt(p, 10)
+	suite.Equal(io.EOF, err)
+
+	// Test that ReadAt returns the invocation's error
+	mockErr := fmt.Errorf("execution error")
+	mockScript.OnInvokeAndWait(mock.Anything, "read", entry, "0", "5").Return(mockInvocation([]byte{}), mockErr).Once()
+	_, err = rdr.ReadAt(p, 0)
+	suite.EqualError(err, mockErr.Error())
+	mockScript.AssertExpectations(suite.T())
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestOpenWithValidators() {
 	mockScript := &mockExternalPluginScript{path: "plugin_script"}
 	entry := &externalPluginEntry{
 		EntryBase: NewEntry("foo"),
@@ -545,15 +1153,50 @@ func (suite *ExternalPluginEntryTestSuite) TestListOpenWithMethodResults() {
 	}
 	entry.SetTestID("/foo")
 
-	ctx := context.Background()
-	mockInvokeAndWait := func(stdout []byte, err error) {
-		mockScript.OnInvokeAndWait(ctx, "list", entry).Return(mockInvocation(stdout), err).Once()
+	slot := &validatorsSlot{previous: Validators{ETag: "v1"}}
+	ctx := context.WithValue(context.Background(), validatorsKey, slot)
+	writeValidators := func(validators string) {
+		mockScript.OnInvokeAndWait(ctx, "read", entry).Return(mockInvocation([]byte("foo")), nil).Run(func(mock.Arguments) {
+			env := validatorsEnv(ctx)
+			if suite.Len(env, 2) {
+				suite.Equal(etagEnvVar+"=v1", env[1])
+				suite.NoError(ioutil.WriteFile(strings.TrimPrefix(env[0], validatorsFileEnvVar+"="), []byte(validators), 0600))
+			}
+		}).Once()
 	}
 
-	// Test that List is invoked when
-	stdoutFn := func(content string) []byte {
-		return []byte(`
-[{"name": "foo", "methods": [
+	writeValidators(`{"etag":"v2"}`)
+	_, err := entry.Open(ctx)
+	if suite.NoError(err) {
+		suite.Equal(Validators{ETag: "v2"}, slot.current)
+	}
+
+	writeValidators(`{"etag":"v1","unchanged":true}`)
+	_, err = entry.Open(ctx)
+	suite.Equal(ErrUnchanged, err)
+
+	writeValidators(`not json`)
+	_, err = entry.Open(ctx)
+	suite.Regexp("could not decode the validators", err)
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestListOpenWithMethodResults() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		script:    mockScript,
+	}
+	entry.SetTestID("/foo")
+
+	ctx := context.Background()
+	mockInvokeAndWait := func(stdout []byte, err error) {
+		mockScript.OnInvokeAndWait(ctx, "list", entry).Return(mockInvocation(stdout), err).Once()
+	}
+
+	// Test that List is invoked when
+	stdoutFn := func(content string) []byte {
+		return []byte(`
+[{"name": "foo", "methods": [
 	["list", [
 		{"name": "bar", "methods": [["read", "` + content + `"]]}
 	]]
@@ -676,9 +1319,439 @@ func (suite *ExternalPluginEntryTestSuite) TestMetadata_Implemented() {
 	}
 }
 
+func (suite *ExternalPluginEntryTestSuite) TestRefreshAttributes() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		methods:   map[string]interface{}{"read": nil, "attributes": nil},
+		script:    mockScript,
+	}
+	entry.SetTestID("/foo")
+	suite.True(RefreshesAttributes(entry))
+
+	ctx := context.Background()
+	mockInvokeAndWait := func(stdout []byte, err error) {
+		mockScript.OnInvokeAndWait(ctx, "attributes", entry).Return(mockInvocation(stdout), err).Once()
+	}
+
+	mockErr := fmt.Errorf("execution error")
+	mockInvokeAndWait([]byte{}, mockErr)
+	_, err := entry.RefreshAttributes(ctx)
+	suite.EqualError(mockErr, err.Error())
+
+	mockInvokeAndWait([]byte("bad format"), nil)
+	_, err = entry.RefreshAttributes(ctx)
+	suite.Regexp("stdout", err)
+
+	mockInvokeAndWait([]byte("{\"size\":1024}"), nil)
+	attr, err := entry.RefreshAttributes(ctx)
+	if suite.NoError(err) {
+		suite.Equal(uint64(1024), attr.Size())
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithAttributesMethod() {
+	decodedEntry := decodedExternalPluginEntry{Name: "foo", Methods: []interface{}{"read", "attributes"}}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.True(RefreshesAttributes(entry))
+	}
+
+	decodedEntry.Methods = []interface{}{"read", []interface{}{"attributes", map[string]interface{}{"size": 10}}}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.Regexp("entry foo prefetched its attributes. Set the attributes key instead", err)
+
+	decodedEntry.Methods = []interface{}{"read"}
+	entry, err = decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.False(RefreshesAttributes(entry))
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestTelemetry() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		methods:   map[string]interface{}{"telemetry": nil},
+		script:    mockScript,
+	}
+	entry.SetTestID("/foo")
+	suite.True(TelemetryAction().IsSupportedOn(entry))
+
+	ctx := context.Background()
+	mockInvokeAndWait := func(stdout []byte, err error) {
+		// telemetry has a default timeout, so the context's a child of ctx
+		mockScript.OnInvokeAndWait(mock.Anything, "telemetry", entry).Return(mockInvocation(stdout), err).Once()
+	}
+
+	mockErr := fmt.Errorf("execution error")
+	mockInvokeAndWait([]byte{}, mockErr)
+	_, err := entry.Telemetry(ctx)
+	suite.EqualError(mockErr, err.Error())
+
+	mockInvokeAndWait([]byte("[1, 2]"), nil)
+	_, err = entry.Telemetry(ctx)
+	suite.Regexp("stdout", err)
+
+	mockInvokeAndWait([]byte("null"), nil)
+	_, err = entry.Telemetry(ctx)
+	suite.Regexp("must be a JSON object", err)
+
+	mockInvokeAndWait([]byte("{\"cpu_percent\":12.5}"), nil)
+	telemetry, err := entry.Telemetry(ctx)
+	if suite.NoError(err) {
+		suite.Equal(JSONObject{"cpu_percent": 12.5}, telemetry)
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithTelemetryMethod() {
+	decodedEntry := decodedExternalPluginEntry{Name: "foo", Methods: []interface{}{"telemetry"}}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.Contains(SupportedActionsOf(entry), TelemetryAction().Name)
+	}
+
+	decodedEntry.Methods = []interface{}{[]interface{}{"telemetry", map[string]interface{}{"cpu_percent": 10}}}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.Regexp("entry foo prefetched its telemetry", err)
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestWrite() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		methods:   map[string]interface{}{"write": nil},
+		script:    mockScript,
+	}
+	entry.SetTestID("/foo")
+
+	ctx := context.Background()
+	stdinHas := func(content string) interface{} {
+		return mock.MatchedBy(func(stdin io.Reader) bool {
+			data, err := ioutil.ReadAll(stdin)
+			return err == nil && string(data) == content
+		})
+	}
+	mockInvokeAndWait := func(stdout []byte, err error) {
+		mockScript.OnInvokeAndWaitWithStdin(ctx, "write", entry, stdinHas("content")).Return(mockInvocation(stdout), err).Once()
+	}
+
+	// Test that if InvokeAndWait errors, then Write returns its error
+	mockErr := fmt.Errorf("execution error")
+	mockInvokeAndWait([]byte{}, mockErr)
+	suite.EqualError(entry.Write(ctx, []byte("content")), mockErr.Error())
+
+	// Test that Write returns an error if stdout does not have the right
+	// output format
+	mockInvokeAndWait([]byte("bad format"), nil)
+	suite.Regexp(regexp.MustCompile("stdout"), entry.Write(ctx, []byte("content")))
+
+	// Test that Write returns the reported error
+	mockInvokeAndWait([]byte(`{"error":"the file is read-only"}`), nil)
+	suite.EqualError(entry.Write(ctx, []byte("content")), "could not write to /foo: the file is read-only")
+
+	// Test that Write succeeds if the script doesn't report an error
+	mockInvokeAndWait([]byte("\n"), nil)
+	suite.NoError(entry.Write(ctx, []byte("content")))
+	mockInvokeAndWait([]byte("{}"), nil)
+	suite.NoError(entry.Write(ctx, []byte("content")))
+	mockScript.AssertExpectations(suite.T())
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDelete() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		methods:   map[string]interface{}{"delete": nil},
+		script:    mockScript,
+	}
+	entry.SetTestID("/foo")
+
+	ctx := context.Background()
+	mockInvokeAndWait := func(stdout []byte, err error) {
+		mockScript.OnInvokeAndWait(ctx, "delete", entry).Return(mockInvocation(stdout), err).Once()
+	}
+
+	// Test that if InvokeAndWait errors, then Delete returns its error
+	mockErr := fmt.Errorf("execution error")
+	mockInvokeAndWait([]byte{}, mockErr)
+	suite.EqualError(entry.Delete(ctx), mockErr.Error())
+
+	// Test that Delete returns an error if stdout does not have the right
+	// output format
+	mockInvokeAndWait([]byte("bad format"), nil)
+	suite.Regexp(regexp.MustCompile("stdout"), entry.Delete(ctx))
+
+	// Test that Delete returns the reported error
+	mockInvokeAndWait([]byte(`{"error":"the instance is protected from termination"}`), nil)
+	suite.EqualError(entry.Delete(ctx), "could not delete /foo: the instance is protected from termination")
+
+	// Test that Delete succeeds if the script doesn't report an error
+	mockInvokeAndWait([]byte("\n"), nil)
+	suite.NoError(entry.Delete(ctx))
+	mockInvokeAndWait([]byte("{}"), nil)
+	suite.NoError(entry.Delete(ctx))
+	mockScript.AssertExpectations(suite.T())
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestRename() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		methods:   map[string]interface{}{"rename": nil},
+		script:    mockScript,
+	}
+	entry.SetTestID("/foo")
+
+	ctx := context.Background()
+	mockInvokeAndWait := func(stdout []byte, err error) {
+		mockScript.OnInvokeAndWait(ctx, "rename", entry, "bar").Return(mockInvocation(stdout), err).Once()
+	}
+
+	// Test that if InvokeAndWait errors, then Rename returns its error
+	mockErr := fmt.Errorf("execution error")
+	mockInvokeAndWait([]byte{}, mockErr)
+	suite.EqualError(entry.Rename(ctx, "bar"), mockErr.Error())
+
+	// Test that Rename returns an error if stdout does not have the right
+	// output format
+	mockInvokeAndWait([]byte("bad format"), nil)
+	suite.Regexp(regexp.MustCompile("stdout"), entry.Rename(ctx, "bar"))
+
+	// Test that Rename returns the reported error
+	mockInvokeAndWait([]byte(`{"error":"the name is already taken"}`), nil)
+	suite.EqualError(entry.Rename(ctx, "bar"), "could not rename /foo to bar: the name is already taken")
+
+	// Test that Rename succeeds if the script doesn't report an error
+	mockInvokeAndWait([]byte("\n"), nil)
+	suite.NoError(entry.Rename(ctx, "bar"))
+	mockInvokeAndWait([]byte("{}"), nil)
+	suite.NoError(entry.Rename(ctx, "bar"))
+	mockScript.AssertExpectations(suite.T())
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestSignal() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		methods:   map[string]interface{}{"signal": nil},
+		script:    mockScript,
+	}
+	entry.SetTestID("/foo")
+
+	ctx := context.Background()
+	mockInvokeAndWait := func(stdout []byte, err error) {
+		mockScript.OnInvokeAndWait(ctx, "signal", entry, "stop").Return(mockInvocation(stdout), err).Once()
+	}
+
+	// Test that if InvokeAndWait errors, then Signal returns its error
+	mockErr := fmt.Errorf("execution error")
+	mockInvokeAndWait([]byte{}, mockErr)
+	suite.EqualError(entry.Signal(ctx, "stop"), mockErr.Error())
+
+	// Test that Signal returns an error if stdout does not have the right
+	// output format
+	mockInvokeAndWait([]byte("bad format"), nil)
+	suite.Regexp(regexp.MustCompile("stdout"), entry.Signal(ctx, "stop"))
+
+	// Test that Signal returns the reported error
+	mockInvokeAndWait([]byte(`{"error":"the container is already stopped"}`), nil)
+	suite.EqualError(entry.Signal(ctx, "stop"), "could not send stop to /foo: the container is already stopped")
+
+	// Test that Signal succeeds if the script doesn't report an error
+	mockInvokeAndWait([]byte("{}"), nil)
+	suite.NoError(entry.Signal(ctx, "stop"))
+	mockScript.AssertExpectations(suite.T())
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestCreateChild() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		methods:   map[string]interface{}{"list": nil, "create": nil},
+		script:    mockScript,
+	}
+	entry.SetTestID("/foo")
+
+	ctx := context.Background()
+	mockInvokeAndWait := func(stdout []byte, err error) {
+		mockScript.OnInvokeAndWait(ctx, "create", entry, "bar", "dir").Return(mockInvocation(stdout), err).Once()
+	}
+
+	// Test that if InvokeAndWait errors, then CreateChild returns its error
+	mockErr := fmt.Errorf("execution error")
+	mockInvokeAndWait([]byte{}, mockErr)
+	_, err := entry.CreateChild(ctx, "bar", true)
+	suite.EqualError(err, mockErr.Error())
+
+	// Test that CreateChild returns an error if stdout does not have the right
+	// output format
+	mockInvokeAndWait([]byte("bad format"), nil)
+	_, err = entry.CreateChild(ctx, "bar", true)
+	suite.Regexp(regexp.MustCompile("stdout"), err)
+
+	// Test that CreateChild returns the created child, which inherits the
+	// entry's script
+	mockInvokeAndWait([]byte(`{"name":"bar","methods":["list","delete"]}`), nil)
+	child, err := entry.CreateChild(ctx, "bar", true)
+	if suite.NoError(err) {
+		suite.Equal("bar", child.name())
+		suite.ElementsMatch([]string{"list", "delete"}, child.(*externalPluginEntry).supportedMethods())
+		suite.Equal(mockScript, child.(*externalPluginEntry).script)
+	}
+
+	mockScript.OnInvokeAndWait(ctx, "create", entry, "baz", "file").Return(mockInvocation([]byte(`{"name":"baz","methods":["write"]}`)), nil).Once()
+	child, err = entry.CreateChild(ctx, "baz", false)
+	if suite.NoError(err) {
+		suite.Equal("baz", child.name())
+	}
+	mockScript.AssertExpectations(suite.T())
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestDecodeExternalPluginEntryWithCreate() {
+	decodedEntry := decodedExternalPluginEntry{Name: "foo", Methods: []interface{}{"create"}}
+	_, err := decodedEntry.toExternalPluginEntry(false, false)
+	suite.Regexp("entry foo implements create, but does not implement list", err)
+
+	decodedEntry.Methods = []interface{}{"list", []interface{}{"create", "bar"}}
+	_, err = decodedEntry.toExternalPluginEntry(false, false)
+	suite.Regexp("entry foo prefetched its create result", err)
+
+	decodedEntry.Methods = []interface{}{"list", "create"}
+	entry, err := decodedEntry.toExternalPluginEntry(false, false)
+	if suite.NoError(err) {
+		suite.ElementsMatch([]string{"list", "create"}, SupportedActionsOf(entry))
+	}
+}
+
 // TODO: Add tests for stdoutStreamer, Stream and Exec
 // once the API for Stream and Exec's at a more stable
 // state.
+func (suite *ExternalPluginEntryTestSuite) TestExecPassesOptionsAndStdin() {
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		script:    newExternalPluginScript("", "testdata/exec.sh"),
+	}
+	entry.SetTestID("/foo")
+
+	opts := ExecOptions{Stdin: strings.NewReader("input"), Tty: true}
+	cmd, err := entry.Exec(context.Background(), "echo", []string{"hello"}, opts)
+	if !suite.NoError(err) {
+		return
+	}
+	var stdout bytes.Buffer
+	for chunk := range cmd.OutputCh() {
+		if suite.NoError(chunk.Err) && chunk.StreamID == Stdout {
+			stdout.WriteString(chunk.Data)
+		}
+	}
+	lines := strings.SplitN(stdout.String(), "\n", 3)
+	if suite.Len(lines, 3) {
+		suite.JSONEq(`{"tty":true,"elevate":false,"env":null,"cwd":"","stdin":true}`, lines[0])
+		suite.Equal("echo hello", lines[1])
+		suite.Equal("input", lines[2])
+	}
+	exitCode, err := cmd.ExitCode()
+	if suite.NoError(err) {
+		suite.Equal(3, exitCode)
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) execEvents(cmd string) (string, string, ExecCommand) {
+	entry := &externalPluginEntry{
+		EntryBase:  NewEntry("foo"),
+		script:     newExternalPluginScript("", "testdata/execEvents.sh"),
+		execEvents: true,
+	}
+	entry.SetTestID("/foo")
+
+	execCmd, err := entry.Exec(context.Background(), cmd, nil, ExecOptions{})
+	if !suite.NoError(err) {
+		suite.FailNow("could not exec")
+	}
+	var stdout, stderr bytes.Buffer
+	for chunk := range execCmd.OutputCh() {
+		if chunk.Err != nil {
+			continue
+		}
+		if chunk.StreamID == Stdout {
+			stdout.WriteString(chunk.Data)
+		} else {
+			stderr.WriteString(chunk.Data)
+		}
+	}
+	return stdout.String(), stderr.String(), execCmd
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestExecWithExecEvents() {
+	stdout, stderr, cmd := suite.execEvents("ok")
+	suite.Equal("hello\nworld\n", stdout)
+	suite.Equal("oops", stderr)
+	exitCode, err := cmd.ExitCode()
+	if suite.NoError(err) {
+		suite.Equal(2, exitCode)
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestExecWithExecEvents_ErrorEvent() {
+	stdout, _, cmd := suite.execEvents("error")
+	suite.Equal("partial", stdout)
+	_, err := cmd.ExitCode()
+	if suite.Error(err) {
+		suite.Regexp("^the API is down", err.Error())
+		suite.True(IsRetryable(err))
+	}
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestExecWithExecEvents_MalformedEvents() {
+	_, _, cmd := suite.execEvents("malformed")
+	_, err := cmd.ExitCode()
+	suite.Regexp("(?s)could not decode the exec's events.*some diagnostics", err)
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestExecWithExecEvents_MissingExitEvent() {
+	stdout, _, cmd := suite.execEvents("noexit")
+	suite.Equal("hello", stdout)
+	_, err := cmd.ExitCode()
+	suite.Regexp("ended without an exit or error event.*exit status 1", err)
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestRun() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase:     NewEntry("foo"),
+		methods:       map[string]interface{}{"read": nil},
+		customActions: []string{"snapshot"},
+		script:        mockScript,
+	}
+	entry.SetTestID("/foo")
+
+	ctx := context.Background()
+	mockInvokeAndWait := func(stdout []byte, err error) {
+		mockScript.OnInvokeAndWait(ctx, "snapshot", entry, "--name", "nightly").Return(mockInvocation(stdout), err).Once()
+	}
+
+	// Test that if InvokeAndWait errors, then Run returns its error
+	mockErr := fmt.Errorf("execution error")
+	mockInvokeAndWait([]byte{}, mockErr)
+	_, err := entry.Run(ctx, "snapshot", []string{"--name", "nightly"})
+	suite.EqualError(err, mockErr.Error())
+
+	// Test that Run returns the action's output
+	mockInvokeAndWait([]byte("created snapshot nightly\n"), nil)
+	output, err := entry.Run(ctx, "snapshot", []string{"--name", "nightly"})
+	if suite.NoError(err) {
+		suite.Equal("created snapshot nightly\n", string(output))
+	}
+	mockScript.AssertExpectations(suite.T())
+
+	// Test that Run doesn't invoke the script for unknown actions
+	_, err = entry.Run(ctx, "reboot", nil)
+	suite.EqualError(err, "/foo does not have the reboot action")
+}
+
+// TODO: Add tests for stdoutStreamer and Stream once the API for Stream's at
+// a more stable state.
 
 func (suite *ExternalPluginEntryTestSuite) TestUnmarshalSchemaGraph_ErrorsIfNotAJSONObject() {
 	entry := &externalPluginEntry{}
@@ -937,6 +2010,56 @@ func (suite *ExternalPluginEntryTestSuite) TestUnmarshalSchemaGraph_ValidInput()
 	}
 }
 
+func (suite *ExternalPluginEntryTestSuite) TestUnmarshalSchemaGraph_ErrorsIfDeprecatedMethodNotImplemented() {
+	entry := &externalPluginEntry{
+		rawTypeID: "foo",
+	}
+	entry.SetTestID("fooPlugin")
+
+	stdout := []byte(`
+{
+	"foo":{
+		"label": "fooLabel",
+		"methods": ["read"],
+		"deprecated_methods": {
+			"exec": {"message": "use ssh instead"}
+		}
+	}
+}
+`)
+	_, err := unmarshalSchemaGraph(entry, stdout)
+	suite.Regexp("deprecates.*exec", err)
+}
+
+func (suite *ExternalPluginEntryTestSuite) TestUnmarshalSchemaGraph_DecodesDeprecatedMethods() {
+	entry := &externalPluginEntry{
+		rawTypeID: "foo",
+	}
+	entry.SetTestID("/fooPlugin")
+
+	stdout := []byte(`
+{
+	"foo":{
+		"label": "fooLabel",
+		"methods": ["read", "exec"],
+		"deprecated_methods": {
+			"exec": {"message": "use ssh instead", "since": "1.2", "removed_in": "2.0"}
+		}
+	}
+}
+`)
+	graph, err := unmarshalSchemaGraph(entry, stdout)
+	if suite.NoError(err) {
+		node, ok := graph.Get("fooPlugin::foo")
+		if suite.True(ok) {
+			expected := map[string]ActionDeprecation{
+				"exec": ActionDeprecation{Message: "use ssh instead", Since: "1.2", RemovedIn: "2.0"},
+			}
+			suite.Equal(expected, node.(entrySchema).DeprecatedActions)
+		}
+	}
+}
+
 func TestExternalPluginEntry(t *testing.T) {
 	suite.Run(t, new(ExternalPluginEntryTestSuite))
 }
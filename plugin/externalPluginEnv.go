@@ -0,0 +1,234 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/plugin/internal"
+)
+
+// ExternalPluginEnv configures the environment that an external plugin's
+// script is invoked with. By default, the script inherits Wash's entire
+// environment.
+type ExternalPluginEnv struct {
+	// Allow are the inherited environment variables that are passed to the
+	// script, e.g. AWS_PROFILE or KUBECONFIG. A trailing * matches a
+	// prefix, e.g. AWS_*. If Allow's set, then the rest of the environment
+	// is removed, except for the basics that most scripts need (like PATH,
+	// HOME and the locale) and the plugin's required environment variables
+	// (see Requirements.Env).
+	Allow []string `mapstructure:"allow"`
+	// Set are the environment variables that are injected into each
+	// invocation. Their values can reference Wash's environment variables,
+	// e.g. $HOME/.mycloud.
+	Set map[string]string `mapstructure:"set"`
+	// Secrets are the environment variables whose values are resolved by the
+	// CredentialsHelper, e.g. an API token that shouldn't live in Wash's
+	// environment or config.
+	Secrets []string `mapstructure:"secrets"`
+	// CredentialsHelper is the path to an executable that resolves Secrets.
+	// It's passed an ExternalPluginSecretsRequest as JSON on stdin, and prints
+	// a JSON object that maps each secret to its value on stdout.
+	CredentialsHelper string `mapstructure:"credentials_helper"`
+}
+
+func (e ExternalPluginEnv) isEmpty() bool {
+	return len(e.Allow) == 0 && len(e.Set) == 0 && len(e.Secrets) == 0 && e.CredentialsHelper == ""
+}
+
+func (e ExternalPluginEnv) validate() error {
+	for name := range e.Set {
+		if name == "" || strings.Contains(name, "=") {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	if len(e.Secrets) == 0 {
+		if e.CredentialsHelper != "" {
+			return fmt.Errorf("the credentials helper %v is set, but there aren't any secrets for it to resolve", e.CredentialsHelper)
+		}
+		return nil
+	}
+	if e.CredentialsHelper == "" {
+		return fmt.Errorf("the secrets %v require a credentials helper", strings.Join(e.Secrets, ", "))
+	}
+	info, err := os.Stat(e.CredentialsHelper)
+	if err != nil {
+		return fmt.Errorf("invalid credentials helper %v: %v", e.CredentialsHelper, err)
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return fmt.Errorf("invalid credentials helper %v: it isn't an executable file", e.CredentialsHelper)
+	}
+	return nil
+}
+
+// ExternalPluginSecretsRequest is passed to a credentials helper when the
+// Secrets of the plugin named Plugin are resolved
+type ExternalPluginSecretsRequest struct {
+	Plugin  string   `json:"plugin"`
+	Secrets []string `json:"secrets"`
+}
+
+// baseEnv are the inherited environment variables that are passed to every
+// script, even if the plugin's environment is sanitized
+var baseEnv = []string{
+	"PATH",
+	"HOME",
+	"USER",
+	"LOGNAME",
+	"SHELL",
+	"TMPDIR",
+	"TZ",
+	"TERM",
+	"LANG",
+	"LC_*",
+}
+
+// secretsTTL is how long a credentials helper's secrets are reused before it's
+// invoked again
+const secretsTTL = 1 * time.Minute
+
+// secretsRenewalWindow is how long before the secrets' TTL runs out that the
+// credentials helper's invoked again, so that invocations don't wait for it
+const secretsRenewalWindow = 10 * time.Second
+
+// credentialsHelperTimeout is how long a credentials helper has to resolve the
+// secrets
+const credentialsHelperTimeout = 10 * time.Second
+
+// externalPluginEnv builds the environment of a plugin's invocations
+type externalPluginEnv struct {
+	name     string
+	spec     ExternalPluginEnv
+	required []string
+	secrets  *Credentials
+}
+
+// newExternalPluginEnv returns the environment of the plugin named name.
+// required are the plugin's required environment variables. It returns nil
+// if the plugin inherits Wash's environment as-is.
+func newExternalPluginEnv(name string, spec ExternalPluginEnv, required []string) *externalPluginEnv {
+	if spec.isEmpty() {
+		return nil
+	}
+	e := &externalPluginEnv{name: name, spec: spec, required: required}
+	if len(spec.Secrets) > 0 {
+		// The secrets are shared by the plugin's concurrent invocations, so
+		// only one of them invokes the credentials helper when they're stale
+		e.secrets = RegisterCredentials(name, secretsRenewalWindow, func(ctx context.Context) (Credential, error) {
+			values, err := e.resolveSecrets(ctx)
+			if err != nil {
+				return Credential{}, err
+			}
+			return Credential{Value: values, ExpiresAt: time.Now().Add(secretsTTL)}, nil
+		})
+	}
+	return e
+}
+
+// environ returns the environment that an invocation's script is started with,
+// excluding the invocation-specific variables. Secrets that can't be resolved
+// are left out so that the invocation can still proceed; the script will
+// notice that they're missing.
+func (e *externalPluginEnv) environ(ctx context.Context) []string {
+	if e == nil {
+		return os.Environ()
+	}
+	var env []string
+	if len(e.spec.Allow) == 0 {
+		env = os.Environ()
+	} else {
+		allowed := append(append(append([]string{}, baseEnv...), e.required...), e.spec.Allow...)
+		for _, envVar := range os.Environ() {
+			name := strings.SplitN(envVar, "=", 2)[0]
+			if isAllowedEnvVar(name, allowed) {
+				env = append(env, envVar)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(e.spec.Set))
+	for name := range e.spec.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+os.ExpandEnv(e.spec.Set[name]))
+	}
+	return append(env, e.secretsEnv(ctx)...)
+}
+
+// secretsEnv returns the resolved secrets as NAME=VALUE pairs
+func (e *externalPluginEnv) secretsEnv(ctx context.Context) []string {
+	if e == nil || e.secrets == nil {
+		return nil
+	}
+	credential, err := e.secrets.Get(ctx)
+	if err != nil {
+		// Secrets aren't recorded anywhere, so only the failure's reported
+		activity.Warnf(ctx, "Could not resolve the %v plugin's secrets: %v", e.name, err)
+		return nil
+	}
+	values := credential.Value.(map[string]string)
+	secrets := make([]string, 0, len(e.spec.Secrets))
+	for _, name := range e.spec.Secrets {
+		secrets = append(secrets, name+"="+values[name])
+	}
+	return secrets
+}
+
+const credentialsHelperOutputFormat = "{\"SECRET_NAME\":\"value\"}"
+
+func (e *externalPluginEnv) resolveSecrets(ctx context.Context) (map[string]string, error) {
+	input, err := json.Marshal(ExternalPluginSecretsRequest{Plugin: e.name, Secrets: e.spec.Secrets})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal the request: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, credentialsHelperTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	command := internal.NewCommand(ctx, e.spec.CredentialsHelper)
+	command.SetStdin(bytes.NewReader(input))
+	command.SetStdout(&stdout)
+	command.SetStderr(&stderr)
+	if err := command.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("the credentials helper didn't resolve them within %v", credentialsHelperTimeout)
+		}
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			return nil, fmt.Errorf("the credentials helper failed: %v: %v", err, output)
+		}
+		return nil, fmt.Errorf("the credentials helper failed: %v", err)
+	}
+	var values map[string]string
+	if err := json.Unmarshal(stdout.Bytes(), &values); err != nil {
+		// Don't include stdout in the error since it could contain secrets
+		return nil, fmt.Errorf("could not decode the credentials helper's stdout: it should look like %v", credentialsHelperOutputFormat)
+	}
+	for _, name := range e.spec.Secrets {
+		if _, ok := values[name]; !ok {
+			return nil, fmt.Errorf("the credentials helper didn't resolve %v", name)
+		}
+	}
+	return values, nil
+}
+
+func isAllowedEnvVar(name string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginEnvTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ExternalPluginEnvTestSuite) SetupTest() {
+	for name, value := range map[string]string{
+		"AWS_PROFILE":       "dev",
+		"AWS_REGION":        "us-west-2",
+		"KUBECONFIG":        "/tmp/kubeconfig",
+		"UNRELATED_SECRET":  "hunter2",
+		"WASH_TEST_ENV_DIR": "/tmp/mycloud",
+	} {
+		suite.NoError(os.Setenv(name, value))
+	}
+}
+
+func (suite *ExternalPluginEnvTestSuite) TearDownTest() {
+	for _, name := range []string{"AWS_PROFILE", "AWS_REGION", "KUBECONFIG", "UNRELATED_SECRET", "WASH_TEST_ENV_DIR"} {
+		suite.NoError(os.Unsetenv(name))
+	}
+}
+
+func toEnvMap(env []string) map[string]string {
+	m := make(map[string]string)
+	for _, envVar := range env {
+		segments := strings.SplitN(envVar, "=", 2)
+		m[segments[0]] = segments[1]
+	}
+	return m
+}
+
+func (suite *ExternalPluginEnvTestSuite) TestEmptyEnvInheritsWashsEnvironment() {
+	e := newExternalPluginEnv("mycloud", ExternalPluginEnv{}, nil)
+	suite.Nil(e)
+	suite.Equal(os.Environ(), e.environ(context.Background()))
+}
+
+func (suite *ExternalPluginEnvTestSuite) TestAllowSanitizesTheEnvironment() {
+	e := newExternalPluginEnv("mycloud", ExternalPluginEnv{Allow: []string{"AWS_*"}}, []string{"KUBECONFIG"})
+	env := toEnvMap(e.environ(context.Background()))
+	suite.Equal("dev", env["AWS_PROFILE"])
+	suite.Equal("us-west-2", env["AWS_REGION"])
+	suite.Equal("/tmp/kubeconfig", env["KUBECONFIG"])
+	suite.Equal(os.Getenv("PATH"), env["PATH"])
+	suite.NotContains(env, "UNRELATED_SECRET")
+}
+
+func (suite *ExternalPluginEnvTestSuite) TestSetInjectsVariables() {
+	e := newExternalPluginEnv("mycloud", ExternalPluginEnv{
+		Set: map[string]string{"AWS_PROFILE": "prod", "MYCLOUD_CONFIG": "$WASH_TEST_ENV_DIR/config"},
+	}, nil)
+	env := toEnvMap(e.environ(context.Background()))
+	suite.Equal("prod", env["AWS_PROFILE"])
+	suite.Equal("/tmp/mycloud/config", env["MYCLOUD_CONFIG"])
+	// The environment isn't sanitized without an allow-list
+	suite.Equal("hunter2", env["UNRELATED_SECRET"])
+}
+
+func (suite *ExternalPluginEnvTestSuite) TestSecretsAreResolvedByTheCredentialsHelper() {
+	spec := ExternalPluginEnv{Secrets: []string{"API_TOKEN"}, CredentialsHelper: "testdata/credentialsHelper.sh"}
+	suite.NoError(spec.validate())
+	e := newExternalPluginEnv("mycloud", spec, nil)
+	suite.Equal([]string{"API_TOKEN=hunter2"}, e.secretsEnv(context.Background()))
+	suite.Equal("hunter2", toEnvMap(e.environ(context.Background()))["API_TOKEN"])
+}
+
+func (suite *ExternalPluginEnvTestSuite) TestSecretsThatCantBeResolvedAreLeftOut() {
+	for _, name := range []string{"broken", "partial"} {
+		e := newExternalPluginEnv(name, ExternalPluginEnv{Secrets: []string{"API_TOKEN"}, CredentialsHelper: "testdata/credentialsHelper.sh"}, nil)
+		suite.Empty(e.secretsEnv(context.Background()), name)
+		suite.NotContains(toEnvMap(e.environ(context.Background())), "API_TOKEN", name)
+	}
+}
+
+func (suite *ExternalPluginEnvTestSuite) TestResolveSecretsErrors() {
+	e := newExternalPluginEnv("broken", ExternalPluginEnv{Secrets: []string{"API_TOKEN"}, CredentialsHelper: "testdata/credentialsHelper.sh"}, nil)
+	_, err := e.resolveSecrets(context.Background())
+	suite.Regexp("the credentials helper failed: .*the vault is sealed", err)
+
+	e = newExternalPluginEnv("partial", ExternalPluginEnv{Secrets: []string{"API_TOKEN"}, CredentialsHelper: "testdata/credentialsHelper.sh"}, nil)
+	_, err = e.resolveSecrets(context.Background())
+	suite.Regexp("the credentials helper didn't resolve API_TOKEN", err)
+}
+
+func (suite *ExternalPluginEnvTestSuite) TestSecretsAreSharedByTheInvocations() {
+	e := newExternalPluginEnv("mycloud", ExternalPluginEnv{Secrets: []string{"API_TOKEN"}, CredentialsHelper: "testdata/credentialsHelper.sh"}, nil)
+	suite.NotEmpty(e.secretsEnv(context.Background()))
+	suite.NotEmpty(e.secretsEnv(context.Background()))
+	c, ok := FindCredentials("mycloud")
+	if suite.True(ok) {
+		status := c.Status()
+		suite.Equal(1, status.Generation)
+		suite.WithinDuration(time.Now().Add(secretsTTL), status.ExpiresAt, 5*time.Second)
+	}
+}
+
+func (suite *ExternalPluginEnvTestSuite) TestValidate() {
+	suite.Regexp("require a credentials helper", ExternalPluginEnv{Secrets: []string{"API_TOKEN"}}.validate())
+	suite.Regexp("there aren't any secrets", ExternalPluginEnv{CredentialsHelper: "testdata/credentialsHelper.sh"}.validate())
+	suite.Regexp("invalid credentials helper testdata/missing", ExternalPluginEnv{Secrets: []string{"API_TOKEN"}, CredentialsHelper: "testdata/missing"}.validate())
+	suite.Regexp("it isn't an executable file", ExternalPluginEnv{Secrets: []string{"API_TOKEN"}, CredentialsHelper: "testdata/noexec"}.validate())
+	suite.Regexp("invalid environment variable name", ExternalPluginEnv{Set: map[string]string{"A=B": "c"}}.validate())
+}
+
+func (suite *ExternalPluginEnvTestSuite) TestInvocationsUseTheEnvironment() {
+	spec := ExternalPluginSpec{
+		Script: "testdata/env.sh",
+		Env: ExternalPluginEnv{
+			Allow:             []string{"AWS_PROFILE"},
+			Set:               map[string]string{"MYCLOUD_REGION": "eu"},
+			Secrets:           []string{"API_TOKEN"},
+			CredentialsHelper: "testdata/credentialsHelper.sh",
+		},
+	}
+	root, err := spec.Load()
+	if !suite.NoError(err) {
+		return
+	}
+	suite.NoError(root.Init(nil))
+	SetAPISocket("/tmp/wash-api.sock")
+	defer SetAPISocket("")
+	entry := root.(*externalPluginRoot).externalPluginEntry
+	inv, err := entry.script.InvokeAndWait(context.Background(), "read", entry)
+	if !suite.NoError(err) {
+		return
+	}
+	env := toEnvMap(strings.Split(strings.TrimSpace(inv.stdout.String()), "\n"))
+	suite.Equal("dev", env["AWS_PROFILE"])
+	suite.Equal("eu", env["MYCLOUD_REGION"])
+	suite.Equal("hunter2", env["API_TOKEN"])
+	suite.Contains(env, protocolVersionEnvVar)
+	suite.Equal("/tmp/wash-api.sock", env[apiSocketEnvVar])
+	suite.NotContains(env, "AWS_REGION")
+	suite.NotContains(env, "UNRELATED_SECRET")
+}
+
+func TestExternalPluginEnv(t *testing.T) {
+	suite.Run(t, new(ExternalPluginEnvTestSuite))
+}
@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+)
+
+// These are the kinds of structured errors that external plugin scripts can
+// report (see ExternalPluginError). Errors of an unknown kind are treated as
+// ErrorKindUnknown errors.
+const (
+	ErrorKindNotFound         = "not_found"
+	ErrorKindPermissionDenied = "permission_denied"
+	ErrorKindTimeout          = "timeout"
+	ErrorKindUnavailable      = "unavailable"
+	ErrorKindUnknown          = "unknown"
+)
+
+var externalPluginErrorKinds = []string{
+	ErrorKindNotFound,
+	ErrorKindPermissionDenied,
+	ErrorKindTimeout,
+	ErrorKindUnavailable,
+	ErrorKindUnknown,
+}
+
+// decodedExternalPluginError is the structured error that a failed script can
+// print to stderr
+type decodedExternalPluginError struct {
+	Kind      string `json:"kind"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+// decodeExternalPluginError decodes the structured error in a failed script's
+// stderr. The error's either all of stderr, or its last line so that scripts
+// can log other things before they fail. The returned bool is false if stderr
+// doesn't contain a structured error.
+func decodeExternalPluginError(stderr string) (decodedExternalPluginError, bool) {
+	var decoded decodedExternalPluginError
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return decoded, false
+	}
+	candidates := []string{stderr}
+	if i := strings.LastIndex(stderr, "\n"); i >= 0 {
+		candidates = append(candidates, strings.TrimSpace(stderr[i+1:]))
+	}
+	for _, candidate := range candidates {
+		if !strings.HasPrefix(candidate, "{") {
+			continue
+		}
+		decoded = decodedExternalPluginError{}
+		if err := json.Unmarshal([]byte(candidate), &decoded); err == nil && decoded.Message != "" {
+			return decoded, true
+		}
+	}
+	return decoded, false
+}
+
+// toError returns the ExternalPluginError that e describes. details describe
+// the failed invocation.
+func (e decodedExternalPluginError) toError(details string) *ExternalPluginError {
+	kind := ErrorKindUnknown
+	for _, k := range externalPluginErrorKinds {
+		if e.Kind == k {
+			kind = k
+			break
+		}
+	}
+	return &ExternalPluginError{
+		Kind:      kind,
+		Message:   e.Message,
+		Retryable: e.Retryable,
+		details:   details,
+	}
+}
+
+// ExternalPluginError is a structured error that an external plugin script
+// reported, e.g. that the entry no longer exists or that its API is
+// unavailable. errors.Is classifies it by its kind, so e.g.
+// errors.Is(err, os.ErrPermission) is true for permission_denied errors.
+type ExternalPluginError struct {
+	Kind    string
+	Message string
+	// Retryable is true if the failed invocation's worth retrying
+	Retryable bool
+	details   string
+}
+
+func (e *ExternalPluginError) Error() string {
+	return e.Message + "\n" + e.details
+}
+
+// Is returns true if the error's kind corresponds to target
+func (e *ExternalPluginError) Is(target error) bool {
+	switch e.Kind {
+	case ErrorKindNotFound:
+		return target == os.ErrNotExist
+	case ErrorKindPermissionDenied:
+		return target == os.ErrPermission
+	case ErrorKindTimeout:
+		return target == context.DeadlineExceeded
+	default:
+		return false
+	}
+}
+
+// IsRetryable returns true if err is (or wraps) a retryable
+// ExternalPluginError
+func IsRetryable(err error) bool {
+	var pluginErr *ExternalPluginError
+	return errors.As(err, &pluginErr) && pluginErr.Retryable
+}
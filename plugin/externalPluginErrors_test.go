@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginErrorsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ExternalPluginErrorsTestSuite) TestDecodeExternalPluginError() {
+	decoded, ok := decodeExternalPluginError(`{"kind":"not_found","message":"no such bucket"}`)
+	if suite.True(ok) {
+		suite.Equal(decodedExternalPluginError{Kind: ErrorKindNotFound, Message: "no such bucket"}, decoded)
+	}
+
+	// The error can be on the last line so that scripts can log other things
+	decoded, ok = decodeExternalPluginError("listing buckets\n{\"kind\":\"unavailable\",\"message\":\"rate-limited\",\"retryable\":true}\n")
+	if suite.True(ok) {
+		suite.Equal(decodedExternalPluginError{Kind: ErrorKindUnavailable, Message: "rate-limited", Retryable: true}, decoded)
+	}
+
+	for _, stderr := range []string{"", "failed", `{"kind":"not_found"}`, "{\"message\":\"failed\"}\nmore output"} {
+		_, ok = decodeExternalPluginError(stderr)
+		suite.False(ok, "%q shouldn't be a structured error", stderr)
+	}
+}
+
+func (suite *ExternalPluginErrorsTestSuite) TestNewInvokeError() {
+	inv := invocation{}
+	inv.stderr.WriteString(`{"kind":"permission_denied","message":"access denied"}`)
+	err := newInvokeError("script returned a non-zero exit code of 1", inv)
+	suite.True(errors.Is(err, os.ErrPermission))
+	suite.False(IsRetryable(err))
+	suite.Regexp("^access denied\nscript returned a non-zero exit code of 1", err.Error())
+
+	inv = invocation{}
+	inv.stderr.WriteString("access denied")
+	err = newInvokeError("script returned a non-zero exit code of 1", inv)
+	suite.False(errors.Is(err, os.ErrPermission))
+}
+
+func (suite *ExternalPluginErrorsTestSuite) TestToError() {
+	err := decodedExternalPluginError{Kind: "bogus", Message: "failed"}.toError("")
+	suite.Equal(ErrorKindUnknown, err.Kind)
+
+	err = decodedExternalPluginError{Kind: ErrorKindNotFound, Message: "failed"}.toError("")
+	suite.True(errors.Is(err, os.ErrNotExist))
+	err = decodedExternalPluginError{Kind: ErrorKindTimeout, Message: "failed"}.toError("")
+	suite.True(errors.Is(fmt.Errorf("list failed: %w", err), context.DeadlineExceeded))
+}
+
+func TestExternalPluginErrors(t *testing.T) {
+	suite.Run(t, new(ExternalPluginErrorsTestSuite))
+}
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// These are the types of the events that an exec prints if its entry sets
+// exec_events. Output events carry a chunk of the command's stdout or stderr.
+// The exit event carries the command's exit code, and the error event reports
+// that the command couldn't be executed (e.g. because an API call failed).
+// Either of them ends the exec.
+const (
+	execEventStdout = "stdout"
+	execEventStderr = "stderr"
+	execEventExit   = "exit"
+	execEventError  = "error"
+)
+
+var externalPluginExecEventTypes = []string{
+	execEventStdout,
+	execEventStderr,
+	execEventExit,
+	execEventError,
+}
+
+// base64Encoding is the encoding of an output event's data if the data isn't
+// UTF-8 text
+const base64Encoding = "base64"
+
+// maxExecEventsStderr is how much of an exec's stderr is kept when its entry
+// sets exec_events. The command's stderr is sent as events, so the script's
+// own stderr is only used to report why the exec failed.
+const maxExecEventsStderr = 64 * 1024
+
+var errExecEventsEnded = fmt.Errorf("the exec's events ended without an %v or %v event", execEventExit, execEventError)
+
+// decodedExecEvent is one of the newline-delimited JSON events that an exec
+// prints to stdout if its entry sets exec_events
+type decodedExecEvent struct {
+	Type     string                      `json:"type"`
+	Data     string                      `json:"data"`
+	Encoding string                      `json:"encoding"`
+	ExitCode *int                        `json:"exit_code"`
+	Error    *decodedExternalPluginError `json:"error"`
+}
+
+// execEventsResult is the result of decoding an exec's events. Only one of its
+// fields is set.
+type execEventsResult struct {
+	exitCode *int
+	// execErr is the error that the script reported via an error event
+	execErr *decodedExternalPluginError
+	// decodeErr is set if the events couldn't be decoded, or if they ended
+	// without an exit or error event
+	decodeErr error
+}
+
+// decodeExecEvents decodes the events in r, writing the output events to
+// execCmd's streams, until it decodes an exit or error event
+func decodeExecEvents(r io.Reader, execCmd *ExecCommandImpl) execEventsResult {
+	decoder := json.NewDecoder(r)
+	for {
+		var event decodedExecEvent
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				err = errExecEventsEnded
+			}
+			return execEventsResult{decodeErr: err}
+		}
+		switch event.Type {
+		case execEventStdout, execEventStderr:
+			data, err := event.data()
+			if err != nil {
+				return execEventsResult{decodeErr: err}
+			}
+			stream := execCmd.Stdout()
+			if event.Type == execEventStderr {
+				stream = execCmd.Stderr()
+			}
+			if _, err := stream.Write(data); err != nil {
+				// The exec was cancelled
+				return execEventsResult{decodeErr: err}
+			}
+		case execEventExit:
+			if event.ExitCode == nil {
+				return execEventsResult{decodeErr: fmt.Errorf("the %v event must include the exit_code", execEventExit)}
+			}
+			return execEventsResult{exitCode: event.ExitCode}
+		case execEventError:
+			if event.Error == nil || event.Error.Message == "" {
+				return execEventsResult{decodeErr: fmt.Errorf("the %v event must include the error's message", execEventError)}
+			}
+			return execEventsResult{execErr: event.Error}
+		default:
+			return execEventsResult{decodeErr: fmt.Errorf("unknown exec event type %q", event.Type)}
+		}
+	}
+}
+
+func (e decodedExecEvent) data() ([]byte, error) {
+	switch e.Encoding {
+	case "":
+		return []byte(e.Data), nil
+	case base64Encoding:
+		data, err := base64.StdEncoding.DecodeString(e.Data)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode the %v event's data: %v", e.Type, err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("the %v event's data has an unknown encoding %q. Use %q, or omit it for UTF-8 text", e.Type, e.Encoding, base64Encoding)
+	}
+}
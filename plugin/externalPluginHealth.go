@@ -0,0 +1,297 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+	log "github.com/sirupsen/logrus"
+)
+
+var healthCheckInterval = limits.Register(
+	"plugins.health_check_interval_ms",
+	"How many milliseconds apart each external plugin's health checks are. 0 disables the health checks.",
+	30000,
+	nil,
+)
+
+var healthCheckTimeout = limits.Register(
+	"plugins.health_check_timeout_ms",
+	"How many milliseconds an external plugin's health check can take before it fails. It's overridden by the plugin root's health timeout (if any).",
+	10000,
+	nil,
+)
+
+var quarantineAfter = limits.Register(
+	"plugins.quarantine_after_failures",
+	"The number of consecutive health checks that an external plugin must fail before it's quarantined. A quarantined plugin's entries fail immediately (instead of invoking its script) until it passes a health check. 0 disables quarantining.",
+	3,
+	nil,
+)
+
+// listHealthCheckTimeout caps the timeout of the health checks of the roots
+// that don't implement health, which are checked by listing them instead. A
+// listing's usually slower than a health check, but a backend that takes this
+// long to list the root is unlikely to be healthy.
+var listHealthCheckTimeout = 5 * time.Second
+
+// healthCheckPollDelay is how often a health check that's disabled (see
+// plugins.health_check_interval_ms) checks whether it was re-enabled
+var healthCheckPollDelay = 5 * time.Second
+
+// QuarantinedErr is returned by the entries of an external plugin that's
+// quarantined because it repeatedly failed its health checks
+type QuarantinedErr struct {
+	Plugin   string
+	Failures int
+	// Reason is the error of the plugin's last health check
+	Reason error
+}
+
+func (e QuarantinedErr) Error() string {
+	return fmt.Sprintf(
+		"the %v plugin is quarantined because its last %v health checks failed. Its entries are unavailable until it passes a health check. Its last health check failed with: %v",
+		e.Plugin,
+		e.Failures,
+		e.Reason,
+	)
+}
+
+// quarantines tracks the external plugin roots' consecutive health check
+// failures and their quarantines. They're keyed by the root's ID.
+var quarantines = struct {
+	mux         sync.Mutex
+	failures    map[string]int
+	quarantined map[string]QuarantinedErr
+}{
+	failures:    make(map[string]int),
+	quarantined: make(map[string]QuarantinedErr),
+}
+
+// quarantineErrOf returns the QuarantinedErr of the entry with the given ID if
+// its plugin's quarantined. Otherwise, it returns nil.
+func quarantineErrOf(id string) error {
+	quarantines.mux.Lock()
+	defer quarantines.mux.Unlock()
+	for rootID, err := range quarantines.quarantined {
+		if id == rootID || strings.HasPrefix(id, strings.TrimRight(rootID, "/")+"/") {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordHealthCheck records the result of the root's health check. The root's
+// quarantined once it fails enough consecutive health checks, and its
+// quarantine's lifted once it passes one. Lifting the quarantine clears the
+// root's cached results, which may include the errors of the requests that
+// were made while it was unhealthy.
+func recordHealthCheck(r *externalPluginRoot, err error) {
+	id, name := r.id(), r.name()
+	quarantines.mux.Lock()
+	defer quarantines.mux.Unlock()
+	if err == nil {
+		delete(quarantines.failures, id)
+		if _, ok := quarantines.quarantined[id]; ok {
+			delete(quarantines.quarantined, id)
+			log.Infof("The %v plugin passed its health check, so it's no longer quarantined", name)
+			setQuarantinedStatus(id, name, "")
+			if _, err := ClearCacheFor(id); err != nil {
+				log.Warnf("Could not clear the %v plugin's cached results once its quarantine was lifted: %v", name, err)
+			}
+		}
+		return
+	}
+
+	quarantines.failures[id]++
+	failures := quarantines.failures[id]
+	log.Debugf("The %v plugin failed its health check (%v in a row): %v", name, failures, err)
+	if threshold := quarantineAfter.Value(); threshold <= 0 || failures < threshold {
+		return
+	}
+	_, wasQuarantined := quarantines.quarantined[id]
+	quarantined := QuarantinedErr{Plugin: name, Failures: failures, Reason: err}
+	quarantines.quarantined[id] = quarantined
+	if !wasQuarantined {
+		log.Warnf("Quarantined the %v plugin because its last %v health checks failed: %v", name, failures, err)
+		setQuarantinedStatus(id, name, quarantined.Error())
+	}
+}
+
+// liftQuarantine forgets the root's health checks, e.g. because it was
+// replaced
+func liftQuarantine(r *externalPluginRoot) {
+	quarantines.mux.Lock()
+	defer quarantines.mux.Unlock()
+	delete(quarantines.failures, r.id())
+	delete(quarantines.quarantined, r.id())
+}
+
+// setQuarantinedStatus sets the status of the plugin with the given root ID to
+// PluginQuarantined, or back to PluginLoaded if reason is empty. Only the
+// registered plugins have a status, so the nested roots of meta plugins are
+// skipped.
+func setQuarantinedStatus(id string, name string, reason string) {
+	if id != "/"+name {
+		return
+	}
+	for _, status := range PluginStatuses() {
+		if status.Name != name {
+			continue
+		}
+		if reason == "" {
+			status.Status, status.Reason = PluginLoaded, ""
+		} else {
+			status.Status, status.Reason = PluginQuarantined, reason
+		}
+		setPluginStatus(status)
+	}
+}
+
+// checkHealth invokes the root's health method. The root's healthy if the
+// invocation succeeds. Roots that don't implement health are listed instead,
+// with a timeout of at most listHealthCheckTimeout. The listing bypasses the
+// cache, and its output's ignored.
+func (r *externalPluginRoot) checkHealth(ctx context.Context) error {
+	method := "health"
+	timeout, ok := r.timeoutOf(method)
+	if !ok {
+		timeout = time.Duration(healthCheckTimeout.Value()) * time.Millisecond
+	}
+	if !r.implements(method) {
+		method = "list"
+		if timeout <= 0 || timeout > listHealthCheckTimeout {
+			timeout = listHealthCheckTimeout
+		}
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	inv, err := r.script.InvokeAndWait(ctx, method, r.externalPluginEntry)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return newInvokeError(fmt.Sprintf("%v timed out after %v", method, timeout), inv)
+	}
+	return err
+}
+
+// isHealthCheckable returns true if the root's health can be checked, i.e. if
+// it implements health or if listing it invokes its script. Static plugins
+// don't invoke a script, so they're never checked.
+func (r *externalPluginRoot) isHealthCheckable() bool {
+	if _, ok := r.script.(staticPluginScript); ok {
+		return false
+	}
+	if r.implements("health") {
+		return true
+	}
+	// Prefetched listings are the root's methods' non-nil values
+	return r.implements("list") && r.methods["list"] == nil
+}
+
+// externalPluginHealthCheck periodically checks an external plugin root's
+// health for as long as the root's registered (see
+// plugins.health_check_interval_ms). The health method's invoked as
+// `<plugin_script> health <path> <state>`, and it passes if it exits with 0.
+// Roots that don't implement health are listed instead (see checkHealth). A root that fails plugins.quarantine_after_failures
+// consecutive checks is quarantined: its entries fail with a QuarantinedErr
+// instead of invoking its script, so that a broken plugin doesn't hang every
+// listing of it. The quarantine's lifted once the root passes a health check.
+type externalPluginHealthCheck struct {
+	root   *externalPluginRoot
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+var healthChecks = make(map[*externalPluginRoot]*externalPluginHealthCheck)
+var healthChecksMux sync.Mutex
+
+// startHealthChecksOf starts the health checks of root's external plugin roots
+func startHealthChecksOf(root Root) {
+	healthChecksMux.Lock()
+	defer healthChecksMux.Unlock()
+	for _, r := range externalRootsOf(root) {
+		if !r.isHealthCheckable() || healthChecks[r] != nil {
+			continue
+		}
+		setRootIDOf(root, r)
+		c := &externalPluginHealthCheck{root: r, stopCh: make(chan struct{}), doneCh: make(chan struct{})}
+		healthChecks[r] = c
+		go c.run()
+	}
+}
+
+// stopHealthChecksOf stops the health checks of root's external plugin roots,
+// and lifts their quarantines, e.g. because the root was replaced
+func stopHealthChecksOf(root Root) {
+	healthChecksMux.Lock()
+	var stopped []*externalPluginHealthCheck
+	for _, r := range externalRootsOf(root) {
+		if c, ok := healthChecks[r]; ok {
+			delete(healthChecks, r)
+			stopped = append(stopped, c)
+		}
+	}
+	healthChecksMux.Unlock()
+	for _, c := range stopped {
+		c.stop()
+		liftQuarantine(c.root)
+	}
+}
+
+// StopHealthChecks stops the external plugin roots' health checks. It should
+// be called when the Wash server shuts down.
+func StopHealthChecks() {
+	healthChecksMux.Lock()
+	stopped := healthChecks
+	healthChecks = make(map[*externalPluginRoot]*externalPluginHealthCheck)
+	healthChecksMux.Unlock()
+	for _, c := range stopped {
+		c.stop()
+	}
+}
+
+func (c *externalPluginHealthCheck) stop() {
+	close(c.stopCh)
+	<-c.doneCh
+}
+
+func (c *externalPluginHealthCheck) run() {
+	defer close(c.doneCh)
+	// Stopping the health check cancels the check that's in progress
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		interval := time.Duration(healthCheckInterval.Value()) * time.Millisecond
+		delay := interval
+		if interval <= 0 {
+			delay = healthCheckPollDelay
+		}
+		select {
+		case <-c.stopCh:
+			return
+		case <-time.After(delay):
+		}
+		if interval <= 0 {
+			continue
+		}
+		err := c.root.checkHealth(ctx)
+		select {
+		case <-c.stopCh:
+			return
+		default:
+			recordHealthCheck(c.root, err)
+		}
+	}
+}
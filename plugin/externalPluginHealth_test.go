@@ -0,0 +1,225 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginHealthTestSuite struct {
+	suite.Suite
+	healthyFile string
+}
+
+func (suite *ExternalPluginHealthTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "health_tests")
+	if err != nil {
+		suite.FailNow(err.Error())
+	}
+	suite.healthyFile = filepath.Join(dir, "healthy")
+	suite.NoError(os.Setenv("HEALTH_TESTS_FILE", suite.healthyFile))
+	suite.setHealthy(true)
+	SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *ExternalPluginHealthTestSuite) TearDownTest() {
+	UnsetTestCache()
+	suite.NoError(os.RemoveAll(filepath.Dir(suite.healthyFile)))
+	suite.NoError(os.Unsetenv("HEALTH_TESTS_FILE"))
+	_, err := limits.Set(healthCheckInterval.Name(), 30000)
+	suite.NoError(err)
+	_, err = limits.Set(quarantineAfter.Name(), 3)
+	suite.NoError(err)
+}
+
+func (suite *ExternalPluginHealthTestSuite) setHealthy(healthy bool) {
+	if healthy {
+		suite.NoError(ioutil.WriteFile(suite.healthyFile, nil, 0600))
+	} else {
+		suite.NoError(os.Remove(suite.healthyFile))
+	}
+}
+
+func (suite *ExternalPluginHealthTestSuite) newRoot(cfg map[string]interface{}) *externalPluginRoot {
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
+		EntryBase: NewEntry("health"),
+		script:    newExternalPluginScript("health", "testdata/health.sh"),
+	}}
+	if !suite.NoError(root.Init(cfg)) {
+		suite.FailNow("could not initialize the root")
+	}
+	root.setID("/health")
+	return root
+}
+
+// waitFor waits up to a second for cond to be true
+func (suite *ExternalPluginHealthTestSuite) waitFor(cond func() bool, msg string) {
+	for i := 0; i < 100 && !cond(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	suite.True(cond(), msg)
+}
+
+func (suite *ExternalPluginHealthTestSuite) TestCheckHealth() {
+	root := suite.newRoot(nil)
+	suite.NoError(root.checkHealth(context.Background()))
+	suite.setHealthy(false)
+	suite.Regexp("the backend is down", root.checkHealth(context.Background()))
+}
+
+func (suite *ExternalPluginHealthTestSuite) TestCheckHealth_ListsRootsWithoutHealth() {
+	root := suite.newRoot(map[string]interface{}{"health": false})
+	suite.NoError(root.checkHealth(context.Background()))
+	suite.setHealthy(false)
+	suite.Regexp("the backend is down", root.checkHealth(context.Background()))
+}
+
+func (suite *ExternalPluginHealthTestSuite) TestOnlyRootsCanCheckTheirHealth() {
+	entry := decodedExternalPluginEntry{Name: "foo", Methods: []interface{}{"list", "health"}}
+	_, err := entry.toExternalPluginEntry(false, false)
+	suite.Regexp("entry foo implements health, but only plugin roots can check their health", err)
+	_, err = entry.toExternalPluginEntry(false, true)
+	suite.NoError(err)
+
+	entry.Methods = []interface{}{"list", []interface{}{"health", true}}
+	_, err = entry.toExternalPluginEntry(false, true)
+	suite.Regexp("entry foo prefetched its health", err)
+}
+
+func (suite *ExternalPluginHealthTestSuite) TestIsHealthCheckable() {
+	suite.True(suite.newRoot(nil).isHealthCheckable())
+	// Roots that don't implement health are listed instead
+	suite.True(suite.newRoot(map[string]interface{}{"health": false}).isHealthCheckable())
+
+	entry := decodedExternalPluginEntry{Name: "static", Methods: []interface{}{[]interface{}{"list", []interface{}{}}}}
+	root, err := entry.toExternalPluginEntry(false, true)
+	if suite.NoError(err) {
+		suite.False((&externalPluginRoot{externalPluginEntry: root}).isHealthCheckable())
+	}
+}
+
+func (suite *ExternalPluginHealthTestSuite) TestRecordHealthCheck() {
+	_, err := limits.Set(quarantineAfter.Name(), 2)
+	suite.NoError(err)
+	root := suite.newRoot(nil)
+	defer liftQuarantine(root)
+
+	healthErr := errors.New("the backend is down")
+	recordHealthCheck(root, healthErr)
+	suite.NoError(quarantineErrOf("/health/foo"))
+	// Passing a health check resets the failures
+	recordHealthCheck(root, nil)
+	recordHealthCheck(root, healthErr)
+	suite.NoError(quarantineErrOf("/health/foo"))
+
+	recordHealthCheck(root, healthErr)
+	for _, id := range []string{"/health", "/health/foo"} {
+		err := quarantineErrOf(id)
+		var quarantinedErr QuarantinedErr
+		if suite.True(errors.As(err, &quarantinedErr), id) {
+			suite.Equal(QuarantinedErr{Plugin: "health", Failures: 2, Reason: healthErr}, quarantinedErr)
+		}
+	}
+	suite.NoError(quarantineErrOf("/healthy"))
+
+	// The quarantined root's entries fail instead of invoking its script
+	_, err = root.List(context.Background())
+	suite.IsType(QuarantinedErr{}, err)
+
+	recordHealthCheck(root, nil)
+	suite.NoError(quarantineErrOf("/health/foo"))
+	_, err = root.List(context.Background())
+	suite.NoError(err)
+}
+
+func (suite *ExternalPluginHealthTestSuite) TestRecordHealthCheck_LiftingTheQuarantineClearsTheCache() {
+	_, err := limits.Set(quarantineAfter.Name(), 1)
+	suite.NoError(err)
+	root := suite.newRoot(nil)
+	defer liftQuarantine(root)
+
+	recordHealthCheck(root, errors.New("the backend is down"))
+	// The quarantine's error is cached like any other error
+	_, err = CachedList(context.Background(), root)
+	suite.IsType(QuarantinedErr{}, err)
+	recordHealthCheck(root, nil)
+	_, err = CachedList(context.Background(), root)
+	suite.NoError(err)
+}
+
+func (suite *ExternalPluginHealthTestSuite) TestRecordHealthCheck_QuarantiningDisabled() {
+	_, err := limits.Set(quarantineAfter.Name(), 0)
+	suite.NoError(err)
+	root := suite.newRoot(nil)
+	defer liftQuarantine(root)
+	for i := 0; i < 5; i++ {
+		recordHealthCheck(root, errors.New("the backend is down"))
+	}
+	suite.NoError(quarantineErrOf("/health"))
+}
+
+func (suite *ExternalPluginHealthTestSuite) TestRecordHealthCheck_SetsPluginStatus() {
+	_, err := limits.Set(quarantineAfter.Name(), 1)
+	suite.NoError(err)
+	root := suite.newRoot(nil)
+	defer liftQuarantine(root)
+	setPluginStatus(PluginStatus{Name: "health", Status: PluginLoaded})
+
+	statusOf := func() PluginStatus {
+		for _, status := range PluginStatuses() {
+			if status.Name == "health" {
+				return status
+			}
+		}
+		return PluginStatus{}
+	}
+	recordHealthCheck(root, errors.New("the backend is down"))
+	suite.Equal(PluginQuarantined, statusOf().Status)
+	suite.Regexp("the health plugin is quarantined.*the backend is down", statusOf().Reason)
+	recordHealthCheck(root, nil)
+	suite.Equal(PluginStatus{Name: "health", Status: PluginLoaded}, statusOf())
+}
+
+func (suite *ExternalPluginHealthTestSuite) TestHealthChecks() {
+	_, err := limits.Set(healthCheckInterval.Name(), 10)
+	suite.NoError(err)
+	_, err = limits.Set(quarantineAfter.Name(), 2)
+	suite.NoError(err)
+	root := suite.newRoot(nil)
+	startHealthChecksOf(root)
+	defer stopHealthChecksOf(root)
+
+	suite.setHealthy(false)
+	suite.waitFor(func() bool { return quarantineErrOf("/health") != nil }, "the root wasn't quarantined")
+	suite.setHealthy(true)
+	suite.waitFor(func() bool { return quarantineErrOf("/health") == nil }, "the root's quarantine wasn't lifted")
+
+	// Stopping the health checks lifts the quarantine
+	suite.setHealthy(false)
+	suite.waitFor(func() bool { return quarantineErrOf("/health") != nil }, "the root wasn't quarantined")
+	stopHealthChecksOf(root)
+	suite.NoError(quarantineErrOf("/health"))
+}
+
+func (suite *ExternalPluginHealthTestSuite) TestHealthChecksOfNestedRoots() {
+	nestedRoot := suite.newRoot(nil)
+	nestedRoot.setID("")
+	metaRoot := newExternalPluginMetaRoot("meta", "")
+	metaRoot.nestedRoots = []Entry{nestedRoot}
+	startHealthChecksOf(metaRoot)
+	defer stopHealthChecksOf(metaRoot)
+	// The nested root's ID is namespaced under the meta root
+	suite.Equal("/meta/health", nestedRoot.id())
+}
+
+func TestExternalPluginHealth(t *testing.T) {
+	suite.Run(t, new(ExternalPluginHealthTestSuite))
+}
@@ -0,0 +1,275 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	log "github.com/sirupsen/logrus"
+)
+
+// decodedInvalidationEvent is one of the newline-delimited JSON events that a
+// plugin root's watch method prints to stdout. It tells Wash that the entry at
+// path changed in the plugin's backend, so its cached action result is
+// evicted. If the action's omitted, then the cached results of the entry and
+// its descendants are evicted.
+type decodedInvalidationEvent struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+}
+
+// externalPluginInvalidationActions are the actions whose cached results can
+// be invalidated
+var externalPluginInvalidationActions = []string{"list", "read", "metadata"}
+
+const invalidationEventFormat = "{\"path\":\"/<plugin>/<entry>\",\"action\":\"list\"}"
+
+// watchRestartDelay is how long Wash waits before restarting a watch that
+// exited
+var watchRestartDelay = 5 * time.Second
+
+// externalPluginWatch runs a plugin root's watch method for as long as the
+// root's registered. Unlike the other methods, watch doesn't return. It's
+// invoked as `<plugin_script> watch <path> <state>`, then it prints an
+// invalidation event whenever the plugin's backend changes. watch is
+// restarted if it exits, and the root's cache is cleared since its events may
+// have been missed in the meantime. Like stream, it runs in its own process
+// even if the plugin's script runs in daemon mode.
+type externalPluginWatch struct {
+	root   *externalPluginRoot
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+var watches = make(map[*externalPluginRoot]*externalPluginWatch)
+var watchesMux sync.Mutex
+
+// externalRootsOf returns root's external plugin roots, which are the nested
+// roots of a meta plugin
+func externalRootsOf(root Root) []*externalPluginRoot {
+	switch t := root.(type) {
+	case *externalPluginRoot:
+		return []*externalPluginRoot{t}
+	case *externalPluginMetaRoot:
+		var roots []*externalPluginRoot
+		for _, nestedRoot := range t.nestedRoots {
+			if r, ok := nestedRoot.(*externalPluginRoot); ok {
+				roots = append(roots, r)
+			}
+		}
+		return roots
+	default:
+		return nil
+	}
+}
+
+// setRootIDOf sets the ID of r, which is either root or one of its nested
+// roots, if it isn't set yet. It's the ID that the registry's listing would
+// set, so a meta root's nested roots are namespaced under the meta root like
+// externalPluginMetaRoot.Init does.
+func setRootIDOf(root Root, r *externalPluginRoot) {
+	if r.id() != "" {
+		return
+	}
+	id := "/" + CName(root)
+	if Root(r) != root {
+		id += "/" + CName(r)
+	}
+	r.setID(id)
+}
+
+// startWatchesOf starts the watches of root's external plugin roots that
+// implement watch
+func startWatchesOf(root Root) {
+	watchesMux.Lock()
+	defer watchesMux.Unlock()
+	for _, r := range externalRootsOf(root) {
+		if !r.implements("watch") || watches[r] != nil {
+			continue
+		}
+		setRootIDOf(root, r)
+		w := &externalPluginWatch{root: r, stopCh: make(chan struct{}), doneCh: make(chan struct{})}
+		watches[r] = w
+		go w.run()
+	}
+}
+
+// stopWatchesOf stops the watches of root's external plugin roots, e.g.
+// because the root was replaced
+func stopWatchesOf(root Root) {
+	watchesMux.Lock()
+	var stopped []*externalPluginWatch
+	for _, r := range externalRootsOf(root) {
+		if w, ok := watches[r]; ok {
+			delete(watches, r)
+			stopped = append(stopped, w)
+		}
+	}
+	watchesMux.Unlock()
+	for _, w := range stopped {
+		w.stop()
+	}
+}
+
+// StopWatches stops the external plugin roots' watches. It should be called
+// when the Wash server shuts down.
+func StopWatches() {
+	watchesMux.Lock()
+	stopped := watches
+	watches = make(map[*externalPluginRoot]*externalPluginWatch)
+	watchesMux.Unlock()
+	for _, w := range stopped {
+		w.stop()
+	}
+}
+
+func (w *externalPluginWatch) stop() {
+	close(w.stopCh)
+	<-w.doneCh
+}
+
+func (w *externalPluginWatch) run() {
+	defer close(w.doneCh)
+	name := w.root.name()
+	for {
+		err := w.watch()
+		select {
+		case <-w.stopCh:
+			return
+		default:
+		}
+		log.Warnf("The %v plugin's watch exited: %v. Restarting it in %v", name, err, watchRestartDelay)
+		select {
+		case <-w.stopCh:
+			return
+		case <-time.After(watchRestartDelay):
+		}
+		if _, err := ClearCacheFor(w.root.id()); err != nil {
+			log.Warnf("Could not clear the %v plugin's cache: %v", name, err)
+		}
+	}
+}
+
+// watch invokes the root's watch method, then evicts the cached results that
+// its events invalidate until it exits or it's stopped
+func (w *externalPluginWatch) watch() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	inv := w.root.script.NewInvocation(ctx, "watch", w.root.externalPluginEntry)
+	cmd := inv.command
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	log.Debugf("Starting the %v plugin's watch: %v", w.root.name(), cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// The watch's stderr can't be attributed to a specific request, so it's
+	// logged instead. It's read to the end before Wait is called, since Wait
+	// closes the pipe.
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			log.Debugf("%v watch: %v", w.root.name(), scanner.Text())
+		}
+		// Drain the rest (e.g. after a line that's too long) so that the
+		// script doesn't block on writing to stderr
+		_, _ = io.Copy(ioutil.Discard, stderr)
+	}()
+	go func() {
+		select {
+		case <-w.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	decodeErr := w.consume(stdout)
+	if decodeErr != nil {
+		cmd.Terminate()
+		// Drain stdout so that Wait doesn't block on the script
+		_, _ = io.Copy(ioutil.Discard, stdout)
+	}
+	<-stderrDone
+	waitErr := cmd.Wait()
+	if decodeErr != nil {
+		return decodeErr
+	}
+	if waitErr != nil {
+		return waitErr
+	}
+	return fmt.Errorf("the watch exited")
+}
+
+// consume evicts the cached results that the events in r invalidate. It
+// returns an error if an event can't be decoded.
+func (w *externalPluginWatch) consume(r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	for {
+		var event decodedInvalidationEvent
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("could not decode the invalidation event from stdout: %v. Events should look like %v", err, invalidationEventFormat)
+		}
+		if err := w.invalidate(event); err != nil {
+			// A bad event shouldn't stop the watch since the plugin can
+			// still invalidate the other entries
+			log.Warnf("The %v plugin's watch printed an invalid event: %v", w.root.name(), err)
+		}
+	}
+}
+
+func (w *externalPluginWatch) invalidate(event decodedInvalidationEvent) error {
+	id := w.root.id()
+	if event.Path != id && !strings.HasPrefix(event.Path, id+"/") {
+		return fmt.Errorf("%v is not one of the plugin's entries. Paths must start with %v", event.Path, id)
+	}
+	var deleted []string
+	var err error
+	if event.Action == "" {
+		deleted, err = ClearCacheFor(event.Path)
+	} else {
+		deleted, err = clearCachedAction(event.Path, event.Action)
+	}
+	if err != nil {
+		return err
+	}
+	activity.Record(context.Background(), "The %v plugin's watch invalidated %v: %v", w.root.name(), event.Path, deleted)
+	notifyContentChangesOf(deleted)
+	return nil
+}
+
+// notifyContentChangesOf notifies the OnContentChange listeners about the
+// entries whose content or listing was among the deleted cache keys, so that
+// the kernel's cache of them is invalidated without waiting for them to be
+// refetched
+func notifyContentChangesOf(deleted []string) {
+	notified := make(map[string]bool)
+	for _, key := range deleted {
+		segments := strings.SplitN(key, "::", 2)
+		if len(segments) != 2 || notified[segments[1]] {
+			continue
+		}
+		switch segments[0] {
+		case defaultOpCodeToNameMap[OpenOp], defaultOpCodeToNameMap[ListOp]:
+			notified[segments[1]] = true
+			notifyContentChange(segments[1])
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginInvalidationTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) SetupTest() {
+	SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) TearDownTest() {
+	UnsetTestCache()
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) newRoot() *externalPluginRoot {
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
+		EntryBase: NewEntry("watch"),
+		script:    newExternalPluginScript("watch", "testdata/watch.sh"),
+	}}
+	if !suite.NoError(root.Init(nil)) {
+		suite.FailNow("could not initialize the root")
+	}
+	return root
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) cache(opName string, id string) {
+	_, err := cache.GetOrUpdate(opName, id, time.Minute, false, func() (interface{}, error) {
+		return "value", nil
+	})
+	suite.NoError(err)
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) isCached(opName string, id string) bool {
+	value, err := cache.Get(opName, id)
+	suite.NoError(err)
+	return value != nil
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) TestClearCachedAction() {
+	suite.cache("List", "/watch/foo")
+	suite.cache("Metadata", "/watch/foo")
+	suite.cache("List", "/watch/foo/bar")
+	suite.cache("List", "/watch/foobar")
+
+	deleted, err := clearCachedAction("/watch/foo", "list")
+	if suite.NoError(err) {
+		suite.Equal([]string{"List::/watch/foo"}, deleted)
+	}
+	suite.True(suite.isCached("Metadata", "/watch/foo"))
+	suite.True(suite.isCached("List", "/watch/foo/bar"))
+	suite.True(suite.isCached("List", "/watch/foobar"))
+
+	// Clearing a listing also clears the parent's missing children
+	suite.cache("List", "/watch/foo")
+	suite.cache(missingOpName, "/watch/foo/baz")
+	suite.cache(missingOpName, "/watch/foo/bar/baz")
+	suite.cache(partitionsOpName, "/watch/foo/1")
+	suite.cache(partitionsOpName, "/watch/foo/bar/1")
+	deleted, err = clearCachedAction("/watch/foo", "list")
+	if suite.NoError(err) {
+		suite.ElementsMatch([]string{"List::/watch/foo", "Missing::/watch/foo/baz", "Partitions::/watch/foo/1"}, deleted)
+	}
+	suite.True(suite.isCached(partitionsOpName, "/watch/foo/bar/1"))
+	suite.True(suite.isCached(missingOpName, "/watch/foo/bar/baz"))
+
+	_, err = clearCachedAction("/watch/foo", "exec")
+	suite.Regexp("exec action can't be cleared. Valid actions are list, read, metadata", err)
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) TestInvalidate() {
+	root := suite.newRoot()
+	root.SetTestID("/watch")
+	w := &externalPluginWatch{root: root}
+
+	suite.cache("Open", "/watch/foo")
+	suite.cache("List", "/watch/foo")
+	suite.NoError(w.invalidate(decodedInvalidationEvent{Path: "/watch/foo", Action: "read"}))
+	suite.False(suite.isCached("Open", "/watch/foo"))
+	suite.True(suite.isCached("List", "/watch/foo"))
+
+	// Events without an action invalidate the entry's descendants too
+	suite.cache("List", "/watch/foo/bar")
+	suite.NoError(w.invalidate(decodedInvalidationEvent{Path: "/watch/foo"}))
+	suite.False(suite.isCached("List", "/watch/foo"))
+	suite.False(suite.isCached("List", "/watch/foo/bar"))
+
+	suite.Regexp("/watcher/foo is not one of the plugin's entries", w.invalidate(decodedInvalidationEvent{Path: "/watcher/foo"}))
+	suite.Regexp("signal action can't be cleared", w.invalidate(decodedInvalidationEvent{Path: "/watch", Action: "signal"}))
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) TestInvalidateNotifiesTheContentChanges() {
+	root := suite.newRoot()
+	root.SetTestID("/watch")
+	w := &externalPluginWatch{root: root}
+	changes := make(chan string, 10)
+	OnContentChange(func(id string) {
+		if strings.HasPrefix(id, "/watch/notified") {
+			changes <- id
+		}
+	})
+
+	suite.cache("Open", "/watch/notified")
+	suite.cache("List", "/watch/notified")
+	suite.cache("Metadata", "/watch/notified/bar")
+	suite.cache("Open", "/watch/notified/baz")
+	suite.NoError(w.invalidate(decodedInvalidationEvent{Path: "/watch/notified"}))
+
+	var notified []string
+	for i := 0; i < 2; i++ {
+		select {
+		case id := <-changes:
+			notified = append(notified, id)
+		case <-time.After(time.Second):
+			suite.FailNow("the content changes weren't notified")
+		}
+	}
+	// Metadata isn't content, and each entry's notified once
+	suite.ElementsMatch([]string{"/watch/notified", "/watch/notified/baz"}, notified)
+	select {
+	case id := <-changes:
+		suite.Fail("unexpected content change", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) TestWatch() {
+	root := suite.newRoot()
+	suite.cache("List", "/watch/foo")
+	suite.cache("Metadata", "/watch/foo")
+	suite.cache("List", "/watch/bar")
+	suite.cache("List", "/other/foo")
+
+	startWatchesOf(root)
+	defer stopWatchesOf(root)
+	suite.Equal("/watch", root.id())
+	for i := 0; i < 100 && suite.isCached("List", "/watch/bar"); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	suite.False(suite.isCached("List", "/watch/foo"))
+	suite.True(suite.isCached("Metadata", "/watch/foo"))
+	suite.False(suite.isCached("List", "/watch/bar"))
+	suite.True(suite.isCached("List", "/other/foo"))
+
+	// Stopping the watch terminates it
+	done := make(chan struct{})
+	go func() {
+		stopWatchesOf(root)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		suite.Fail("the watch wasn't stopped")
+	}
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) TestWatchesOfNestedRoots() {
+	nestedRoot := suite.newRoot()
+	metaRoot := newExternalPluginMetaRoot("meta", "")
+	metaRoot.nestedRoots = []Entry{nestedRoot}
+	startWatchesOf(metaRoot)
+	defer stopWatchesOf(metaRoot)
+	// The nested root's ID is namespaced under the meta root
+	suite.Equal("/meta/watch", nestedRoot.id())
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) TestOnlyRootsCanWatch() {
+	entry := decodedExternalPluginEntry{Name: "foo", Methods: []interface{}{"list", "watch"}}
+	_, err := entry.toExternalPluginEntry(false, false)
+	suite.Regexp("entry foo implements watch, but only plugin roots can watch for changes", err)
+	_, err = entry.toExternalPluginEntry(false, true)
+	suite.NoError(err)
+}
+
+func (suite *ExternalPluginInvalidationTestSuite) TestRegistryStartsAndStopsWatches() {
+	registry := NewRegistry()
+	root := suite.newRoot()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+	watchesMux.Lock()
+	_, ok := watches[root]
+	watchesMux.Unlock()
+	suite.True(ok)
+
+	suite.True(registry.UnregisterPlugin("watch"))
+	watchesMux.Lock()
+	_, ok = watches[root]
+	watchesMux.Unlock()
+	suite.False(ok)
+}
+
+func TestExternalPluginInvalidation(t *testing.T) {
+	suite.Run(t, new(ExternalPluginInvalidationTestSuite))
+}
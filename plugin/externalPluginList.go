@@ -0,0 +1,229 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/puppetlabs/wash/limits"
+)
+
+// These limits keep a misbehaving external plugin from spiking the server's
+// memory usage with a huge list result
+var maxListEntries = limits.Register(
+	"plugins.max_list_entries",
+	"The maximum number of entries that an external plugin's list can return. 0 means unlimited.",
+	100000,
+	nil,
+)
+
+var maxListOutputSize = limits.Register(
+	"plugins.max_list_output_mb",
+	"The maximum size (in MB) of an external plugin's list output. Output past the limit is discarded, and the list errors. 0 means unlimited.",
+	256,
+	nil,
+)
+
+// maxListOutputBytes returns the maximum size of a list output in bytes, or
+// 0 if it's unlimited
+func maxListOutputBytes() int64 {
+	return int64(maxListOutputSize.Value()) * 1024 * 1024
+}
+
+// cappedWriter writes to w until max bytes have been written. Subsequent
+// writes are discarded (as opposed to failed) so that the plugin script still
+// runs to completion instead of blocking on a full stdout pipe.
+type cappedWriter struct {
+	w        io.Writer
+	max      int64
+	written  int64
+	exceeded bool
+}
+
+func (c *cappedWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if c.max > 0 && c.written+int64(n) > c.max {
+		c.exceeded = true
+		p = p[:c.max-c.written]
+	}
+	written, err := c.w.Write(p)
+	c.written += int64(written)
+	if err != nil {
+		return written, err
+	}
+	return n, nil
+}
+
+// cappedReader reads from r until max bytes have been read. Subsequent reads
+// fail once it's clear that r has more than max bytes.
+type cappedReader struct {
+	r        io.Reader
+	max      int64
+	read     int64
+	exceeded bool
+}
+
+var errCappedReaderExceeded = errors.New("the output exceeded its maximum size")
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if c.max > 0 && c.read >= c.max {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		// Output that's exactly max bytes isn't too large
+		n, err := c.r.Read(p[:1])
+		if n > 0 {
+			c.exceeded = true
+			return 0, errCappedReaderExceeded
+		}
+		return 0, err
+	}
+	if c.max > 0 && int64(len(p)) > c.max-c.read {
+		p = p[:c.max-c.read]
+	}
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+// decodeEntries incrementally decodes the JSON array of entries in r, calling
+// onEntry for each decoded entry. That way, only one decoded entry needs to be
+// kept in memory at a time. It errors if the array has more than the
+// plugins.max_list_entries limit's entries.
+func decodeEntries(r io.Reader, onEntry func(decodedExternalPluginEntry) error) error {
+	decoder := json.NewDecoder(r)
+	tok, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected an array, not %v", tok)
+	}
+	if err := decodeEntryArray(decoder, onEntry); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after the array of entries")
+	}
+	return nil
+}
+
+// decodeEntriesPage is decodeEntries for a list result that may be a page of
+// the entries, i.e. an object like pagedListFormat. It returns the next page's
+// token, which is empty if the result's the last page (or if it's an array).
+func decodeEntriesPage(r io.Reader, onEntry func(decodedExternalPluginEntry) error) (string, error) {
+	decoder := json.NewDecoder(r)
+	tok, err := decoder.Token()
+	if err != nil {
+		return "", err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok || (delim != '[' && delim != '{') {
+		return "", fmt.Errorf("expected an array or an object, not %v", tok)
+	}
+	if delim == '[' {
+		if err := decodeEntryArray(decoder, onEntry); err != nil {
+			return "", err
+		}
+		if decoder.More() {
+			return "", fmt.Errorf("unexpected data after the array of entries")
+		}
+		return "", nil
+	}
+
+	var nextPage string
+	for decoder.More() {
+		tok, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		switch tok {
+		case "entries":
+			tok, err := decoder.Token()
+			if err != nil {
+				return "", err
+			}
+			if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+				return "", fmt.Errorf("expected entries to be an array, not %v", tok)
+			}
+			if err := decodeEntryArray(decoder, onEntry); err != nil {
+				return "", err
+			}
+		case "next_page":
+			if err := decoder.Decode(&nextPage); err != nil {
+				return "", fmt.Errorf("expected next_page to be a string: %v", err)
+			}
+		default:
+			return "", fmt.Errorf("unexpected key %v", tok)
+		}
+	}
+	// Consume the closing '}'
+	if _, err := decoder.Token(); err != nil {
+		return "", err
+	}
+	if decoder.More() {
+		return "", fmt.Errorf("unexpected data after the page of entries")
+	}
+	return nextPage, nil
+}
+
+// decodeEntryArray decodes the rest of the JSON array of entries whose opening
+// '[' was already consumed (see decodeEntries)
+func decodeEntryArray(decoder *json.Decoder, onEntry func(decodedExternalPluginEntry) error) error {
+	max := maxListEntries.Value()
+	for count := 0; decoder.More(); count++ {
+		if max > 0 && count >= max {
+			return fmt.Errorf("more than %v entries were returned. Increase the %v limit if that's expected", max, maxListEntries.Name())
+		}
+		var decodedEntry decodedExternalPluginEntry
+		if err := decoder.Decode(&decodedEntry); err != nil {
+			return err
+		}
+		if err := onEntry(decodedEntry); err != nil {
+			return err
+		}
+	}
+	// Consume the closing ']'
+	_, err := decoder.Token()
+	return err
+}
+
+// decodeEntryLines decodes the newline-delimited JSON entries in data, which
+// is the output of a streaming list
+func decodeEntryLines(data []byte, onEntry func(decodedExternalPluginEntry) error) error {
+	decodeLine := newEntryLineDecoder(onEntry)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if err := decodeLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const streamingListLineFormat = "{\"name\":\"entry1\",\"methods\":[\"list\"]}"
+
+// newEntryLineDecoder returns a function that decodes a line of a streaming
+// list's output, then calls onEntry with the decoded entry. Blank lines are
+// skipped. Like decodeEntries, it errors once more than the
+// plugins.max_list_entries limit's entries were decoded.
+func newEntryLineDecoder(onEntry func(decodedExternalPluginEntry) error) func([]byte) error {
+	max := maxListEntries.Value()
+	count := 0
+	return func(line []byte) error {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			return nil
+		}
+		if max > 0 && count >= max {
+			return fmt.Errorf("more than %v entries were returned. Increase the %v limit if that's expected", max, maxListEntries.Name())
+		}
+		count++
+		var decodedEntry decodedExternalPluginEntry
+		if err := json.Unmarshal(line, &decodedEntry); err != nil {
+			return fmt.Errorf("could not decode entry %v (%q): %v. Each line should look like %v", count, line, err, streamingListLineFormat)
+		}
+		return onEntry(decodedEntry)
+	}
+}
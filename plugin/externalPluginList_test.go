@@ -0,0 +1,149 @@
+package plugin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginListTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ExternalPluginListTestSuite) decode(stdout string) ([]string, error) {
+	var names []string
+	err := decodeEntries(strings.NewReader(stdout), func(entry decodedExternalPluginEntry) error {
+		names = append(names, entry.Name)
+		return nil
+	})
+	return names, err
+}
+
+func (suite *ExternalPluginListTestSuite) TestDecodeEntries() {
+	names, err := suite.decode(`[{"name":"foo","methods":["list"]}, {"name":"bar","methods":["read"]}]`)
+	if suite.NoError(err) {
+		suite.Equal([]string{"foo", "bar"}, names)
+	}
+
+	_, err = suite.decode(`{"name":"foo"}`)
+	suite.Regexp("expected an array", err)
+
+	_, err = suite.decode(`[{"name":"foo"}`)
+	suite.Error(err)
+
+	_, err = suite.decode(`[{"name":"foo"}] [`)
+	suite.Regexp("unexpected data", err)
+}
+
+func (suite *ExternalPluginListTestSuite) TestDecodeEntriesPage() {
+	var names []string
+	decodePage := func(stdout string) (string, error) {
+		names = nil
+		return decodeEntriesPage(strings.NewReader(stdout), func(entry decodedExternalPluginEntry) error {
+			names = append(names, entry.Name)
+			return nil
+		})
+	}
+
+	nextPage, err := decodePage(`{"entries":[{"name":"foo"},{"name":"bar"}],"next_page":"abc"}`)
+	if suite.NoError(err) {
+		suite.Equal("abc", nextPage)
+		suite.Equal([]string{"foo", "bar"}, names)
+	}
+	// The keys can be in any order, and the last page doesn't have a next page
+	nextPage, err = decodePage(`{"next_page":"","entries":[{"name":"foo"}]}`)
+	if suite.NoError(err) {
+		suite.Equal("", nextPage)
+		suite.Equal([]string{"foo"}, names)
+	}
+	// Arrays are the last page
+	nextPage, err = decodePage(`[{"name":"foo"}]`)
+	if suite.NoError(err) {
+		suite.Equal("", nextPage)
+		suite.Equal([]string{"foo"}, names)
+	}
+
+	_, err = decodePage(`"foo"`)
+	suite.Regexp("expected an array or an object", err)
+	_, err = decodePage(`{"entries":{}}`)
+	suite.Regexp("expected entries to be an array", err)
+	_, err = decodePage(`{"next_page":1}`)
+	suite.Regexp("expected next_page to be a string", err)
+	_, err = decodePage(`{"foo":"bar"}`)
+	suite.Regexp("unexpected key foo", err)
+	_, err = decodePage(`{"entries":[]} [`)
+	suite.Regexp("unexpected data", err)
+}
+
+func (suite *ExternalPluginListTestSuite) TestDecodeEntries_EnforcesMaxEntries() {
+	_, err := limits.Set(maxListEntries.Name(), 2)
+	suite.NoError(err)
+	defer func() {
+		_, _ = limits.Set(maxListEntries.Name(), 100000)
+	}()
+
+	names, err := suite.decode(`[{"name":"foo"},{"name":"bar"}]`)
+	if suite.NoError(err) {
+		suite.Equal([]string{"foo", "bar"}, names)
+	}
+	names, err = suite.decode(`[{"name":"foo"},{"name":"bar"},{"name":"baz"}]`)
+	suite.Regexp("more than 2 entries.*plugins.max_list_entries", err)
+	// Entries past the limit aren't decoded
+	suite.Equal([]string{"foo", "bar"}, names)
+}
+
+func (suite *ExternalPluginListTestSuite) TestCappedWriter() {
+	var buf bytes.Buffer
+	w := &cappedWriter{w: &buf, max: 5}
+	n, err := w.Write([]byte("abc"))
+	suite.NoError(err)
+	suite.Equal(3, n)
+	suite.False(w.exceeded)
+
+	n, err = w.Write([]byte("defg"))
+	suite.NoError(err)
+	suite.Equal(4, n)
+	suite.True(w.exceeded)
+
+	n, err = w.Write([]byte("hij"))
+	suite.NoError(err)
+	suite.Equal(3, n)
+	suite.Equal("abcde", buf.String())
+
+	// 0 means unlimited
+	buf.Reset()
+	w = &cappedWriter{w: &buf}
+	_, err = w.Write([]byte("abcdefg"))
+	suite.NoError(err)
+	suite.False(w.exceeded)
+	suite.Equal("abcdefg", buf.String())
+}
+
+func (suite *ExternalPluginListTestSuite) TestCappedReader() {
+	r := &cappedReader{r: strings.NewReader("abcde"), max: 5}
+	data, err := ioutil.ReadAll(r)
+	suite.NoError(err)
+	suite.Equal("abcde", string(data))
+	suite.False(r.exceeded)
+
+	r = &cappedReader{r: strings.NewReader("abcdefg"), max: 5}
+	data, err = ioutil.ReadAll(r)
+	suite.Equal(errCappedReaderExceeded, err)
+	suite.Equal("abcde", string(data))
+	suite.True(r.exceeded)
+
+	// 0 means unlimited
+	r = &cappedReader{r: strings.NewReader("abcdefg")}
+	data, err = ioutil.ReadAll(r)
+	suite.NoError(err)
+	suite.Equal("abcdefg", string(data))
+	suite.False(r.exceeded)
+}
+
+func TestExternalPluginList(t *testing.T) {
+	suite.Run(t, new(ExternalPluginListTestSuite))
+}
@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// externalPluginMetaRoot represents a meta plugin's root. A meta plugin is a
+// directory of external plugin scripts. Each script is loaded as a nested
+// plugin root that's responsible for its own subtree, so Wash always invokes
+// the script that produced the entry. Nested roots are initialized separately,
+// which means that each one has its own state, cache TTLs and (optional) schema.
+type externalPluginMetaRoot struct {
+	EntryBase
+	dir         string
+	nestedRoots []Entry
+	requires    Requirements
+	env         ExternalPluginEnv
+	sandbox     ExternalPluginSandbox
+}
+
+func newExternalPluginMetaRoot(name string, dir string) *externalPluginMetaRoot {
+	r := &externalPluginMetaRoot{
+		EntryBase: NewEntry(name),
+		dir:       dir,
+	}
+	// The nested roots are loaded once, in Init, so there's nothing to cache.
+	r.DisableDefaultCaching()
+	return r
+}
+
+// Requirements returns the requirements from the meta plugin's spec. They
+// apply to all of its nested roots.
+func (r *externalPluginMetaRoot) Requirements() Requirements {
+	return r.requires
+}
+
+// Init loads and initializes each of the meta plugin's nested roots. A nested
+// root's config is the value of its name's key in cfg. Nested roots that fail
+// to load are skipped so that a single bad script doesn't take down the rest
+// of the meta plugin.
+func (r *externalPluginMetaRoot) Init(cfg map[string]interface{}) error {
+	files, err := ioutil.ReadDir(r.dir)
+	if err != nil {
+		return fmt.Errorf("could not read the meta plugin directory %v: %v", r.dir, err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	r.nestedRoots = nil
+	for _, fi := range files {
+		if !fi.Mode().IsRegular() || fi.Mode().Perm()&0100 == 0 {
+			continue
+		}
+		// The nested roots share the meta plugin's environment and sandbox.
+		// They're also passed its requirements so that its required
+		// environment variables aren't sanitized away.
+		spec := ExternalPluginSpec{
+			Script:   filepath.Join(r.dir, fi.Name()),
+			Requires: r.requires,
+			Env:      r.env,
+			Sandbox:  r.sandbox,
+			meta:     r.name(),
+		}
+		nestedRoot, err := spec.Load()
+		if err != nil {
+			log.Warnf("%v: %v failed to load: %v", r.name(), spec.Script, err)
+			continue
+		}
+		// Set the nested root's ID before initializing it so that any prefetched
+		// schema graph is namespaced under the meta plugin's name. CachedList sets
+		// the same ID when the meta root is listed.
+		nestedRoot.setID("/" + CName(r) + "/" + CName(nestedRoot))
+
+		var nestedCfg map[string]interface{}
+		if rawCfg, ok := cfg[spec.Name()]; ok {
+			if nestedCfg, ok = rawCfg.(map[string]interface{}); !ok {
+				log.Warnf("%v: expected a map for %v's config, not %v", r.name(), spec.Name(), rawCfg)
+				continue
+			}
+		}
+		if err := nestedRoot.Init(nestedCfg); err != nil {
+			log.Warnf("%v: %v failed to load: %+v", r.name(), spec.Script, err)
+			continue
+		}
+		r.nestedRoots = append(r.nestedRoots, nestedRoot)
+	}
+
+	if len(r.nestedRoots) == 0 {
+		return fmt.Errorf("the meta plugin directory %v does not contain any loadable plugin scripts", r.dir)
+	}
+	return nil
+}
+
+// ChildSchemas only makes sense for core plugin roots
+func (r *externalPluginMetaRoot) ChildSchemas() []*EntrySchema {
+	return nil
+}
+
+// Schema only makes sense for core plugin roots
+func (r *externalPluginMetaRoot) Schema() *EntrySchema {
+	return nil
+}
+
+// WrappedTypes only makes sense for core plugin roots
+func (r *externalPluginMetaRoot) WrappedTypes() SchemaMap {
+	return nil
+}
+
+// List returns the meta plugin's nested roots
+func (r *externalPluginMetaRoot) List(ctx context.Context) ([]Entry, error) {
+	return r.nestedRoots, nil
+}
@@ -0,0 +1,246 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ExternalPluginProtocolVersion is the version of the protocol that Wash uses
+// to invoke external plugin scripts. It's bumped whenever the protocol changes
+// in a way that isn't backwards compatible. Plugin scripts get it via the
+// WASH_PROTOCOL_VERSION environment variable.
+const ExternalPluginProtocolVersion = 1
+
+const protocolVersionEnvVar = "WASH_PROTOCOL_VERSION"
+
+// configEnvVar is the environment variable that passes the plugin's config
+// section from wash.yaml to the invocations after init, as JSON. init gets it
+// as its argument instead.
+const configEnvVar = "WASH_PLUGIN_CONFIG"
+
+// minExternalPluginProtocolVersion is the oldest version of the protocol that
+// Wash still speaks
+const minExternalPluginProtocolVersion = 1
+
+// negotiateProtocolVersion returns the version of the protocol that Wash and a
+// plugin script speak, given the protocol_version that the script returned
+// from init. Scripts that predate the handshake don't return one, so they're
+// assumed to speak version 1. Scripts that speak a newer version than Wash get
+// Wash's version, and should fall back to it.
+func negotiateProtocolVersion(declared int) (int, error) {
+	switch {
+	case declared == 0:
+		return 1, nil
+	case declared < minExternalPluginProtocolVersion:
+		return 0, fmt.Errorf(
+			"the plugin speaks version %v of the external plugin protocol, but Wash only speaks versions %v to %v",
+			declared,
+			minExternalPluginProtocolVersion,
+			ExternalPluginProtocolVersion,
+		)
+	case declared > ExternalPluginProtocolVersion:
+		return ExternalPluginProtocolVersion, nil
+	default:
+		return declared, nil
+	}
+}
+
+// externalPluginMethods are the methods that Wash can invoke on an external
+// plugin script
+var externalPluginMethods = []string{"init", "list", "read", "metadata", "stream", "exec", "write", "delete", "create", "rename", "signal", "schema", "watch", "attributes", "telemetry", "health"}
+
+type protocolEnvVar struct {
+	Name  string
+	Value string
+}
+
+// externalPluginProtocol describes the external plugin protocol. The Python
+// and Ruby helper libraries in plugin/external are generated from (and tested
+// against) it so that they can't drift from the types that Wash decodes.
+type externalPluginProtocol struct {
+	Version               int
+	Methods               []string
+	EntryKeys             []string
+	CacheTTLKeys          []string
+	AttributeKeys         []string
+	DeprecationKeys       []string
+	ValidatorsKeys        []string
+	ExecOptionsKeys       []string
+	ErrorKeys             []string
+	ErrorKinds            []string
+	ExecEventKeys         []string
+	ExecEventTypes        []string
+	InvalidationEventKeys []string
+	InvalidationActions   []string
+	Transports            []string
+	EnvVars               []protocolEnvVar
+}
+
+func newExternalPluginProtocol() externalPluginProtocol {
+	return externalPluginProtocol{
+		Version:               ExternalPluginProtocolVersion,
+		Methods:               externalPluginMethods,
+		EntryKeys:             jsonKeysOf(decodedExternalPluginEntry{}),
+		CacheTTLKeys:          jsonKeysOf(decodedCacheTTLs{}),
+		AttributeKeys:         attributeKeys(),
+		DeprecationKeys:       jsonKeysOf(ActionDeprecation{}),
+		ValidatorsKeys:        jsonKeysOf(decodedValidators{}),
+		ExecOptionsKeys:       jsonKeysOf(serializedExecOptions{}),
+		ErrorKeys:             jsonKeysOf(decodedExternalPluginError{}),
+		ErrorKinds:            externalPluginErrorKinds,
+		ExecEventKeys:         jsonKeysOf(decodedExecEvent{}),
+		ExecEventTypes:        externalPluginExecEventTypes,
+		InvalidationEventKeys: jsonKeysOf(decodedInvalidationEvent{}),
+		InvalidationActions:   externalPluginInvalidationActions,
+		Transports:            externalPluginTransports,
+		EnvVars: []protocolEnvVar{
+			{"PROTOCOL_VERSION_ENV_VAR", protocolVersionEnvVar},
+			{"CONFIG_ENV_VAR", configEnvVar},
+			{"WORKSPACE_ENV_VAR", WorkspaceEnvVar},
+			{"VALIDATORS_FILE_ENV_VAR", validatorsFileEnvVar},
+			{"ETAG_ENV_VAR", etagEnvVar},
+			{"LAST_MODIFIED_ENV_VAR", lastModifiedEnvVar},
+			{"STATE_FILE_ENV_VAR", stateFileEnvVar},
+		},
+	}
+}
+
+// jsonKeysOf returns the JSON keys of the struct v's fields, including the
+// fields of its embedded structs
+func jsonKeysOf(v interface{}) []string {
+	var keys []string
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if field.Anonymous && tag == "" {
+			keys = append(keys, jsonKeysOf(reflect.Zero(field.Type).Interface())...)
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+// attributeKeys returns the keys that EntryAttributes unmarshals
+func attributeKeys() []string {
+	var attr EntryAttributes
+	t := time.Now()
+	attr.
+		SetAtime(t).
+		SetMtime(t).
+		SetCtime(t).
+		SetCrtime(t).
+		SetMode(0).
+		SetSize(0).
+		SetOwner("owner").
+		SetGroup("group").
+		SetLifecycle(LifecycleRunning).
+		SetXattrs(map[string]string{"xattr": ""}).
+		SetContentType("text/plain").
+		SetMeta(JSONObject{})
+	var keys []string
+	for key := range attr.ToMap(true) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+const generatedHeader = "Code generated from the Wash external plugin protocol. DO NOT EDIT."
+
+var pythonProtocolTemplate = template.Must(template.New("python").Funcs(protocolFuncs).Parse(`# {{header}}
+# Run "go test ./plugin -update" to regenerate it.
+
+PROTOCOL_VERSION = {{.Version}}
+
+METHODS = {{list .Methods "(" ")"}}
+ENTRY_KEYS = {{list .EntryKeys "(" ")"}}
+CACHE_TTL_KEYS = {{list .CacheTTLKeys "(" ")"}}
+ATTRIBUTE_KEYS = {{list .AttributeKeys "(" ")"}}
+DEPRECATION_KEYS = {{list .DeprecationKeys "(" ")"}}
+VALIDATORS_KEYS = {{list .ValidatorsKeys "(" ")"}}
+EXEC_OPTIONS_KEYS = {{list .ExecOptionsKeys "(" ")"}}
+ERROR_KEYS = {{list .ErrorKeys "(" ")"}}
+ERROR_KINDS = {{list .ErrorKinds "(" ")"}}
+EXEC_EVENT_KEYS = {{list .ExecEventKeys "(" ")"}}
+EXEC_EVENT_TYPES = {{list .ExecEventTypes "(" ")"}}
+INVALIDATION_EVENT_KEYS = {{list .InvalidationEventKeys "(" ")"}}
+INVALIDATION_ACTIONS = {{list .InvalidationActions "(" ")"}}
+TRANSPORTS = {{list .Transports "(" ")"}}
+{{range .EnvVars}}
+{{.Name}} = {{quote .Value}}{{end}}
+`))
+
+var rubyProtocolTemplate = template.Must(template.New("ruby").Funcs(protocolFuncs).Parse(`# frozen_string_literal: true
+
+# {{header}}
+# Run "go test ./plugin -update" to regenerate it.
+
+module WashPlugin
+  module Protocol
+    VERSION = {{.Version}}
+
+    METHODS = {{list .Methods "[" "]"}}.freeze
+    ENTRY_KEYS = {{list .EntryKeys "[" "]"}}.freeze
+    CACHE_TTL_KEYS = {{list .CacheTTLKeys "[" "]"}}.freeze
+    ATTRIBUTE_KEYS = {{list .AttributeKeys "[" "]"}}.freeze
+    DEPRECATION_KEYS = {{list .DeprecationKeys "[" "]"}}.freeze
+    VALIDATORS_KEYS = {{list .ValidatorsKeys "[" "]"}}.freeze
+    EXEC_OPTIONS_KEYS = {{list .ExecOptionsKeys "[" "]"}}.freeze
+    ERROR_KEYS = {{list .ErrorKeys "[" "]"}}.freeze
+    ERROR_KINDS = {{list .ErrorKinds "[" "]"}}.freeze
+    EXEC_EVENT_KEYS = {{list .ExecEventKeys "[" "]"}}.freeze
+    EXEC_EVENT_TYPES = {{list .ExecEventTypes "[" "]"}}.freeze
+    INVALIDATION_EVENT_KEYS = {{list .InvalidationEventKeys "[" "]"}}.freeze
+    INVALIDATION_ACTIONS = {{list .InvalidationActions "[" "]"}}.freeze
+    TRANSPORTS = {{list .Transports "[" "]"}}.freeze
+{{range .EnvVars}}
+    {{.Name}} = {{quote .Value}}{{end}}
+  end
+end
+`))
+
+var protocolFuncs = template.FuncMap{
+	"header": func() string {
+		return generatedHeader
+	},
+	// Python and Ruby both accept JSON strings as string literals
+	"quote": func(s string) string {
+		bits, _ := json.Marshal(s)
+		return string(bits)
+	},
+	"list": func(items []string, open string, close string) string {
+		quoted := make([]string, len(items))
+		for i, item := range items {
+			bits, _ := json.Marshal(item)
+			quoted[i] = string(bits)
+		}
+		if open == "(" && len(items) == 1 {
+			// Single-element Python tuples need a trailing comma
+			quoted[0] += ","
+		}
+		return open + strings.Join(quoted, ", ") + close
+	},
+}
+
+func renderProtocol(tmpl *template.Template) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, newExternalPluginProtocol()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var updateProtocol = flag.Bool("update", false, "regenerate the external plugin libraries' protocol files")
+
+type ExternalPluginProtocolTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ExternalPluginProtocolTestSuite) TestJSONKeysOf() {
+	type embedded struct {
+		B string `json:"b,omitempty"`
+	}
+	type s struct {
+		A string `json:"a"`
+		embedded
+		C      string `json:"-"`
+		Nested embedded
+	}
+	suite.Equal([]string{"a", "b", "Nested"}, jsonKeysOf(s{}))
+}
+
+func (suite *ExternalPluginProtocolTestSuite) TestProtocol() {
+	protocol := newExternalPluginProtocol()
+	suite.Equal(
+		[]string{"type_id", "name", "methods", "deprecated_methods", "slash_replacer", "cache_ttls", "exec_options", "partial_reads", "streaming_list", "resumable_stream", "exec_events", "custom_actions", "symlink_target", "timeouts", "attributes", "state", "help", "version", "protocol_version", "transport", "state_spillover"},
+		protocol.EntryKeys,
+	)
+	suite.Equal([]string{"list", "read", "metadata"}, protocol.CacheTTLKeys)
+	suite.Equal([]string{"atime", "content_type", "crtime", "ctime", "group", "lifecycle", "meta", "mode", "mtime", "owner", "size", "xattrs"}, protocol.AttributeKeys)
+	suite.Equal([]string{"etag", "last_modified", "unchanged"}, protocol.ValidatorsKeys)
+	suite.Equal([]string{"tty", "elevate", "env", "cwd", "stdin"}, protocol.ExecOptionsKeys)
+	suite.Equal([]string{"kind", "message", "retryable"}, protocol.ErrorKeys)
+	suite.Equal([]string{"not_found", "permission_denied", "timeout", "unavailable", "unknown"}, protocol.ErrorKinds)
+	suite.Equal([]string{"type", "data", "encoding", "exit_code", "error"}, protocol.ExecEventKeys)
+	suite.Equal([]string{"stdout", "stderr", "exit", "error"}, protocol.ExecEventTypes)
+	suite.Equal([]string{"path", "action"}, protocol.InvalidationEventKeys)
+	suite.Equal([]string{"list", "read", "metadata"}, protocol.InvalidationActions)
+	suite.Equal([]string{"json", "msgpack", "cbor"}, protocol.Transports)
+}
+
+func (suite *ExternalPluginProtocolTestSuite) TestNegotiateProtocolVersion() {
+	for declared, expected := range map[int]int{
+		0:                                 1,
+		1:                                 1,
+		ExternalPluginProtocolVersion + 1: ExternalPluginProtocolVersion,
+	} {
+		version, err := negotiateProtocolVersion(declared)
+		if suite.NoError(err) {
+			suite.Equal(expected, version)
+		}
+	}
+
+	_, err := negotiateProtocolVersion(-1)
+	suite.Error(err)
+}
+
+// TestLibrariesAreUpToDate ensures that the helper libraries' protocol files
+// match the protocol that Wash implements
+func (suite *ExternalPluginProtocolTestSuite) TestLibrariesAreUpToDate() {
+	files := map[string]*template.Template{
+		filepath.Join("external", "python", "wash_protocol.py"): pythonProtocolTemplate,
+		filepath.Join("external", "ruby", "wash_protocol.rb"):   rubyProtocolTemplate,
+	}
+	for path, tmpl := range files {
+		expected, err := renderProtocol(tmpl)
+		if !suite.NoError(err) {
+			continue
+		}
+		if *updateProtocol {
+			suite.NoError(ioutil.WriteFile(path, expected, 0644))
+			continue
+		}
+		actual, err := ioutil.ReadFile(path)
+		if suite.NoError(err) {
+			suite.Equal(string(expected), string(actual), "%v is out of date. Run \"go test ./plugin -update\" to regenerate it.", path)
+		}
+	}
+}
+
+func TestExternalPluginProtocol(t *testing.T) {
+	suite.Run(t, new(ExternalPluginProtocolTestSuite))
+}
@@ -0,0 +1,93 @@
+package plugin
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+)
+
+var invocationRetries = limits.Register(
+	"plugins.invocation_retries",
+	"The maximum number of times that an external plugin's list, read, metadata, attributes, telemetry or schema invocation is retried when it fails with a retryable error. 0 disables retries.",
+	2,
+	nil,
+)
+
+var retryBackoff = limits.Register(
+	"plugins.retry_backoff_ms",
+	"How many milliseconds the first retry of a failed external plugin invocation waits (before jitter). Each later retry waits twice as long as the previous one, up to plugins.max_retry_backoff_ms.",
+	250,
+	nil,
+)
+
+var maxRetryBackoff = limits.Register(
+	"plugins.max_retry_backoff_ms",
+	"The maximum number of milliseconds that a retry of a failed external plugin invocation waits (before jitter). 0 means that the wait's unbounded.",
+	5000,
+	nil,
+)
+
+// retryJitter returns a random number in [0, 1). It's a variable so that the
+// tests can stub it.
+var retryJitter = rand.Float64
+
+// retryableMethods are the methods that are safe to retry because they don't
+// change anything
+var retryableMethods = map[string]bool{
+	"list":       true,
+	"read":       true,
+	"metadata":   true,
+	"schema":     true,
+	"attributes": true,
+	"telemetry":  true,
+}
+
+// retryDelay returns how long the given retry (starting from 0) waits. The
+// backoff doubles with each retry, and the delay's a random duration between
+// half the backoff and the backoff so that invocations that failed together
+// (e.g. because they were all rate-limited) aren't retried together.
+func retryDelay(retry int) time.Duration {
+	backoff := time.Duration(retryBackoff.Value()) * time.Millisecond
+	max := time.Duration(maxRetryBackoff.Value()) * time.Millisecond
+	for i := 0; i < retry && (max <= 0 || backoff < max); i++ {
+		backoff *= 2
+	}
+	if max > 0 && backoff > max {
+		backoff = max
+	}
+	return backoff/2 + time.Duration(retryJitter()*float64(backoff/2))
+}
+
+// invokeWithRetries returns invoke's result. If method's safe to retry, then
+// invoke's retried (with exponential backoff and jitter) while it fails with
+// a retryable error. Each retry is recorded in the activity journal.
+func invokeWithRetries(ctx context.Context, method string, invoke func() (invocation, error)) (invocation, error) {
+	inv, err := invoke()
+	if !retryableMethods[method] {
+		return inv, err
+	}
+	maxRetries := invocationRetries.Value()
+	retries := 0
+	for ; retries < maxRetries && IsRetryable(err); retries++ {
+		delay := retryDelay(retries)
+		activity.Record(ctx, "Retrying %v in %v (retry %v of %v) because it failed with a retryable error: %v", method, delay, retries+1, maxRetries, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			activity.Record(ctx, "Stopped retrying %v: %v", method, ctx.Err())
+			return inv, err
+		}
+		inv, err = invoke()
+	}
+	if retries > 0 {
+		if err == nil {
+			activity.Record(ctx, "%v succeeded after %v retries", method, retries)
+		} else {
+			activity.Record(ctx, "%v failed after %v retries: %v", method, retries, err)
+		}
+	}
+	return inv, err
+}
@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginRetriesTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ExternalPluginRetriesTestSuite) TearDownTest() {
+	retryJitter = rand.Float64
+	_, err := limits.Set(retryBackoff.Name(), 250)
+	suite.NoError(err)
+	_, err = limits.Set(maxRetryBackoff.Name(), 5000)
+	suite.NoError(err)
+}
+
+func (suite *ExternalPluginRetriesTestSuite) TestRetryDelay() {
+	retryJitter = func() float64 { return 0 }
+	suite.Equal(125*time.Millisecond, retryDelay(0))
+	suite.Equal(250*time.Millisecond, retryDelay(1))
+	suite.Equal(500*time.Millisecond, retryDelay(2))
+	// The backoff's capped by plugins.max_retry_backoff_ms
+	suite.Equal(2500*time.Millisecond, retryDelay(10))
+	_, err := limits.Set(maxRetryBackoff.Name(), 0)
+	suite.NoError(err)
+	suite.Equal(128*time.Second, retryDelay(10))
+
+	// The jitter adds up to half of the backoff
+	retryJitter = func() float64 { return 0.5 }
+	suite.Equal(375*time.Millisecond, retryDelay(1))
+}
+
+func (suite *ExternalPluginRetriesTestSuite) TestInvokeWithRetries() {
+	_, err := limits.Set(retryBackoff.Name(), 1)
+	suite.NoError(err)
+	retryableErr := &ExternalPluginError{Kind: ErrorKindUnavailable, Message: "rate-limited", Retryable: true}
+
+	// Retryable errors are retried until the invocation succeeds
+	calls := 0
+	_, err = invokeWithRetries(context.Background(), "list", func() (invocation, error) {
+		calls++
+		if calls < 2 {
+			return invocation{}, retryableErr
+		}
+		return invocation{}, nil
+	})
+	suite.NoError(err)
+	suite.Equal(2, calls)
+
+	// They're retried at most plugins.invocation_retries times
+	calls = 0
+	_, err = invokeWithRetries(context.Background(), "read", func() (invocation, error) {
+		calls++
+		return invocation{}, retryableErr
+	})
+	suite.Equal(retryableErr, err)
+	suite.Equal(1+invocationRetries.Value(), calls)
+
+	// Other errors and methods that change things aren't retried
+	calls = 0
+	_, err = invokeWithRetries(context.Background(), "list", func() (invocation, error) {
+		calls++
+		return invocation{}, errors.New("failed")
+	})
+	suite.EqualError(err, "failed")
+	suite.Equal(1, calls)
+	calls = 0
+	_, err = invokeWithRetries(context.Background(), "delete", func() (invocation, error) {
+		calls++
+		return invocation{}, retryableErr
+	})
+	suite.Equal(retryableErr, err)
+	suite.Equal(1, calls)
+}
+
+func TestExternalPluginRetries(t *testing.T) {
+	suite.Run(t, new(ExternalPluginRetriesTestSuite))
+}
@@ -13,6 +13,18 @@ import (
 // externalPluginRoot represents an external plugin's root.
 type externalPluginRoot struct {
 	*externalPluginEntry
+	requires Requirements
+	help     string
+}
+
+// Requirements returns the requirements from the plugin's spec
+func (r *externalPluginRoot) Requirements() Requirements {
+	return r.requires
+}
+
+// Help returns the help document from the plugin's init response
+func (r *externalPluginRoot) Help() string {
+	return r.help
 }
 
 // Init initializes the external plugin root
@@ -59,16 +71,38 @@ it's safe to omit name from the response to 'init'`, r.script.Path()))
 	if decodedRoot.Methods == nil {
 		decodedRoot.Methods = []interface{}{"list"}
 	}
+	if _, err := negotiateProtocolVersion(decodedRoot.ProtocolVersion); err != nil {
+		return err
+	}
+	if err := validateTransport(decodedRoot.Transport); err != nil {
+		return err
+	}
+	if isBinaryTransport(decodedRoot.Transport) && isDaemon(r.script) {
+		return fmt.Errorf("the %v transport isn't supported in daemon mode since the daemon's responses are JSON", decodedRoot.Transport)
+	}
+	decodedRoot.adaptTo(decodedRoot.ProtocolVersion)
 	entry, err := decodedRoot.toExternalPluginEntry(false, true)
 	if err != nil {
 		return err
 	}
+	if err := validateStateSize(decodedRoot.Name, entry.state, decodedRoot.StateSpillover); err != nil {
+		return err
+	}
 	if !ListAction().IsSupportedOn(entry) {
 		panic(fmt.Sprintf("plugin root for %s must implement 'list'", r.script.Path()))
 	}
 	script := r.script
+	// Preserve the ID in case it was set before Init was called (e.g. by a
+	// meta plugin root).
+	entry.setID(r.id())
 	r.externalPluginEntry = entry
 	r.externalPluginEntry.script = script
+	r.help = decodedRoot.Help
+	r.protocolVersion = decodedRoot.ProtocolVersion
+	r.version = decodedRoot.Version
+	r.transport = decodedRoot.Transport
+	r.config = string(cfgJSON)
+	r.stateSpillover = decodedRoot.StateSpillover
 
 	// Fill in the schema graph if provided
 	if rawSchema := r.methods["schema"]; rawSchema != nil {
@@ -1,8 +1,11 @@
 package plugin
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"regexp"
 	"testing"
 
@@ -18,7 +21,7 @@ type ExternalPluginRootTestSuite struct {
 
 func (suite *ExternalPluginRootTestSuite) TestInit() {
 	mockScript := &mockExternalPluginScript{path: "plugin_script"}
-	root := &externalPluginRoot{&externalPluginEntry{
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
 		EntryBase: NewEntry("foo"),
 		script:    mockScript,
 	}}
@@ -55,6 +58,7 @@ func (suite *ExternalPluginRootTestSuite) TestInit() {
 				methods:   map[string]interface{}{"list": nil},
 				script:    root.script,
 				rawTypeID: "foo_type",
+				config:    "{}",
 			},
 		}
 
@@ -64,7 +68,7 @@ func (suite *ExternalPluginRootTestSuite) TestInit() {
 
 func (suite *ExternalPluginRootTestSuite) TestInitWithConfig() {
 	mockScript := &mockExternalPluginScript{path: "plugin_script"}
-	root := &externalPluginRoot{&externalPluginEntry{
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
 		EntryBase: NewEntry("foo"),
 		script:    mockScript,
 	}}
@@ -79,9 +83,84 @@ func (suite *ExternalPluginRootTestSuite) TestInitWithConfig() {
 	suite.NoError(root.Init(map[string]interface{}{"key": []string{"value"}}))
 }
 
+func (suite *ExternalPluginRootTestSuite) TestInitPassesTheConfigToLaterInvocations() {
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
+		EntryBase: NewEntry("config"),
+		script:    newExternalPluginScript("config", "testdata/config.sh"),
+	}}
+	root.SetTestID("/config")
+	if !suite.NoError(root.Init(map[string]interface{}{"key": "value"})) {
+		return
+	}
+
+	ctx := context.Background()
+	entries, err := root.List(ctx)
+	if suite.NoError(err) && suite.Len(entries, 1) {
+		rdr, err := entries[0].(*externalPluginEntry).Open(ctx)
+		if suite.NoError(err) {
+			content, err := ioutil.ReadAll(io.NewSectionReader(rdr, 0, rdr.Size()))
+			suite.NoError(err)
+			suite.Equal(`{"key":"value"}`, string(content))
+		}
+	}
+}
+
+func (suite *ExternalPluginRootTestSuite) TestInitWithHelp() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		script:    mockScript,
+	}}
+
+	mockScript.OnInvokeAndWait(
+		mock.Anything,
+		"init",
+		nil,
+		"{}",
+	).Return(mockInvocation([]byte(`{"help":"Some help"}`)), nil).Once()
+
+	if suite.NoError(root.Init(nil)) {
+		suite.Equal("Some help", Help(root))
+	}
+}
+
+func (suite *ExternalPluginRootTestSuite) TestInitNegotiatesTheProtocolVersion() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		script:    mockScript,
+	}}
+	mockInvokeAndWait := func(stdout string) {
+		mockScript.OnInvokeAndWait(
+			mock.Anything,
+			"init",
+			nil,
+			"{}",
+		).Return(mockInvocation([]byte(stdout)), nil).Once()
+	}
+
+	// Scripts that predate the handshake speak version 1
+	mockInvokeAndWait("{}")
+	if suite.NoError(root.Init(nil)) {
+		suite.Equal(1, root.negotiatedProtocolVersion())
+	}
+
+	// Scripts that speak a newer version get Wash's version, and the things
+	// that Wash doesn't understand are dropped
+	mockInvokeAndWait(`{"protocol_version":1000,"methods":["list","exec"],"exec_options":["env","new_option"]}`)
+	if suite.NoError(root.Init(nil)) {
+		suite.Equal(1000, root.protocolVersion)
+		suite.Equal(ExternalPluginProtocolVersion, root.negotiatedProtocolVersion())
+		suite.Equal([]string{"env"}, root.execOptions)
+	}
+
+	mockInvokeAndWait(`{"protocol_version":-1}`)
+	suite.Regexp("speaks version -1 of the external plugin protocol, but Wash only speaks versions 1 to", root.Init(nil))
+}
+
 func (suite *ExternalPluginRootTestSuite) TestInitWithSchema_SetsSchemaKnownVariable() {
 	mockScript := &mockExternalPluginScript{path: "plugin_script"}
-	root := &externalPluginRoot{&externalPluginEntry{
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
 		EntryBase: NewEntry("foo"),
 		script:    mockScript,
 	}}
@@ -99,7 +178,7 @@ func (suite *ExternalPluginRootTestSuite) TestInitWithSchema_SetsSchemaKnownVari
 
 func (suite *ExternalPluginRootTestSuite) TestInitWithSchema_PrefetchedSchema_ReturnsErrorIfUnmarshallingSchemaFails() {
 	mockScript := &mockExternalPluginScript{path: "plugin_script"}
-	root := &externalPluginRoot{&externalPluginEntry{
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
 		EntryBase: NewEntry("foo"),
 		script:    mockScript,
 	}}
@@ -120,7 +199,7 @@ func (suite *ExternalPluginRootTestSuite) TestInitWithSchema_PrefetchedSchema_Re
 
 func (suite *ExternalPluginRootTestSuite) TestInitWithSchema_PrefetchedSchema_PartitionsSchemaGraph() {
 	mockScript := &mockExternalPluginScript{path: "plugin_script"}
-	root := &externalPluginRoot{&externalPluginEntry{
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
 		EntryBase: NewEntry("fooPlugin"),
 		script:    mockScript,
 	}}
@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/puppetlabs/wash/plugin/internal"
+)
+
+// ExternalPluginSandbox restricts the resources that an external plugin's
+// scripts can use so that a misbehaving plugin can't take down Wash or the
+// host. Zero limits are ignored. The scripts always run in their own process
+// group, so cancelling an invocation also kills the processes it started.
+type ExternalPluginSandbox struct {
+	// CPUSeconds limits the CPU time of each invocation (or of the whole
+	// daemon in daemon mode)
+	CPUSeconds int `mapstructure:"cpu_seconds"`
+	// MemoryMB limits the virtual memory of each invocation, in megabytes
+	MemoryMB int `mapstructure:"memory_mb"`
+	// OpenFiles limits the number of files that each invocation can open
+	OpenFiles int `mapstructure:"open_files"`
+	// NoNetwork runs each invocation without network access. It's only
+	// supported on Linux.
+	NoNetwork bool `mapstructure:"no_network"`
+}
+
+func (s ExternalPluginSandbox) isEmpty() bool {
+	return s == ExternalPluginSandbox{}
+}
+
+func (s ExternalPluginSandbox) validate() error {
+	for name, limit := range map[string]int{"cpu_seconds": s.CPUSeconds, "memory_mb": s.MemoryMB, "open_files": s.OpenFiles} {
+		if limit < 0 {
+			return fmt.Errorf("the sandbox's %v must be positive", name)
+		}
+	}
+	if s.NoNetwork && !internal.NetworkIsolationSupported {
+		return fmt.Errorf("the sandbox's no_network is only supported on Linux")
+	}
+	return nil
+}
+
+// apply runs the command in the sandbox
+func (s ExternalPluginSandbox) apply(command *internal.Command) {
+	command.SetRlimits(internal.Rlimits{
+		CPUSeconds: s.CPUSeconds,
+		MemoryMB:   s.MemoryMB,
+		OpenFiles:  s.OpenFiles,
+	})
+	if s.NoNetwork {
+		command.IsolateNetwork()
+	}
+}
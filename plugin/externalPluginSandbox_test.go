@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginSandboxTestSuite struct {
+	suite.Suite
+}
+
+// invoke returns the lines that testdata/sandbox.sh prints when it's read in
+// the sandbox
+func (suite *ExternalPluginSandboxTestSuite) invoke(sandbox ExternalPluginSandbox) ([]string, error) {
+	spec := ExternalPluginSpec{Script: "testdata/sandbox.sh", Sandbox: sandbox}
+	root, err := spec.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := root.Init(nil); err != nil {
+		return nil, err
+	}
+	entry := root.(*externalPluginRoot).externalPluginEntry
+	inv, err := entry.script.InvokeAndWait(context.Background(), "read", entry)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(inv.stdout.String()), "\n"), nil
+}
+
+func (suite *ExternalPluginSandboxTestSuite) TestValidate() {
+	suite.NoError(ExternalPluginSandbox{CPUSeconds: 10, MemoryMB: 512, OpenFiles: 64}.validate())
+	suite.EqualError(ExternalPluginSandbox{OpenFiles: -1}.validate(), "the sandbox's open_files must be positive")
+
+	spec := ExternalPluginSpec{File: "testdata/static/inventory.yaml", Sandbox: ExternalPluginSandbox{OpenFiles: 64}}
+	_, err := spec.Load()
+	suite.EqualError(err, "testdata/static/inventory.yaml: sandboxes are not supported for static plugins")
+
+	spec = ExternalPluginSpec{Dir: "testdata/meta", Sandbox: ExternalPluginSandbox{OpenFiles: 64}}
+	root, err := spec.Load()
+	if suite.NoError(err) {
+		suite.Equal(spec.Sandbox, root.(*externalPluginMetaRoot).sandbox)
+	}
+}
+
+func (suite *ExternalPluginSandboxTestSuite) TestRlimits() {
+	lines, err := suite.invoke(ExternalPluginSandbox{CPUSeconds: 30, OpenFiles: 64})
+	if suite.NoError(err) && suite.True(len(lines) >= 2) {
+		suite.Equal("64", lines[0])
+		suite.Equal("30", lines[1])
+	}
+}
+
+func (suite *ExternalPluginSandboxTestSuite) TestNoNetwork() {
+	if runtime.GOOS != "linux" {
+		_, err := suite.invoke(ExternalPluginSandbox{NoNetwork: true})
+		suite.EqualError(err, "testdata/sandbox.sh: the sandbox's no_network is only supported on Linux")
+		return
+	}
+	lines, err := suite.invoke(ExternalPluginSandbox{NoNetwork: true})
+	if err != nil {
+		// Some hosts don't allow unprivileged user namespaces
+		suite.T().Skipf("could not create a network namespace: %v", err)
+	}
+	if suite.True(len(lines) >= 2) {
+		// The namespace only has a loopback device
+		suite.Equal([]string{"lo"}, lines[2:])
+	}
+}
+
+func TestExternalPluginSandbox(t *testing.T) {
+	suite.Run(t, new(ExternalPluginSandboxTestSuite))
+}
@@ -1,13 +1,18 @@
 package plugin
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"strings"
 
 	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
 	"github.com/puppetlabs/wash/plugin/internal"
 )
 
@@ -15,14 +20,35 @@ import (
 type externalPluginScript interface {
 	Path() string
 	InvokeAndWait(ctx context.Context, method string, entry *externalPluginEntry, args ...string) (invocation, error)
+	InvokeAndWaitWithStdin(ctx context.Context, method string, entry *externalPluginEntry, stdin io.Reader, args ...string) (invocation, error)
 	NewInvocation(ctx context.Context, method string, entry *externalPluginEntry, args ...string) invocation
 }
 
+// outputStreamer is implemented by the scripts that can pass a method's
+// stdout along as it's printed, which is how streaming lists are streamed.
+// Daemons can't since their responses are only sent once they're complete.
+type outputStreamer interface {
+	InvokeAndStream(ctx context.Context, method string, entry *externalPluginEntry, onLine func([]byte) error) (invocation, error)
+}
+
+// outputDecoder is implemented by the scripts that can pass a method's stdout
+// along to a decoder as it's printed, which is how list results are decoded
+// without buffering them. Daemons can't since their responses are only sent
+// once they're complete.
+type outputDecoder interface {
+	InvokeAndDecode(ctx context.Context, method string, entry *externalPluginEntry, decode func(io.Reader), args ...string) (invocation, error)
+}
+
 type invocation struct {
 	command        *internal.Command
 	stdout, stderr bytes.Buffer
+	// stdoutTruncated is true if stdout exceeded its maximum size, in which
+	// case the rest of it was discarded
+	stdoutTruncated bool
 }
 
+// newInvokeError returns the error of a failed invocation. It's an
+// ExternalPluginError if the script reported a structured error on stderr.
 func newInvokeError(msg string, inv invocation) error {
 	var builder strings.Builder
 	builder.WriteString(msg)
@@ -33,11 +59,88 @@ func newInvokeError(msg string, inv invocation) error {
 	if output := strings.Trim(inv.stderr.String(), "\n"); len(output) > 0 {
 		fmt.Fprintf(&builder, "\nSTDERR:\n%s", output)
 	}
+	if decoded, ok := decodeExternalPluginError(inv.stderr.String()); ok {
+		return decoded.toError(builder.String())
+	}
 	return errors.New(builder.String())
 }
 
+// limitedMethods are the methods that have their own concurrency limit in
+// addition to the plugin's, e.g. so that a burst of FUSE reads can't use up
+// all of a slow plugin's invocations
+var limitedMethods = []string{"list", "read", "metadata", "write", "delete", "signal"}
+
+// defaultMaxInvocations is the default limit of an external plugin's
+// concurrent invocations
+const defaultMaxInvocations = 10
+
 type externalPluginScriptImpl struct {
+	name string
 	path string
+	// invocations limits the number of concurrent InvokeAndWait calls. It is
+	// optional.
+	invocations *limits.Semaphore
+	// methodInvocations limits the number of concurrent InvokeAndWait calls
+	// of each of the limitedMethods. It is optional.
+	methodInvocations map[string]*limits.Semaphore
+	// env builds the environment that the script's invoked with. If it's nil,
+	// then the script inherits Wash's environment.
+	env *externalPluginEnv
+	// sandbox restricts the resources that the script can use
+	sandbox ExternalPluginSandbox
+}
+
+func newExternalPluginScript(name string, path string) externalPluginScriptImpl {
+	return newNamespacedExternalPluginScript(name, name, path)
+}
+
+// newNamespacedExternalPluginScript is newExternalPluginScript for a plugin
+// whose concurrency limits are named plugins.<limitsName>.*. limitsName is
+// the plugin's full path (see ExternalPluginSpec.limitsName), so that the
+// limits of a meta plugin's nested roots don't collide with other plugins'.
+func newNamespacedExternalPluginScript(name string, limitsName string, path string) externalPluginScriptImpl {
+	s := externalPluginScriptImpl{
+		name: name,
+		path: path,
+		invocations: limits.NewSemaphore(
+			"plugins."+limitsName+".max_invocations",
+			fmt.Sprintf("The maximum number of concurrent invocations of the %v plugin's script (excluding stream and exec). 0 means unlimited.", limitsName),
+			defaultMaxInvocations,
+		),
+		methodInvocations: make(map[string]*limits.Semaphore, len(limitedMethods)),
+	}
+	for _, method := range limitedMethods {
+		s.methodInvocations[method] = limits.NewSemaphore(
+			"plugins."+limitsName+".max_"+method+"_invocations",
+			fmt.Sprintf("The maximum number of concurrent %v invocations of the %v plugin's script. They also count towards plugins.%v.max_invocations. 0 means unlimited.", method, limitsName, limitsName),
+			0,
+		)
+	}
+	return s
+}
+
+// acquire waits for the method's invocation to be allowed by the script's
+// concurrency limits. The method's semaphore is acquired before the plugin's
+// so that the invocations that are queued behind a saturated method don't
+// hold up the plugin's other methods. The returned function releases them.
+func (s externalPluginScriptImpl) acquire(ctx context.Context, method string) (func(), error) {
+	var acquired []*limits.Semaphore
+	release := func() {
+		for i := len(acquired) - 1; i >= 0; i-- {
+			acquired[i].Release()
+		}
+	}
+	for _, semaphore := range []*limits.Semaphore{s.methodInvocations[method], s.invocations} {
+		if semaphore == nil {
+			continue
+		}
+		if err := semaphore.Acquire(ctx); err != nil {
+			release()
+			return nil, err
+		}
+		acquired = append(acquired, semaphore)
+	}
+	return release, nil
 }
 
 func (s externalPluginScriptImpl) Path() string {
@@ -51,12 +154,51 @@ func (s externalPluginScriptImpl) InvokeAndWait(
 	method string,
 	entry *externalPluginEntry,
 	args ...string,
+) (invocation, error) {
+	return s.InvokeAndWaitWithStdin(ctx, method, entry, nil, args...)
+}
+
+// InvokeAndWaitWithStdin is InvokeAndWait, except that stdin is passed-in
+// as the script's stdin. If stdin is nil, then the script reads from the null
+// device. Invocations of methods that don't change anything (e.g. list) are
+// retried if they fail with a retryable error.
+func (s externalPluginScriptImpl) InvokeAndWaitWithStdin(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	stdin io.Reader,
+	args ...string,
+) (invocation, error) {
+	return invokeWithRetries(ctx, method, func() (invocation, error) {
+		return s.invokeAndWaitOnce(ctx, method, entry, stdin, args...)
+	})
+}
+
+func (s externalPluginScriptImpl) invokeAndWaitOnce(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	stdin io.Reader,
+	args ...string,
 ) (invocation, error) {
 	inv := s.NewInvocation(ctx, method, entry, args...)
-	inv.command.SetStdout(&inv.stdout)
+	if stdin != nil {
+		inv.command.SetStdin(stdin)
+	}
+	release, err := s.acquire(ctx, method)
+	if err != nil {
+		return inv, err
+	}
+	defer release()
+	stdout := &cappedWriter{w: &inv.stdout}
+	if method == "list" {
+		stdout.max = maxListOutputBytes()
+	}
+	inv.command.SetStdout(stdout)
 	inv.command.SetStderr(&inv.stderr)
 	activity.Record(ctx, "Invoking %v", inv.command)
-	err := inv.command.Run()
+	err = inv.command.Run()
+	inv.stdoutTruncated = stdout.exceeded
 	exitCode := inv.command.ProcessState().ExitCode()
 	if exitCode < 0 {
 		return inv, newInvokeError(err.Error(), inv)
@@ -72,22 +214,232 @@ func (s externalPluginScriptImpl) InvokeAndWait(
 	return inv, nil
 }
 
+// stdoutExcerptSize is how much of a decoded stdout is kept for the errors and
+// the activity log
+const stdoutExcerptSize = 64 * 1024
+
+// InvokeAndDecode is InvokeAndWait, except that stdout is passed to decode as
+// it's printed instead of being buffered. Only its first stdoutExcerptSize bytes
+// are kept in the invocation's stdout. decode's result is left to the caller
+// since it's only meaningful if the script succeeded. Like InvokeAndWait, the
+// invocations of methods that don't change anything are retried, in which case
+// decode's called again with the retried invocation's stdout.
+func (s externalPluginScriptImpl) InvokeAndDecode(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	decode func(io.Reader),
+	args ...string,
+) (invocation, error) {
+	return invokeWithRetries(ctx, method, func() (invocation, error) {
+		return s.invokeAndDecodeOnce(ctx, method, entry, decode, args...)
+	})
+}
+
+func (s externalPluginScriptImpl) invokeAndDecodeOnce(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	decode func(io.Reader),
+	args ...string,
+) (invocation, error) {
+	inv := s.NewInvocation(ctx, method, entry, args...)
+	release, err := s.acquire(ctx, method)
+	if err != nil {
+		return inv, err
+	}
+	defer release()
+	stdoutR, err := inv.command.StdoutPipe()
+	if err != nil {
+		return inv, err
+	}
+	inv.command.SetStderr(&inv.stderr)
+	activity.Record(ctx, "Invoking %v", inv.command)
+	if err := inv.command.Start(); err != nil {
+		return inv, newInvokeError(err.Error(), inv)
+	}
+
+	stdout := &cappedReader{r: io.TeeReader(stdoutR, &cappedWriter{w: &inv.stdout, max: stdoutExcerptSize})}
+	if method == "list" {
+		stdout.max = maxListOutputBytes()
+	}
+	decode(stdout)
+	// Drain stdout so that the script isn't blocked on a full pipe if decode
+	// stopped early
+	_, _ = io.Copy(ioutil.Discard, stdoutR)
+	inv.stdoutTruncated = stdout.exceeded
+	waitErr := inv.command.Wait()
+
+	activity.Record(ctx, "stdout: %v", inv.stdout.String())
+	if inv.stderr.Len() != 0 {
+		activity.Record(ctx, "stderr: %v", inv.stderr.String())
+	}
+	exitCode := inv.command.ProcessState().ExitCode()
+	if exitCode < 0 {
+		return inv, newInvokeError(waitErr.Error(), inv)
+	}
+	if exitCode != 0 {
+		return inv, newInvokeError(fmt.Sprintf("script returned a non-zero exit code of %v", exitCode), inv)
+	}
+	return inv, nil
+}
+
+// decodeBuffered passes a buffered invocation's stdout to decode if the
+// invocation succeeded. It's for the scripts that don't implement
+// outputDecoder.
+func decodeBuffered(inv invocation, err error, decode func(io.Reader)) (invocation, error) {
+	if err == nil && !inv.stdoutTruncated {
+		decode(bytes.NewReader(inv.stdout.Bytes()))
+	}
+	return inv, err
+}
+
+// InvokeAndStream is InvokeAndWait, except that each line of stdout is passed
+// to onLine as soon as it's printed instead of being buffered. If onLine
+// errors, then the script's terminated and InvokeAndStream returns onLine's
+// error as-is. Streamed invocations aren't retried since onLine may have
+// already seen some of the output.
+func (s externalPluginScriptImpl) InvokeAndStream(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	onLine func([]byte) error,
+) (invocation, error) {
+	inv := s.NewInvocation(ctx, method, entry)
+	release, err := s.acquire(ctx, method)
+	if err != nil {
+		return inv, err
+	}
+	defer release()
+	stdoutR, err := inv.command.StdoutPipe()
+	if err != nil {
+		return inv, err
+	}
+	inv.command.SetStderr(&inv.stderr)
+	activity.Record(ctx, "Invoking %v", inv.command)
+	if err := inv.command.Start(); err != nil {
+		return inv, newInvokeError(err.Error(), inv)
+	}
+
+	var max int64
+	if method == "list" {
+		max = maxListOutputBytes()
+	}
+	scanner := bufio.NewScanner(stdoutR)
+	if max > 0 {
+		scanner.Buffer(nil, int(max))
+	} else {
+		scanner.Buffer(nil, math.MaxInt32)
+	}
+	var lineErr error
+	var read int64
+	for scanner.Scan() {
+		read += int64(len(scanner.Bytes())) + 1
+		if max > 0 && read > max {
+			lineErr = newInvokeError(fmt.Sprintf(
+				"the entries exceeded %v MB. Increase the %v limit if that's expected",
+				maxListOutputSize.Value(),
+				maxListOutputSize.Name(),
+			), inv)
+			break
+		}
+		if lineErr = onLine(scanner.Bytes()); lineErr != nil {
+			break
+		}
+	}
+	if lineErr == nil && scanner.Err() != nil {
+		lineErr = newInvokeError(fmt.Sprintf("could not read stdout: %v", scanner.Err()), inv)
+	}
+	if lineErr != nil {
+		inv.command.Terminate()
+		// Drain stdout so that the script isn't blocked on a full pipe
+		_, _ = io.Copy(ioutil.Discard, stdoutR)
+	}
+	waitErr := inv.command.Wait()
+
+	if inv.stderr.Len() != 0 {
+		activity.Record(ctx, "stderr: %v", inv.stderr.String())
+	}
+	if lineErr != nil {
+		return inv, lineErr
+	}
+	exitCode := inv.command.ProcessState().ExitCode()
+	if exitCode < 0 {
+		return inv, newInvokeError(waitErr.Error(), inv)
+	}
+	if exitCode != 0 {
+		return inv, newInvokeError(fmt.Sprintf("script returned a non-zero exit code of %v", exitCode), inv)
+	}
+	return inv, nil
+}
+
 func (s externalPluginScriptImpl) NewInvocation(
 	ctx context.Context,
 	method string,
 	entry *externalPluginEntry,
 	args ...string,
 ) invocation {
+	var command *internal.Command
+	// init's where the version's negotiated, so it gets the newest version
+	// that Wash speaks
+	protocolVersion := ExternalPluginProtocolVersion
+	var config, stateFile string
 	if method == "init" {
-		return invocation{command: internal.NewCommand(ctx, s.Path(), append([]string{"init"}, args...)...)}
+		command = internal.NewCommand(ctx, s.Path(), append([]string{"init"}, args...)...)
+	} else {
+		if entry == nil {
+			msg := fmt.Sprintf("s.NewInvocation called with method '%v' and entry == nil", method)
+			panic(msg)
+		}
+		state := entry.state
+		if len(state) > maxInlineStateSize {
+			// The state's too large for an argument. validateStateSize
+			// ensures that the script reads it from WASH_STATE_FILE.
+			if path, err := spillState(state); err != nil {
+				activity.Warnf(ctx, "%v", err)
+			} else {
+				state, stateFile = "", path
+			}
+		}
+		command = internal.NewCommand(
+			ctx,
+			s.Path(),
+			append([]string{method, entry.id(), state}, args...)...,
+		)
+		protocolVersion = entry.negotiatedProtocolVersion()
+		config = entry.config
+	}
+
+	env := append(
+		[]string{fmt.Sprintf("%v=%v", protocolVersionEnvVar, protocolVersion)},
+		validatorsEnv(ctx)...,
+	)
+	if config != "" {
+		env = append(env, configEnvVar+"="+config)
+	}
+	if stateFile != "" {
+		env = append(env, stateFileEnvVar+"="+stateFile)
+	}
+	if apiSocket != "" {
+		env = append(env, apiSocketEnvVar+"="+apiSocket)
+		if s.name != "" {
+			if token, err := CredentialsTokenFor(s.name); err != nil {
+				activity.Warnf(ctx, "%v", err)
+			} else {
+				env = append(env, credentialsTokenEnvVar+"="+token)
+			}
+		}
 	}
-	if entry == nil {
-		msg := fmt.Sprintf("s.NewInvocation called with method '%v' and entry == nil", method)
-		panic(msg)
+	if s.name != "" {
+		// Failing to create the workspace shouldn't fail the invocation. The
+		// script will notice that it's missing if it needs it.
+		if workspace, err := Workspace(s.name); err != nil {
+			activity.Warnf(ctx, "%v", err)
+		} else {
+			env = append(env, WorkspaceEnvVar+"="+workspace)
+		}
 	}
-	return invocation{command: internal.NewCommand(
-		ctx,
-		s.Path(),
-		append([]string{method, entry.id(), entry.state}, args...)...,
-	)}
+	command.SetEnv(append(s.env.environ(ctx), env...))
+	s.sandbox.apply(command)
+	return invocation{command: command}
 }
@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginScriptTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ExternalPluginScriptTestSuite) TestNewExternalPluginScript_RegistersLimits() {
+	newExternalPluginScript("limits_tests", "testdata/limits.sh")
+	l, ok := limits.Get("plugins.limits_tests.max_invocations")
+	if suite.True(ok) {
+		suite.Equal(defaultMaxInvocations, l.Value())
+	}
+	for _, method := range limitedMethods {
+		l, ok := limits.Get("plugins.limits_tests.max_" + method + "_invocations")
+		if suite.True(ok, method) {
+			suite.Equal(0, l.Value())
+		}
+	}
+}
+
+func (suite *ExternalPluginScriptTestSuite) TestAcquire() {
+	s := newExternalPluginScript("acquire_tests", "testdata/acquire.sh")
+	_, err := limits.Set("plugins.acquire_tests.max_read_invocations", 1)
+	suite.NoError(err)
+	_, err = limits.Set("plugins.acquire_tests.max_invocations", 2)
+	suite.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	releaseRead, err := s.acquire(ctx, "read")
+	if !suite.NoError(err) {
+		return
+	}
+	// The read limit's saturated, but list isn't limited by it
+	_, err = s.acquire(ctx, "read")
+	suite.Equal(context.DeadlineExceeded, err)
+	releaseList, err := s.acquire(context.Background(), "list")
+	if !suite.NoError(err) {
+		return
+	}
+	// The plugin's limit is saturated
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = s.acquire(ctx, "metadata")
+	suite.Equal(context.DeadlineExceeded, err)
+	suite.Equal(0, s.methodInvocations["metadata"].Stats().InUse)
+
+	releaseRead()
+	releaseList()
+	suite.Equal(0, s.invocations.Stats().InUse)
+	release, err := s.acquire(context.Background(), "read")
+	if suite.NoError(err) {
+		release()
+	}
+}
+
+func (suite *ExternalPluginScriptTestSuite) TestInvokeAndDecode() {
+	s := newExternalPluginScript("", "testdata/pagedList.sh")
+	entry := &externalPluginEntry{EntryBase: NewEntry("paged")}
+	entry.SetTestID("/paged")
+	var decoded []byte
+	decode := func(stdout io.Reader) {
+		data, err := ioutil.ReadAll(stdout)
+		suite.NoError(err)
+		decoded = data
+	}
+	expected := `{"entries":[{"name":"b","methods":["read"]},{"name":"c","methods":["read"]}],"next_page":"p3"}` + "\n"
+
+	inv, err := s.InvokeAndDecode(context.Background(), "list", entry, decode, "p2")
+	if suite.NoError(err) {
+		suite.Equal(expected, string(decoded))
+		suite.Equal(expected, inv.stdout.String())
+		suite.False(inv.stdoutTruncated)
+	}
+
+	// The script's errors take precedence over the decoded output
+	_, err = s.InvokeAndDecode(context.Background(), "list", entry, decode, "unknown")
+	suite.Regexp("unknown page unknown", err)
+}
+
+func TestExternalPluginScript(t *testing.T) {
+	suite.Run(t, new(ExternalPluginScriptTestSuite))
+}
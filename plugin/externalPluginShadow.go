@@ -0,0 +1,185 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+)
+
+// shadowedMethods are the methods whose invocations are also sent to the
+// shadow. They're the methods that don't change anything, so sending them
+// twice is safe.
+var shadowedMethods = map[string]bool{
+	"init":       true,
+	"list":       true,
+	"read":       true,
+	"metadata":   true,
+	"schema":     true,
+	"attributes": true,
+	"telemetry":  true,
+}
+
+// shadowInvocationTimeout is how long the shadow has to respond to an
+// invocation
+const shadowInvocationTimeout = 30 * time.Second
+
+// shadowedScript is the script of an external plugin whose new version is
+// registered as a shadow (see ExternalPluginSpec). Invocations are served by
+// the active script. Invocations of the shadowed methods are also sent to the
+// shadow, and divergences between their responses are reported in the
+// invocation's journal. That way, plugin authors can validate an upgrade
+// against real traffic before switching to it.
+//
+// The shadow's passed the same entries (and states) as the active script.
+// Except for init, the shadow's invoked in the background so that it can't
+// slow down (or fail) the invocation.
+type shadowedScript struct {
+	externalPluginScript
+	shadow externalPluginScript
+	// pending tracks the shadow's in-flight invocations
+	pending sync.WaitGroup
+}
+
+func newShadowedScript(name string, limitsName string, active externalPluginScript, shadowPath string, env *externalPluginEnv, sandbox ExternalPluginSandbox) *shadowedScript {
+	shadow := externalPluginScriptImpl{
+		name:    name,
+		path:    shadowPath,
+		env:     env,
+		sandbox: sandbox,
+		invocations: limits.NewSemaphore(
+			"plugins."+limitsName+".max_shadow_invocations",
+			fmt.Sprintf("The maximum number of concurrent invocations of the %v plugin's shadow script. 0 means unlimited.", limitsName),
+			5,
+		),
+	}
+	return &shadowedScript{externalPluginScript: active, shadow: shadow}
+}
+
+// InvokeAndWait invokes method on the active script, then sends the same
+// invocation to the shadow if method's shadowed
+func (s *shadowedScript) InvokeAndWait(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	args ...string,
+) (invocation, error) {
+	inv, err := s.externalPluginScript.InvokeAndWait(ctx, method, entry, args...)
+	s.shadowInvocation(ctx, method, entry, args, inv, err)
+	return inv, err
+}
+
+// InvokeAndWaitWithStdin is InvokeAndWait, except that stdin is passed-in as
+// the script's stdin. Invocations with stdin aren't sent to the shadow since
+// stdin can only be read once.
+func (s *shadowedScript) InvokeAndWaitWithStdin(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	stdin io.Reader,
+	args ...string,
+) (invocation, error) {
+	inv, err := s.externalPluginScript.InvokeAndWaitWithStdin(ctx, method, entry, stdin, args...)
+	if stdin == nil {
+		s.shadowInvocation(ctx, method, entry, args, inv, err)
+	}
+	return inv, err
+}
+
+func (s *shadowedScript) shadowInvocation(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	args []string,
+	inv invocation,
+	err error,
+) {
+	if !shadowedMethods[method] {
+		return
+	}
+	// The caller owns inv, so copy its output
+	output := append([]byte(nil), inv.stdout.Bytes()...)
+	if _, revalidated := PreviousValidators(ctx); revalidated && err == nil && len(output) == 0 {
+		// The active script (likely) reported that the previous result's
+		// unchanged, so there's nothing to compare the shadow's response with
+		return
+	}
+	// The shadow's invocation shouldn't be cancelled with the request, nor
+	// should it see (or overwrite) the request's validators. It's still
+	// recorded in the request's journal.
+	shadowCtx := context.Background()
+	if journal, ok := ctx.Value(activity.JournalKey).(activity.Journal); ok {
+		shadowCtx = context.WithValue(shadowCtx, activity.JournalKey, journal)
+	}
+	compare := func() {
+		shadowCtx, cancel := context.WithTimeout(shadowCtx, shadowInvocationTimeout)
+		defer cancel()
+		shadowInv, shadowErr := s.shadow.InvokeAndWait(shadowCtx, method, entry, args...)
+		path := "the plugin root"
+		if entry != nil {
+			path = entry.id()
+		}
+		if divergence := shadowDivergence(method, output, err, shadowInv.stdout.Bytes(), shadowErr); divergence != "" {
+			activity.Warnf(shadowCtx, "Shadow: %v on %v diverged: %v", method, path, divergence)
+		} else {
+			activity.Record(shadowCtx, "Shadow: %v on %v matched", method, path)
+		}
+	}
+	// The shadow has to be initialized before it can serve other invocations
+	if method == "init" {
+		compare()
+		return
+	}
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		compare()
+	}()
+}
+
+// maxDivergenceOutput is the maximum number of bytes of each response that's
+// included in a divergence
+const maxDivergenceOutput = 512
+
+// shadowDivergence describes how the shadow's response to method (its output
+// and error) diverged from the active script's response. It returns an empty string if they match.
+// JSON responses match if they decode to the same value, so e.g. whitespace
+// and key order don't matter.
+func shadowDivergence(method string, active []byte, err error, shadow []byte, shadowErr error) string {
+	switch {
+	case err != nil && shadowErr != nil:
+		// Both failed, which is consistent enough
+		return ""
+	case err != nil:
+		return fmt.Sprintf("the active version failed with %v, but the shadow succeeded", err)
+	case shadowErr != nil:
+		return fmt.Sprintf("the active version succeeded, but the shadow failed with %v", shadowErr)
+	}
+
+	if bytes.Equal(active, shadow) {
+		return ""
+	}
+	if method != "read" {
+		var activeValue, shadowValue interface{}
+		if json.Unmarshal(active, &activeValue) == nil && json.Unmarshal(shadow, &shadowValue) == nil {
+			if reflect.DeepEqual(activeValue, shadowValue) {
+				return ""
+			}
+		}
+	}
+	return fmt.Sprintf("the active version returned %q, but the shadow returned %q", truncateOutput(active), truncateOutput(shadow))
+}
+
+func truncateOutput(output []byte) string {
+	if len(output) <= maxDivergenceOutput {
+		return string(output)
+	}
+	return string(output[:maxDivergenceOutput]) + "..."
+}
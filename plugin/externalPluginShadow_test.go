@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginShadowTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ExternalPluginShadowTestSuite) TestInvokeAndWait() {
+	active := &mockExternalPluginScript{path: "active"}
+	shadow := &mockExternalPluginScript{path: "shadow"}
+	script := &shadowedScript{externalPluginScript: active, shadow: shadow}
+	entry := &externalPluginEntry{EntryBase: NewEntry("foo"), script: script}
+	entry.SetTestID("/foo")
+
+	ctx := context.Background()
+	active.OnInvokeAndWait(ctx, "list", entry).Return(mockInvocation([]byte(`[{"name":"bar"}]`)), nil).Once()
+	shadow.OnInvokeAndWait(mock.Anything, "list", entry).Return(mockInvocation([]byte(`[{"name":"baz"}]`)), nil).Once()
+	inv, err := script.InvokeAndWait(ctx, "list", entry)
+	if suite.NoError(err) {
+		suite.Equal(`[{"name":"bar"}]`, inv.stdout.String())
+	}
+	script.pending.Wait()
+
+	// Methods that change things aren't sent to the shadow
+	active.OnInvokeAndWait(ctx, "delete", entry).Return(mockInvocation([]byte{}), nil).Once()
+	_, err = script.InvokeAndWait(ctx, "delete", entry)
+	suite.NoError(err)
+	script.pending.Wait()
+
+	active.AssertExpectations(suite.T())
+	shadow.AssertExpectations(suite.T())
+}
+
+func (suite *ExternalPluginShadowTestSuite) TestShadowDivergence() {
+	suite.Equal("", shadowDivergence("read", []byte("foo"), nil, []byte("foo"), nil))
+	suite.Equal("", shadowDivergence("list", []byte(`[{"name":"foo","methods":["read"]}]`), nil, []byte(`[ {"methods": ["read"], "name": "foo"} ]`), nil))
+	suite.Equal("", shadowDivergence("list", nil, fmt.Errorf("failed"), nil, fmt.Errorf("also failed")))
+
+	suite.Equal(
+		`the active version returned "{\"foo\":1}", but the shadow returned "{\"foo\":2}"`,
+		shadowDivergence("metadata", []byte(`{"foo":1}`), nil, []byte(`{"foo":2}`), nil),
+	)
+	// read's output isn't decoded
+	suite.Regexp("the active version returned", shadowDivergence("read", []byte(`{"foo":1}`), nil, []byte(`{ "foo": 1 }`), nil))
+	suite.Equal(
+		"the active version succeeded, but the shadow failed with failed",
+		shadowDivergence("list", []byte("[]"), nil, nil, fmt.Errorf("failed")),
+	)
+	suite.Equal(
+		"the active version failed with failed, but the shadow succeeded",
+		shadowDivergence("list", nil, fmt.Errorf("failed"), []byte("[]"), nil),
+	)
+}
+
+func TestExternalPluginShadow(t *testing.T) {
+	suite.Run(t, new(ExternalPluginShadowTestSuite))
+}
@@ -7,31 +7,173 @@ import (
 	"strings"
 )
 
-// ExternalPluginSpec represents an external plugin's specification.
+// ExternalPluginSpec represents an external plugin's specification. Script
+// is the path to the plugin script. Dir is the path to a meta plugin directory,
+// which is a directory of plugin scripts that are each loaded as a nested plugin
+// root. File is the path to a static plugin, which is a YAML or JSON file that
+// describes the plugin's entire tree. Only one of Script, Dir or File should be
+// specified. Requires are the plugin's requirements, which are checked before
+// it's loaded (see Requirements). Daemon starts the script once and keeps it
+// running instead of invoking it once per method (see externalPluginDaemon).
+// It's only supported for scripts. Shadow is the path to a new version of the
+// plugin script that's sent the same requests as Script so that their responses
+// can be compared (see shadowedScript). It's also only supported for scripts.
+// Env configures the environment that the plugin's scripts are invoked with
+// (see ExternalPluginEnv). It isn't supported for static plugins. Sandbox
+// restricts the resources that the plugin's scripts can use (see
+// ExternalPluginSandbox). It isn't supported for static plugins either.
 type ExternalPluginSpec struct {
-	Script string
+	Script   string
+	Dir      string
+	File     string
+	Requires Requirements
+	Daemon   bool
+	Shadow   string
+	Env      ExternalPluginEnv
+	Sandbox  ExternalPluginSandbox
+	// meta is the name of the meta plugin whose nested root the spec is,
+	// if any
+	meta string
 }
 
-// Name returns the plugin name, which is the basename of the script with extension removed.
+// Path returns the path to the plugin's script, meta plugin directory or static
+// plugin file.
+func (s ExternalPluginSpec) Path() string {
+	if s.Dir != "" {
+		return s.Dir
+	}
+	if s.File != "" {
+		return s.File
+	}
+	return s.Script
+}
+
+// Name returns the plugin name, which is the basename of the script (or directory)
+// with extension removed.
 func (s ExternalPluginSpec) Name() string {
-	basename := filepath.Base(s.Script)
+	basename := filepath.Base(s.Path())
 	return strings.TrimSuffix(basename, filepath.Ext(basename))
 }
 
+// limitsName returns the name that the plugin's limits are namespaced by,
+// which is its full path. It's <meta>.<name> for a meta plugin's nested root,
+// so that the nested root's limits don't collide with those of a plugin (or
+// of another meta plugin's nested root) with the same name.
+func (s ExternalPluginSpec) limitsName() string {
+	if s.meta != "" {
+		return s.meta + "." + s.Name()
+	}
+	return s.Name()
+}
+
 // Load ensures the external plugin represents an executable artifact and create a plugin Root.
 func (s ExternalPluginSpec) Load() (Root, error) {
-	fi, err := os.Stat(s.Script)
-	if err != nil {
+	specified := 0
+	for _, path := range []string{s.Script, s.Dir, s.File} {
+		if path != "" {
+			specified++
+		}
+	}
+	if specified > 1 {
+		return nil, fmt.Errorf("only one of script (%v), dir (%v) or file (%v) can be specified", s.Script, s.Dir, s.File)
+	}
+	if s.Daemon && s.Script == "" {
+		return nil, fmt.Errorf("%v: daemon mode is only supported for plugin scripts", s.Path())
+	}
+	if s.Shadow != "" && s.Script == "" {
+		return nil, fmt.Errorf("%v: shadows are only supported for plugin scripts", s.Path())
+	}
+	if !s.Env.isEmpty() {
+		if s.File != "" {
+			return nil, fmt.Errorf("%v: env is not supported for static plugins", s.Path())
+		}
+		if err := s.Env.validate(); err != nil {
+			return nil, fmt.Errorf("%v: %v", s.Path(), err)
+		}
+	}
+	if !s.Sandbox.isEmpty() {
+		if s.File != "" {
+			return nil, fmt.Errorf("%v: sandboxes are not supported for static plugins", s.Path())
+		}
+		if err := s.Sandbox.validate(); err != nil {
+			return nil, fmt.Errorf("%v: %v", s.Path(), err)
+		}
+	}
+	if s.Dir != "" {
+		fi, err := os.Stat(s.Dir)
+		if err != nil {
+			return nil, err
+		} else if !fi.IsDir() {
+			return nil, fmt.Errorf("meta plugin %v is not a directory", s.Dir)
+		}
+		root := newExternalPluginMetaRoot(s.Name(), s.Dir)
+		root.requires = s.Requires
+		root.env = s.Env
+		root.sandbox = s.Sandbox
+		return root, nil
+	}
+	if s.File != "" {
+		fi, err := os.Stat(s.File)
+		if err != nil {
+			return nil, err
+		} else if !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("static plugin %v is not a file", s.File)
+		}
+		script, err := newStaticPluginScript(s.Name(), s.File)
+		if err != nil {
+			return nil, err
+		}
+		root := &externalPluginRoot{
+			externalPluginEntry: &externalPluginEntry{
+				EntryBase: NewEntry(s.Name()),
+				script:    script,
+			},
+			requires: s.Requires,
+		}
+		return root, nil
+	}
+
+	if err := validateScript(s.Script); err != nil {
 		return nil, err
-	} else if !fi.Mode().IsRegular() {
-		return nil, fmt.Errorf("script %v is not a file", s.Script)
-	} else if fi.Mode().Perm()&0100 == 0 {
-		return nil, fmt.Errorf("script %v is not executable", s.Script)
 	}
 
-	root := &externalPluginRoot{&externalPluginEntry{
-		EntryBase: NewEntry(s.Name()),
-		script:    externalPluginScriptImpl{path: s.Script},
-	}}
+	env := newExternalPluginEnv(s.Name(), s.Env, s.Requires.Env)
+	var script externalPluginScript
+	if s.Daemon {
+		daemon := newExternalPluginDaemon(s.Name(), s.limitsName(), s.Script)
+		daemon.env = env
+		daemon.sandbox = s.Sandbox
+		script = daemon
+	} else {
+		impl := newNamespacedExternalPluginScript(s.Name(), s.limitsName(), s.Script)
+		impl.env = env
+		impl.sandbox = s.Sandbox
+		script = impl
+	}
+	if s.Shadow != "" {
+		if err := validateScript(s.Shadow); err != nil {
+			return nil, fmt.Errorf("invalid shadow: %v", err)
+		}
+		script = newShadowedScript(s.Name(), s.limitsName(), script, s.Shadow, env, s.Sandbox)
+	}
+	root := &externalPluginRoot{
+		externalPluginEntry: &externalPluginEntry{
+			EntryBase: NewEntry(s.Name()),
+			script:    script,
+		},
+		requires: s.Requires,
+	}
 	return root, nil
 }
+
+func validateScript(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	} else if !fi.Mode().IsRegular() {
+		return fmt.Errorf("script %v is not a file", path)
+	} else if fi.Mode().Perm()&0100 == 0 {
+		return fmt.Errorf("script %v is not executable", path)
+	}
+	return nil
+}
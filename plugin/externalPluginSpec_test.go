@@ -1,8 +1,10 @@
 package plugin
 
 import (
+	"context"
 	"testing"
 
+	"github.com/puppetlabs/wash/limits"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,3 +32,146 @@ func TestRegisterExternalPluginNotFile(t *testing.T) {
 	_, err := spec.Load()
 	assert.EqualError(t, err, "script testdata/notfile is not a file")
 }
+
+func TestLoadExternalPluginWithShadow(t *testing.T) {
+	spec := ExternalPluginSpec{Script: "testdata/external.sh", Shadow: "testdata/daemon.sh"}
+	root, err := spec.Load()
+	if assert.NoError(t, err) {
+		script, ok := root.(*externalPluginRoot).script.(*shadowedScript)
+		if assert.True(t, ok) {
+			assert.Equal(t, "testdata/external.sh", script.Path())
+			assert.Equal(t, "testdata/daemon.sh", script.shadow.Path())
+		}
+	}
+
+	spec = ExternalPluginSpec{Script: "testdata/external.sh", Shadow: "testdata/noexec"}
+	_, err = spec.Load()
+	assert.EqualError(t, err, "invalid shadow: script testdata/noexec is not executable")
+
+	spec = ExternalPluginSpec{Dir: "testdata/meta", Shadow: "testdata/external.sh"}
+	_, err = spec.Load()
+	assert.EqualError(t, err, "testdata/meta: shadows are only supported for plugin scripts")
+}
+
+func TestLoadExternalMetaPlugin(t *testing.T) {
+	spec := ExternalPluginSpec{Dir: "testdata/meta"}
+	root, err := spec.Load()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "meta", root.name())
+		if assert.NoError(t, root.Init(nil)) {
+			// broken.sh fails init and notes.txt isn't executable, so only
+			// nested.sh should be loaded.
+			nestedRoots, err := root.List(context.Background())
+			if assert.NoError(t, err) && assert.Equal(t, 1, len(nestedRoots)) {
+				assert.Equal(t, "nested", nestedRoots[0].name())
+				assert.Equal(t, "/meta/nested", nestedRoots[0].id())
+				assert.Equal(t, "testdata/meta/nested.sh", nestedRoots[0].(*externalPluginRoot).script.Path())
+			}
+		}
+	}
+}
+
+func TestLoadExternalMetaPluginNamespacesTheNestedRootsLimits(t *testing.T) {
+	spec := ExternalPluginSpec{Script: "testdata/external.sh", meta: "meta"}
+	assert.Equal(t, "meta.external", spec.limitsName())
+	if _, err := spec.Load(); assert.NoError(t, err) {
+		_, ok := limits.Get("plugins.meta.external.max_invocations")
+		assert.True(t, ok)
+	}
+
+	spec = ExternalPluginSpec{Script: "testdata/external.sh"}
+	assert.Equal(t, "external", spec.limitsName())
+}
+
+func TestLoadExternalMetaPluginNotDir(t *testing.T) {
+	spec := ExternalPluginSpec{Dir: "testdata/external.sh"}
+	_, err := spec.Load()
+	assert.EqualError(t, err, "meta plugin testdata/external.sh is not a directory")
+}
+
+func TestLoadExternalPluginScriptAndDir(t *testing.T) {
+	spec := ExternalPluginSpec{Script: "testdata/external.sh", Dir: "testdata/meta"}
+	_, err := spec.Load()
+	assert.Error(t, err)
+}
+
+func TestLoadStaticPlugin(t *testing.T) {
+	spec := ExternalPluginSpec{File: "testdata/static/inventory.yaml"}
+	root, err := spec.Load()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "inventory", root.name())
+	if !assert.NoError(t, root.Init(nil)) {
+		return
+	}
+
+	ctx := context.Background()
+	hosts, err := root.List(ctx)
+	if !assert.NoError(t, err) || !assert.Equal(t, 2, len(hosts)) {
+		return
+	}
+	web01 := hosts[0]
+	assert.Equal(t, "web01", web01.name())
+	attr := web01.(*externalPluginEntry).attributes()
+	assert.Equal(t, JSONObject{"role": "web", "region": "us-west-1"}, attr.Meta())
+
+	files, err := web01.(Parent).List(ctx)
+	if assert.NoError(t, err) && assert.Equal(t, 1, len(files)) {
+		assert.Equal(t, "motd", files[0].name())
+		content, err := files[0].(Readable).Open(ctx)
+		if assert.NoError(t, err) {
+			assert.Equal(t, int64(len("Welcome to web01\n")), content.Size())
+		}
+	}
+}
+
+func TestLoadStaticPluginUnprefetchedMethod(t *testing.T) {
+	spec := ExternalPluginSpec{File: "testdata/static/unprefetched.yaml"}
+	_, err := spec.Load()
+	assert.EqualError(t, err, "static plugin testdata/static/unprefetched.yaml is invalid: entry unprefetched must prefetch its list result")
+}
+
+func TestLoadStaticPluginUnsupportedMethod(t *testing.T) {
+	spec := ExternalPluginSpec{File: "testdata/static/exec.json"}
+	_, err := spec.Load()
+	assert.EqualError(t, err, "static plugin testdata/static/exec.json is invalid: entry svc implements exec, but static plugins only support the list, read and schema methods")
+}
+
+func TestLoadStaticPluginNotFile(t *testing.T) {
+	spec := ExternalPluginSpec{File: "testdata/static"}
+	_, err := spec.Load()
+	assert.EqualError(t, err, "static plugin testdata/static is not a file")
+}
+
+func TestLoadExternalPluginScriptAndFile(t *testing.T) {
+	spec := ExternalPluginSpec{Script: "testdata/external.sh", File: "testdata/static/inventory.yaml"}
+	_, err := spec.Load()
+	assert.Error(t, err)
+}
+
+func TestLoadExternalPluginWithEnv(t *testing.T) {
+	env := ExternalPluginEnv{Allow: []string{"AWS_*"}}
+	spec := ExternalPluginSpec{Script: "testdata/external.sh", Env: env, Daemon: true}
+	root, err := spec.Load()
+	if assert.NoError(t, err) {
+		daemon, ok := root.(*externalPluginRoot).script.(*externalPluginDaemon)
+		if assert.True(t, ok) && assert.NotNil(t, daemon.env) {
+			assert.Equal(t, env, daemon.env.spec)
+		}
+	}
+
+	spec = ExternalPluginSpec{Dir: "testdata/meta", Env: env}
+	root, err = spec.Load()
+	if assert.NoError(t, err) {
+		assert.Equal(t, env, root.(*externalPluginMetaRoot).env)
+	}
+
+	spec = ExternalPluginSpec{File: "testdata/static/inventory.yaml", Env: env}
+	_, err = spec.Load()
+	assert.EqualError(t, err, "testdata/static/inventory.yaml: env is not supported for static plugins")
+
+	spec = ExternalPluginSpec{Script: "testdata/external.sh", Env: ExternalPluginEnv{Secrets: []string{"API_TOKEN"}}}
+	_, err = spec.Load()
+	assert.EqualError(t, err, "testdata/external.sh: the secrets API_TOKEN require a credentials helper")
+}
@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+)
+
+// stateFileEnvVar is the environment variable that contains the path to the
+// entry's state when it's spilled to disk. The <state> argument is empty in
+// that case.
+const stateFileEnvVar = "WASH_STATE_FILE"
+
+// maxStateSize is the maximum size of each external plugin entry's state
+var maxStateSize = limits.Register(
+	"plugins.max_state_size_kb",
+	"The maximum size (in kilobytes) of each external plugin entry's state. Entries with larger states fail to decode.",
+	1024,
+	nil,
+)
+
+// maxInlineStateSize is the maximum size of the states that are passed in
+// via the <state> argument. Larger states would exceed the OS's limit on the
+// size of a single argument, so they're spilled to disk. Only the plugins
+// whose root sets state_spillover can have larger states.
+const maxInlineStateSize = 64 * 1024
+
+// spilledStatesWorkspace is the workspace that spilled states are written
+// to. It's hidden so that it can't clash with a plugin's workspace.
+const spilledStatesWorkspace = ".states"
+
+// decodeState returns the <state> of an entry's decoded state. Strings are
+// passed in as-is, which is how states were always passed in. Other JSON
+// values (e.g. objects) are passed in as compact JSON, which preserves them
+// losslessly (including their key order and the precision of their
+// numbers).
+func decodeState(entryName string, raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var state string
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &state); err != nil {
+			return "", fmt.Errorf("entry %v has an invalid state: %v", entryName, err)
+		}
+	} else {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err != nil {
+			return "", fmt.Errorf("entry %v has an invalid state: %v", entryName, err)
+		}
+		state = buf.String()
+	}
+	if max := maxStateSize.Value() * 1024; max > 0 && len(state) > max {
+		return "", fmt.Errorf(
+			"entry %v's state is %v bytes, which exceeds the plugins.max_state_size_kb limit of %v bytes",
+			entryName,
+			len(state),
+			max,
+		)
+	}
+	return state, nil
+}
+
+// validateStateSize returns an error if the entry's state is too large to be
+// passed in via the <state> argument, and the plugin doesn't accept spilled
+// states
+func validateStateSize(entryName string, state string, spillover bool) error {
+	if len(state) <= maxInlineStateSize || spillover {
+		return nil
+	}
+	return fmt.Errorf(
+		"entry %v's state is %v bytes. States larger than %v bytes are spilled to disk, so the plugin root must set state_spillover to accept them",
+		entryName,
+		len(state),
+		maxInlineStateSize,
+	)
+}
+
+// spillState writes state to the spilled states' workspace, and returns the
+// file's path. The files are named by their content's hash so that entries
+// with the same state share a file. Existing files are touched so that
+// they're not garbage-collected while they're in use.
+func spillState(state string) (string, error) {
+	dir, err := Workspace(spilledStatesWorkspace)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(state))
+	path := filepath.Join(dir, hex.EncodeToString(sum[:]))
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return path, nil
+	}
+	// The file's written to a temporary file first so that concurrent
+	// invocations never read a partially written state
+	f, err := ioutil.TempFile(dir, "state-")
+	if err != nil {
+		return "", fmt.Errorf("could not spill the state to disk: %v", err)
+	}
+	_, err = f.WriteString(state)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("could not spill the state to disk: %v", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginStateTestSuite struct {
+	suite.Suite
+	origWorkspacesDir string
+}
+
+func (suite *ExternalPluginStateTestSuite) SetupTest() {
+	suite.origWorkspacesDir = workspacesDir
+	workspacesDir = suite.T().TempDir()
+}
+
+func (suite *ExternalPluginStateTestSuite) TearDownTest() {
+	workspacesDir = suite.origWorkspacesDir
+}
+
+func (suite *ExternalPluginStateTestSuite) TestDecodeState() {
+	state, err := decodeState("foo", nil)
+	if suite.NoError(err) {
+		suite.Equal("", state)
+	}
+	state, err = decodeState("foo", json.RawMessage("null"))
+	if suite.NoError(err) {
+		suite.Equal("", state)
+	}
+
+	// Strings are passed in as-is
+	state, err = decodeState("foo", json.RawMessage(`"{\"klass\":\"SSHFS::VM\"}"`))
+	if suite.NoError(err) {
+		suite.Equal(`{"klass":"SSHFS::VM"}`, state)
+	}
+
+	// Other values are passed in as JSON, preserving their key order and
+	// the precision of their numbers
+	state, err = decodeState("foo", json.RawMessage(`{ "page_token": "b", "id": 12345678901234567890, "scopes": [ "a" ] }`))
+	if suite.NoError(err) {
+		suite.Equal(`{"page_token":"b","id":12345678901234567890,"scopes":["a"]}`, state)
+	}
+}
+
+func (suite *ExternalPluginStateTestSuite) TestDecodeStateEnforcesTheSizeLimit() {
+	_, err := limits.Set("plugins.max_state_size_kb", 1)
+	suite.NoError(err)
+	defer func() {
+		_, err := limits.Set("plugins.max_state_size_kb", 1024)
+		suite.NoError(err)
+	}()
+
+	_, err = decodeState("foo", json.RawMessage(`"`+strings.Repeat("a", 1025)+`"`))
+	suite.Regexp("foo's state is 1025 bytes.*plugins.max_state_size_kb limit of 1024 bytes", err)
+	_, err = decodeState("foo", json.RawMessage(`"`+strings.Repeat("a", 1024)+`"`))
+	suite.NoError(err)
+}
+
+func (suite *ExternalPluginStateTestSuite) TestValidateStateSize() {
+	big := strings.Repeat("a", maxInlineStateSize+1)
+	suite.NoError(validateStateSize("foo", big[1:], false))
+	suite.Regexp("foo's state.*must set state_spillover", validateStateSize("foo", big, false))
+	suite.NoError(validateStateSize("foo", big, true))
+}
+
+func (suite *ExternalPluginStateTestSuite) TestSpillState() {
+	path, err := spillState("some state")
+	if !suite.NoError(err) {
+		return
+	}
+	content, err := ioutil.ReadFile(path)
+	if suite.NoError(err) {
+		suite.Equal("some state", string(content))
+	}
+
+	// Entries with the same state share its file
+	samePath, err := spillState("some state")
+	if suite.NoError(err) {
+		suite.Equal(path, samePath)
+	}
+	otherPath, err := spillState("other state")
+	if suite.NoError(err) {
+		suite.NotEqual(path, otherPath)
+	}
+
+	// The file's rewritten if it was garbage-collected
+	suite.NoError(os.Remove(path))
+	_, err = spillState("some state")
+	if suite.NoError(err) {
+		_, err = os.Stat(path)
+		suite.NoError(err)
+	}
+}
+
+func (suite *ExternalPluginStateTestSuite) newRoot(config map[string]interface{}) (*externalPluginRoot, error) {
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
+		EntryBase: NewEntry("state"),
+		script:    newExternalPluginScript("state", "testdata/state.sh"),
+	}}
+	root.SetTestID("/state")
+	return root, root.Init(config)
+}
+
+func (suite *ExternalPluginStateTestSuite) read(entry Entry) string {
+	rdr, err := entry.(*externalPluginEntry).Open(context.Background())
+	if !suite.NoError(err) {
+		return ""
+	}
+	content, err := ioutil.ReadAll(io.NewSectionReader(rdr, 0, rdr.Size()))
+	suite.NoError(err)
+	return string(content)
+}
+
+func (suite *ExternalPluginStateTestSuite) TestPassesInTheStates() {
+	root, err := suite.newRoot(nil)
+	if !suite.NoError(err) {
+		return
+	}
+	entries, err := root.List(context.Background())
+	if !suite.NoError(err) || !suite.Len(entries, 2) {
+		return
+	}
+
+	suite.Equal(`arg:{"token":"abc","page":2.50}`, suite.read(entries[0]))
+
+	content := suite.read(entries[1])
+	suite.True(strings.HasPrefix(content, `file::{"data":"aaa`), content)
+	suite.Len(content, len(`file::{"data":""}`)+70000)
+}
+
+func (suite *ExternalPluginStateTestSuite) TestRejectsLargeStatesWithoutSpillover() {
+	root, err := suite.newRoot(map[string]interface{}{"nospillover": true})
+	if !suite.NoError(err) {
+		return
+	}
+	_, err = root.List(context.Background())
+	suite.Regexp("big's state is .* must set state_spillover", err)
+}
+
+func TestExternalPluginState(t *testing.T) {
+	suite.Run(t, new(ExternalPluginStateTestSuite))
+}
@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultTimeouts are the timeouts of the methods that Wash times out even if
+// the plugin doesn't set a timeout for them. stream's timeout is how long Wash
+// waits for the stream's header, not how long the stream lasts.
+var defaultTimeouts = map[string]time.Duration{
+	"stream": 5 * time.Second,
+	"schema": 3 * time.Second,
+	// telemetry's supposed to be cheap, so it shouldn't hold up e.g. a
+	// listing with telemetry
+	"telemetry": 3 * time.Second,
+}
+
+// validateTimeouts validates an entry's decoded timeouts. They're in seconds,
+// and 0 means that the method's never timed out.
+func validateTimeouts(entryName string, timeouts map[string]time.Duration) error {
+	for method, timeout := range timeouts {
+		// The run timeout applies to all of the entry's custom actions
+		if method == "init" || (!isExternalPluginMethod(method) && method != RunAction().Name) {
+			return fmt.Errorf("entry %v sets a timeout for the unknown method %v", entryName, method)
+		}
+		if timeout < 0 {
+			return fmt.Errorf("entry %v sets a negative timeout for %v", entryName, method)
+		}
+	}
+	return nil
+}
+
+func isExternalPluginMethod(method string) bool {
+	for _, m := range externalPluginMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// inheritTimeouts sets the entry's timeouts for the methods that it didn't set
+// one for to its parent's. That way, a timeout that's set by the plugin root
+// (i.e. in the init response) applies to the whole plugin.
+func (e *externalPluginEntry) inheritTimeouts(parent *externalPluginEntry) {
+	for method, timeout := range parent.timeouts {
+		if _, ok := e.timeouts[method]; ok {
+			continue
+		}
+		if e.timeouts == nil {
+			e.timeouts = make(map[string]time.Duration)
+		}
+		e.timeouts[method] = timeout
+	}
+}
+
+// timeoutOf returns the entry's timeout for method. It returns false if the
+// method's never timed out.
+func (e *externalPluginEntry) timeoutOf(method string) (time.Duration, bool) {
+	timeout, ok := e.timeouts[method]
+	if !ok {
+		timeout = defaultTimeouts[method]
+	}
+	return timeout, timeout > 0
+}
+
+// invokeWithTimeout calls invoke with a context that expires after the entry's
+// timeout for method. invoke isn't called if the entry's plugin is
+// quarantined (see externalPluginHealthCheck).
+func (e *externalPluginEntry) invokeWithTimeout(
+	ctx context.Context,
+	method string,
+	invoke func(context.Context) (invocation, error),
+) (invocation, error) {
+	if err := quarantineErrOf(e.id()); err != nil {
+		return invocation{}, err
+	}
+	timeout, ok := e.timeoutOf(method)
+	if !ok {
+		return invoke(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	inv, err := invoke(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return inv, newInvokeError(fmt.Sprintf("%v timed out after %v", method, timeout), inv)
+	}
+	return inv, err
+}
@@ -0,0 +1,315 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/puppetlabs/wash/plugin/internal"
+)
+
+// These are the transports that an external plugin script can return its list
+// and metadata results in. Scripts declare their transport in their init
+// response. JSON's the default. MessagePack and CBOR are much cheaper to decode
+// for huge results, e.g. lists with tens of thousands of entries.
+const (
+	transportJSON    = "json"
+	transportMsgpack = "msgpack"
+	transportCBOR    = "cbor"
+)
+
+var externalPluginTransports = []string{transportJSON, transportMsgpack, transportCBOR}
+
+func validateTransport(transport string) error {
+	if transport == "" {
+		return nil
+	}
+	for _, t := range externalPluginTransports {
+		if transport == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown transport %v; it must be one of %v", transport, strings.Join(externalPluginTransports, ", "))
+}
+
+// isBinaryTransport returns true if transport isn't JSON
+func isBinaryTransport(transport string) bool {
+	return transport != "" && transport != transportJSON
+}
+
+// newStreamDecoder returns the streaming decoder of the given binary
+// transport's output
+func newStreamDecoder(transport string, r io.Reader) internal.StreamDecoder {
+	switch transport {
+	case transportMsgpack:
+		return internal.NewMsgpackDecoder(r)
+	case transportCBOR:
+		return internal.NewCBORDecoder(r)
+	default:
+		panic(fmt.Sprintf("newStreamDecoder called with the non-binary transport %v", transport))
+	}
+}
+
+// forEachItem calls decodeItem for each of the n items of the array or map
+// whose header was just read from d. n is -1 for indefinite-length
+// containers, which end with a break.
+func forEachItem(d internal.StreamDecoder, n int, decodeItem func() error) error {
+	for i := 0; n < 0 || i < n; i++ {
+		if n < 0 {
+			if done, err := d.ReadBreak(); err != nil {
+				return err
+			} else if done {
+				return nil
+			}
+		}
+		if err := decodeItem(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeEntriesWith is decodeEntriesPage for a list result that's returned
+// via transport. Like the JSON results, binary results are decoded
+// incrementally, so only one decoded entry needs to be kept in memory at a
+// time.
+func decodeEntriesWith(transport string, r io.Reader, onEntry func(decodedExternalPluginEntry) error) (string, error) {
+	if !isBinaryTransport(transport) {
+		return decodeEntriesPage(r, onEntry)
+	}
+	d := newStreamDecoder(transport, r)
+	kind, err := d.PeekKind()
+	if err != nil {
+		return "", err
+	}
+	var nextPage string
+	switch kind {
+	case internal.KindArray:
+		err = decodeBinaryEntryArray(d, onEntry)
+	case internal.KindMap:
+		nextPage, err = decodeBinaryEntriesPage(d, onEntry)
+	default:
+		payload, err := d.Decode()
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("expected an array, not %v", payload)
+	}
+	if err != nil {
+		return "", err
+	}
+	return nextPage, d.ExpectEOF()
+}
+
+// decodeBinaryEntriesPage decodes a page of entries, i.e. a map like
+// pagedListFormat, from d. It returns the next page's token.
+func decodeBinaryEntriesPage(d internal.StreamDecoder, onEntry func(decodedExternalPluginEntry) error) (string, error) {
+	n, err := d.ReadMapHeader()
+	if err != nil {
+		return "", err
+	}
+	var nextPage string
+	err = forEachItem(d, n, func() error {
+		key, err := d.Decode()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "entries":
+			kind, err := d.PeekKind()
+			if err != nil {
+				return err
+			}
+			if kind == internal.KindArray {
+				return decodeBinaryEntryArray(d, onEntry)
+			}
+			entries, err := d.Decode()
+			if err != nil || entries == nil {
+				return err
+			}
+			return fmt.Errorf("expected entries to be an array, not %v", entries)
+		case "next_page":
+			rawNextPage, err := d.Decode()
+			if err != nil {
+				return err
+			}
+			var ok bool
+			if nextPage, ok = rawNextPage.(string); !ok {
+				return fmt.Errorf("expected next_page to be a string, not %v", rawNextPage)
+			}
+			return nil
+		default:
+			return fmt.Errorf("unexpected key %v", key)
+		}
+	})
+	return nextPage, err
+}
+
+// decodeBinaryEntryArray decodes an array of entries from d. Like
+// decodeEntryArray, it errors if the array has more than the
+// plugins.max_list_entries limit's entries.
+func decodeBinaryEntryArray(d internal.StreamDecoder, onEntry func(decodedExternalPluginEntry) error) error {
+	n, err := d.ReadArrayHeader()
+	if err != nil {
+		return err
+	}
+	max := maxListEntries.Value()
+	count := 0
+	return forEachItem(d, n, func() error {
+		if max > 0 && count >= max {
+			return fmt.Errorf("more than %v entries were returned. Increase the %v limit if that's expected", max, maxListEntries.Name())
+		}
+		count++
+		decodedEntry, err := decodeBinaryEntry(d)
+		if err != nil {
+			return err
+		}
+		return onEntry(decodedEntry)
+	})
+}
+
+// decodeEntryWith decodes a single entry (e.g. a create result) that was
+// returned via transport
+func decodeEntryWith(transport string, data []byte) (decodedExternalPluginEntry, error) {
+	if !isBinaryTransport(transport) {
+		var decodedEntry decodedExternalPluginEntry
+		err := json.Unmarshal(data, &decodedEntry)
+		return decodedEntry, err
+	}
+	d := newStreamDecoder(transport, bytes.NewReader(data))
+	decodedEntry, err := decodeBinaryEntry(d)
+	if err != nil {
+		return decodedEntry, err
+	}
+	return decodedEntry, d.ExpectEOF()
+}
+
+var entryAttributesType = reflect.TypeOf(EntryAttributes{})
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// entryFields maps decodedExternalPluginEntry's JSON keys to the indexes of
+// their fields
+var entryFields = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(decodedExternalPluginEntry{})
+	for i := 0; i < t.NumField(); i++ {
+		fields[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = i
+	}
+	return fields
+}()
+
+// decodeBinaryEntry decodes the next entry in d straight into a
+// decodedExternalPluginEntry, one field at a time. Unknown keys are ignored
+// like encoding/json ignores them.
+func decodeBinaryEntry(d internal.StreamDecoder) (decodedExternalPluginEntry, error) {
+	var decodedEntry decodedExternalPluginEntry
+	kind, err := d.PeekKind()
+	if err != nil {
+		return decodedEntry, err
+	}
+	if kind != internal.KindMap {
+		raw, err := d.Decode()
+		if err != nil {
+			return decodedEntry, err
+		}
+		return decodedEntry, fmt.Errorf("expected an entry object, not %v", raw)
+	}
+	n, err := d.ReadMapHeader()
+	if err != nil {
+		return decodedEntry, err
+	}
+	entry := reflect.ValueOf(&decodedEntry).Elem()
+	err = forEachItem(d, n, func() error {
+		key, err := d.Decode()
+		if err != nil {
+			return err
+		}
+		value, err := d.Decode()
+		if err != nil {
+			return err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return fmt.Errorf("the entry's keys must be strings, not %v", key)
+		}
+		index, ok := entryFields[name]
+		if !ok {
+			return nil
+		}
+		if err := setEntryField(entry.Field(index), value); err != nil {
+			return fmt.Errorf("could not decode the entry's %v: %v", key, err)
+		}
+		return nil
+	})
+	return decodedEntry, err
+}
+
+// setEntryField sets one of decodedExternalPluginEntry's fields to the
+// decoded value. Values that already have the field's type (e.g. the name
+// and the methods, whose results can include timestamps) are set as-is,
+// while the rest are decoded from their JSON encoding so that they're decoded
+// like the json transport decodes them. Nil values leave the field unset.
+func setEntryField(field reflect.Value, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	switch field.Type() {
+	case entryAttributesType:
+		// EntryAttributes munges its values, so it's set from the decoded
+		// map to keep the transports' native timestamps
+		mp, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("attributes must be an object, not %v", value)
+		}
+		var attr EntryAttributes
+		if err := attr.fromMap(mp); err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(attr))
+		return nil
+	case rawMessageType:
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("could not encode %v as JSON: %v", value, err)
+		}
+		field.SetBytes(raw)
+		return nil
+	}
+	if v := reflect.ValueOf(value); v.Type().AssignableTo(field.Type()) {
+		field.Set(v)
+		return nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("could not encode %v as JSON: %v", value, err)
+	}
+	return json.Unmarshal(raw, field.Addr().Interface())
+}
+
+// decodeMetadata decodes a metadata result that was returned via transport
+func decodeMetadata(transport string, data []byte) (JSONObject, error) {
+	var metadata JSONObject
+	if !isBinaryTransport(transport) {
+		err := json.Unmarshal(data, &metadata)
+		return metadata, err
+	}
+	d := newStreamDecoder(transport, bytes.NewReader(data))
+	payload, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.ExpectEOF(); err != nil {
+		return nil, err
+	}
+	if payload == nil {
+		return nil, nil
+	}
+	metadata, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object, not %v", payload)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,217 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginTransportTestSuite struct {
+	suite.Suite
+}
+
+// msgpackOf is a minimal MessagePack encoder for the tests' payloads. It only
+// supports small maps, arrays, strings and positive integers.
+func msgpackOf(v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return append([]byte{0xa0 | byte(len(v))}, v...)
+	case int:
+		return []byte{byte(v)}
+	case bool:
+		if v {
+			return []byte{0xc3}
+		}
+		return []byte{0xc2}
+	case []interface{}:
+		bits := []byte{0x90 | byte(len(v))}
+		for _, item := range v {
+			bits = append(bits, msgpackOf(item)...)
+		}
+		return bits
+	case [][2]interface{}:
+		// An ordered map
+		bits := []byte{0x80 | byte(len(v))}
+		for _, pair := range v {
+			bits = append(bits, msgpackOf(pair[0])...)
+			bits = append(bits, msgpackOf(pair[1])...)
+		}
+		return bits
+	default:
+		panic("msgpackOf: unsupported value")
+	}
+}
+
+const transportTestEntryJSON = `{"name":"foo","methods":["list",["read","bar"]],"cache_ttls":{"list":30},"partial_reads":true,"timeouts":{"read":5},"attributes":{"mtime":60,"size":10,"meta":{"k":"v"}}}`
+
+func transportTestEntryMsgpack() []byte {
+	return msgpackOf([][2]interface{}{
+		{"name", "foo"},
+		{"methods", []interface{}{"list", []interface{}{"read", "bar"}}},
+		{"cache_ttls", [][2]interface{}{{"list", 30}}},
+		{"partial_reads", true},
+		{"timeouts", [][2]interface{}{{"read", 5}}},
+		{"attributes", [][2]interface{}{{"mtime", 60}, {"size", 10}, {"meta", [][2]interface{}{{"k", "v"}}}}},
+	})
+}
+
+func (suite *ExternalPluginTransportTestSuite) decodeEntries(transport string, data []byte) []decodedExternalPluginEntry {
+	var entries []decodedExternalPluginEntry
+	_, err := decodeEntriesWith(transport, bytes.NewReader(data), func(e decodedExternalPluginEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if !suite.NoError(err) {
+		suite.FailNow("could not decode the entries")
+	}
+	return entries
+}
+
+func (suite *ExternalPluginTransportTestSuite) TestDecodeEntriesWith_MsgpackDecodesLikeJSON() {
+	expected := suite.decodeEntries(transportJSON, []byte("["+transportTestEntryJSON+"]"))
+	actual := suite.decodeEntries(transportMsgpack, append([]byte{0x91}, transportTestEntryMsgpack()...))
+	suite.Equal(expected, actual)
+	suite.Equal(uint64(10), actual[0].Attributes.Size())
+}
+
+func (suite *ExternalPluginTransportTestSuite) TestDecodeEntriesWith_State() {
+	expected := suite.decodeEntries(transportJSON, []byte(`[{"name":"foo","methods":["read"],"state":"abc"},{"name":"bar","methods":["read"],"state":{"a":"b","c":[1,true]}}]`))
+	actual := suite.decodeEntries(transportMsgpack, msgpackOf([]interface{}{
+		[][2]interface{}{{"name", "foo"}, {"methods", []interface{}{"read"}}, {"state", "abc"}},
+		[][2]interface{}{{"name", "bar"}, {"methods", []interface{}{"read"}}, {"state", [][2]interface{}{{"a", "b"}, {"c", []interface{}{1, true}}}}},
+	}))
+	suite.Equal(expected, actual)
+
+	// The decoded states are passed to the script like JSON states are
+	for i, state := range []string{"abc", `{"a":"b","c":[1,true]}`} {
+		entry, err := actual[i].toExternalPluginEntry(false, false)
+		if suite.NoError(err) {
+			suite.Equal(state, entry.state)
+		}
+	}
+}
+
+func (suite *ExternalPluginTransportTestSuite) TestDecodeEntriesWith_CBOR() {
+	// [{"name": "foo", "methods": ["list"]}]
+	data := []byte{0x81, 0xa2, 0x64, 'n', 'a', 'm', 'e', 0x63, 'f', 'o', 'o', 0x67, 'm', 'e', 't', 'h', 'o', 'd', 's', 0x81, 0x64, 'l', 'i', 's', 't'}
+	entries := suite.decodeEntries(transportCBOR, data)
+	if suite.Len(entries, 1) {
+		suite.Equal("foo", entries[0].Name)
+		suite.Equal([]interface{}{"list"}, entries[0].Methods)
+	}
+
+	// [{"name": "foo", "attributes": {"mtime": <tag 1 epoch timestamp>}}]
+	data = []byte{0x81, 0xa2, 0x64, 'n', 'a', 'm', 'e', 0x63, 'f', 'o', 'o', 0x6a, 'a', 't', 't', 'r', 'i', 'b', 'u', 't', 'e', 's', 0xa1, 0x65, 'm', 't', 'i', 'm', 'e', 0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}
+	entries = suite.decodeEntries(transportCBOR, data)
+	if suite.Len(entries, 1) {
+		suite.Equal(time.Unix(1363896240, 0), entries[0].Attributes.Mtime())
+	}
+}
+
+func (suite *ExternalPluginTransportTestSuite) TestDecodeEntriesWith_DecodesIncrementally() {
+	// The second entry's truncated, so the first one should've been decoded
+	// before the decoder reached it
+	data := msgpackOf([]interface{}{
+		[][2]interface{}{{"name", "foo"}},
+		[][2]interface{}{{"name", "bar"}},
+	})
+	var names []string
+	_, err := decodeEntriesWith(transportMsgpack, bytes.NewReader(data[:len(data)-2]), func(e decodedExternalPluginEntry) error {
+		names = append(names, e.Name)
+		return nil
+	})
+	suite.Regexp("unexpected end of data", err)
+	suite.Equal([]string{"foo"}, names)
+}
+
+func (suite *ExternalPluginTransportTestSuite) TestDecodeEntriesWith_Page() {
+	var names []string
+	onEntry := func(e decodedExternalPluginEntry) error {
+		names = append(names, e.Name)
+		return nil
+	}
+	page := msgpackOf([][2]interface{}{
+		{"entries", []interface{}{[][2]interface{}{{"name", "foo"}}}},
+		{"next_page", "abc"},
+	})
+	nextPage, err := decodeEntriesWith(transportMsgpack, bytes.NewReader(page), onEntry)
+	if suite.NoError(err) {
+		suite.Equal("abc", nextPage)
+		suite.Equal([]string{"foo"}, names)
+	}
+
+	nextPage, err = decodeEntriesWith(transportMsgpack, bytes.NewReader(msgpackOf([][2]interface{}{{"entries", []interface{}{}}})), onEntry)
+	if suite.NoError(err) {
+		suite.Equal("", nextPage)
+	}
+	_, err = decodeEntriesWith(transportMsgpack, bytes.NewReader(msgpackOf([][2]interface{}{{"next_page", 1}})), onEntry)
+	suite.Regexp("expected next_page to be a string", err)
+	_, err = decodeEntriesWith(transportMsgpack, bytes.NewReader(msgpackOf([][2]interface{}{{"foo", "bar"}})), onEntry)
+	suite.Regexp("unexpected key foo", err)
+}
+
+func (suite *ExternalPluginTransportTestSuite) TestDecodeEntriesWith_Errors() {
+	noop := func(decodedExternalPluginEntry) error { return nil }
+	_, err := decodeEntriesWith(transportMsgpack, bytes.NewReader(msgpackOf("foo")), noop)
+	suite.EqualError(err, "expected an array, not foo")
+	_, err = decodeEntriesWith(transportMsgpack, bytes.NewReader([]byte{0x91}), noop)
+	suite.Regexp("invalid MessagePack", err)
+
+	invalidAttrs := msgpackOf([]interface{}{[][2]interface{}{{"name", "foo"}, {"attributes", "bar"}}})
+	_, err = decodeEntriesWith(transportMsgpack, bytes.NewReader(invalidAttrs), noop)
+	suite.Regexp("attributes must be an object", err)
+
+	_, err = decodeEntriesWith(transportMsgpack, bytes.NewReader(msgpackOf([]interface{}{"foo"})), noop)
+	suite.EqualError(err, "expected an entry object, not foo")
+	_, err = decodeEntriesWith(transportMsgpack, bytes.NewReader(msgpackOf([]interface{}{[][2]interface{}{{1, "foo"}}})), noop)
+	suite.EqualError(err, "the entry's keys must be strings, not 1")
+}
+
+func (suite *ExternalPluginTransportTestSuite) TestDecodeMetadata() {
+	metadata, err := decodeMetadata(transportMsgpack, msgpackOf([][2]interface{}{{"key", "value"}}))
+	if suite.NoError(err) {
+		suite.Equal(JSONObject{"key": "value"}, metadata)
+	}
+	_, err = decodeMetadata(transportMsgpack, msgpackOf([]interface{}{}))
+	suite.EqualError(err, "expected an object, not []")
+
+	metadata, err = decodeMetadata(transportJSON, []byte(`{"key":"value"}`))
+	if suite.NoError(err) {
+		suite.Equal(JSONObject{"key": "value"}, metadata)
+	}
+}
+
+func (suite *ExternalPluginTransportTestSuite) TestListUsesTheEntrysTransport() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		script:    mockScript,
+		transport: transportMsgpack,
+	}
+	entry.SetTestID("/fooPlugin")
+
+	ctx := context.Background()
+	stdout := msgpackOf([]interface{}{[][2]interface{}{{"name", "bar"}, {"methods", []interface{}{"list"}}}})
+	mockScript.OnInvokeAndWait(ctx, "list", entry).Return(mockInvocation(stdout), nil).Once()
+	entries, err := entry.List(ctx)
+	if suite.NoError(err) && suite.Len(entries, 1) {
+		child := entries[0].(*externalPluginEntry)
+		suite.Equal("bar", child.name())
+		// Children inherit the transport
+		suite.Equal(transportMsgpack, child.transport)
+	}
+	mockScript.AssertExpectations(suite.T())
+}
+
+func (suite *ExternalPluginTransportTestSuite) TestValidateTransport() {
+	suite.NoError(validateTransport(""))
+	suite.NoError(validateTransport(transportCBOR))
+	suite.EqualError(validateTransport("xml"), "unknown transport xml; it must be one of json, msgpack, cbor")
+}
+
+func TestExternalPluginTransport(t *testing.T) {
+	suite.Run(t, new(ExternalPluginTransportTestSuite))
+}
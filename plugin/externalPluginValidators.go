@@ -0,0 +1,126 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+)
+
+// These environment variables implement validators (see Validators) for
+// external plugins. The previous validators are passed via WASH_ETAG and
+// WASH_LAST_MODIFIED. The script writes the new validators to the file at
+// WASH_VALIDATORS_FILE, optionally with "unchanged": true to indicate that
+// the previous result's still valid.
+const (
+	etagEnvVar           = "WASH_ETAG"
+	lastModifiedEnvVar   = "WASH_LAST_MODIFIED"
+	validatorsFileEnvVar = "WASH_VALIDATORS_FILE"
+)
+
+// decodedValidators is the content of the validators file
+type decodedValidators struct {
+	Validators
+	Unchanged bool `json:"unchanged"`
+}
+
+const validatorsFileFormat = "{\"etag\":\"<etag>\",\"last_modified\":\"<RFC3339 timestamp>\",\"unchanged\":<true or false>}"
+
+// validatorsEnv returns the environment variables that pass the validators in
+// ctx to the plugin script
+func validatorsEnv(ctx context.Context) []string {
+	slot, ok := ctx.Value(validatorsKey).(*validatorsSlot)
+	if !ok {
+		return nil
+	}
+	slot.mux.Lock()
+	path := slot.file
+	slot.mux.Unlock()
+	if path == "" {
+		return nil
+	}
+	env := []string{validatorsFileEnvVar + "=" + path}
+	if previous, ok := PreviousValidators(ctx); ok {
+		if previous.ETag != "" {
+			env = append(env, etagEnvVar+"="+previous.ETag)
+		}
+		if !previous.LastModified.IsZero() {
+			env = append(env, lastModifiedEnvVar+"="+previous.LastModified.Format(time.RFC3339))
+		}
+	}
+	return env
+}
+
+// invokeAndWaitValidated is like script.InvokeAndWait, except that it passes
+// along the previous validators and sets the validators that the script wrote.
+// It returns ErrUnchanged if the script reported that the previous result is
+// still valid.
+func (e *externalPluginEntry) invokeAndWaitValidated(ctx context.Context, method string) (invocation, error) {
+	return e.invokeValidated(ctx, method, func(ctx context.Context) (invocation, error) {
+		return e.script.InvokeAndWait(ctx, method, e)
+	})
+}
+
+// invokeValidated is invokeAndWaitValidated for an arbitrary invocation of
+// method, e.g. one whose stdout's decoded as it's printed
+func (e *externalPluginEntry) invokeValidated(ctx context.Context, method string, invoke func(context.Context) (invocation, error)) (invocation, error) {
+	return e.invokeWithTimeout(ctx, method, func(ctx context.Context) (invocation, error) {
+		return e.invokeWithValidators(ctx, method, invoke)
+	})
+}
+
+func (e *externalPluginEntry) invokeWithValidators(ctx context.Context, method string, invoke func(context.Context) (invocation, error)) (invocation, error) {
+	slot, ok := ctx.Value(validatorsKey).(*validatorsSlot)
+	if !ok {
+		// The result isn't cached, so there's nothing to validate
+		return invoke(ctx)
+	}
+
+	f, err := ioutil.TempFile("", "wash-validators-")
+	if err != nil {
+		activity.Warnf(ctx, "Could not create the validators file for %v on %v: %v", method, e.id(), err)
+		return invoke(ctx)
+	}
+	path := f.Name()
+	defer func() {
+		if err := os.Remove(path); err != nil {
+			activity.Warnf(ctx, "Could not remove the validators file %v: %v", path, err)
+		}
+	}()
+	if err := f.Close(); err != nil {
+		activity.Warnf(ctx, "Could not close the validators file %v: %v", path, err)
+	}
+
+	slot.mux.Lock()
+	slot.file = path
+	slot.mux.Unlock()
+	inv, err := invoke(ctx)
+	slot.mux.Lock()
+	slot.file = ""
+	slot.mux.Unlock()
+	if err != nil {
+		return inv, err
+	}
+	bits, err := ioutil.ReadFile(path)
+	if err != nil || len(bits) == 0 {
+		// The script doesn't support validators
+		return inv, nil
+	}
+
+	var decoded decodedValidators
+	if err := json.Unmarshal(bits, &decoded); err != nil {
+		return inv, newInvokeError(
+			fmt.Sprintf("could not decode the validators %q: %v. They should look like %v", bits, err, validatorsFileFormat),
+			inv,
+		)
+	}
+	SetValidators(ctx, decoded.Validators)
+	if decoded.Unchanged {
+		return inv, ErrUnchanged
+	}
+	return inv, nil
+}
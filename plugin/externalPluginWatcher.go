@@ -0,0 +1,313 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// reloadDelay is how long the watcher waits for an external plugin's files to
+// stop changing before it reloads the plugin. Editors and installers tend to
+// write a file in several steps (e.g. write a temporary file, then rename it),
+// and the plugin should only be reloaded once.
+var reloadDelay = 500 * time.Millisecond
+
+// ExternalPluginWatcher reloads external plugins when their files change, so
+// that plugins can be added, removed or updated without restarting the Wash
+// server. It watches each plugin's script (and shadow), meta plugin directory
+// or static plugin file. When one changes, the plugin's reloaded from its spec
+// and registered in place of the previous version, whose cached results are
+// cleared (see Registry#ReplacePlugin). A plugin whose script, directory or
+// file is removed is unregistered. A plugin that fails to reload keeps running
+// its previous version. It also watches the directories that plugin scripts
+// are discovered in (see DiscoverExternalPlugins), so that new scripts are
+// loaded.
+type ExternalPluginWatcher struct {
+	registry *Registry
+	config   map[string]map[string]interface{}
+	watcher  *fsnotify.Watcher
+	// discoveryDirs is the set of watched discovery directories
+	discoveryDirs map[string]bool
+
+	// specs and dirs are guarded by mux since discovered plugins are added
+	// to them. dirs maps each watched directory to the indexes of the specs
+	// whose files are in it.
+	mux     sync.Mutex
+	specs   []ExternalPluginSpec
+	dirs    map[string][]int
+	pending map[int]*time.Timer
+	reloads sync.WaitGroup
+	doneCh  chan struct{}
+}
+
+// WatchExternalPlugins starts watching the files of the external plugins
+// with the given specs, and reloads them into r when they change. It also
+// watches discoveryDirs, and loads the plugin scripts that are added to them.
+// config contains each plugin's config, keyed by the plugin's name. Call Stop
+// to stop watching.
+func WatchExternalPlugins(r *Registry, specs []ExternalPluginSpec, discoveryDirs []string, config map[string]map[string]interface{}) (*ExternalPluginWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("could not create the external plugin watcher: %v", err)
+	}
+	w := &ExternalPluginWatcher{
+		registry:      r,
+		config:        config,
+		watcher:       watcher,
+		discoveryDirs: make(map[string]bool),
+		dirs:          make(map[string][]int),
+		pending:       make(map[int]*time.Timer),
+		doneCh:        make(chan struct{}),
+	}
+	for _, spec := range specs {
+		w.addSpec(spec)
+	}
+	for _, dir := range discoveryDirs {
+		dir = filepath.Clean(expandPath(dir))
+		if w.discoveryDirs[dir] {
+			continue
+		}
+		if _, ok := w.dirs[dir]; !ok {
+			if err := watcher.Add(dir); err != nil {
+				if !os.IsNotExist(err) {
+					log.Warnf("Could not watch %v for new external plugins: %v", dir, err)
+				}
+				continue
+			}
+		}
+		w.discoveryDirs[dir] = true
+	}
+	go w.watch()
+	return w, nil
+}
+
+// addSpec starts watching the spec's files. It returns the spec's index. It
+// must be called with w.mux held once the watcher's started.
+func (w *ExternalPluginWatcher) addSpec(spec ExternalPluginSpec) int {
+	i := len(w.specs)
+	w.specs = append(w.specs, spec)
+	for _, dir := range spec.watchedDirs() {
+		if _, ok := w.dirs[dir]; !ok && !w.discoveryDirs[dir] {
+			if err := w.watcher.Add(dir); err != nil {
+				// The plugin can still be reloaded if its other files
+				// change
+				log.Warnf("Could not watch %v for changes to the %v plugin: %v", dir, spec.Name(), err)
+				continue
+			}
+		}
+		w.dirs[dir] = append(w.dirs[dir], i)
+	}
+	return i
+}
+
+// watchedDirs returns the directories that contain the plugin's files. A meta
+// plugin's directory is watched instead of its parent so that its nested
+// plugins are reloaded when scripts are added to (or removed from) it.
+func (s ExternalPluginSpec) watchedDirs() []string {
+	if s.Dir != "" {
+		return []string{filepath.Clean(s.Dir)}
+	}
+	dirs := []string{filepath.Dir(filepath.Clean(s.Path()))}
+	if s.Shadow != "" {
+		if shadowDir := filepath.Dir(filepath.Clean(s.Shadow)); shadowDir != dirs[0] {
+			dirs = append(dirs, shadowDir)
+		}
+	}
+	return dirs
+}
+
+// isAffectedBy returns true if a change to the file at path affects the plugin
+func (s ExternalPluginSpec) isAffectedBy(path string) bool {
+	path = filepath.Clean(path)
+	if s.Dir != "" {
+		dir := filepath.Clean(s.Dir)
+		return path == dir || filepath.Dir(path) == dir
+	}
+	return path == filepath.Clean(s.Path()) || (s.Shadow != "" && path == filepath.Clean(s.Shadow))
+}
+
+func (w *ExternalPluginWatcher) watch() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod && !w.isScriptEvent(event.Name) {
+				continue
+			}
+			w.onChange(event.Name)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warnf("External plugin watcher: %v", err)
+		case <-w.doneCh:
+			return
+		}
+	}
+}
+
+// isScriptEvent returns true if path is one of the watched scripts. Chmods are
+// only relevant for scripts since they can make a script (non-)executable.
+func (w *ExternalPluginWatcher) isScriptEvent(path string) bool {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.discoveryDirs[filepath.Dir(filepath.Clean(path))] {
+		// A chmod can make a new script executable
+		return true
+	}
+	for _, spec := range w.specs {
+		if spec.File == "" && spec.isAffectedBy(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// onChange schedules the reloads of the plugins that are affected by a change
+// to the file at path. A reload that's already scheduled is pushed back so
+// that the plugin's only reloaded once its files stop changing.
+func (w *ExternalPluginWatcher) onChange(path string) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	select {
+	case <-w.doneCh:
+		return
+	default:
+	}
+	affected := false
+	for _, dir := range []string{filepath.Clean(path), filepath.Dir(filepath.Clean(path))} {
+		for _, i := range w.dirs[dir] {
+			if !w.specs[i].isAffectedBy(path) {
+				continue
+			}
+			affected = true
+			w.scheduleReload(i)
+		}
+	}
+	if !affected && w.discoveryDirs[filepath.Dir(filepath.Clean(path))] {
+		w.discover(path)
+	}
+}
+
+// discover starts watching the plugin script at path, which was added to one
+// of the discovery directories, and schedules its load. Scripts that are
+// named like an already registered plugin are skipped, like they are by
+// DiscoverExternalPlugins. It must be called with w.mux held.
+func (w *ExternalPluginWatcher) discover(path string) {
+	spec, ok := discoveredExternalPlugin(path)
+	if !ok {
+		return
+	}
+	for _, other := range w.specs {
+		if other.Name() == spec.Name() {
+			// The other spec's either this script, which is already watched,
+			// or a configured plugin, which takes precedence
+			return
+		}
+	}
+	if _, ok := w.registry.Plugins()[spec.Name()]; ok {
+		log.Warnf("Skipping the discovered plugin %v: the %v plugin is already loaded", path, spec.Name())
+		return
+	}
+	log.Infof("Discovered the %v plugin at %v", spec.Name(), path)
+	w.scheduleReload(w.addSpec(spec))
+}
+
+// scheduleReload schedules the reload of the plugin whose spec is at index i.
+// A reload that's already scheduled is pushed back. It must be called with
+// w.mux held.
+func (w *ExternalPluginWatcher) scheduleReload(i int) {
+	if timer, ok := w.pending[i]; ok && timer.Stop() {
+		timer.Reset(reloadDelay)
+		return
+	}
+	var timer *time.Timer
+	w.reloads.Add(1)
+	timer = time.AfterFunc(reloadDelay, func() {
+		defer w.reloads.Done()
+		w.mux.Lock()
+		if w.pending[i] == timer {
+			delete(w.pending, i)
+		}
+		spec := w.specs[i]
+		w.mux.Unlock()
+		select {
+		case <-w.doneCh:
+		default:
+			w.reload(spec)
+		}
+	})
+	w.pending[i] = timer
+}
+
+// reload reloads the plugin with the given spec
+func (w *ExternalPluginWatcher) reload(spec ExternalPluginSpec) {
+	name := spec.Name()
+	root, err := spec.Load()
+	if err != nil {
+		if _, statErr := os.Stat(spec.Path()); os.IsNotExist(statErr) {
+			if w.registry.UnregisterPlugin(name) {
+				log.Infof("Unloaded the %v plugin since %v was removed", name, spec.Path())
+				setPluginStatus(PluginStatus{
+					Name:         name,
+					Status:       PluginSkipped,
+					Reason:       fmt.Sprintf("%v was removed", spec.Path()),
+					Requirements: spec.Requires,
+				})
+			}
+			return
+		}
+		log.Warnf("%v failed to reload: %+v", spec.Path(), err)
+		w.recordFailure(name, PluginFailed, fmt.Sprintf("%+v", err), spec.Requires)
+		return
+	}
+
+	status := PluginStatus{Name: name, Requirements: requirementsOf(root)}
+	if reason := status.Requirements.unmetHostRequirement(); reason != "" {
+		log.Warnf("%v failed to reload: %v", name, reason)
+		w.recordFailure(name, PluginSkipped, reason, status.Requirements)
+		return
+	}
+	if err := w.registry.ReplacePlugin(root, w.config[name]); err != nil {
+		log.Warnf("%v failed to reload, so its previous version is still loaded: %+v", name, err)
+		w.recordFailure(name, PluginFailed, fmt.Sprintf("%+v", err), status.Requirements)
+		return
+	}
+	log.Infof("Reloaded the %v plugin", name)
+	status.Status = PluginLoaded
+	setPluginStatus(status)
+}
+
+// recordFailure records why the named plugin failed to (re)load in its status.
+// Plugins that are still running their previous version keep their status.
+func (w *ExternalPluginWatcher) recordFailure(name string, status string, reason string, requirements Requirements) {
+	if _, ok := w.registry.Plugins()[name]; ok {
+		return
+	}
+	setPluginStatus(PluginStatus{Name: name, Status: status, Reason: reason, Requirements: requirements})
+}
+
+// Stop stops watching the external plugins. It waits for any in-progress
+// reloads to finish.
+func (w *ExternalPluginWatcher) Stop() {
+	w.mux.Lock()
+	close(w.doneCh)
+	for i, timer := range w.pending {
+		if timer.Stop() {
+			w.reloads.Done()
+		}
+		delete(w.pending, i)
+	}
+	w.mux.Unlock()
+	if err := w.watcher.Close(); err != nil {
+		log.Warnf("Could not stop the external plugin watcher: %v", err)
+	}
+	w.reloads.Wait()
+}
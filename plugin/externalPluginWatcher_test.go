@@ -0,0 +1,219 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalPluginWatcherTestSuite struct {
+	suite.Suite
+	origReloadDelay time.Duration
+	dir             string
+	registry        *Registry
+}
+
+func (suite *ExternalPluginWatcherTestSuite) SetupTest() {
+	SetTestCache(datastore.NewMemCache())
+	suite.origReloadDelay = reloadDelay
+	reloadDelay = 10 * time.Millisecond
+	suite.dir = suite.T().TempDir()
+	suite.registry = NewRegistry()
+}
+
+func (suite *ExternalPluginWatcherTestSuite) TearDownTest() {
+	UnsetTestCache()
+	reloadDelay = suite.origReloadDelay
+}
+
+func (suite *ExternalPluginWatcherTestSuite) writeScript(path string) {
+	suite.NoError(ioutil.WriteFile(path, []byte("#!/bin/sh\necho '{}'\n"), 0755))
+}
+
+func (suite *ExternalPluginWatcherTestSuite) register(spec ExternalPluginSpec) Root {
+	root, err := spec.Load()
+	if suite.NoError(err) {
+		suite.NoError(suite.registry.RegisterPlugin(root, nil))
+	}
+	return root
+}
+
+func (suite *ExternalPluginWatcherTestSuite) watch(specs ...ExternalPluginSpec) *ExternalPluginWatcher {
+	return suite.watchWith(specs, nil)
+}
+
+func (suite *ExternalPluginWatcherTestSuite) watchWith(specs []ExternalPluginSpec, discoveryDirs []string) *ExternalPluginWatcher {
+	w, err := WatchExternalPlugins(suite.registry, specs, discoveryDirs, nil)
+	if !suite.NoError(err) {
+		suite.FailNow("could not watch the plugins")
+	}
+	return w
+}
+
+// eventually returns true if cond becomes true within a few seconds
+func eventually(cond func() bool) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+func (suite *ExternalPluginWatcherTestSuite) TestUpdatedScriptsAreReloaded() {
+	path := filepath.Join(suite.dir, "mycloud.sh")
+	suite.writeScript(path)
+	spec := ExternalPluginSpec{Script: path}
+	old := suite.register(spec)
+	w := suite.watch(spec)
+	defer w.Stop()
+
+	suite.writeScript(path)
+	suite.True(eventually(func() bool {
+		root, ok := suite.registry.Plugins()["mycloud"]
+		return ok && root != old
+	}))
+}
+
+func (suite *ExternalPluginWatcherTestSuite) TestRemovedScriptsAreUnregistered() {
+	path := filepath.Join(suite.dir, "mycloud.sh")
+	suite.writeScript(path)
+	spec := ExternalPluginSpec{Script: path}
+	suite.register(spec)
+	w := suite.watch(spec)
+	defer w.Stop()
+
+	suite.NoError(os.Remove(path))
+	suite.True(eventually(func() bool {
+		_, ok := suite.registry.Plugins()["mycloud"]
+		return !ok
+	}))
+	// The status is updated after the plugin's unregistered
+	var status PluginStatus
+	suite.True(eventually(func() bool {
+		for _, s := range PluginStatuses() {
+			if s.Name == "mycloud" {
+				status = s
+			}
+		}
+		return status.Status == PluginSkipped
+	}))
+	suite.Equal(path+" was removed", status.Reason)
+
+	// The plugin's registered again once it's restored
+	suite.writeScript(path)
+	suite.True(eventually(func() bool {
+		_, ok := suite.registry.Plugins()["mycloud"]
+		return ok
+	}))
+}
+
+func (suite *ExternalPluginWatcherTestSuite) TestBrokenScriptsKeepThePreviousVersion() {
+	path := filepath.Join(suite.dir, "mycloud.sh")
+	suite.writeScript(path)
+	spec := ExternalPluginSpec{Script: path}
+	old := suite.register(spec)
+	w := suite.watch(spec)
+	defer w.Stop()
+
+	suite.NoError(ioutil.WriteFile(path, []byte("#!/bin/sh\nexit 1\n"), 0755))
+	time.Sleep(10 * reloadDelay)
+	suite.True(suite.registry.Plugins()["mycloud"] == old)
+}
+
+func (suite *ExternalPluginWatcherTestSuite) TestScriptsAddedToAMetaPluginAreLoaded() {
+	metaDir := filepath.Join(suite.dir, "meta")
+	suite.NoError(os.Mkdir(metaDir, 0755))
+	suite.writeScript(filepath.Join(metaDir, "a.sh"))
+	spec := ExternalPluginSpec{Dir: metaDir}
+	suite.register(spec)
+	w := suite.watch(spec)
+	defer w.Stop()
+
+	suite.writeScript(filepath.Join(metaDir, "b.sh"))
+	suite.True(eventually(func() bool {
+		root, ok := suite.registry.Plugins()["meta"].(*externalPluginMetaRoot)
+		return ok && len(root.nestedRoots) == 2
+	}))
+}
+
+func (suite *ExternalPluginWatcherTestSuite) TestUnrelatedFilesAreIgnored() {
+	path := filepath.Join(suite.dir, "mycloud.sh")
+	suite.writeScript(path)
+	spec := ExternalPluginSpec{Script: path}
+	old := suite.register(spec)
+	w := suite.watch(spec)
+	defer w.Stop()
+
+	suite.writeScript(filepath.Join(suite.dir, "other.sh"))
+	time.Sleep(10 * reloadDelay)
+	suite.True(suite.registry.Plugins()["mycloud"] == old)
+}
+
+func pluginStatusOf(name string) PluginStatus {
+	for _, s := range PluginStatuses() {
+		if s.Name == name {
+			return s
+		}
+	}
+	return PluginStatus{}
+}
+
+func (suite *ExternalPluginWatcherTestSuite) TestFailedPluginsAreLoadedOnceTheyreFixed() {
+	path := filepath.Join(suite.dir, "mycloud.sh")
+	suite.NoError(ioutil.WriteFile(path, []byte("#!/bin/sh\necho '{}'\n"), 0644))
+	spec := ExternalPluginSpec{Script: path}
+	_, err := spec.Load()
+	if !suite.Error(err) {
+		return
+	}
+	suite.registry.RegisterPlugins(map[string]Root{"mycloud": FailedExternalPlugin("mycloud", err)}, nil)
+	status := pluginStatusOf("mycloud")
+	suite.Equal(PluginFailed, status.Status)
+	suite.Contains(status.Reason, err.Error())
+	suite.NotContains(suite.registry.Plugins(), "mycloud")
+
+	w := suite.watch(spec)
+	defer w.Stop()
+	suite.NoError(os.Chmod(path, 0755))
+	suite.True(eventually(func() bool {
+		_, ok := suite.registry.Plugins()["mycloud"]
+		return ok
+	}))
+	suite.Equal(PluginLoaded, pluginStatusOf("mycloud").Status)
+}
+
+func (suite *ExternalPluginWatcherTestSuite) TestScriptsAddedToADiscoveryDirAreLoaded() {
+	w := suite.watchWith(nil, []string{suite.dir})
+	defer w.Stop()
+
+	path := filepath.Join(suite.dir, "mycloud.sh")
+	suite.writeScript(path)
+	suite.True(eventually(func() bool {
+		_, ok := suite.registry.Plugins()["mycloud"]
+		return ok
+	}))
+
+	// Hidden files and non-executables aren't plugins
+	suite.writeScript(filepath.Join(suite.dir, ".hidden.sh"))
+	suite.NoError(ioutil.WriteFile(filepath.Join(suite.dir, "README"), []byte("docs"), 0644))
+	time.Sleep(10 * reloadDelay)
+	suite.Len(suite.registry.Plugins(), 1)
+
+	// Discovered plugins are unloaded when they're removed, like any other
+	// external plugin
+	suite.NoError(os.Remove(path))
+	suite.True(eventually(func() bool {
+		_, ok := suite.registry.Plugins()["mycloud"]
+		return !ok
+	}))
+}
+
+func TestExternalPluginWatcher(t *testing.T) {
+	suite.Run(t, new(ExternalPluginWatcherTestSuite))
+}
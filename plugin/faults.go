@@ -0,0 +1,193 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+)
+
+// FaultRule injects a fault into the list, read, or metadata calls on the
+// entries whose path matches Path. It's meant for testing how Wash (e.g. its
+// FUSE filesystem, cache, and CLI) behaves when a plugin's backend misbehaves.
+// Faults are injected into the calls to the plugin, so cached results are
+// unaffected until they expire.
+type FaultRule struct {
+	// Path is a glob (see path.Match). The rule also applies to the
+	// descendants of the entries that it matches.
+	Path string `json:"path"`
+	// Actions are the affected actions (list, read, or metadata). If empty,
+	// then all of them are affected.
+	Actions []string `json:"actions,omitempty"`
+	// Probability is the probability that a matching call is affected. 0
+	// means that every matching call is affected.
+	Probability float64 `json:"probability,omitempty"`
+	// DelayMS delays the call by that many milliseconds.
+	DelayMS int `json:"delay_ms,omitempty" mapstructure:"delay_ms"`
+	// Error fails the call with an error containing that message.
+	Error string `json:"error,omitempty"`
+	// Truncate drops half of the call's result, i.e. half of the listed
+	// entries, half of the content, or half of the metadata's keys.
+	Truncate bool `json:"truncate,omitempty"`
+}
+
+var faultActions = []string{"list", "read", "metadata"}
+
+func (r FaultRule) validate() error {
+	if r.Path == "" {
+		return fmt.Errorf("the path must be provided")
+	}
+	if _, err := path.Match(r.Path, "/"); err != nil {
+		return fmt.Errorf("invalid path %v: %v", r.Path, err)
+	}
+	for _, action := range r.Actions {
+		if !containsString(faultActions, action) {
+			return fmt.Errorf("invalid action %v; faults can be injected into %v", action, strings.Join(faultActions, ", "))
+		}
+	}
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("the probability must be between 0 and 1, not %v", r.Probability)
+	}
+	if r.DelayMS < 0 {
+		return fmt.Errorf("the delay must be non-negative, not %v", r.DelayMS)
+	}
+	if r.DelayMS == 0 && r.Error == "" && !r.Truncate {
+		return fmt.Errorf("the rule for %v must delay, error, or truncate", r.Path)
+	}
+	return nil
+}
+
+// matches returns true if the rule applies to the given action on the entry
+// with the given ID
+func (r FaultRule) matches(id string, action string) bool {
+	if len(r.Actions) > 0 && !containsString(r.Actions, action) {
+		return false
+	}
+	for p := id; ; p = path.Dir(p) {
+		if matched, _ := path.Match(r.Path, p); matched {
+			return true
+		}
+		if p == "/" || p == "." || p == "" {
+			return false
+		}
+	}
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+var faultRulesMux sync.RWMutex
+var faultRules []FaultRule
+
+// faultRand is stubbed by the tests
+var faultRand = rand.Float64
+
+// SetFaultRules replaces the fault injection rules. An empty rules disables
+// fault injection.
+func SetFaultRules(rules []FaultRule) error {
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+	faultRulesMux.Lock()
+	defer faultRulesMux.Unlock()
+	faultRules = append([]FaultRule(nil), rules...)
+	return nil
+}
+
+// FaultRules returns the fault injection rules
+func FaultRules() []FaultRule {
+	faultRulesMux.RLock()
+	defer faultRulesMux.RUnlock()
+	return append([]FaultRule{}, faultRules...)
+}
+
+// faultFor returns the first rule that applies to the given action on the
+// entry with the given ID
+func faultFor(id string, action string) (FaultRule, bool) {
+	faultRulesMux.RLock()
+	defer faultRulesMux.RUnlock()
+	for _, rule := range faultRules {
+		if !rule.matches(id, action) {
+			continue
+		}
+		if rule.Probability > 0 && faultRand() >= rule.Probability {
+			continue
+		}
+		return rule, true
+	}
+	return FaultRule{}, false
+}
+
+// faultyOp wraps op so that the matching fault rule (if any) is injected into
+// it
+func faultyOp(opCode defaultOpCode, entry Entry, op func(context.Context) (interface{}, error)) func(context.Context) (interface{}, error) {
+	action := actionOf(opCode)
+	return func(ctx context.Context) (interface{}, error) {
+		rule, ok := faultFor(entry.id(), action)
+		if !ok {
+			return op(ctx)
+		}
+		activity.Record(ctx, "Injecting a fault into %v on %v: %+v", action, entry.id(), rule)
+		if rule.DelayMS > 0 {
+			select {
+			case <-time.After(time.Duration(rule.DelayMS) * time.Millisecond):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if rule.Error != "" {
+			return nil, fmt.Errorf("injected fault: %v", rule.Error)
+		}
+		result, err := op(ctx)
+		if err != nil || !rule.Truncate {
+			return result, err
+		}
+		return truncateResult(result), nil
+	}
+}
+
+func truncateResult(result interface{}) interface{} {
+	switch r := result.(type) {
+	case map[string]Entry:
+		cnames := make([]string, 0, len(r))
+		for cname := range r {
+			cnames = append(cnames, cname)
+		}
+		sort.Strings(cnames)
+		truncated := make(map[string]Entry)
+		for _, cname := range cnames[:len(cnames)/2] {
+			truncated[cname] = r[cname]
+		}
+		return truncated
+	case SizedReader:
+		return io.NewSectionReader(r, 0, r.Size()/2)
+	case JSONObject:
+		keys := make([]string, 0, len(r))
+		for key := range r {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		truncated := make(JSONObject)
+		for _, key := range keys[:len(keys)/2] {
+			truncated[key] = r[key]
+		}
+		return truncated
+	default:
+		return result
+	}
+}
@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FaultsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *FaultsTestSuite) SetupTest() {
+	faultRand = func() float64 { return 0 }
+}
+
+func (suite *FaultsTestSuite) TearDownTest() {
+	suite.NoError(SetFaultRules(nil))
+	faultRand = rand.Float64
+}
+
+func (suite *FaultsTestSuite) newEntry(id string) Entry {
+	e := newCacheTestsMockEntry("foo")
+	e.SetTestID(id)
+	return e
+}
+
+func (suite *FaultsTestSuite) TestSetFaultRules_Validates() {
+	suite.Regexp("path must be provided", SetFaultRules([]FaultRule{{Error: "boom"}}))
+	suite.Regexp("invalid path", SetFaultRules([]FaultRule{{Path: "[", Error: "boom"}}))
+	suite.Regexp("invalid action exec", SetFaultRules([]FaultRule{{Path: "/a", Actions: []string{"exec"}, Error: "boom"}}))
+	suite.Regexp("probability", SetFaultRules([]FaultRule{{Path: "/a", Probability: 2, Error: "boom"}}))
+	suite.Regexp("must delay, error, or truncate", SetFaultRules([]FaultRule{{Path: "/a"}}))
+	suite.Empty(FaultRules())
+
+	rules := []FaultRule{{Path: "/a/*", Error: "boom"}}
+	suite.NoError(SetFaultRules(rules))
+	suite.Equal(rules, FaultRules())
+}
+
+func (suite *FaultsTestSuite) TestMatches() {
+	rule := FaultRule{Path: "/docker/containers/*"}
+	suite.True(rule.matches("/docker/containers/foo", "list"))
+	suite.True(rule.matches("/docker/containers/foo/fs/etc", "read"))
+	suite.False(rule.matches("/docker/containers", "list"))
+	suite.False(rule.matches("/docker/volumes/foo", "list"))
+
+	rule.Actions = []string{"read"}
+	suite.False(rule.matches("/docker/containers/foo", "list"))
+	suite.True(rule.matches("/docker/containers/foo", "read"))
+}
+
+func (suite *FaultsTestSuite) TestFaultyOp_Error() {
+	suite.NoError(SetFaultRules([]FaultRule{{Path: "/a", Actions: []string{"list"}, Error: "boom"}}))
+	called := false
+	op := faultyOp(ListOp, suite.newEntry("/a/b"), func(context.Context) (interface{}, error) {
+		called = true
+		return map[string]Entry{}, nil
+	})
+	_, err := op(context.Background())
+	suite.EqualError(err, "injected fault: boom")
+	suite.False(called)
+
+	// Other actions aren't affected
+	op = faultyOp(MetadataOp, suite.newEntry("/a/b"), func(context.Context) (interface{}, error) {
+		return JSONObject{}, nil
+	})
+	_, err = op(context.Background())
+	suite.NoError(err)
+}
+
+func (suite *FaultsTestSuite) TestFaultyOp_Probability() {
+	suite.NoError(SetFaultRules([]FaultRule{{Path: "/a", Probability: 0.5, Error: "boom"}}))
+	op := faultyOp(ListOp, suite.newEntry("/a"), func(context.Context) (interface{}, error) {
+		return map[string]Entry{}, nil
+	})
+	faultRand = func() float64 { return 0.7 }
+	_, err := op(context.Background())
+	suite.NoError(err)
+	faultRand = func() float64 { return 0.2 }
+	_, err = op(context.Background())
+	suite.Error(err)
+}
+
+func (suite *FaultsTestSuite) TestFaultyOp_Delay() {
+	suite.NoError(SetFaultRules([]FaultRule{{Path: "/a", DelayMS: 50}}))
+	op := faultyOp(OpenOp, suite.newEntry("/a"), func(context.Context) (interface{}, error) {
+		return strings.NewReader("content"), nil
+	})
+	start := time.Now()
+	_, err := op(context.Background())
+	suite.NoError(err)
+	suite.True(time.Since(start) >= 50*time.Millisecond)
+
+	// Cancelling the context aborts the delay
+	suite.NoError(SetFaultRules([]FaultRule{{Path: "/a", DelayMS: 60000}}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = op(ctx)
+	suite.Equal(context.DeadlineExceeded, err)
+}
+
+func (suite *FaultsTestSuite) TestFaultyOp_Truncate() {
+	suite.NoError(SetFaultRules([]FaultRule{{Path: "/a", Truncate: true}}))
+	entry := suite.newEntry("/a")
+
+	list := faultyOp(ListOp, entry, func(context.Context) (interface{}, error) {
+		return map[string]Entry{"a": entry, "b": entry, "c": entry, "d": entry}, nil
+	})
+	result, err := list(context.Background())
+	if suite.NoError(err) {
+		suite.Equal(map[string]Entry{"a": entry, "b": entry}, result)
+	}
+
+	read := faultyOp(OpenOp, entry, func(context.Context) (interface{}, error) {
+		return strings.NewReader("abcdef"), nil
+	})
+	result, err = read(context.Background())
+	if suite.NoError(err) {
+		rdr := result.(SizedReader)
+		suite.Equal(int64(3), rdr.Size())
+		content, err := ioutil.ReadAll(io.NewSectionReader(rdr, 0, rdr.Size()))
+		suite.NoError(err)
+		suite.Equal("abc", string(content))
+	}
+
+	metadata := faultyOp(MetadataOp, entry, func(context.Context) (interface{}, error) {
+		return JSONObject{"a": 1, "b": 2}, nil
+	})
+	result, err = metadata(context.Background())
+	if suite.NoError(err) {
+		suite.Equal(JSONObject{"a": 1}, result)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	suite.Run(t, new(FaultsTestSuite))
+}
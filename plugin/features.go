@@ -0,0 +1,29 @@
+package plugin
+
+import "github.com/puppetlabs/wash/features"
+
+// streamingListFlag gates streaming lists (see StreamingLister)
+var streamingListFlag = features.Register(
+	"plugin.streaming_list",
+	"Streams the children of parents that support streaming lists (e.g. external plugin entries that set streaming_list) to API clients that stream the listing, instead of waiting for the whole listing.",
+	false,
+)
+
+// FeatureEnabled returns true if the feature flag is enabled for the entry's
+// plugin. Use it to gate experimental behaviors so that they can be enabled
+// for specific plugins, e.g.
+//
+//	var streamingList = features.Register("streaming_list", ...)
+//	...
+//	if plugin.FeatureEnabled(streamingList, e) { ... }
+func FeatureEnabled(f *features.Flag, e Entry) bool {
+	return f.Enabled(pluginName(e))
+}
+
+// SupportsStreamingList returns true if the entry's children are streamed
+// when it's listed via StreamingList, i.e. if it supports streaming lists and
+// the plugin.streaming_list flag's enabled for its plugin.
+func SupportsStreamingList(e Entry) bool {
+	sl, ok := e.(StreamingLister)
+	return ok && sl.SupportsStreamingList() && FeatureEnabled(streamingListFlag, e)
+}
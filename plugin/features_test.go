@@ -0,0 +1,30 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/puppetlabs/wash/features"
+	"github.com/stretchr/testify/suite"
+)
+
+type FeaturesTestSuite struct {
+	suite.Suite
+}
+
+func (suite *FeaturesTestSuite) TestFeatureEnabled() {
+	f := features.Register("plugin_tests.feature", "", false)
+	foo := newCacheTestsMockEntry("foo")
+	foo.SetTestID("/foo/bar")
+	baz := newCacheTestsMockEntry("baz")
+	baz.SetTestID("/baz/bar")
+	suite.False(FeatureEnabled(f, foo))
+
+	_, err := features.Set(f.Name(), "foo", true)
+	suite.NoError(err)
+	suite.True(FeatureEnabled(f, foo))
+	suite.False(FeatureEnabled(f, baz))
+}
+
+func TestFeatures(t *testing.T) {
+	suite.Run(t, new(FeaturesTestSuite))
+}
@@ -2,6 +2,7 @@ package plugin
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -12,20 +13,35 @@ func FindEntry(ctx context.Context, start Entry, segments []string) (Entry, erro
 	for _, segment := range segments {
 		switch curParent := start.(type) {
 		case Parent:
+			notFound := func() error {
+				reason := fmt.Sprintf("The %v entry does not exist", segment)
+				if len(visitedSegments) != 0 {
+					reason += fmt.Sprintf(" in the %v parent", strings.Join(visitedSegments, "/"))
+				}
+				return errors.New(reason)
+			}
+			// Skip listing the parent if the entry was recently found missing
+			if IsKnownMissing(curParent, segment) {
+				return nil, notFound()
+			}
+
 			// Get the entries via. List()
-			entries, err := CachedList(ctx, curParent)
+			entries, err := partitionedList(ctx, curParent)
 			if err != nil {
 				return nil, err
 			}
 
-			// Search for the specific entry
+			// Search for the specific entry. A partitioned parent's children
+			// can also be found via their flat path.
 			entry, ok := entries[segment]
 			if !ok {
-				reason := fmt.Sprintf("The %v entry does not exist", segment)
-				if len(visitedSegments) != 0 {
-					reason += fmt.Sprintf(" in the %v parent", strings.Join(visitedSegments, "/"))
+				if flatEntries, err := CachedList(ctx, curParent); err == nil {
+					entry, ok = flatEntries[segment]
 				}
-				return nil, fmt.Errorf(reason)
+			}
+			if !ok {
+				RecordMissing(curParent, segment)
+				return nil, notFound()
 			}
 
 			start = entry
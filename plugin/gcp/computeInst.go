@@ -36,9 +36,29 @@ func newComputeInstance(inst *compute.Instance, c computeProjectService) *comput
 	comp.
 		DisableCachingFor(plugin.MetadataOp).
 		Attributes().SetMeta(inst)
+	if lifecycle, ok := computeInstanceLifecycle(inst.Status); ok {
+		comp.Attributes().SetLifecycle(lifecycle)
+	}
 	return comp
 }
 
+func computeInstanceLifecycle(status string) (plugin.Lifecycle, bool) {
+	switch status {
+	case "PROVISIONING", "STAGING":
+		return plugin.LifecycleProvisioning, true
+	case "RUNNING":
+		return plugin.LifecycleRunning, true
+	case "STOPPING", "SUSPENDING":
+		return plugin.LifecycleStopping, true
+	case "STOPPED", "SUSPENDED", "TERMINATED":
+		return plugin.LifecycleTerminated, true
+	case "REPAIRING":
+		return plugin.LifecycleError, true
+	default:
+		return "", false
+	}
+}
+
 func (c *computeInstance) List(ctx context.Context) ([]plugin.Entry, error) {
 	metadataJSONFile, err := plugin.NewMetadataJSONFile(ctx, c)
 	if err != nil {
@@ -66,6 +86,11 @@ func (c *computeInstance) ChildSchemas() []*plugin.EntrySchema {
 	}
 }
 
+// HonoredExecOptions implements plugin.ExecOptionHonorer
+func (c *computeInstance) HonoredExecOptions() []string {
+	return transport.SSHExecOptions
+}
+
 func (c *computeInstance) Exec(ctx context.Context, cmd string, args []string,
 	opts plugin.ExecOptions) (plugin.ExecCommand, error) {
 	conf, err := gceSSHFiles()
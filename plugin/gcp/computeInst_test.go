@@ -21,6 +21,21 @@ func TestComputeInstance(t *testing.T) {
 	assert.Implements(t, (*plugin.Execable)(nil), compInst)
 }
 
+func TestComputeInstanceLifecycle(t *testing.T) {
+	inst := compute.Instance{Name: "foo", Status: "STAGING"}
+	attr := newComputeInstance(&inst, computeProjectService{}).Attributes()
+	assert.Equal(t, plugin.LifecycleProvisioning, attr.Lifecycle())
+
+	inst.Status = "STOPPED"
+	attr = newComputeInstance(&inst, computeProjectService{}).Attributes()
+	assert.Equal(t, plugin.LifecycleTerminated, attr.Lifecycle())
+
+	// Unknown statuses are left out rather than guessed at
+	inst.Status = "NEW_STATUS"
+	attr = newComputeInstance(&inst, computeProjectService{}).Attributes()
+	assert.False(t, attr.HasLifecycle())
+}
+
 func TestParseUserAndKey(t *testing.T) {
 	// Exercise generateKeys to create temporary test keys.
 	keyDir, err := ioutil.TempDir("", "computeInstTest_ParseUserAndKey")
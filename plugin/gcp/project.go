@@ -25,19 +25,32 @@ func newProject(p *crm.Project, client *http.Client) *project {
 	return proj
 }
 
-// List all services as dirs.
-func (p *project) List(ctx context.Context) ([]plugin.Entry, error) {
-	comp, err := newComputeDir(p.client, p.id)
-	if err != nil {
-		return nil, err
-	}
+// projectService creates the directory of one of a project's services
+type projectService func(client *http.Client, projID string) (plugin.Entry, error)
 
-	stor, err := newStorageDir(p.client, p.id)
-	if err != nil {
-		return nil, err
-	}
+// projectServices are the services that a project lists, keyed by name.
+// projectServiceNames is their listing order.
+var projectServices = map[string]projectService{
+	"compute": func(client *http.Client, projID string) (plugin.Entry, error) {
+		return newComputeDir(client, projID)
+	},
+	"storage": func(client *http.Client, projID string) (plugin.Entry, error) {
+		return newStorageDir(client, projID)
+	},
+}
+
+var projectServiceNames = []string{"compute", "storage"}
 
-	return []plugin.Entry{comp, stor}, nil
+// List all services as dirs. Services whose clients can't be created are
+// shown as error entries.
+func (p *project) List(ctx context.Context) ([]plugin.Entry, error) {
+	return plugin.ParallelList(ctx, projectServiceNames, func(ctx context.Context, name string) ([]plugin.Entry, error) {
+		dir, err := projectServices[name](p.client, p.id)
+		if err != nil {
+			return nil, err
+		}
+		return []plugin.Entry{dir}, nil
+	})
 }
 
 func (p *project) Schema() *plugin.EntrySchema {
@@ -50,5 +63,6 @@ func (p *project) ChildSchemas() []*plugin.EntrySchema {
 	return []*plugin.EntrySchema{
 		(&computeDir{}).Schema(),
 		(&storageDir{}).Schema(),
+		(&plugin.ErrorEntry{}).Schema(),
 	}
 }
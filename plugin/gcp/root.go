@@ -9,6 +9,7 @@ import (
 
 	"github.com/puppetlabs/wash/activity"
 	"github.com/puppetlabs/wash/plugin"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	crm "google.golang.org/api/cloudresourcemanager/v1"
 	"google.golang.org/api/option"
@@ -29,9 +30,20 @@ func (r *Root) Init(cfg map[string]interface{}) error {
 	r.EntryBase = plugin.NewEntry("gcp")
 	r.SetTTLOf(plugin.ListOp, 1*time.Minute)
 
+	httpOpts, err := plugin.HTTPOptionsFor(cfg)
+	if err != nil {
+		return fmt.Errorf("gcp: %v", err)
+	}
+	httpClient, err := httpOpts.NewClient()
+	if err != nil {
+		return fmt.Errorf("gcp: %v", err)
+	}
+
 	// We use the auto-generated SDK because it's the only one that allows us to list
-	// projects for the current credentials.
-	oauthClient, err := google.DefaultClient(context.Background(), serviceScopes...)
+	// projects for the current credentials. The oauth2 client uses the context's
+	// HTTP client as its base transport.
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
+	oauthClient, err := google.DefaultClient(ctx, serviceScopes...)
 	r.oauthClient = oauthClient
 
 	if projsI, ok := cfg["projects"]; ok {
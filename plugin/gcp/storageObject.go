@@ -43,6 +43,11 @@ func (r *objectReader) ReadAt(p []byte, off int64) (int, error) {
 	return io.ReadFull(rdr, p)
 }
 
+// SupportsPartialReads returns true because ReadAt uses a range reader.
+func (r *objectReader) SupportsPartialReads() bool {
+	return true
+}
+
 func (r *objectReader) Size() int64 {
 	return r.size
 }
@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+)
+
+// HelpCName is the cname of the entry that contains a plugin's help document
+const HelpCName = ".help"
+
+// Documented is implemented by plugin roots that supply a help document, e.g.
+// an overview of the plugin's tree and how to configure it. Wash adds the
+// document to the root's children as the readable HelpCName entry, and
+// 'wash help plugin <name>' prints it.
+//
+// Documented roots should include the HelpEntry's schema in their
+// ChildSchemas, e.g.
+//	return []*plugin.EntrySchema{
+//		(&instancesDir{}).Schema(),
+//		(&plugin.HelpEntry{}).Schema(),
+//	}
+type Documented interface {
+	Root
+	Help() string
+}
+
+// Help returns the root's help document. It's empty if the root isn't
+// Documented.
+func Help(root Root) string {
+	if d, ok := root.(Documented); ok {
+		return d.Help()
+	}
+	return ""
+}
+
+// HelpEntry is the readable entry that contains a plugin's help document
+type HelpEntry struct {
+	EntryBase
+	help string
+}
+
+func newHelpEntry(help string) *HelpEntry {
+	e := &HelpEntry{
+		EntryBase: NewEntry(HelpCName),
+		help:      help,
+	}
+	e.DisableDefaultCaching()
+	e.Attributes().SetSize(uint64(len(help)))
+	return e
+}
+
+// Schema returns the help entry's schema
+func (e *HelpEntry) Schema() *EntrySchema {
+	return NewEntrySchema(e, "help").IsSingleton()
+}
+
+// Open returns the help document
+func (e *HelpEntry) Open(ctx context.Context) (SizedReader, error) {
+	return bytes.NewReader([]byte(e.help)), nil
+}
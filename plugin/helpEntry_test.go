@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/puppetlabs/wash/datastore"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type documentedRoot struct {
+	mockParent
+	help string
+}
+
+func (r *documentedRoot) Init(map[string]interface{}) error {
+	return nil
+}
+
+func (r *documentedRoot) Help() string {
+	return r.help
+}
+
+type HelpEntryTestSuite struct {
+	suite.Suite
+}
+
+func (suite *HelpEntryTestSuite) SetupSuite() {
+	SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *HelpEntryTestSuite) TearDownSuite() {
+	UnsetTestCache()
+}
+
+func (suite *HelpEntryTestSuite) newRoot(help string, entries ...Entry) *documentedRoot {
+	root := &documentedRoot{mockParent: mockParent{EntryBase: NewEntry("docs"), entries: entries}, help: help}
+	root.SetTestID("/docs")
+	root.DisableDefaultCaching()
+	return root
+}
+
+func (suite *HelpEntryTestSuite) TestCachedListIncludesTheHelpEntry() {
+	child := &mockParent{EntryBase: NewEntry("child")}
+	children, err := CachedList(context.Background(), suite.newRoot("Some help\n", child))
+	if !suite.NoError(err) {
+		return
+	}
+	suite.Len(children, 2)
+	help, ok := children[HelpCName].(*HelpEntry)
+	if suite.True(ok) {
+		suite.Equal("/docs/.help", help.id())
+		suite.Equal(uint64(len("Some help\n")), help.Attributes().Size())
+		content, err := help.Open(context.Background())
+		if suite.NoError(err) {
+			bits, err := ioutil.ReadAll(io.NewSectionReader(content, 0, content.Size()))
+			suite.NoError(err)
+			suite.Equal("Some help\n", string(bits))
+		}
+	}
+}
+
+func (suite *HelpEntryTestSuite) TestCachedListOmitsEmptyHelp() {
+	children, err := CachedList(context.Background(), suite.newRoot(""))
+	if suite.NoError(err) {
+		suite.Empty(children)
+	}
+}
+
+func (suite *HelpEntryTestSuite) TestCachedListPrefersThePluginsOwnHelpEntry() {
+	child := &mockParent{EntryBase: NewEntry(HelpCName)}
+	children, err := CachedList(context.Background(), suite.newRoot("Some help", child))
+	if suite.NoError(err) {
+		suite.Len(children, 1)
+		suite.Equal(child, children[HelpCName])
+	}
+}
+
+func (suite *HelpEntryTestSuite) TestHelp() {
+	suite.Equal("Some help", Help(suite.newRoot("Some help")))
+	suite.Equal("", Help(&mockRoot{EntryBase: NewEntry("undocumented")}))
+}
+
+func TestHelpEntry(t *testing.T) {
+	suite.Run(t, new(HelpEntryTestSuite))
+}
@@ -77,6 +77,12 @@ func TrackTime(start time.Time, name string) {
 	log.Infof("%s took %s", name, elapsed)
 }
 
+// TTLOf returns how long the result of the specified op on the entry is
+// cached for. It's negative if caching is disabled for the op.
+func TTLOf(e Entry, op defaultOpCode) time.Duration {
+	return e.getTTLOf(op)
+}
+
 // Attributes returns the entry's attributes. If size is unknown, it will check whether the entry
 // has locally cached content and if so set that for the size.
 func Attributes(e Entry) EntryAttributes {
@@ -101,3 +107,33 @@ func Attributes(e Entry) EntryAttributes {
 func IsPrefetched(e Entry) bool {
 	return e.isPrefetched()
 }
+
+// IsCached returns true if the result of the specified op on the entry is
+// cached, i.e. if the next call to the op won't invoke the plugin. Cached
+// errors count as cached results.
+func IsCached(e Entry, op defaultOpCode) bool {
+	if cache == nil || TTLOf(e, op) < 0 {
+		return false
+	}
+	val, err := cache.Get(defaultOpCodeToNameMap[op], e.id())
+	return val != nil || err != nil
+}
+
+// ExternalPluginInfo describes the script that implements an external
+// plugin's entry
+type ExternalPluginInfo struct {
+	Script string
+	// Version is the plugin's version. It's empty if the plugin doesn't
+	// declare one.
+	Version         string
+	ProtocolVersion int
+}
+
+// ExternalPluginInfoOf returns the info of the entry's external plugin. The
+// returned bool is false if the entry doesn't belong to an external plugin.
+func ExternalPluginInfoOf(e Entry) (ExternalPluginInfo, bool) {
+	if ext, ok := e.(interface{ externalPluginInfo() ExternalPluginInfo }); ok {
+		return ext.externalPluginInfo(), true
+	}
+	return ExternalPluginInfo{}, false
+}
@@ -1,6 +1,8 @@
 package plugin
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -80,6 +82,88 @@ func (suite *HelpersTestSuite) TestPrefetched() {
 	suite.True(IsPrefetched(e))
 }
 
+func (suite *HelpersTestSuite) TestIsCached() {
+	e := newHelpersTestsMockEntry("mockEntry")
+	e.SetTestID("/mockEntry")
+	metadata := func(context.Context) (interface{}, error) {
+		return JSONObject{"state": "running"}, nil
+	}
+	suite.False(IsCached(e, MetadataOp))
+
+	// Results aren't cached if caching's disabled
+	_, err := cachedDefaultOp(context.Background(), MetadataOp, e, metadata)
+	suite.NoError(err)
+	suite.False(IsCached(e, MetadataOp))
+
+	e.SetTTLOf(MetadataOp, 15*time.Second)
+	_, err = cachedDefaultOp(context.Background(), MetadataOp, e, metadata)
+	suite.NoError(err)
+	suite.True(IsCached(e, MetadataOp))
+	suite.False(IsCached(e, OpenOp))
+}
+
+func (suite *HelpersTestSuite) TestExternalPluginInfoOf() {
+	_, ok := ExternalPluginInfoOf(newHelpersTestsMockEntry("mockEntry"))
+	suite.False(ok)
+
+	root := &externalPluginRoot{externalPluginEntry: &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		script:    &mockExternalPluginScript{path: "plugin_script"},
+	}}
+	info, ok := ExternalPluginInfoOf(root)
+	if suite.True(ok) {
+		suite.Equal(ExternalPluginInfo{Script: "plugin_script", ProtocolVersion: 1}, info)
+	}
+}
+
+func (suite *HelpersTestSuite) TestCreateChild() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	parent := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		methods:   map[string]interface{}{"list": nil, "create": nil},
+		script:    mockScript,
+	}
+	parent.SetTestID("/foo")
+	ctx := context.Background()
+	mockCreate := func(name string, kind string, stdout string) {
+		mockScript.OnInvokeAndWait(ctx, "create", parent, name, kind).Return(mockInvocation([]byte(stdout)), nil).Once()
+	}
+
+	mockCreate("bar", "dir", `{"name":"bar","methods":["list"]}`)
+	child, err := CreateChild(ctx, parent, "bar", true)
+	if suite.NoError(err) {
+		suite.Equal("/foo/bar", ID(child))
+	}
+
+	// The child must match the request
+	mockCreate("bar", "file", `{"name":"baz","methods":["read"]}`)
+	_, err = CreateChild(ctx, parent, "bar", false)
+	suite.EqualError(err, "the creation of bar in /foo returned the child baz instead")
+	mockCreate("bar", "dir", `{"name":"bar","methods":["read"]}`)
+	_, err = CreateChild(ctx, parent, "bar", true)
+	suite.EqualError(err, "the creation of bar in /foo returned a child that isn't a parent")
+	mockScript.AssertExpectations(suite.T())
+}
+
+func (suite *HelpersTestSuite) TestRename() {
+	mockScript := &mockExternalPluginScript{path: "plugin_script"}
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("foo"),
+		methods:   map[string]interface{}{"rename": nil},
+		script:    mockScript,
+	}
+	entry.SetTestID("/foo")
+	ctx := context.Background()
+
+	// Entries are only renamed within their parent
+	for _, newName := range []string{"", "bar/baz"} {
+		suite.EqualError(Rename(ctx, entry, newName), fmt.Sprintf("could not rename /foo: %q is not a valid name", newName))
+	}
+	mockScript.OnInvokeAndWait(ctx, "rename", entry, "bar").Return(mockInvocation(nil), nil).Once()
+	suite.NoError(Rename(ctx, entry, "bar"))
+	mockScript.AssertExpectations(suite.T())
+}
+
 func TestHelpers(t *testing.T) {
 	suite.Run(t, new(HelpersTestSuite))
 }
@@ -0,0 +1,169 @@
+package plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// HTTPOptions configure the HTTP clients that core plugins use to talk to
+// their providers. They can be set globally via the http key in wash.yaml,
+// and overridden per plugin via the http key in the plugin's config.
+type HTTPOptions struct {
+	// Proxy is the proxy URL for HTTP and HTTPS requests. If it's empty,
+	// then the HTTP_PROXY and HTTPS_PROXY environment variables are used.
+	Proxy string `json:"proxy,omitempty" mapstructure:"proxy"`
+	// NoProxy is a comma-separated list of the hosts (and domains, IPs and
+	// CIDRs) that aren't proxied. If it's empty, then the NO_PROXY
+	// environment variable is used.
+	NoProxy string `json:"no_proxy,omitempty" mapstructure:"no_proxy"`
+	// CABundle is the path to a PEM file of additional CA certificates that
+	// are trusted along with the system's, e.g. a corporate proxy's CA.
+	CABundle string `json:"ca_bundle,omitempty" mapstructure:"ca_bundle"`
+	// IPVersion restricts connections to IPv4 (4) or IPv6 (6) addresses.
+	// 0 means either, which is the default.
+	IPVersion int `json:"ip_version,omitempty" mapstructure:"ip_version"`
+}
+
+func (o HTTPOptions) validate() error {
+	if o.Proxy != "" {
+		if _, err := url.Parse(o.Proxy); err != nil {
+			return fmt.Errorf("invalid proxy %v: %v", o.Proxy, err)
+		}
+	}
+	switch o.IPVersion {
+	case 0, 4, 6:
+	default:
+		return fmt.Errorf("the IP version must be 4 or 6, not %v", o.IPVersion)
+	}
+	return nil
+}
+
+// merge returns o with the non-zero options in overrides
+func (o HTTPOptions) merge(overrides HTTPOptions) HTTPOptions {
+	if overrides.Proxy != "" {
+		o.Proxy = overrides.Proxy
+	}
+	if overrides.NoProxy != "" {
+		o.NoProxy = overrides.NoProxy
+	}
+	if overrides.CABundle != "" {
+		o.CABundle = overrides.CABundle
+	}
+	if overrides.IPVersion != 0 {
+		o.IPVersion = overrides.IPVersion
+	}
+	return o
+}
+
+var globalHTTPOptions HTTPOptions
+var globalHTTPOptionsMux sync.Mutex
+
+// SetHTTPOptions sets the global HTTP options. It's meant to be called by the
+// Wash server before the plugins are initialized.
+func SetHTTPOptions(opts HTTPOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	globalHTTPOptionsMux.Lock()
+	defer globalHTTPOptionsMux.Unlock()
+	globalHTTPOptions = opts
+	return nil
+}
+
+// HTTPOptionsFor returns the HTTP options of the plugin whose config is cfg,
+// i.e. the global options overridden by the ones in the config's http key.
+func HTTPOptionsFor(cfg map[string]interface{}) (HTTPOptions, error) {
+	globalHTTPOptionsMux.Lock()
+	opts := globalHTTPOptions
+	globalHTTPOptionsMux.Unlock()
+
+	raw, ok := cfg["http"]
+	if !ok {
+		return opts, nil
+	}
+	var overrides HTTPOptions
+	if err := mapstructure.Decode(raw, &overrides); err != nil {
+		return HTTPOptions{}, fmt.Errorf("could not decode the http config: %v", err)
+	}
+	if err := overrides.validate(); err != nil {
+		return HTTPOptions{}, fmt.Errorf("invalid http config: %v", err)
+	}
+	return opts.merge(overrides), nil
+}
+
+// NewClient returns an HTTP client that implements o
+func (o HTTPOptions) NewClient() (*http.Client, error) {
+	transport := &http.Transport{
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if err := o.ConfigureTransport(transport); err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// ConfigureTransport configures t's proxy, dialer and trusted CAs to implement
+// o. It's useful for SDKs that construct their own transports.
+func (o HTTPOptions) ConfigureTransport(t *http.Transport) error {
+	t.Proxy = o.proxyFunc()
+
+	network := "tcp"
+	if o.IPVersion != 0 {
+		network = fmt.Sprintf("tcp%v", o.IPVersion)
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	if o.CABundle == "" {
+		return nil
+	}
+	bundle, err := ioutil.ReadFile(expandPath(o.CABundle))
+	if err != nil {
+		return fmt.Errorf("could not read the CA bundle: %v", err)
+	}
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	if t.TLSClientConfig.RootCAs == nil {
+		if t.TLSClientConfig.RootCAs, err = x509.SystemCertPool(); err != nil {
+			t.TLSClientConfig.RootCAs = x509.NewCertPool()
+		}
+	}
+	if !t.TLSClientConfig.RootCAs.AppendCertsFromPEM(bundle) {
+		return fmt.Errorf("the CA bundle %v does not contain any PEM-encoded certificates", o.CABundle)
+	}
+	return nil
+}
+
+func (o HTTPOptions) proxyFunc() func(*http.Request) (*url.URL, error) {
+	if o.Proxy == "" && o.NoProxy == "" {
+		return http.ProxyFromEnvironment
+	}
+	cfg := httpproxy.FromEnvironment()
+	if o.Proxy != "" {
+		cfg.HTTPProxy, cfg.HTTPSProxy = o.Proxy, o.Proxy
+	}
+	if o.NoProxy != "" {
+		cfg.NoProxy = o.NoProxy
+	}
+	proxy := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
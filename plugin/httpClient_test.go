@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type HTTPClientTestSuite struct {
+	suite.Suite
+}
+
+func (suite *HTTPClientTestSuite) TearDownTest() {
+	suite.NoError(SetHTTPOptions(HTTPOptions{}))
+}
+
+func (suite *HTTPClientTestSuite) TestSetHTTPOptionsValidatesTheOptions() {
+	suite.EqualError(SetHTTPOptions(HTTPOptions{IPVersion: 5}), "the IP version must be 4 or 6, not 5")
+}
+
+func (suite *HTTPClientTestSuite) TestHTTPOptionsFor() {
+	suite.NoError(SetHTTPOptions(HTTPOptions{Proxy: "http://proxy:3128", NoProxy: "internal"}))
+
+	opts, err := HTTPOptionsFor(map[string]interface{}{})
+	if suite.NoError(err) {
+		suite.Equal(HTTPOptions{Proxy: "http://proxy:3128", NoProxy: "internal"}, opts)
+	}
+
+	cfg := map[string]interface{}{
+		"http": map[string]interface{}{"proxy": "http://other:8080", "ip_version": 6},
+	}
+	opts, err = HTTPOptionsFor(cfg)
+	if suite.NoError(err) {
+		suite.Equal(HTTPOptions{Proxy: "http://other:8080", NoProxy: "internal", IPVersion: 6}, opts)
+	}
+
+	_, err = HTTPOptionsFor(map[string]interface{}{"http": "proxy"})
+	suite.Regexp("could not decode the http config", err)
+	_, err = HTTPOptionsFor(map[string]interface{}{"http": map[string]interface{}{"ip_version": 5}})
+	suite.EqualError(err, "invalid http config: the IP version must be 4 or 6, not 5")
+}
+
+func (suite *HTTPClientTestSuite) TestProxy() {
+	opts := HTTPOptions{Proxy: "http://proxy:3128", NoProxy: "internal.example.com"}
+	proxy := opts.proxyFunc()
+
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+	proxyURL, err := proxy(req)
+	if suite.NoError(err) && suite.NotNil(proxyURL) {
+		suite.Equal("http://proxy:3128", proxyURL.String())
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "https://internal.example.com", nil)
+	proxyURL, err = proxy(req)
+	if suite.NoError(err) {
+		suite.Nil(proxyURL)
+	}
+}
+
+func (suite *HTTPClientTestSuite) TestCABundle() {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// The server's certificate isn't trusted by default
+	client, err := HTTPOptions{}.NewClient()
+	if suite.NoError(err) {
+		_, err = client.Get(server.URL)
+		suite.Error(err)
+	}
+
+	bundle, err := ioutil.TempFile("", "wash-ca-bundle")
+	if !suite.NoError(err) {
+		return
+	}
+	defer os.Remove(bundle.Name())
+	suite.NoError(pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	suite.NoError(bundle.Close())
+
+	client, err = HTTPOptions{CABundle: bundle.Name(), IPVersion: 4}.NewClient()
+	if suite.NoError(err) {
+		resp, err := client.Get(server.URL)
+		if suite.NoError(err) {
+			suite.Equal(http.StatusNoContent, resp.StatusCode)
+			resp.Body.Close()
+		}
+	}
+
+	_, err = HTTPOptions{CABundle: "testdata/external.sh"}.NewClient()
+	suite.Regexp("does not contain any PEM-encoded certificates", err)
+}
+
+func TestHTTPClient(t *testing.T) {
+	suite.Run(t, new(HTTPClientTestSuite))
+}
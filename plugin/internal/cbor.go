@@ -0,0 +1,340 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// CBOR (RFC 7049) major types
+const (
+	cborUnsigned byte = iota
+	cborNegative
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+const (
+	cborIndefiniteLength byte = 31
+	cborBreak            byte = 0xff
+)
+
+// DecodeCBOR decodes the CBOR payload in data. Values are decoded like
+// StreamDecoder's Decode decodes them. It's meant for CBOR that's produced by
+// other programs (e.g. external plugins), which often encode text as byte
+// strings, so byte strings are decoded as strings too. Tags are ignored, so a
+// date/time tag decodes to its RFC 3339 string or its Unix timestamp. Floats
+// that JSON can't represent (NaN and the infinities) aren't supported.
+func DecodeCBOR(data []byte) (interface{}, error) {
+	d := NewCBORDecoder(bytes.NewReader(data))
+	v, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.ExpectEOF(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// NewCBORDecoder returns a StreamDecoder of the CBOR values in r. Its values
+// are decoded like DecodeCBOR decodes them.
+func NewCBORDecoder(r io.Reader) StreamDecoder {
+	return &cborDecoder{streamReader: newStreamReader(r)}
+}
+
+type cborDecoder struct {
+	streamReader
+}
+
+func cborError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("invalid CBOR: %v", err)
+}
+
+func (d *cborDecoder) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf(format+" at offset %v", append(a, d.off)...)
+}
+
+func (d *cborDecoder) PeekKind() (Kind, error) {
+	b, err := d.peekByte()
+	if err != nil {
+		return KindOther, cborError(err)
+	}
+	switch b >> 5 {
+	case cborArray:
+		return KindArray, nil
+	case cborMap:
+		return KindMap, nil
+	default:
+		return KindOther, nil
+	}
+}
+
+func (d *cborDecoder) ReadArrayHeader() (int, error) {
+	n, err := d.containerHeader(cborArray, "an array")
+	return n, cborError(err)
+}
+
+func (d *cborDecoder) ReadMapHeader() (int, error) {
+	n, err := d.containerHeader(cborMap, "a map")
+	return n, cborError(err)
+}
+
+func (d *cborDecoder) containerHeader(expected byte, kind string) (int, error) {
+	off := d.off
+	major, _, n, indefinite, err := d.head()
+	if err != nil {
+		return 0, err
+	}
+	if major != expected {
+		return 0, fmt.Errorf("expected %v at offset %v, not major type %v", kind, off, major)
+	}
+	if indefinite {
+		return -1, nil
+	}
+	if n > math.MaxInt32 {
+		return 0, fmt.Errorf("the length %v at offset %v is too big", n, off)
+	}
+	return int(n), nil
+}
+
+func (d *cborDecoder) ReadBreak() (bool, error) {
+	done, err := d.isBreak()
+	return done, cborError(err)
+}
+
+func (d *cborDecoder) Decode() (interface{}, error) {
+	v, err := d.value(0)
+	return v, cborError(err)
+}
+
+func (d *cborDecoder) ExpectEOF() error {
+	return cborError(d.expectEOF())
+}
+
+// head reads a data item's head. indefinite is true if the item has an
+// indefinite length, in which case n is meaningless.
+func (d *cborDecoder) head() (major byte, info byte, n uint64, indefinite bool, err error) {
+	b, err := d.readByte()
+	if err != nil {
+		return
+	}
+	major, info = b>>5, b&0x1f
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		var arg []byte
+		if arg, err = d.read(1 << (info - 24)); err != nil {
+			return
+		}
+		for _, b := range arg {
+			n = n<<8 | uint64(b)
+		}
+	case info == cborIndefiniteLength && major >= cborBytes && major <= cborMap:
+		indefinite = true
+	case info == cborIndefiniteLength && major == cborSimple:
+		err = d.errorf("unexpected break")
+	default:
+		err = d.errorf("invalid additional information %v", info)
+	}
+	return
+}
+
+// isBreak consumes the next byte if it's a break
+func (d *cborDecoder) isBreak() (bool, error) {
+	b, err := d.peekByte()
+	if err != nil {
+		return false, err
+	}
+	if b == cborBreak {
+		_, err = d.readByte()
+		return true, err
+	}
+	return false, nil
+}
+
+func (d *cborDecoder) value(depth int) (interface{}, error) {
+	if depth > maxDecodeDepth {
+		return nil, d.errorf("exceeded the maximum nesting depth of %v", maxDecodeDepth)
+	}
+	major, info, n, indefinite, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUnsigned:
+		return float64(n), nil
+	case cborNegative:
+		// The value is -1 - n, which overflows an int64 for large n
+		return -1 - float64(n), nil
+	case cborBytes, cborText:
+		str, err := d.str(major, n, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText && !utf8.Valid(str) {
+			return nil, d.errorf("invalid UTF-8 in a text string")
+		}
+		return string(str), nil
+	case cborArray:
+		arr := make([]interface{}, 0, int(minUint64(n, maxPreallocation)))
+		err := d.items(n, indefinite, func() error {
+			v, err := d.value(depth + 1)
+			arr = append(arr, v)
+			return err
+		})
+		return arr, err
+	case cborMap:
+		mp := make(map[string]interface{}, int(minUint64(n, maxPreallocation)))
+		err := d.items(n, indefinite, func() error {
+			key, err := d.key()
+			if err != nil {
+				return err
+			}
+			mp[key], err = d.value(depth + 1)
+			return err
+		})
+		return mp, err
+	case cborTag:
+		return d.value(depth + 1)
+	default:
+		return d.simple(info, n)
+	}
+}
+
+// items calls decodeItem for each of a container's n items, or until the
+// break of an indefinite-length container
+func (d *cborDecoder) items(n uint64, indefinite bool, decodeItem func() error) error {
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite {
+			if done, err := d.isBreak(); err != nil {
+				return err
+			} else if done {
+				return nil
+			}
+		}
+		if err := decodeItem(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// str reads a byte or text string's contents, concatenating the chunks of an
+// indefinite-length string
+func (d *cborDecoder) str(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		return d.read(n)
+	}
+	var str []byte
+	for {
+		if done, err := d.isBreak(); err != nil {
+			return nil, err
+		} else if done {
+			return str, nil
+		}
+		chunkMajor, _, chunkLen, chunkIndefinite, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || chunkIndefinite {
+			return nil, d.errorf("invalid chunk in an indefinite-length string")
+		}
+		chunk, err := d.read(chunkLen)
+		if err != nil {
+			return nil, err
+		}
+		str = append(str, chunk...)
+	}
+}
+
+// key decodes a map key. Keys are strings like JSON's, so integer keys are
+// converted to strings.
+func (d *cborDecoder) key() (string, error) {
+	major, _, n, indefinite, err := d.head()
+	if err != nil {
+		return "", err
+	}
+	switch major {
+	case cborText, cborBytes:
+		str, err := d.str(major, n, indefinite)
+		if err != nil {
+			return "", err
+		}
+		if major == cborText && !utf8.Valid(str) {
+			return "", d.errorf("invalid UTF-8 in a text string")
+		}
+		return string(str), nil
+	case cborUnsigned:
+		return strconv.FormatUint(n, 10), nil
+	case cborNegative:
+		if n == math.MaxUint64 {
+			return "-18446744073709551616", nil
+		}
+		return "-" + strconv.FormatUint(n+1, 10), nil
+	default:
+		return "", d.errorf("unsupported map key of major type %v", major)
+	}
+}
+
+func (d *cborDecoder) simple(info byte, n uint64) (interface{}, error) {
+	var f float64
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		f = float16ToFloat64(uint16(n))
+	case 26:
+		f = float64(math.Float32frombits(uint32(n)))
+	case 27:
+		f = math.Float64frombits(n)
+	default:
+		return nil, d.errorf("unsupported simple value %v", n)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, d.errorf("%v can't be represented in JSON", f)
+	}
+	return f, nil
+}
+
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1.0
+	}
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	default:
+		return sign * math.Ldexp(mant+1024, exp-25)
+	}
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CBORTestSuite struct {
+	suite.Suite
+}
+
+func (suite *CBORTestSuite) TestDecodeCBOR() {
+	cases := []struct {
+		input    []byte
+		expected interface{}
+	}{
+		{[]byte{0xf6}, nil},
+		// undefined
+		{[]byte{0xf7}, nil},
+		{[]byte{0xf5}, true},
+		{[]byte{0x18, 0x64}, float64(100)},
+		{[]byte{0x39, 0x01, 0x00}, float64(-257)},
+		{[]byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, float64(math.MaxUint64)},
+		{[]byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, -1 - float64(math.MaxUint64)},
+		// float16, float32 and float64
+		{[]byte{0xf9, 0x3c, 0x00}, float64(1)},
+		{[]byte{0xf9, 0x3e, 0x00}, 1.5},
+		{[]byte{0xf9, 0x00, 0x01}, 5.960464477539063e-08},
+		{[]byte{0xfa, 0x47, 0xc3, 0x50, 0x00}, float64(100000)},
+		{[]byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, 1.5},
+		{[]byte{0x63, 'f', 'o', 'o'}, "foo"},
+		// byte strings are decoded as strings
+		{[]byte{0x42, 0x00, 0x01}, "\x00\x01"},
+		// indefinite-length strings
+		{[]byte{0x7f, 0x62, 0x68, 0x65, 0x63, 0x6c, 0x6c, 0x6f, 0xff}, "hello"},
+		// tags are ignored
+		{append([]byte{0xc0, 0x74}, "2013-03-21T20:04:00Z"...), "2013-03-21T20:04:00Z"},
+		{[]byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}, float64(1363896240)},
+		// definite and indefinite-length containers. Integer keys are
+		// converted to strings.
+		{[]byte{0x82, 0x01, 0x61, 0x61}, []interface{}{float64(1), "a"}},
+		{[]byte{0x9f, 0x01, 0xff}, []interface{}{float64(1)}},
+		{[]byte{0xa2, 0x61, 0x61, 0x01, 0x01, 0x02}, map[string]interface{}{"a": float64(1), "1": float64(2)}},
+		{[]byte{0xbf, 0x61, 'a', 0xf5, 0xff}, map[string]interface{}{"a": true}},
+	}
+	for _, c := range cases {
+		actual, err := DecodeCBOR(c.input)
+		if suite.NoError(err, "input: %x", c.input) {
+			suite.Equal(c.expected, actual, "input: %x", c.input)
+		}
+	}
+}
+
+func (suite *CBORTestSuite) TestDecodeCBORErrors() {
+	cases := []struct {
+		input  []byte
+		errMsg string
+	}{
+		{[]byte{}, "unexpected end of data"},
+		{[]byte{0x19, 0x01}, "unexpected end of data"},
+		{[]byte{0x9f, 0x01}, "unexpected end of data"},
+		{[]byte{0x62, 'a'}, "unexpected end of data"},
+		{[]byte{0x01, 0x02}, "unexpected data after the top-level value"},
+		{[]byte{0xff}, "unexpected break"},
+		{[]byte{0x1c}, "invalid additional information"},
+		{[]byte{0xa1, 0x80, 0x01}, "unsupported map key"},
+		{[]byte{0xf9, 0x7c, 0x00}, "can't be represented in JSON"},
+		{[]byte{0xf8, 0x20}, "unsupported simple value"},
+		{[]byte{0x61, 0xff}, "invalid UTF-8"},
+		{[]byte{0x7f, 0x41, 0x61, 0xff}, "invalid chunk"},
+		// Bogus lengths error once the data runs out
+		{[]byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 'a'}, "unexpected end of data"},
+		{[]byte{0x9b, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, "unexpected end of data"},
+	}
+	for _, c := range cases {
+		_, err := DecodeCBOR(c.input)
+		if suite.Error(err, "input: %x", c.input) {
+			suite.Contains(err.Error(), c.errMsg, "input: %x", c.input)
+			suite.Contains(err.Error(), "invalid CBOR", "input: %x", c.input)
+		}
+	}
+}
+
+func (suite *CBORTestSuite) TestDecodeCBORLimitsDepth() {
+	input := make([]byte, maxDecodeDepth+2)
+	for i := range input {
+		input[i] = 0x81
+	}
+	_, err := DecodeCBOR(input)
+	if suite.Error(err) {
+		suite.Contains(err.Error(), "nesting depth")
+	}
+}
+
+func (suite *CBORTestSuite) TestStreamsContainers() {
+	// [{"a": 1}, <indefinite-length array of 2, 3>], then a break
+	d := NewCBORDecoder(bytes.NewReader([]byte{0x9f, 0xa1, 0x61, 'a', 0x01, 0x9f, 0x02, 0x03, 0xff, 0xff}))
+	kind, err := d.PeekKind()
+	if suite.NoError(err) {
+		suite.Equal(KindArray, kind)
+	}
+	n, err := d.ReadArrayHeader()
+	if suite.NoError(err) {
+		suite.Equal(-1, n)
+	}
+	n, err = d.ReadMapHeader()
+	if suite.NoError(err) {
+		suite.Equal(1, n)
+	}
+	key, err := d.Decode()
+	if suite.NoError(err) {
+		suite.Equal("a", key)
+	}
+	value, err := d.Decode()
+	if suite.NoError(err) {
+		suite.Equal(float64(1), value)
+	}
+	done, err := d.ReadBreak()
+	if suite.NoError(err) {
+		suite.False(done)
+	}
+	value, err = d.Decode()
+	if suite.NoError(err) {
+		suite.Equal([]interface{}{float64(2), float64(3)}, value)
+	}
+	done, err = d.ReadBreak()
+	if suite.NoError(err) {
+		suite.True(done)
+	}
+	suite.NoError(d.ExpectEOF())
+
+	_, err = NewCBORDecoder(bytes.NewReader([]byte{0x01})).ReadMapHeader()
+	suite.EqualError(err, "invalid CBOR: expected a map at offset 0, not major type 0")
+}
+
+func TestCBOR(t *testing.T) {
+	suite.Run(t, new(CBORTestSuite))
+}
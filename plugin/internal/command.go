@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -179,6 +180,38 @@ func (cmd *Command) signal(sig syscall.Signal) error {
 	return nil
 }
 
+// Rlimits are the resource limits of a command. Zero limits are ignored.
+type Rlimits struct {
+	// CPUSeconds limits the command's CPU time
+	CPUSeconds int
+	// MemoryMB limits the command's virtual memory
+	MemoryMB int
+	// OpenFiles limits the command's open file descriptors
+	OpenFiles int
+}
+
+// SetRlimits runs the command under the given resource limits. The limits are
+// set by a shell that then execs the command so that they're in place before
+// the command starts. Call this before cmd.Start().
+func (cmd *Command) SetRlimits(limits Rlimits) {
+	var ulimits []string
+	if limits.CPUSeconds > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -t %v", limits.CPUSeconds))
+	}
+	if limits.MemoryMB > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -v %v", limits.MemoryMB*1024))
+	}
+	if limits.OpenFiles > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -n %v", limits.OpenFiles))
+	}
+	if len(ulimits) == 0 {
+		return
+	}
+	script := strings.Join(ulimits, " && ") + ` && exec "$0" "$@"`
+	cmd.c.Args = append([]string{"/bin/sh", "-c", script, cmd.c.Path}, cmd.c.Args[1:]...)
+	cmd.c.Path = "/bin/sh"
+}
+
 // exec.Cmd wrappers go here
 
 // SetStdout wraps exec.Cmd#Stdout
@@ -196,6 +229,16 @@ func (cmd *Command) SetStdin(stdin io.Reader) {
 	cmd.c.Stdin = stdin
 }
 
+// SetEnv wraps exec.Cmd#Env
+func (cmd *Command) SetEnv(env []string) {
+	cmd.c.Env = env
+}
+
+// StdinPipe wraps exec.Cmd#StdinPipe
+func (cmd *Command) StdinPipe() (io.WriteCloser, error) {
+	return cmd.c.StdinPipe()
+}
+
 // StdoutPipe wraps exec.Cmd#StdoutPipe
 func (cmd *Command) StdoutPipe() (io.ReadCloser, error) {
 	return cmd.c.StdoutPipe()
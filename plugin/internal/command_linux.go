@@ -0,0 +1,20 @@
+package internal
+
+import (
+	"os"
+	"syscall"
+)
+
+// NetworkIsolationSupported is true if IsolateNetwork is supported on this
+// platform
+const NetworkIsolationSupported = true
+
+// IsolateNetwork runs the command in its own network namespace, which only
+// has a (down) loopback device. The namespace is created in a new user
+// namespace that maps the current user to itself, so it doesn't require root.
+// Call this before cmd.Start().
+func (cmd *Command) IsolateNetwork() {
+	cmd.c.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET | syscall.CLONE_NEWUSER
+	cmd.c.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	cmd.c.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package internal
+
+// NetworkIsolationSupported is true if IsolateNetwork is supported on this
+// platform. Network namespaces are only supported on Linux.
+const NetworkIsolationSupported = false
+
+// IsolateNetwork is a no-op since network isolation isn't supported on this
+// platform. Check NetworkIsolationSupported before calling it.
+func (cmd *Command) IsolateNetwork() {
+}
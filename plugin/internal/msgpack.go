@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// maxDecodeDepth is the maximum nesting depth of a decoded value. It keeps a
+// malicious payload from overflowing the stack.
+const maxDecodeDepth = 10000
+
+// DecodeMsgpack decodes the MessagePack payload in data. Values are decoded
+// like StreamDecoder's Decode decodes them, and timestamps are decoded as
+// time.Times. Maps with non-string keys and other extension types aren't
+// supported.
+func DecodeMsgpack(data []byte) (interface{}, error) {
+	d := NewMsgpackDecoder(bytes.NewReader(data))
+	v, err := d.Decode()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.ExpectEOF(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// NewMsgpackDecoder returns a StreamDecoder of the MessagePack values in r.
+// Its values are decoded like DecodeMsgpack decodes them.
+func NewMsgpackDecoder(r io.Reader) StreamDecoder {
+	return &msgpackDecoder{streamReader: newStreamReader(r)}
+}
+
+type msgpackDecoder struct {
+	streamReader
+}
+
+func msgpackError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("invalid MessagePack: %v", err)
+}
+
+func (d *msgpackDecoder) PeekKind() (Kind, error) {
+	b, err := d.peekByte()
+	if err != nil {
+		return KindOther, msgpackError(err)
+	}
+	switch {
+	case (b >= 0x90 && b <= 0x9f) || b == 0xdc || b == 0xdd:
+		return KindArray, nil
+	case (b >= 0x80 && b <= 0x8f) || b == 0xde || b == 0xdf:
+		return KindMap, nil
+	default:
+		return KindOther, nil
+	}
+}
+
+func (d *msgpackDecoder) ReadArrayHeader() (int, error) {
+	n, err := d.containerHeader(0x90, 0xdc, "an array")
+	return n, msgpackError(err)
+}
+
+func (d *msgpackDecoder) ReadMapHeader() (int, error) {
+	n, err := d.containerHeader(0x80, 0xde, "a map")
+	return n, msgpackError(err)
+}
+
+// containerHeader reads the header of an array or a map, whose fix type is
+// fix and whose 16-bit type is sized
+func (d *msgpackDecoder) containerHeader(fix byte, sized byte, kind string) (int, error) {
+	off := d.off
+	b, err := d.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b >= fix && b <= fix+0x0f:
+		return int(b & 0x0f), nil
+	case b == sized || b == sized+1:
+		return d.length(2 << (b - sized))
+	default:
+		return 0, fmt.Errorf("expected %v at offset %v, not type 0x%x", kind, off, b)
+	}
+}
+
+// ReadBreak always returns false since MessagePack doesn't have
+// indefinite-length containers
+func (d *msgpackDecoder) ReadBreak() (bool, error) {
+	return false, nil
+}
+
+func (d *msgpackDecoder) Decode() (interface{}, error) {
+	v, err := d.decode(0)
+	return v, msgpackError(err)
+}
+
+func (d *msgpackDecoder) ExpectEOF() error {
+	return msgpackError(d.expectEOF())
+}
+
+// uint reads an n-byte big-endian unsigned integer
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	bits, err := d.read(uint64(n))
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, b := range bits {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+// length reads an n-byte length
+func (d *msgpackDecoder) length(n int) (int, error) {
+	v, err := d.uint(n)
+	return int(v), err
+}
+
+func (d *msgpackDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxDecodeDepth {
+		return nil, fmt.Errorf("exceeded the maximum nesting depth of %v", maxDecodeDepth)
+	}
+	off := d.off
+	b, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0x7f:
+		return float64(b), nil
+	case b >= 0xe0:
+		return float64(int8(b)), nil
+	case b >= 0x80 && b <= 0x8f:
+		return d.decodeMap(int(b&0x0f), depth)
+	case b >= 0x90 && b <= 0x9f:
+		return d.decodeArray(int(b&0x0f), depth)
+	case b >= 0xa0 && b <= 0xbf:
+		return d.decodeString(int(b & 0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9:
+		return d.decodeSizedString(1)
+	case 0xc5, 0xda:
+		return d.decodeSizedString(2)
+	case 0xc6, 0xdb:
+		return d.decodeSizedString(4)
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := d.uint(1 << (b - 0xcc))
+		return float64(v), err
+	case 0xd0:
+		v, err := d.uint(1)
+		return float64(int8(v)), err
+	case 0xd1:
+		v, err := d.uint(2)
+		return float64(int16(v)), err
+	case 0xd2:
+		v, err := d.uint(4)
+		return float64(int32(v)), err
+	case 0xd3:
+		v, err := d.uint(8)
+		return float64(int64(v)), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (b - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.length(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n, depth)
+	default:
+		return nil, fmt.Errorf("unknown type 0x%x at offset %v", b, off)
+	}
+}
+
+func (d *msgpackDecoder) decodeString(n int) (interface{}, error) {
+	bits, err := d.read(uint64(n))
+	if err != nil {
+		return nil, err
+	}
+	return string(bits), nil
+}
+
+func (d *msgpackDecoder) decodeSizedString(lengthSize int) (interface{}, error) {
+	n, err := d.length(lengthSize)
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeString(n)
+}
+
+func (d *msgpackDecoder) decodeArray(n int, depth int) (interface{}, error) {
+	arr := make([]interface{}, 0, minInt(n, maxPreallocation))
+	for i := 0; i < n; i++ {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int, depth int) (interface{}, error) {
+	mp := make(map[string]interface{}, minInt(n, maxPreallocation))
+	for i := 0; i < n; i++ {
+		keyOff := d.off
+		key, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		str, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("the map key at offset %v is a %T, not a string", keyOff, key)
+		}
+		if mp[str], err = d.decode(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return mp, nil
+}
+
+// msgpackTimestampType is the extension type of MessagePack timestamps
+const msgpackTimestampType = -1
+
+func (d *msgpackDecoder) decodeExt(n int) (interface{}, error) {
+	typeOff := d.off
+	typ, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	bits, err := d.read(uint64(n))
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ) != msgpackTimestampType {
+		return nil, fmt.Errorf("unsupported extension type %v at offset %v", int8(typ), typeOff)
+	}
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(bits)), 0), nil
+	case 8:
+		v := binary.BigEndian.Uint64(bits)
+		return time.Unix(int64(v&0x3ffffffff), int64(v>>34)), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(bits[:4])
+		return time.Unix(int64(binary.BigEndian.Uint64(bits[4:])), int64(nsec)), nil
+	default:
+		return nil, fmt.Errorf("invalid timestamp of %v bytes at offset %v", n, typeOff)
+	}
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MsgpackTestSuite struct {
+	suite.Suite
+}
+
+func msgpackStr(s string) []byte {
+	return append([]byte{0xa0 | byte(len(s))}, s...)
+}
+
+func (suite *MsgpackTestSuite) assertDecodes(data []byte, expected interface{}) {
+	v, err := DecodeMsgpack(data)
+	if suite.NoError(err) {
+		suite.Equal(expected, v)
+	}
+}
+
+func (suite *MsgpackTestSuite) TestDecodesScalars() {
+	suite.assertDecodes([]byte{0xc0}, nil)
+	suite.assertDecodes([]byte{0xc2}, false)
+	suite.assertDecodes([]byte{0xc3}, true)
+	suite.assertDecodes([]byte{0x7f}, float64(127))
+	suite.assertDecodes([]byte{0xff}, float64(-1))
+	suite.assertDecodes([]byte{0xcd, 0x01, 0x00}, float64(256))
+	suite.assertDecodes([]byte{0xd0, 0x80}, float64(-128))
+	suite.assertDecodes([]byte{0xd3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}, float64(-2))
+
+	bits := math.Float64bits(1.5)
+	float := []byte{0xcb}
+	for i := 7; i >= 0; i-- {
+		float = append(float, byte(bits>>(8*uint(i))))
+	}
+	suite.assertDecodes(float, 1.5)
+
+	suite.assertDecodes(msgpackStr("foo"), "foo")
+	suite.assertDecodes([]byte{0xd9, 0x03, 'f', 'o', 'o'}, "foo")
+	suite.assertDecodes([]byte{0xc4, 0x02, 0x00, 0x01}, "\x00\x01")
+	suite.assertDecodes([]byte{0xd6, 0xff, 0x00, 0x00, 0x00, 0x3c}, time.Unix(60, 0))
+}
+
+func (suite *MsgpackTestSuite) TestDecodesCollections() {
+	data := []byte{0x83}
+	data = append(data, msgpackStr("name")...)
+	data = append(data, msgpackStr("foo")...)
+	data = append(data, msgpackStr("methods")...)
+	data = append(data, 0x91)
+	data = append(data, msgpackStr("list")...)
+	data = append(data, msgpackStr("attributes")...)
+	data = append(data, 0x81)
+	data = append(data, msgpackStr("size")...)
+	data = append(data, 0x0a)
+	suite.assertDecodes(data, map[string]interface{}{
+		"name":       "foo",
+		"methods":    []interface{}{"list"},
+		"attributes": map[string]interface{}{"size": float64(10)},
+	})
+
+	suite.assertDecodes([]byte{0xdc, 0x00, 0x02, 0x01, 0xc0}, []interface{}{float64(1), nil})
+	suite.assertDecodes([]byte{0x90}, []interface{}{})
+}
+
+func (suite *MsgpackTestSuite) TestErrorsOnInvalidPayloads() {
+	_, err := DecodeMsgpack([]byte{0xa3, 'f', 'o'})
+	suite.EqualError(err, "invalid MessagePack: unexpected end of data at offset 1")
+
+	_, err = DecodeMsgpack([]byte{0x01, 0x02})
+	suite.EqualError(err, "invalid MessagePack: unexpected data after the top-level value at offset 1")
+
+	_, err = DecodeMsgpack([]byte{0x81, 0x01, 0x02})
+	suite.EqualError(err, "invalid MessagePack: the map key at offset 1 is a float64, not a string")
+
+	// Bogus lengths error once the data runs out
+	_, err = DecodeMsgpack([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	suite.EqualError(err, "invalid MessagePack: unexpected end of data at offset 5")
+	_, err = DecodeMsgpack([]byte{0xdb, 0xff, 0xff, 0xff, 0xff, 'a'})
+	suite.EqualError(err, "invalid MessagePack: unexpected end of data at offset 6")
+
+	_, err = DecodeMsgpack([]byte{0xd4, 0x01, 0x00})
+	suite.EqualError(err, "invalid MessagePack: unsupported extension type 1 at offset 1")
+
+	_, err = DecodeMsgpack([]byte{0xc1})
+	suite.EqualError(err, "invalid MessagePack: unknown type 0xc1 at offset 0")
+}
+
+func (suite *MsgpackTestSuite) TestStreamsContainers() {
+	// [{"name": "foo"}, "bar"]
+	data := []byte{0x92, 0x81}
+	data = append(data, msgpackStr("name")...)
+	data = append(data, msgpackStr("foo")...)
+	data = append(data, msgpackStr("bar")...)
+	d := NewMsgpackDecoder(bytes.NewReader(data))
+	kind, err := d.PeekKind()
+	if suite.NoError(err) {
+		suite.Equal(KindArray, kind)
+	}
+	n, err := d.ReadArrayHeader()
+	if suite.NoError(err) {
+		suite.Equal(2, n)
+	}
+	kind, err = d.PeekKind()
+	if suite.NoError(err) {
+		suite.Equal(KindMap, kind)
+	}
+	n, err = d.ReadMapHeader()
+	if suite.NoError(err) {
+		suite.Equal(1, n)
+	}
+	for _, expected := range []interface{}{"name", "foo", "bar"} {
+		v, err := d.Decode()
+		if suite.NoError(err) {
+			suite.Equal(expected, v)
+		}
+	}
+	suite.NoError(d.ExpectEOF())
+
+	_, err = NewMsgpackDecoder(bytes.NewReader(msgpackStr("foo"))).ReadArrayHeader()
+	suite.EqualError(err, "invalid MessagePack: expected an array at offset 0, not type 0xa3")
+}
+
+func TestMsgpack(t *testing.T) {
+	suite.Run(t, new(MsgpackTestSuite))
+}
@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Kind is the kind of a StreamDecoder's next value
+type Kind int
+
+// These are the kinds of values that a StreamDecoder distinguishes between.
+// Everything that isn't an array or a map is a KindOther.
+const (
+	KindOther Kind = iota
+	KindArray
+	KindMap
+)
+
+// StreamDecoder incrementally decodes a stream of binary-encoded (e.g.
+// MessagePack) values. It's meant for big payloads like the list results of
+// external plugins, whose items can be decoded one at a time by reading the
+// containers' headers instead of decoding the whole payload.
+type StreamDecoder interface {
+	// PeekKind returns the kind of the next value without consuming it
+	PeekKind() (Kind, error)
+	// ReadArrayHeader consumes the header of the next value, which must be
+	// an array, and returns its length. The length is -1 if the array has
+	// an indefinite length, in which case its end is marked by a break (see
+	// ReadBreak).
+	ReadArrayHeader() (int, error)
+	// ReadMapHeader is ReadArrayHeader for maps. The length is the number of
+	// key-value pairs.
+	ReadMapHeader() (int, error)
+	// ReadBreak consumes the break that ends an indefinite-length array or
+	// map. It returns false without consuming anything if the next item
+	// isn't a break.
+	ReadBreak() (bool, error)
+	// Decode decodes the next value like encoding/json decodes values into
+	// an interface{}, i.e. maps are map[string]interface{}s, arrays are
+	// []interface{}s and numbers are float64s. Binary data is decoded as a
+	// string.
+	Decode() (interface{}, error)
+	// ExpectEOF errors if there's data after the last decoded value
+	ExpectEOF() error
+}
+
+// maxPreallocation caps what's allocated upfront for the strings and
+// containers of a payload. Their lengths are read from the payload, so a
+// bogus length errors once the data runs out instead of exhausting memory.
+const maxPreallocation = 4096
+
+// streamReader reads a binary-encoded stream, keeping track of its offset
+// for error messages
+type streamReader struct {
+	r   *bufio.Reader
+	off int
+}
+
+func newStreamReader(r io.Reader) streamReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return streamReader{r: br}
+}
+
+func (s *streamReader) unexpectedEnd(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("unexpected end of data at offset %v", s.off)
+	}
+	return err
+}
+
+func (s *streamReader) peekByte() (byte, error) {
+	bits, err := s.r.Peek(1)
+	if err != nil {
+		return 0, s.unexpectedEnd(err)
+	}
+	return bits[0], nil
+}
+
+func (s *streamReader) readByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err != nil {
+		return 0, s.unexpectedEnd(err)
+	}
+	s.off++
+	return b, nil
+}
+
+// read reads the next n bytes
+func (s *streamReader) read(n uint64) ([]byte, error) {
+	if n <= maxPreallocation {
+		bits := make([]byte, n)
+		if _, err := io.ReadFull(s.r, bits); err != nil {
+			return nil, s.unexpectedEnd(err)
+		}
+		s.off += int(n)
+		return bits, nil
+	}
+	var buf bytes.Buffer
+	copied, err := io.CopyN(&buf, s.r, int64(n))
+	if err == nil && uint64(copied) != n {
+		// n overflowed the int64
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		s.off += int(copied)
+		return nil, s.unexpectedEnd(err)
+	}
+	s.off += int(n)
+	return buf.Bytes(), nil
+}
+
+func (s *streamReader) expectEOF() error {
+	if _, err := s.r.Peek(1); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("unexpected data after the top-level value at offset %v", s.off)
+	}
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
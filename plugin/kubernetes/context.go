@@ -2,9 +2,11 @@ package kubernetes
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/puppetlabs/wash/activity"
 	"github.com/puppetlabs/wash/plugin"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8s "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -56,3 +58,19 @@ func (c *k8context) List(ctx context.Context) ([]plugin.Entry, error) {
 	activity.Record(ctx, "Listing namespaces: %+v", namespaces)
 	return namespaces, nil
 }
+
+// CreateChild implements plugin.ChildCreator. It creates a namespace, so it
+// can only create parents (e.g. via mkdir).
+func (c *k8context) CreateChild(ctx context.Context, name string, isParent bool) (plugin.Entry, error) {
+	if !isParent {
+		return nil, fmt.Errorf("only namespaces can be created in context %v", c.Name())
+	}
+	activity.Record(ctx, "Creating namespace %v", name)
+	ns, err := c.client.CoreV1().Namespaces().Create(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newNamespace(ns.Name, ns, c.client, c.config), nil
+}
@@ -3,8 +3,10 @@ package kubernetes
 import (
 	"context"
 
+	"github.com/puppetlabs/wash/activity"
 	"github.com/puppetlabs/wash/plugin"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8s "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -47,3 +49,11 @@ func (n *namespace) ChildSchemas() []*plugin.EntrySchema {
 func (n *namespace) List(ctx context.Context) ([]plugin.Entry, error) {
 	return n.resources, nil
 }
+
+// Delete implements plugin.Deletable. Kubernetes deletes the namespace's
+// resources before deleting the namespace, so it's listed (as terminating)
+// until they're gone.
+func (n *namespace) Delete(ctx context.Context) error {
+	activity.Record(ctx, "Deleting namespace %v", n.Name())
+	return n.client.CoreV1().Namespaces().Delete(n.Name(), &metav1.DeleteOptions{})
+}
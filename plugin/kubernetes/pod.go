@@ -10,6 +10,7 @@ import (
 	"github.com/puppetlabs/wash/activity"
 	"github.com/puppetlabs/wash/plugin"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8s "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/remotecommand"
@@ -36,10 +37,31 @@ func newPod(ctx context.Context, client *k8s.Clientset, config *rest.Config, ns
 		SetCrtime(p.CreationTimestamp.Time).
 		SetAtime(p.CreationTimestamp.Time).
 		SetMeta(plugin.ToJSONObject(p))
+	if lifecycle, ok := podLifecycle(p); ok {
+		pd.Attributes().SetLifecycle(lifecycle)
+	}
 
 	return pd, nil
 }
 
+func podLifecycle(p *corev1.Pod) (plugin.Lifecycle, bool) {
+	if p.DeletionTimestamp != nil && p.Status.Phase != corev1.PodSucceeded && p.Status.Phase != corev1.PodFailed {
+		return plugin.LifecycleStopping, true
+	}
+	switch p.Status.Phase {
+	case corev1.PodPending:
+		return plugin.LifecycleProvisioning, true
+	case corev1.PodRunning:
+		return plugin.LifecycleRunning, true
+	case corev1.PodSucceeded:
+		return plugin.LifecycleTerminated, true
+	case corev1.PodFailed, corev1.PodUnknown:
+		return plugin.LifecycleError, true
+	default:
+		return "", false
+	}
+}
+
 func (p *pod) Schema() *plugin.EntrySchema {
 	return plugin.
 		NewEntrySchema(p, "pod").
@@ -64,7 +86,32 @@ func (p *pod) Open(ctx context.Context) (plugin.SizedReader, error) {
 func (p *pod) Stream(ctx context.Context) (io.ReadCloser, error) {
 	var tailLines int64 = 10
 	req := p.client.CoreV1().Pods(p.ns).GetLogs(p.Name(), &corev1.PodLogOptions{Follow: true, TailLines: &tailLines})
-	return req.Stream()
+	rdr, err := req.Stream()
+	if err != nil {
+		return nil, err
+	}
+	return &plugin.EndingReader{ReadCloser: rdr, End: func() *plugin.StreamEnd { return p.streamEnd(ctx) }}, nil
+}
+
+// streamEnd describes why the pod's log stream ended. Kubernetes ends the
+// stream once the pod's container terminates, so this reports its termination
+// reason and exit code if it's terminated.
+func (p *pod) streamEnd(ctx context.Context) *plugin.StreamEnd {
+	pd, err := p.client.CoreV1().Pods(p.ns).Get(p.Name(), metav1.GetOptions{})
+	if err != nil {
+		activity.Record(ctx, "Error reading info for pod %v: %v", p.Name(), err)
+		return plugin.NewStreamEnd("Kubernetes closed the pod's log stream")
+	}
+	for _, status := range pd.Status.ContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil {
+			reason := terminated.Reason
+			if reason == "" {
+				reason = "Terminated"
+			}
+			return plugin.NewStreamExit(fmt.Sprintf("the container %v exited: %v", status.Name, reason), int(terminated.ExitCode))
+		}
+	}
+	return plugin.NewStreamEnd(fmt.Sprintf("Kubernetes closed the pod's log stream (the pod is %v)", pd.Status.Phase))
 }
 
 func (p *pod) Exec(ctx context.Context, cmd string, args []string, opts plugin.ExecOptions) (plugin.ExecCommand, error) {
@@ -5,6 +5,9 @@ package kubernetes
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"os"
 
 	"github.com/puppetlabs/wash/activity"
 	"github.com/puppetlabs/wash/plugin"
@@ -24,12 +27,26 @@ type Root struct {
 	contexts []plugin.Entry
 }
 
-func createContext(raw clientcmdapi.Config, name string, access clientcmd.ConfigAccess) (plugin.Entry, error) {
+func createContext(raw clientcmdapi.Config, name string, access clientcmd.ConfigAccess, httpOpts plugin.HTTPOptions) (plugin.Entry, error) {
 	config := clientcmd.NewNonInteractiveClientConfig(raw, name, &clientcmd.ConfigOverrides{}, access)
 	cfg, err := config.ClientConfig()
 	if err != nil {
 		return nil, err
 	}
+	// The context's CAs come from the kubeconfig, but its transport still
+	// needs the configured proxy and IP version. The transport's built by
+	// client-go, so it's configured once it's been constructed.
+	httpOpts.CABundle = ""
+	wrapTransport := cfg.WrapTransport
+	cfg.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if t, ok := rt.(*http.Transport); ok {
+			_ = httpOpts.ConfigureTransport(t)
+		}
+		if wrapTransport != nil {
+			return wrapTransport(rt)
+		}
+		return rt
+	}
 	clientset, err := k8s.NewForConfig(cfg)
 	if err != nil {
 		return nil, err
@@ -42,7 +59,12 @@ func createContext(raw clientcmdapi.Config, name string, access clientcmd.Config
 }
 
 // Init for root
-func (r *Root) Init(map[string]interface{}) error {
+func (r *Root) Init(cfg map[string]interface{}) error {
+	httpOpts, err := plugin.HTTPOptionsFor(cfg)
+	if err != nil {
+		return fmt.Errorf("kubernetes: %v", err)
+	}
+
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
 	raw, err := config.RawConfig()
@@ -55,7 +77,7 @@ func (r *Root) Init(map[string]interface{}) error {
 
 	contexts := make([]plugin.Entry, 0)
 	for name := range raw.Contexts {
-		ctx, err := createContext(raw, name, config.ConfigAccess())
+		ctx, err := createContext(raw, name, config.ConfigAccess(), httpOpts)
 		if err != nil {
 			activity.Warnf(context.Background(), "loading context %v failed: %+v", name, err)
 			continue
@@ -67,6 +89,15 @@ func (r *Root) Init(map[string]interface{}) error {
 	return nil
 }
 
+// Requirements requires ~/.kube/config unless the KUBECONFIG environment
+// variable specifies the kubeconfig files
+func (r *Root) Requirements() plugin.Requirements {
+	if os.Getenv(clientcmd.RecommendedConfigPathEnvVar) != "" {
+		return plugin.Requirements{}
+	}
+	return plugin.Requirements{Files: []string{clientcmd.RecommendedHomeFile}}
+}
+
 // Schema returns the root's schema
 func (r *Root) Schema() *plugin.EntrySchema {
 	return plugin.NewEntrySchema(r, "kubernetes").IsSingleton()
@@ -0,0 +1,167 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/retention"
+	log "github.com/sirupsen/logrus"
+)
+
+// MetadataHistoryDir is the directory that the entries' metadata histories are
+// saved in. Each entry's history is saved to its own file, so histories
+// persist across restarts.
+var MetadataHistoryDir = func() string {
+	cdir, err := os.UserCacheDir()
+	if err != nil {
+		cdir = os.TempDir()
+	}
+	return filepath.Join(cdir, "wash", "metadata_history")
+}()
+
+// Saved histories are pruned according to the "metadata_history" retention
+// policy. A history's file is modified whenever its entry's metadata is
+// observed, so the oldest files are the least recently observed entries'.
+var _ = retention.Register("metadata_history", func() string { return MetadataHistoryDir }, nil)
+
+// MetadataSnapshot is an observation of an entry's metadata. Time is when
+// the metadata was first observed, and LastObserved is when it was last
+// observed without changes.
+type MetadataSnapshot struct {
+	Time         time.Time  `json:"time"`
+	LastObserved time.Time  `json:"last_observed"`
+	Metadata     JSONObject `json:"metadata"`
+}
+
+// metadataHistory is an entry's history's on-disk format
+type metadataHistory struct {
+	ID        string             `json:"id"`
+	Snapshots []MetadataSnapshot `json:"snapshots"`
+}
+
+var metadataHistories struct {
+	// mux serializes the reads and writes of the histories' files
+	mux  sync.Mutex
+	size int
+}
+
+// ConfigureMetadataHistory sets the number of metadata snapshots that are
+// retained per entry. 0 disables metadata history.
+func ConfigureMetadataHistory(size int) error {
+	if size < 0 {
+		return fmt.Errorf("metadata_history must not be negative, not %v", size)
+	}
+	metadataHistories.mux.Lock()
+	defer metadataHistories.mux.Unlock()
+	metadataHistories.size = size
+	return nil
+}
+
+// metadataHistoryPath returns the path of the file that the history of the
+// entry with the given ID is saved to. IDs are hashed since they can contain
+// characters that aren't valid in a file name.
+func metadataHistoryPath(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(MetadataHistoryDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// loadMetadataHistoryLocked loads the history of the entry with the given ID.
+// It returns nil if the entry doesn't have one. metadataHistories.mux must be
+// held.
+func loadMetadataHistoryLocked(id string) (*metadataHistory, error) {
+	data, err := ioutil.ReadFile(metadataHistoryPath(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read the metadata history of %v: %v", id, err)
+	}
+	var h metadataHistory
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("could not decode the metadata history of %v: %v", id, err)
+	}
+	// Hashes could collide, so the entry's ID is checked too
+	if h.ID != id {
+		return nil, nil
+	}
+	return &h, nil
+}
+
+// saveMetadataHistoryLocked saves h. metadataHistories.mux must be held.
+func saveMetadataHistoryLocked(h *metadataHistory) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("could not encode the metadata history of %v: %v", h.ID, err)
+	}
+	if err := os.MkdirAll(MetadataHistoryDir, 0700); err != nil {
+		return fmt.Errorf("could not create the metadata history directory %v: %v", MetadataHistoryDir, err)
+	}
+	if err := ioutil.WriteFile(metadataHistoryPath(h.ID), data, 0600); err != nil {
+		return fmt.Errorf("could not save the metadata history of %v: %v", h.ID, err)
+	}
+	return nil
+}
+
+// recordMetadata records an observation of the entry's metadata. A new
+// snapshot is only taken if the metadata changed since it was last observed.
+func recordMetadata(e Entry, metadata JSONObject) {
+	metadataHistories.mux.Lock()
+	defer metadataHistories.mux.Unlock()
+	size := metadataHistories.size
+	if size <= 0 {
+		return
+	}
+	id := ID(e)
+	h, err := loadMetadataHistoryLocked(id)
+	if err != nil {
+		log.Warnf("Starting a new metadata history: %v", err)
+	}
+	if h == nil {
+		h = &metadataHistory{ID: id}
+	}
+
+	now := time.Now()
+	if n := len(h.Snapshots); n > 0 && reflect.DeepEqual(h.Snapshots[n-1].Metadata, metadata) {
+		h.Snapshots[n-1].LastObserved = now
+	} else {
+		h.Snapshots = append(h.Snapshots, MetadataSnapshot{
+			Time:         now,
+			LastObserved: now,
+			Metadata:     metadata,
+		})
+	}
+	if len(h.Snapshots) > size {
+		h.Snapshots = h.Snapshots[len(h.Snapshots)-size:]
+	}
+	if err := saveMetadataHistoryLocked(h); err != nil {
+		log.Warnf("%v", err)
+	}
+}
+
+// MetadataHistory returns the retained snapshots of the entry's metadata,
+// from oldest to newest. Snapshots are taken whenever the entry's metadata
+// is fetched (i.e. on a cache miss). It returns nil if metadata history is
+// disabled or if the entry's metadata hasn't been fetched yet.
+func MetadataHistory(e Entry) []MetadataSnapshot {
+	metadataHistories.mux.Lock()
+	defer metadataHistories.mux.Unlock()
+	if metadataHistories.size <= 0 {
+		return nil
+	}
+	h, err := loadMetadataHistoryLocked(ID(e))
+	if err != nil {
+		log.Warnf("%v", err)
+		return nil
+	}
+	if h == nil {
+		return nil
+	}
+	return h.Snapshots
+}
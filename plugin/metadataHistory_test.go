@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MetadataHistoryTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *MetadataHistoryTestSuite) SetupTest() {
+	suite.dir = MetadataHistoryDir
+	MetadataHistoryDir = suite.T().TempDir()
+	suite.NoError(ConfigureMetadataHistory(2))
+}
+
+func (suite *MetadataHistoryTestSuite) TearDownTest() {
+	suite.NoError(ConfigureMetadataHistory(0))
+	MetadataHistoryDir = suite.dir
+}
+
+func (suite *MetadataHistoryTestSuite) newEntry(id string) Entry {
+	e := newCacheTestsMockEntry("foo")
+	e.SetTestID(id)
+	return e
+}
+
+func (suite *MetadataHistoryTestSuite) TestMetadataHistory_NotFetched() {
+	suite.Nil(MetadataHistory(suite.newEntry("/foo")))
+}
+
+func (suite *MetadataHistoryTestSuite) TestRecordMetadata() {
+	e := suite.newEntry("/foo")
+	recordMetadata(e, JSONObject{"state": "running"})
+	recordMetadata(e, JSONObject{"state": "running"})
+
+	// Unchanged metadata shouldn't take a new snapshot
+	snapshots := MetadataHistory(e)
+	if suite.Len(snapshots, 1) {
+		suite.Equal(JSONObject{"state": "running"}, snapshots[0].Metadata)
+		suite.False(snapshots[0].LastObserved.Before(snapshots[0].Time))
+	}
+
+	// Only the newest snapshots should be retained
+	recordMetadata(e, JSONObject{"state": "stopped"})
+	recordMetadata(e, JSONObject{"state": "terminated"})
+	snapshots = MetadataHistory(e)
+	if suite.Len(snapshots, 2) {
+		suite.Equal(JSONObject{"state": "stopped"}, snapshots[0].Metadata)
+		suite.Equal(JSONObject{"state": "terminated"}, snapshots[1].Metadata)
+	}
+
+	// Other entries' histories are separate
+	suite.Nil(MetadataHistory(suite.newEntry("/bar")))
+}
+
+func (suite *MetadataHistoryTestSuite) TestRecordMetadata_Persists() {
+	e := suite.newEntry("/foo")
+	recordMetadata(e, JSONObject{"state": "running"})
+	files, err := ioutil.ReadDir(MetadataHistoryDir)
+	if suite.NoError(err) {
+		suite.Len(files, 1)
+	}
+
+	// The history's read from its file, so it survives a restart
+	snapshots := MetadataHistory(suite.newEntry("/foo"))
+	if suite.Len(snapshots, 1) {
+		suite.Equal(JSONObject{"state": "running"}, snapshots[0].Metadata)
+	}
+
+	// A corrupted history's replaced
+	suite.NoError(ioutil.WriteFile(metadataHistoryPath("/foo"), []byte("bad"), 0600))
+	suite.Nil(MetadataHistory(e))
+	recordMetadata(e, JSONObject{"state": "stopped"})
+	suite.Len(MetadataHistory(e), 1)
+}
+
+func (suite *MetadataHistoryTestSuite) TestConfigureMetadataHistory() {
+	suite.EqualError(ConfigureMetadataHistory(-1), "metadata_history must not be negative, not -1")
+}
+
+func (suite *MetadataHistoryTestSuite) TestRecordMetadata_Disabled() {
+	suite.NoError(ConfigureMetadataHistory(0))
+
+	e := suite.newEntry("/foo")
+	recordMetadata(e, JSONObject{"state": "running"})
+	suite.Nil(MetadataHistory(e))
+}
+
+func TestMetadataHistory(t *testing.T) {
+	suite.Run(t, new(MetadataHistoryTestSuite))
+}
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"strings"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+)
+
+var missingEntryTTL = limits.Register(
+	"plugins.missing_entry_ttl_ms",
+	"How many milliseconds a lookup of an entry that doesn't exist is cached for after its parent's cached listing expires, so that repeated lookups of it (e.g. by tab completion or stat) don't list its parent again. They're cached by the parent's path and the entry's cname, and they're cleared along with the parent's cached results or once the parent's relisted. 0 disables caching them.",
+	5000,
+	nil,
+)
+
+// missingOpName is the cache category of the missing entries. Its keys are
+// the missing entries' IDs, so ClearCacheFor clears them along with their
+// parent's cached results. Its values are the generations of the parent's
+// listings that the entries were missing from (see lastListingGeneration).
+const missingOpName = "Missing"
+
+func missingEntryKey(p Parent, cname string) string {
+	return strings.TrimRight(p.id(), "/") + "/" + cname
+}
+
+// cachesMissingEntries returns true if p's missing children are cached. They
+// aren't if p's listing is never cached.
+func cachesMissingEntries(p Parent) bool {
+	return cache != nil && p.id() != "" && missingEntryTTL.Value() > 0 && TTLOf(p, ListOp) >= 0
+}
+
+// IsKnownMissing returns true if p's child with the given cname was recently
+// looked up, but it didn't exist, and p hasn't been relisted since. p's cached
+// listing takes precedence, so it returns false if p's listing is cached.
+func IsKnownMissing(p Parent, cname string) bool {
+	if !cachesMissingEntries(p) || IsCached(p, ListOp) {
+		return false
+	}
+	val, _ := cache.Get(missingOpName, missingEntryKey(p, cname))
+	generation, ok := val.(uint64)
+	return ok && generation == lastListingGeneration(p.id())
+}
+
+// RecordMissing records that p's last listing doesn't have a child with the
+// given cname. It's recorded for as long as the listing's cached, plus
+// plugins.missing_entry_ttl_ms.
+func RecordMissing(p Parent, cname string) {
+	if !cachesMissingEntries(p) {
+		return
+	}
+	ttl := TTLOf(p, ListOp) + time.Duration(missingEntryTTL.Value())*time.Millisecond
+	generation := lastListingGeneration(p.id())
+	record := func() (interface{}, error) {
+		return generation, nil
+	}
+	// Refresh replaces the generation of an earlier listing, but it's a no-op
+	// if the entry isn't recorded yet
+	key := missingEntryKey(p, cname)
+	_ = cache.Refresh(missingOpName, key, ttl, record)
+	_, _ = cache.GetOrUpdate(missingOpName, key, ttl, false, record)
+}
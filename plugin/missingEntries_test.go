@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type MissingEntriesTestSuite struct {
+	suite.Suite
+}
+
+func (suite *MissingEntriesTestSuite) SetupTest() {
+	SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *MissingEntriesTestSuite) TearDownTest() {
+	UnsetTestCache()
+	_, err := limits.Set(missingEntryTTL.Name(), 5000)
+	suite.NoError(err)
+}
+
+type missingEntriesTestsParent struct {
+	mockParent
+	lists int
+}
+
+func (p *missingEntriesTestsParent) List(ctx context.Context) ([]Entry, error) {
+	p.lists++
+	return p.entries, nil
+}
+
+func newMissingEntriesTestsParent() *missingEntriesTestsParent {
+	p := &missingEntriesTestsParent{mockParent: mockParent{EntryBase: NewEntry("root"), entries: []Entry{newMockEntry("foo")}}}
+	p.SetTestID("/root")
+	// The listing expires right away, so lookups have to list the parent
+	// unless the missing entries are cached
+	p.SetTTLOf(ListOp, time.Nanosecond)
+	return p
+}
+
+func (suite *MissingEntriesTestSuite) TestFindEntrySkipsListingMissingEntries() {
+	p := newMissingEntriesTestsParent()
+	ctx := context.Background()
+	_, err := FindEntry(ctx, p, []string{"bar"})
+	suite.EqualError(err, "The bar entry does not exist")
+	lists := p.lists
+	for i := 0; i < 3; i++ {
+		_, err := FindEntry(ctx, p, []string{"bar"})
+		suite.EqualError(err, "The bar entry does not exist")
+	}
+	suite.Equal(lists, p.lists)
+	suite.True(IsKnownMissing(p, "bar"))
+	suite.False(IsKnownMissing(p, "foo"))
+
+	// Clearing the parent's cached results clears its missing entries
+	_, err = ClearCacheFor("/root")
+	suite.NoError(err)
+	suite.False(IsKnownMissing(p, "bar"))
+	p.entries = append(p.entries, newMockEntry("bar"))
+	_, err = FindEntry(ctx, p, []string{"bar"})
+	suite.NoError(err)
+}
+
+func (suite *MissingEntriesTestSuite) TestDefaults() {
+	// The default TTLs of a listing and of its missing entries
+	p := &missingEntriesTestsParent{mockParent: mockParent{EntryBase: NewEntry("root"), entries: []Entry{newMockEntry("foo")}}}
+	p.SetTestID("/root")
+	suite.Equal(15*time.Second, TTLOf(p, ListOp))
+	suite.Equal(5000, missingEntryTTL.Value())
+
+	ctx := context.Background()
+	_, err := FindEntry(ctx, p, []string{"bar"})
+	suite.EqualError(err, "The bar entry does not exist")
+	suite.False(IsKnownMissing(p, "bar"))
+
+	// Once the listing expires, the missing entry's still known
+	expireListing := func() {
+		cache.Delete(regexp.MustCompile("^List::/root$"))
+	}
+	expireListing()
+	suite.True(IsKnownMissing(p, "bar"))
+	lists := p.lists
+	_, err = FindEntry(ctx, p, []string{"bar"})
+	suite.EqualError(err, "The bar entry does not exist")
+	suite.Equal(lists, p.lists)
+
+	// Relisting the parent forgets the missing entries of its earlier listing
+	p.entries = append(p.entries, newMockEntry("bar"))
+	_, err = CachedList(ctx, p)
+	suite.NoError(err)
+	expireListing()
+	suite.False(IsKnownMissing(p, "bar"))
+	_, err = FindEntry(ctx, p, []string{"bar"})
+	suite.NoError(err)
+}
+
+func (suite *MissingEntriesTestSuite) TestCachedListingTakesPrecedence() {
+	p := newMissingEntriesTestsParent()
+	RecordMissing(p, "foo")
+	suite.True(IsKnownMissing(p, "foo"))
+	p.SetTTLOf(ListOp, time.Minute)
+	_, err := CachedList(context.Background(), p)
+	suite.NoError(err)
+	suite.False(IsKnownMissing(p, "foo"))
+}
+
+func (suite *MissingEntriesTestSuite) TestDisabled() {
+	p := newMissingEntriesTestsParent()
+	_, err := limits.Set(missingEntryTTL.Name(), 0)
+	suite.NoError(err)
+	RecordMissing(p, "bar")
+	suite.False(IsKnownMissing(p, "bar"))
+
+	// Entries whose listings aren't cached don't cache their missing entries
+	_, err = limits.Set(missingEntryTTL.Name(), 5000)
+	suite.NoError(err)
+	p.DisableDefaultCaching()
+	RecordMissing(p, "bar")
+	suite.False(IsKnownMissing(p, "bar"))
+}
+
+func TestMissingEntries(t *testing.T) {
+	suite.Run(t, new(MissingEntriesTestSuite))
+}
@@ -0,0 +1,379 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+)
+
+// OperationStatus is the status of an asynchronous operation
+type OperationStatus = string
+
+// These are the possible operation statuses
+const (
+	OperationRunning   OperationStatus = "running"
+	OperationSucceeded OperationStatus = "succeeded"
+	OperationFailed    OperationStatus = "failed"
+	OperationCancelled OperationStatus = "cancelled"
+)
+
+// Finished operations (and their results) are retained for operationRetention
+const operationRetention = 1 * time.Hour
+
+// operationResultsSize is the maximum size of the retained results
+var operationResultsSize = limits.Register(
+	"plugins.operation_results_mb",
+	"The maximum size (in megabytes) of the retained results of finished asynchronous operations (e.g. `GET /fs/read?async=true`), which are spilled to disk. Once it's exceeded, the operations that finished first are removed before they expire. Operations whose result alone exceeds it fail. 0 means unlimited.",
+	256,
+	nil,
+)
+
+// operationResultsDir is where the operations' results are spilled. Each
+// result's file is unlinked as soon as it's created, so the files never
+// outlive the Wash server (even if it crashes).
+var operationResultsDir = func() string {
+	cdir, err := os.UserCacheDir()
+	if err != nil {
+		cdir = os.TempDir()
+	}
+	return filepath.Join(cdir, "wash", "operations")
+}()
+
+// operationResult is an operation's result, which is spilled to an unlinked
+// file. refs is the number of open readers, plus one while the operation's
+// retained. The file's closed once it drops to 0. refs is guarded by the
+// operation's mux.
+type operationResult struct {
+	file *os.File
+	size int64
+	refs int
+}
+
+func newOperationResult() (*operationResult, error) {
+	if err := os.MkdirAll(operationResultsDir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(operationResultsDir, "result-")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &operationResult{file: f, refs: 1}, nil
+}
+
+// Write writes p to the result's file. It errors once the result exceeds the
+// plugins.operation_results_mb limit since the result could never be
+// retained.
+func (r *operationResult) Write(p []byte) (int, error) {
+	if max := maxOperationResultsSize(); max > 0 && r.size+int64(len(p)) > max {
+		return 0, fmt.Errorf("the result is bigger than the %v limit", operationResultsSize.Name())
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *operationResult) release() {
+	r.refs--
+	if r.refs == 0 {
+		r.file.Close()
+	}
+}
+
+func maxOperationResultsSize() int64 {
+	return int64(operationResultsSize.Value()) * 1024 * 1024
+}
+
+// Operation is an action that's run asynchronously, e.g. a slow read. The
+// operation's result is spilled to disk (see operationResultsDir), and it's
+// available until the operation expires.
+type Operation struct {
+	mux      sync.Mutex
+	id       string
+	action   string
+	path     string
+	started  time.Time
+	finished time.Time
+	status   OperationStatus
+	done     int64
+	total    int64
+	err      error
+	result   *operationResult
+	cancel   context.CancelFunc
+	// journalID is the ID of the journal of the request that started the
+	// operation. It's used to cancel the operations of interrupted commands.
+	journalID string
+}
+
+// OperationInfo describes an operation
+type OperationInfo struct {
+	ID       string          `json:"id"`
+	Action   string          `json:"action"`
+	Path     string          `json:"path"`
+	Status   OperationStatus `json:"status"`
+	Started  time.Time       `json:"started"`
+	Finished time.Time       `json:"finished,omitempty"`
+	// Done and Total describe the operation's progress, e.g. the number of
+	// bytes that were read. Total is 0 if it isn't known.
+	Done  int64  `json:"done"`
+	Total int64  `json:"total"`
+	Err   string `json:"error,omitempty"`
+}
+
+var operationsMux sync.Mutex
+var operations = make(map[string]*Operation)
+var lastOperationID int64
+
+// ID returns the operation's ID
+func (o *Operation) ID() string {
+	return o.id
+}
+
+// SetProgress reports that done of total units (e.g. bytes) were processed.
+// total can be 0 if it isn't known.
+func (o *Operation) SetProgress(done int64, total int64) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.done, o.total = done, total
+}
+
+// Info returns a description of the operation's current state
+func (o *Operation) Info() OperationInfo {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	info := OperationInfo{
+		ID:       o.id,
+		Action:   o.action,
+		Path:     o.path,
+		Status:   o.status,
+		Started:  o.started,
+		Finished: o.finished,
+		Done:     o.done,
+		Total:    o.total,
+	}
+	if o.err != nil {
+		info.Err = o.err.Error()
+	}
+	return info
+}
+
+// Cancel cancels the operation if it's running
+func (o *Operation) Cancel() {
+	o.cancel()
+}
+
+// Result returns the operation's result. It errors if the operation didn't
+// succeed. Callers must close the returned reader.
+func (o *Operation) Result() (io.ReadCloser, error) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	switch o.status {
+	case OperationRunning:
+		return nil, fmt.Errorf("operation %v is still running", o.id)
+	case OperationSucceeded:
+		if o.result == nil {
+			return nil, fmt.Errorf("operation %v expired", o.id)
+		}
+		o.result.refs++
+		return &operationResultReader{
+			SectionReader: io.NewSectionReader(o.result.file, 0, o.result.size),
+			o:             o,
+			result:        o.result,
+		}, nil
+	default:
+		return nil, fmt.Errorf("operation %v %v: %v", o.id, o.status, o.err)
+	}
+}
+
+// operationResultReader reads an operation's result. It holds a reference to
+// the result until it's closed so that removing the operation doesn't close
+// the result's file while it's still being read.
+type operationResultReader struct {
+	*io.SectionReader
+	o      *Operation
+	result *operationResult
+	once   sync.Once
+}
+
+func (r *operationResultReader) Close() error {
+	r.once.Do(func() {
+		r.o.mux.Lock()
+		defer r.o.mux.Unlock()
+		r.result.release()
+	})
+	return nil
+}
+
+// remove releases the operation's result once it's removed from the
+// operations registry
+func (o *Operation) remove() {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if o.result != nil {
+		o.result.release()
+		o.result = nil
+	}
+}
+
+// StartOperation runs op in the background. op writes its result to the
+// provided writer and reports its progress via the provided operation. The
+// operation's context is detached from ctx's cancellation so that it outlives
+// the request that started it, but it's still logged to ctx's journal.
+func StartOperation(ctx context.Context, action string, path string, op func(context.Context, *Operation, io.Writer) error) *Operation {
+	operationsMux.Lock()
+	gcOperations(time.Now())
+	lastOperationID++
+	opCtx, cancel := context.WithCancel(DetachedContext(ctx))
+	o := &Operation{
+		id:      strconv.FormatInt(lastOperationID, 10),
+		action:  action,
+		path:    path,
+		started: time.Now(),
+		status:  OperationRunning,
+		cancel:  cancel,
+	}
+	if journal, ok := ctx.Value(activity.JournalKey).(activity.Journal); ok {
+		o.journalID = journal.ID
+	}
+	operations[o.id] = o
+	operationsMux.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := newOperationResult()
+		if err != nil {
+			err = fmt.Errorf("could not create the operation's result: %v", err)
+		} else {
+			err = op(opCtx, o, result)
+			if err != nil {
+				result.release()
+			}
+		}
+
+		o.mux.Lock()
+		o.finished = time.Now()
+		switch {
+		case err == nil:
+			o.status, o.result = OperationSucceeded, result
+		case opCtx.Err() == context.Canceled:
+			o.status, o.err = OperationCancelled, err
+		default:
+			o.status, o.err = OperationFailed, err
+		}
+		activity.Record(opCtx, "Operation %v (%v %v) %v", o.id, action, path, o.status)
+		o.mux.Unlock()
+
+		operationsMux.Lock()
+		gcOperations(time.Now())
+		operationsMux.Unlock()
+	}()
+	return o
+}
+
+// FindOperation returns the operation with the given ID
+func FindOperation(id string) (*Operation, bool) {
+	operationsMux.Lock()
+	defer operationsMux.Unlock()
+	o, ok := operations[id]
+	return o, ok
+}
+
+// CancelOperationsOf cancels the running operations that were started by
+// requests from the journal with the given ID (e.g. by an interrupted wash
+// command), and returns them
+func CancelOperationsOf(journalID string) []*Operation {
+	operationsMux.Lock()
+	defer operationsMux.Unlock()
+	var cancelled []*Operation
+	for _, o := range operations {
+		if o.journalID != journalID || o.Info().Status != OperationRunning {
+			continue
+		}
+		o.Cancel()
+		cancelled = append(cancelled, o)
+	}
+	sort.Slice(cancelled, func(i, j int) bool {
+		return cancelled[i].started.Before(cancelled[j].started)
+	})
+	return cancelled
+}
+
+// Operations returns the running operations and the recently finished ones,
+// sorted by when they started
+func Operations() []*Operation {
+	operationsMux.Lock()
+	defer operationsMux.Unlock()
+	gcOperations(time.Now())
+	ops := make([]*Operation, 0, len(operations))
+	for _, o := range operations {
+		ops = append(ops, o)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].started.Before(ops[j].started)
+	})
+	return ops
+}
+
+// gcOperations removes the operations that finished more than
+// operationRetention ago. It then removes the operations that finished first
+// until their results fit in the plugins.operation_results_mb limit. It must
+// be called with operationsMux held.
+func gcOperations(now time.Time) {
+	var finished []*Operation
+	var size int64
+	for id, o := range operations {
+		o.mux.Lock()
+		running, finishedAt, resultSize := o.status == OperationRunning, o.finished, o.resultSize()
+		o.mux.Unlock()
+		if running {
+			continue
+		}
+		if now.Sub(finishedAt) > operationRetention {
+			delete(operations, id)
+			o.remove()
+			continue
+		}
+		finished = append(finished, o)
+		size += resultSize
+	}
+
+	maxSize := maxOperationResultsSize()
+	if maxSize <= 0 || size <= maxSize {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].Info().Finished.Before(finished[j].Info().Finished)
+	})
+	for _, o := range finished {
+		if size <= maxSize {
+			break
+		}
+		delete(operations, o.id)
+		o.mux.Lock()
+		size -= o.resultSize()
+		o.mux.Unlock()
+		o.remove()
+	}
+}
+
+// resultSize returns the size of the operation's result. It must be called
+// with o.mux held.
+func (o *Operation) resultSize() int64 {
+	if o.result == nil {
+		return 0
+	}
+	return o.result.size
+}
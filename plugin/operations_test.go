@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type OperationsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *OperationsTestSuite) TearDownTest() {
+	operationsMux.Lock()
+	operations = make(map[string]*Operation)
+	operationsMux.Unlock()
+	_, err := limits.Set(operationResultsSize.Name(), 256)
+	suite.NoError(err)
+}
+
+func (suite *OperationsTestSuite) waitFor(o *Operation) OperationInfo {
+	for i := 0; i < 100 && o.Info().Status == OperationRunning; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return o.Info()
+}
+
+func (suite *OperationsTestSuite) TestSuccessfulOperation() {
+	o := StartOperation(context.Background(), "read", "/foo", func(ctx context.Context, o *Operation, w io.Writer) error {
+		o.SetProgress(5, 5)
+		_, err := io.WriteString(w, "hello")
+		return err
+	})
+
+	info := suite.waitFor(o)
+	suite.Equal(OperationSucceeded, info.Status)
+	suite.Equal("read", info.Action)
+	suite.Equal("/foo", info.Path)
+	suite.Equal(int64(5), info.Done)
+	suite.False(info.Finished.IsZero())
+
+	result, err := o.Result()
+	if suite.NoError(err) {
+		defer result.Close()
+		content, err := ioutil.ReadAll(result)
+		suite.NoError(err)
+		suite.Equal("hello", string(content))
+	}
+
+	found, ok := FindOperation(o.ID())
+	suite.True(ok)
+	suite.Equal(o, found)
+	suite.Equal([]*Operation{o}, Operations())
+}
+
+func (suite *OperationsTestSuite) TestFailedOperation() {
+	o := StartOperation(context.Background(), "read", "/foo", func(ctx context.Context, o *Operation, w io.Writer) error {
+		return fmt.Errorf("failed")
+	})
+
+	info := suite.waitFor(o)
+	suite.Equal(OperationFailed, info.Status)
+	suite.Equal("failed", info.Err)
+	_, err := o.Result()
+	suite.Error(err)
+}
+
+func (suite *OperationsTestSuite) TestCancelledOperation() {
+	// The operation should outlive the context that started it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	o := StartOperation(ctx, "read", "/foo", func(ctx context.Context, o *Operation, w io.Writer) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	suite.Equal(OperationRunning, o.Info().Status)
+
+	o.Cancel()
+	suite.Equal(OperationCancelled, suite.waitFor(o).Status)
+}
+
+func (suite *OperationsTestSuite) TestCancelOperationsOf() {
+	wait := func(ctx context.Context, o *Operation, w io.Writer) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	ctx := context.WithValue(context.Background(), activity.JournalKey, activity.Journal{ID: "1"})
+	o := StartOperation(ctx, "read", "/foo", wait)
+	other := StartOperation(context.Background(), "read", "/bar", wait)
+	defer other.Cancel()
+
+	suite.Equal([]*Operation{o}, CancelOperationsOf("1"))
+	suite.Equal(OperationCancelled, suite.waitFor(o).Status)
+	suite.Equal(OperationRunning, other.Info().Status)
+	// Finished operations aren't cancelled again
+	suite.Empty(CancelOperationsOf("1"))
+}
+
+func (suite *OperationsTestSuite) TestGCOperations() {
+	o := StartOperation(context.Background(), "read", "/foo", func(ctx context.Context, o *Operation, w io.Writer) error {
+		return nil
+	})
+	suite.waitFor(o)
+
+	operationsMux.Lock()
+	gcOperations(time.Now().Add(2 * operationRetention))
+	operationsMux.Unlock()
+	_, ok := FindOperation(o.ID())
+	suite.False(ok)
+}
+
+func (suite *OperationsTestSuite) TestGCOperations_RemovesTheOldestResultsOnceTheyDontFit() {
+	_, err := limits.Set(operationResultsSize.Name(), 1)
+	suite.NoError(err)
+	write := func(ctx context.Context, o *Operation, w io.Writer) error {
+		_, err := w.Write(make([]byte, 600*1024))
+		return err
+	}
+	first := StartOperation(context.Background(), "read", "/foo", write)
+	suite.waitFor(first)
+	second := StartOperation(context.Background(), "read", "/bar", write)
+	suite.waitFor(second)
+
+	operationsMux.Lock()
+	gcOperations(time.Now())
+	operationsMux.Unlock()
+	_, ok := FindOperation(first.ID())
+	suite.False(ok)
+	_, ok = FindOperation(second.ID())
+	suite.True(ok)
+}
+
+func (suite *OperationsTestSuite) TestResultsAreReadableAfterTheOperationsRemoved() {
+	o := StartOperation(context.Background(), "read", "/foo", func(ctx context.Context, o *Operation, w io.Writer) error {
+		_, err := w.Write([]byte("content"))
+		return err
+	})
+	suite.waitFor(o)
+	result, err := o.Result()
+	if !suite.NoError(err) {
+		return
+	}
+	defer result.Close()
+
+	operationsMux.Lock()
+	gcOperations(time.Now().Add(2 * operationRetention))
+	operationsMux.Unlock()
+	content, err := ioutil.ReadAll(result)
+	suite.NoError(err)
+	suite.Equal("content", string(content))
+
+	_, err = o.Result()
+	suite.Error(err)
+}
+
+func (suite *OperationsTestSuite) TestFailsOperationsWhoseResultExceedsTheLimit() {
+	_, err := limits.Set(operationResultsSize.Name(), 1)
+	suite.NoError(err)
+	o := StartOperation(context.Background(), "read", "/foo", func(ctx context.Context, o *Operation, w io.Writer) error {
+		_, err := w.Write(make([]byte, 2*1024*1024))
+		return err
+	})
+	info := suite.waitFor(o)
+	suite.Equal(OperationFailed, info.Status)
+	suite.Contains(info.Err, "plugins.operation_results_mb")
+}
+
+func TestOperations(t *testing.T) {
+	suite.Run(t, new(OperationsTestSuite))
+}
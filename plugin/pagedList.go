@@ -0,0 +1,49 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+)
+
+// pagedLister is a Parent whose children can be listed a page at a time.
+// External plugin entries are pagedListers since their scripts can page their
+// list results.
+type pagedLister interface {
+	Parent
+	listPage(ctx context.Context, page string, onChild func(Entry) error) (string, error)
+}
+
+// ListPage lists the given page of p's children, keyed by their cname, for API
+// clients that page through parents with lots of children. The first page is
+// the empty page. ListPage returns the next page's token, which is empty if it
+// listed the last page.
+//
+// Pages aren't cached, and they don't affect p's cached listing (see List),
+// which always includes all of p's children. Only the parents whose listings
+// are actually paged have more than one page, so other parents' children are
+// all returned in their first page.
+func ListPage(ctx context.Context, p Parent, page string) (map[string]Entry, string, error) {
+	submitMethodInvocation(ctx, p, "List")
+	pl, ok := p.(pagedLister)
+	if !ok {
+		if page != "" {
+			return nil, "", fmt.Errorf("the list of %v isn't paged, so it doesn't have the page %q", p.id(), page)
+		}
+		entries, err := CachedList(ctx, p)
+		return entries, "", err
+	}
+
+	entries := make(map[string]Entry)
+	addEntry := newChildAdder(p, entries, nil)
+	listCtx := context.WithValue(ctx, parentID, p.id())
+	nextPage, err := pl.listPage(listCtx, page, addEntry)
+	if err != nil {
+		return nil, "", err
+	}
+	if page == "" {
+		if err := addHelpEntry(p, addEntry); err != nil {
+			return nil, "", err
+		}
+	}
+	return entries, nextPage, nil
+}
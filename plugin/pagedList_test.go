@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type PagedListTestSuite struct {
+	suite.Suite
+}
+
+func (suite *PagedListTestSuite) SetupTest() {
+	SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *PagedListTestSuite) TearDownTest() {
+	UnsetTestCache()
+}
+
+func (suite *PagedListTestSuite) newEntry(id string) *externalPluginEntry {
+	entry := &externalPluginEntry{
+		EntryBase: NewEntry("paged"),
+		script:    newExternalPluginScript("", "testdata/pagedList.sh"),
+	}
+	entry.SetTestID(id)
+	return entry
+}
+
+func cnamesOf(entries map[string]Entry) []string {
+	cnames := make([]string, 0, len(entries))
+	for cname := range entries {
+		cnames = append(cnames, cname)
+	}
+	sort.Strings(cnames)
+	return cnames
+}
+
+func (suite *PagedListTestSuite) TestListAggregatesThePages() {
+	entries, err := CachedList(context.Background(), suite.newEntry("/paged"))
+	if suite.NoError(err) {
+		suite.Equal([]string{"a", "b", "c", "d"}, cnamesOf(entries))
+		suite.Equal("/paged/b", entries["b"].id())
+	}
+}
+
+func (suite *PagedListTestSuite) TestListErrorsIfAPageRepeats() {
+	_, err := CachedList(context.Background(), suite.newEntry("/loop"))
+	suite.Regexp("returned the next page \"again\" more than once", err)
+}
+
+func (suite *PagedListTestSuite) TestListEnforcesMaxEntriesAcrossPages() {
+	_, err := limits.Set(maxListEntries.Name(), 2)
+	suite.NoError(err)
+	defer func() {
+		_, _ = limits.Set(maxListEntries.Name(), 100000)
+	}()
+	_, err = CachedList(context.Background(), suite.newEntry("/paged"))
+	suite.Regexp("more than 2 entries were returned across the list's pages", err)
+}
+
+func (suite *PagedListTestSuite) TestListPage() {
+	ctx := context.Background()
+	entry := suite.newEntry("/paged")
+
+	var pages [][]string
+	page := ""
+	for {
+		entries, nextPage, err := ListPage(ctx, entry, page)
+		if !suite.NoError(err) {
+			return
+		}
+		for _, child := range entries {
+			suite.Equal("/paged/"+CName(child), child.id())
+		}
+		pages = append(pages, cnamesOf(entries))
+		if nextPage == "" {
+			break
+		}
+		page = nextPage
+	}
+	suite.Equal([][]string{{"a"}, {"b", "c"}, {"d"}}, pages)
+
+	// Pages aren't cached, and they don't affect the cached listing
+	cached, err := cache.Get(defaultOpCodeToNameMap[ListOp], "/paged")
+	suite.NoError(err)
+	suite.Nil(cached)
+
+	_, _, err = ListPage(ctx, entry, "unknown")
+	suite.Regexp("unknown page", err)
+}
+
+func (suite *PagedListTestSuite) TestListPage_UnpagedParent() {
+	p := &mockParent{EntryBase: NewEntry("parent"), entries: []Entry{newMockEntry("a"), newMockEntry("b")}}
+	p.SetTestID("/parent")
+
+	entries, nextPage, err := ListPage(context.Background(), p, "")
+	if suite.NoError(err) {
+		suite.Equal("", nextPage)
+		suite.Equal([]string{"a", "b"}, cnamesOf(entries))
+	}
+
+	_, _, err = ListPage(context.Background(), p, "p2")
+	suite.Regexp("the list of /parent isn't paged", err)
+}
+
+func TestPagedList(t *testing.T) {
+	suite.Run(t, new(PagedListTestSuite))
+}
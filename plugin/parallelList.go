@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+)
+
+// maxParallelLists limits the number of partitions that ParallelList lists
+// concurrently. The limit is per-call so that nested ParallelList calls can't
+// starve each other.
+var maxParallelLists = limits.Register(
+	"plugins.max_parallel_lists",
+	"The maximum number of regions/projects/etc. that a single parallel listing lists concurrently. 0 means unlimited.",
+	10,
+	nil,
+)
+
+// PartitionLister lists a single partition (e.g. a region) of a parent's
+// children.
+type PartitionLister func(ctx context.Context, partition string) ([]Entry, error)
+
+// ParallelList lists each of the given partitions (e.g. regions, projects or
+// subscriptions) in parallel and returns their combined entries in partition
+// order. Use it instead of a serial loop when a parent's children are spread
+// across several APIs or endpoints.
+//
+// ParallelList tolerates partial failures. If a partition errors, then its
+// entries are replaced with an ErrorEntry named after the partition so that the
+// entries from the other partitions are still listed. ParallelList only errors
+// if all of the partitions errored, in which case it also returns their error
+// entries so that parents that shouldn't fail (e.g. a root that's listed by
+// its Init) can list them instead.
+func ParallelList(ctx context.Context, partitions []string, list PartitionLister) ([]Entry, error) {
+	type result struct {
+		entries []Entry
+		err     error
+	}
+	results := make([]result, len(partitions))
+
+	workers := maxParallelLists.Value()
+	if workers <= 0 || workers > len(partitions) {
+		workers = len(partitions)
+	}
+	indexCh := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for index := range indexCh {
+				entries, err := list(ctx, partitions[index])
+				results[index] = result{entries, err}
+			}
+		}()
+	}
+	for index := range partitions {
+		indexCh <- index
+	}
+	close(indexCh)
+	wg.Wait()
+
+	var entries []Entry
+	var errs []string
+	for index, partition := range partitions {
+		r := results[index]
+		if r.err != nil {
+			activity.Warnf(ctx, "Failed to list %v: %v", partition, r.err)
+			errs = append(errs, fmt.Sprintf("%v: %v", partition, r.err))
+			entries = append(entries, NewErrorEntry(partition, r.err))
+			continue
+		}
+		entries = append(entries, r.entries...)
+	}
+	if len(partitions) > 0 && len(errs) == len(partitions) {
+		return entries, fmt.Errorf("failed to list %v", strings.Join(errs, "; "))
+	}
+	return entries, nil
+}
@@ -0,0 +1,127 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type ParallelListTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ParallelListTestSuite) names(entries []Entry) []string {
+	var names []string
+	for _, entry := range entries {
+		names = append(names, Name(entry))
+	}
+	return names
+}
+
+func (suite *ParallelListTestSuite) TestListsPartitionsInOrder() {
+	entries, err := ParallelList(context.Background(), []string{"a", "b", "c"}, func(ctx context.Context, partition string) ([]Entry, error) {
+		// Finish the partitions in reverse order
+		time.Sleep(time.Duration('c'-partition[0]) * time.Millisecond)
+		return []Entry{newMockEntry(partition + "1"), newMockEntry(partition + "2")}, nil
+	})
+	if suite.NoError(err) {
+		suite.Equal([]string{"a1", "a2", "b1", "b2", "c1", "c2"}, suite.names(entries))
+	}
+}
+
+func (suite *ParallelListTestSuite) TestListsPartitionsInParallel() {
+	var inFlight, maxInFlight int32
+	_, err := ParallelList(context.Background(), []string{"a", "b", "c"}, func(ctx context.Context, partition string) ([]Entry, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return nil, nil
+	})
+	suite.NoError(err)
+	suite.Equal(int32(3), maxInFlight)
+}
+
+func (suite *ParallelListTestSuite) TestRespectsMaxParallelLists() {
+	_, err := limits.Set(maxParallelLists.Name(), 1)
+	suite.NoError(err)
+	defer func() {
+		_, _ = limits.Set(maxParallelLists.Name(), 10)
+	}()
+
+	var inFlight, maxInFlight int32
+	_, err = ParallelList(context.Background(), []string{"a", "b", "c"}, func(ctx context.Context, partition string) ([]Entry, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		if n > atomic.LoadInt32(&maxInFlight) {
+			atomic.StoreInt32(&maxInFlight, n)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return nil, nil
+	})
+	suite.NoError(err)
+	suite.Equal(int32(1), maxInFlight)
+}
+
+func (suite *ParallelListTestSuite) TestReplacesFailedPartitionsWithErrorEntries() {
+	entries, err := ParallelList(context.Background(), []string{"a", "b"}, func(ctx context.Context, partition string) ([]Entry, error) {
+		if partition == "b" {
+			return nil, fmt.Errorf("b is unavailable")
+		}
+		return []Entry{newMockEntry("a1")}, nil
+	})
+	if !suite.NoError(err) {
+		return
+	}
+	suite.Equal([]string{"a1", "b"}, suite.names(entries))
+
+	errorEntry, ok := entries[1].(*ErrorEntry)
+	if suite.True(ok) {
+		suite.EqualError(errorEntry.Err(), "b is unavailable")
+		suite.Equal(JSONObject{"error": "b is unavailable"}, errorEntry.Attributes().Meta())
+
+		rdr, err := errorEntry.Open(context.Background())
+		if suite.NoError(err) {
+			content, err := ioutil.ReadAll(rdr.(*bytes.Reader))
+			suite.NoError(err)
+			suite.Equal("b is unavailable\n", string(content))
+			suite.Equal(uint64(len(content)), errorEntry.Attributes().Size())
+		}
+	}
+}
+
+func (suite *ParallelListTestSuite) TestErrorsIfAllPartitionsFail() {
+	entries, err := ParallelList(context.Background(), []string{"a", "b"}, func(ctx context.Context, partition string) ([]Entry, error) {
+		return nil, fmt.Errorf("%v is unavailable", partition)
+	})
+	suite.EqualError(err, "failed to list a: a is unavailable; b: b is unavailable")
+	// The error entries are still returned
+	if suite.Len(entries, 2) {
+		suite.IsType(&ErrorEntry{}, entries[0])
+		suite.IsType(&ErrorEntry{}, entries[1])
+	}
+}
+
+func (suite *ParallelListTestSuite) TestNoPartitions() {
+	entries, err := ParallelList(context.Background(), nil, func(ctx context.Context, partition string) ([]Entry, error) {
+		panic("should not be called")
+	})
+	suite.NoError(err)
+	suite.Empty(entries)
+}
+
+func TestParallelList(t *testing.T) {
+	suite.Run(t, new(ParallelListTestSuite))
+}
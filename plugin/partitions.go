@@ -0,0 +1,286 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/puppetlabs/wash/limits"
+	log "github.com/sirupsen/logrus"
+)
+
+// These limits keep giant flat directories (e.g. S3 prefixes or namespaces
+// with 100k+ children) usable from readdir and shells by presenting their
+// children in synthetic sub-directories
+var partitionThreshold = limits.Register(
+	"plugins.partition_threshold",
+	"Parents with more than this many children are presented as synthetic sub-directories (partitions) of them so that readdir and shells remain usable. Their flat listing is still available via `wash ls --flat`. 0 disables partitioning.",
+	10000,
+	nil,
+)
+
+var partitionSize = limits.Register(
+	"plugins.partition_size",
+	"The maximum number of children in each of a partitioned parent's automatic partitions. 0 means plugins.partition_threshold.",
+	1000,
+	nil,
+)
+
+// Partitioner can be implemented by parents that know how their children
+// should be partitioned once there's more than plugins.partition_threshold of
+// them, e.g. by date for a bucket of logs. PartitionOf returns the name of the
+// child's partition; see PartitionByMtime and PartitionByHash. Children with
+// an empty partition are put in the "_" partition. Without a Partitioner,
+// children are partitioned into ranges of their sorted cnames.
+type Partitioner interface {
+	Parent
+	PartitionOf(child Entry) string
+}
+
+// PartitionByMtime returns the child's mtime formatted with the given layout
+// (e.g. "2006-01" for monthly partitions), or "" if it doesn't have one
+func PartitionByMtime(child Entry, layout string) string {
+	attr := child.attributes()
+	if !attr.HasMtime() {
+		return ""
+	}
+	return attr.Mtime().Format(layout)
+}
+
+// PartitionByHash returns one of n partitions, named 0 to n-1, based on the
+// hash of the child's cname. Use it when the children's names don't have a
+// useful order.
+func PartitionByHash(child Entry, n int) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(CName(child)))
+	return fmt.Sprintf("%d", h.Sum32()%uint32(n))
+}
+
+// partitionEntry is a synthetic directory containing some of a partitioned
+// parent's children. Partitions only affect navigation. Their children keep
+// the IDs that they have in their parent's flat listing, so they can also be
+// found via the partitioned parent (see FindEntry).
+type partitionEntry struct {
+	EntryBase
+	// children contains all of the partition's children, keyed by their cname
+	children map[string]Entry
+	// byRange is true if the partition is a range of cnames. Range partitions
+	// are never partitioned again.
+	byRange bool
+	// generation is the generation of the parent's listing that the partition
+	// was created from. Partitions aren't listed, so their own partitions are
+	// memoized with it.
+	generation uint64
+}
+
+func newPartitionEntry(parent Parent, name string, children map[string]Entry, byRange bool) *partitionEntry {
+	e := &partitionEntry{
+		EntryBase: NewEntry(name),
+		children:  children,
+		byRange:   byRange,
+	}
+	e.setID(strings.TrimRight(parent.id(), "/") + "/" + name)
+	return e
+}
+
+func (e *partitionEntry) Schema() *EntrySchema {
+	return nil
+}
+
+func (e *partitionEntry) ChildSchemas() []*EntrySchema {
+	return nil
+}
+
+func (e *partitionEntry) List(ctx context.Context) ([]Entry, error) {
+	entries := make([]Entry, 0, len(e.children))
+	for _, child := range e.children {
+		entries = append(entries, child)
+	}
+	return entries, nil
+}
+
+// PartitionedList is like List, except that parents with more than
+// plugins.partition_threshold children are listed as partitions of their
+// children. Use it when the listing is meant for navigation, e.g. in readdir.
+func PartitionedList(ctx context.Context, p Parent) (map[string]Entry, error) {
+	submitMethodInvocation(ctx, p, "List")
+	return partitionedList(ctx, p)
+}
+
+func partitionedList(ctx context.Context, p Parent) (map[string]Entry, error) {
+	entries, err := CachedList(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	threshold := partitionThreshold.Value()
+	if threshold <= 0 || len(entries) <= threshold {
+		return entries, nil
+	}
+	if pe, ok := p.(*partitionEntry); ok && pe.byRange {
+		return entries, nil
+	}
+	return partitionsOf(p, entries, generationOf(p, entries)), nil
+}
+
+// generationOf returns the generation of p's listing of the entries (see
+// generationOfListing)
+func generationOf(p Parent, entries map[string]Entry) uint64 {
+	if pe, ok := p.(*partitionEntry); ok {
+		return pe.generation
+	}
+	return generationOfListing(p.id(), entries)
+}
+
+// partitionsOpName is the cache category of the memoized partitions. They're
+// keyed by <parent_id>/<generation>, so they're cleared along with their
+// parent's cached results.
+const partitionsOpName = "Partitions"
+
+func partitionsKey(p Parent, generation uint64) string {
+	return fmt.Sprintf("%v/%v", strings.TrimRight(p.id(), "/"), generation)
+}
+
+type partitionView struct {
+	size       int
+	partitions map[string]Entry
+}
+
+// partitionsOf returns the partitions of p's entries. They're memoized for as
+// long as p's listing is cached, so they're only recomputed when p's relisted.
+// A generation of 0 means that they aren't memoized.
+func partitionsOf(p Parent, entries map[string]Entry, generation uint64) map[string]Entry {
+	size := partitionSize.Value()
+	if size <= 0 {
+		size = partitionThreshold.Value()
+	}
+	partition := func() map[string]Entry {
+		partitions := partitionWithoutCollisions(p, entries, size)
+		for _, partition := range partitions {
+			if pe, ok := partition.(*partitionEntry); ok {
+				pe.generation = generation
+			}
+		}
+		return partitions
+	}
+
+	ttl := TTLOf(p, ListOp)
+	if cache == nil || p.id() == "" || generation == 0 || ttl < 0 {
+		return partition()
+	}
+	val, err := cache.GetOrUpdate(partitionsOpName, partitionsKey(p, generation), ttl, false, func() (interface{}, error) {
+		return partitionView{size: size, partitions: partition()}, nil
+	})
+	if view, ok := val.(partitionView); ok && err == nil && view.size == size {
+		return view.partitions
+	}
+	// The partition size changed since they were memoized
+	return partition()
+}
+
+// partitionWithoutCollisions partitions the entries. A partition can't have
+// the same name as one of the entries, since FindEntry would find the
+// partition instead of the entry. Partitions that collide are rejected in
+// favor of range partitions, and colliding range partitions are rejected in
+// favor of the flat listing.
+func partitionWithoutCollisions(p Parent, entries map[string]Entry, size int) map[string]Entry {
+	if partitioner, ok := p.(Partitioner); ok {
+		partitions := partitionWith(partitioner, entries)
+		name, collides := collisionOf(partitions, entries)
+		if !collides {
+			return partitions
+		}
+		log.Warnf("Partitioning %v by range since its %v partition has the same name as one of its children", p.id(), name)
+	}
+	partitions := partitionByRange(p, entries, size)
+	if name, collides := collisionOf(partitions, entries); collides {
+		log.Warnf("Not partitioning %v since its %v partition has the same name as one of its children", p.id(), name)
+		return entries
+	}
+	return partitions
+}
+
+func collisionOf(partitions map[string]Entry, entries map[string]Entry) (string, bool) {
+	for name := range partitions {
+		if _, ok := entries[name]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func partitionWith(p Partitioner, entries map[string]Entry) map[string]Entry {
+	grouped := make(map[string]map[string]Entry)
+	for cname, entry := range entries {
+		name := strings.Replace(p.PartitionOf(entry), "/", "#", -1)
+		if name == "" {
+			name = "_"
+		}
+		if grouped[name] == nil {
+			grouped[name] = make(map[string]Entry)
+		}
+		grouped[name][cname] = entry
+	}
+	partitions := make(map[string]Entry, len(grouped))
+	for name, children := range grouped {
+		partitions[name] = newPartitionEntry(p, name, children, false)
+	}
+	return partitions
+}
+
+// partitionByRange partitions the entries into groups of size entries with
+// consecutive cnames. Each group is named <first>..<last>, where <first> and
+// <last> are the prefixes of its first and last cnames that are just long
+// enough to distinguish the group from its neighbors, e.g. "log0..log1".
+func partitionByRange(p Parent, entries map[string]Entry, size int) map[string]Entry {
+	cnames := make([]string, 0, len(entries))
+	for cname := range entries {
+		cnames = append(cnames, cname)
+	}
+	sort.Strings(cnames)
+
+	partitions := make(map[string]Entry)
+	for start := 0; start < len(cnames); start += size {
+		end := start + size
+		if end > len(cnames) {
+			end = len(cnames)
+		}
+		first, last := []rune(cnames[start]), []rune(cnames[end-1])
+		n := 1
+		if start > 0 {
+			if m := commonPrefixLen(first, []rune(cnames[start-1])) + 1; m > n {
+				n = m
+			}
+		}
+		if end < len(cnames) {
+			if m := commonPrefixLen(last, []rune(cnames[end])) + 1; m > n {
+				n = m
+			}
+		}
+		name := string(prefixOf(first, n)) + ".." + string(prefixOf(last, n))
+
+		children := make(map[string]Entry, end-start)
+		for _, cname := range cnames[start:end] {
+			children[cname] = entries[cname]
+		}
+		partitions[name] = newPartitionEntry(p, name, children, true)
+	}
+	return partitions
+}
+
+func commonPrefixLen(a []rune, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// prefixOf returns s's first n runes, or all of s if it's shorter
+func prefixOf(s []rune, n int) []rune {
+	if n > len(s) {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,235 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type PartitionsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *PartitionsTestSuite) SetupTest() {
+	SetTestCache(datastore.NewMemCache())
+	_, err := limits.Set(partitionThreshold.Name(), 4)
+	suite.NoError(err)
+	_, err = limits.Set(partitionSize.Name(), 2)
+	suite.NoError(err)
+}
+
+func (suite *PartitionsTestSuite) TearDownTest() {
+	UnsetTestCache()
+	_, err := limits.Set(partitionThreshold.Name(), 10000)
+	suite.NoError(err)
+	_, err = limits.Set(partitionSize.Name(), 1000)
+	suite.NoError(err)
+}
+
+func (suite *PartitionsTestSuite) newParent(cnames ...string) *mockParent {
+	parent := &mockParent{EntryBase: NewEntry("bucket")}
+	parent.SetTestID("/s3/bucket")
+	for _, cname := range cnames {
+		parent.entries = append(parent.entries, newMockEntry(cname))
+	}
+	return parent
+}
+
+func (p *mockParent) entriesMap() map[string]Entry {
+	entries := make(map[string]Entry)
+	for _, entry := range p.entries {
+		entries[CName(entry)] = entry
+	}
+	return entries
+}
+
+func (suite *PartitionsTestSuite) cnamesOf(entries map[string]Entry) []string {
+	var cnames []string
+	for cname := range entries {
+		cnames = append(cnames, cname)
+	}
+	return cnames
+}
+
+func (suite *PartitionsTestSuite) TestPartitionedList_BelowThreshold() {
+	parent := suite.newParent("a", "b", "c", "d")
+	entries, err := PartitionedList(context.Background(), parent)
+	if suite.NoError(err) {
+		suite.ElementsMatch([]string{"a", "b", "c", "d"}, suite.cnamesOf(entries))
+	}
+}
+
+func (suite *PartitionsTestSuite) TestPartitionedList_ByRange() {
+	parent := suite.newParent("apple", "apricot", "banana", "blueberry", "cherry")
+	entries, err := PartitionedList(context.Background(), parent)
+	if !suite.NoError(err) {
+		return
+	}
+	suite.ElementsMatch([]string{"a..a", "b..b", "c..c"}, suite.cnamesOf(entries))
+
+	partition := entries["b..b"]
+	suite.Equal("/s3/bucket/b..b", partition.id())
+	children, err := PartitionedList(context.Background(), partition.(Parent))
+	if suite.NoError(err) {
+		suite.ElementsMatch([]string{"banana", "blueberry"}, suite.cnamesOf(children))
+		// The children keep their flat IDs
+		suite.Equal("/s3/bucket/banana", children["banana"].id())
+	}
+
+	// The flat listing is unaffected
+	flatEntries, err := List(context.Background(), parent)
+	if suite.NoError(err) {
+		suite.Len(flatEntries, 5)
+	}
+}
+
+func (suite *PartitionsTestSuite) TestPartitionedList_ZeroThresholdDisablesPartitioning() {
+	_, err := limits.Set(partitionThreshold.Name(), 0)
+	suite.NoError(err)
+	parent := suite.newParent("a", "b", "c", "d", "e")
+	entries, err := PartitionedList(context.Background(), parent)
+	if suite.NoError(err) {
+		suite.Len(entries, 5)
+	}
+}
+
+func (suite *PartitionsTestSuite) TestPartitionedList_MemoizesPartitions() {
+	parent := suite.newParent("a", "b", "c", "d", "e")
+	first, err := PartitionedList(context.Background(), parent)
+	suite.NoError(err)
+	second, err := PartitionedList(context.Background(), parent)
+	suite.NoError(err)
+	suite.True(first["a..b"] == second["a..b"])
+
+	// The partitions are recomputed once the parent's relisted
+	entries, err := CachedList(context.Background(), parent)
+	suite.NoError(err)
+	generation := generationOfListing(parent.id(), entries)
+	suite.NotNil(suite.memoizedPartitions(parent, generation))
+	_, err = ClearCacheFor(parent.id())
+	suite.NoError(err)
+	suite.Nil(suite.memoizedPartitions(parent, generation))
+	parent.entries = append(parent.entries, newMockEntry("f"))
+	third, err := PartitionedList(context.Background(), parent)
+	suite.NoError(err)
+	suite.ElementsMatch([]string{"a..b", "c..d", "e..f"}, suite.cnamesOf(third))
+	suite.False(first["a..b"] == third["a..b"])
+}
+
+func (suite *PartitionsTestSuite) TestPartitionedList_MemoizesPartitionsByGeneration() {
+	parent := suite.newParent("a", "b", "c", "d", "e")
+	entries, err := CachedList(context.Background(), parent)
+	if !suite.NoError(err) {
+		return
+	}
+	generation := generationOfListing(parent.id(), entries)
+	suite.NotZero(generation)
+	first := partitionsOf(parent, entries, generation)
+	suite.True(first["a..b"] == partitionsOf(parent, entries, generation)["a..b"])
+	suite.False(first["a..b"] == partitionsOf(parent, entries, generation+1)["a..b"])
+
+	// Partitions that aren't memoized are recomputed each time
+	suite.False(partitionsOf(parent, entries, 0)["a..b"] == partitionsOf(parent, entries, 0)["a..b"])
+}
+
+func (suite *PartitionsTestSuite) memoizedPartitions(p Parent, generation uint64) interface{} {
+	val, err := cache.Get(partitionsOpName, partitionsKey(p, generation))
+	suite.NoError(err)
+	return val
+}
+
+type mockPartitioner struct {
+	*mockParent
+}
+
+func (p mockPartitioner) PartitionOf(child Entry) string {
+	return PartitionByMtime(child, "2006-01")
+}
+
+func (suite *PartitionsTestSuite) TestPartitionedList_WithPartitioner() {
+	parent := mockPartitioner{suite.newParent()}
+	for i, month := range []time.Month{1, 1, 1, 1, 1, 1, 2} {
+		child := newMockEntry(fmt.Sprintf("log%v", i))
+		child.Attributes().SetMtime(time.Date(2019, month, 1, 0, 0, 0, 0, time.UTC))
+		parent.entries = append(parent.entries, child)
+	}
+	parent.entries = append(parent.entries, newMockEntry("nomtime"))
+
+	entries, err := PartitionedList(context.Background(), parent)
+	if !suite.NoError(err) {
+		return
+	}
+	suite.ElementsMatch([]string{"2019-01", "2019-02", "_"}, suite.cnamesOf(entries))
+
+	// Hinted partitions that are too big are partitioned by range
+	children, err := PartitionedList(context.Background(), entries["2019-01"].(Parent))
+	if suite.NoError(err) {
+		suite.ElementsMatch([]string{"log0..log1", "log2..log3", "log4..log5"}, suite.cnamesOf(children))
+	}
+}
+
+type collidingPartitioner struct {
+	*mockParent
+}
+
+func (p collidingPartitioner) PartitionOf(child Entry) string {
+	return "a"
+}
+
+func (suite *PartitionsTestSuite) TestPartitionedList_RejectsCollidingPartitions() {
+	// The hinted partition collides with the "a" child, so the children are
+	// partitioned by range instead
+	parent := collidingPartitioner{suite.newParent("a", "b", "c", "d", "e")}
+	entries, err := PartitionedList(context.Background(), parent)
+	if suite.NoError(err) {
+		suite.ElementsMatch([]string{"a..b", "c..d", "e..e"}, suite.cnamesOf(entries))
+	}
+
+	// The "a..b" range partition collides with the "a..b" child, so the
+	// children aren't partitioned
+	_, err = limits.Set(partitionSize.Name(), 3)
+	suite.NoError(err)
+	rangeParent := suite.newParent("a", "a..b", "b", "c", "d", "e")
+	rangeParent.SetTestID("/s3/other")
+	entries, err = PartitionedList(context.Background(), rangeParent)
+	if suite.NoError(err) {
+		suite.ElementsMatch([]string{"a", "a..b", "b", "c", "d", "e"}, suite.cnamesOf(entries))
+	}
+}
+
+func (suite *PartitionsTestSuite) TestFindEntry_Partitioned() {
+	parent := suite.newParent("apple", "apricot", "banana", "blueberry", "cherry")
+	for _, segments := range [][]string{{"b..b", "banana"}, {"banana"}} {
+		entry, err := FindEntry(context.Background(), parent, segments)
+		if suite.NoError(err) {
+			suite.Equal("/s3/bucket/banana", entry.id())
+		}
+	}
+	entry, err := FindEntry(context.Background(), parent, []string{"b..b"})
+	if suite.NoError(err) {
+		suite.Equal("b..b", CName(entry))
+	}
+	_, err = FindEntry(context.Background(), parent, []string{"a..a", "banana"})
+	suite.Error(err)
+}
+
+func (suite *PartitionsTestSuite) TestPartitionByRange_Names() {
+	parent := suite.newParent("日本語", "日曜", "ab", "abc", "abd")
+	partitions := partitionByRange(parent, parent.entriesMap(), 2)
+	suite.ElementsMatch([]string{"ab..abc", "abd..日曜", "日本..日本"}, suite.cnamesOf(partitions))
+}
+
+func (suite *PartitionsTestSuite) TestPartitionByHash() {
+	child := newMockEntry("foo")
+	suite.Equal(PartitionByHash(child, 16), PartitionByHash(child, 16))
+	suite.Contains([]string{"0", "1"}, PartitionByHash(child, 2))
+}
+
+func TestPartitions(t *testing.T) {
+	suite.Run(t, new(PartitionsTestSuite))
+}
@@ -0,0 +1,291 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultPinTTL is how often a pinned subtree's cached results are refreshed
+// if the pin doesn't specify a TTL
+const DefaultPinTTL = 30 * time.Second
+
+// MinPinTTL is the shortest refresh interval of a pin. It keeps a pin from
+// hammering its plugin's API.
+const MinPinTTL = 1 * time.Second
+
+// noExpiration is the TTL of a pinned entry's cached results. Pinned results
+// never expire; they're refreshed in the background instead.
+const noExpiration time.Duration = -1
+
+// pinTickInterval is how often the pin tracker refreshes the pinned results
+// that are due for a refresh
+var pinTickInterval = 1 * time.Second
+
+// PinInfo describes a pinned subtree
+type PinInfo struct {
+	Path string `json:"path"`
+	// TTLSeconds is how often the subtree's cached results are refreshed
+	TTLSeconds float64   `json:"ttl_seconds"`
+	PinnedAt   time.Time `json:"pinned_at"`
+	// Results is the number of the subtree's cached List, Open and Metadata
+	// results that are kept warm. A result's kept warm once it's accessed.
+	Results     int       `json:"results"`
+	LastRefresh time.Time `json:"last_refresh,omitempty"`
+	// LastError is the error of the subtree's most recent failed refresh, if
+	// any. Results whose refresh fails keep their previous value.
+	LastError string `json:"last_error,omitempty"`
+}
+
+type pinnedSubtree struct {
+	path        string
+	ttl         time.Duration
+	pinnedAt    time.Time
+	lastRefresh time.Time
+	lastErr     error
+	// jobs is keyed by <op_name>::<entry_id>, like the cache
+	jobs map[string]*pinJob
+}
+
+type pinJob struct {
+	id         string
+	opName     string
+	op         opFunc
+	refreshAt  time.Time
+	refreshing bool
+}
+
+// pinTracker tracks the pinned subtrees. Unlike the popularity tracker, it's
+// always set so that entries can be pinned regardless of their popularity.
+type pinTracker struct {
+	mux  sync.Mutex
+	pins map[string]*pinnedSubtree
+	now  func() time.Time
+}
+
+var pins = newPinTracker()
+
+func newPinTracker() *pinTracker {
+	return &pinTracker{
+		pins: make(map[string]*pinnedSubtree),
+		now:  time.Now,
+	}
+}
+
+func (p *pinnedSubtree) contains(id string) bool {
+	return p.path == "/" || id == p.path || strings.HasPrefix(id, p.path+"/")
+}
+
+func (p *pinnedSubtree) info() PinInfo {
+	info := PinInfo{
+		Path:        p.path,
+		TTLSeconds:  p.ttl.Seconds(),
+		PinnedAt:    p.pinnedAt,
+		Results:     len(p.jobs),
+		LastRefresh: p.lastRefresh,
+	}
+	if p.lastErr != nil {
+		info.LastError = p.lastErr.Error()
+	}
+	return info
+}
+
+// pinOf returns the innermost pinned subtree that contains the entry with the
+// given ID, or nil if the entry isn't pinned. t.mux must be held.
+func (t *pinTracker) pinOf(id string) *pinnedSubtree {
+	var pin *pinnedSubtree
+	for _, p := range t.pins {
+		if p.contains(id) && (pin == nil || len(p.path) > len(pin.path)) {
+			pin = p
+		}
+	}
+	return pin
+}
+
+func (t *pinTracker) isPinned(id string) bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.pinOf(id) != nil
+}
+
+// keepWarm schedules the entry's op to be refreshed at its pin's interval. It
+// returns false if the entry isn't pinned.
+func (t *pinTracker) keepWarm(id string, opName string, op opFunc) bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	pin := t.pinOf(id)
+	if pin == nil {
+		return false
+	}
+	key := opName + "::" + id
+	if job, ok := pin.jobs[key]; ok {
+		job.op = op
+		return true
+	}
+	pin.jobs[key] = &pinJob{
+		id:        id,
+		opName:    opName,
+		op:        op,
+		refreshAt: t.now().Add(pin.ttl),
+	}
+	return true
+}
+
+func (t *pinTracker) pin(path string, ttl time.Duration) PinInfo {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if pin, ok := t.pins[path]; ok {
+		pin.ttl = ttl
+		for _, job := range pin.jobs {
+			if refreshAt := t.now().Add(ttl); refreshAt.Before(job.refreshAt) {
+				job.refreshAt = refreshAt
+			}
+		}
+		return pin.info()
+	}
+	pin := &pinnedSubtree{
+		path:     path,
+		ttl:      ttl,
+		pinnedAt: t.now(),
+		jobs:     make(map[string]*pinJob),
+	}
+	// Jobs are tracked by their innermost pin, so the jobs of the new pin's
+	// results move from the pins that contain it
+	for _, p := range t.pins {
+		if !p.contains(path) {
+			continue
+		}
+		for key, job := range p.jobs {
+			if pin.contains(job.id) {
+				delete(p.jobs, key)
+				job.refreshAt = t.now().Add(ttl)
+				pin.jobs[key] = job
+			}
+		}
+	}
+	t.pins[path] = pin
+	return pin.info()
+}
+
+func (t *pinTracker) unpin(path string) (PinInfo, bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	pin, ok := t.pins[path]
+	if !ok {
+		return PinInfo{}, false
+	}
+	delete(t.pins, path)
+	return pin.info(), true
+}
+
+// tick refreshes the pinned results that are due for a refresh
+func (t *pinTracker) tick() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	now := t.now()
+	for _, pin := range t.pins {
+		for _, job := range pin.jobs {
+			if job.refreshing || now.Before(job.refreshAt) {
+				continue
+			}
+			job.refreshing = true
+			job.refreshAt = now.Add(pin.ttl)
+			go t.refresh(pin, job)
+		}
+	}
+}
+
+func (t *pinTracker) refresh(pin *pinnedSubtree, job *pinJob) {
+	t.mux.Lock()
+	op := job.op
+	t.mux.Unlock()
+
+	err := cache.Refresh(job.opName, job.id, noExpiration, op)
+	if err != nil {
+		log.Debugf("Failed to refresh the pinned %v::%v: %v", job.opName, job.id, err)
+	}
+
+	t.mux.Lock()
+	job.refreshing = false
+	pin.lastRefresh = t.now()
+	if err != nil {
+		pin.lastErr = err
+	}
+	t.mux.Unlock()
+}
+
+// run ticks the tracker until ctx is cancelled
+func (t *pinTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(pinTickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.tick()
+		}
+	}
+}
+
+func (t *pinTracker) list() []PinInfo {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	infos := make([]PinInfo, 0, len(t.pins))
+	for _, pin := range t.pins {
+		infos = append(infos, pin.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Path < infos[j].Path
+	})
+	return infos
+}
+
+// Pin pins the subtree at path (an entry's ID). The cached List, Open and
+// Metadata results of the pinned entries never expire and are never evicted.
+// Instead, they're refreshed in the background every ttl so that they're
+// always warm and (reasonably) fresh. An entry's results are kept warm once
+// they're accessed. Pinning a pinned subtree updates its TTL.
+func Pin(path string, ttl time.Duration) (PinInfo, error) {
+	if ttl < MinPinTTL {
+		return PinInfo{}, fmt.Errorf("the pin's TTL must be at least %v", MinPinTTL)
+	}
+	return pins.pin(normalizePinPath(path), ttl), nil
+}
+
+// Unpin unpins the subtree at path and clears its cached results so that
+// they're cached with their usual TTLs again. It returns false if the subtree
+// isn't pinned.
+func Unpin(path string) (PinInfo, bool, error) {
+	path = normalizePinPath(path)
+	info, ok := pins.unpin(path)
+	if !ok {
+		return info, false, nil
+	}
+	if _, err := ClearCacheFor(path); err != nil {
+		return info, true, err
+	}
+	return info, true, nil
+}
+
+// Pins returns the pinned subtrees, sorted by their path
+func Pins() []PinInfo {
+	return pins.list()
+}
+
+func normalizePinPath(path string) string {
+	if path = strings.TrimRight(path, "/"); path == "" {
+		return "/"
+	}
+	return path
+}
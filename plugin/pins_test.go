@@ -0,0 +1,172 @@
+package plugin
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type PinsTestSuite struct {
+	suite.Suite
+	cache       *cacheTestsMockCache
+	tracker     *pinTracker
+	origTracker *pinTracker
+	now         time.Time
+}
+
+func (suite *PinsTestSuite) SetupTest() {
+	suite.cache = &cacheTestsMockCache{}
+	SetTestCache(suite.cache)
+	suite.now = time.Now()
+	suite.tracker = newPinTracker()
+	suite.tracker.now = func() time.Time {
+		return suite.now
+	}
+	suite.origTracker = pins
+	pins = suite.tracker
+}
+
+func (suite *PinsTestSuite) TearDownTest() {
+	UnsetTestCache()
+	pins = suite.origTracker
+}
+
+func noopOp() (interface{}, error) {
+	return nil, nil
+}
+
+func (suite *PinsTestSuite) TestPin() {
+	_, err := Pin("/foo", MinPinTTL-1)
+	suite.EqualError(err, "the pin's TTL must be at least 1s")
+
+	pin, err := Pin("/foo/", time.Minute)
+	if suite.NoError(err) {
+		suite.Equal("/foo", pin.Path)
+		suite.Equal(60.0, pin.TTLSeconds)
+		suite.Equal(suite.now, pin.PinnedAt)
+	}
+	suite.True(suite.tracker.isPinned("/foo"))
+	suite.True(suite.tracker.isPinned("/foo/bar"))
+	suite.False(suite.tracker.isPinned("/foobar"))
+	suite.False(suite.tracker.isPinned("/"))
+
+	// Re-pinning updates the TTL
+	pin, err = Pin("/foo", time.Second)
+	if suite.NoError(err) {
+		suite.Equal(1.0, pin.TTLSeconds)
+	}
+	suite.Len(Pins(), 1)
+}
+
+func (suite *PinsTestSuite) TestPinRoot() {
+	_, err := Pin("/", time.Minute)
+	suite.NoError(err)
+	suite.True(suite.tracker.isPinned("/"))
+	suite.True(suite.tracker.isPinned("/foo/bar"))
+}
+
+func (suite *PinsTestSuite) TestKeepWarm() {
+	suite.False(suite.tracker.keepWarm("/foo", "List", noopOp))
+
+	_, _ = Pin("/foo", time.Minute)
+	suite.True(suite.tracker.keepWarm("/foo/bar", "List", noopOp))
+	suite.True(suite.tracker.keepWarm("/foo/bar", "List", noopOp))
+	suite.True(suite.tracker.keepWarm("/foo/bar", "Metadata", noopOp))
+	suite.Equal(2, Pins()[0].Results)
+}
+
+func (suite *PinsTestSuite) TestKeepWarm_UsesTheInnermostPin() {
+	_, _ = Pin("/foo", time.Minute)
+	suite.tracker.keepWarm("/foo/bar/baz", "List", noopOp)
+	suite.tracker.keepWarm("/foo/qux", "List", noopOp)
+
+	// The new pin takes over the jobs of its results
+	_, _ = Pin("/foo/bar", time.Second)
+	suite.tracker.keepWarm("/foo/bar", "List", noopOp)
+	pins := Pins()
+	if suite.Len(pins, 2) {
+		suite.Equal("/foo", pins[0].Path)
+		suite.Equal(1, pins[0].Results)
+		suite.Equal("/foo/bar", pins[1].Path)
+		suite.Equal(2, pins[1].Results)
+	}
+}
+
+func (suite *PinsTestSuite) TestTick_RefreshesPinnedResults() {
+	_, _ = Pin("/foo", time.Minute)
+	refreshed := make(chan struct{})
+	suite.cache.On("Refresh", "List", "/foo/bar", noExpiration, mock.Anything).Return(nil).Run(func(mock.Arguments) {
+		close(refreshed)
+	}).Once()
+	suite.tracker.keepWarm("/foo/bar", "List", noopOp)
+
+	// The result shouldn't be refreshed until the pin's TTL has elapsed
+	suite.tracker.tick()
+	suite.cache.AssertNotCalled(suite.T(), "Refresh", "List", "/foo/bar", noExpiration, mock.Anything)
+
+	suite.now = suite.now.Add(time.Minute)
+	suite.tracker.tick()
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		suite.Fail("expected /foo/bar to be refreshed")
+	}
+	suite.True(eventually(func() bool {
+		return !Pins()[0].LastRefresh.IsZero()
+	}))
+}
+
+func (suite *PinsTestSuite) TestUnpin() {
+	_, ok, err := Unpin("/foo")
+	suite.False(ok)
+	suite.NoError(err)
+
+	_, _ = Pin("/foo", time.Minute)
+	suite.cache.On("Delete", mock.Anything).Return([]string{}).Once()
+	pin, ok, err := Unpin("/foo")
+	suite.True(ok)
+	suite.NoError(err)
+	suite.Equal("/foo", pin.Path)
+	suite.False(suite.tracker.isPinned("/foo"))
+
+	// Unpinning clears the subtree's results so that they're cached with
+	// their usual TTLs again
+	suite.cache.AssertCalled(suite.T(), "Delete", mock.MatchedBy(func(rx *regexp.Regexp) bool {
+		return rx.MatchString("List::/foo") && rx.MatchString("List::/foo/bar") && !rx.MatchString("List::/foobar")
+	}))
+}
+
+func (suite *PinsTestSuite) TestCachedOpsOnPinnedEntriesNeverExpire() {
+	entry := newCacheTestsMockEntry("bar")
+	entry.SetTestID("/foo/bar")
+	entry.SetTTLOf(MetadataOp, 5*time.Second)
+	_, _ = Pin("/foo", time.Minute)
+
+	suite.cache.On("GetOrUpdate", "Metadata", "/foo/bar", noExpiration, false, mock.Anything).Return(JSONObject{}, nil).Once()
+	_, err := CachedMetadata(context.Background(), entry)
+	suite.NoError(err)
+	suite.cache.AssertExpectations(suite.T())
+	suite.Equal(1, Pins()[0].Results)
+}
+
+func (suite *PinsTestSuite) TestPopularityTrackerDoesntEvictPinnedEntries() {
+	_, _ = Pin("/foo", time.Minute)
+	popularity := newPopularityTracker()
+	popularity.now = func() time.Time {
+		return suite.now
+	}
+	popularity.record("/foo/bar")
+
+	suite.now = suite.now.Add(4 * popularityHalfLife)
+	popularity.tick()
+	suite.NotContains(popularity.entries, "/foo/bar")
+	suite.cache.AssertNotCalled(suite.T(), "Delete", mock.Anything)
+}
+
+func TestPins(t *testing.T) {
+	suite.Run(t, new(PinsTestSuite))
+}
@@ -0,0 +1,254 @@
+package plugin
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// popularityHalfLife is how long it takes for an entry's popularity score to
+// halve if the entry isn't accessed.
+const popularityHalfLife = 10 * time.Minute
+
+const (
+	// Entries whose popularity score is at least hotScore are hot. The TTLs of
+	// their cached ops are multiplied by hotTTLMultiplier, and their cached List,
+	// Open and Metadata results are refreshed in the background before they
+	// expire so that they stay warm.
+	hotScore         = 5.0
+	hotTTLMultiplier = 2
+	// Entries whose popularity score decays below coldScore are cold. Their
+	// cached ops are evicted, and they're no longer tracked.
+	coldScore = 0.1
+)
+
+// popularityTickInterval is how often the popularity tracker refreshes hot
+// entries and evicts cold ones.
+var popularityTickInterval = 5 * time.Second
+
+// PopularityStat describes how often an entry's been accessed.
+type PopularityStat struct {
+	Path string `json:"path"`
+	// Score is the entry's number of accesses, decayed by how long ago they
+	// happened.
+	Score      float64   `json:"score"`
+	Accesses   int       `json:"accesses"`
+	LastAccess time.Time `json:"last_access"`
+	Hot        bool      `json:"hot"`
+}
+
+type refreshJob struct {
+	ttl        time.Duration
+	op         opFunc
+	refreshAt  time.Time
+	refreshing bool
+}
+
+type entryPopularity struct {
+	score      float64
+	updatedAt  time.Time
+	accesses   int
+	lastAccess time.Time
+	// refreshJobs is keyed by the op's name
+	refreshJobs map[string]*refreshJob
+}
+
+// popularityTracker tracks how often each entry's cached ops are accessed. It
+// uses that to bias the cache's policy towards popular entries.
+type popularityTracker struct {
+	mux     sync.Mutex
+	entries map[string]*entryPopularity
+	now     func() time.Time
+}
+
+// popularity is set by InitCache. It is nil when running the tests so that
+// cachedOp's behavior is unaffected by the order in which entries are accessed.
+var popularity *popularityTracker
+
+func newPopularityTracker() *popularityTracker {
+	return &popularityTracker{
+		entries: make(map[string]*entryPopularity),
+		now:     time.Now,
+	}
+}
+
+// decay updates p's score to account for the time that's elapsed since it
+// was last updated.
+func (p *entryPopularity) decay(now time.Time) {
+	elapsed := now.Sub(p.updatedAt)
+	if elapsed > 0 {
+		p.score *= math.Pow(0.5, float64(elapsed)/float64(popularityHalfLife))
+	}
+	p.updatedAt = now
+}
+
+// record records an access of the entry with the given ID and returns the
+// entry's updated score.
+func (t *popularityTracker) record(id string) float64 {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	now := t.now()
+	p, ok := t.entries[id]
+	if !ok {
+		p = &entryPopularity{updatedAt: now}
+		t.entries[id] = p
+	}
+	p.decay(now)
+	p.score++
+	p.accesses++
+	p.lastAccess = now
+	return p.score
+}
+
+// ttlFor returns the TTL that should be used for an op on an entry with the
+// given score.
+func (t *popularityTracker) ttlFor(score float64, ttl time.Duration) time.Duration {
+	if score >= hotScore && ttl > 0 {
+		return ttl * hotTTLMultiplier
+	}
+	return ttl
+}
+
+// keepWarm schedules the entry's op to be refreshed in the background before
+// its cached result expires. The entry stops being refreshed once it's no
+// longer hot.
+func (t *popularityTracker) keepWarm(id string, opName string, ttl time.Duration, op opFunc) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	p, ok := t.entries[id]
+	if !ok || p.score < hotScore {
+		return
+	}
+	if p.refreshJobs == nil {
+		p.refreshJobs = make(map[string]*refreshJob)
+	}
+	if job, ok := p.refreshJobs[opName]; ok {
+		job.ttl = ttl
+		job.op = op
+		return
+	}
+	p.refreshJobs[opName] = &refreshJob{
+		ttl:       ttl,
+		op:        op,
+		refreshAt: t.now().Add(refreshDelay(ttl)),
+	}
+}
+
+// refreshDelay returns how long to wait before refreshing a result that's
+// cached for ttl. The result's refreshed when most of its TTL has elapsed so
+// that it's still cached while the refresh is in-flight.
+func refreshDelay(ttl time.Duration) time.Duration {
+	return ttl * 3 / 4
+}
+
+// tick refreshes the hot entries that are due for a refresh and evicts the
+// cold entries.
+func (t *popularityTracker) tick() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	now := t.now()
+	for id, p := range t.entries {
+		p.decay(now)
+		// Pinned entries are kept warm by their pin, and they're never evicted
+		pinned := pins.isPinned(id)
+		if p.score < coldScore {
+			delete(t.entries, id)
+			if !pinned {
+				t.evict(id)
+			}
+			continue
+		}
+		for opName, job := range p.refreshJobs {
+			if p.score < hotScore || pinned {
+				delete(p.refreshJobs, opName)
+				continue
+			}
+			if job.refreshing || now.Before(job.refreshAt) {
+				continue
+			}
+			job.refreshing = true
+			job.refreshAt = now.Add(refreshDelay(job.ttl))
+			go t.refresh(id, opName, job)
+		}
+	}
+}
+
+func (t *popularityTracker) refresh(id string, opName string, job *refreshJob) {
+	t.mux.Lock()
+	ttl, op := job.ttl, job.op
+	t.mux.Unlock()
+
+	if err := cache.Refresh(opName, id, ttl, op); err != nil {
+		log.Debugf("Failed to refresh %v::%v: %v", opName, id, err)
+	}
+
+	t.mux.Lock()
+	job.refreshing = false
+	t.mux.Unlock()
+}
+
+// evict evicts the entry's cached ops
+func (t *popularityTracker) evict(id string) {
+	rx, err := regexp.Compile(opQualifier + regexp.QuoteMeta(id) + "$")
+	if err != nil {
+		// This should never happen since id's quoted
+		panic(err)
+	}
+	cache.Delete(rx)
+}
+
+// run ticks the tracker until ctx is cancelled
+func (t *popularityTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(popularityTickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.tick()
+		}
+	}
+}
+
+func (t *popularityTracker) stats() []PopularityStat {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	now := t.now()
+	stats := make([]PopularityStat, 0, len(t.entries))
+	for id, p := range t.entries {
+		p.decay(now)
+		stats = append(stats, PopularityStat{
+			Path:       id,
+			Score:      p.score,
+			Accesses:   p.accesses,
+			LastAccess: p.lastAccess,
+			Hot:        p.score >= hotScore,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Score != stats[j].Score {
+			return stats[i].Score > stats[j].Score
+		}
+		return stats[i].Path < stats[j].Path
+	})
+	return stats
+}
+
+// PopularityStats returns the popularity of each tracked entry, sorted from
+// most to least popular. It returns nil if the cache hasn't been initialized.
+func PopularityStats() []PopularityStat {
+	if popularity == nil {
+		return nil
+	}
+	return popularity.stats()
+}
@@ -0,0 +1,138 @@
+package plugin
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type PopularityTestSuite struct {
+	suite.Suite
+	cache   *cacheTestsMockCache
+	tracker *popularityTracker
+	now     time.Time
+}
+
+func (suite *PopularityTestSuite) SetupTest() {
+	suite.cache = &cacheTestsMockCache{}
+	SetTestCache(suite.cache)
+	suite.now = time.Now()
+	suite.tracker = newPopularityTracker()
+	suite.tracker.now = func() time.Time {
+		return suite.now
+	}
+}
+
+func (suite *PopularityTestSuite) TearDownTest() {
+	UnsetTestCache()
+}
+
+func (suite *PopularityTestSuite) recordN(id string, n int) float64 {
+	var score float64
+	for i := 0; i < n; i++ {
+		score = suite.tracker.record(id)
+	}
+	return score
+}
+
+func (suite *PopularityTestSuite) TestRecord() {
+	suite.Equal(1.0, suite.tracker.record("/foo"))
+	suite.Equal(2.0, suite.tracker.record("/foo"))
+
+	// The score should halve every half-life
+	suite.now = suite.now.Add(popularityHalfLife)
+	suite.InDelta(2.0, suite.tracker.record("/foo"), 0.0001)
+
+	stats := suite.tracker.stats()
+	if suite.Len(stats, 1) {
+		suite.Equal("/foo", stats[0].Path)
+		suite.Equal(3, stats[0].Accesses)
+		suite.Equal(suite.now, stats[0].LastAccess)
+		suite.False(stats[0].Hot)
+	}
+}
+
+func (suite *PopularityTestSuite) TestStats_SortsByScore() {
+	suite.recordN("/foo", 1)
+	suite.recordN("/bar", int(hotScore))
+	suite.recordN("/baz", 2)
+
+	stats := suite.tracker.stats()
+	var paths []string
+	for _, stat := range stats {
+		paths = append(paths, stat.Path)
+	}
+	suite.Equal([]string{"/bar", "/baz", "/foo"}, paths)
+	suite.True(stats[0].Hot)
+}
+
+func (suite *PopularityTestSuite) TestTTLFor() {
+	suite.Equal(time.Second, suite.tracker.ttlFor(1, time.Second))
+	suite.Equal(hotTTLMultiplier*time.Second, suite.tracker.ttlFor(hotScore, time.Second))
+	// The cache's default TTL should be left alone
+	suite.Equal(time.Duration(0), suite.tracker.ttlFor(hotScore, 0))
+}
+
+func (suite *PopularityTestSuite) TestKeepWarm_IgnoresEntriesThatArentHot() {
+	suite.recordN("/foo", 1)
+	suite.tracker.keepWarm("/foo", "List", time.Second, func() (interface{}, error) { return nil, nil })
+	suite.Nil(suite.tracker.entries["/foo"].refreshJobs)
+
+	suite.tracker.keepWarm("/bar", "List", time.Second, func() (interface{}, error) { return nil, nil })
+	suite.NotContains(suite.tracker.entries, "/bar")
+}
+
+func (suite *PopularityTestSuite) TestTick_RefreshesHotEntries() {
+	// Record enough accesses for /foo to stay hot while its TTL elapses
+	suite.recordN("/foo", 2*int(hotScore))
+	refreshed := make(chan struct{})
+	suite.cache.On("Refresh", "List", "/foo", time.Minute, mock.Anything).Return(nil).Run(func(mock.Arguments) {
+		close(refreshed)
+	}).Once()
+	suite.tracker.keepWarm("/foo", "List", time.Minute, func() (interface{}, error) { return nil, nil })
+
+	// The entry shouldn't be refreshed until most of its TTL has elapsed
+	suite.tracker.tick()
+	suite.cache.AssertNotCalled(suite.T(), "Refresh", "List", "/foo", time.Minute, mock.Anything)
+
+	suite.now = suite.now.Add(refreshDelay(time.Minute))
+	suite.tracker.tick()
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		suite.Fail("expected /foo to be refreshed")
+	}
+}
+
+func (suite *PopularityTestSuite) TestTick_StopsRefreshingEntriesThatCoolDown() {
+	suite.recordN("/foo", int(hotScore))
+	suite.tracker.keepWarm("/foo", "List", time.Minute, func() (interface{}, error) { return nil, nil })
+
+	suite.now = suite.now.Add(popularityHalfLife)
+	suite.tracker.tick()
+	suite.Empty(suite.tracker.entries["/foo"].refreshJobs)
+	suite.cache.AssertNotCalled(suite.T(), "Refresh", "List", "/foo", time.Minute, mock.Anything)
+}
+
+func (suite *PopularityTestSuite) TestTick_EvictsColdEntries() {
+	suite.recordN("/foo", 1)
+	suite.recordN("/bar", int(hotScore))
+	suite.cache.On("Delete", mock.Anything).Return([]string{}).Once()
+
+	// /foo's score decays to 0.5^4 = 0.0625, which is below the cold score,
+	// while /bar's score decays to 5 * 0.0625 = 0.3125
+	suite.now = suite.now.Add(4 * popularityHalfLife)
+	suite.tracker.tick()
+	suite.NotContains(suite.tracker.entries, "/foo")
+	suite.Contains(suite.tracker.entries, "/bar")
+	suite.cache.AssertCalled(suite.T(), "Delete", mock.MatchedBy(func(rx *regexp.Regexp) bool {
+		return rx.MatchString("List::/foo") && rx.MatchString("Open::/foo") && !rx.MatchString("List::/foo/bar")
+	}))
+}
+
+func TestPopularity(t *testing.T) {
+	suite.Run(t, new(PopularityTestSuite))
+}
@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/limits"
+)
+
+// readBlockSize is the size of the blocks that a partial reader's content is
+// cached in
+var readBlockSize = limits.Register(
+	"plugins.read_block_kb",
+	"The content of files that support partial reads (e.g. S3 objects) is cached in blocks of this many KB, so seeking around a large file only fetches the blocks that are read. 0 disables block caching.",
+	1024,
+	nil,
+)
+
+// readCacheSize is the maximum size of all of the cached blocks
+var readCacheSize = limits.Register(
+	"plugins.read_cache_mb",
+	"The maximum size (in megabytes) of the cached blocks of files that support partial reads (see plugins.read_block_kb). Once it's exceeded, the blocks that are closest to expiring are evicted.",
+	256,
+	nil,
+)
+
+const readBlockCategory = "ReadBlock"
+
+// readBlocks caches the blocks of partial readers. It's recreated whenever
+// the block size or the cache size changes.
+var readBlocks struct {
+	mux       sync.Mutex
+	cache     *datastore.MemCache
+	blockSize int64
+	cacheSize int64
+}
+
+// currentReadBlocks returns the block cache and its block size. The returned
+// cache is nil if block caching is disabled.
+func currentReadBlocks() (*datastore.MemCache, int64) {
+	blockSize := int64(readBlockSize.Value()) * 1024
+	cacheSize := int64(readCacheSize.Value()) * 1024 * 1024
+	readBlocks.mux.Lock()
+	defer readBlocks.mux.Unlock()
+	if blockSize <= 0 || cacheSize <= 0 {
+		readBlocks.cache = nil
+		return nil, 0
+	}
+	if readBlocks.cache == nil || readBlocks.blockSize != blockSize || readBlocks.cacheSize != cacheSize {
+		maxBlocks := cacheSize / blockSize
+		if maxBlocks < 1 {
+			maxBlocks = 1
+		}
+		readBlocks.cache = datastore.NewMemCache().Limit(int(maxBlocks))
+		readBlocks.blockSize, readBlocks.cacheSize = blockSize, cacheSize
+	}
+	return readBlocks.cache, blockSize
+}
+
+// clearReadBlocks deletes the cached blocks whose keys match rx
+func clearReadBlocks(rx *regexp.Regexp) []string {
+	readBlocks.mux.Lock()
+	cache := readBlocks.cache
+	readBlocks.mux.Unlock()
+	if cache == nil {
+		return nil
+	}
+	return cache.Delete(rx)
+}
+
+// readerGeneration distinguishes the blocks of an entry's successive readers,
+// e.g. after its cached Open result expires, so that a new reader doesn't
+// serve the previous reader's (possibly stale) blocks.
+var readerGeneration uint64
+
+// blockCachedReader caches a partial reader's content in fixed-size blocks.
+// Reads are served from the cached blocks, and only the missing blocks are
+// fetched from the partial reader. This way seeking around a large file
+// (e.g. via `less`) doesn't re-fetch the content that was already read.
+type blockCachedReader struct {
+	PartialReader
+	key       string
+	ttl       time.Duration
+	cache     *datastore.MemCache
+	blockSize int64
+}
+
+// newBlockCachedReader returns a block-cached reader for e's content if the
+// content supports partial reads. Otherwise, it returns content. ttl is the
+// TTL of e's cached Open result.
+func newBlockCachedReader(e Entry, ttl time.Duration, content SizedReader) SizedReader {
+	pr, ok := content.(PartialReader)
+	if !ok || !pr.SupportsPartialReads() || ttl < 0 || e.id() == "" {
+		return content
+	}
+	cache, blockSize := currentReadBlocks()
+	if cache == nil {
+		return content
+	}
+	generation := atomic.AddUint64(&readerGeneration, 1)
+	return &blockCachedReader{
+		PartialReader: pr,
+		key:           fmt.Sprintf("%v/%v", e.id(), generation),
+		ttl:           ttl,
+		cache:         cache,
+		blockSize:     blockSize,
+	}
+}
+
+func (r *blockCachedReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("plugin.blockCachedReader.ReadAt: negative offset")
+	}
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.Size() {
+			return n, io.EOF
+		}
+		index := pos / r.blockSize
+		block, err := r.block(index)
+		if err != nil {
+			return n, err
+		}
+		start := pos - index*r.blockSize
+		if start >= int64(len(block)) {
+			// The block was cut short by the end of the content
+			return n, io.EOF
+		}
+		n += copy(p[n:], block[start:])
+	}
+	return n, nil
+}
+
+// block returns the block at the given index, fetching it if it isn't cached.
+// Errors aren't cached so that a failed read can be retried.
+func (r *blockCachedReader) block(index int64) ([]byte, error) {
+	key := fmt.Sprintf("%v/%v", r.key, index)
+	block, err := r.cache.GetOrUpdate(readBlockCategory, key, r.ttl, false, func() (interface{}, error) {
+		offset := index * r.blockSize
+		length := r.blockSize
+		if remaining := r.Size() - offset; remaining < length {
+			length = remaining
+		}
+		buf := make([]byte, length)
+		n, err := r.PartialReader.ReadAt(buf, offset)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, datastore.DoNotCache(err)
+		}
+		return buf[:n], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return block.([]byte), nil
+}
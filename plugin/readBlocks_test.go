@@ -0,0 +1,180 @@
+package plugin
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+// countingPartialReader is a partial reader that records the offsets of its
+// reads
+type countingPartialReader struct {
+	*strings.Reader
+	mux     sync.Mutex
+	offsets []int64
+	err     error
+}
+
+func (r *countingPartialReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mux.Lock()
+	r.offsets = append(r.offsets, off)
+	err := r.err
+	r.mux.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return r.Reader.ReadAt(p, off)
+}
+
+func (r *countingPartialReader) SupportsPartialReads() bool {
+	return true
+}
+
+type ReadBlocksTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ReadBlocksTestSuite) SetupTest() {
+	_, err := limits.Set(readBlockSize.Name(), 1)
+	suite.NoError(err)
+	// Start with an empty cache
+	readBlocks.mux.Lock()
+	readBlocks.cache = nil
+	readBlocks.mux.Unlock()
+}
+
+func (suite *ReadBlocksTestSuite) TearDownTest() {
+	_, err := limits.Set(readBlockSize.Name(), 1024)
+	suite.NoError(err)
+	_, err = limits.Set(readCacheSize.Name(), 256)
+	suite.NoError(err)
+}
+
+func (suite *ReadBlocksTestSuite) newReader(id string, content string) (*countingPartialReader, SizedReader) {
+	e := newCacheTestsMockEntry("foo")
+	e.SetTestID(id)
+	pr := &countingPartialReader{Reader: strings.NewReader(content)}
+	return pr, newBlockCachedReader(e, time.Minute, pr)
+}
+
+func (suite *ReadBlocksTestSuite) readAt(r SizedReader, off int64, n int) (string, error) {
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, off)
+	return string(buf[:read]), err
+}
+
+func (suite *ReadBlocksTestSuite) TestReadAt_CachesBlocks() {
+	content := strings.Repeat("a", 1024) + strings.Repeat("b", 1024) + "end"
+	pr, r := suite.newReader("/foo", content)
+	suite.IsType(&blockCachedReader{}, r)
+
+	// A read that spans two blocks fetches both of them
+	data, err := suite.readAt(r, 1020, 8)
+	suite.NoError(err)
+	suite.Equal("aaaabbbb", data)
+	suite.Equal([]int64{0, 1024}, pr.offsets)
+
+	// Reads within the cached blocks aren't fetched again
+	data, err = suite.readAt(r, 0, 4)
+	suite.NoError(err)
+	suite.Equal("aaaa", data)
+	suite.Equal([]int64{0, 1024}, pr.offsets)
+
+	// The last block is cut short by the end of the content
+	data, err = suite.readAt(r, 2046, 10)
+	suite.Equal(io.EOF, err)
+	suite.Equal("bbend", data)
+	suite.Equal([]int64{0, 1024, 2048}, pr.offsets)
+
+	_, err = suite.readAt(r, int64(len(content)), 1)
+	suite.Equal(io.EOF, err)
+	_, err = suite.readAt(r, -1, 1)
+	suite.Error(err)
+}
+
+func (suite *ReadBlocksTestSuite) TestReadAt_DoesNotShareBlocksBetweenReaders() {
+	pr1, r1 := suite.newReader("/foo", "old")
+	pr2, r2 := suite.newReader("/foo", "new")
+	data, _ := suite.readAt(r1, 0, 3)
+	suite.Equal("old", data)
+	data, _ = suite.readAt(r2, 0, 3)
+	suite.Equal("new", data)
+	suite.Len(pr1.offsets, 1)
+	suite.Len(pr2.offsets, 1)
+}
+
+func (suite *ReadBlocksTestSuite) TestReadAt_DoesNotCacheErrors() {
+	pr, r := suite.newReader("/foo", "content")
+	pr.err = errors.New("failed")
+	_, err := suite.readAt(r, 0, 1)
+	suite.EqualError(err, "failed")
+
+	pr.err = nil
+	data, err := suite.readAt(r, 0, 7)
+	suite.NoError(err)
+	suite.Equal("content", data)
+	suite.Len(pr.offsets, 2)
+}
+
+func (suite *ReadBlocksTestSuite) TestReadAt_EvictsBlocksOnceTheCacheIsFull() {
+	// The cache holds 2 blocks
+	_, err := limits.Set(readBlockSize.Name(), 512)
+	suite.NoError(err)
+	_, err = limits.Set(readCacheSize.Name(), 1)
+	suite.NoError(err)
+
+	const blockSize = 512 * 1024
+	pr, r := suite.newReader("/foo", strings.Repeat("a", 3*blockSize))
+	for i := int64(0); i < 3; i++ {
+		_, err = suite.readAt(r, i*blockSize, 1)
+		suite.NoError(err)
+	}
+	suite.Equal([]int64{0, blockSize, 2 * blockSize}, pr.offsets)
+
+	// The last block's still cached, but the first block (which was the
+	// closest to expiring) was evicted
+	_, _ = suite.readAt(r, 2*blockSize, 1)
+	suite.Len(pr.offsets, 3)
+	_, _ = suite.readAt(r, 0, 1)
+	suite.Equal([]int64{0, blockSize, 2 * blockSize, 0}, pr.offsets)
+}
+
+func (suite *ReadBlocksTestSuite) TestNewBlockCachedReader_ReturnsContentIfBlockCachingIsDisabled() {
+	e := newCacheTestsMockEntry("foo")
+	e.SetTestID("/foo")
+	pr := &countingPartialReader{Reader: strings.NewReader("")}
+
+	// Block caching is disabled for entries that disabled caching
+	suite.Equal(pr, newBlockCachedReader(e, -1, pr))
+
+	// Readers that don't support partial reads aren't block-cached
+	sr := strings.NewReader("")
+	suite.Equal(sr, newBlockCachedReader(e, time.Minute, sr))
+
+	_, err := limits.Set(readBlockSize.Name(), 0)
+	suite.NoError(err)
+	suite.Equal(pr, newBlockCachedReader(e, time.Minute, pr))
+}
+
+func (suite *ReadBlocksTestSuite) TestClearReadBlocks() {
+	pr, r := suite.newReader("/foo/bar", "content")
+	_, _ = suite.readAt(r, 0, 1)
+	rx, err := opKeysRegex("/foo")
+	if suite.NoError(err) {
+		suite.Len(clearReadBlocks(rx), 1)
+	}
+	suite.Empty(clearReadBlocks(regexp.MustCompile(".*")))
+	_, _ = suite.readAt(r, 0, 1)
+	suite.Len(pr.offsets, 2)
+}
+
+func TestReadBlocks(t *testing.T) {
+	suite.Run(t, new(ReadBlocksTestSuite))
+}
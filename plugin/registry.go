@@ -4,11 +4,17 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // Registry represents the plugin registry. It is also Wash's root.
 type Registry struct {
 	EntryBase
+	// mux guards the plugins since they can be replaced while the server's
+	// running (see ReplacePlugin)
+	mux         sync.RWMutex
 	plugins     map[string]Root
 	pluginRoots []Entry
 }
@@ -28,7 +34,21 @@ func NewRegistry() *Registry {
 // Plugins returns a map of the currently registered
 // plugins
 func (r *Registry) Plugins() map[string]Root {
-	return r.plugins
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	plugins := make(map[string]Root, len(r.plugins))
+	for name, root := range r.plugins {
+		plugins[name] = root
+	}
+	return plugins
+}
+
+// roots returns the registered plugin roots in the order that they were
+// registered
+func (r *Registry) roots() []Entry {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return append([]Entry{}, r.pluginRoots...)
 }
 
 var pluginNameRegex = regexp.MustCompile("^[0-9a-zA-Z_-]+$")
@@ -45,16 +65,94 @@ func (r *Registry) RegisterPlugin(root Root, config map[string]interface{}) erro
 		panic(msg)
 	}
 
+	r.mux.Lock()
 	if _, ok := r.plugins[root.name()]; ok {
+		r.mux.Unlock()
 		msg := fmt.Sprintf("r.RegisterPlugin: the %v plugin's already been registered", root.name())
 		panic(msg)
 	}
 
 	r.plugins[root.name()] = root
 	r.pluginRoots = append(r.pluginRoots, root)
+	r.mux.Unlock()
+	registerSlowCallThresholds(root.name())
+	startWatchesOf(root)
+	startHealthChecksOf(root)
 	return nil
 }
 
+// ReplacePlugin initializes the given plugin and registers it in place of the
+// registered plugin with the same name. If there isn't one, then the plugin's
+// registered like it would be by RegisterPlugin. The replaced plugin's cached
+// results are cleared and its daemons, watches and health checks (if any) are
+// stopped, which also lifts its quarantine. The
+// replaced plugin stays registered if the new one fails to initialize.
+func (r *Registry) ReplacePlugin(root Root, config map[string]interface{}) error {
+	name := root.name()
+	if !pluginNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid plugin name %v. The plugin name must consist of alphanumeric characters, or a hyphen", name)
+	}
+	if err := root.Init(config); err != nil {
+		// Init may have started the new plugin's daemon
+		stopDaemonsOf(root)
+		return err
+	}
+
+	r.mux.Lock()
+	old, replaced := r.plugins[name]
+	r.plugins[name] = root
+	if replaced {
+		for i, pluginRoot := range r.pluginRoots {
+			if pluginRoot == old {
+				r.pluginRoots[i] = root
+			}
+		}
+	} else {
+		r.pluginRoots = append(r.pluginRoots, root)
+	}
+	r.mux.Unlock()
+
+	registerSlowCallThresholds(name)
+	if replaced {
+		r.cleanupPlugin(old)
+	}
+	startWatchesOf(root)
+	startHealthChecksOf(root)
+	return nil
+}
+
+// UnregisterPlugin removes the named plugin from the registry, then clears its
+// cached results and stops its daemons, watches and health checks (if any). It returns false if the
+// plugin isn't registered.
+func (r *Registry) UnregisterPlugin(name string) bool {
+	r.mux.Lock()
+	old, ok := r.plugins[name]
+	if ok {
+		delete(r.plugins, name)
+		for i, pluginRoot := range r.pluginRoots {
+			if pluginRoot == old {
+				r.pluginRoots = append(r.pluginRoots[:i], r.pluginRoots[i+1:]...)
+				break
+			}
+		}
+	}
+	r.mux.Unlock()
+
+	if ok {
+		r.cleanupPlugin(old)
+	}
+	return ok
+}
+
+func (r *Registry) cleanupPlugin(root Root) {
+	stopWatchesOf(root)
+	stopHealthChecksOf(root)
+	stopDaemonsOf(root)
+	if _, err := ClearCacheFor("/" + root.name()); err != nil {
+		log.Warnf("Could not clear the %v plugin's cache: %v", root.name(), err)
+	}
+}
+
 // ChildSchemas only makes sense for core plugin roots
 func (r *Registry) ChildSchemas() []*EntrySchema {
 	return nil
@@ -67,5 +165,5 @@ func (r *Registry) Schema() *EntrySchema {
 
 // List all of Wash's loaded plugins
 func (r *Registry) List(ctx context.Context) ([]Entry, error) {
-	return r.pluginRoots, nil
+	return r.roots(), nil
 }
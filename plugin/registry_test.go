@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/puppetlabs/wash/datastore"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
@@ -115,6 +117,82 @@ func (suite *RegistryTestSuite) TestRegisterPluginRegisteredPlugin() {
 	suite.Panics(panicFunc, "r.RegisterPlugin: the mine plugin's already been registered")
 }
 
+func (suite *RegistryTestSuite) TestReplacePlugin() {
+	SetTestCache(datastore.NewMemCache())
+	defer UnsetTestCache()
+
+	reg := NewRegistry()
+	other := &mockRoot{EntryBase: NewEntry("other")}
+	other.On("Init", map[string]interface{}(nil)).Return(nil)
+	old := &mockRoot{EntryBase: NewEntry("mine")}
+	old.On("Init", map[string]interface{}(nil)).Return(nil)
+	suite.NoError(reg.RegisterPlugin(old, nil))
+	suite.NoError(reg.RegisterPlugin(other, nil))
+	for _, key := range []string{"/mine", "/other"} {
+		_, err := cache.GetOrUpdate("List", key, time.Minute, false, func() (interface{}, error) {
+			return "cached", nil
+		})
+		suite.NoError(err)
+	}
+
+	cfg := map[string]interface{}{"key": "value"}
+	m := &mockRoot{EntryBase: NewEntry("mine")}
+	m.On("Init", cfg).Return(nil)
+	suite.NoError(reg.ReplacePlugin(m, cfg))
+	m.AssertExpectations(suite.T())
+	suite.True(reg.Plugins()["mine"] == m)
+	// The replacement's listed where the replaced plugin was
+	roots, err := reg.List(context.Background())
+	suite.NoError(err)
+	suite.Equal([]Entry{m, other}, roots)
+	// Only the replaced plugin's cache is cleared
+	cached, _ := cache.Get("List", "/mine")
+	suite.Nil(cached)
+	cached, _ = cache.Get("List", "/other")
+	suite.Equal("cached", cached)
+}
+
+func (suite *RegistryTestSuite) TestReplacePluginInitError() {
+	reg := NewRegistry()
+	old := &mockRoot{EntryBase: NewEntry("mine")}
+	old.On("Init", map[string]interface{}(nil)).Return(nil)
+	suite.NoError(reg.RegisterPlugin(old, nil))
+
+	m := &mockRoot{EntryBase: NewEntry("mine")}
+	m.On("Init", map[string]interface{}(nil)).Return(errors.New("failed"))
+	suite.EqualError(reg.ReplacePlugin(m, nil), "failed")
+	suite.True(reg.Plugins()["mine"] == old)
+}
+
+func (suite *RegistryTestSuite) TestReplaceUnregisteredPlugin() {
+	SetTestCache(datastore.NewMemCache())
+	defer UnsetTestCache()
+
+	reg := NewRegistry()
+	m := &mockRoot{EntryBase: NewEntry("mine")}
+	m.On("Init", map[string]interface{}(nil)).Return(nil)
+	suite.NoError(reg.ReplacePlugin(m, nil))
+	suite.Contains(reg.Plugins(), "mine")
+	suite.EqualError(reg.ReplacePlugin(&mockRoot{EntryBase: NewEntry("b@dname")}, nil), "invalid plugin name b@dname. The plugin name must consist of alphanumeric characters, or a hyphen")
+}
+
+func (suite *RegistryTestSuite) TestUnregisterPlugin() {
+	SetTestCache(datastore.NewMemCache())
+	defer UnsetTestCache()
+
+	reg := NewRegistry()
+	m := &mockRoot{EntryBase: NewEntry("mine")}
+	m.On("Init", map[string]interface{}(nil)).Return(nil)
+	suite.NoError(reg.RegisterPlugin(m, nil))
+
+	suite.True(reg.UnregisterPlugin("mine"))
+	suite.NotContains(reg.Plugins(), "mine")
+	roots, err := reg.List(context.Background())
+	suite.NoError(err)
+	suite.Empty(roots)
+	suite.False(reg.UnregisterPlugin("mine"))
+}
+
 func TestRegistry(t *testing.T) {
 	suite.Run(t, new(RegistryTestSuite))
 }
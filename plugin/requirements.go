@@ -0,0 +1,194 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Requirements are a plugin's prerequisites. Plugins whose requirements
+// aren't met are skipped instead of failing to load.
+type Requirements struct {
+	// Plugins are the plugins that must be loaded before the plugin, e.g.
+	// because it delegates to them.
+	Plugins []string `json:"plugins,omitempty"`
+	// Files must exist, e.g. /var/run/docker.sock. A leading ~ is expanded
+	// to the user's home directory, and environment variables are expanded.
+	Files []string `json:"files,omitempty"`
+	// Commands must be in the PATH.
+	Commands []string `json:"commands,omitempty"`
+	// Env are environment variables that must be set.
+	Env []string `json:"env,omitempty"`
+}
+
+// Requirer is implemented by plugin roots that have requirements. Requirements
+// is called before the root's Init, so it shouldn't rely on Init.
+type Requirer interface {
+	Root
+	Requirements() Requirements
+}
+
+func requirementsOf(root Root) Requirements {
+	if r, ok := root.(Requirer); ok {
+		return r.Requirements()
+	}
+	return Requirements{}
+}
+
+// unmetHostRequirement returns why one of the host requirements (i.e. the
+// files, commands, or environment variables) isn't met, or "" if they're all
+// met
+func (r Requirements) unmetHostRequirement() string {
+	for _, file := range r.Files {
+		path := expandPath(file)
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Sprintf("%v doesn't exist", path)
+		}
+	}
+	for _, command := range r.Commands {
+		if _, err := exec.LookPath(command); err != nil {
+			return fmt.Sprintf("the %v command isn't in the PATH", command)
+		}
+	}
+	for _, env := range r.Env {
+		if os.Getenv(env) == "" {
+			return fmt.Sprintf("the %v environment variable isn't set", env)
+		}
+	}
+	return ""
+}
+
+func expandPath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
+
+// The plugin statuses
+const (
+	PluginLoaded  = "loaded"
+	PluginSkipped = "skipped"
+	PluginFailed  = "failed"
+	// PluginQuarantined is the status of a loaded external plugin that's
+	// quarantined because it repeatedly failed its health checks
+	PluginQuarantined = "quarantined"
+)
+
+// PluginStatus describes whether a plugin was loaded (see
+// Registry#RegisterPlugins), and why not if it wasn't
+type PluginStatus struct {
+	Name         string       `json:"name"`
+	Status       string       `json:"status"`
+	Reason       string       `json:"reason,omitempty"`
+	Requirements Requirements `json:"requirements"`
+}
+
+var pluginStatusesMux sync.Mutex
+var pluginStatuses []PluginStatus
+
+// PluginStatuses returns the statuses of the plugins that were registered via
+// Registry#RegisterPlugins, sorted by name
+func PluginStatuses() []PluginStatus {
+	pluginStatusesMux.Lock()
+	defer pluginStatusesMux.Unlock()
+	return append([]PluginStatus{}, pluginStatuses...)
+}
+
+// setPluginStatus adds status to the plugin statuses, replacing the plugin's
+// previous status (if any)
+func setPluginStatus(status PluginStatus) {
+	pluginStatusesMux.Lock()
+	defer pluginStatusesMux.Unlock()
+	for i, s := range pluginStatuses {
+		if s.Name == status.Name {
+			pluginStatuses[i] = status
+			return
+		}
+	}
+	pluginStatuses = append(pluginStatuses, status)
+	sort.Slice(pluginStatuses, func(i, j int) bool { return pluginStatuses[i].Name < pluginStatuses[j].Name })
+}
+
+// RegisterPlugins registers the given plugin roots, keyed by their names, in
+// dependency order. config contains each plugin's config. Plugins whose
+// requirements aren't met (including plugins that depend on a plugin that
+// wasn't loaded) are skipped. The returned statuses (also available via
+// PluginStatuses) describe which plugins were loaded.
+func (r *Registry) RegisterPlugins(roots map[string]Root, config map[string]map[string]interface{}) []PluginStatus {
+	names := make([]string, 0, len(roots))
+	for name := range roots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statuses := make(map[string]*PluginStatus)
+	var load func(name string, chain []string)
+	load = func(name string, chain []string) {
+		if _, ok := statuses[name]; ok {
+			return
+		}
+		root := roots[name]
+		status := &PluginStatus{Name: name, Requirements: requirementsOf(root)}
+		statuses[name] = status
+		skip := func(reason string) {
+			log.Warnf("Skipping %v: %v", name, reason)
+			status.Status, status.Reason = PluginSkipped, reason
+		}
+
+		chain = append(chain, name)
+		for _, dep := range status.Requirements.Plugins {
+			if _, ok := roots[dep]; !ok {
+				skip(fmt.Sprintf("it requires the %v plugin, which isn't configured", dep))
+				return
+			}
+			if depStatus, ok := statuses[dep]; ok && depStatus.Status == "" {
+				skip(fmt.Sprintf("it has a circular dependency: %v", strings.Join(append(chain, dep), " -> ")))
+				return
+			}
+			load(dep, chain)
+			switch statuses[dep].Status {
+			case PluginSkipped:
+				skip(fmt.Sprintf("it requires the %v plugin, which was skipped", dep))
+				return
+			case PluginFailed:
+				skip(fmt.Sprintf("it requires the %v plugin, which failed to load", dep))
+				return
+			}
+		}
+		if reason := status.Requirements.unmetHostRequirement(); reason != "" {
+			skip(reason)
+			return
+		}
+
+		log.Infof("Loading %v", name)
+		if err := r.RegisterPlugin(root, config[name]); err != nil {
+			// %+v is a convention used by some errors to print additional context such as a stack trace
+			log.Warnf("%v failed to load: %+v", name, err)
+			status.Status, status.Reason = PluginFailed, fmt.Sprintf("%+v", err)
+			return
+		}
+		status.Status = PluginLoaded
+	}
+	for _, name := range names {
+		load(name, nil)
+	}
+
+	result := make([]PluginStatus, 0, len(names))
+	for _, name := range names {
+		result = append(result, *statuses[name])
+	}
+	pluginStatusesMux.Lock()
+	defer pluginStatusesMux.Unlock()
+	pluginStatuses = result
+	return append([]PluginStatus{}, result...)
+}
@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type RequirementsTestSuite struct {
+	suite.Suite
+	initOrder []string
+}
+
+func (suite *RequirementsTestSuite) SetupTest() {
+	suite.initOrder = nil
+}
+
+type mockRequirerRoot struct {
+	mockRoot
+	requires Requirements
+}
+
+func (m *mockRequirerRoot) Requirements() Requirements {
+	return m.requires
+}
+
+func (suite *RequirementsTestSuite) newRoot(name string, requires Requirements, initErr error) *mockRequirerRoot {
+	m := &mockRequirerRoot{mockRoot: mockRoot{EntryBase: NewEntry(name)}, requires: requires}
+	m.On("Init", mock.Anything).Return(initErr).Run(func(mock.Arguments) {
+		suite.initOrder = append(suite.initOrder, name)
+	})
+	return m
+}
+
+func (suite *RequirementsTestSuite) TestUnmetHostRequirement() {
+	dir, err := ioutil.TempDir("", "wash-requirements-test")
+	if !suite.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	suite.Equal("", Requirements{Files: []string{dir}}.unmetHostRequirement())
+	missing := filepath.Join(dir, "missing")
+	suite.Equal(missing+" doesn't exist", Requirements{Files: []string{dir, missing}}.unmetHostRequirement())
+
+	suite.Equal("", Requirements{Commands: []string{"go"}}.unmetHostRequirement())
+	suite.Equal("the wash-missing-command command isn't in the PATH", Requirements{Commands: []string{"wash-missing-command"}}.unmetHostRequirement())
+
+	suite.NoError(os.Setenv("WASH_REQUIREMENTS_TEST", dir))
+	defer os.Unsetenv("WASH_REQUIREMENTS_TEST")
+	suite.Equal("", Requirements{Env: []string{"WASH_REQUIREMENTS_TEST"}}.unmetHostRequirement())
+	suite.Equal("", Requirements{Files: []string{"$WASH_REQUIREMENTS_TEST"}}.unmetHostRequirement())
+	suite.Equal("the WASH_MISSING_ENV environment variable isn't set", Requirements{Env: []string{"WASH_MISSING_ENV"}}.unmetHostRequirement())
+}
+
+func (suite *RequirementsTestSuite) TestExpandPath() {
+	homeDir, err := os.UserHomeDir()
+	if suite.NoError(err) {
+		suite.Equal(filepath.Join(homeDir, ".kube", "config"), expandPath("~/.kube/config"))
+		suite.Equal(homeDir, expandPath("~"))
+	}
+	suite.Equal("~foo", expandPath("~foo"))
+}
+
+func (suite *RequirementsTestSuite) TestRegisterPlugins_InitializesInDependencyOrder() {
+	reg := NewRegistry()
+	roots := map[string]Root{
+		"a": suite.newRoot("a", Requirements{Plugins: []string{"c"}}, nil),
+		"b": suite.newRoot("b", Requirements{}, nil),
+		"c": suite.newRoot("c", Requirements{Plugins: []string{"b"}}, nil),
+	}
+	statuses := reg.RegisterPlugins(roots, nil)
+
+	suite.Equal([]string{"b", "c", "a"}, suite.initOrder)
+	suite.Len(reg.Plugins(), 3)
+	suite.Equal([]PluginStatus{
+		{Name: "a", Status: PluginLoaded, Requirements: Requirements{Plugins: []string{"c"}}},
+		{Name: "b", Status: PluginLoaded},
+		{Name: "c", Status: PluginLoaded, Requirements: Requirements{Plugins: []string{"b"}}},
+	}, statuses)
+	suite.Equal(statuses, PluginStatuses())
+}
+
+func (suite *RequirementsTestSuite) TestRegisterPlugins_PassesConfig() {
+	reg := NewRegistry()
+	m := &mockRoot{EntryBase: NewEntry("mine")}
+	cfg := map[string]interface{}{"key": "value"}
+	m.On("Init", cfg).Return(nil)
+
+	reg.RegisterPlugins(map[string]Root{"mine": m}, map[string]map[string]interface{}{"mine": cfg})
+	m.AssertExpectations(suite.T())
+}
+
+func (suite *RequirementsTestSuite) TestRegisterPlugins_SkipsPluginsWithUnmetRequirements() {
+	reg := NewRegistry()
+	roots := map[string]Root{
+		"missing-dep":  suite.newRoot("missing-dep", Requirements{Plugins: []string{"foo"}}, nil),
+		"missing-env":  suite.newRoot("missing-env", Requirements{Env: []string{"WASH_MISSING_ENV"}}, nil),
+		"failed":       suite.newRoot("failed", Requirements{}, errors.New("init failed")),
+		"needs-failed": suite.newRoot("needs-failed", Requirements{Plugins: []string{"failed"}}, nil),
+		"needs-env":    suite.newRoot("needs-env", Requirements{Plugins: []string{"missing-env"}}, nil),
+		"ok":           suite.newRoot("ok", Requirements{}, nil),
+	}
+	statuses := reg.RegisterPlugins(roots, nil)
+
+	suite.Equal([]string{"failed", "ok"}, suite.initOrder)
+	suite.Len(reg.Plugins(), 1)
+	suite.Contains(reg.Plugins(), "ok")
+
+	reasons := make(map[string]string)
+	for _, status := range statuses {
+		reasons[status.Name] = status.Status + ": " + status.Reason
+	}
+	suite.Equal(map[string]string{
+		"failed":       "failed: init failed",
+		"missing-dep":  "skipped: it requires the foo plugin, which isn't configured",
+		"missing-env":  "skipped: the WASH_MISSING_ENV environment variable isn't set",
+		"needs-env":    "skipped: it requires the missing-env plugin, which was skipped",
+		"needs-failed": "skipped: it requires the failed plugin, which failed to load",
+		"ok":           "loaded: ",
+	}, reasons)
+}
+
+func (suite *RequirementsTestSuite) TestRegisterPlugins_SkipsCircularDependencies() {
+	reg := NewRegistry()
+	roots := map[string]Root{
+		"a": suite.newRoot("a", Requirements{Plugins: []string{"b"}}, nil),
+		"b": suite.newRoot("b", Requirements{Plugins: []string{"a"}}, nil),
+	}
+	statuses := reg.RegisterPlugins(roots, nil)
+
+	suite.Empty(suite.initOrder)
+	suite.Equal("it requires the b plugin, which was skipped", statuses[0].Reason)
+	suite.Equal("it has a circular dependency: a -> b -> a", statuses[1].Reason)
+}
+
+func TestRequirements(t *testing.T) {
+	suite.Run(t, new(RequirementsTestSuite))
+}
@@ -0,0 +1,156 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+)
+
+// maxRewarmCalls limits the number of plugin calls that Rewarm makes
+// concurrently so that re-warming the cache doesn't starve other requests
+var maxRewarmCalls = limits.NewSemaphore(
+	"plugins.max_rewarm_calls",
+	"The maximum number of plugin calls that are made concurrently when the cache is re-warmed from a previous server's handoff.",
+	10,
+)
+
+// RewarmStats summarizes a call to Rewarm. Lists is the number of listings
+// that were fetched via their plugin. Content and Metadata are the number of
+// contents and metadata that were imported from the snapshot. Expired is the
+// number of results that weren't imported because their TTL lapsed since the
+// snapshot was captured.
+type RewarmStats struct {
+	Lists    int64
+	Content  int64
+	Metadata int64
+	Expired  int64
+	Failures int64
+}
+
+// Calls returns the number of plugin calls that the re-warm made
+func (s RewarmStats) Calls() int64 {
+	return s.Lists
+}
+
+// Rewarm re-populates the cache with the parts of r's plugin tree that were
+// cached when s was captured, e.g. by the server that r's server replaced.
+// The captured content and metadata are imported into the cache as-is, for
+// what's left of their TTL, so re-warming them doesn't invoke their plugin.
+// Listings are fetched again via their plugin since the cache needs the
+// plugin's entries, which can't be recreated from the snapshot. Entries that
+// no longer exist are skipped, and failures are logged to ctx's activity
+// journal rather than stopping the re-warm. Rewarm returns once everything's
+// been re-warmed or ctx is cancelled.
+func Rewarm(ctx context.Context, r *Registry, s *Snapshot) RewarmStats {
+	w := &rewarmer{snapshot: s}
+	if top, ok := s.Entries[r.id()]; ok {
+		// Listing the registry sets the plugin roots' IDs, which their
+		// cached results are keyed by
+		roots, err := CachedList(ctx, r)
+		if err != nil {
+			w.failed(ctx, "list", r, err)
+		}
+		for _, cname := range top.Children {
+			if root, ok := roots[cname]; ok {
+				w.rewarm(ctx, root)
+			}
+		}
+	}
+	w.wg.Wait()
+	return RewarmStats{
+		Lists:    atomic.LoadInt64(&w.stats.Lists),
+		Content:  atomic.LoadInt64(&w.stats.Content),
+		Metadata: atomic.LoadInt64(&w.stats.Metadata),
+		Expired:  atomic.LoadInt64(&w.stats.Expired),
+		Failures: atomic.LoadInt64(&w.stats.Failures),
+	}
+}
+
+type rewarmer struct {
+	snapshot *Snapshot
+	wg       sync.WaitGroup
+	stats    RewarmStats
+}
+
+// rewarm re-warms e in the background, then does the same for its captured
+// children
+func (w *rewarmer) rewarm(ctx context.Context, e Entry) {
+	captured := w.snapshot.Entries[e.id()]
+	if captured == nil {
+		return
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		if err := maxRewarmCalls.Acquire(ctx); err != nil {
+			return
+		}
+		children := w.fetch(ctx, e, captured)
+		maxRewarmCalls.Release()
+
+		// The children are re-warmed after releasing the semaphore so that
+		// they can't deadlock on their parent
+		for _, cname := range captured.Children {
+			if child, ok := children[cname]; ok {
+				w.rewarm(ctx, child)
+			}
+		}
+	}()
+}
+
+// fetch fetches e's listing if it was cached, and imports its captured content
+// and metadata. It returns e's children if its listing was cached.
+func (w *rewarmer) fetch(ctx context.Context, e Entry, captured *SnapshotEntry) map[string]Entry {
+	var children map[string]Entry
+	if p, ok := e.(Parent); ok && captured.Listed && ListAction().IsSupportedOn(e) {
+		var err error
+		if children, err = CachedList(ctx, p); err != nil {
+			w.failed(ctx, "list", e, err)
+		}
+		atomic.AddInt64(&w.stats.Lists, 1)
+	}
+	if captured.HasContent && ReadAction().IsSupportedOn(e) {
+		w.importResult(e, OpenOp, bytes.NewReader(captured.Content), &w.stats.Content)
+	}
+	if captured.Metadata != nil {
+		w.importResult(e, MetadataOp, captured.Metadata, &w.stats.Metadata)
+	}
+	return children
+}
+
+// importResult caches value as e's result for the given op, unless it was
+// already cached (e.g. by a request that came in during the re-warm). The
+// value expires when it would have expired in the snapshot's cache, at the
+// latest.
+func (w *rewarmer) importResult(e Entry, opCode defaultOpCode, value interface{}, count *int64) {
+	ttl := e.getTTLOf(opCode)
+	if ttl < 0 {
+		// The entry's results aren't cached
+		return
+	}
+	if ttl == 0 {
+		// The cache's default TTL
+		ttl = time.Minute
+	}
+	ttl -= time.Since(w.snapshot.Time)
+	if ttl <= 0 {
+		atomic.AddInt64(&w.stats.Expired, 1)
+		return
+	}
+	_, err := cache.GetOrUpdate(defaultOpCodeToNameMap[opCode], e.id(), ttl, false, func() (interface{}, error) {
+		return value, nil
+	})
+	if err == nil {
+		atomic.AddInt64(count, 1)
+	}
+}
+
+func (w *rewarmer) failed(ctx context.Context, method string, e Entry, err error) {
+	atomic.AddInt64(&w.stats.Failures, 1)
+	activity.Warnf(ctx, "Rewarm: %v on %v failed: %v", method, e.id(), err)
+}
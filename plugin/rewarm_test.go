@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/stretchr/testify/suite"
+)
+
+type RewarmTestSuite struct {
+	suite.Suite
+}
+
+func (suite *RewarmTestSuite) SetupTest() {
+	SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *RewarmTestSuite) TearDownTest() {
+	UnsetTestCache()
+}
+
+func (suite *RewarmTestSuite) TestRewarmFetchesWhatWasCached() {
+	ctx := context.Background()
+	cached := &snapshotTestsFile{EntryBase: NewEntry("cached"), content: "hello"}
+	uncached := &snapshotTestsFile{EntryBase: NewEntry("uncached"), content: "world"}
+	unlisted := &mockParent{EntryBase: NewEntry("unlisted"), entries: []Entry{newMockEntry("child")}}
+	root := &snapshotTestsRoot{mockParent{EntryBase: NewEntry("mock"), entries: []Entry{cached, uncached, unlisted}}}
+
+	registry := NewRegistry()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+	_, err := CachedList(ctx, registry)
+	suite.NoError(err)
+	_, err = CachedList(ctx, root)
+	suite.NoError(err)
+	_, err = CachedOpen(ctx, cached)
+	suite.NoError(err)
+	_, err = CachedMetadata(ctx, cached)
+	suite.NoError(err)
+	s := CaptureSnapshot(registry, "id")
+
+	// Simulate the next server's empty cache
+	UnsetTestCache()
+	SetTestCache(datastore.NewMemCache())
+	cached.content = "changed"
+	// EntryBase#Metadata disables the metadata's caching, which would keep
+	// it from being imported
+	cached.SetTTLOf(MetadataOp, 15*time.Second)
+	stats := Rewarm(ctx, registry, s)
+	suite.Equal(RewarmStats{Lists: 1, Content: 1, Metadata: 1}, stats)
+
+	suite.NotNil(cachedValue(ListOp, root))
+	suite.Nil(cachedValue(OpenOp, uncached))
+	suite.Nil(cachedValue(ListOp, unlisted))
+	suite.NotNil(cachedValue(MetadataOp, cached))
+	// The content's imported from the snapshot instead of being read again
+	content, ok := cachedValue(OpenOp, cached).(SizedReader)
+	if suite.True(ok) {
+		data, err := ioutil.ReadAll(io.NewSectionReader(content, 0, content.Size()))
+		suite.NoError(err)
+		suite.Equal("hello", string(data))
+	}
+}
+
+func (suite *RewarmTestSuite) TestRewarmSkipsExpiredResults() {
+	ctx := context.Background()
+	file := &snapshotTestsFile{EntryBase: NewEntry("file"), content: "hello"}
+	root := &snapshotTestsRoot{mockParent{EntryBase: NewEntry("mock"), entries: []Entry{file}}}
+
+	registry := NewRegistry()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+	_, err := CachedList(ctx, registry)
+	suite.NoError(err)
+	_, err = CachedList(ctx, root)
+	suite.NoError(err)
+	_, err = CachedOpen(ctx, file)
+	suite.NoError(err)
+	s := CaptureSnapshot(registry, "id")
+	s.Time = s.Time.Add(-time.Hour)
+
+	UnsetTestCache()
+	SetTestCache(datastore.NewMemCache())
+	stats := Rewarm(ctx, registry, s)
+	suite.Equal(RewarmStats{Lists: 1, Expired: 1}, stats)
+	suite.Nil(cachedValue(OpenOp, file))
+}
+
+func (suite *RewarmTestSuite) TestRewarmSkipsEntriesThatNoLongerExist() {
+	ctx := context.Background()
+	gone := &snapshotTestsFile{EntryBase: NewEntry("gone"), content: "hello"}
+	root := &snapshotTestsRoot{mockParent{EntryBase: NewEntry("mock"), entries: []Entry{gone}}}
+
+	registry := NewRegistry()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+	_, err := CachedList(ctx, registry)
+	suite.NoError(err)
+	_, err = CachedList(ctx, root)
+	suite.NoError(err)
+	_, err = CachedOpen(ctx, gone)
+	suite.NoError(err)
+	s := CaptureSnapshot(registry, "id")
+
+	UnsetTestCache()
+	SetTestCache(datastore.NewMemCache())
+	root.entries = nil
+	stats := Rewarm(ctx, registry, s)
+	suite.Equal(RewarmStats{Lists: 1}, stats)
+	suite.Nil(cachedValue(OpenOp, gone))
+}
+
+func TestRewarm(t *testing.T) {
+	suite.Run(t, new(RewarmTestSuite))
+}
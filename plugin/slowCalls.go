@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/puppetlabs/wash/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// slowCallThreshold is the default latency threshold of every plugin's
+// actions. It can be overridden for a specific plugin's action via that
+// action's threshold (see slowCallThresholdOf).
+var slowCallThreshold = limits.Register(
+	"plugins.slow_call_ms",
+	"Calls to a plugin's list, read, metadata, stream, or exec that take longer than this many milliseconds are logged as slow and counted in wash/slow_calls. 0 disables slow-call logging.",
+	10000,
+	nil,
+)
+
+// slowCallActions are the actions whose latency is tracked
+var slowCallActions = []string{"list", "read", "metadata", "stream", "exec"}
+
+// registerSlowCallThresholds registers the given plugin's per-action latency
+// thresholds so that they can be tuned like any other limit
+func registerSlowCallThresholds(pluginName string) {
+	for _, action := range slowCallActions {
+		limits.Register(
+			slowCallThresholdName(pluginName, action),
+			"Overrides plugins.slow_call_ms for the "+pluginName+" plugin's "+action+" calls. 0 means use plugins.slow_call_ms.",
+			0,
+			nil,
+		)
+	}
+}
+
+func slowCallThresholdName(pluginName string, action string) string {
+	return "plugins." + pluginName + ".slow_" + action + "_ms"
+}
+
+// slowCallThresholdOf returns the latency threshold of the given plugin's
+// action
+func slowCallThresholdOf(pluginName string, action string) time.Duration {
+	threshold := 0
+	if l, ok := limits.Get(slowCallThresholdName(pluginName, action)); ok {
+		threshold = l.Value()
+	}
+	if threshold <= 0 {
+		threshold = slowCallThreshold.Value()
+	}
+	return time.Duration(threshold) * time.Millisecond
+}
+
+// SlowCallStat counts the calls to a plugin's action that exceeded the
+// action's latency threshold
+type SlowCallStat struct {
+	Plugin string `json:"plugin"`
+	Action string `json:"action"`
+	Count  int    `json:"count"`
+	// MaxMS is the latency of the slowest call in milliseconds
+	MaxMS int64 `json:"max_ms"`
+	// LastPath and LastTime describe the most recent slow call
+	LastPath string    `json:"last_path"`
+	LastTime time.Time `json:"last_time"`
+}
+
+var slowCallStatsMux sync.Mutex
+var slowCallStats = make(map[string]*SlowCallStat)
+
+// SlowCallStats returns the stats of each plugin action that had slow calls,
+// sorted from most to least slow calls
+func SlowCallStats() []SlowCallStat {
+	slowCallStatsMux.Lock()
+	defer slowCallStatsMux.Unlock()
+	stats := make([]SlowCallStat, 0, len(slowCallStats))
+	for _, stat := range slowCallStats {
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Plugin+"/"+stats[i].Action < stats[j].Plugin+"/"+stats[j].Action
+	})
+	return stats
+}
+
+// trackLatency logs the call to the entry's action that started at start if
+// it exceeded the action's latency threshold. Usage is
+// `defer trackLatency(ctx, e, "list", time.Now())`.
+func trackLatency(ctx context.Context, e Entry, action string, start time.Time) {
+	elapsed := time.Since(start)
+	plugin := pluginName(e)
+	metrics.RecordCall(plugin, action, elapsed)
+	threshold := slowCallThresholdOf(plugin, action)
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+
+	path := e.id()
+	activity.Record(ctx, "Slow call: %v on %v took %v, which exceeds the %v threshold", action, path, elapsed, threshold)
+	log.WithFields(log.Fields{
+		"plugin":    plugin,
+		"action":    action,
+		"path":      path,
+		"type_id":   TypeID(e),
+		"elapsed":   elapsed,
+		"threshold": threshold,
+	}).Warnf("Slow %v call on %v", action, path)
+
+	slowCallStatsMux.Lock()
+	defer slowCallStatsMux.Unlock()
+	key := plugin + "/" + action
+	stat, ok := slowCallStats[key]
+	if !ok {
+		stat = &SlowCallStat{Plugin: plugin, Action: action}
+		slowCallStats[key] = stat
+	}
+	stat.Count++
+	if ms := elapsed.Milliseconds(); ms > stat.MaxMS {
+		stat.MaxMS = ms
+	}
+	stat.LastPath = path
+	stat.LastTime = start.Add(elapsed)
+}
+
+// trackedOp wraps op so that its latency is tracked as the entry's action
+// corresponding to opCode
+func trackedOp(opCode defaultOpCode, entry Entry, op func(context.Context) (interface{}, error)) func(context.Context) (interface{}, error) {
+	action := actionOf(opCode)
+	return func(ctx context.Context) (interface{}, error) {
+		defer trackLatency(ctx, entry, action, time.Now())
+		return op(ctx)
+	}
+}
+
+// actionOf returns the name of the action that corresponds to opCode
+func actionOf(opCode defaultOpCode) string {
+	switch opCode {
+	case ListOp:
+		return ListAction().Name
+	case OpenOp:
+		return ReadAction().Name
+	default:
+		return "metadata"
+	}
+}
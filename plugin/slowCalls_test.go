@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type SlowCallsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SlowCallsTestSuite) SetupTest() {
+	slowCallStats = make(map[string]*SlowCallStat)
+}
+
+func (suite *SlowCallsTestSuite) TearDownTest() {
+	slowCallStats = make(map[string]*SlowCallStat)
+	_, _ = limits.Set(slowCallThreshold.Name(), 10000)
+}
+
+func (suite *SlowCallsTestSuite) newEntry(id string) Entry {
+	e := newCacheTestsMockEntry("foo")
+	e.SetTestID(id)
+	return e
+}
+
+func (suite *SlowCallsTestSuite) TestThresholds() {
+	registerSlowCallThresholds("slowplugin")
+	suite.Equal(10*time.Second, slowCallThresholdOf("slowplugin", "list"))
+	suite.Equal(10*time.Second, slowCallThresholdOf("unregistered", "list"))
+
+	_, err := limits.Set(slowCallThresholdName("slowplugin", "list"), 500)
+	suite.NoError(err)
+	defer func() {
+		_, _ = limits.Set(slowCallThresholdName("slowplugin", "list"), 0)
+	}()
+	suite.Equal(500*time.Millisecond, slowCallThresholdOf("slowplugin", "list"))
+	suite.Equal(10*time.Second, slowCallThresholdOf("slowplugin", "read"))
+}
+
+func (suite *SlowCallsTestSuite) TestTrackLatency() {
+	_, err := limits.Set(slowCallThreshold.Name(), 1000)
+	suite.NoError(err)
+	ctx := context.Background()
+	e := suite.newEntry("/slowplugin/foo")
+
+	trackLatency(ctx, e, "list", time.Now().Add(-10*time.Millisecond))
+	suite.Empty(SlowCallStats())
+
+	trackLatency(ctx, e, "list", time.Now().Add(-2*time.Second))
+	trackLatency(ctx, e, "list", time.Now().Add(-3*time.Second))
+	trackLatency(ctx, e, "read", time.Now().Add(-2*time.Second))
+	stats := SlowCallStats()
+	if suite.Len(stats, 2) {
+		suite.Equal("slowplugin", stats[0].Plugin)
+		suite.Equal("list", stats[0].Action)
+		suite.Equal(2, stats[0].Count)
+		suite.InDelta(3000, stats[0].MaxMS, 100)
+		suite.Equal("/slowplugin/foo", stats[0].LastPath)
+		suite.Equal("read", stats[1].Action)
+		suite.Equal(1, stats[1].Count)
+	}
+
+	// 0 disables slow-call tracking
+	_, err = limits.Set(slowCallThreshold.Name(), 0)
+	suite.NoError(err)
+	trackLatency(ctx, e, "read", time.Now().Add(-time.Hour))
+	suite.Equal(1, SlowCallStats()[1].Count)
+}
+
+func TestSlowCalls(t *testing.T) {
+	suite.Run(t, new(SlowCallsTestSuite))
+}
@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+// Snapshot is a frozen copy of the parts of the plugin tree that are cached
+// at a point in time. Capturing a snapshot doesn't invoke any plugins, so it
+// only includes the listings, content and metadata that were in the cache.
+// Snapshots can be serialized to JSON so that they can be mounted later (see
+// `wash mount --snapshot`).
+type Snapshot struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Entries maps each captured entry's ID (its path relative to Wash's
+	// root) to the entry
+	Entries map[string]*SnapshotEntry `json:"entries"`
+}
+
+// SnapshotEntry is an entry that was captured in a snapshot
+type SnapshotEntry struct {
+	// CName is the entry's cname, which is also its name in the snapshot
+	CName      string          `json:"cname"`
+	Actions    []string        `json:"actions"`
+	Attributes EntryAttributes `json:"attributes"`
+	// Children are the cnames of the entry's children. It's only set if the
+	// entry's listing was cached, which is indicated by Listed.
+	Children []string `json:"children,omitempty"`
+	Listed   bool     `json:"listed"`
+	// Content is the entry's content. It's only set if the entry's content was
+	// cached, which is indicated by HasContent.
+	Content    []byte `json:"content,omitempty"`
+	HasContent bool   `json:"has_content"`
+	// Metadata is the entry's metadata. It's nil if it wasn't cached.
+	Metadata JSONObject `json:"metadata,omitempty"`
+}
+
+// CaptureSnapshot captures a snapshot of the cached parts of r's plugin tree.
+// Entries are only captured if their parent's listing is cached, and their
+// content is only captured if it's cached in memory (the content of entries
+// that support partial reads is fetched on demand, so it's never captured).
+func CaptureSnapshot(r *Registry, id string) *Snapshot {
+	s := &Snapshot{ID: id, Time: time.Now(), Entries: make(map[string]*SnapshotEntry)}
+	root := &SnapshotEntry{CName: "/", Actions: []string{ListAction().Name}, Listed: true}
+	for _, p := range r.roots() {
+		root.Children = append(root.Children, CName(p))
+		captureEntry(s, p)
+	}
+	sort.Strings(root.Children)
+	s.Entries[r.id()] = root
+	return s
+}
+
+func captureEntry(s *Snapshot, e Entry) {
+	captured := &SnapshotEntry{
+		CName:      CName(e),
+		Actions:    SupportedActionsOf(e),
+		Attributes: e.attributes(),
+	}
+	s.Entries[e.id()] = captured
+
+	if ListAction().IsSupportedOn(e) {
+		if children, ok := cachedValue(ListOp, e).(map[string]Entry); ok {
+			captured.Listed = true
+			captured.Children = make([]string, 0, len(children))
+			for cname, child := range children {
+				captured.Children = append(captured.Children, cname)
+				captureEntry(s, child)
+			}
+			sort.Strings(captured.Children)
+		}
+	}
+	if ReadAction().IsSupportedOn(e) {
+		if content, ok := cachedValue(OpenOp, e).(SizedReader); ok {
+			if !fetchesOnDemand(content) {
+				data, err := ioutil.ReadAll(io.NewSectionReader(content, 0, content.Size()))
+				if err == nil {
+					captured.Content, captured.HasContent = data, true
+				}
+			}
+		}
+	}
+	if metadata, ok := cachedValue(MetadataOp, e).(JSONObject); ok {
+		captured.Metadata = metadata
+	}
+}
+
+// fetchesOnDemand returns true if reading content would invoke its plugin
+func fetchesOnDemand(content SizedReader) bool {
+	if _, ok := content.(*blockCachedReader); ok {
+		return true
+	}
+	pr, ok := content.(PartialReader)
+	return ok && pr.SupportsPartialReads()
+}
+
+// cachedValue returns e's cached result for the given op without invoking
+// the op. It returns nil if the result isn't cached or if it's an error.
+func cachedValue(opCode defaultOpCode, e Entry) interface{} {
+	if cache == nil || e.id() == "" {
+		return nil
+	}
+	value, err := cache.Get(defaultOpCodeToNameMap[opCode], e.id())
+	if err != nil {
+		return nil
+	}
+	return value
+}
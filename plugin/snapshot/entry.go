@@ -0,0 +1,159 @@
+// Package snapshot stores the snapshots of Wash's cache and presents them as
+// read-only plugin trees. Mounted snapshots never invoke the plugins that the
+// snapshot was captured from; everything is served from the snapshot.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// NewRegistry returns a registry whose plugins are the plugin roots that were
+// captured in s
+func NewRegistry(s *plugin.Snapshot) (*plugin.Registry, error) {
+	top, ok := s.Entries["/"]
+	if !ok {
+		return nil, fmt.Errorf("snapshot %v does not include Wash's root", s.ID)
+	}
+	registry := plugin.NewRegistry()
+	for _, cname := range top.Children {
+		root := &root{dir{entry{tree: tree{snapshot: s, id: "/" + cname}}}}
+		if err := registry.RegisterPlugin(root, nil); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// tree is the part of a snapshot that's rooted at the entry with the given ID
+type tree struct {
+	snapshot *plugin.Snapshot
+	id       string
+}
+
+func (t tree) entry() *plugin.SnapshotEntry {
+	return t.snapshot.Entries[t.id]
+}
+
+// newEntry returns the snapshot's entry at the given ID. The returned entry
+// only supports the list and read actions, and only if it supported them when
+// the snapshot was captured.
+func newEntry(t tree) plugin.Entry {
+	captured := t.entry()
+	base := entry{EntryBase: plugin.NewEntry(captured.CName), tree: t}
+	base.SetAttributes(captured.Attributes)
+	// Snapshots never change, so there's nothing to gain from caching them
+	base.DisableDefaultCaching()
+
+	var isParent, isReadable bool
+	for _, action := range captured.Actions {
+		switch action {
+		case plugin.ListAction().Name:
+			isParent = true
+		case plugin.ReadAction().Name:
+			isReadable = true
+		}
+	}
+	switch {
+	case isParent && isReadable:
+		return &readableDir{dir{base}}
+	case isParent:
+		return &dir{base}
+	case isReadable:
+		return &file{base}
+	default:
+		return &base
+	}
+}
+
+type entry struct {
+	plugin.EntryBase
+	tree
+}
+
+func (e *entry) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+// Metadata returns the entry's captured metadata, or its meta attribute if its
+// metadata wasn't captured
+func (e *entry) Metadata(ctx context.Context) (plugin.JSONObject, error) {
+	if metadata := e.tree.entry().Metadata; metadata != nil {
+		return metadata, nil
+	}
+	return e.EntryBase.Metadata(ctx)
+}
+
+type dir struct {
+	entry
+}
+
+func (d *dir) ChildSchemas() []*plugin.EntrySchema {
+	return nil
+}
+
+func (d *dir) List(ctx context.Context) ([]plugin.Entry, error) {
+	captured := d.tree.entry()
+	if !captured.Listed {
+		return nil, fmt.Errorf("snapshot %v does not include the children of %v: they weren't cached when it was captured", d.snapshot.ID, d.id)
+	}
+	entries := make([]plugin.Entry, 0, len(captured.Children))
+	for _, cname := range captured.Children {
+		child := tree{snapshot: d.snapshot, id: strings.TrimRight(d.id, "/") + "/" + cname}
+		if child.entry() == nil {
+			continue
+		}
+		entries = append(entries, newEntry(child))
+	}
+	return entries, nil
+}
+
+func open(e *entry) (plugin.SizedReader, error) {
+	captured := e.tree.entry()
+	if !captured.HasContent {
+		return nil, fmt.Errorf("snapshot %v does not include the content of %v: it wasn't cached when it was captured", e.snapshot.ID, e.id)
+	}
+	return bytes.NewReader(captured.Content), nil
+}
+
+type file struct {
+	entry
+}
+
+func (f *file) Open(ctx context.Context) (plugin.SizedReader, error) {
+	return open(&f.entry)
+}
+
+type readableDir struct {
+	dir
+}
+
+func (d *readableDir) Open(ctx context.Context) (plugin.SizedReader, error) {
+	return open(&d.entry)
+}
+
+// root is the root of one of the snapshot's plugins. Only its tree is set
+// until it's initialized.
+type root struct {
+	dir
+}
+
+func (r *root) Init(map[string]interface{}) error {
+	captured := r.tree.entry()
+	if captured == nil {
+		return fmt.Errorf("snapshot %v does not include %v", r.snapshot.ID, r.id)
+	}
+	switch d := newEntry(r.tree).(type) {
+	case *dir:
+		r.dir = *d
+	case *readableDir:
+		r.dir = d.dir
+	default:
+		return fmt.Errorf("the %v plugin root in snapshot %v isn't a directory", captured.CName, r.snapshot.ID)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// HandoffPath is where a stopping Wash server saves the snapshot of its cache
+// so that the server that replaces it (e.g. after an upgrade) can re-warm its
+// own cache instead of starting cold
+var HandoffPath = filepath.Join(filepath.Dir(Dir), "handoff.json")
+
+// MaxHandoffAge is the age after which a handoff is too stale to be worth
+// re-warming from
+const MaxHandoffAge = 10 * time.Minute
+
+// SaveHandoff saves s as the handoff for the next server
+func SaveHandoff(s *plugin.Snapshot) error {
+	if err := os.MkdirAll(filepath.Dir(HandoffPath), 0700); err != nil {
+		return fmt.Errorf("could not create the handoff's directory: %v", err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("could not marshal the handoff: %v", err)
+	}
+	// Write to a temporary file first so that the next server never reads a
+	// partially written handoff
+	tmp := HandoffPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("could not save the handoff: %v", err)
+	}
+	if err := os.Rename(tmp, HandoffPath); err != nil {
+		return fmt.Errorf("could not save the handoff: %v", err)
+	}
+	return nil
+}
+
+// TakeHandoff loads and removes the handoff that the previous server saved.
+// It returns nil if there's no handoff, or if it's older than MaxHandoffAge.
+// The handoff's removed either way so that it's only ever used once.
+func TakeHandoff() (*plugin.Snapshot, error) {
+	if _, err := os.Stat(HandoffPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	s, err := Load(HandoffPath)
+	if rmErr := os.Remove(HandoffPath); rmErr != nil && !os.IsNotExist(rmErr) && err == nil {
+		err = fmt.Errorf("could not remove the handoff: %v", rmErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(s.Time) > MaxHandoffAge {
+		return nil, nil
+	}
+	return s, nil
+}
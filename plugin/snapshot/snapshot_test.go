@@ -0,0 +1,174 @@
+package snapshot
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type SnapshotTestSuite struct {
+	suite.Suite
+	dir         string
+	handoffPath string
+}
+
+func (suite *SnapshotTestSuite) SetupTest() {
+	tmp, err := ioutil.TempDir("", "wash-snapshots")
+	suite.NoError(err)
+	suite.dir, Dir = Dir, tmp
+	suite.handoffPath, HandoffPath = HandoffPath, filepath.Join(tmp, "handoff.json")
+}
+
+func (suite *SnapshotTestSuite) TearDownTest() {
+	suite.NoError(os.RemoveAll(Dir))
+	Dir = suite.dir
+	HandoffPath = suite.handoffPath
+}
+
+func newTestSnapshot(id string, t time.Time) *plugin.Snapshot {
+	list, read := plugin.ListAction().Name, plugin.ReadAction().Name
+	return &plugin.Snapshot{
+		ID:   id,
+		Time: t,
+		Entries: map[string]*plugin.SnapshotEntry{
+			"/":             {CName: "/", Actions: []string{list}, Children: []string{"mock"}, Listed: true},
+			"/mock":         {CName: "mock", Actions: []string{list}, Children: []string{"file", "dir"}, Listed: true},
+			"/mock/file":    {CName: "file", Actions: []string{read}, Content: []byte("hello"), HasContent: true, Metadata: plugin.JSONObject{"foo": "bar"}},
+			"/mock/dir":     {CName: "dir", Actions: []string{list, read}},
+			"/mock/missing": {CName: "missing"},
+		},
+	}
+}
+
+func (suite *SnapshotTestSuite) TestNewRegistry() {
+	ctx := context.Background()
+	registry, err := NewRegistry(newTestSnapshot("id", time.Now()))
+	if !suite.NoError(err) {
+		return
+	}
+	root, ok := registry.Plugins()["mock"]
+	if !suite.True(ok) {
+		return
+	}
+
+	entries, err := root.List(ctx)
+	if !suite.NoError(err) || !suite.Len(entries, 2) {
+		return
+	}
+	file, dir := entries[0], entries[1]
+	suite.Equal("file", plugin.CName(file))
+	suite.Equal("dir", plugin.CName(dir))
+
+	rdr, err := file.(plugin.Readable).Open(ctx)
+	if suite.NoError(err) {
+		content := make([]byte, rdr.Size())
+		_, err := rdr.ReadAt(content, 0)
+		suite.NoError(err)
+		suite.Equal("hello", string(content))
+	}
+	suite.False(plugin.ListAction().IsSupportedOn(file))
+	metadata, err := file.Metadata(ctx)
+	suite.NoError(err)
+	suite.Equal(plugin.JSONObject{"foo": "bar"}, metadata)
+
+	_, err = dir.(plugin.Parent).List(ctx)
+	suite.EqualError(err, "snapshot id does not include the children of /mock/dir: they weren't cached when it was captured")
+	_, err = dir.(plugin.Readable).Open(ctx)
+	suite.EqualError(err, "snapshot id does not include the content of /mock/dir: it wasn't cached when it was captured")
+}
+
+func (suite *SnapshotTestSuite) TestNewRegistryWithoutRoot() {
+	s := newTestSnapshot("id", time.Now())
+	delete(s.Entries, "/")
+	_, err := NewRegistry(s)
+	suite.EqualError(err, "snapshot id does not include Wash's root")
+}
+
+func (suite *SnapshotTestSuite) TestSaveAndLoad() {
+	t := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	id := NewID(t)
+	suite.Equal("20200102T030405Z", id)
+
+	s := newTestSnapshot(id, t)
+	path, err := Save(s)
+	if !suite.NoError(err) {
+		return
+	}
+	suite.Equal("20200102T030405Z-2", NewID(t))
+
+	loaded, err := Load(path)
+	if suite.NoError(err) {
+		suite.Equal(s.ID, loaded.ID)
+		suite.True(s.Time.Equal(loaded.Time))
+		suite.Equal(s.Entries["/mock/file"].Content, loaded.Entries["/mock/file"].Content)
+		suite.Equal(s.Entries["/mock"].Children, loaded.Entries["/mock"].Children)
+	}
+}
+
+func (suite *SnapshotTestSuite) TestFind() {
+	_, err := Find("20200102T030405Z")
+	suite.EqualError(err, "there are no snapshots. Use wash snapshot to capture one")
+
+	older := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	for _, t := range []time.Time{older, newer} {
+		_, err := Save(newTestSnapshot(NewID(t), t))
+		suite.NoError(err)
+	}
+
+	infos, err := List()
+	if suite.NoError(err) && suite.Len(infos, 2) {
+		suite.Equal("20200102T030405Z", infos[0].ID)
+		suite.Equal("20200102T040405Z", infos[1].ID)
+	}
+
+	info, err := Find("20200102T040405Z")
+	if suite.NoError(err) {
+		suite.Equal("20200102T040405Z", info.ID)
+	}
+	info, err = Find(older.Add(30 * time.Minute).Format(time.RFC3339))
+	if suite.NoError(err) {
+		suite.Equal("20200102T030405Z", info.ID)
+	}
+	_, err = Find(older.Add(-time.Minute).Format(time.RFC3339))
+	suite.EqualError(err, "there are no snapshots from 2020-01-02T03:03:05Z or earlier. The oldest snapshot is from 2020-01-02T03:04:05Z")
+	_, err = Find("foo")
+	suite.EqualError(err, "foo is neither a snapshot ID nor a timestamp")
+}
+
+func (suite *SnapshotTestSuite) TestTakeHandoff() {
+	s, err := TakeHandoff()
+	suite.NoError(err)
+	suite.Nil(s)
+
+	suite.NoError(SaveHandoff(newTestSnapshot("handoff", time.Now())))
+	s, err = TakeHandoff()
+	if suite.NoError(err) && suite.NotNil(s) {
+		suite.Equal("handoff", s.ID)
+		suite.Equal("hello", string(s.Entries["/mock/file"].Content))
+	}
+
+	// Handoffs are only taken once
+	s, err = TakeHandoff()
+	suite.NoError(err)
+	suite.Nil(s)
+}
+
+func (suite *SnapshotTestSuite) TestTakeHandoffIgnoresStaleHandoffs() {
+	suite.NoError(SaveHandoff(newTestSnapshot("handoff", time.Now().Add(-2*MaxHandoffAge))))
+	s, err := TakeHandoff()
+	suite.NoError(err)
+	suite.Nil(s)
+	_, err = os.Stat(HandoffPath)
+	suite.True(os.IsNotExist(err))
+}
+
+func TestSnapshot(t *testing.T) {
+	suite.Run(t, new(SnapshotTestSuite))
+}
@@ -0,0 +1,144 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/puppetlabs/wash/munge"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/puppetlabs/wash/retention"
+)
+
+// Dir is the directory that snapshots are saved in
+var Dir = func() string {
+	cdir, err := os.UserCacheDir()
+	if err != nil {
+		cdir = os.TempDir()
+	}
+	return filepath.Join(cdir, "wash", "snapshots")
+}()
+
+// Saved snapshots are pruned according to the "snapshots" retention policy
+var _ = retention.Register("snapshots", func() string { return Dir }, nil)
+
+// idLayout is the layout of a snapshot ID's timestamp. IDs start with the
+// time that the snapshot was captured at so that listing them doesn't require
+// reading them.
+const idLayout = "20060102T150405Z"
+
+// Info describes a saved snapshot
+type Info struct {
+	ID   string
+	Time time.Time
+	Path string
+}
+
+func pathOf(id string) string {
+	return filepath.Join(Dir, id+".json")
+}
+
+// NewID returns the ID of a snapshot captured at t. IDs are unique among the
+// saved snapshots.
+func NewID(t time.Time) string {
+	id := t.UTC().Format(idLayout)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(pathOf(id)); os.IsNotExist(err) {
+			return id
+		}
+		id = fmt.Sprintf("%v-%v", t.UTC().Format(idLayout), i)
+	}
+}
+
+// Save saves s so that it can be mounted later. It returns the path of the
+// saved snapshot.
+func Save(s *plugin.Snapshot) (string, error) {
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return "", fmt.Errorf("could not create the snapshots directory %v: %v", Dir, err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal snapshot %v: %v", s.ID, err)
+	}
+	path := pathOf(s.ID)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("could not save snapshot %v: %v", s.ID, err)
+	}
+	return path, nil
+}
+
+// Load loads the snapshot that was saved at path
+func Load(path string) (*plugin.Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the snapshot: %v", err)
+	}
+	var s plugin.Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("could not decode the snapshot at %v: %v", path, err)
+	}
+	return &s, nil
+}
+
+// List returns the saved snapshots, from oldest to newest
+func List() ([]Info, error) {
+	files, err := ioutil.ReadDir(Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not list the snapshots: %v", err)
+	}
+	var infos []Info
+	for _, f := range files {
+		id := strings.TrimSuffix(f.Name(), ".json")
+		if f.IsDir() || id == f.Name() || len(id) < len(idLayout) {
+			continue
+		}
+		t, err := time.Parse(idLayout, id[:len(idLayout)])
+		if err != nil {
+			continue
+		}
+		infos = append(infos, Info{ID: id, Time: t, Path: filepath.Join(Dir, f.Name())})
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		if !infos[i].Time.Equal(infos[j].Time) {
+			return infos[i].Time.Before(infos[j].Time)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos, nil
+}
+
+// Find returns the saved snapshot that ref refers to. ref is either a
+// snapshot's ID or a timestamp, in which case Find returns the latest snapshot
+// that was captured at or before it.
+func Find(ref string) (Info, error) {
+	infos, err := List()
+	if err != nil {
+		return Info{}, err
+	}
+	if len(infos) == 0 {
+		return Info{}, fmt.Errorf("there are no snapshots. Use wash snapshot to capture one")
+	}
+	for _, info := range infos {
+		if info.ID == ref {
+			return info, nil
+		}
+	}
+	t, err := munge.ToTime(ref)
+	if err != nil {
+		return Info{}, fmt.Errorf("%v is neither a snapshot ID nor a timestamp", ref)
+	}
+	for i := len(infos) - 1; i >= 0; i-- {
+		if !infos[i].Time.After(t) {
+			return infos[i], nil
+		}
+	}
+	return Info{}, fmt.Errorf("there are no snapshots from %v or earlier. The oldest snapshot is from %v", t.Format(time.RFC3339), infos[0].Time.Format(time.RFC3339))
+}
@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/stretchr/testify/suite"
+)
+
+type snapshotTestsRoot struct {
+	mockParent
+}
+
+func (r *snapshotTestsRoot) Init(map[string]interface{}) error {
+	return nil
+}
+
+type snapshotTestsFile struct {
+	EntryBase
+	content string
+}
+
+func (f *snapshotTestsFile) Schema() *EntrySchema {
+	return nil
+}
+
+func (f *snapshotTestsFile) Open(context.Context) (SizedReader, error) {
+	return strings.NewReader(f.content), nil
+}
+
+type SnapshotTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SnapshotTestSuite) SetupTest() {
+	SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *SnapshotTestSuite) TearDownTest() {
+	UnsetTestCache()
+}
+
+func (suite *SnapshotTestSuite) TestCaptureSnapshotOnlyCapturesCachedData() {
+	ctx := context.Background()
+	cached := &snapshotTestsFile{EntryBase: NewEntry("cached"), content: "hello"}
+	uncached := &snapshotTestsFile{EntryBase: NewEntry("uncached"), content: "world"}
+	unlisted := &mockParent{EntryBase: NewEntry("unlisted"), entries: []Entry{newMockEntry("child")}}
+	root := &snapshotTestsRoot{mockParent{EntryBase: NewEntry("mock"), entries: []Entry{cached, uncached, unlisted}}}
+
+	registry := NewRegistry()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+	_, err := CachedList(ctx, registry)
+	suite.NoError(err)
+	_, err = CachedList(ctx, root)
+	suite.NoError(err)
+	_, err = CachedOpen(ctx, cached)
+	suite.NoError(err)
+	_, err = CachedMetadata(ctx, cached)
+	suite.NoError(err)
+
+	s := CaptureSnapshot(registry, "id")
+	suite.Equal("id", s.ID)
+	suite.Equal([]string{"mock"}, s.Entries["/"].Children)
+
+	mock := s.Entries["/mock"]
+	if suite.NotNil(mock) {
+		suite.True(mock.Listed)
+		suite.Equal([]string{"cached", "uncached", "unlisted"}, mock.Children)
+	}
+
+	captured := s.Entries["/mock/cached"]
+	if suite.NotNil(captured) {
+		suite.True(captured.HasContent)
+		suite.Equal("hello", string(captured.Content))
+		suite.NotNil(captured.Metadata)
+		suite.Contains(captured.Actions, ReadAction().Name)
+	}
+
+	captured = s.Entries["/mock/uncached"]
+	if suite.NotNil(captured) {
+		suite.False(captured.HasContent)
+		suite.Nil(captured.Metadata)
+	}
+
+	captured = s.Entries["/mock/unlisted"]
+	if suite.NotNil(captured) {
+		suite.False(captured.Listed)
+		suite.Empty(captured.Children)
+	}
+	suite.NotContains(s.Entries, "/mock/unlisted/child")
+}
+
+func TestSnapshot(t *testing.T) {
+	suite.Run(t, new(SnapshotTestSuite))
+}
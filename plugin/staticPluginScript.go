@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+)
+
+// staticPluginScript serves a static plugin, which is a YAML or JSON file
+// describing the plugin's entire tree. The file contains the plugin root as it
+// would be returned by init, with the results of all of its entries' methods
+// prefetched. Thus, init is the only method that's ever invoked.
+type staticPluginScript struct {
+	path string
+	// root is the JSON-serialized plugin root
+	root []byte
+}
+
+func newStaticPluginScript(name string, path string) (staticPluginScript, error) {
+	rawRoot, err := ioutil.ReadFile(path)
+	if err != nil {
+		return staticPluginScript{}, err
+	}
+	// YAML is a superset of JSON, so this also works for JSON files
+	root, err := yaml.YAMLToJSON(rawRoot)
+	if err != nil {
+		return staticPluginScript{}, fmt.Errorf("could not parse static plugin %v: %v", path, err)
+	}
+	var decodedRoot decodedExternalPluginEntry
+	if err := json.Unmarshal(root, &decodedRoot); err != nil {
+		return staticPluginScript{}, fmt.Errorf("could not decode the plugin root from static plugin %v: %v", path, err)
+	}
+	if decodedRoot.Name == "" {
+		decodedRoot.Name = name
+	}
+	if err := validateStaticEntry(decodedRoot, true); err != nil {
+		return staticPluginScript{}, fmt.Errorf("static plugin %v is invalid: %v", path, err)
+	}
+	return staticPluginScript{path: path, root: root}, nil
+}
+
+// validateStaticEntry ensures that Wash never has to invoke a method on e or on
+// any of its descendants.
+func validateStaticEntry(e decodedExternalPluginEntry, isRoot bool) error {
+	if e.Methods == nil {
+		return fmt.Errorf("entry %v must provide its methods", e.Name)
+	}
+	methods, err := mungeToMethods(e.Methods)
+	if err != nil {
+		return fmt.Errorf("entry %v: %v", e.Name, err)
+	}
+	for method, result := range methods {
+		switch method {
+		case "list":
+			if result == nil {
+				return fmt.Errorf("entry %v must prefetch its list result", e.Name)
+			}
+			bits, err := json.Marshal(result)
+			if err != nil {
+				panic(fmt.Sprintf("Error remarshaling previously unmarshaled data: %v", err))
+			}
+			var children []decodedExternalPluginEntry
+			if err := json.Unmarshal(bits, &children); err != nil {
+				return fmt.Errorf("entry %v: implementation of list must conform to %v, not %v", e.Name, listFormat, result)
+			}
+			for _, child := range children {
+				if err := validateStaticEntry(child, false); err != nil {
+					return err
+				}
+			}
+		case "read":
+			if _, ok := result.(string); !ok {
+				return fmt.Errorf("entry %v must prefetch its read result as a string", e.Name)
+			}
+		case "schema":
+			// Only roots can prefetch their schema. The other entries' schemas
+			// are included in the root's schema.
+			if isRoot && result == nil {
+				return fmt.Errorf("entry %v must prefetch its schema result", e.Name)
+			}
+		default:
+			return fmt.Errorf("entry %v implements %v, but static plugins only support the list, read and schema methods", e.Name, method)
+		}
+	}
+	return nil
+}
+
+func (s staticPluginScript) Path() string {
+	return s.path
+}
+
+// InvokeAndWait returns the plugin root for init. Every other method's result
+// is prefetched, so invoking them returns an error.
+func (s staticPluginScript) InvokeAndWait(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	args ...string,
+) (invocation, error) {
+	var inv invocation
+	if method != "init" {
+		return inv, fmt.Errorf("static plugin %v does not implement %v", s.path, method)
+	}
+	inv.stdout.Write(s.root)
+	return inv, nil
+}
+
+// InvokeAndWaitWithStdin returns an error since static plugins don't
+// implement any methods that read stdin
+func (s staticPluginScript) InvokeAndWaitWithStdin(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	stdin io.Reader,
+	args ...string,
+) (invocation, error) {
+	return invocation{}, fmt.Errorf("static plugin %v does not implement %v", s.path, method)
+}
+
+func (s staticPluginScript) NewInvocation(
+	ctx context.Context,
+	method string,
+	entry *externalPluginEntry,
+	args ...string,
+) invocation {
+	// This should never happen since newStaticPluginScript ensures that static
+	// plugins only implement prefetched methods.
+	panic(fmt.Sprintf("s.NewInvocation called with method '%v' on static plugin %v", method, s.path))
+}
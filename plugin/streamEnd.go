@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+)
+
+// StreamEnd describes why a stream ended. Streams that know why their source
+// terminated (e.g. the container exited, or the provider closed its log
+// stream) still end with io.EOF like any other io.Reader, but they also
+// report a StreamEnd (see StreamEndOf) so that clients are told why the stream
+// ended instead of seeing a silent EOF.
+type StreamEnd struct {
+	// Reason is a human-readable description of why the stream ended
+	Reason string `json:"reason"`
+	// ExitCode is the exit status of the stream's source (e.g. a container's
+	// exit code). It's nil if the source doesn't have one or it's unknown.
+	ExitCode *int `json:"exit_code,omitempty"`
+}
+
+// NewStreamEnd returns a StreamEnd with the given reason
+func NewStreamEnd(reason string) *StreamEnd {
+	return &StreamEnd{Reason: reason}
+}
+
+// NewStreamExit returns a StreamEnd with the given reason and exit code
+func NewStreamExit(reason string, exitCode int) *StreamEnd {
+	return &StreamEnd{Reason: reason, ExitCode: &exitCode}
+}
+
+func (e *StreamEnd) Error() string {
+	if e.ExitCode != nil {
+		return fmt.Sprintf("%v (exit status %v)", e.Reason, *e.ExitCode)
+	}
+	return e.Reason
+}
+
+// streamEnder is a stream that knows why it ended once it's returned io.EOF
+type streamEnder interface {
+	streamEnd() *StreamEnd
+}
+
+// StreamEndOf returns the StreamEnd that describes why the stream ended with
+// err, which is the error that its final Read returned. It's nil if err is
+// nil. Streams that end with io.EOF without knowing why are described as
+// closed by their source.
+func StreamEndOf(stream io.Reader, err error) *StreamEnd {
+	switch {
+	case err == nil:
+		return nil
+	case err != io.EOF:
+		return NewStreamEnd(err.Error())
+	}
+	if e, ok := stream.(streamEnder); ok {
+		if end := e.streamEnd(); end != nil {
+			return end
+		}
+	}
+	return NewStreamEnd("the stream was closed by its source")
+}
+
+// EndingReader is a wrapper for a stream's io.ReadCloser that reports the
+// StreamEnd returned by End once the stream returns io.EOF. It's useful for
+// streams that can only tell why they ended once their source has stopped
+// sending output (e.g. by inspecting the container whose logs they stream).
+type EndingReader struct {
+	io.ReadCloser
+	End func() *StreamEnd
+	end *StreamEnd
+}
+
+// Read reads from the reader it wraps. End's invoked once the reader returns
+// io.EOF.
+func (r *EndingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF && r.end == nil {
+		r.end = r.End()
+	}
+	return n, err
+}
+
+func (r *EndingReader) streamEnd() *StreamEnd {
+	return r.end
+}
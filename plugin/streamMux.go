@@ -0,0 +1,272 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/puppetlabs/wash/activity"
+	"github.com/puppetlabs/wash/limits"
+)
+
+// streamBufferSize is the maximum amount of a shared stream's output that
+// each of its subscribers buffers
+var streamBufferSize = limits.Register(
+	"plugins.stream_buffer_kb",
+	"Clients that stream the same entry share a single stream from the plugin. Each client buffers up to this many KB of the stream's output. Clients that fall further behind are disconnected so that they don't slow down the other clients. 0 means unlimited.",
+	1024,
+	nil,
+)
+
+// streamMux fans out the output of an entry's stream to all of its
+// subscribers. Each subscriber buffers its own output so that a slow
+// subscriber doesn't block the others. If the stream's offset is known (see
+// StreamOffsetOf), then it's tracked so that each subscriber knows where its
+// output starts.
+type streamMux struct {
+	id          string
+	rdr         io.ReadCloser
+	cancel      context.CancelFunc
+	started     chan struct{}
+	err         error
+	mux         sync.Mutex
+	subscribers map[*streamSubscriber]struct{}
+	closed      bool
+	offset      int64
+	hasOffset   bool
+}
+
+var streamMuxesMux sync.Mutex
+var streamMuxes = make(map[string]*streamMux)
+
+// subscribeToStream returns a subscription to the shared stream of s. The
+// shared stream is started if s doesn't have one, and it's closed once all of
+// its subscribers are closed. Subscribers only receive the output that's
+// streamed after they subscribe.
+func subscribeToStream(ctx context.Context, s Streamable) (io.ReadCloser, error) {
+	id := s.id()
+	for {
+		streamMuxesMux.Lock()
+		m, ok := streamMuxes[id]
+		if !ok {
+			m = &streamMux{
+				id:          id,
+				started:     make(chan struct{}),
+				subscribers: make(map[*streamSubscriber]struct{}),
+			}
+			streamMuxes[id] = m
+			streamMuxesMux.Unlock()
+			return m.start(ctx, s)
+		}
+		streamMuxesMux.Unlock()
+
+		select {
+		case <-m.started:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if m.err != nil {
+			return nil, m.err
+		}
+		if sub, ok := m.subscribe(); ok {
+			activity.Record(ctx, "Attached to the existing stream of %v", id)
+			return sub, nil
+		}
+		// The stream ended before we could subscribe to it, so start a new
+		// one
+	}
+}
+
+// start starts the shared stream and returns its first subscriber
+func (m *streamMux) start(ctx context.Context, s Streamable) (io.ReadCloser, error) {
+	defer close(m.started)
+
+	// The shared stream outlives the request that started it, so it's only
+	// cancelled once all of its subscribers are closed.
+	streamCtx, cancel := context.WithCancel(DetachedContext(ctx))
+	rdr, err := s.Stream(streamCtx)
+	if err != nil {
+		cancel()
+		m.err = err
+		m.remove()
+		return nil, err
+	}
+	m.rdr, m.cancel = rdr, cancel
+	m.offset, m.hasOffset = StreamOffsetOf(rdr)
+
+	sub, _ := m.subscribe()
+	go m.pump()
+	return sub, nil
+}
+
+func (m *streamMux) subscribe() (*streamSubscriber, bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.closed {
+		return nil, false
+	}
+	sub := &streamSubscriber{mux: m, offset: m.offset, hasOffset: m.hasOffset}
+	sub.cond = sync.NewCond(&sub.lock)
+	m.subscribers[sub] = struct{}{}
+	return sub, true
+}
+
+func (m *streamMux) pump() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := m.rdr.Read(buf)
+		if n > 0 {
+			m.broadcast(buf[:n])
+		}
+		if err != nil {
+			m.finish(err)
+			return
+		}
+	}
+}
+
+func (m *streamMux) broadcast(data []byte) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.hasOffset {
+		m.offset += int64(len(data))
+	}
+	max := streamBufferSize.Value() * 1024
+	for sub := range m.subscribers {
+		if !sub.write(data, max) {
+			delete(m.subscribers, sub)
+		}
+	}
+	if len(m.subscribers) == 0 {
+		m.closeLocked()
+	}
+}
+
+// finish ends all of the subscriptions with err once the stream ends
+func (m *streamMux) finish(err error) {
+	end := StreamEndOf(m.rdr, err)
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for sub := range m.subscribers {
+		sub.end(err, end)
+	}
+	m.subscribers = make(map[*streamSubscriber]struct{})
+	m.closeLocked()
+}
+
+func (m *streamMux) unsubscribe(sub *streamSubscriber) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.subscribers, sub)
+	if len(m.subscribers) == 0 {
+		m.closeLocked()
+	}
+}
+
+// closeLocked closes the shared stream. It must be called with m.mux held.
+func (m *streamMux) closeLocked() {
+	if m.closed {
+		return
+	}
+	m.closed = true
+	m.remove()
+	m.cancel()
+	if err := m.rdr.Close(); err != nil {
+		activity.Record(context.Background(), "Closing the shared stream of %v errored: %v", m.id, err)
+	}
+}
+
+func (m *streamMux) remove() {
+	streamMuxesMux.Lock()
+	defer streamMuxesMux.Unlock()
+	if streamMuxes[m.id] == m {
+		delete(streamMuxes, m.id)
+	}
+}
+
+// streamSubscriber is a subscription to a shared stream
+type streamSubscriber struct {
+	mux       *streamMux
+	lock      sync.Mutex
+	cond      *sync.Cond
+	buf       bytes.Buffer
+	err       error
+	ended     *StreamEnd
+	closed    bool
+	offset    int64
+	hasOffset bool
+}
+
+// write buffers data. It returns false if the subscriber's closed, or if
+// buffering data would exceed max bytes, in which case the subscriber is
+// ended once it reads what it's already buffered.
+func (s *streamSubscriber) write(data []byte, max int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	defer s.cond.Broadcast()
+	if s.closed || s.err != nil {
+		return false
+	}
+	if max > 0 && s.buf.Len()+len(data) > max {
+		s.err = fmt.Errorf("the stream was disconnected because more than %v KB of its output was unread. Increase the %v limit if that's expected", max/1024, streamBufferSize.Name())
+		return false
+	}
+	s.buf.Write(data)
+	return true
+}
+
+func (s *streamSubscriber) end(err error, end *StreamEnd) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err == nil {
+		s.err, s.ended = err, end
+	}
+	s.cond.Broadcast()
+}
+
+func (s *streamSubscriber) Read(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for s.buf.Len() == 0 && s.err == nil && !s.closed {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return 0, os.ErrClosed
+	}
+	if s.buf.Len() > 0 {
+		n, err := s.buf.Read(p)
+		s.offset += int64(n)
+		return n, err
+	}
+	return 0, s.err
+}
+
+func (s *streamSubscriber) streamOffset() (int64, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.offset, s.hasOffset
+}
+
+func (s *streamSubscriber) streamEnd() *StreamEnd {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.ended
+}
+
+func (s *streamSubscriber) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.buf.Reset()
+	s.cond.Broadcast()
+	s.lock.Unlock()
+
+	s.mux.unsubscribe(s)
+	return nil
+}
@@ -0,0 +1,247 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockStreamableEntry struct {
+	EntryBase
+	mock.Mock
+}
+
+func newMockStreamableEntry(id string) *mockStreamableEntry {
+	e := &mockStreamableEntry{EntryBase: NewEntry("foo")}
+	e.SetTestID(id)
+	return e
+}
+
+func (e *mockStreamableEntry) Schema() *EntrySchema {
+	return nil
+}
+
+func (e *mockStreamableEntry) Stream(ctx context.Context) (io.ReadCloser, error) {
+	args := e.Called(ctx)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+type StreamMuxTestSuite struct {
+	suite.Suite
+}
+
+func (suite *StreamMuxTestSuite) TearDownTest() {
+	_, err := limits.Set(streamBufferSize.Name(), 1024)
+	suite.NoError(err)
+}
+
+func (suite *StreamMuxTestSuite) readN(rdr io.Reader, n int) string {
+	buf := make([]byte, n)
+	_, err := io.ReadFull(rdr, buf)
+	suite.NoError(err)
+	return string(buf)
+}
+
+func (suite *StreamMuxTestSuite) TestSharesTheStream() {
+	upstreamRdr, upstreamWriter := io.Pipe()
+	e := newMockStreamableEntry("/foo")
+	e.On("Stream", mock.Anything).Return(upstreamRdr, nil).Once()
+
+	first, err := Stream(context.Background(), e)
+	if !suite.NoError(err) {
+		return
+	}
+	second, err := Stream(context.Background(), e)
+	if !suite.NoError(err) {
+		return
+	}
+	e.AssertNumberOfCalls(suite.T(), "Stream", 1)
+
+	go func() { _, _ = upstreamWriter.Write([]byte("hello")) }()
+	suite.Equal("hello", suite.readN(first, 5))
+	suite.Equal("hello", suite.readN(second, 5))
+
+	// Closing a subscriber doesn't affect the others
+	suite.NoError(first.Close())
+	_, err = first.Read(make([]byte, 1))
+	suite.Equal(os.ErrClosed, err)
+	go func() { _, _ = upstreamWriter.Write([]byte("world")) }()
+	suite.Equal("world", suite.readN(second, 5))
+
+	// Closing the last subscriber closes the upstream
+	suite.NoError(second.Close())
+	_, err = upstreamWriter.Write([]byte("closed"))
+	suite.Equal(io.ErrClosedPipe, err)
+
+	// A new stream's started for the next subscriber
+	upstreamRdr, upstreamWriter = io.Pipe()
+	e.On("Stream", mock.Anything).Return(upstreamRdr, nil).Once()
+	third, err := Stream(context.Background(), e)
+	if suite.NoError(err) {
+		e.AssertNumberOfCalls(suite.T(), "Stream", 2)
+		suite.NoError(upstreamWriter.Close())
+		_, err = ioutil.ReadAll(third)
+		suite.NoError(err)
+	}
+}
+
+func (suite *StreamMuxTestSuite) TestEndOfStreamEndsSubscribers() {
+	e := newMockStreamableEntry("/foo")
+	e.On("Stream", mock.Anything).Return(ioutil.NopCloser(strings.NewReader("hello")), nil).Once()
+
+	rdr, err := Stream(context.Background(), e)
+	if suite.NoError(err) {
+		content, err := ioutil.ReadAll(rdr)
+		suite.NoError(err)
+		suite.Equal("hello", string(content))
+	}
+}
+
+// errReader returns err once it's read
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func (suite *StreamMuxTestSuite) TestStreamEndIsReportedToSubscribers() {
+	e := newMockStreamableEntry("/foo")
+	end := NewStreamExit("the container exited", 3)
+	rdr := &EndingReader{
+		ReadCloser: ioutil.NopCloser(strings.NewReader("hello")),
+		End:        func() *StreamEnd { return end },
+	}
+	e.On("Stream", mock.Anything).Return(rdr, nil).Once()
+
+	sub, err := Stream(context.Background(), e)
+	if suite.NoError(err) {
+		// The subscriber's a plain io.Reader, so it ends with io.EOF
+		content, err := ioutil.ReadAll(sub)
+		suite.NoError(err)
+		suite.Equal("hello", string(content))
+		suite.Equal(end, StreamEndOf(sub, io.EOF))
+		suite.Equal("the container exited (exit status 3)", StreamEndOf(sub, io.EOF).Error())
+	}
+}
+
+func (suite *StreamMuxTestSuite) TestStreamEndOf() {
+	suite.Nil(StreamEndOf(nil, nil))
+	suite.Equal(NewStreamEnd("the stream was closed by its source"), StreamEndOf(strings.NewReader(""), io.EOF))
+	suite.Equal(NewStreamEnd(io.ErrUnexpectedEOF.Error()), StreamEndOf(strings.NewReader(""), io.ErrUnexpectedEOF))
+
+	end := NewStreamEnd("the log stream was closed")
+	rdr := &EndingReader{
+		ReadCloser: ioutil.NopCloser(strings.NewReader("")),
+		End:        func() *StreamEnd { return end },
+	}
+	_, err := rdr.Read(make([]byte, 1))
+	suite.Equal(io.EOF, err)
+	suite.Equal(end, StreamEndOf(rdr, err))
+}
+
+func (suite *StreamMuxTestSuite) TestStreamErrorsAreReturned() {
+	e := newMockStreamableEntry("/foo")
+	e.On("Stream", mock.Anything).Return(ioutil.NopCloser(nil), io.ErrUnexpectedEOF).Once()
+	_, err := Stream(context.Background(), e)
+	suite.Equal(io.ErrUnexpectedEOF, err)
+
+	// The failed stream isn't shared
+	e.On("Stream", mock.Anything).Return(ioutil.NopCloser(strings.NewReader("")), nil).Once()
+	_, err = Stream(context.Background(), e)
+	suite.NoError(err)
+}
+
+func (suite *StreamMuxTestSuite) TestSlowSubscribersAreDisconnected() {
+	_, err := limits.Set(streamBufferSize.Name(), 1)
+	suite.NoError(err)
+
+	upstreamRdr, upstreamWriter := io.Pipe()
+	e := newMockStreamableEntry("/foo")
+	e.On("Stream", mock.Anything).Return(upstreamRdr, nil).Once()
+	fast, err := Stream(context.Background(), e)
+	if !suite.NoError(err) {
+		return
+	}
+	slow, err := Stream(context.Background(), e)
+	if !suite.NoError(err) {
+		return
+	}
+	defer func() { suite.NoError(fast.Close()) }()
+
+	chunk := strings.Repeat("a", 600)
+	go func() { _, _ = upstreamWriter.Write([]byte(chunk)) }()
+	suite.Equal(chunk, suite.readN(fast, 600))
+	go func() { _, _ = upstreamWriter.Write([]byte(chunk)) }()
+	suite.Equal(chunk, suite.readN(fast, 600))
+
+	// The slow subscriber reads what it buffered before it fell behind
+	suite.Equal(chunk, suite.readN(slow, 600))
+	_, err = slow.Read(make([]byte, 1))
+	if suite.Error(err) {
+		suite.Regexp("more than 1 KB of its output was unread", err)
+	}
+	suite.NoError(slow.Close())
+}
+
+func (suite *StreamMuxTestSuite) TestEntriesWithoutIDsAreNotShared() {
+	e := newMockStreamableEntry("")
+	e.On("Stream", mock.Anything).Return(ioutil.NopCloser(strings.NewReader("")), nil).Twice()
+	_, err := Stream(context.Background(), e)
+	suite.NoError(err)
+	_, err = Stream(context.Background(), e)
+	suite.NoError(err)
+	e.AssertNumberOfCalls(suite.T(), "Stream", 2)
+}
+
+// offsetPipeReader is a stream whose output starts at offset
+type offsetPipeReader struct {
+	*io.PipeReader
+	offset int64
+}
+
+func (r offsetPipeReader) streamOffset() (int64, bool) {
+	return r.offset, true
+}
+
+func (suite *StreamMuxTestSuite) TestTracksTheSubscribersOffsets() {
+	upstreamRdr, upstreamWriter := io.Pipe()
+	e := newMockStreamableEntry("/offsets")
+	e.On("Stream", mock.Anything).Return(offsetPipeReader{upstreamRdr, 100}, nil).Once()
+
+	first, err := Stream(context.Background(), e)
+	if !suite.NoError(err) {
+		return
+	}
+	defer first.Close()
+	offset, ok := StreamOffsetOf(first)
+	suite.True(ok)
+	suite.Equal(int64(100), offset)
+
+	go func() { _, _ = upstreamWriter.Write([]byte("hello")) }()
+	suite.Equal("hello", suite.readN(first, 5))
+	offset, _ = StreamOffsetOf(first)
+	suite.Equal(int64(105), offset)
+
+	// Later subscribers start at the stream's current offset
+	second, err := Stream(context.Background(), e)
+	if !suite.NoError(err) {
+		return
+	}
+	defer second.Close()
+	offset, ok = StreamOffsetOf(second)
+	suite.True(ok)
+	suite.Equal(int64(105), offset)
+}
+
+func TestStreamMux(t *testing.T) {
+	suite.Run(t, new(StreamMuxTestSuite))
+}
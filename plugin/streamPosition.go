@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// StreamPosition is the position in a stream's history that a resumed stream
+// starts at (see ResumableStreamable). The stream starts at the byte Offset of
+// its output if HasOffset is true. Otherwise, it starts with the output since
+// Since.
+type StreamPosition struct {
+	Since     time.Time
+	Offset    int64
+	HasOffset bool
+}
+
+func (p StreamPosition) String() string {
+	if p.HasOffset {
+		return fmt.Sprintf("offset %v", p.Offset)
+	}
+	return fmt.Sprintf("output since %v", p.Since.Format(time.RFC3339))
+}
+
+// streamOffsetter is a stream that knows the offset (in its history) of the
+// next byte that it'll read
+type streamOffsetter interface {
+	streamOffset() (int64, bool)
+}
+
+// StreamOffsetOf returns the offset in the stream's history of the next byte
+// that the stream will read. The returned bool is false if the offset's
+// unknown, e.g. because the stream isn't resumable.
+func StreamOffsetOf(stream io.Reader) (int64, bool) {
+	if o, ok := stream.(streamOffsetter); ok {
+		return o.streamOffset()
+	}
+	return 0, false
+}
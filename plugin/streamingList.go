@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+
+	"github.com/puppetlabs/wash/datastore"
+)
+
+// StreamingList is like List, except that it calls onEntry with each of p's
+// children as soon as it's listed instead of once they're all listed. That
+// way, the children of parents with lots of them (like S3 buckets) can be
+// shown as they arrive. The listing's still cached once it's done, so
+// StreamingList calls onEntry with the cached children (in cname order) if p's
+// listing was already cached. Only StreamingListers are actually streamed;
+// the other parents' children are passed to onEntry once they're all listed.
+//
+// StreamingList returns the children like List does. If onEntry errors, then
+// the listing stops and StreamingList returns onEntry's error (along with the
+// children if they were cached). onEntry's error isn't cached as p's listing.
+func StreamingList(ctx context.Context, p Parent, onEntry func(Entry) error) (map[string]Entry, error) {
+	submitMethodInvocation(ctx, p, "List")
+	if _, ok := p.(*partitionEntry); ok {
+		entries, err := CachedList(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		return entries, emitEntries(entries, onEntry)
+	}
+
+	// The op may be invoked again later to refresh the cached listing in the
+	// background, so only the first invocation streams to onEntry
+	var streamed int32
+	cachedEntries, err := cachedDefaultOp(ctx, ListOp, p, func(ctx context.Context) (interface{}, error) {
+		if atomic.CompareAndSwapInt32(&streamed, 0, 1) {
+			var onEntryErr error
+			entries, err := listChildren(ctx, p, func(entry Entry) error {
+				onEntryErr = onEntry(entry)
+				return onEntryErr
+			})
+			if onEntryErr != nil {
+				// The listing didn't fail, the consumer did
+				return nil, datastore.DoNotCache(onEntryErr)
+			}
+			return entries, err
+		}
+		return listChildren(ctx, p, nil)
+	})
+	handleChildChanges(ctx, p.id())
+	if err != nil {
+		return nil, err
+	}
+	entries := cachedEntries.(map[string]Entry)
+	if atomic.CompareAndSwapInt32(&streamed, 0, 1) {
+		// The listing was cached, so onEntry hasn't seen the children yet
+		return entries, emitEntries(entries, onEntry)
+	}
+	return entries, nil
+}
+
+// emitEntries calls onEntry with each of the entries, in cname order
+func emitEntries(entries map[string]Entry, onEntry func(Entry) error) error {
+	cnames := make([]string, 0, len(entries))
+	for cname := range entries {
+		cnames = append(cnames, cname)
+	}
+	sort.Strings(cnames)
+	for _, cname := range cnames {
+		if err := onEntry(entries[cname]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/features"
+	"github.com/stretchr/testify/suite"
+)
+
+// streamingListTestsParent streams its children one at a time. Each child's
+// only listed once the previous one's been seen by onEntry, so the test
+// would deadlock if the children weren't streamed.
+type streamingListTestsParent struct {
+	mockParent
+	streaming bool
+	seen      chan string
+}
+
+func (p *streamingListTestsParent) ListStreaming(ctx context.Context, onEntry func(Entry) error) error {
+	for _, entry := range p.entries {
+		if err := onEntry(entry); err != nil {
+			return err
+		}
+		if seen := <-p.seen; seen != entry.name() {
+			return fmt.Errorf("expected onEntry to see %v, not %v", entry.name(), seen)
+		}
+	}
+	return nil
+}
+
+func (p *streamingListTestsParent) SupportsStreamingList() bool {
+	return p.streaming
+}
+
+type StreamingListTestSuite struct {
+	suite.Suite
+}
+
+func (suite *StreamingListTestSuite) SetupTest() {
+	SetTestCache(datastore.NewMemCache())
+	_, err := features.Set(streamingListFlag.Name(), "", true)
+	suite.NoError(err)
+}
+
+func (suite *StreamingListTestSuite) TearDownTest() {
+	UnsetTestCache()
+	_, err := features.Set(streamingListFlag.Name(), "", false)
+	suite.NoError(err)
+}
+
+func (suite *StreamingListTestSuite) newParent(streaming bool) *streamingListTestsParent {
+	p := &streamingListTestsParent{
+		mockParent: mockParent{EntryBase: NewEntry("parent"), entries: []Entry{newMockEntry("b"), newMockEntry("a")}},
+		streaming:  streaming,
+		seen:       make(chan string, 1),
+	}
+	p.SetTestID("/parent")
+	return p
+}
+
+func (suite *StreamingListTestSuite) TestStreamsTheChildren() {
+	p := suite.newParent(true)
+	var ids []string
+	entries, err := StreamingList(context.Background(), p, func(e Entry) error {
+		ids = append(ids, e.id())
+		p.seen <- e.name()
+		return nil
+	})
+	if suite.NoError(err) {
+		suite.Len(entries, 2)
+		suite.Equal([]string{"/parent/b", "/parent/a"}, ids)
+	}
+
+	// The listing's cached, so the children are passed along from the cache
+	// in cname order
+	ids = nil
+	_, err = StreamingList(context.Background(), p, func(e Entry) error {
+		ids = append(ids, e.id())
+		return nil
+	})
+	if suite.NoError(err) {
+		suite.Equal([]string{"/parent/a", "/parent/b"}, ids)
+	}
+	entries, err = CachedList(context.Background(), p)
+	if suite.NoError(err) {
+		suite.Len(entries, 2)
+	}
+}
+
+func (suite *StreamingListTestSuite) TestListsParentsThatDontSupportStreaming() {
+	p := suite.newParent(false)
+	var names []string
+	_, err := StreamingList(context.Background(), p, func(e Entry) error {
+		names = append(names, e.name())
+		return nil
+	})
+	if suite.NoError(err) {
+		suite.Equal([]string{"b", "a"}, names)
+	}
+}
+
+func (suite *StreamingListTestSuite) TestListsParentsIfTheFlagIsDisabled() {
+	p := suite.newParent(true)
+	suite.True(SupportsStreamingList(p))
+	_, err := features.Set(streamingListFlag.Name(), "", false)
+	suite.NoError(err)
+	suite.False(SupportsStreamingList(p))
+
+	var names []string
+	_, err = StreamingList(context.Background(), p, func(e Entry) error {
+		names = append(names, e.name())
+		return nil
+	})
+	if suite.NoError(err) {
+		suite.Equal([]string{"b", "a"}, names)
+	}
+}
+
+func (suite *StreamingListTestSuite) TestStopsIfOnEntryErrors() {
+	p := suite.newParent(true)
+	_, err := StreamingList(context.Background(), p, func(e Entry) error {
+		return fmt.Errorf("stop")
+	})
+	suite.EqualError(err, "stop")
+
+	// onEntry's error isn't cached as p's listing
+	entries, err := List(context.Background(), p)
+	if suite.NoError(err) {
+		suite.Len(entries, 2)
+	}
+}
+
+func (suite *StreamingListTestSuite) TestReturnsDuplicateCNameErrors() {
+	p := suite.newParent(true)
+	p.entries = []Entry{newMockEntry("a"), newMockEntry("a")}
+	_, err := StreamingList(context.Background(), p, func(e Entry) error {
+		p.seen <- e.name()
+		return nil
+	})
+	suite.IsType(DuplicateCNameErr{}, err)
+}
+
+func TestStreamingList(t *testing.T) {
+	suite.Run(t, new(StreamingListTestSuite))
+}
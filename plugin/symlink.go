@@ -0,0 +1,12 @@
+package plugin
+
+// SymlinkTargetOf returns the entry's symlink target. The returned bool is
+// false if the entry isn't a symlink.
+func SymlinkTargetOf(e Entry) (string, bool) {
+	s, ok := e.(Symlink)
+	if !ok {
+		return "", false
+	}
+	target := s.SymlinkTarget()
+	return target, target != ""
+}
@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+)
+
+// telemetryOpName is the name that telemetry is cached under
+const telemetryOpName = "Telemetry"
+
+var telemetryTTL = limits.Register(
+	"plugins.telemetry_ttl_ms",
+	"How many milliseconds an entry's telemetry (see the telemetry action) is cached for. It's much shorter than the other TTLs since telemetry is live data. 0 disables caching it.",
+	5000,
+	nil,
+)
+
+// TelemetryTTL returns how long telemetry is cached for. It's negative if
+// caching telemetry is disabled.
+func TelemetryTTL() time.Duration {
+	ttl := time.Duration(telemetryTTL.Value()) * time.Millisecond
+	if ttl <= 0 {
+		return -1
+	}
+	return ttl
+}
+
+// CachedTelemetry returns the entry's telemetry. It's cached separately from
+// (and for much less time than) the entry's metadata.
+func CachedTelemetry(ctx context.Context, r TelemetryReporter) (JSONObject, error) {
+	telemetry, err := cachedOp(ctx, telemetryOpName, r, TelemetryTTL(), func() (interface{}, error) {
+		defer trackLatency(ctx, r, TelemetryAction().Name, time.Now())
+		return r.Telemetry(ctx)
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return telemetry.(JSONObject), nil
+}
@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/limits"
+	"github.com/stretchr/testify/suite"
+)
+
+type telemetryTestsEntry struct {
+	EntryBase
+	reports int
+}
+
+func (e *telemetryTestsEntry) Telemetry(ctx context.Context) (JSONObject, error) {
+	e.reports++
+	return JSONObject{"reports": e.reports}, nil
+}
+
+func (e *telemetryTestsEntry) Schema() *EntrySchema {
+	return nil
+}
+
+type TelemetryTestSuite struct {
+	suite.Suite
+}
+
+func (suite *TelemetryTestSuite) SetupTest() {
+	SetTestCache(datastore.NewMemCache())
+}
+
+func (suite *TelemetryTestSuite) TearDownTest() {
+	UnsetTestCache()
+	_, err := limits.Set("plugins.telemetry_ttl_ms", 5000)
+	suite.NoError(err)
+}
+
+func (suite *TelemetryTestSuite) newEntry() *telemetryTestsEntry {
+	e := &telemetryTestsEntry{EntryBase: NewEntry("vm")}
+	e.SetTestID("/vm")
+	return e
+}
+
+func (suite *TelemetryTestSuite) TestTelemetryAction() {
+	suite.True(TelemetryAction().IsSupportedOn(suite.newEntry()))
+	suite.False(TelemetryAction().IsSupportedOn(newCacheTestsMockEntry("foo")))
+}
+
+func (suite *TelemetryTestSuite) TestCachedTelemetry() {
+	e := suite.newEntry()
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		telemetry, err := CachedTelemetry(ctx, e)
+		if suite.NoError(err) {
+			suite.Equal(JSONObject{"reports": 1}, telemetry)
+		}
+	}
+
+	// The telemetry's cached separately from the entry's metadata
+	_, err := CachedMetadata(ctx, e)
+	suite.NoError(err)
+	suite.Equal(1, e.reports)
+}
+
+func (suite *TelemetryTestSuite) TestCachedTelemetry_CachingDisabled() {
+	_, err := limits.Set("plugins.telemetry_ttl_ms", 0)
+	suite.NoError(err)
+	e := suite.newEntry()
+	for i := 1; i <= 2; i++ {
+		telemetry, err := CachedTelemetry(context.Background(), e)
+		if suite.NoError(err) {
+			suite.Equal(JSONObject{"reports": i}, telemetry)
+		}
+	}
+}
+
+func TestTelemetry(t *testing.T) {
+	suite.Run(t, new(TelemetryTestSuite))
+}
@@ -0,0 +1,7 @@
+#!/bin/sh
+# Prints the plugin config that it's passed after init
+case "$1" in
+  init) echo '{"methods":["list"]}' ;;
+  list) echo '[{"name":"foo","methods":["read"]}]' ;;
+  read) printf '%s' "$WASH_PLUGIN_CONFIG" ;;
+esac
@@ -0,0 +1,15 @@
+#!/bin/sh
+# Resolves API_TOKEN for the plugin named in the request
+request=$(cat)
+case "$request" in
+*'"plugin":"broken"'*)
+  echo "the vault is sealed" >&2
+  exit 1
+  ;;
+*'"plugin":"partial"'*)
+  echo '{}'
+  ;;
+*)
+  echo '{"API_TOKEN":"hunter2"}'
+  ;;
+esac
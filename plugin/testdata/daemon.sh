@@ -0,0 +1,41 @@
+#!/bin/sh
+# daemon.sh is a daemon-mode plugin script for the externalPluginDaemon tests.
+# Its read results include the number of requests it's served so far, which
+# shows whether the same process served them.
+if [ "$1" != "init" ] || [ "$2" != "--daemon" ]; then
+  echo "daemon.sh must be started in daemon mode" >&2
+  exit 1
+fi
+
+count=0
+while read -r request; do
+  count=$((count + 1))
+  id=$(echo "$request" | sed 's/.*"id":\([0-9]*\).*/\1/')
+  method=$(echo "$request" | sed 's/.*"method":"\([a-z]*\)".*/\1/')
+  path=$(echo "$request" | sed -n 's/.*"path":"\([^"]*\)".*/\1/p')
+  case "$method:$path" in
+  init:)
+    # The token shows whether the (replayed) init request included the
+    # plugin's secrets
+    token=$(echo "$request" | sed -n 's/.*"API_TOKEN":"\([^"]*\)".*/\1/p')
+    result='{"methods":["list"]}'
+    ;;
+  list:/daemon)
+    result='[{"name":"file","methods":["read","metadata"]},{"name":"crash","methods":["read"]},{"name":"token","methods":["read"]}]'
+    ;;
+  read:/daemon/file)
+    result="\"request $count\""
+    ;;
+  read:/daemon/crash)
+    exit 1
+    ;;
+  read:/daemon/token)
+    result="\"$token\""
+    ;;
+  *)
+    echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"error\":{\"code\":1,\"message\":\"$method is not implemented on $path\"}}"
+    continue
+    ;;
+  esac
+  echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"result\":$result}"
+done
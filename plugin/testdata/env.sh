@@ -0,0 +1,7 @@
+#!/bin/sh
+# Prints the environment that it's invoked with
+case "$1" in
+  init) echo '{"methods":["list"]}' ;;
+  list) echo '[{"name":"foo","methods":["read"]}]' ;;
+  read) env ;;
+esac
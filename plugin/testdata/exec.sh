@@ -0,0 +1,8 @@
+#!/bin/sh
+# Invoked as exec.sh exec <path> <state> <opts> <cmd> <args...>. It prints the
+# opts and the command, then echoes its stdin.
+echo "$4"
+shift 4
+echo "$@"
+cat
+exit 3
@@ -0,0 +1,25 @@
+#!/bin/sh
+# Invoked as execEvents.sh exec <path> <state> <opts> <cmd> <args...>. It
+# prints the exec events of the given command.
+case "$5" in
+  ok)
+    cat <<'END'
+{"type":"stdout","data":"hello\n"}
+{"type":"stderr","data":"b29wcw==","encoding":"base64"}
+{"type":"stdout","data":"world\n"}
+{"type":"exit","exit_code":2}
+END
+    ;;
+  error)
+    echo '{"type":"stdout","data":"partial"}'
+    echo '{"type":"error","error":{"kind":"unavailable","message":"the API is down","retryable":true}}'
+    ;;
+  malformed)
+    echo 'some diagnostics' >&2
+    echo 'not an event'
+    ;;
+  noexit)
+    echo '{"type":"stdout","data":"hello"}'
+    exit 1
+    ;;
+esac
@@ -0,0 +1,24 @@
+#!/bin/sh
+# Denies rm, requires a justification for reboot, and allows everything else
+request=$(cat)
+case "$request" in
+*'"cmd":"rm"'*)
+  echo '{"decision":"deny","reason":"rm is not allowed"}'
+  ;;
+*'"cmd":"reboot"'*'"justification"'*)
+  echo '{"decision":"allow"}'
+  ;;
+*'"cmd":"reboot"'*)
+  echo '{"decision":"justify","reason":"reboots need a change ticket"}'
+  ;;
+*'"cmd":"fail"'*)
+  echo "boom" >&2
+  exit 1
+  ;;
+*'"cmd":"garbage"'*)
+  echo 'not json'
+  ;;
+*)
+  echo '{"decision":"allow"}'
+  ;;
+esac
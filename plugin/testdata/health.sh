@@ -0,0 +1,25 @@
+#!/bin/sh
+# A plugin root whose backend is up while the file at $HEALTH_TESTS_FILE
+# exists. The root implements health unless its config is {"health":false}, in
+# which case its health isn't checked.
+case "$1" in
+  init)
+    if [ "$2" = '{"health":false}' ]; then
+      echo '{"methods":["list"]}'
+    else
+      echo '{"methods":["list","health"]}'
+    fi
+    ;;
+  list|health)
+    if [ ! -e "${HEALTH_TESTS_FILE}" ]; then
+      echo '{"kind":"unavailable","message":"the backend is down"}' >&2
+      exit 1
+    fi
+    if [ "$1" = list ]; then
+      echo '[{"name":"foo","methods":["read"]}]'
+    fi
+    ;;
+  read)
+    echo foo
+    ;;
+esac
@@ -0,0 +1,13 @@
+#!/bin/sh
+# Lists its children a page at a time. /loop's pages always have the same next
+# page.
+if [ "$2" = "/loop" ]; then
+  echo '{"entries":[],"next_page":"again"}'
+  exit 0
+fi
+case "$4" in
+  "") echo '{"entries":[{"name":"a","methods":["read"]}],"next_page":"p2"}' ;;
+  p2) echo '{"entries":[{"name":"b","methods":["read"]},{"name":"c","methods":["read"]}],"next_page":"p3"}' ;;
+  p3) echo '[{"name":"d","methods":["read"]}]' ;;
+  *) echo "unknown page $4" >&2; exit 1 ;;
+esac
@@ -0,0 +1,17 @@
+#!/bin/sh
+# Streams its history, which is "line1\nline2\nline3\n", from the requested
+# position. Its latest output is the last line.
+case "$4" in
+  --offset)
+    echo "200 $5"
+    printf 'line1\nline2\nline3\n' | tail -c +$(($5 + 1))
+    ;;
+  --since)
+    echo "200"
+    echo "since $5"
+    ;;
+  *)
+    echo "200 12"
+    echo "line3"
+    ;;
+esac
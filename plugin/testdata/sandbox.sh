@@ -0,0 +1,10 @@
+#!/bin/sh
+# Prints the resource limits that it's invoked with, and its network devices
+case "$1" in
+  init) echo '{"methods":["list"]}' ;;
+  read)
+    ulimit -n
+    ulimit -t
+    tail -n +3 /proc/net/dev | cut -d: -f1 | tr -d ' '
+    ;;
+esac
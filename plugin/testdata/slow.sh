@@ -0,0 +1,3 @@
+#!/bin/sh
+# Takes too long to respond to any method
+sleep 5
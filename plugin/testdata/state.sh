@@ -0,0 +1,23 @@
+#!/bin/sh
+# Lists an entry with a small state and an entry whose state is spilled to
+# disk. read prints how the entry's state was passed in.
+case "$1" in
+  init)
+    case "$2" in
+      *nospillover*) echo '{"methods":["list"]}' ;;
+      *) echo '{"methods":["list"],"state_spillover":true}' ;;
+    esac
+    ;;
+  list)
+    big=$(head -c 70000 /dev/zero | tr '\0' a)
+    echo "[{\"name\":\"small\",\"methods\":[\"read\"],\"state\":{\"token\": \"abc\", \"page\": 2.50}},{\"name\":\"big\",\"methods\":[\"read\"],\"state\":{\"data\":\"$big\"}}]"
+    ;;
+  read)
+    if [ -n "$WASH_STATE_FILE" ]; then
+      printf 'file:%s:' "$3"
+      cat "$WASH_STATE_FILE"
+    else
+      printf 'arg:%s' "$3"
+    fi
+    ;;
+esac
@@ -0,0 +1,9 @@
+#!/bin/sh
+# Streams its children, taking a while to list the last one
+echo '{"name":"first","methods":["read"]}'
+echo
+echo '{"name":"second","methods":["read"]}'
+if [ "$2" = "/slow" ]; then
+  sleep 10
+fi
+echo '{"name":"third","methods":["read"]}'
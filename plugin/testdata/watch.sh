@@ -0,0 +1,18 @@
+#!/bin/sh
+# A plugin root that watches for changes. watch invalidates foo's list, an
+# entry outside of the plugin (which is ignored) and bar, then waits to be
+# terminated.
+case "$1" in
+  init)
+    echo '{"methods":["list","watch"]}'
+    ;;
+  list)
+    echo '[{"name":"foo","methods":["list"]},{"name":"bar","methods":["list"]}]'
+    ;;
+  watch)
+    echo "{\"path\":\"$2/foo\",\"action\":\"list\"}"
+    echo '{"path":"/other/foo"}'
+    echo "{\"path\":\"$2/bar\"}"
+    exec sleep 60
+    ;;
+esac
@@ -17,7 +17,7 @@ Anything that does not implement Parent will be displayed as a file.
 
 The Readable interface gives a file its contents when read via the filesystem.
 
-All of the above, as well as other types - Execable, Stream - provide additional functionality
+All of the above, as well as other types - Execable, Stream, Writable, Deletable, Signalable - provide additional functionality
 via the HTTP API.
 */
 package plugin
@@ -52,6 +52,7 @@ type Entry interface {
 	wrappedTypes() map[interface{}]*JSONSchema
 	setWrappedTypes(map[interface{}]*JSONSchema)
 	isPrefetched() bool
+	deprecatedActions() map[string]ActionDeprecation
 }
 
 /*
@@ -85,6 +86,18 @@ type Parent interface {
 	List(context.Context) ([]Entry, error)
 }
 
+// StreamingLister is a Parent that can list its children incrementally, e.g.
+// by paging through an S3 bucket's keys. ListStreaming calls onEntry with each
+// child as soon as it's listed, and stops listing if onEntry errors. Use it
+// for parents with so many children that listing them all takes a while, so
+// that API clients see the first children immediately. Parents whose
+// SupportsStreamingList returns false are listed with List.
+type StreamingLister interface {
+	Parent
+	ListStreaming(ctx context.Context, onEntry func(Entry) error) error
+	SupportsStreamingList() bool
+}
+
 // SchemaMap represents a map of <type> => <JSON schema>.
 type SchemaMap = map[interface{}]*JSONSchema
 
@@ -136,6 +149,15 @@ type ExecOptions struct {
 
 	// Elevate execution to run as a privileged user if not already running as a privileged user.
 	Elevate bool `json:"elevate"`
+
+	// Env are environment variables to set for the command, in addition to the ones that are set
+	// on the remote side. Only entries that honor the EnvExecOption support it (see
+	// ExecOptionHonorer).
+	Env map[string]string `json:"env"`
+
+	// Cwd is the working directory to run the command in. Only entries that honor the
+	// CwdExecOption support it (see ExecOptionHonorer).
+	Cwd string `json:"cwd"`
 }
 
 // ExecPacketType identifies the packet type.
@@ -182,12 +204,117 @@ type Streamable interface {
 	Stream(context.Context) (io.ReadCloser, error)
 }
 
+// ResumableStreamable is a Streamable whose stream can start at a position in
+// its history instead of at its latest output, e.g. so that a client that lost
+// its connection can resume the stream without missing (or replaying) output.
+// Streamables whose SupportsResumableStream returns false can't be resumed.
+type ResumableStreamable interface {
+	Streamable
+	StreamFrom(ctx context.Context, pos StreamPosition) (io.ReadCloser, error)
+	SupportsResumableStream() bool
+}
+
+// Writable is an entry whose content can be replaced. Write replaces the
+// entry's entire content with data.
+type Writable interface {
+	Entry
+	Write(ctx context.Context, data []byte) error
+}
+
+// Deletable is an entry that can be deleted, e.g. an EC2 instance that can be
+// terminated or a Docker container that can be removed. Delete should return
+// once the deletion's been requested; it doesn't have to wait for the entry
+// to be gone.
+type Deletable interface {
+	Entry
+	Delete(ctx context.Context) error
+}
+
+// ChildCreator is a Parent that can create children, e.g. an S3 bucket that
+// can create a "folder" or a Kubernetes context that can create a namespace.
+// CreateChild creates the child with the given cname and returns it. The child
+// must be a Parent if isParent is true (e.g. because it's created via mkdir).
+// The created child is deleted via its Delete method (see Deletable).
+type ChildCreator interface {
+	Parent
+	CreateChild(ctx context.Context, name string, isParent bool) (Entry, error)
+}
+
+// Renamable is an entry that can be renamed, e.g. an S3 object or a Docker
+// container. Rename renames the entry to the given cname. Entries are only
+// renamed within their parent, so moving an entry to another parent isn't
+// supported.
+type Renamable interface {
+	Entry
+	Rename(ctx context.Context, newName string) error
+}
+
+// Signalable is an entry that can be sent signals, e.g. a container or VM
+// that can be started, stopped, restarted or killed. Signal should return an
+// error if the entry doesn't support the signal. Like Delete, it should return
+// once the signal's been sent.
+type Signalable interface {
+	Entry
+	Signal(ctx context.Context, signal string) error
+}
+
+// Runnable is an entry with custom, plugin-defined actions, e.g. a VM that can
+// be snapshotted or rebooted. CustomActions returns the names of the entry's
+// actions. Run performs the named action with the given args, and returns its
+// output. It should return an error if the entry doesn't have the action.
+type Runnable interface {
+	Entry
+	CustomActions() []string
+	Run(ctx context.Context, action string, args []string) ([]byte, error)
+}
+
+// AttrRefresher is an entry whose attributes can be refreshed without
+// re-listing its parent, e.g. a file whose size and mtime change more often
+// than its siblings do. RefreshAttributes returns the entry's current
+// attributes. See CachedAttributes.
+type AttrRefresher interface {
+	Entry
+	RefreshAttributes(ctx context.Context) (EntryAttributes, error)
+}
+
+// TelemetryReporter is an entry with live telemetry, e.g. a VM's CPU usage or
+// a load balancer's request rate. Telemetry returns the telemetry's current
+// values, keyed by their names. It should be cheap since it's invoked far more
+// often than Metadata; its result is only cached for the
+// plugins.telemetry_ttl_ms limit. See CachedTelemetry.
+type TelemetryReporter interface {
+	Entry
+	Telemetry(ctx context.Context) (JSONObject, error)
+}
+
+// Symlink is an entry that's an alias of another entry, e.g. a "latest" image
+// tag that points at a specific tag. It's rendered as a symlink in the Wash
+// filesystem. SymlinkTarget returns the symlink's target, which is used
+// verbatim like a real symlink's target. Thus, relative targets (e.g.
+// "v1.2.3" or "../images/foo") are resolved relative to the symlink's parent.
+// An empty target means that the entry isn't a symlink. See SymlinkTargetOf.
+type Symlink interface {
+	Entry
+	SymlinkTarget() string
+}
+
 // SizedReader returns a ReaderAt that can report its Size.
 type SizedReader interface {
 	io.ReaderAt
 	Size() int64
 }
 
+// PartialReader is a SizedReader whose ReadAt only fetches the requested range
+// of content from the plugin's API, e.g. via a ranged GET request. The Wash
+// filesystem issues read-ahead requests against partial readers when it detects
+// sequential reads (like those made by `cp` or `grep`) so that large files can
+// be read with fewer, larger requests. Readers whose content is already in memory
+// should not implement PartialReader.
+type PartialReader interface {
+	SizedReader
+	SupportsPartialReads() bool
+}
+
 // Readable is an entry that has a fixed amount of content we can read.
 type Readable interface {
 	Entry
@@ -0,0 +1,38 @@
+package wash
+
+import (
+	"context"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+type cacheDir struct {
+	plugin.EntryBase
+	files []plugin.Entry
+}
+
+func newCacheDir() *cacheDir {
+	cd := &cacheDir{
+		EntryBase: plugin.NewEntry("cache"),
+	}
+	cd.DisableDefaultCaching()
+	cd.files = []plugin.Entry{
+		newPopularityFile(),
+	}
+	return cd
+}
+
+func (cd *cacheDir) Schema() *plugin.EntrySchema {
+	return plugin.NewEntrySchema(cd, "cache").IsSingleton()
+}
+
+func (cd *cacheDir) ChildSchemas() []*plugin.EntrySchema {
+	return []*plugin.EntrySchema{
+		(&popularityFile{}).Schema(),
+	}
+}
+
+// List lists the cache's stats
+func (cd *cacheDir) List(ctx context.Context) ([]plugin.Entry, error) {
+	return cd.files, nil
+}
@@ -0,0 +1,38 @@
+package wash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// operationFile contains an operation's status and progress. It's named by
+// the operation's ID.
+type operationFile struct {
+	plugin.EntryBase
+	op *plugin.Operation
+}
+
+func newOperationFile(op *plugin.Operation) *operationFile {
+	of := &operationFile{
+		EntryBase: plugin.NewEntry(op.ID()),
+		op:        op,
+	}
+	of.DisableDefaultCaching()
+	of.Attributes().SetCrtime(op.Info().Started)
+	return of
+}
+
+func (of *operationFile) Schema() *plugin.EntrySchema {
+	return plugin.NewEntrySchema(of, "operation")
+}
+
+func (of *operationFile) Open(ctx context.Context) (plugin.SizedReader, error) {
+	content, err := json.MarshalIndent(of.op.Info(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(append(content, '\n')), nil
+}
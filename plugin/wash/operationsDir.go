@@ -0,0 +1,41 @@
+package wash
+
+import (
+	"context"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// operationsDir contains a file for each of the running and recently finished
+// asynchronous operations
+type operationsDir struct {
+	plugin.EntryBase
+}
+
+func newOperationsDir() *operationsDir {
+	od := &operationsDir{
+		EntryBase: plugin.NewEntry("operations"),
+	}
+	od.DisableDefaultCaching()
+	return od
+}
+
+func (od *operationsDir) Schema() *plugin.EntrySchema {
+	return plugin.NewEntrySchema(od, "operations").IsSingleton()
+}
+
+func (od *operationsDir) ChildSchemas() []*plugin.EntrySchema {
+	return []*plugin.EntrySchema{
+		(&operationFile{}).Schema(),
+	}
+}
+
+// List lists the operations
+func (od *operationsDir) List(ctx context.Context) ([]plugin.Entry, error) {
+	ops := plugin.Operations()
+	entries := make([]plugin.Entry, 0, len(ops))
+	for _, op := range ops {
+		entries = append(entries, newOperationFile(op))
+	}
+	return entries, nil
+}
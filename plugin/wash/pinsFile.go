@@ -0,0 +1,36 @@
+package wash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// pinsFile describes the pinned subtrees (see `wash pin`), sorted by path.
+// Pinned entries are kept warm by background refresh, and they're never
+// evicted from the cache.
+type pinsFile struct {
+	plugin.EntryBase
+}
+
+func newPinsFile() *pinsFile {
+	pf := &pinsFile{
+		EntryBase: plugin.NewEntry("pins"),
+	}
+	pf.DisableDefaultCaching()
+	return pf
+}
+
+func (pf *pinsFile) Schema() *plugin.EntrySchema {
+	return plugin.NewEntrySchema(pf, "pins").IsSingleton()
+}
+
+func (pf *pinsFile) Open(ctx context.Context) (plugin.SizedReader, error) {
+	content, err := json.MarshalIndent(plugin.Pins(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(append(content, '\n')), nil
+}
@@ -0,0 +1,40 @@
+package wash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// popularityFile contains the popularity of each entry that's tracked by the
+// cache, sorted from most to least popular. Popular (hot) entries are kept warm
+// while unpopular (cold) entries are evicted.
+type popularityFile struct {
+	plugin.EntryBase
+}
+
+func newPopularityFile() *popularityFile {
+	pf := &popularityFile{
+		EntryBase: plugin.NewEntry("popularity"),
+	}
+	pf.DisableDefaultCaching()
+	return pf
+}
+
+func (pf *popularityFile) Schema() *plugin.EntrySchema {
+	return plugin.NewEntrySchema(pf, "popularity").IsSingleton()
+}
+
+func (pf *popularityFile) Open(ctx context.Context) (plugin.SizedReader, error) {
+	stats := plugin.PopularityStats()
+	if stats == nil {
+		stats = []plugin.PopularityStat{}
+	}
+	content, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(append(content, '\n')), nil
+}
@@ -0,0 +1,54 @@
+// Package wash presents a filesystem hierarchy for Wash's own state, e.g. its
+// cache, its asynchronous operations, its pins, and which plugins were loaded.
+//
+// Unlike the other core plugins, it is always loaded.
+package wash
+
+import (
+	"context"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// WASH ROOT
+
+// Root of the Wash plugin
+type Root struct {
+	plugin.EntryBase
+	resources []plugin.Entry
+}
+
+// Init for root
+func (r *Root) Init(map[string]interface{}) error {
+	r.EntryBase = plugin.NewEntry("wash")
+	r.DisableDefaultCaching()
+	r.resources = []plugin.Entry{
+		newCacheDir(),
+		newOperationsDir(),
+		newPinsFile(),
+		newSlowCallsFile(),
+		newStatusFile(),
+	}
+	return nil
+}
+
+// Schema returns the root's schema
+func (r *Root) Schema() *plugin.EntrySchema {
+	return plugin.NewEntrySchema(r, "wash").IsSingleton()
+}
+
+// ChildSchemas returns the root's child schema
+func (r *Root) ChildSchemas() []*plugin.EntrySchema {
+	return []*plugin.EntrySchema{
+		(&cacheDir{}).Schema(),
+		(&operationsDir{}).Schema(),
+		(&pinsFile{}).Schema(),
+		(&slowCallsFile{}).Schema(),
+		(&statusFile{}).Schema(),
+	}
+}
+
+// List lists the parts of Wash's state that the Wash plugin exposes.
+func (r *Root) List(ctx context.Context) ([]plugin.Entry, error) {
+	return r.resources, nil
+}
@@ -0,0 +1,36 @@
+package wash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// slowCallsFile counts the calls to each plugin's actions that exceeded their
+// latency threshold (see the plugins.slow_call_ms limit), sorted from most to
+// least slow calls. It answers questions like "which backend made my ls slow".
+type slowCallsFile struct {
+	plugin.EntryBase
+}
+
+func newSlowCallsFile() *slowCallsFile {
+	sf := &slowCallsFile{
+		EntryBase: plugin.NewEntry("slow_calls"),
+	}
+	sf.DisableDefaultCaching()
+	return sf
+}
+
+func (sf *slowCallsFile) Schema() *plugin.EntrySchema {
+	return plugin.NewEntrySchema(sf, "slow_calls").IsSingleton()
+}
+
+func (sf *slowCallsFile) Open(ctx context.Context) (plugin.SizedReader, error) {
+	content, err := json.MarshalIndent(plugin.SlowCallStats(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(append(content, '\n')), nil
+}
@@ -0,0 +1,36 @@
+package wash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/puppetlabs/wash/plugin"
+)
+
+// statusFile describes whether each plugin was loaded, sorted by plugin name.
+// Plugins that were skipped or that failed to load include the reason, e.g.
+// "/var/run/docker.sock doesn't exist".
+type statusFile struct {
+	plugin.EntryBase
+}
+
+func newStatusFile() *statusFile {
+	sf := &statusFile{
+		EntryBase: plugin.NewEntry("status"),
+	}
+	sf.DisableDefaultCaching()
+	return sf
+}
+
+func (sf *statusFile) Schema() *plugin.EntrySchema {
+	return plugin.NewEntrySchema(sf, "status").IsSingleton()
+}
+
+func (sf *statusFile) Open(ctx context.Context) (plugin.SizedReader, error) {
+	content, err := json.MarshalIndent(plugin.PluginStatuses(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(append(content, '\n')), nil
+}
@@ -0,0 +1,179 @@
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/puppetlabs/wash/limits"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// WorkspaceEnvVar is the environment variable that contains the path to an
+// external plugin's workspace when its script is invoked
+const WorkspaceEnvVar = "WASH_PLUGIN_WORKSPACE"
+
+// workspaceSize is the maximum size of each plugin's workspace
+var workspaceSize = limits.Register(
+	"plugins.workspace_size_mb",
+	"The maximum size (in megabytes) of each plugin's workspace. Once it's exceeded, the least recently modified files are removed. 0 means unlimited.",
+	1024,
+	nil,
+)
+
+const (
+	// Files that haven't been modified in workspaceMaxAge are removed
+	workspaceMaxAge     = 24 * time.Hour
+	workspaceGCInterval = time.Minute
+)
+
+// workspacesDir contains a directory of workspaces for each running Wash
+// server, named by the server's PID. That way concurrent Wash servers (e.g.
+// from separate Wash shells) don't share workspaces.
+var workspacesDir = func() string {
+	cdir, err := os.UserCacheDir()
+	if err != nil {
+		cdir = os.TempDir()
+	}
+	return filepath.Join(cdir, "wash", "workspaces")
+}()
+
+func serverWorkspacesDir() string {
+	return filepath.Join(workspacesDir, strconv.Itoa(os.Getpid()))
+}
+
+// Workspace returns the path to the named plugin's workspace, creating it if
+// necessary. A workspace is a scratch directory for the plugin's temporary
+// files (e.g. downloaded archives or rendered config files). It's only
+// accessible by the current user. Files are removed from it once it exceeds
+// the plugins.workspace_size_mb limit or once they haven't been modified in
+// a day, and the whole workspace is removed when the Wash server shuts down.
+//
+// External plugins get their workspace via the WASH_PLUGIN_WORKSPACE
+// environment variable.
+func Workspace(pluginName string) (string, error) {
+	dir := filepath.Join(serverWorkspacesDir(), pluginName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("could not create the %v plugin's workspace: %v", pluginName, err)
+	}
+	return dir, nil
+}
+
+// InitWorkspaces removes the workspaces left behind by Wash servers that
+// didn't shut down cleanly, then starts garbage-collecting the current
+// server's workspaces. It should be called once when the Wash server starts.
+func InitWorkspaces() {
+	removeStaleWorkspaces()
+	go func() {
+		ticker := time.NewTicker(workspaceGCInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			gcWorkspaces(time.Now(), int64(workspaceSize.Value())*1024*1024)
+		}
+	}()
+}
+
+// RemoveWorkspaces removes the current server's workspaces. It should be
+// called when the Wash server shuts down.
+func RemoveWorkspaces() {
+	if err := os.RemoveAll(serverWorkspacesDir()); err != nil {
+		log.Warnf("Failed to remove the plugin workspaces: %v", err)
+	}
+}
+
+func removeStaleWorkspaces() {
+	dirs, err := ioutil.ReadDir(workspacesDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Failed to read the plugin workspaces: %v", err)
+		}
+		return
+	}
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil || pid == os.Getpid() || processExists(pid) {
+			continue
+		}
+		log.Debugf("Removing the stale plugin workspaces of Wash server %v", pid)
+		if err := os.RemoveAll(filepath.Join(workspacesDir, dir.Name())); err != nil {
+			log.Warnf("Failed to remove the stale plugin workspaces of Wash server %v: %v", pid, err)
+		}
+	}
+}
+
+func processExists(pid int) bool {
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
+
+func gcWorkspaces(now time.Time, maxSize int64) {
+	dirs, err := ioutil.ReadDir(serverWorkspacesDir())
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		if dir.IsDir() {
+			gcWorkspace(filepath.Join(serverWorkspacesDir(), dir.Name()), now, maxSize)
+		}
+	}
+}
+
+type workspaceFile struct {
+	path  string
+	size  int64
+	mtime time.Time
+}
+
+// gcWorkspace removes the workspace's files that haven't been modified since
+// workspaceMaxAge. It then removes the least recently modified files until
+// the workspace's size is at most maxSize. A maxSize <= 0 means unlimited.
+func gcWorkspace(dir string, now time.Time, maxSize int64) {
+	var files []workspaceFile
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// The file may have been removed by the plugin
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if now.Sub(info.ModTime()) > workspaceMaxAge {
+			removeWorkspaceFile(path)
+			return nil
+		}
+		files = append(files, workspaceFile{path: path, size: info.Size(), mtime: info.ModTime()})
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		log.Warnf("Failed to garbage-collect the plugin workspace %v: %v", dir, err)
+		return
+	}
+	if maxSize <= 0 || size <= maxSize {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mtime.Before(files[j].mtime)
+	})
+	for _, file := range files {
+		if size <= maxSize {
+			break
+		}
+		removeWorkspaceFile(file.path)
+		size -= file.size
+	}
+}
+
+func removeWorkspaceFile(path string) {
+	log.Debugf("Removing %v from its plugin workspace", path)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Failed to remove %v from its plugin workspace: %v", path, err)
+	}
+}
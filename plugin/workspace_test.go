@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WorkspaceTestSuite struct {
+	suite.Suite
+	origWorkspacesDir string
+}
+
+func (suite *WorkspaceTestSuite) SetupTest() {
+	suite.origWorkspacesDir = workspacesDir
+	workspacesDir = suite.T().TempDir()
+}
+
+func (suite *WorkspaceTestSuite) TearDownTest() {
+	workspacesDir = suite.origWorkspacesDir
+}
+
+func (suite *WorkspaceTestSuite) writeFile(path string, size int, mtime time.Time) {
+	suite.NoError(os.MkdirAll(filepath.Dir(path), 0700))
+	suite.NoError(ioutil.WriteFile(path, make([]byte, size), 0600))
+	suite.NoError(os.Chtimes(path, mtime, mtime))
+}
+
+func (suite *WorkspaceTestSuite) TestWorkspace() {
+	dir, err := Workspace("foo")
+	if suite.NoError(err) {
+		suite.Equal(filepath.Join(serverWorkspacesDir(), "foo"), dir)
+		info, err := os.Stat(dir)
+		if suite.NoError(err) {
+			suite.True(info.IsDir())
+			suite.Equal(os.FileMode(0700), info.Mode().Perm())
+		}
+	}
+
+	RemoveWorkspaces()
+	_, err = os.Stat(dir)
+	suite.True(os.IsNotExist(err))
+}
+
+func (suite *WorkspaceTestSuite) TestRemoveStaleWorkspaces() {
+	current, err := Workspace("foo")
+	suite.NoError(err)
+	// PIDs are capped well below this, so it can't be a running process
+	stale := filepath.Join(workspacesDir, "134217727", "foo")
+	suite.NoError(os.MkdirAll(stale, 0700))
+
+	removeStaleWorkspaces()
+	_, err = os.Stat(current)
+	suite.NoError(err)
+	_, err = os.Stat(filepath.Dir(stale))
+	suite.True(os.IsNotExist(err))
+}
+
+func (suite *WorkspaceTestSuite) TestGCWorkspace() {
+	dir, err := Workspace("foo")
+	suite.NoError(err)
+	now := time.Now()
+	suite.writeFile(filepath.Join(dir, "expired"), 1, now.Add(-2*workspaceMaxAge))
+	suite.writeFile(filepath.Join(dir, "nested", "oldest"), 10, now.Add(-3*time.Hour))
+	suite.writeFile(filepath.Join(dir, "older"), 10, now.Add(-2*time.Hour))
+	suite.writeFile(filepath.Join(dir, "newest"), 10, now.Add(-1*time.Hour))
+
+	gcWorkspaces(now, 25)
+	for _, name := range []string{"expired", "nested/oldest"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		suite.True(os.IsNotExist(err), name)
+	}
+	for _, name := range []string{"older", "newest"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		suite.NoError(err, name)
+	}
+
+	// A maxSize of 0 means unlimited
+	gcWorkspaces(now, 0)
+	_, err = os.Stat(filepath.Join(dir, "older"))
+	suite.NoError(err)
+}
+
+func TestWorkspace(t *testing.T) {
+	suite.Run(t, new(WorkspaceTestSuite))
+}
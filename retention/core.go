@@ -0,0 +1,195 @@
+// Package retention prunes the files that Wash accumulates on disk (e.g. its
+// activity journals and cache snapshots) according to retention policies.
+// Stores are registered by the packages that write them. The Wash server
+// prunes them in the background, and they can be pruned on demand via
+// `wash prune`.
+package retention
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Policy is a store's retention policy. A zero Policy keeps everything.
+type Policy struct {
+	// MaxAgeDays deletes the files that weren't modified in this many days
+	MaxAgeDays int `mapstructure:"max_age_days" json:"max_age_days"`
+	// MaxSizeMB deletes the oldest files once the store's bigger than this
+	// many MB
+	MaxSizeMB int `mapstructure:"max_size_mb" json:"max_size_mb"`
+}
+
+// Enabled returns true if the policy prunes anything
+func (p Policy) Enabled() bool {
+	return p.MaxAgeDays > 0 || p.MaxSizeMB > 0
+}
+
+// Result describes what pruning a store deleted
+type Result struct {
+	Store string `json:"store"`
+	// Removed are the names of the deleted files
+	Removed        []string `json:"removed"`
+	RemovedBytes   int64    `json:"removed_bytes"`
+	RemainingBytes int64    `json:"remaining_bytes"`
+}
+
+// Store is a directory of files that are pruned according to its policy
+type Store struct {
+	name  string
+	dir   func() string
+	inUse func(name string) bool
+	// mux ensures that the store's only pruned by one caller at a time
+	mux sync.Mutex
+}
+
+// Name returns the store's name
+func (s *Store) Name() string {
+	return s.name
+}
+
+var registryMux sync.Mutex
+var registry = make(map[string]*Store)
+
+// configured contains the policies set by Configure
+var configured = make(map[string]Policy)
+
+// now is mocked by the tests
+var now = time.Now
+
+// Register registers a store with the given name. dir returns the store's
+// directory. inUse is optional. If set, it's invoked with the name of each of
+// the store's files, and files that are in use are never deleted. If a store
+// with the same name was already registered, then Register returns the
+// existing store.
+func Register(name string, dir func() string, inUse func(name string) bool) *Store {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	if s, ok := registry[name]; ok {
+		return s
+	}
+	s := &Store{name: name, dir: dir, inUse: inUse}
+	registry[name] = s
+	return s
+}
+
+// All returns all of the registered stores, sorted by name
+func All() []*Store {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	stores := make([]*Store, 0, len(registry))
+	for _, s := range registry {
+		stores = append(stores, s)
+	}
+	sort.Slice(stores, func(i, j int) bool { return stores[i].name < stores[j].name })
+	return stores
+}
+
+// Configure sets the stores' policies. policies maps store names to their
+// policy. Stores that don't have a policy keep everything.
+func Configure(policies map[string]Policy) error {
+	for name, p := range policies {
+		if p.MaxAgeDays < 0 || p.MaxSizeMB < 0 {
+			return fmt.Errorf("the %v retention policy's max_age_days and max_size_mb must not be negative", name)
+		}
+	}
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	configured = make(map[string]Policy, len(policies))
+	for name, p := range policies {
+		configured[name] = p
+	}
+	return nil
+}
+
+// Policy returns the store's configured policy
+func (s *Store) Policy() Policy {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	return configured[s.name]
+}
+
+type file struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// Prune deletes the store's files that are older than p.MaxAgeDays, then
+// deletes the oldest of the remaining files until the store's no bigger than
+// p.MaxSizeMB. Files are ordered by when they were last modified.
+func (s *Store) Prune(p Policy) (Result, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	result := Result{Store: s.name, Removed: []string{}}
+	dir := s.dir()
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return result, fmt.Errorf("could not list the %v in %v: %v", s.name, dir, err)
+	}
+	var files []file
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, file{info.Name(), info.Size(), info.ModTime()})
+		result.RemainingBytes += info.Size()
+	}
+	// Oldest first
+	sort.SliceStable(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	maxAge := time.Duration(p.MaxAgeDays) * 24 * time.Hour
+	maxSize := int64(p.MaxSizeMB) * 1024 * 1024
+	for _, f := range files {
+		tooOld := maxAge > 0 && now().Sub(f.modTime) > maxAge
+		tooBig := maxSize > 0 && result.RemainingBytes > maxSize
+		if !tooOld && !tooBig {
+			continue
+		}
+		if s.inUse != nil && s.inUse(f.name) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, f.name)); err != nil && !os.IsNotExist(err) {
+			return result, fmt.Errorf("could not delete %v from the %v: %v", f.name, s.name, err)
+		}
+		result.Removed = append(result.Removed, f.name)
+		result.RemovedBytes += f.size
+		result.RemainingBytes -= f.size
+	}
+	return result, nil
+}
+
+// PruneAll prunes all of the registered stores according to their policy.
+// override is optional. If set, then its non-zero fields override the
+// corresponding fields of each store's policy.
+func PruneAll(override *Policy) ([]Result, error) {
+	var results []Result
+	for _, s := range All() {
+		p := s.Policy()
+		if override != nil {
+			if override.MaxAgeDays > 0 {
+				p.MaxAgeDays = override.MaxAgeDays
+			}
+			if override.MaxSizeMB > 0 {
+				p.MaxSizeMB = override.MaxSizeMB
+			}
+		}
+		if !p.Enabled() {
+			continue
+		}
+		result, err := s.Prune(p)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,143 @@
+package retention
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RetentionTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *RetentionTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "wash-retention")
+	suite.NoError(err)
+	suite.dir = dir
+	now = func() time.Time { return time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC) }
+}
+
+func (suite *RetentionTestSuite) TearDownTest() {
+	suite.NoError(os.RemoveAll(suite.dir))
+	now = time.Now
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	registry = make(map[string]*Store)
+	configured = make(map[string]Policy)
+}
+
+// writeFile writes a file with the given size that was last modified
+// daysAgo days ago
+func (suite *RetentionTestSuite) writeFile(name string, size int, daysAgo int) {
+	path := filepath.Join(suite.dir, name)
+	suite.NoError(ioutil.WriteFile(path, []byte(strings.Repeat("a", size)), 0600))
+	modTime := now().Add(-time.Duration(daysAgo) * 24 * time.Hour)
+	suite.NoError(os.Chtimes(path, modTime, modTime))
+}
+
+func (suite *RetentionTestSuite) remainingFiles() []string {
+	infos, err := ioutil.ReadDir(suite.dir)
+	suite.NoError(err)
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names
+}
+
+func (suite *RetentionTestSuite) newStore(inUse func(string) bool) *Store {
+	return Register("files", func() string { return suite.dir }, inUse)
+}
+
+func (suite *RetentionTestSuite) TestRegister() {
+	s := suite.newStore(nil)
+	suite.Equal("files", s.Name())
+	suite.Equal(s, Register("files", nil, nil))
+	suite.Equal([]*Store{s}, All())
+}
+
+func (suite *RetentionTestSuite) TestPruneMaxAge() {
+	suite.writeFile("old", 1, 5)
+	suite.writeFile("new", 2, 1)
+
+	result, err := suite.newStore(nil).Prune(Policy{MaxAgeDays: 3})
+	if suite.NoError(err) {
+		suite.Equal([]string{"old"}, result.Removed)
+		suite.Equal(int64(1), result.RemovedBytes)
+		suite.Equal(int64(2), result.RemainingBytes)
+	}
+	suite.Equal([]string{"new"}, suite.remainingFiles())
+}
+
+func (suite *RetentionTestSuite) TestPruneMaxSizeDeletesTheOldestFiles() {
+	mb := 1024 * 1024
+	suite.writeFile("a", mb, 3)
+	suite.writeFile("b", mb, 2)
+	suite.writeFile("c", mb, 1)
+
+	result, err := suite.newStore(nil).Prune(Policy{MaxSizeMB: 2})
+	if suite.NoError(err) {
+		suite.Equal([]string{"a"}, result.Removed)
+		suite.Equal(int64(2*mb), result.RemainingBytes)
+	}
+	suite.Equal([]string{"b", "c"}, suite.remainingFiles())
+}
+
+func (suite *RetentionTestSuite) TestPruneKeepsFilesInUse() {
+	suite.writeFile("a", 1, 5)
+	suite.writeFile("b", 1, 5)
+
+	result, err := suite.newStore(func(name string) bool { return name == "a" }).Prune(Policy{MaxAgeDays: 1})
+	if suite.NoError(err) {
+		suite.Equal([]string{"b"}, result.Removed)
+	}
+	suite.Equal([]string{"a"}, suite.remainingFiles())
+}
+
+func (suite *RetentionTestSuite) TestPruneMissingDir() {
+	s := Register("missing", func() string { return filepath.Join(suite.dir, "missing") }, nil)
+	result, err := s.Prune(Policy{MaxAgeDays: 1})
+	if suite.NoError(err) {
+		suite.Empty(result.Removed)
+	}
+}
+
+func (suite *RetentionTestSuite) TestPruneAll() {
+	suite.writeFile("a", 1, 5)
+	suite.writeFile("b", 1, 2)
+	suite.newStore(nil)
+
+	// Stores without a policy are skipped
+	results, err := PruneAll(nil)
+	suite.NoError(err)
+	suite.Empty(results)
+	suite.Len(suite.remainingFiles(), 2)
+
+	suite.NoError(Configure(map[string]Policy{"files": {MaxAgeDays: 3}}))
+	results, err = PruneAll(nil)
+	if suite.NoError(err) && suite.Len(results, 1) {
+		suite.Equal("files", results[0].Store)
+		suite.Equal([]string{"a"}, results[0].Removed)
+	}
+
+	results, err = PruneAll(&Policy{MaxAgeDays: 1})
+	if suite.NoError(err) && suite.Len(results, 1) {
+		suite.Equal([]string{"b"}, results[0].Removed)
+	}
+	suite.Empty(suite.remainingFiles())
+}
+
+func (suite *RetentionTestSuite) TestConfigureRejectsNegativeValues() {
+	err := Configure(map[string]Policy{"files": {MaxSizeMB: -1}})
+	suite.EqualError(err, "the files retention policy's max_age_days and max_size_mb must not be negative")
+}
+
+func TestRetention(t *testing.T) {
+	suite.Run(t, new(RetentionTestSuite))
+}
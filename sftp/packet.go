@@ -0,0 +1,198 @@
+package sftp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The SFTP v3 packet types that are used by the server. See
+// https://tools.ietf.org/html/draft-ietf-secsh-filexfer-02.
+const (
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpWrite    = 6
+	fxpLstat    = 7
+	fxpFstat    = 8
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpRealpath = 16
+	fxpStat     = 17
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpAttrs    = 105
+)
+
+// The SFTP v3 status codes
+const (
+	fxOK               = 0
+	fxEOF              = 1
+	fxNoSuchFile       = 2
+	fxPermissionDenied = 3
+	fxFailure          = 4
+	fxBadMessage       = 5
+	fxOpUnsupported    = 8
+)
+
+// The flags of an open request
+const (
+	fxfRead  = 0x01
+	fxfWrite = 0x02
+	fxfTrunc = 0x10
+)
+
+// The flags of a file's attributes
+const (
+	attrSize        = 0x01
+	attrUIDGID      = 0x02
+	attrPermissions = 0x04
+	attrAcModTime   = 0x08
+)
+
+const protocolVersion = 3
+
+// maxPacketSize bounds the packets that clients can send. OpenSSH's client
+// sends at most 256KB.
+const maxPacketSize = 1024 * 1024
+
+// maxReadSize bounds how much is read for a single read request
+const maxReadSize = 256 * 1024
+
+// maxWriteSize bounds the content of the files that are opened for writing,
+// since it's buffered in memory until they're closed
+const maxWriteSize = 64 * 1024 * 1024
+
+// packet is a decoded request. Its payload is everything after its type.
+type packet struct {
+	typ     byte
+	payload []byte
+}
+
+func readPacket(r io.Reader) (packet, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return packet{}, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > maxPacketSize {
+		return packet{}, fmt.Errorf("invalid packet length %v", length)
+	}
+	payload := make([]byte, length-1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return packet{}, err
+	}
+	return packet{typ: header[4], payload: payload}, nil
+}
+
+func writePacket(w io.Writer, typ byte, payload []byte) error {
+	buf := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(1+len(payload)))
+	buf[4] = typ
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+var errShortPacket = fmt.Errorf("the packet is too short")
+
+// decoder reads the fields of a packet's payload
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) uint32() uint32 {
+	if d.err != nil || len(d.buf) < 4 {
+		d.err = errShortPacket
+		return 0
+	}
+	v := binary.BigEndian.Uint32(d.buf)
+	d.buf = d.buf[4:]
+	return v
+}
+
+func (d *decoder) uint64() uint64 {
+	if d.err != nil || len(d.buf) < 8 {
+		d.err = errShortPacket
+		return 0
+	}
+	v := binary.BigEndian.Uint64(d.buf)
+	d.buf = d.buf[8:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uint32()
+	if d.err != nil || uint32(len(d.buf)) < n {
+		d.err = errShortPacket
+		return nil
+	}
+	v := d.buf[:n]
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+// encoder builds a packet's payload
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint32(v uint32) *encoder {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+	return e
+}
+
+func (e *encoder) uint64(v uint64) *encoder {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+	return e
+}
+
+func (e *encoder) bytes(v []byte) *encoder {
+	e.uint32(uint32(len(v)))
+	e.buf = append(e.buf, v...)
+	return e
+}
+
+func (e *encoder) string(v string) *encoder {
+	return e.bytes([]byte(v))
+}
+
+// fileAttrs are the attributes that the server reports for an entry
+type fileAttrs struct {
+	flags uint32
+	size  uint64
+	uid   uint32
+	gid   uint32
+	perms uint32
+	atime uint32
+	mtime uint32
+}
+
+func (e *encoder) attrs(a fileAttrs) *encoder {
+	e.uint32(a.flags)
+	if a.flags&attrSize != 0 {
+		e.uint64(a.size)
+	}
+	if a.flags&attrUIDGID != 0 {
+		e.uint32(a.uid).uint32(a.gid)
+	}
+	if a.flags&attrPermissions != 0 {
+		e.uint32(a.perms)
+	}
+	if a.flags&attrAcModTime != 0 {
+		e.uint32(a.atime).uint32(a.mtime)
+	}
+	return e
+}
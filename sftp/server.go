@@ -0,0 +1,230 @@
+// Package sftp serves the Wash filesystem over SFTP, so that it can be
+// browsed on machines without FUSE (e.g. Windows) with any SFTP client.
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/puppetlabs/wash/internal/netserver"
+	"github.com/puppetlabs/wash/plugin"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// Opts configures the SFTP server
+type Opts struct {
+	// Address is the address that the server listens on, e.g.
+	// "localhost:2222". The server's disabled if it's empty.
+	Address string
+	// HostKey is the server's private key. It defaults to
+	// <user_cache_dir>/wash/sftp_host_key, which is generated if it doesn't
+	// exist.
+	HostKey string `mapstructure:"host_key"`
+	// AuthorizedKeys are the public keys of the users that are allowed to
+	// connect. It defaults to ~/.ssh/authorized_keys.
+	AuthorizedKeys string `mapstructure:"authorized_keys"`
+}
+
+// Enabled returns true if the SFTP server should be started
+func (o Opts) Enabled() bool {
+	return o.Address != ""
+}
+
+// ServeSFTP starts serving the registry's entries over SFTP. Like
+// fuse.ServeFuseFS, it returns a channel to initiate the shutdown (by
+// closing it) and a channel that's closed once the server's shutdown.
+func ServeSFTP(registry *plugin.Registry, opts Opts) (chan<- struct{}, <-chan struct{}, error) {
+	config, err := serverConfig(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	listener, err := net.Listen("tcp", opts.Address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not listen on %v: %v", opts.Address, err)
+	}
+	log.Infof("SFTP: Listening on %v", listener.Addr())
+
+	stopCh := make(chan struct{})
+	stoppedCh := make(chan struct{})
+	srv := &server{registry: registry, config: config}
+	conns := netserver.New("SFTP", srv.serveConn)
+	go conns.Accept(listener)
+	go func() {
+		<-stopCh
+		conns.Shutdown(listener)
+		close(stoppedCh)
+	}()
+	return stopCh, stoppedCh, nil
+}
+
+type server struct {
+	registry *plugin.Registry
+	config   *ssh.ServerConfig
+}
+
+func (s *server) serveConn(conn net.Conn) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		log.Debugf("SFTP: Handshake with %v failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+	defer sshConn.Close()
+	log.Infof("SFTP: %v connected from %v", sshConn.User(), sshConn.RemoteAddr())
+	go ssh.DiscardRequests(reqs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			log.Debugf("SFTP: Could not accept a channel from %v: %v", sshConn.RemoteAddr(), err)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer channel.Close()
+			if !waitForSFTPSubsystem(requests) {
+				return
+			}
+			if err := newSession(s.registry, channel).serve(ctx); err != nil {
+				log.Debugf("SFTP: Session with %v ended: %v", sshConn.RemoteAddr(), err)
+			}
+		}()
+	}
+	cancel()
+	wg.Wait()
+	log.Infof("SFTP: %v disconnected", sshConn.RemoteAddr())
+}
+
+// waitForSFTPSubsystem returns true once the session requests the sftp
+// subsystem. Shells and commands aren't supported.
+func waitForSFTPSubsystem(requests <-chan *ssh.Request) bool {
+	for req := range requests {
+		ok := req.Type == "subsystem" && len(req.Payload) >= 4 && string(req.Payload[4:]) == "sftp"
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+		if ok {
+			go ssh.DiscardRequests(requests)
+			return true
+		}
+	}
+	return false
+}
+
+func serverConfig(opts Opts) (*ssh.ServerConfig, error) {
+	authorizedKeysPath := opts.AuthorizedKeys
+	if authorizedKeysPath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("could not find the default authorized_keys: %v", err)
+		}
+		authorizedKeysPath = filepath.Join(homeDir, ".ssh", "authorized_keys")
+	}
+	authorizedKeys, err := loadAuthorizedKeys(authorizedKeysPath)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyPath := opts.HostKey
+	if hostKeyPath == "" {
+		cdir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("could not find the default host key: %v", err)
+		}
+		hostKeyPath = filepath.Join(cdir, "wash", "sftp_host_key")
+	}
+	hostKey, err := loadHostKey(hostKeyPath, opts.HostKey == "")
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorizedKeys[string(key.Marshal())] {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("%v's key is not authorized", conn.User())
+		},
+	}
+	config.AddHostKey(hostKey)
+	return config, nil
+}
+
+// loadAuthorizedKeys returns the set of keys in an authorized_keys file,
+// keyed by their wire format
+func loadAuthorizedKeys(path string) (map[string]bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the authorized keys: %v", err)
+	}
+	keys := make(map[string]bool)
+	for len(bytes.TrimSpace(data)) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil && len(keys) > 0 && strings.Contains(err.Error(), "no key found") {
+			// The rest of the file's comments
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the authorized keys in %v: %v", path, err)
+		}
+		keys[string(key.Marshal())] = true
+		data = rest
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%v does not contain any authorized keys", path)
+	}
+	return keys, nil
+}
+
+// loadHostKey loads the host key at path. If generate is true and the key
+// doesn't exist, then it's generated.
+func loadHostKey(path string, generate bool) (ssh.Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && generate {
+		log.Infof("SFTP: Generating the host key %v", path)
+		if data, err = generateHostKey(path); err != nil {
+			return nil, fmt.Errorf("could not generate the host key: %v", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read the host key: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the host key %v: %v", path, err)
+	}
+	return signer, nil
+}
+
+func generateHostKey(path string) ([]byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
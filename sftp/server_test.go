@@ -0,0 +1,154 @@
+package sftp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/puppetlabs/wash/internal/netserver"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ssh"
+)
+
+type ServerTestSuite struct {
+	suite.Suite
+	dir       string
+	clientKey ssh.Signer
+	stop      func()
+}
+
+func (suite *ServerTestSuite) SetupTest() {
+	var err error
+	suite.dir, err = ioutil.TempDir("", "wash-sftp")
+	suite.NoError(err)
+	suite.clientKey = suite.newKey()
+	suite.NoError(ioutil.WriteFile(suite.path("authorized_keys"), ssh.MarshalAuthorizedKey(suite.clientKey.PublicKey()), 0600))
+}
+
+func (suite *ServerTestSuite) TearDownTest() {
+	if suite.stop != nil {
+		suite.stop()
+		suite.stop = nil
+	}
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *ServerTestSuite) path(name string) string {
+	return filepath.Join(suite.dir, name)
+}
+
+func (suite *ServerTestSuite) newKey() ssh.Signer {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	suite.NoError(err)
+	signer, err := ssh.NewSignerFromKey(key)
+	suite.NoError(err)
+	return signer
+}
+
+// connect connects to a server that's configured with opts. The server's
+// stopped once the test's done.
+func (suite *ServerTestSuite) connect(opts Opts, key ssh.Signer) (*ssh.Client, error) {
+	config, err := serverConfig(opts)
+	if !suite.NoError(err) {
+		suite.FailNow("could not configure the server")
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !suite.NoError(err) {
+		suite.FailNow("could not listen")
+	}
+	srv := &server{registry: plugin.NewRegistry(), config: config}
+	conns := netserver.New("SFTP", srv.serveConn)
+	go conns.Accept(listener)
+	suite.stop = func() {
+		conns.Shutdown(listener)
+	}
+
+	return ssh.Dial("tcp", listener.Addr().String(), &ssh.ClientConfig{
+		User:            "wash",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(key)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+}
+
+func (suite *ServerTestSuite) TestServesTheSFTPSubsystem() {
+	opts := Opts{AuthorizedKeys: suite.path("authorized_keys"), HostKey: suite.path("host_key")}
+	suite.NoError(generateKeyFile(suite.path("host_key")))
+	client, err := suite.connect(opts, suite.clientKey)
+	if !suite.NoError(err) {
+		return
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if !suite.NoError(err) {
+		return
+	}
+	defer session.Close()
+	stdin, err := session.StdinPipe()
+	suite.NoError(err)
+	stdout, err := session.StdoutPipe()
+	suite.NoError(err)
+	suite.NoError(session.RequestSubsystem("sftp"))
+
+	suite.NoError(writePacket(stdin, fxpInit, new(encoder).uint32(protocolVersion).buf))
+	pkt, err := readPacket(stdout)
+	if suite.NoError(err) {
+		suite.Equal(byte(fxpVersion), pkt.typ)
+	}
+}
+
+func (suite *ServerTestSuite) TestRejectsUnauthorizedKeys() {
+	opts := Opts{AuthorizedKeys: suite.path("authorized_keys"), HostKey: suite.path("host_key")}
+	suite.NoError(generateKeyFile(suite.path("host_key")))
+	_, err := suite.connect(opts, suite.newKey())
+	suite.Error(err)
+}
+
+func (suite *ServerTestSuite) TestLoadHostKeyGeneratesTheDefaultKey() {
+	path := suite.path("wash/sftp_host_key")
+	signer, err := loadHostKey(path, true)
+	if suite.NoError(err) {
+		// The generated key's reused
+		reloaded, err := loadHostKey(path, true)
+		if suite.NoError(err) {
+			suite.Equal(signer.PublicKey().Marshal(), reloaded.PublicKey().Marshal())
+		}
+	}
+	info, err := os.Stat(path)
+	if suite.NoError(err) {
+		suite.Equal(os.FileMode(0600), info.Mode().Perm())
+	}
+
+	// Configured keys must exist
+	_, err = loadHostKey(suite.path("missing"), false)
+	suite.Error(err)
+}
+
+func (suite *ServerTestSuite) TestLoadAuthorizedKeys() {
+	otherKey := suite.newKey()
+	data := "# Wash's users\n" + string(ssh.MarshalAuthorizedKey(suite.clientKey.PublicKey())) + "\n" + string(ssh.MarshalAuthorizedKey(otherKey.PublicKey()))
+	suite.NoError(ioutil.WriteFile(suite.path("keys"), []byte(data), 0600))
+	keys, err := loadAuthorizedKeys(suite.path("keys"))
+	if suite.NoError(err) {
+		suite.Len(keys, 2)
+		suite.True(keys[string(otherKey.PublicKey().Marshal())])
+	}
+
+	suite.NoError(ioutil.WriteFile(suite.path("empty"), []byte("\n"), 0600))
+	_, err = loadAuthorizedKeys(suite.path("empty"))
+	suite.Error(err)
+}
+
+func generateKeyFile(path string) error {
+	_, err := generateHostKey(path)
+	return err
+}
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
@@ -0,0 +1,474 @@
+package sftp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/puppetlabs/wash/activity"
+	apitypes "github.com/puppetlabs/wash/api/types"
+	"github.com/puppetlabs/wash/plugin"
+	log "github.com/sirupsen/logrus"
+)
+
+// readdirBatchSize is how many children are returned for each readdir request
+const readdirBatchSize = 100
+
+var startTime = time.Now()
+
+// session serves the SFTP requests of a single client. Requests are served
+// in order.
+type session struct {
+	registry   *plugin.Registry
+	rw         io.ReadWriter
+	handles    map[string]*handle
+	nextHandle int
+}
+
+// handle is an open file or directory
+type handle struct {
+	path  string
+	entry plugin.Entry
+	// reader is set for the files that are only opened for reading
+	reader plugin.SizedReader
+	// writable is set for the files that are opened for writing. Writes are
+	// buffered in content, which is written to the entry when the handle's
+	// closed since entries can only replace their entire content.
+	writable plugin.Writable
+	content  []byte
+	dirty    bool
+	// children and cnames are set for directories once they're listed.
+	// cnames are the children that haven't been returned yet.
+	children map[string]plugin.Entry
+	cnames   []string
+	listed   bool
+}
+
+func newSession(registry *plugin.Registry, rw io.ReadWriter) *session {
+	return &session{registry: registry, rw: rw, handles: make(map[string]*handle)}
+}
+
+// serve serves the session's requests until the client disconnects
+func (s *session) serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer s.closeAll(ctx)
+
+	pkt, err := readPacket(s.rw)
+	if err != nil {
+		return err
+	}
+	if pkt.typ != fxpInit {
+		return fmt.Errorf("expected an init packet, got a packet of type %v", pkt.typ)
+	}
+	if err := writePacket(s.rw, fxpVersion, new(encoder).uint32(protocolVersion).buf); err != nil {
+		return err
+	}
+
+	for {
+		pkt, err := readPacket(s.rw)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := s.handle(ctx, pkt); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *session) handle(ctx context.Context, pkt packet) error {
+	d := &decoder{buf: pkt.payload}
+	id := d.uint32()
+	if d.err != nil {
+		return d.err
+	}
+	switch pkt.typ {
+	case fxpRealpath:
+		p := cleanPath(d.string())
+		if d.err != nil {
+			return s.badMessage(id)
+		}
+		return s.reply(fxpName, new(encoder).uint32(id).uint32(1).string(p).string(p).attrs(fileAttrs{}))
+	case fxpStat, fxpLstat:
+		p := cleanPath(d.string())
+		if d.err != nil {
+			return s.badMessage(id)
+		}
+		entry, err := s.find(ctx, p)
+		if err != nil {
+			return s.lookupError(id, "Stat", p, err)
+		}
+		return s.reply(fxpAttrs, new(encoder).uint32(id).attrs(attrsOf(entry)))
+	case fxpFstat:
+		h, ok := s.handles[d.string()]
+		if !ok {
+			return s.invalidHandle(id)
+		}
+		attrs := attrsOf(h.entry)
+		if h.writable != nil {
+			attrs.flags |= attrSize
+			attrs.size = uint64(len(h.content))
+		}
+		return s.reply(fxpAttrs, new(encoder).uint32(id).attrs(attrs))
+	case fxpOpendir:
+		p := cleanPath(d.string())
+		if d.err != nil {
+			return s.badMessage(id)
+		}
+		entry, err := s.find(ctx, p)
+		if err != nil {
+			return s.lookupError(id, "Opendir", p, err)
+		}
+		if !plugin.ListAction().IsSupportedOn(entry) {
+			return s.status(id, fxFailure, fmt.Sprintf("%v is not a directory", p))
+		}
+		return s.newHandle(id, &handle{path: p, entry: entry})
+	case fxpReaddir:
+		h, ok := s.handles[d.string()]
+		if !ok || !plugin.ListAction().IsSupportedOn(h.entry) {
+			return s.invalidHandle(id)
+		}
+		return s.readdir(ctx, id, h)
+	case fxpOpen:
+		p := cleanPath(d.string())
+		flags := d.uint32()
+		if d.err != nil {
+			return s.badMessage(id)
+		}
+		return s.open(ctx, id, p, flags)
+	case fxpRead:
+		h, ok := s.handles[d.string()]
+		offset, length := d.uint64(), d.uint32()
+		if d.err != nil {
+			return s.badMessage(id)
+		}
+		if !ok || (h.reader == nil && h.writable == nil) {
+			return s.invalidHandle(id)
+		}
+		if offset > math.MaxInt64 {
+			return s.status(id, fxFailure, fmt.Sprintf("the offset %v is too large", offset))
+		}
+		return s.read(id, h, int64(offset), length)
+	case fxpWrite:
+		h, ok := s.handles[d.string()]
+		offset, data := d.uint64(), d.bytes()
+		if d.err != nil {
+			return s.badMessage(id)
+		}
+		if !ok {
+			return s.invalidHandle(id)
+		}
+		if h.writable == nil {
+			return s.status(id, fxPermissionDenied, fmt.Sprintf("%v was not opened for writing", h.path))
+		}
+		// Checking the offset first keeps the end from overflowing
+		if offset > maxWriteSize || offset+uint64(len(data)) > maxWriteSize {
+			return s.status(id, fxFailure, fmt.Sprintf("%v cannot be larger than %v bytes", h.path, maxWriteSize))
+		}
+		if end := int(offset) + len(data); end > len(h.content) {
+			h.content = append(h.content, make([]byte, end-len(h.content))...)
+		}
+		copy(h.content[offset:], data)
+		h.dirty = true
+		return s.status(id, fxOK, "")
+	case fxpClose:
+		handleID := d.string()
+		h, ok := s.handles[handleID]
+		if !ok {
+			return s.invalidHandle(id)
+		}
+		delete(s.handles, handleID)
+		if err := s.close(ctx, h); err != nil {
+			return s.status(id, statusFor(err), err.Error())
+		}
+		return s.status(id, fxOK, "")
+	default:
+		return s.status(id, fxOpUnsupported, fmt.Sprintf("Wash does not support SFTP requests of type %v", pkt.typ))
+	}
+}
+
+func (s *session) open(ctx context.Context, id uint32, p string, flags uint32) error {
+	log.Debugf("SFTP: Open %v", p)
+	entry, err := s.find(ctx, p)
+	if err != nil {
+		return s.lookupError(id, "Open", p, err)
+	}
+	if plugin.ListAction().IsSupportedOn(entry) {
+		return s.status(id, fxFailure, fmt.Sprintf("%v is a directory", p))
+	}
+
+	h := &handle{path: p, entry: entry}
+	if flags&fxfWrite != 0 {
+		if !plugin.WriteAction().IsSupportedOn(entry) {
+			return s.status(id, fxPermissionDenied, fmt.Sprintf("%v does not support the write action", p))
+		}
+		h.writable = entry.(plugin.Writable)
+		if flags&fxfTrunc != 0 {
+			// Truncating the file replaces its content even if nothing's
+			// written
+			h.dirty = true
+		} else if plugin.ReadAction().IsSupportedOn(entry) {
+			// The writes only change part of the content, so the rest
+			// of it needs to be written back
+			if h.content, err = readAll(ctx, entry.(plugin.Readable)); err != nil {
+				activity.Warnf(ctx, "SFTP: [%v] Open %v errored: %v", apitypes.ErrorCodeFor(err), p, err)
+				return s.status(id, statusFor(err), err.Error())
+			}
+		}
+	} else if flags&fxfRead != 0 {
+		if !plugin.ReadAction().IsSupportedOn(entry) {
+			return s.status(id, fxPermissionDenied, fmt.Sprintf("%v does not support the read action", p))
+		}
+		if h.reader, err = plugin.Open(ctx, entry.(plugin.Readable)); err != nil {
+			activity.Warnf(ctx, "SFTP: [%v] Open %v errored: %v", apitypes.ErrorCodeFor(err), p, err)
+			return s.status(id, statusFor(err), err.Error())
+		}
+	} else {
+		return s.status(id, fxBadMessage, "the file must be opened for reading or writing")
+	}
+	return s.newHandle(id, h)
+}
+
+func (s *session) readdir(ctx context.Context, id uint32, h *handle) error {
+	if !h.listed {
+		log.Debugf("SFTP: Readdir %v", h.path)
+		children, err := plugin.List(ctx, h.entry.(plugin.Parent))
+		if err != nil {
+			activity.Warnf(ctx, "SFTP: [%v] Readdir %v errored: %v", apitypes.ErrorCodeFor(err), h.path, err)
+			return s.status(id, statusFor(err), err.Error())
+		}
+		h.children = children
+		for cname := range children {
+			h.cnames = append(h.cnames, cname)
+		}
+		sort.Strings(h.cnames)
+		h.listed = true
+	}
+	if len(h.cnames) == 0 {
+		return s.status(id, fxEOF, "")
+	}
+
+	batch := h.cnames
+	if len(batch) > readdirBatchSize {
+		batch = batch[:readdirBatchSize]
+	}
+	h.cnames = h.cnames[len(batch):]
+
+	e := new(encoder).uint32(id).uint32(uint32(len(batch)))
+	for _, cname := range batch {
+		attrs := attrsOf(h.children[cname])
+		e.string(cname).string(longname(cname, attrs)).attrs(attrs)
+	}
+	return s.reply(fxpName, e)
+}
+
+func (s *session) read(id uint32, h *handle, offset int64, length uint32) error {
+	if length > maxReadSize {
+		length = maxReadSize
+	}
+	if h.writable != nil {
+		if offset >= int64(len(h.content)) {
+			return s.status(id, fxEOF, "")
+		}
+		end := offset + int64(length)
+		if end > int64(len(h.content)) {
+			end = int64(len(h.content))
+		}
+		return s.reply(fxpData, new(encoder).uint32(id).bytes(h.content[offset:end]))
+	}
+
+	if offset >= h.reader.Size() {
+		return s.status(id, fxEOF, "")
+	}
+	buf := make([]byte, length)
+	n, err := h.reader.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return s.status(id, statusFor(err), err.Error())
+	}
+	if n == 0 {
+		return s.status(id, fxEOF, "")
+	}
+	return s.reply(fxpData, new(encoder).uint32(id).bytes(buf[:n]))
+}
+
+func (s *session) close(ctx context.Context, h *handle) error {
+	if closer, ok := h.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	if h.writable == nil || !h.dirty {
+		return nil
+	}
+	activity.Record(ctx, "SFTP: Writing %v bytes to %v", len(h.content), h.path)
+	if err := plugin.Write(ctx, h.writable, h.content); err != nil {
+		activity.Warnf(ctx, "SFTP: [%v] Write %v errored: %v", apitypes.ErrorCodeFor(err), h.path, err)
+		return err
+	}
+	return nil
+}
+
+// closeAll closes the handles that the client left open when it
+// disconnected. Their unflushed writes are discarded since the client
+// never confirmed them.
+func (s *session) closeAll(ctx context.Context) {
+	for id, h := range s.handles {
+		if closer, ok := h.reader.(io.Closer); ok {
+			closer.Close()
+		}
+		delete(s.handles, id)
+	}
+}
+
+// find returns the entry at p, which is an absolute, clean path
+func (s *session) find(ctx context.Context, p string) (plugin.Entry, error) {
+	if p == "/" {
+		return s.registry, nil
+	}
+	segments := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	root, ok := s.registry.Plugins()[segments[0]]
+	if !ok {
+		return nil, fmt.Errorf("the %v plugin does not exist", segments[0])
+	}
+	if len(segments) == 1 {
+		return root, nil
+	}
+	return plugin.FindEntry(ctx, root, segments[1:])
+}
+
+func (s *session) newHandle(id uint32, h *handle) error {
+	s.nextHandle++
+	handleID := strconv.Itoa(s.nextHandle)
+	s.handles[handleID] = h
+	return s.reply(fxpHandle, new(encoder).uint32(id).string(handleID))
+}
+
+func (s *session) reply(typ byte, e *encoder) error {
+	return writePacket(s.rw, typ, e.buf)
+}
+
+func (s *session) status(id uint32, code uint32, msg string) error {
+	return s.reply(fxpStatus, new(encoder).uint32(id).uint32(code).string(msg).string(""))
+}
+
+func (s *session) badMessage(id uint32) error {
+	return s.status(id, fxBadMessage, errShortPacket.Error())
+}
+
+func (s *session) invalidHandle(id uint32) error {
+	return s.status(id, fxFailure, "invalid handle")
+}
+
+// lookupError responds with the error that occurred while finding the
+// entry at p. Like the API, it reports that the entry doesn't exist unless
+// the plugin denied access to it.
+func (s *session) lookupError(id uint32, op string, p string, err error) error {
+	log.Debugf("SFTP: %v %v errored: %v", op, p, err)
+	if os.IsPermission(err) {
+		return s.status(id, fxPermissionDenied, err.Error())
+	}
+	return s.status(id, fxNoSuchFile, err.Error())
+}
+
+func statusFor(err error) uint32 {
+	switch {
+	case os.IsPermission(err):
+		return fxPermissionDenied
+	case os.IsNotExist(err):
+		return fxNoSuchFile
+	default:
+		return fxFailure
+	}
+}
+
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+func readAll(ctx context.Context, r plugin.Readable) ([]byte, error) {
+	content, err := plugin.Open(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := content.(io.Closer); ok {
+		defer closer.Close()
+	}
+	data := make([]byte, content.Size())
+	if _, err := content.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// attrsOf returns entry's attributes. Like the FUSE filesystem, it falls
+// back to sensible defaults for the attributes that the plugin doesn't know.
+func attrsOf(entry plugin.Entry) fileAttrs {
+	attr := plugin.Attributes(entry)
+	isDir := plugin.ListAction().IsSupportedOn(entry)
+
+	var perms os.FileMode
+	if attr.HasMode() {
+		perms = attr.Mode().Perm()
+	}
+	if perms == 0 {
+		if isDir {
+			perms = 0555
+		} else {
+			if plugin.ReadAction().IsSupportedOn(entry) {
+				perms |= 0444
+			}
+			if plugin.WriteAction().IsSupportedOn(entry) {
+				perms |= 0200
+			}
+		}
+	}
+
+	a := fileAttrs{flags: attrPermissions | attrAcModTime, perms: uint32(perms)}
+	if isDir {
+		a.perms |= modeDir
+	} else {
+		a.perms |= modeRegular
+	}
+	if uid, gid := os.Getuid(), os.Getgid(); uid >= 0 && gid >= 0 {
+		a.flags |= attrUIDGID
+		a.uid, a.gid = uint32(uid), uint32(gid)
+	}
+	if attr.HasSize() {
+		a.flags |= attrSize
+		a.size = attr.Size()
+	}
+	a.mtime = uint32(startTime.Unix())
+	if attr.HasMtime() {
+		a.mtime = uint32(attr.Mtime().Unix())
+	}
+	a.atime = a.mtime
+	if attr.HasAtime() {
+		a.atime = uint32(attr.Atime().Unix())
+	}
+	return a
+}
+
+// The file types of the POSIX permissions that are sent to clients
+const (
+	modeDir     = 0040000
+	modeRegular = 0100000
+)
+
+// longname formats a readdir result like `ls -l`, which is what clients
+// like OpenSSH's sftp show
+func longname(name string, a fileAttrs) string {
+	mode := os.FileMode(a.perms & 0777)
+	if a.perms&modeDir != 0 {
+		mode |= os.ModeDir
+	}
+	mtime := time.Unix(int64(a.mtime), 0)
+	return fmt.Sprintf("%v 1 %-8d %-8d %8d %v %v", mode, a.uid, a.gid, a.size, mtime.Format("Jan _2 15:04"), name)
+}
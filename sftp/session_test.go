@@ -0,0 +1,322 @@
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/puppetlabs/wash/datastore"
+	"github.com/puppetlabs/wash/plugin"
+	"github.com/stretchr/testify/suite"
+)
+
+type sftpTestsDir struct {
+	plugin.EntryBase
+	children []plugin.Entry
+}
+
+func newSFTPTestsDir(name string, children ...plugin.Entry) *sftpTestsDir {
+	return &sftpTestsDir{EntryBase: plugin.NewEntry(name), children: children}
+}
+
+func (d *sftpTestsDir) Init(map[string]interface{}) error {
+	return nil
+}
+
+func (d *sftpTestsDir) List(context.Context) ([]plugin.Entry, error) {
+	return d.children, nil
+}
+
+func (d *sftpTestsDir) ChildSchemas() []*plugin.EntrySchema {
+	return nil
+}
+
+func (d *sftpTestsDir) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+type sftpTestsFile struct {
+	plugin.EntryBase
+	content []byte
+	writes  []string
+}
+
+func newSFTPTestsFile(name string, content string) *sftpTestsFile {
+	f := &sftpTestsFile{EntryBase: plugin.NewEntry(name), content: []byte(content)}
+	f.DisableDefaultCaching()
+	return f
+}
+
+func (f *sftpTestsFile) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+func (f *sftpTestsFile) Open(context.Context) (plugin.SizedReader, error) {
+	return bytes.NewReader(f.content), nil
+}
+
+func (f *sftpTestsFile) Write(ctx context.Context, data []byte) error {
+	f.writes = append(f.writes, string(data))
+	f.content = data
+	return nil
+}
+
+type sftpTestsReadOnlyFile struct {
+	plugin.EntryBase
+}
+
+func (f *sftpTestsReadOnlyFile) Schema() *plugin.EntrySchema {
+	return nil
+}
+
+func (f *sftpTestsReadOnlyFile) Open(context.Context) (plugin.SizedReader, error) {
+	return bytes.NewReader([]byte("read only")), nil
+}
+
+type SessionTestSuite struct {
+	suite.Suite
+	file   *sftpTestsFile
+	conn   net.Conn
+	nextID uint32
+	done   chan error
+}
+
+func (suite *SessionTestSuite) SetupTest() {
+	plugin.SetTestCache(datastore.NewMemCache())
+	suite.file = newSFTPTestsFile("motd", "hello world")
+	readOnly := &sftpTestsReadOnlyFile{EntryBase: plugin.NewEntry("readonly")}
+	root := newSFTPTestsDir("vms", newSFTPTestsDir("vm", suite.file, readOnly))
+	root.SetTestID("/vms")
+	registry := plugin.NewRegistry()
+	suite.NoError(registry.RegisterPlugin(root, nil))
+
+	serverConn, clientConn := net.Pipe()
+	suite.conn = clientConn
+	suite.done = make(chan error, 1)
+	go func() {
+		suite.done <- newSession(registry, serverConn).serve(context.Background())
+		serverConn.Close()
+	}()
+
+	suite.NoError(writePacket(suite.conn, fxpInit, new(encoder).uint32(protocolVersion).buf))
+	pkt, err := readPacket(suite.conn)
+	if suite.NoError(err) && suite.Equal(byte(fxpVersion), pkt.typ) {
+		d := &decoder{buf: pkt.payload}
+		suite.Equal(uint32(protocolVersion), d.uint32())
+	}
+}
+
+func (suite *SessionTestSuite) TearDownTest() {
+	suite.conn.Close()
+	suite.NoError(<-suite.done)
+	plugin.UnsetTestCache()
+}
+
+// request sends a request with the given fields, and returns the type of
+// the response along with a decoder for the rest of its fields
+func (suite *SessionTestSuite) request(typ byte, fields func(*encoder)) (byte, *decoder) {
+	suite.nextID++
+	e := new(encoder).uint32(suite.nextID)
+	if fields != nil {
+		fields(e)
+	}
+	if !suite.NoError(writePacket(suite.conn, typ, e.buf)) {
+		suite.FailNow("could not send the request")
+	}
+	pkt, err := readPacket(suite.conn)
+	if !suite.NoError(err) {
+		suite.FailNow("could not read the response")
+	}
+	d := &decoder{buf: pkt.payload}
+	suite.Equal(suite.nextID, d.uint32())
+	return pkt.typ, d
+}
+
+func (suite *SessionTestSuite) requestHandle(typ byte, fields func(*encoder)) string {
+	respType, d := suite.request(typ, fields)
+	if respType == fxpStatus {
+		code, msg := d.uint32(), d.string()
+		suite.FailNow(fmt.Sprintf("expected a handle, got status %v: %v", code, msg))
+	}
+	suite.Equal(byte(fxpHandle), respType)
+	return d.string()
+}
+
+func (suite *SessionTestSuite) assertStatus(code uint32, respType byte, d *decoder) string {
+	if suite.Equal(byte(fxpStatus), respType) {
+		suite.Equal(code, d.uint32())
+		return d.string()
+	}
+	return ""
+}
+
+func (suite *SessionTestSuite) requestOK(typ byte, fields func(*encoder)) {
+	respType, d := suite.request(typ, fields)
+	suite.assertStatus(fxOK, respType, d)
+}
+
+func (suite *SessionTestSuite) open(path string, flags uint32) string {
+	return suite.requestHandle(fxpOpen, func(e *encoder) {
+		e.string(path).uint32(flags).uint32(0)
+	})
+}
+
+func (suite *SessionTestSuite) close(handle string) {
+	suite.requestOK(fxpClose, func(e *encoder) { e.string(handle) })
+}
+
+func (suite *SessionTestSuite) readdir(path string) []string {
+	handle := suite.requestHandle(fxpOpendir, func(e *encoder) { e.string(path) })
+	var names []string
+	for {
+		respType, d := suite.request(fxpReaddir, func(e *encoder) { e.string(handle) })
+		if respType == fxpStatus {
+			suite.Equal(uint32(fxEOF), d.uint32())
+			break
+		}
+		suite.Equal(byte(fxpName), respType)
+		count := d.uint32()
+		for i := uint32(0); i < count; i++ {
+			names = append(names, d.string())
+			d.string()
+			decodeAttrs(d)
+		}
+		suite.NoError(d.err)
+	}
+	suite.close(handle)
+	return names
+}
+
+func decodeAttrs(d *decoder) fileAttrs {
+	a := fileAttrs{flags: d.uint32()}
+	if a.flags&attrSize != 0 {
+		a.size = d.uint64()
+	}
+	if a.flags&attrUIDGID != 0 {
+		a.uid, a.gid = d.uint32(), d.uint32()
+	}
+	if a.flags&attrPermissions != 0 {
+		a.perms = d.uint32()
+	}
+	if a.flags&attrAcModTime != 0 {
+		a.atime, a.mtime = d.uint32(), d.uint32()
+	}
+	return a
+}
+
+func (suite *SessionTestSuite) TestRealpath() {
+	respType, d := suite.request(fxpRealpath, func(e *encoder) { e.string(".") })
+	if suite.Equal(byte(fxpName), respType) {
+		suite.Equal(uint32(1), d.uint32())
+		suite.Equal("/", d.string())
+	}
+
+	respType, d = suite.request(fxpRealpath, func(e *encoder) { e.string("vms/vm/../vm/") })
+	if suite.Equal(byte(fxpName), respType) {
+		suite.Equal(uint32(1), d.uint32())
+		suite.Equal("/vms/vm", d.string())
+	}
+}
+
+func (suite *SessionTestSuite) TestReaddir() {
+	suite.Equal([]string{"vms"}, suite.readdir("/"))
+	suite.Equal([]string{"motd", "readonly"}, suite.readdir("/vms/vm"))
+}
+
+func (suite *SessionTestSuite) TestStat() {
+	respType, d := suite.request(fxpStat, func(e *encoder) { e.string("/vms/vm") })
+	if suite.Equal(byte(fxpAttrs), respType) {
+		attrs := decodeAttrs(d)
+		suite.Equal(uint32(modeDir|0555), attrs.perms)
+	}
+
+	respType, d = suite.request(fxpLstat, func(e *encoder) { e.string("/vms/vm/motd") })
+	if suite.Equal(byte(fxpAttrs), respType) {
+		attrs := decodeAttrs(d)
+		suite.Equal(uint32(modeRegular|0644), attrs.perms)
+	}
+
+	respType, d = suite.request(fxpStat, func(e *encoder) { e.string("/vms/vm/readonly") })
+	if suite.Equal(byte(fxpAttrs), respType) {
+		attrs := decodeAttrs(d)
+		suite.Equal(uint32(modeRegular|0444), attrs.perms)
+	}
+}
+
+func (suite *SessionTestSuite) TestStatMissingEntry() {
+	respType, d := suite.request(fxpStat, func(e *encoder) { e.string("/vms/vm/missing") })
+	suite.Contains(suite.assertStatus(fxNoSuchFile, respType, d), "missing")
+
+	respType, d = suite.request(fxpStat, func(e *encoder) { e.string("/aws") })
+	suite.Contains(suite.assertStatus(fxNoSuchFile, respType, d), "aws")
+}
+
+func (suite *SessionTestSuite) TestRead() {
+	handle := suite.open("/vms/vm/motd", fxfRead)
+	respType, d := suite.request(fxpRead, func(e *encoder) { e.string(handle).uint64(6).uint32(1024) })
+	if suite.Equal(byte(fxpData), respType) {
+		suite.Equal("world", string(d.bytes()))
+	}
+	respType, d = suite.request(fxpRead, func(e *encoder) { e.string(handle).uint64(11).uint32(1024) })
+	suite.assertStatus(fxEOF, respType, d)
+	suite.close(handle)
+	suite.Empty(suite.file.writes)
+}
+
+func (suite *SessionTestSuite) TestWrite() {
+	handle := suite.open("/vms/vm/motd", fxfWrite|fxfTrunc)
+	suite.requestOK(fxpWrite, func(e *encoder) { e.string(handle).uint64(0).bytes([]byte("goodbye ")) })
+	suite.requestOK(fxpWrite, func(e *encoder) { e.string(handle).uint64(8).bytes([]byte("world")) })
+	suite.Empty(suite.file.writes)
+	suite.close(handle)
+	suite.Equal([]string{"goodbye world"}, suite.file.writes)
+}
+
+func (suite *SessionTestSuite) TestWriteWithoutTruncatingKeepsTheRestOfTheContent() {
+	handle := suite.open("/vms/vm/motd", fxfWrite)
+	suite.requestOK(fxpWrite, func(e *encoder) { e.string(handle).uint64(0).bytes([]byte("jello")) })
+	suite.close(handle)
+	suite.Equal([]string{"jello world"}, suite.file.writes)
+}
+
+func (suite *SessionTestSuite) TestWriteAndReadBeyondTheBounds() {
+	handle := suite.open("/vms/vm/motd", fxfWrite|fxfTrunc)
+	for _, offset := range []uint64{maxWriteSize, math.MaxUint64} {
+		respType, d := suite.request(fxpWrite, func(e *encoder) { e.string(handle).uint64(offset).bytes([]byte("a")) })
+		suite.Contains(suite.assertStatus(fxFailure, respType, d), "larger")
+	}
+	respType, d := suite.request(fxpRead, func(e *encoder) { e.string(handle).uint64(math.MaxUint64).uint32(1024) })
+	suite.Contains(suite.assertStatus(fxFailure, respType, d), "too large")
+	suite.close(handle)
+	suite.Equal([]string{""}, suite.file.writes)
+
+	handle = suite.open("/vms/vm/motd", fxfRead)
+	respType, d = suite.request(fxpRead, func(e *encoder) { e.string(handle).uint64(math.MaxUint64).uint32(1024) })
+	suite.Contains(suite.assertStatus(fxFailure, respType, d), "too large")
+	suite.close(handle)
+}
+
+func (suite *SessionTestSuite) TestWriteUnsupported() {
+	respType, d := suite.request(fxpOpen, func(e *encoder) {
+		e.string("/vms/vm/readonly").uint32(fxfWrite | fxfTrunc).uint32(0)
+	})
+	suite.Contains(suite.assertStatus(fxPermissionDenied, respType, d), "write")
+
+	respType, d = suite.request(fxpOpen, func(e *encoder) {
+		e.string("/vms/vm").uint32(fxfRead).uint32(0)
+	})
+	suite.Contains(suite.assertStatus(fxFailure, respType, d), "directory")
+}
+
+func (suite *SessionTestSuite) TestUnsupportedRequest() {
+	const fxpRemove = 13
+	respType, d := suite.request(fxpRemove, func(e *encoder) { e.string("/vms/vm/motd") })
+	suite.assertStatus(fxOpUnsupported, respType, d)
+}
+
+func TestSession(t *testing.T) {
+	suite.Run(t, new(SessionTestSuite))
+}
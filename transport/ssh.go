@@ -187,9 +187,14 @@ type Identity struct {
 	Retries uint
 }
 
+// SSHExecOptions are the exec options that ExecSSH honors. Entries that use
+// ExecSSH return them from HonoredExecOptions.
+var SSHExecOptions = []string{plugin.EnvExecOption, plugin.CwdExecOption}
+
 // ExecSSH executes against a target via SSH. It will look up port, user, and other configuration
 // by exact hostname match from default SSH config files. Identity can be used to override the
 // user configured in SSH config. If opts.Elevate is true, will attempt to `sudo` as root.
+// opts.Env and opts.Cwd are applied via `env` and `cd` on the remote shell.
 //
 // If present, a local SSH agent will be used for authentication.
 //
@@ -232,11 +237,20 @@ func ExecSSH(ctx context.Context, id Identity, cmd []string, opts plugin.ExecOpt
 	execCmd := plugin.NewExecCommand(ctx)
 	session.Stdin, session.Stdout, session.Stderr = opts.Stdin, execCmd.Stdout(), execCmd.Stderr()
 
+	if len(opts.Env) > 0 {
+		// Use env instead of session.Setenv because most sshd configs only
+		// accept a few variables (via AcceptEnv). It also ensures that the
+		// variables survive sudo.
+		cmd = append(append([]string{"env"}, opts.Environ()...), cmd...)
+	}
 	if opts.Elevate {
 		cmd = append([]string{"sudo"}, cmd...)
 	}
 
 	cmdStr := shellquote.Join(cmd...)
+	if opts.Cwd != "" {
+		cmdStr = "cd " + shellquote.Join(opts.Cwd) + " && " + cmdStr
+	}
 	if err := session.Start(cmdStr); err != nil {
 		return nil, err
 	}